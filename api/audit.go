@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/YaleSpinup/ecs-api/orchestration"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+type contextKey string
+
+const (
+	// requestIDKey is the context key for the request id
+	requestIDKey contextKey = "requestID"
+	// auditEventKey is the context key for the audit event of a mutating request
+	auditEventKey contextKey = "auditEvent"
+)
+
+// RequestIDHeader is the header used to pass and return the request id
+const RequestIDHeader = "X-Request-Id"
+
+// AuditEvent is the structured audit record of a mutating api call
+type AuditEvent struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId"`
+	Operation string    `json:"operation"`
+	Account   string    `json:"account,omitempty"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Service   string    `json:"service,omitempty"`
+	Family    string    `json:"family,omitempty"`
+	Org       string    `json:"org,omitempty"`
+	// SourceIP, ForwardedFor and UserAgent identify the caller, requests are authenticated with a shared token
+	SourceIP     string `json:"sourceIp,omitempty"`
+	ForwardedFor string `json:"forwardedFor,omitempty"`
+	UserAgent    string `json:"userAgent,omitempty"`
+	Status       int    `json:"status"`
+	Outcome      string `json:"outcome"`
+}
+
+// auditSink receives the audit events
+type auditSink interface {
+	Audit(event *AuditEvent)
+}
+
+// jsonAuditSink writes audit events as lines of JSON
+type jsonAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Audit writes the audit event as a single line of JSON
+func (j *jsonAuditSink) Audit(event *AuditEvent) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		log.Errorf("failed to marshal audit event %+v: %s", event, err)
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.w.Write(append(b, '\n')); err != nil {
+		log.Errorf("failed to write audit event %s: %s", string(b), err)
+	}
+}
+
+// statusWriter records the status code written to the response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code and writes it to the response
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// requestIDMiddleware sets the request id from the request header (or a new one) on the request context and the response
+func requestIDMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = uuid.NewV4().String()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// requestID returns the request id from the context or a new uuid if there isn't one
+func requestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey).(string); ok && id != "" {
+		return id
+	}
+	return uuid.NewV4().String()
+}
+
+// auditMiddleware emits an audit event to the audit sink for every mutating (POST, PUT, PATCH or DELETE) request
+func (s *server) auditMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.auditor == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		operation := r.Method + " " + r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if tmpl, err := route.GetPathTemplate(); err == nil {
+				operation = r.Method + " " + tmpl
+			}
+		}
+
+		sourceIP := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			sourceIP = host
+		}
+
+		vars := mux.Vars(r)
+		event := &AuditEvent{
+			Time:         time.Now().UTC(),
+			RequestID:    requestID(r.Context()),
+			Operation:    operation,
+			Account:      vars["account"],
+			Cluster:      vars["cluster"],
+			Service:      vars["service"],
+			Family:       vars["taskdef"],
+			SourceIP:     sourceIP,
+			ForwardedFor: r.Header.Get("X-Forwarded-For"),
+			UserAgent:    r.UserAgent(),
+		}
+
+		// the org of the cluster is looked up before a delete since the cluster may be deleted by it, other requests
+		// look it up afterwards (unless the handler set it) so the request isn't held up by the lookup
+		clusterScoped := event.Account != "" && event.Cluster != ""
+		if clusterScoped && r.Method == http.MethodDelete {
+			event.Org = s.clusterOrg(r.Context(), event.Account, event.Cluster)
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), auditEventKey, event)))
+
+		if clusterScoped && r.Method != http.MethodDelete && event.Org == "" {
+			event.Org = s.clusterOrg(r.Context(), event.Account, event.Cluster)
+		}
+
+		if event.Org == "" {
+			event.Org = s.org
+		}
+
+		event.Status = sw.status
+		event.Outcome = "success"
+		if sw.status >= http.StatusBadRequest {
+			event.Outcome = "failure"
+		}
+
+		s.auditor.Audit(event)
+	})
+}
+
+// auditResource sets the resources of the audit event for requests that pass them in the body instead of the path
+func auditResource(r *http.Request, cluster, service, family string) {
+	event, ok := r.Context().Value(auditEventKey).(*AuditEvent)
+	if !ok {
+		return
+	}
+
+	if cluster != "" {
+		event.Cluster = cluster
+	}
+
+	if service != "" {
+		event.Service = service
+	}
+
+	if family != "" {
+		event.Family = family
+	}
+}
+
+// auditOrg sets the org of the audit event for requests that pass it in the body, ie. the spinup:org tag of a new resource
+func auditOrg(r *http.Request, org string) {
+	event, ok := r.Context().Value(auditEventKey).(*AuditEvent)
+	if !ok || org == "" {
+		return
+	}
+
+	event.Org = org
+}
+
+// requestOrg returns the org from the spinup:org (or yale:org) tag passed in a request or the server org if there isn't one
+func (s *server) requestOrg(tags []*orchestration.Tag) string {
+	for _, t := range tags {
+		if k := aws.StringValue(t.Key); (k == "spinup:org" || k == "yale:org") && aws.StringValue(t.Value) != "" {
+			return aws.StringValue(t.Value)
+		}
+	}
+	return s.org
+}
+
+// clusterOrg returns the spinup:org tag of a cluster in the account or an empty string if it can't be determined
+func (s *server) clusterOrg(ctx context.Context, account, cluster string) string {
+	ecsService, ok := s.ecsServices[account]
+	if !ok {
+		return ""
+	}
+
+	c, err := ecsService.GetCluster(ctx, aws.String(cluster))
+	if err != nil {
+		log.Debugf("unable to get cluster %s for the audit event: %s", cluster, err)
+		return ""
+	}
+
+	tags, err := ecsService.ListTags(ctx, aws.StringValue(c.ClusterArn))
+	if err != nil {
+		log.Debugf("unable to list tags of cluster %s for the audit event: %s", cluster, err)
+		return ""
+	}
+
+	for _, t := range tags {
+		if aws.StringValue(t.Key) == "spinup:org" {
+			return aws.StringValue(t.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YaleSpinup/ecs-api/ecs"
+	"github.com/gorilla/mux"
+)
+
+// mockAuditSink collects the audit events
+type mockAuditSink struct {
+	mu     sync.Mutex
+	events []*AuditEvent
+}
+
+func (m *mockAuditSink) Audit(event *AuditEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	sink := &mockAuditSink{}
+	s := &server{
+		router:  mux.NewRouter(),
+		org:     "myorg",
+		auditor: sink,
+		ecsServices: map[string]ecs.ECS{
+			"acct1": {Service: &mockECSClient{t: t}},
+		},
+	}
+
+	api := s.router.PathPrefix("/v1/ecs").Subrouter()
+	api.Use(s.auditMiddleware)
+	api.HandleFunc("/{account}/services", func(w http.ResponseWriter, r *http.Request) {
+		auditResource(r, "clu1", "svc1", "fam1")
+		auditOrg(r, r.URL.Query().Get("org"))
+		w.WriteHeader(http.StatusOK)
+	}).Methods(http.MethodPost)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods(http.MethodDelete)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}", func(w http.ResponseWriter, r *http.Request) {
+		auditOrg(r, r.URL.Query().Get("org"))
+		w.WriteHeader(http.StatusOK)
+	}).Methods(http.MethodPut)
+
+	handler := requestIDMiddleware(s.router)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		requestID string
+		forwarded string
+		want      *AuditEvent
+	}{
+		{
+			name:      "create",
+			method:    http.MethodPost,
+			path:      "/v1/ecs/acct1/services",
+			requestID: "req-create",
+			want: &AuditEvent{
+				RequestID: "req-create",
+				Operation: "POST /v1/ecs/{account}/services",
+				Account:   "acct1",
+				Cluster:   "clu1",
+				Service:   "svc1",
+				Family:    "fam1",
+				Org:       "myorg",
+				SourceIP:  "192.0.2.1",
+				UserAgent: "spinup-test",
+				Status:    http.StatusOK,
+				Outcome:   "success",
+			},
+		},
+		{
+			name:      "create in another org",
+			method:    http.MethodPost,
+			path:      "/v1/ecs/acct1/services?org=otherorg",
+			requestID: "req-create-other",
+			want: &AuditEvent{
+				RequestID: "req-create-other",
+				Operation: "POST /v1/ecs/{account}/services",
+				Account:   "acct1",
+				Cluster:   "clu1",
+				Service:   "svc1",
+				Family:    "fam1",
+				Org:       "otherorg",
+				SourceIP:  "192.0.2.1",
+				UserAgent: "spinup-test",
+				Status:    http.StatusOK,
+				Outcome:   "success",
+			},
+		},
+		{
+			name:      "update in a cluster of another org",
+			method:    http.MethodPut,
+			path:      "/v1/ecs/acct1/clusters/clu2/services/svc1",
+			requestID: "req-update-other",
+			want: &AuditEvent{
+				RequestID: "req-update-other",
+				Operation: "PUT /v1/ecs/{account}/clusters/{cluster}/services/{service}",
+				Account:   "acct1",
+				Cluster:   "clu2",
+				Service:   "svc1",
+				Org:       "otherorg",
+				SourceIP:  "192.0.2.1",
+				UserAgent: "spinup-test",
+				Status:    http.StatusOK,
+				Outcome:   "success",
+			},
+		},
+		{
+			name:      "update with the org set by the handler",
+			method:    http.MethodPut,
+			path:      "/v1/ecs/acct1/clusters/missing/services/svc1?org=thirdorg",
+			requestID: "req-update-org",
+			want: &AuditEvent{
+				RequestID: "req-update-org",
+				Operation: "PUT /v1/ecs/{account}/clusters/{cluster}/services/{service}",
+				Account:   "acct1",
+				Cluster:   "missing",
+				Service:   "svc1",
+				Org:       "thirdorg",
+				SourceIP:  "192.0.2.1",
+				UserAgent: "spinup-test",
+				Status:    http.StatusOK,
+				Outcome:   "success",
+			},
+		},
+		{
+			name:   "get isn't audited",
+			method: http.MethodGet,
+			path:   "/v1/ecs/acct1/clusters/clu1/services/svc1",
+		},
+		{
+			name:      "failed delete",
+			method:    http.MethodDelete,
+			path:      "/v1/ecs/acct1/clusters/clu1/services/svc2",
+			requestID: "req-delete",
+			want: &AuditEvent{
+				RequestID: "req-delete",
+				Operation: "DELETE /v1/ecs/{account}/clusters/{cluster}/services/{service}",
+				Account:   "acct1",
+				Cluster:   "clu1",
+				Service:   "svc2",
+				Org:       "myorg",
+				SourceIP:  "192.0.2.1",
+				UserAgent: "spinup-test",
+				Status:    http.StatusNotFound,
+				Outcome:   "failure",
+			},
+		},
+		{
+			name:      "delete in a cluster of another org",
+			method:    http.MethodDelete,
+			path:      "/v1/ecs/acct1/clusters/clu2/services/svc1",
+			requestID: "req-delete-other",
+			forwarded: "198.51.100.7",
+			want: &AuditEvent{
+				RequestID:    "req-delete-other",
+				Operation:    "DELETE /v1/ecs/{account}/clusters/{cluster}/services/{service}",
+				Account:      "acct1",
+				Cluster:      "clu2",
+				Service:      "svc1",
+				Org:          "otherorg",
+				SourceIP:     "192.0.2.1",
+				ForwardedFor: "198.51.100.7",
+				UserAgent:    "spinup-test",
+				Status:       http.StatusNotFound,
+				Outcome:      "failure",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink.events = nil
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			req.Header.Set("User-Agent", "spinup-test")
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if id := rr.Header().Get(RequestIDHeader); tt.requestID != "" && id != tt.requestID {
+				t.Errorf("expected request id header %s, got %s", tt.requestID, id)
+			}
+
+			if tt.want == nil {
+				if len(sink.events) != 0 {
+					t.Errorf("expected no audit events, got %+v", sink.events)
+				}
+				return
+			}
+
+			if len(sink.events) != 1 {
+				t.Fatalf("expected 1 audit event, got %d", len(sink.events))
+			}
+
+			got := sink.events[0]
+			if got.Time.IsZero() {
+				t.Error("expected audit event time to be set")
+			}
+			got.Time = time.Time{}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected audit event %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestServiceCreateHandlerAudit(t *testing.T) {
+	sink := &mockAuditSink{}
+	s := &server{router: mux.NewRouter(), org: "myorg", auditor: sink}
+	s.routes()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/ecs/missing/services", strings.NewReader("{}"))
+	rr := httptest.NewRecorder()
+	requestIDMiddleware(s.router).ServeHTTP(rr, req)
+
+	if len(sink.events) != 1 {
+		t.Fatalf("expected 1 audit event, got %d", len(sink.events))
+	}
+
+	got := sink.events[0]
+	if got.RequestID == "" || got.RequestID != rr.Header().Get(RequestIDHeader) {
+		t.Errorf("expected audit request id to match the response header %s, got %s", rr.Header().Get(RequestIDHeader), got.RequestID)
+	}
+
+	if got.Operation != "POST /v1/ecs/{account}/services" || got.Account != "missing" || got.Org != "myorg" {
+		t.Errorf("unexpected audit event %+v", got)
+	}
+
+	if got.Status != http.StatusNotFound || got.Outcome != "failure" {
+		t.Errorf("expected failed audit event with status 404, got %+v", got)
+	}
+}
+
+func TestJSONAuditSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := &jsonAuditSink{w: buf}
+
+	sink.Audit(&AuditEvent{
+		Time:      time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		RequestID: "req1",
+		Operation: "POST /v1/ecs/{account}/taskdefs",
+		Account:   "acct1",
+		Cluster:   "clu1",
+		Family:    "fam1",
+		Org:       "myorg",
+		Status:    http.StatusOK,
+		Outcome:   "success",
+	})
+
+	if !strings.HasSuffix(buf.String(), "\n") {
+		t.Errorf("expected audit event to end with a newline, got %q", buf.String())
+	}
+
+	got := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("expected JSON audit event, got %s: %s", buf.String(), err)
+	}
+
+	want := map[string]interface{}{
+		"time":      "2020-01-01T00:00:00Z",
+		"requestId": "req1",
+		"operation": "POST /v1/ecs/{account}/taskdefs",
+		"account":   "acct1",
+		"cluster":   "clu1",
+		"family":    "fam1",
+		"org":       "myorg",
+		"status":    float64(200),
+		"outcome":   "success",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected audit event %v, got %v", want, got)
+	}
+}
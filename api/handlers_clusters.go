@@ -0,0 +1,307 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/YaleSpinup/ecs-api/orchestration"
+	"github.com/aws/aws-sdk-go/aws"
+	awsecs "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ClusterSettingsRequest is the request to update the settings of a cluster
+type ClusterSettingsRequest struct {
+	Settings []*awsecs.ClusterSetting
+}
+
+// ClusterInventory is a read only snapshot of a cluster and the resources in its space.  Each section is collected
+// independently, a section that can't be collected is left empty and its error is reported in Errors by section name.
+type ClusterInventory struct {
+	Cluster         *awsecs.Cluster
+	Services        []string
+	TaskDefinitions []string
+	Parameters      []string
+	Secrets         []string
+	Errors          map[string]string `json:",omitempty"`
+}
+
+// ClusterTagsHandler gets the tags for a cluster
+func (s *server) ClusterTagsHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	ecsService, ok := s.ecsServices[account]
+	if !ok {
+		msg := fmt.Sprintf("ecs service not found for account: %s", account)
+		handleError(w, apierror.New(apierror.ErrNotFound, msg, nil))
+		return
+	}
+
+	clu, err := ecsService.GetCluster(r.Context(), aws.String(cluster))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	// deleted clusters are still described for a while with an INACTIVE status
+	if aws.StringValue(clu.Status) == "INACTIVE" {
+		msg := fmt.Sprintf("cluster %s not found", cluster)
+		handleError(w, apierror.New(apierror.ErrNotFound, msg, nil))
+		return
+	}
+
+	tags, err := ecsService.ListTags(r.Context(), aws.StringValue(clu.ClusterArn))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(tags)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ClusterListHandler lists the clusters in the org
+func (s *server) ClusterListHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.ListClusters(r.Context())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ClusterSummaryHandler summarizes the services, tasks and requested cpu and memory in a cluster
+func (s *server) ClusterSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.ClusterSummary(r.Context(), cluster)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ClusterInventoryHandler gets a snapshot of a cluster, its services, its task definition families and the parameters
+// and secrets under the org/cluster prefix for support tooling
+func (s *server) ClusterInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	ssmService, ok := s.ssmServices[account]
+	if !ok {
+		msg := fmt.Sprintf("ssm service not found for account: %s", account)
+		handleError(w, apierror.New(apierror.ErrNotFound, msg, nil))
+		return
+	}
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output := &ClusterInventory{
+		Services:        []string{},
+		TaskDefinitions: []string{},
+		Parameters:      []string{},
+		Secrets:         []string{},
+		Errors:          map[string]string{},
+	}
+
+	sectionError := func(section string, err error) {
+		log.Warnf("failed to get %s for the inventory of cluster %s: %s", section, cluster, err)
+
+		if aerr, ok := errors.Cause(err).(apierror.Error); ok {
+			output.Errors[section] = aerr.Message
+			return
+		}
+		output.Errors[section] = err.Error()
+	}
+
+	if clu, err := orchestrator.ECS.GetCluster(r.Context(), aws.String(cluster)); err != nil {
+		sectionError("Cluster", err)
+	} else {
+		output.Cluster = clu
+	}
+
+	if services, err := orchestrator.ECS.ListServices(r.Context(), cluster); err != nil {
+		sectionError("Services", err)
+	} else {
+		output.Services = services
+	}
+
+	if families, err := orchestrator.ListTaskDefs(r.Context(), cluster); err != nil {
+		sectionError("TaskDefinitions", err)
+	} else {
+		sort.Strings(families)
+		output.TaskDefinitions = families
+	}
+
+	if params, err := ssmService.ListParametersByPath(r.Context(), fmt.Sprintf("/%s/%s", s.org, cluster)); err != nil {
+		sectionError("Parameters", err)
+	} else {
+		output.Parameters = params
+	}
+
+	prefix := fmt.Sprintf("spinup/%s/%s/", s.org, cluster)
+	if secrets, err := orchestrator.SecretsManager.ListSecretsWithFilter(r.Context(), func(secret *secretsmanager.SecretListEntry) bool {
+		return strings.HasPrefix(aws.StringValue(secret.Name), prefix)
+	}); err != nil {
+		sectionError("Secrets", err)
+	} else {
+		output.Secrets = aws.StringValueSlice(secrets)
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ClusterSettingsHandler gets (GET) or updates (PUT) the settings of a cluster, ie. enabling container insights.  Only
+// clusters tagged with an allowed org can be managed.
+func (s *server) ClusterSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	ecsService, ok := s.ecsServices[account]
+	if !ok {
+		msg := fmt.Sprintf("ecs service not found for account: %s", account)
+		handleError(w, apierror.New(apierror.ErrNotFound, msg, nil))
+		return
+	}
+
+	clu, err := ecsService.GetCluster(r.Context(), aws.String(cluster))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if aws.StringValue(clu.Status) == "INACTIVE" {
+		msg := fmt.Sprintf("cluster %s not found", cluster)
+		handleError(w, apierror.New(apierror.ErrNotFound, msg, nil))
+		return
+	}
+
+	tags, err := ecsService.ListTags(r.Context(), aws.StringValue(clu.ClusterArn))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if !s.clusterInOrg(tags) {
+		msg := fmt.Sprintf("cluster %s not found", cluster)
+		handleError(w, apierror.New(apierror.ErrNotFound, msg, nil))
+		return
+	}
+
+	var settings []*awsecs.ClusterSetting
+	switch r.Method {
+	case http.MethodPut:
+		var req ClusterSettingsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleError(w, apierror.New(apierror.ErrBadRequest, "unable to decode json into input", err))
+			return
+		}
+
+		auditResource(r, cluster, "", "")
+
+		log.Infof("updating settings for cluster %s", cluster)
+
+		settings, err = ecsService.UpdateClusterSettings(r.Context(), aws.String(cluster), req.Settings)
+	default:
+		settings, err = ecsService.GetClusterSettings(r.Context(), aws.String(cluster))
+	}
+
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if settings == nil {
+		settings = []*awsecs.ClusterSetting{}
+	}
+
+	j, err := json.Marshal(settings)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// clusterInOrg returns true if the list of cluster tags has a spinup:org tag matching the server org
+// or one of the allowed orgs
+func (s *server) clusterInOrg(tags []*awsecs.Tag) bool {
+	for _, t := range tags {
+		if aws.StringValue(t.Key) == "spinup:org" && orchestration.AllowedOrg(s.org, s.orgs, aws.StringValue(t.Value)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,467 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/YaleSpinup/ecs-api/applicationautoscaling"
+	"github.com/YaleSpinup/ecs-api/cloudwatch"
+	"github.com/YaleSpinup/ecs-api/cloudwatchlogs"
+	"github.com/YaleSpinup/ecs-api/ecs"
+	"github.com/YaleSpinup/ecs-api/eventbridge"
+	"github.com/YaleSpinup/ecs-api/iam"
+	"github.com/YaleSpinup/ecs-api/kms"
+	"github.com/YaleSpinup/ecs-api/resourcegroupstaggingapi"
+	"github.com/YaleSpinup/ecs-api/secretsmanager"
+	"github.com/YaleSpinup/ecs-api/servicediscovery"
+	"github.com/YaleSpinup/ecs-api/ssm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsecs "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	rgta "github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+	awssm "github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	awsssm "github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/gorilla/mux"
+)
+
+type mockECSClient struct {
+	ecsiface.ECSAPI
+	t        *testing.T
+	err      error
+	settings []*awsecs.ClusterSetting
+	// services are the service arns listed in each cluster
+	services map[string][]string
+}
+
+type mockRGTAClient struct {
+	resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+	t   *testing.T
+	err error
+	// resources are the resource arns returned for any filter
+	resources []string
+}
+
+type mockSMClient struct {
+	secretsmanageriface.SecretsManagerAPI
+	t   *testing.T
+	err error
+	// secrets are the names of the secrets in the account
+	secrets []string
+	// orgs are the spinup:org tags of the secrets, by name
+	orgs map[string]string
+}
+
+var testClusterTags = map[string][]*awsecs.Tag{
+	"arn:aws:ecs:us-east-1:0123456789:cluster/clu1": {
+		{Key: aws.String("spinup:org"), Value: aws.String("myorg")},
+		{Key: aws.String("spinup:spaceid"), Value: aws.String("clu1")},
+	},
+	"arn:aws:ecs:us-east-1:0123456789:cluster/clu2": {
+		{Key: aws.String("spinup:org"), Value: aws.String("otherorg")},
+	},
+}
+
+func (m *mockECSClient) DescribeClustersWithContext(ctx aws.Context, input *awsecs.DescribeClustersInput, opts ...request.Option) (*awsecs.DescribeClustersOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	output := &awsecs.DescribeClustersOutput{}
+	for _, c := range input.Clusters {
+		switch name := aws.StringValue(c); name {
+		case "clu1", "clu2", "deleted":
+			status := "ACTIVE"
+			if name == "deleted" {
+				status = "INACTIVE"
+			}
+
+			output.Clusters = append(output.Clusters, &awsecs.Cluster{
+				ClusterArn:  aws.String("arn:aws:ecs:us-east-1:0123456789:cluster/" + name),
+				ClusterName: c,
+				Status:      aws.String(status),
+				Settings:    m.settings,
+			})
+		default:
+			output.Failures = append(output.Failures, &awsecs.Failure{Arn: c, Reason: aws.String("MISSING")})
+		}
+	}
+
+	return output, nil
+}
+
+func (m *mockECSClient) ListServicesWithContext(ctx aws.Context, input *awsecs.ListServicesInput, opts ...request.Option) (*awsecs.ListServicesOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &awsecs.ListServicesOutput{ServiceArns: aws.StringSlice(m.services[aws.StringValue(input.Cluster)])}, nil
+}
+
+func (m *mockRGTAClient) GetResourcesWithContext(ctx aws.Context, input *rgta.GetResourcesInput, opts ...request.Option) (*rgta.GetResourcesOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	out := &rgta.GetResourcesOutput{}
+	for _, r := range m.resources {
+		out.ResourceTagMappingList = append(out.ResourceTagMappingList, &rgta.ResourceTagMapping{ResourceARN: aws.String(r)})
+	}
+
+	return out, nil
+}
+
+func (m *mockSMClient) ListSecretsWithContext(ctx aws.Context, input *awssm.ListSecretsInput, opts ...request.Option) (*awssm.ListSecretsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	out := &awssm.ListSecretsOutput{}
+	for _, n := range m.secrets {
+		entry := &awssm.SecretListEntry{
+			ARN:  aws.String("arn:aws:secretsmanager:us-east-1:0123456789:secret:" + n + "-AbCdEf"),
+			Name: aws.String(n),
+		}
+
+		if org, ok := m.orgs[n]; ok {
+			entry.Tags = []*awssm.Tag{{Key: aws.String("spinup:org"), Value: aws.String(org)}}
+		}
+
+		out.SecretList = append(out.SecretList, entry)
+	}
+
+	return out, nil
+}
+
+func (m *mockECSClient) ListTagsForResourceWithContext(ctx aws.Context, input *awsecs.ListTagsForResourceInput, opts ...request.Option) (*awsecs.ListTagsForResourceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &awsecs.ListTagsForResourceOutput{Tags: testClusterTags[aws.StringValue(input.ResourceArn)]}, nil
+}
+
+func (m *mockECSClient) UpdateClusterSettingsWithContext(ctx aws.Context, input *awsecs.UpdateClusterSettingsInput, opts ...request.Option) (*awsecs.UpdateClusterSettingsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	m.settings = input.Settings
+
+	return &awsecs.UpdateClusterSettingsOutput{
+		Cluster: &awsecs.Cluster{
+			ClusterName: input.Cluster,
+			Settings:    input.Settings,
+		},
+	}, nil
+}
+
+func TestClusterTagsHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		account    string
+		cluster    string
+		err        error
+		wantStatus int
+		wantTags   []*awsecs.Tag
+	}{
+		{
+			name:       "cluster tags",
+			account:    "acct1",
+			cluster:    "clu1",
+			wantStatus: http.StatusOK,
+			wantTags:   testClusterTags["arn:aws:ecs:us-east-1:0123456789:cluster/clu1"],
+		},
+		{
+			name:       "missing cluster",
+			account:    "acct1",
+			cluster:    "missing",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "deleted cluster",
+			account:    "acct1",
+			cluster:    "deleted",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "missing account",
+			account:    "acct2",
+			cluster:    "clu1",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "ecs error",
+			account:    "acct1",
+			cluster:    "clu1",
+			err:        awserr.New(awsecs.ErrCodeServerException, "boom", nil),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := server{
+				ecsServices: map[string]ecs.ECS{
+					"acct1": {Service: &mockECSClient{t: t, err: tt.err}},
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/ecs/"+tt.account+"/clusters/"+tt.cluster+"/tags", nil)
+			req = mux.SetURLVars(req, map[string]string{"account": tt.account, "cluster": tt.cluster})
+			rr := httptest.NewRecorder()
+
+			s.ClusterTagsHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got []*awsecs.Tag
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to unmarshal response: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.wantTags) {
+				t.Errorf("expected tags %+v, got %+v", tt.wantTags, got)
+			}
+		})
+	}
+}
+
+func TestClusterSettingsHandler(t *testing.T) {
+	insightsDisabled := []*awsecs.ClusterSetting{
+		{Name: aws.String("containerInsights"), Value: aws.String("disabled")},
+	}
+	insightsEnabled := []*awsecs.ClusterSetting{
+		{Name: aws.String("containerInsights"), Value: aws.String("enabled")},
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		cluster      string
+		body         string
+		wantStatus   int
+		wantSettings []*awsecs.ClusterSetting
+	}{
+		{
+			name:         "get settings",
+			method:       http.MethodGet,
+			cluster:      "clu1",
+			wantStatus:   http.StatusOK,
+			wantSettings: insightsDisabled,
+		},
+		{
+			name:         "enable container insights",
+			method:       http.MethodPut,
+			cluster:      "clu1",
+			body:         `{"Settings":[{"Name":"containerInsights","Value":"enabled"}]}`,
+			wantStatus:   http.StatusOK,
+			wantSettings: insightsEnabled,
+		},
+		{
+			name:         "unsupported setting name",
+			method:       http.MethodPut,
+			cluster:      "clu1",
+			body:         `{"Settings":[{"Name":"fooInsights","Value":"enabled"}]}`,
+			wantStatus:   http.StatusBadRequest,
+			wantSettings: insightsDisabled,
+		},
+		{
+			name:         "unsupported setting value",
+			method:       http.MethodPut,
+			cluster:      "clu1",
+			body:         `{"Settings":[{"Name":"containerInsights","Value":"on"}]}`,
+			wantStatus:   http.StatusBadRequest,
+			wantSettings: insightsDisabled,
+		},
+		{
+			name:         "bad json",
+			method:       http.MethodPut,
+			cluster:      "clu1",
+			body:         `{"Settings":`,
+			wantStatus:   http.StatusBadRequest,
+			wantSettings: insightsDisabled,
+		},
+		{
+			name:         "cluster in another org",
+			method:       http.MethodPut,
+			cluster:      "clu2",
+			body:         `{"Settings":[{"Name":"containerInsights","Value":"enabled"}]}`,
+			wantStatus:   http.StatusNotFound,
+			wantSettings: insightsDisabled,
+		},
+		{
+			name:         "missing cluster",
+			method:       http.MethodGet,
+			cluster:      "missing",
+			wantStatus:   http.StatusNotFound,
+			wantSettings: insightsDisabled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockECSClient{t: t, settings: insightsDisabled}
+			s := server{
+				org: "myorg",
+				ecsServices: map[string]ecs.ECS{
+					"acct1": {Service: client},
+				},
+			}
+
+			req := httptest.NewRequest(tt.method, "/v1/ecs/acct1/clusters/"+tt.cluster+"/settings", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"account": "acct1", "cluster": tt.cluster})
+			rr := httptest.NewRecorder()
+
+			s.ClusterSettingsHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if !reflect.DeepEqual(client.settings, tt.wantSettings) {
+				t.Errorf("expected cluster settings %s, got %s", awsutil.Prettify(tt.wantSettings), awsutil.Prettify(client.settings))
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got []*awsecs.ClusterSetting
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to unmarshal response: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.wantSettings) {
+				t.Errorf("expected settings %s, got %s", awsutil.Prettify(tt.wantSettings), awsutil.Prettify(got))
+			}
+		})
+	}
+}
+
+func TestClusterInventoryHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		account    string
+		ssmErr     error
+		wantStatus int
+		want       *ClusterInventory
+	}{
+		{
+			name:       "composed snapshot",
+			account:    "acct1",
+			wantStatus: http.StatusOK,
+			want: &ClusterInventory{
+				Services:        []string{"arn:aws:ecs:us-east-1:0123456789:service/clu1/svc1"},
+				TaskDefinitions: []string{"batchjob", "webapp"},
+				Parameters:      []string{"db/password", "db/username"},
+				Secrets:         []string{"arn:aws:secretsmanager:us-east-1:0123456789:secret:spinup/myorg/clu1/svc1-web-AbCdEf"},
+			},
+		},
+		{
+			name:       "parameters error",
+			account:    "acct1",
+			ssmErr:     awserr.New(awsssm.ErrCodeInternalServerError, "boom", nil),
+			wantStatus: http.StatusOK,
+			want: &ClusterInventory{
+				Services:        []string{"arn:aws:ecs:us-east-1:0123456789:service/clu1/svc1"},
+				TaskDefinitions: []string{"batchjob", "webapp"},
+				Parameters:      []string{},
+				Secrets:         []string{"arn:aws:secretsmanager:us-east-1:0123456789:secret:spinup/myorg/clu1/svc1-web-AbCdEf"},
+				Errors:          map[string]string{"Parameters": "failed to list parameters"},
+			},
+		},
+		{
+			name:       "missing account",
+			account:    "acct2",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := server{
+				org:            "myorg",
+				aasServices:    map[string]applicationautoscaling.ApplicationAutoScaling{"acct1": {}},
+				cwServices:     map[string]cloudwatch.CloudWatch{"acct1": {}},
+				cwLogsServices: map[string]cloudwatchlogs.CloudWatchLogs{"acct1": {}},
+				ecsServices: map[string]ecs.ECS{
+					"acct1": {Service: &mockECSClient{
+						t:        t,
+						services: map[string][]string{"clu1": {"arn:aws:ecs:us-east-1:0123456789:service/clu1/svc1"}},
+					}},
+				},
+				ebServices:  map[string]eventbridge.EventBridge{"acct1": {}},
+				iamServices: map[string]iam.IAM{"acct1": {}},
+				kmsServices: map[string]kms.KMS{"acct1": {}},
+				rgTaggingAPIServices: map[string]resourcegroupstaggingapi.ResourceGroupsTaggingAPI{
+					"acct1": {Service: &mockRGTAClient{
+						t: t,
+						resources: []string{
+							"arn:aws:ecs:us-east-1:0123456789:task-definition/webapp:1",
+							"arn:aws:ecs:us-east-1:0123456789:task-definition/webapp:2",
+							"arn:aws:ecs:us-east-1:0123456789:task-definition/batchjob:1",
+						},
+					}},
+				},
+				sdServices: map[string]servicediscovery.ServiceDiscovery{"acct1": {}},
+				smServices: map[string]secretsmanager.SecretsManager{
+					"acct1": {Service: &mockSMClient{
+						t:       t,
+						secrets: []string{"spinup/myorg/clu1/svc1-web", "spinup/myorg/clu2/svc2-web", "spinup/otherorg/clu1/svc1-web"},
+					}},
+				},
+				ssmServices: map[string]ssm.SSM{
+					"acct1": {Service: &mockSSMClient{
+						t:   t,
+						err: tt.ssmErr,
+						params: map[string]string{
+							"/myorg/clu1/db/username": "admin",
+							"/myorg/clu1/db/password": "sshhh",
+							"/myorg/clu2/db/username": "other",
+						},
+					}},
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/ecs/"+tt.account+"/clusters/clu1/inventory", nil)
+			req = mux.SetURLVars(req, map[string]string{"account": tt.account, "cluster": "clu1"})
+			rr := httptest.NewRecorder()
+
+			s.ClusterInventoryHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got ClusterInventory
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to unmarshal response: %s", err)
+			}
+
+			if got.Cluster == nil || aws.StringValue(got.Cluster.ClusterName) != "clu1" {
+				t.Errorf("expected cluster clu1, got %s", awsutil.Prettify(got.Cluster))
+			}
+			got.Cluster = nil
+
+			if !reflect.DeepEqual(&got, tt.want) {
+				t.Errorf("expected inventory %s, got %s", awsutil.Prettify(tt.want), awsutil.Prettify(got))
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/YaleSpinup/apierror"
 	"github.com/YaleSpinup/ecs-api/elbv2"
+	"github.com/YaleSpinup/ecs-api/orchestration"
 	"github.com/YaleSpinup/ecs-api/resourcegroupstaggingapi"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/gorilla/mux"
@@ -79,7 +80,7 @@ func (s *server) LoadBalancerListHandler(w http.ResponseWriter, r *http.Request)
 	tagFilters := []*resourcegroupstaggingapi.TagFilter{
 		{
 			Key:   "spinup:org",
-			Value: []string{s.org},
+			Value: orchestration.Orgs(s.org, s.orgs),
 		},
 		{
 			Key:   "spinup:spaceid",
@@ -148,7 +149,7 @@ func (s *server) LoadBalancerDescribeHandler(w http.ResponseWriter, r *http.Requ
 	tagFilters := []*resourcegroupstaggingapi.TagFilter{
 		{
 			Key:   "spinup:org",
-			Value: []string{s.org},
+			Value: orchestration.Orgs(s.org, s.orgs),
 		},
 		{
 			Key:   "spinup:spaceid",
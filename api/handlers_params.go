@@ -4,14 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/YaleSpinup/apierror"
+	"github.com/YaleSpinup/ecs-api/orchestration"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ssm"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 )
 
 // ParamListHandler lists the params tagged with the org
@@ -51,6 +54,76 @@ func (s *server) ParamListHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
+// ParamExportHandler lists the params tagged with the org along with their decrypted values.  Since this
+// exposes secret values, the caller must explicitly ask for them with withValues=true.
+func (s *server) ParamExportHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	ssmService, ok := s.ssmServices[account]
+	if !ok {
+		msg := fmt.Sprintf("ssm service not found for account: %s", account)
+		handleError(w, apierror.New(apierror.ErrNotFound, msg, nil))
+		return
+	}
+
+	prefix := vars["prefix"]
+	if prefix == "" {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "prefix is required", nil))
+		return
+	}
+
+	if withValues, err := strconv.ParseBool(r.URL.Query().Get("withValues")); err != nil || !withValues {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "withValues=true is required to export parameter values", nil))
+		return
+	}
+
+	path := fmt.Sprintf("/%s/%s", s.org, prefix)
+	names, err := ssmService.ListParametersByPath(r.Context(), path)
+	if err != nil {
+		msg := fmt.Sprintf("unable to list params from the ssm service path %s", path)
+		handleError(w, errors.Wrap(err, msg))
+		return
+	}
+
+	log.Warnf("exporting values of %d params from the ssm service path %s in account %s for %s", len(names), path, account, r.RemoteAddr)
+
+	params, invalid, err := ssmService.GetParameters(r.Context(), path, names, true)
+	if err != nil {
+		msg := fmt.Sprintf("unable to get param values from the ssm service path %s", path)
+		handleError(w, errors.Wrap(err, msg))
+		return
+	}
+
+	// parameters deleted after they were listed are left out of the export
+	if len(invalid) > 0 {
+		log.Warnf("unable to export values of invalid params %s from the ssm service path %s", invalid, path)
+	}
+
+	type paramValue struct {
+		Name  string
+		Value string
+	}
+
+	out := make([]paramValue, 0, len(params))
+	for _, p := range params {
+		out = append(out, paramValue{
+			Name:  aws.StringValue(p.Name),
+			Value: aws.StringValue(p.Value),
+		})
+	}
+
+	j, err := json.Marshal(out)
+	if err != nil {
+		handleError(w, errors.Wrap(err, "unable to marshal response from the ssm service"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
 // ParamCreateHandler creates a parameter store parameter
 func (s *server) ParamCreateHandler(w http.ResponseWriter, r *http.Request) {
 	w = LogWriter{w}
@@ -101,8 +174,21 @@ func (s *server) ParamCreateHandler(w http.ResponseWriter, r *http.Request) {
 			newTags = append(newTags, t)
 		}
 	}
+
+	// append the default tags that weren't passed
+	for _, t := range orchestration.MergeDefaultTags(s.defaultTags, paramTags(newTags))[len(newTags):] {
+		newTags = append(newTags, &ssm.Tag{Key: t.Key, Value: t.Value})
+	}
+
+	if err := orchestration.ValidateTags(paramTags(newTags[1:])); err != nil {
+		handleError(w, err)
+		return
+	}
 	input.Tags = newTags
 
+	// creating a parameter never overwrites an existing parameter, updates go through the update handler
+	input.Overwrite = aws.Bool(false)
+
 	// default to SecureString type if none is passed
 	if aws.StringValue(input.Type) == "" {
 		input.Type = aws.String("SecureString")
@@ -157,11 +243,27 @@ func (s *server) ParamShowHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	parameter, err := ssmService.GetParameter(r.Context(), path, param)
-	if err != nil {
-		msg := fmt.Sprintf("unable to get parameter from the ssm service path %s/%s", path, param)
-		handleError(w, errors.Wrap(err, msg))
-		return
+	lastModified, version := meta.LastModifiedDate, meta.Version
+
+	var parameter *ssm.Parameter
+	label := r.URL.Query().Get("label")
+	if label != "" {
+		parameter, err = ssmService.GetParameterByLabel(r.Context(), path, param, label)
+		if err != nil {
+			msg := fmt.Sprintf("unable to get parameter with label %s from the ssm service path %s/%s", label, path, param)
+			handleError(w, errors.Wrap(err, msg))
+			return
+		}
+
+		// report the labeled version instead of the latest version
+		lastModified, version = parameter.LastModifiedDate, parameter.Version
+	} else {
+		parameter, err = ssmService.GetParameter(r.Context(), path, param)
+		if err != nil {
+			msg := fmt.Sprintf("unable to get parameter from the ssm service path %s/%s", path, param)
+			handleError(w, errors.Wrap(err, msg))
+			return
+		}
 	}
 
 	tags, err := ssmService.ListParameterTags(r.Context(), path, param)
@@ -180,6 +282,7 @@ func (s *server) ParamShowHandler(w http.ResponseWriter, r *http.Request) {
 		Tags             []*ssm.Tag
 		LastModifiedDate string
 		Version          *int64
+		Label            string `json:",omitempty"`
 	}{
 		parameter.ARN,
 		meta.Name,
@@ -187,8 +290,92 @@ func (s *server) ParamShowHandler(w http.ResponseWriter, r *http.Request) {
 		meta.KeyId,
 		meta.Type,
 		tags,
-		meta.LastModifiedDate.String(),
-		meta.Version,
+		lastModified.String(),
+		version,
+		label,
+	}
+
+	j, err := json.Marshal(out)
+	if err != nil {
+		handleError(w, errors.Wrap(err, "unable to marshal response from the ssm service"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ParamHistoryHandler gets the version history of a param.  The values of SecureString versions are only
+// decrypted if the caller asks for them with withDecryption=true.
+func (s *server) ParamHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	ssmService, ok := s.ssmServices[account]
+	if !ok {
+		msg := fmt.Sprintf("ssm service not found for account: %s", account)
+		handleError(w, apierror.New(apierror.ErrNotFound, msg, nil))
+		return
+	}
+
+	prefix := vars["prefix"]
+	if prefix == "" {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "prefix is required", nil))
+		return
+	}
+
+	param := vars["param"]
+	if param == "" {
+		handleError(w, apierror.New(apierror.ErrNotFound, "param is required", nil))
+		return
+	}
+
+	withDecryption := false
+	if q := r.URL.Query().Get("withDecryption"); q != "" {
+		b, err := strconv.ParseBool(q)
+		if err != nil {
+			handleError(w, apierror.New(apierror.ErrBadRequest, "withDecryption must be a boolean", err))
+			return
+		}
+		withDecryption = b
+	}
+
+	path := fmt.Sprintf("/%s/%s", s.org, prefix)
+	history, err := ssmService.GetParameterHistory(r.Context(), path, param, withDecryption)
+	if err != nil {
+		msg := fmt.Sprintf("unable to get parameter history from the ssm service path %s/%s", path, param)
+		handleError(w, errors.Wrap(err, msg))
+		return
+	}
+
+	if withDecryption {
+		log.Warnf("returning decrypted history of param %s/%s in account %s for %s", path, param, account, r.RemoteAddr)
+	}
+
+	type paramVersion struct {
+		Version          *int64
+		Value            *string
+		Labels           []*string
+		Description      *string
+		KeyId            *string
+		Type             *string
+		LastModifiedDate string
+		LastModifiedUser *string
+	}
+
+	out := make([]paramVersion, 0, len(history))
+	for _, h := range history {
+		out = append(out, paramVersion{
+			Version:          h.Version,
+			Value:            h.Value,
+			Labels:           h.Labels,
+			Description:      h.Description,
+			KeyId:            h.KeyId,
+			Type:             h.Type,
+			LastModifiedDate: aws.TimeValue(h.LastModifiedDate).String(),
+			LastModifiedUser: h.LastModifiedUser,
+		})
 	}
 
 	j, err := json.Marshal(out)
@@ -265,31 +452,43 @@ func (s *server) ParamDeleteAllHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// keep deleting when a parameter fails so the path isn't left half-cleaned, and report the failures
+	deleted := 0
+	failed := map[string]string{}
 	for _, param := range params {
 		p := fmt.Sprintf("/%s/%s/%s", s.org, prefix, param)
-		err := ssmService.DeleteParameter(r.Context(), p)
-		if err != nil {
-			msg := fmt.Sprintf("unable to delete all parameters from the ssm service path %s, failed to delete %s", path, p)
-			handleError(w, errors.Wrap(err, msg))
-			return
+		if err := ssmService.DeleteParameter(r.Context(), p); err != nil {
+			log.Errorf("failed to delete parameter %s from the ssm service path %s: %s", p, path, err)
+			failed[param] = err.Error()
+			continue
 		}
+		deleted++
 	}
 
-	j, err := json.Marshal(
-		struct {
-			Message string
-			Deleted int
-		}{
-			Message: "OK",
-			Deleted: len(params),
-		})
+	output := struct {
+		Message string
+		Deleted int
+		Failed  map[string]string `json:",omitempty"`
+	}{
+		Message: "OK",
+		Deleted: deleted,
+	}
+
+	status := http.StatusOK
+	if len(failed) > 0 {
+		output.Message = fmt.Sprintf("failed to delete %d of %d parameters", len(failed), len(params))
+		output.Failed = failed
+		status = http.StatusMultiStatus
+	}
+
+	j, err := json.Marshal(output)
 	if err != nil {
 		handleError(w, errors.Wrap(err, "unable to marshal response from the ssm service"))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 	w.Write(j)
 }
 
@@ -334,6 +533,18 @@ func (s *server) ParamUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check for the allowPlaintext query param to confirm changing a SecureString to a plaintext type
+	allowPlaintext := false
+	if b, err := strconv.ParseBool(r.URL.Query().Get("allowPlaintext")); err == nil {
+		allowPlaintext = b
+	}
+
+	paramType, err := parameterUpdateType(aws.StringValue(parameter.Type), input, allowPlaintext)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
 	// if new tags are passed, update the tags
 	if input.Tags != nil {
 		newTags := []*ssm.Tag{}
@@ -344,6 +555,11 @@ func (s *server) ParamUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		input.Tags = nil
 
+		if err := orchestration.ValidateTags(paramTags(newTags)); err != nil {
+			handleError(w, err)
+			return
+		}
+
 		err := ssmService.UpdateParameterTags(r.Context(), aws.StringValue(parameter.Name), newTags)
 		if err != nil {
 			handleError(w, errors.Wrap(err, "failed to add tag to resource"))
@@ -351,22 +567,22 @@ func (s *server) ParamUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// the version to label is the current version, unless a new version is put
+	version := aws.Int64Value(parameter.Version)
+
 	// if a new value is passed, update the parameter
 	if aws.StringValue(input.Value) != "" {
 		input.Overwrite = aws.Bool(true)
 		input.Name = aws.String(path + "/" + paramName)
+		input.Type = aws.String(paramType)
 
-		// default to SecureString if none is provided
-		if aws.StringValue(input.Type) == "" {
-			input.Type = aws.String("SecureString")
-		}
-
-		// default to default KMS key if none is provided and type is SecureString
+		// default to default KMS key if none is provided and type is SecureString, this also encrypts a
+		// parameter changing from a plaintext type to SecureString
 		if aws.StringValue(input.Type) == "SecureString" && aws.StringValue(input.KeyId) == "" {
 			input.KeyId = aws.String(ssmService.DefaultKmsKeyId)
 		}
 
-		err = ssmService.UpdateParameter(r.Context(), input)
+		version, err = ssmService.UpdateParameter(r.Context(), input)
 		if err != nil {
 			msg := fmt.Sprintf("unable to create params from the ssm service prefix %s", prefix)
 			handleError(w, errors.Wrap(err, msg))
@@ -374,7 +590,101 @@ func (s *server) ParamUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// if labels are passed, label the version and report the labels that couldn't be attached
+	if labels := r.URL.Query()["label"]; len(labels) > 0 {
+		invalid, err := ssmService.LabelParameterVersion(r.Context(), path+"/"+paramName, version, labels)
+		if err != nil {
+			msg := fmt.Sprintf("unable to label parameter %s/%s version %d", path, paramName, version)
+			handleError(w, errors.Wrap(err, msg))
+			return
+		}
+
+		rejected := make(map[string]struct{}, len(invalid))
+		for _, l := range invalid {
+			rejected[l] = struct{}{}
+		}
+
+		attached := []string{}
+		for _, l := range labels {
+			if _, ok := rejected[l]; !ok {
+				attached = append(attached, l)
+			}
+		}
+
+		j, err := json.Marshal(struct {
+			Version       int64
+			Labels        []string
+			InvalidLabels []string
+		}{version, attached, invalid})
+		if err != nil {
+			handleError(w, errors.Wrap(err, "unable to marshal response from the ssm service"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(j)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
+
+// parameterUpdateType returns the type of an updated parameter.  A parameter keeps its current type unless another type
+// is passed with a new value.  Changing a SecureString to a plaintext type stores the value unencrypted, so it has to be
+// confirmed with allowPlaintext.
+func parameterUpdateType(current string, input *ssm.PutParameterInput, allowPlaintext bool) (string, error) {
+	if current == "" {
+		current = ssm.ParameterTypeSecureString
+	}
+
+	paramType := aws.StringValue(input.Type)
+	if paramType == "" {
+		paramType = current
+	}
+
+	if paramType != current {
+		valid := false
+		for _, t := range ssm.ParameterType_Values() {
+			if paramType == t {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			msg := fmt.Sprintf("invalid parameter type %s, must be one of %s", paramType, strings.Join(ssm.ParameterType_Values(), ", "))
+			return "", apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if aws.StringValue(input.Value) == "" {
+			msg := fmt.Sprintf("a value is required to change the parameter type from %s to %s", current, paramType)
+			return "", apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if current == ssm.ParameterTypeSecureString && !allowPlaintext {
+			msg := fmt.Sprintf("changing the parameter type from %s to %s stores the value unencrypted, pass allowPlaintext=true to confirm", current, paramType)
+			return "", apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		log.Infof("changing parameter type from %s to %s", current, paramType)
+	}
+
+	if paramType != ssm.ParameterTypeSecureString && aws.StringValue(input.KeyId) != "" {
+		msg := fmt.Sprintf("a kms key can only be used with a %s parameter", ssm.ParameterTypeSecureString)
+		return "", apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	return paramType, nil
+}
+
+// paramTags converts ssm parameter tags to orchestration tags
+func paramTags(tags []*ssm.Tag) []*orchestration.Tag {
+	ot := make([]*orchestration.Tag, len(tags))
+	for i, t := range tags {
+		ot[i] = &orchestration.Tag{Key: t.Key, Value: t.Value}
+	}
+	return ot
+}
@@ -0,0 +1,749 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YaleSpinup/ecs-api/ssm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsssm "github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/gorilla/mux"
+)
+
+type mockSSMClient struct {
+	ssmiface.SSMAPI
+	t      *testing.T
+	err    error
+	params map[string]string
+	// requested records the names requested in each GetParameters batch
+	requested [][]string
+	// history maps parameter names to their versions
+	history map[string][]*awsssm.ParameterHistory
+	// decrypted records if the parameter history was requested with decryption
+	decrypted bool
+	// put records the parameters that were put
+	put []*awsssm.PutParameterInput
+	// deleteErrs maps parameter names to the error returned when deleting them
+	deleteErrs map[string]error
+	// deleted records the parameters that were deleted
+	deleted []string
+	// types maps parameter names to their type
+	types map[string]string
+}
+
+func (m *mockSSMClient) DescribeParametersWithContext(ctx aws.Context, input *awsssm.DescribeParametersInput, opts ...request.Option) (*awsssm.DescribeParametersOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	out := &awsssm.DescribeParametersOutput{}
+	for _, f := range input.ParameterFilters {
+		for _, v := range f.Values {
+			if t, ok := m.types[aws.StringValue(v)]; ok {
+				out.Parameters = append(out.Parameters, &awsssm.ParameterMetadata{
+					Name:    v,
+					Type:    aws.String(t),
+					Version: aws.Int64(1),
+				})
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func (m *mockSSMClient) DeleteParameterWithContext(ctx aws.Context, input *awsssm.DeleteParameterInput, opts ...request.Option) (*awsssm.DeleteParameterOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if err, ok := m.deleteErrs[aws.StringValue(input.Name)]; ok {
+		return nil, err
+	}
+
+	m.deleted = append(m.deleted, aws.StringValue(input.Name))
+
+	return &awsssm.DeleteParameterOutput{}, nil
+}
+
+func (m *mockSSMClient) PutParameterWithContext(ctx aws.Context, input *awsssm.PutParameterInput, opts ...request.Option) (*awsssm.PutParameterOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if _, ok := m.params[aws.StringValue(input.Name)]; ok && !aws.BoolValue(input.Overwrite) {
+		return nil, awserr.New(awsssm.ErrCodeParameterAlreadyExists, "parameter already exists", nil)
+	}
+
+	m.put = append(m.put, input)
+
+	return &awsssm.PutParameterOutput{Version: aws.Int64(1)}, nil
+}
+
+func (m *mockSSMClient) GetParameterHistoryWithContext(ctx aws.Context, input *awsssm.GetParameterHistoryInput, opts ...request.Option) (*awsssm.GetParameterHistoryOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	m.decrypted = aws.BoolValue(input.WithDecryption)
+
+	history, ok := m.history[aws.StringValue(input.Name)]
+	if !ok {
+		return nil, awserr.New(awsssm.ErrCodeParameterNotFound, "not found", nil)
+	}
+
+	return &awsssm.GetParameterHistoryOutput{Parameters: history}, nil
+}
+
+func (m *mockSSMClient) GetParametersByPathWithContext(ctx aws.Context, input *awsssm.GetParametersByPathInput, opts ...request.Option) (*awsssm.GetParametersByPathOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	names := []string{}
+	for n := range m.params {
+		if strings.HasPrefix(n, aws.StringValue(input.Path)+"/") {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	start := 0
+	if input.NextToken != nil {
+		fmt.Sscanf(aws.StringValue(input.NextToken), "%d", &start)
+	}
+
+	end := start + int(aws.Int64Value(input.MaxResults))
+	out := &awsssm.GetParametersByPathOutput{}
+	if end < len(names) {
+		out.NextToken = aws.String(fmt.Sprintf("%d", end))
+	} else {
+		end = len(names)
+	}
+
+	for _, n := range names[start:end] {
+		out.Parameters = append(out.Parameters, &awsssm.Parameter{Name: aws.String(n)})
+	}
+
+	return out, nil
+}
+
+func (m *mockSSMClient) GetParametersWithContext(ctx aws.Context, input *awsssm.GetParametersInput, opts ...request.Option) (*awsssm.GetParametersOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if len(input.Names) > 10 {
+		return nil, awserr.New("ValidationException", "too many names", nil)
+	}
+
+	m.requested = append(m.requested, aws.StringValueSlice(input.Names))
+
+	out := &awsssm.GetParametersOutput{}
+	for _, n := range input.Names {
+		if v, ok := m.params[aws.StringValue(n)]; ok {
+			out.Parameters = append(out.Parameters, &awsssm.Parameter{Name: n, Value: aws.String(v)})
+			continue
+		}
+		out.InvalidParameters = append(out.InvalidParameters, n)
+	}
+
+	return out, nil
+}
+
+func TestParamExportHandler(t *testing.T) {
+	params := map[string]string{
+		"/otherorg/app/secret": "not-yours",
+		"/myorg/other/secret":  "wrong-prefix",
+	}
+
+	want := []map[string]string{}
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("param%02d", i)
+		params["/myorg/app/"+name] = "value" + name
+		want = append(want, map[string]string{"Name": name, "Value": "value" + name})
+	}
+
+	tests := []struct {
+		name          string
+		account       string
+		query         string
+		err           error
+		wantStatus    int
+		wantBatches   []int
+		wantResponse  []map[string]string
+		wantRequested bool
+	}{
+		{
+			name:          "multi-batch export",
+			account:       "acct1",
+			query:         "withValues=true",
+			wantStatus:    http.StatusOK,
+			wantBatches:   []int{10, 2},
+			wantResponse:  want,
+			wantRequested: true,
+		},
+		{
+			name:          "withValues 1",
+			account:       "acct1",
+			query:         "withValues=1",
+			wantStatus:    http.StatusOK,
+			wantBatches:   []int{10, 2},
+			wantResponse:  want,
+			wantRequested: true,
+		},
+		{
+			name:       "invalid withValues",
+			account:    "acct1",
+			query:      "withValues=yes",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing withValues",
+			account:    "acct1",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "withValues false",
+			account:    "acct1",
+			query:      "withValues=false",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing account",
+			account:    "acct2",
+			query:      "withValues=true",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "ssm error",
+			account:    "acct1",
+			query:      "withValues=true",
+			err:        awserr.New(awsssm.ErrCodeInternalServerError, "boom", nil),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockSSMClient{t: t, err: tt.err, params: params}
+			s := server{
+				org: "myorg",
+				ssmServices: map[string]ssm.SSM{
+					"acct1": {Service: m},
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/ecs/"+tt.account+"/params/app?"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"account": tt.account, "prefix": "app"})
+			rr := httptest.NewRecorder()
+
+			s.ParamExportHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if !tt.wantRequested && len(m.requested) > 0 {
+				t.Errorf("expected no parameter values to be requested, got %v", m.requested)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			batches := []int{}
+			for _, b := range m.requested {
+				batches = append(batches, len(b))
+				for _, n := range b {
+					if !strings.HasPrefix(n, "/myorg/app/") {
+						t.Errorf("expected only parameters under /myorg/app to be requested, got %s", n)
+					}
+				}
+			}
+
+			if !reflect.DeepEqual(batches, tt.wantBatches) {
+				t.Errorf("expected batches of %v, got %v", tt.wantBatches, batches)
+			}
+
+			got := []map[string]string{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to unmarshal response: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.wantResponse) {
+				t.Errorf("expected response %+v, got %+v", tt.wantResponse, got)
+			}
+		})
+	}
+}
+
+func TestParamHistoryHandler(t *testing.T) {
+	modified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := map[string][]*awsssm.ParameterHistory{
+		"/myorg/app/secret": {
+			{
+				LastModifiedDate: aws.Time(modified),
+				LastModifiedUser: aws.String("someone"),
+				Name:             aws.String("/myorg/app/secret"),
+				Type:             aws.String("SecureString"),
+				Value:            aws.String("value1"),
+				Version:          aws.Int64(1),
+			},
+			{
+				Labels:           aws.StringSlice([]string{"current"}),
+				LastModifiedDate: aws.Time(modified.Add(time.Hour)),
+				LastModifiedUser: aws.String("someone"),
+				Name:             aws.String("/myorg/app/secret"),
+				Type:             aws.String("SecureString"),
+				Value:            aws.String("value2"),
+				Version:          aws.Int64(2),
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		account       string
+		param         string
+		query         string
+		err           error
+		wantStatus    int
+		wantDecrypted bool
+		wantVersions  []int64
+		wantLabels    [][]string
+	}{
+		{
+			name:         "multiple versions",
+			account:      "acct1",
+			param:        "secret",
+			wantStatus:   http.StatusOK,
+			wantVersions: []int64{1, 2},
+			wantLabels:   [][]string{nil, {"current"}},
+		},
+		{
+			name:          "multiple versions with decryption",
+			account:       "acct1",
+			param:         "secret",
+			query:         "withDecryption=true",
+			wantStatus:    http.StatusOK,
+			wantDecrypted: true,
+			wantVersions:  []int64{1, 2},
+			wantLabels:    [][]string{nil, {"current"}},
+		},
+		{
+			name:          "decryption with a boolean value",
+			account:       "acct1",
+			param:         "secret",
+			query:         "withDecryption=1",
+			wantStatus:    http.StatusOK,
+			wantDecrypted: true,
+			wantVersions:  []int64{1, 2},
+			wantLabels:    [][]string{nil, {"current"}},
+		},
+		{
+			name:       "invalid decryption value",
+			account:    "acct1",
+			param:      "secret",
+			query:      "withDecryption=yes",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing param",
+			account:    "acct1",
+			param:      "missing",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "missing account",
+			account:    "acct2",
+			param:      "secret",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "ssm error",
+			account:    "acct1",
+			param:      "secret",
+			err:        awserr.New(awsssm.ErrCodeInternalServerError, "boom", nil),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockSSMClient{t: t, err: tt.err, history: history}
+			s := server{
+				org: "myorg",
+				ssmServices: map[string]ssm.SSM{
+					"acct1": {Service: m},
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/ecs/"+tt.account+"/params/app/"+tt.param+"/history?"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"account": tt.account, "prefix": "app", "param": tt.param})
+			rr := httptest.NewRecorder()
+
+			s.ParamHistoryHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if m.decrypted != tt.wantDecrypted {
+				t.Errorf("expected decryption %t, got %t", tt.wantDecrypted, m.decrypted)
+			}
+
+			got := []struct {
+				Version          int64
+				Value            string
+				Labels           []string
+				LastModifiedDate string
+				LastModifiedUser string
+			}{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to unmarshal response: %s", err)
+			}
+
+			if len(got) != len(tt.wantVersions) {
+				t.Fatalf("expected %d versions, got %d", len(tt.wantVersions), len(got))
+			}
+
+			for i, v := range got {
+				if v.Version != tt.wantVersions[i] {
+					t.Errorf("expected version %d, got %d", tt.wantVersions[i], v.Version)
+				}
+
+				if !reflect.DeepEqual(v.Labels, tt.wantLabels[i]) {
+					t.Errorf("expected labels %v, got %v", tt.wantLabels[i], v.Labels)
+				}
+
+				if v.Value != fmt.Sprintf("value%d", v.Version) {
+					t.Errorf("expected value for version %d, got %s", v.Version, v.Value)
+				}
+
+				if v.LastModifiedDate == "" || v.LastModifiedUser != "someone" {
+					t.Errorf("expected modification details, got %s by %s", v.LastModifiedDate, v.LastModifiedUser)
+				}
+			}
+		})
+	}
+}
+
+func TestParamCreateHandlerTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		defaultTags map[string]string
+		wantStatus  int
+		wantTags    map[string]string
+	}{
+		{
+			name:       "valid tags",
+			body:       `{"Name": "secret", "Value": "abc123", "Tags": [{"Key": "Application", "Value": "app"}]}`,
+			wantStatus: http.StatusOK,
+			wantTags:   map[string]string{"spinup:org": "myorg", "Application": "app"},
+		},
+		{
+			name:        "default tags",
+			body:        `{"Name": "secret", "Value": "abc123", "Tags": [{"Key": "Application", "Value": "app"}, {"Key": "CostCenter", "Value": "5678"}]}`,
+			defaultTags: map[string]string{"CostCenter": "1234", "ManagedBy": "spinup"},
+			wantStatus:  http.StatusOK,
+			wantTags:    map[string]string{"spinup:org": "myorg", "Application": "app", "CostCenter": "5678", "ManagedBy": "spinup"},
+		},
+		{
+			name:       "reserved prefix",
+			body:       `{"Name": "secret", "Value": "abc123", "Tags": [{"Key": "aws:foo", "Value": "app"}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "over-length key",
+			body:       `{"Name": "secret", "Value": "abc123", "Tags": [{"Key": "` + strings.Repeat("k", 129) + `", "Value": "app"}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockSSMClient{t: t}
+			s := server{
+				org:         "myorg",
+				defaultTags: tt.defaultTags,
+				ssmServices: map[string]ssm.SSM{
+					"acct1": {Service: m, DefaultKmsKeyId: "key1"},
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/ecs/acct1/params/app", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"account": "acct1", "prefix": "app"})
+			rr := httptest.NewRecorder()
+
+			s.ParamCreateHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				if len(m.put) != 0 {
+					t.Errorf("expected no parameters to be created, got %d", len(m.put))
+				}
+				return
+			}
+
+			if len(m.put) != 1 {
+				t.Fatalf("expected 1 parameter to be created, got %d", len(m.put))
+			}
+
+			if name := aws.StringValue(m.put[0].Name); name != "/myorg/app/secret" {
+				t.Errorf("expected parameter /myorg/app/secret, got %s", name)
+			}
+
+			tags := map[string]string{}
+			for _, tag := range m.put[0].Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+
+			if !reflect.DeepEqual(tags, tt.wantTags) {
+				t.Errorf("expected parameter tags %v, got %v", tt.wantTags, tags)
+			}
+		})
+	}
+}
+
+func TestParamCreateHandlerOverwrite(t *testing.T) {
+	m := &mockSSMClient{t: t, params: map[string]string{"/myorg/app/secret": "abc123"}}
+	s := server{
+		org: "myorg",
+		ssmServices: map[string]ssm.SSM{
+			"acct1": {Service: m, DefaultKmsKeyId: "key1"},
+		},
+	}
+
+	body := `{"Name": "secret", "Value": "xyz789", "Overwrite": true}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/ecs/acct1/params/app", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"account": "acct1", "prefix": "app"})
+	rr := httptest.NewRecorder()
+
+	s.ParamCreateHandler(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+
+	if len(m.put) != 0 {
+		t.Errorf("expected the existing parameter not to be overwritten, got %d puts", len(m.put))
+	}
+}
+
+func TestParamDeleteAllHandler(t *testing.T) {
+	params := map[string]string{
+		"/myorg/app/alpha": "a",
+		"/myorg/app/bravo": "b",
+		"/myorg/app/delta": "d",
+	}
+
+	tests := []struct {
+		name        string
+		deleteErrs  map[string]error
+		wantStatus  int
+		wantDeleted []string
+		wantFailed  []string
+	}{
+		{
+			name:        "all deleted",
+			wantStatus:  http.StatusOK,
+			wantDeleted: []string{"/myorg/app/alpha", "/myorg/app/bravo", "/myorg/app/delta"},
+		},
+		{
+			name: "one delete fails mid-way",
+			deleteErrs: map[string]error{
+				"/myorg/app/bravo": awserr.New(awsssm.ErrCodeInternalServerError, "Internal Error", nil),
+			},
+			wantStatus:  http.StatusMultiStatus,
+			wantDeleted: []string{"/myorg/app/alpha", "/myorg/app/delta"},
+			wantFailed:  []string{"bravo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockSSMClient{t: t, params: params, deleteErrs: tt.deleteErrs}
+			s := server{
+				org: "myorg",
+				ssmServices: map[string]ssm.SSM{
+					"acct1": {Service: m},
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/v1/ecs/acct1/params/app", nil)
+			req = mux.SetURLVars(req, map[string]string{"account": "acct1", "prefix": "app"})
+			rr := httptest.NewRecorder()
+
+			s.ParamDeleteAllHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if !reflect.DeepEqual(m.deleted, tt.wantDeleted) {
+				t.Errorf("expected deleted parameters %v, got %v", tt.wantDeleted, m.deleted)
+			}
+
+			var out struct {
+				Deleted int
+				Failed  map[string]string
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+				t.Fatalf("failed to unmarshal response: %s", err)
+			}
+
+			if out.Deleted != len(tt.wantDeleted) {
+				t.Errorf("expected %d deleted, got %d", len(tt.wantDeleted), out.Deleted)
+			}
+
+			failed := []string{}
+			for name, reason := range out.Failed {
+				if reason == "" {
+					t.Errorf("expected a failure reason for %s", name)
+				}
+				failed = append(failed, name)
+			}
+			sort.Strings(failed)
+
+			if len(failed) != len(tt.wantFailed) || (len(failed) > 0 && !reflect.DeepEqual(failed, tt.wantFailed)) {
+				t.Errorf("expected failed parameters %v, got %v", tt.wantFailed, failed)
+			}
+		})
+	}
+}
+
+func TestParamUpdateHandlerType(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     string
+		query       string
+		body        string
+		wantStatus  int
+		wantType    string
+		wantKeyId   string
+		wantNoWrite bool
+	}{
+		{
+			name:       "keeps the string type",
+			current:    "String",
+			body:       `{"Value": "abc123"}`,
+			wantStatus: http.StatusOK,
+			wantType:   "String",
+		},
+		{
+			name:       "keeps the secure string type",
+			current:    "SecureString",
+			body:       `{"Value": "abc123"}`,
+			wantStatus: http.StatusOK,
+			wantType:   "SecureString",
+			wantKeyId:  "key1",
+		},
+		{
+			name:       "string to secure string",
+			current:    "String",
+			body:       `{"Value": "abc123", "Type": "SecureString"}`,
+			wantStatus: http.StatusOK,
+			wantType:   "SecureString",
+			wantKeyId:  "key1",
+		},
+		{
+			name:       "string to secure string with a key",
+			current:    "String",
+			body:       `{"Value": "abc123", "Type": "SecureString", "KeyId": "alias/mykey"}`,
+			wantStatus: http.StatusOK,
+			wantType:   "SecureString",
+			wantKeyId:  "alias/mykey",
+		},
+		{
+			name:        "secure string to string without confirmation",
+			current:     "SecureString",
+			body:        `{"Value": "abc123", "Type": "String"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantNoWrite: true,
+		},
+		{
+			name:       "secure string to string",
+			current:    "SecureString",
+			query:      "?allowPlaintext=true",
+			body:       `{"Value": "abc123", "Type": "String"}`,
+			wantStatus: http.StatusOK,
+			wantType:   "String",
+		},
+		{
+			name:        "type change without a value",
+			current:     "String",
+			body:        `{"Type": "SecureString", "Tags": [{"Key": "Application", "Value": "app"}]}`,
+			wantStatus:  http.StatusBadRequest,
+			wantNoWrite: true,
+		},
+		{
+			name:        "invalid type",
+			current:     "String",
+			body:        `{"Value": "abc123", "Type": "Plaintext"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantNoWrite: true,
+		},
+		{
+			name:        "key with a string",
+			current:     "String",
+			body:        `{"Value": "abc123", "KeyId": "alias/mykey"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantNoWrite: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockSSMClient{t: t, types: map[string]string{"/myorg/app/secret": tt.current}}
+			s := server{
+				org: "myorg",
+				ssmServices: map[string]ssm.SSM{
+					"acct1": {Service: m, DefaultKmsKeyId: "key1"},
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPut, "/v1/ecs/acct1/params/app/secret"+tt.query, strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"account": "acct1", "prefix": "app", "param": "secret"})
+			rr := httptest.NewRecorder()
+
+			s.ParamUpdateHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantNoWrite {
+				if len(m.put) != 0 {
+					t.Errorf("expected the parameter not to be updated, got %d puts", len(m.put))
+				}
+				return
+			}
+
+			if len(m.put) != 1 {
+				t.Fatalf("expected 1 parameter update, got %d", len(m.put))
+			}
+
+			if typ := aws.StringValue(m.put[0].Type); typ != tt.wantType {
+				t.Errorf("expected parameter type %s, got %s", tt.wantType, typ)
+			}
+
+			if keyId := aws.StringValue(m.put[0].KeyId); keyId != tt.wantKeyId {
+				t.Errorf("expected parameter kms key %q, got %q", tt.wantKeyId, keyId)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/gorilla/mux"
+)
+
+// ecsQuotaServiceCode is the service quotas service code for ECS
+const ecsQuotaServiceCode = "ecs"
+
+// Quota is the current value of a service quota.  Applied is true when the value has been applied
+// to the account (ie. after a quota increase), otherwise the value is the AWS default.
+type Quota struct {
+	Code       string
+	Name       string
+	Value      float64
+	Unit       string
+	Adjustable bool
+	Applied    bool
+}
+
+// QuotasHandler lists the current ECS service quotas for the account, ie. the number of services per cluster
+func (s *server) QuotasHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	sqService, ok := s.sqServices[account]
+	if !ok {
+		msg := fmt.Sprintf("service quotas service not found for account: %s", account)
+		handleError(w, apierror.New(apierror.ErrNotFound, msg, nil))
+		return
+	}
+
+	defaults, err := sqService.ListDefaultServiceQuotas(r.Context(), ecsQuotaServiceCode)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	applied, err := sqService.ListServiceQuotas(r.Context(), ecsQuotaServiceCode)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(mergeQuotas(defaults, applied))
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// mergeQuotas overrides the default quota values with the values applied to the account and returns the quotas sorted by name
+func mergeQuotas(defaults, applied []*servicequotas.ServiceQuota) []*Quota {
+	quotas := map[string]*Quota{}
+	add := func(list []*servicequotas.ServiceQuota, isApplied bool) {
+		for _, q := range list {
+			if q == nil {
+				continue
+			}
+
+			code := aws.StringValue(q.QuotaCode)
+			quotas[code] = &Quota{
+				Code:       code,
+				Name:       aws.StringValue(q.QuotaName),
+				Value:      aws.Float64Value(q.Value),
+				Unit:       aws.StringValue(q.Unit),
+				Adjustable: aws.BoolValue(q.Adjustable),
+				Applied:    isApplied,
+			}
+		}
+	}
+	add(defaults, false)
+	add(applied, true)
+
+	output := make([]*Quota, 0, len(quotas))
+	for _, q := range quotas {
+		output = append(output, q)
+	}
+
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].Name < output[j].Name
+	})
+
+	return output
+}
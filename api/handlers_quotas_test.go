@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/YaleSpinup/ecs-api/servicequotas"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awssq "github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	"github.com/gorilla/mux"
+)
+
+type mockSQClient struct {
+	servicequotasiface.ServiceQuotasAPI
+	t   *testing.T
+	err error
+	// applied are the quotas applied to the account
+	applied []*awssq.ServiceQuota
+}
+
+// testDefaultQuotas are the default ecs quotas returned by the mock
+var testDefaultQuotas = []*awssq.ServiceQuota{
+	{
+		QuotaCode:  aws.String("L-9EF96962"),
+		QuotaName:  aws.String("Services per cluster"),
+		Value:      aws.Float64(5000),
+		Unit:       aws.String("None"),
+		Adjustable: aws.Bool(true),
+	},
+	{
+		QuotaCode:  aws.String("L-21C621EB"),
+		QuotaName:  aws.String("Clusters per account"),
+		Value:      aws.Float64(10000),
+		Unit:       aws.String("None"),
+		Adjustable: aws.Bool(true),
+	},
+}
+
+func (m *mockSQClient) ListAWSDefaultServiceQuotasWithContext(ctx aws.Context, input *awssq.ListAWSDefaultServiceQuotasInput, opts ...request.Option) (*awssq.ListAWSDefaultServiceQuotasOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if aws.StringValue(input.ServiceCode) != "ecs" {
+		m.t.Errorf("expected ecs service code, got %s", aws.StringValue(input.ServiceCode))
+	}
+
+	return &awssq.ListAWSDefaultServiceQuotasOutput{Quotas: testDefaultQuotas}, nil
+}
+
+func (m *mockSQClient) ListServiceQuotasWithContext(ctx aws.Context, input *awssq.ListServiceQuotasInput, opts ...request.Option) (*awssq.ListServiceQuotasOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if aws.StringValue(input.ServiceCode) != "ecs" {
+		m.t.Errorf("expected ecs service code, got %s", aws.StringValue(input.ServiceCode))
+	}
+
+	return &awssq.ListServiceQuotasOutput{Quotas: m.applied}, nil
+}
+
+func TestQuotasHandler(t *testing.T) {
+	clustersPerAccount := &Quota{Code: "L-21C621EB", Name: "Clusters per account", Value: 10000, Unit: "None", Adjustable: true}
+
+	tests := []struct {
+		name       string
+		account    string
+		applied    []*awssq.ServiceQuota
+		err        error
+		wantStatus int
+		want       []*Quota
+	}{
+		{
+			name:       "default quotas",
+			account:    "acct1",
+			wantStatus: http.StatusOK,
+			want: []*Quota{
+				clustersPerAccount,
+				{Code: "L-9EF96962", Name: "Services per cluster", Value: 5000, Unit: "None", Adjustable: true},
+			},
+		},
+		{
+			name:    "applied services per cluster quota",
+			account: "acct1",
+			applied: []*awssq.ServiceQuota{
+				{
+					QuotaCode:  aws.String("L-9EF96962"),
+					QuotaName:  aws.String("Services per cluster"),
+					Value:      aws.Float64(6000),
+					Unit:       aws.String("None"),
+					Adjustable: aws.Bool(true),
+				},
+			},
+			wantStatus: http.StatusOK,
+			want: []*Quota{
+				clustersPerAccount,
+				{Code: "L-9EF96962", Name: "Services per cluster", Value: 6000, Unit: "None", Adjustable: true, Applied: true},
+			},
+		},
+		{
+			name:       "missing account",
+			account:    "acct2",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "access denied",
+			account:    "acct1",
+			err:        awserr.New(awssq.ErrCodeAccessDeniedException, "denied", nil),
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := server{
+				sqServices: map[string]servicequotas.ServiceQuotas{
+					"acct1": {Service: &mockSQClient{t: t, err: tt.err, applied: tt.applied}},
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/ecs/"+tt.account+"/quotas", nil)
+			req = mux.SetURLVars(req, map[string]string{"account": tt.account})
+			rr := httptest.NewRecorder()
+
+			s.QuotasHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got []*Quota
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to unmarshal response: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected quotas %s, got %s", awsutil.Prettify(tt.want), awsutil.Prettify(got))
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/YaleSpinup/apierror"
+	"github.com/YaleSpinup/ecs-api/orchestration"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
@@ -27,7 +28,7 @@ func (s *server) SecretListHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tagsFilter := map[string]string{"spinup:org": s.org}
+	tagsFilter := map[string]string{}
 	q := r.URL.Query()
 	if len(q) > 0 {
 		log.Debugf("parsing query parameters %+v", q)
@@ -40,30 +41,17 @@ func (s *server) SecretListHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	secrets, err := smService.ListSecretsWithFilter(r.Context(), func(sec *secretsmanager.SecretListEntry) bool {
-		for k, v := range tagsFilter {
-			log.Debugf("checking %s tags for %s = %s", aws.StringValue(sec.Name), k, v)
+	// the tag filters are combined with a single org, so the secrets are listed for each org
+	secrets := []*string{}
+	for _, org := range orchestration.Orgs(s.org, s.orgs) {
+		tagsFilter["spinup:org"] = org
 
-			found := false
-			for _, tag := range sec.Tags {
-				if aws.StringValue(tag.Key) == k && aws.StringValue(tag.Value) == v {
-					found = true
-					break
-				}
-			}
-
-			if !found {
-				log.Debugf("didn't find tag (%s = %s) for %s", k, v, aws.StringValue(sec.Name))
-				return false
-			}
+		out, err := smService.ListSecretsWithTags(r.Context(), tagsFilter)
+		if err != nil {
+			handleError(w, errors.Wrap(err, "unable to list secrets from the secretsmanager service"))
+			return
 		}
-
-		log.Debugf("%s matched all tags", aws.StringValue(sec.Name))
-		return true
-	})
-	if err != nil {
-		handleError(w, errors.Wrap(err, "unable to list secrets from the secretsmanager service"))
-		return
+		secrets = append(secrets, out...)
 	}
 
 	j, err := json.Marshal(secrets)
@@ -96,6 +84,16 @@ func (s *server) SecretCreateHandler(w http.ResponseWriter, r *http.Request) {
 		handleError(w, apierror.New(apierror.ErrBadRequest, msg, err))
 		return
 	}
+
+	// append the default tags that weren't passed
+	for _, t := range orchestration.MergeDefaultTags(s.defaultTags, secretTags(input.Tags))[len(input.Tags):] {
+		input.Tags = append(input.Tags, &secretsmanager.Tag{Key: t.Key, Value: t.Value})
+	}
+
+	if err := orchestration.ValidateTags(secretTags(input.Tags)); err != nil {
+		handleError(w, err)
+		return
+	}
 	input.Tags = append(input.Tags, &secretsmanager.Tag{Key: aws.String("spinup:org"), Value: aws.String(s.org)})
 
 	out, err := smService.CreateSecret(r.Context(), input)
@@ -127,10 +125,10 @@ func (s *server) SecretShowHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	id := vars["secret"]
 	secret, err := smService.GetSecretMetaDataWithFilter(r.Context(), id, func(out *secretsmanager.DescribeSecretOutput) bool {
-		log.Debugf("checking tags for %s to be sure it's part of the org %s", aws.StringValue(out.Name), s.org)
+		log.Debugf("checking tags for %s to be sure it's part of the orgs %v", aws.StringValue(out.Name), orchestration.Orgs(s.org, s.orgs))
 		for _, tag := range out.Tags {
-			if aws.StringValue(tag.Key) == "spinup:org" && aws.StringValue(tag.Value) == s.org {
-				log.Debugf("%s has matching org tag and is part of the %s org, adding to the list", aws.StringValue(out.Name), s.org)
+			if aws.StringValue(tag.Key) == "spinup:org" && orchestration.AllowedOrg(s.org, s.orgs, aws.StringValue(tag.Value)) {
+				log.Debugf("%s has matching org tag and is part of the %s org, adding to the list", aws.StringValue(out.Name), aws.StringValue(tag.Value))
 				return true
 			}
 		}
@@ -178,10 +176,10 @@ func (s *server) SecretDeleteHandler(w http.ResponseWriter, r *http.Request) {
 
 	// first check the secret matches our filters (ie. it's part of the org)
 	_, err := smService.GetSecretMetaDataWithFilter(r.Context(), id, func(out *secretsmanager.DescribeSecretOutput) bool {
-		log.Debugf("checking tags for %s to be sure it's part of the org %s", aws.StringValue(out.Name), s.org)
+		log.Debugf("checking tags for %s to be sure it's part of the orgs %v", aws.StringValue(out.Name), orchestration.Orgs(s.org, s.orgs))
 		for _, tag := range out.Tags {
-			if aws.StringValue(tag.Key) == "spinup:org" && aws.StringValue(tag.Value) == s.org {
-				log.Debugf("%s has matching org tag and is part of the %s org", aws.StringValue(out.Name), s.org)
+			if aws.StringValue(tag.Key) == "spinup:org" && orchestration.AllowedOrg(s.org, s.orgs, aws.StringValue(tag.Value)) {
+				log.Debugf("%s has matching org tag and is part of the %s org", aws.StringValue(out.Name), aws.StringValue(tag.Value))
 				return true
 			}
 		}
@@ -192,10 +190,17 @@ func (s *server) SecretDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// then delete it
-	out, err := smService.DeleteSecret(r.Context(), id, window)
+	// then delete it, overriding the deletion protection only when asked
+	deleteSecret := smService.DeleteSecret
+	if q.Get("override") == "true" {
+		log.Warnf("overriding deletion protection for secret %s", id)
+		deleteSecret = smService.DeleteProtectedSecret
+	}
+
+	out, err := deleteSecret(r.Context(), id, window)
 	if err != nil {
 		handleError(w, err)
+		return
 	}
 
 	j, err := json.Marshal(out)
@@ -240,6 +245,11 @@ func (s *server) SecretUpdateHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		if err := orchestration.ValidateTags(secretTags(input.Tags)); err != nil {
+			handleError(w, err)
+			return
+		}
+
 		if err := smService.UpdateSecretTags(r.Context(), id, input.Tags); err != nil {
 			handleError(w, apierror.New(apierror.ErrBadRequest, "failed to update tags for secret", err))
 			return
@@ -275,3 +285,55 @@ func (s *server) SecretUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(j)
 }
+
+// OrphanedCredentialsHandler lists (GET) or deletes (DELETE) the secrets under the org prefix that aren't referenced by any
+// task definition in the org.  Deleting requires the confirm query param to be set to true.
+func (s *server) OrphanedCredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+
+	deleteOrphans := r.Method == http.MethodDelete
+	if deleteOrphans {
+		if confirm, _ := strconv.ParseBool(r.URL.Query().Get("confirm")); !confirm {
+			handleError(w, apierror.New(apierror.ErrBadRequest, "confirm=true is required to delete orphaned credentials", nil))
+			return
+		}
+	}
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.OrphanedCredentials(r.Context(), deleteOrphans)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	status := http.StatusOK
+	if len(output.Failed) > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(j)
+}
+
+// secretTags converts secretsmanager tags to orchestration tags
+func secretTags(tags []*secretsmanager.Tag) []*orchestration.Tag {
+	ot := make([]*orchestration.Tag, len(tags))
+	for i, t := range tags {
+		ot[i] = &orchestration.Tag{Key: t.Key, Value: t.Value}
+	}
+	return ot
+}
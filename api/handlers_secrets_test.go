@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/YaleSpinup/ecs-api/secretsmanager"
+	"github.com/gorilla/mux"
+)
+
+func TestSecretListHandler(t *testing.T) {
+	secrets := []string{"myorg-secret", "otherorg-secret", "foreignorg-secret"}
+	orgs := map[string]string{
+		"myorg-secret":      "myorg",
+		"otherorg-secret":   "otherorg",
+		"foreignorg-secret": "foreignorg",
+	}
+
+	tests := []struct {
+		name string
+		orgs []string
+		want []string
+	}{
+		{
+			name: "secrets in the org",
+			want: []string{"arn:aws:secretsmanager:us-east-1:0123456789:secret:myorg-secret-AbCdEf"},
+		},
+		{
+			name: "secrets in the org and allowed orgs",
+			orgs: []string{"otherorg"},
+			want: []string{
+				"arn:aws:secretsmanager:us-east-1:0123456789:secret:myorg-secret-AbCdEf",
+				"arn:aws:secretsmanager:us-east-1:0123456789:secret:otherorg-secret-AbCdEf",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := server{
+				org:  "myorg",
+				orgs: tt.orgs,
+				smServices: map[string]secretsmanager.SecretsManager{
+					"acct1": {Service: &mockSMClient{t: t, secrets: secrets, orgs: orgs}},
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/ecs/acct1/secrets", nil)
+			req = mux.SetURLVars(req, map[string]string{"account": "acct1"})
+			rr := httptest.NewRecorder()
+
+			s.SecretListHandler(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+
+			var got []string
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to unmarshal response: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected secrets %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
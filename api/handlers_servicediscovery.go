@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/gorilla/mux"
+)
+
+// serviceDiscoveryNamespace is a service discovery namespace and the services within it
+type serviceDiscoveryNamespace struct {
+	Id       *string
+	Name     *string
+	Type     *string
+	Services []*serviceDiscoveryService
+}
+
+// serviceDiscoveryService is a service discovery service in a namespace
+type serviceDiscoveryService struct {
+	Id            *string
+	Name          *string
+	DnsConfig     *servicediscovery.DnsConfig
+	InstanceCount *int64
+}
+
+// ServiceDiscoveryListHandler lists the service discovery namespaces and the services within them.  If a namespace
+// id is passed, only that namespace is listed.
+func (s *server) ServiceDiscoveryListHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	namespace := vars["namespace"]
+	sdService, ok := s.sdServices[account]
+	if !ok {
+		msg := fmt.Sprintf("servicediscovery service not found for account: %s", account)
+		handleError(w, apierror.New(apierror.ErrNotFound, msg, nil))
+		return
+	}
+
+	namespaces := []*serviceDiscoveryNamespace{}
+	if namespace != "" {
+		ns, err := sdService.GetNamespace(r.Context(), namespace)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+
+		namespaces = append(namespaces, &serviceDiscoveryNamespace{Id: ns.Id, Name: ns.Name, Type: ns.Type})
+	} else {
+		list, err := sdService.ListNamespaces(r.Context())
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+
+		for _, ns := range list {
+			namespaces = append(namespaces, &serviceDiscoveryNamespace{Id: ns.Id, Name: ns.Name, Type: ns.Type})
+		}
+	}
+
+	for _, ns := range namespaces {
+		services, err := sdService.ListServices(r.Context(), aws.StringValue(ns.Id))
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+
+		ns.Services = make([]*serviceDiscoveryService, 0, len(services))
+		for _, svc := range services {
+			ns.Services = append(ns.Services, &serviceDiscoveryService{
+				Id:            svc.Id,
+				Name:          svc.Name,
+				DnsConfig:     svc.DnsConfig,
+				InstanceCount: svc.InstanceCount,
+			})
+		}
+	}
+
+	var output interface{} = namespaces
+	if namespace != "" {
+		output = namespaces[0]
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/YaleSpinup/ecs-api/servicediscovery"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awssd "github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
+	"github.com/gorilla/mux"
+)
+
+type mockSDClient struct {
+	servicediscoveryiface.ServiceDiscoveryAPI
+	t   *testing.T
+	err error
+}
+
+var testSDNamespaces = []*awssd.NamespaceSummary{
+	{Id: aws.String("ns-1"), Name: aws.String("spinup.internal"), Type: aws.String("DNS_PRIVATE")},
+	{Id: aws.String("ns-2"), Name: aws.String("empty.internal"), Type: aws.String("DNS_PRIVATE")},
+}
+
+var testSDServices = map[string][]*awssd.ServiceSummary{
+	"ns-1": {
+		{
+			Id:   aws.String("srv-1"),
+			Name: aws.String("www"),
+			DnsConfig: &awssd.DnsConfig{
+				NamespaceId:   aws.String("ns-1"),
+				RoutingPolicy: aws.String("MULTIVALUE"),
+				DnsRecords:    []*awssd.DnsRecord{{TTL: aws.Int64(10), Type: aws.String("A")}},
+			},
+			InstanceCount: aws.Int64(2),
+		},
+		{
+			Id:   aws.String("srv-2"),
+			Name: aws.String("api"),
+			DnsConfig: &awssd.DnsConfig{
+				NamespaceId:   aws.String("ns-1"),
+				RoutingPolicy: aws.String("WEIGHTED"),
+				DnsRecords:    []*awssd.DnsRecord{{TTL: aws.Int64(60), Type: aws.String("A")}},
+			},
+			InstanceCount: aws.Int64(1),
+		},
+	},
+}
+
+func (m *mockSDClient) ListNamespacesPagesWithContext(ctx aws.Context, input *awssd.ListNamespacesInput, fn func(*awssd.ListNamespacesOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	fn(&awssd.ListNamespacesOutput{Namespaces: testSDNamespaces}, true)
+	return nil
+}
+
+func (m *mockSDClient) GetNamespaceWithContext(ctx aws.Context, input *awssd.GetNamespaceInput, opts ...request.Option) (*awssd.GetNamespaceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	for _, ns := range testSDNamespaces {
+		if aws.StringValue(ns.Id) == aws.StringValue(input.Id) {
+			return &awssd.GetNamespaceOutput{Namespace: &awssd.Namespace{Id: ns.Id, Name: ns.Name, Type: ns.Type}}, nil
+		}
+	}
+
+	return nil, awserr.New(awssd.ErrCodeNamespaceNotFound, "namespace not found", nil)
+}
+
+func (m *mockSDClient) ListServicesPagesWithContext(ctx aws.Context, input *awssd.ListServicesInput, fn func(*awssd.ListServicesOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	fn(&awssd.ListServicesOutput{Services: testSDServices[aws.StringValue(input.Filters[0].Values[0])]}, true)
+	return nil
+}
+
+func TestServiceDiscoveryListHandler(t *testing.T) {
+	type service struct {
+		Id            string
+		Name          string
+		DnsConfig     *awssd.DnsConfig
+		InstanceCount int64
+	}
+
+	type namespace struct {
+		Id       string
+		Name     string
+		Type     string
+		Services []service
+	}
+
+	ns1 := namespace{
+		Id:   "ns-1",
+		Name: "spinup.internal",
+		Type: "DNS_PRIVATE",
+		Services: []service{
+			{Id: "srv-1", Name: "www", DnsConfig: testSDServices["ns-1"][0].DnsConfig, InstanceCount: 2},
+			{Id: "srv-2", Name: "api", DnsConfig: testSDServices["ns-1"][1].DnsConfig, InstanceCount: 1},
+		},
+	}
+
+	ns2 := namespace{Id: "ns-2", Name: "empty.internal", Type: "DNS_PRIVATE", Services: []service{}}
+
+	tests := []struct {
+		name       string
+		account    string
+		namespace  string
+		err        error
+		wantStatus int
+		want       interface{}
+	}{
+		{
+			name:       "list namespaces and services",
+			account:    "acct1",
+			wantStatus: http.StatusOK,
+			want:       []namespace{ns1, ns2},
+		},
+		{
+			name:       "single namespace",
+			account:    "acct1",
+			namespace:  "ns-1",
+			wantStatus: http.StatusOK,
+			want:       ns1,
+		},
+		{
+			name:       "missing namespace",
+			account:    "acct1",
+			namespace:  "ns-missing",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "missing account",
+			account:    "acct2",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "servicediscovery error",
+			account:    "acct1",
+			err:        awserr.New(awssd.ErrCodeInvalidInput, "boom", nil),
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := server{
+				sdServices: map[string]servicediscovery.ServiceDiscovery{
+					"acct1": {Service: &mockSDClient{t: t, err: tt.err}},
+				},
+			}
+
+			vars := map[string]string{"account": tt.account}
+			path := "/v1/ecs/" + tt.account + "/servicediscovery"
+			if tt.namespace != "" {
+				vars["namespace"] = tt.namespace
+				path += "/" + tt.namespace
+			}
+
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req = mux.SetURLVars(req, vars)
+			rr := httptest.NewRecorder()
+
+			s.ServiceDiscoveryListHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got interface{}
+			switch tt.want.(type) {
+			case namespace:
+				n := namespace{}
+				if err := json.Unmarshal(rr.Body.Bytes(), &n); err != nil {
+					t.Fatalf("failed to unmarshal response: %s", err)
+				}
+				got = n
+			default:
+				n := []namespace{}
+				if err := json.Unmarshal(rr.Body.Bytes(), &n); err != nil {
+					t.Fatalf("failed to unmarshal response: %s", err)
+				}
+				got = n
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
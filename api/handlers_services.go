@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/YaleSpinup/apierror"
 	"github.com/YaleSpinup/ecs-api/orchestration"
@@ -44,8 +46,18 @@ func (s *server) ServiceCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := unsupportedContainerFields(body, true); err != nil {
+		handleError(w, err)
+		return
+	}
+
 	log.Debugf("decoded request into service orchestration request:\n%+v", req)
 
+	if req.Cluster != nil && req.Service != nil && req.TaskDefinition != nil {
+		auditResource(r, aws.StringValue(req.Cluster.ClusterName), aws.StringValue(req.Service.ServiceName), aws.StringValue(req.TaskDefinition.Family))
+	}
+	auditOrg(r, s.requestOrg(req.Tags))
+
 	output, err := orchestrator.CreateService(r.Context(), &req)
 	if err != nil {
 		log.Errorf("error in creating service orchestration: %s", err)
@@ -82,6 +94,20 @@ func (s *server) ServiceDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		recursive = b
 	}
 
+	// Check for the wait query param to delete dependencies synchronously
+	wait := false
+	b, err = strconv.ParseBool(r.URL.Query().Get("wait"))
+	if err == nil {
+		wait = b
+	}
+
+	// Check for the cleanupRegistry query param to delete the service discovery services on a non-recursive delete
+	cleanupRegistry := false
+	b, err = strconv.ParseBool(r.URL.Query().Get("cleanupRegistry"))
+	if err == nil {
+		cleanupRegistry = b
+	}
+
 	orchestrator, err := s.newOrchestrator(account)
 	if err != nil {
 		handleError(w, err)
@@ -89,9 +115,11 @@ func (s *server) ServiceDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	output, err := orchestrator.DeleteService(r.Context(), &orchestration.ServiceDeleteInput{
-		Cluster:   aws.String(cluster),
-		Service:   aws.String(service),
-		Recursive: recursive,
+		Cluster:                aws.String(cluster),
+		Service:                aws.String(service),
+		Recursive:              recursive,
+		Wait:                   wait,
+		CleanupServiceRegistry: cleanupRegistry,
 	})
 	if err != nil {
 		log.Errorf("error in service delete orchestration: %s", err)
@@ -106,12 +134,60 @@ func (s *server) ServiceDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// report a multi-status if any of the dependencies failed to delete
+	status := http.StatusOK
+	for _, d := range output.Dependencies {
+		if d.Status == orchestration.DependencyDeleteFailed {
+			status = http.StatusMultiStatus
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(j)
+}
+
+// DeleteCancelHandler cancels the background cleanup of a recursive delete in a cluster by its token (the DeleteToken
+// returned by the delete) as long as the cleanup hasn't started
+func (s *server) DeleteCancelHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	token := vars["token"]
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if err := orchestrator.CancelDelete(cluster, token); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	type cancelOutput struct {
+		DeleteToken string
+		Status      string
+	}
+
+	output := cancelOutput{DeleteToken: token, Status: "canceled"}
+	j, err := json.Marshal(output)
+	if err != nil {
+		log.Errorf("cannot marshal response (%v) into JSON: %s", output, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(j)
 }
 
-// ServiceUpdateHandler updates a service and its dependencies
+// ServiceUpdateHandler updates a service and its dependencies.  The optional wait query param is the number of
+// seconds (up to orchestration.MaxDeploymentStatusWait) to wait for the updated service to become stable.
 func (s *server) ServiceUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	w = LogWriter{w}
 	vars := mux.Vars(r)
@@ -119,6 +195,20 @@ func (s *server) ServiceUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	cluster := vars["cluster"]
 	service := vars["service"]
 
+	var wait time.Duration
+	if q := r.URL.Query().Get("wait"); q != "" {
+		seconds, err := strconv.Atoi(q)
+		if err != nil || seconds <= 0 {
+			handleError(w, apierror.New(apierror.ErrBadRequest, "wait must be a positive number of seconds", err))
+			return
+		}
+
+		wait = time.Duration(seconds) * time.Second
+		if wait > orchestration.MaxDeploymentStatusWait {
+			wait = orchestration.MaxDeploymentStatusWait
+		}
+	}
+
 	body, _ := ioutil.ReadAll(r.Body)
 	log.Debugf("update service (%s/%s) orchestration request body: %s", cluster, service, body)
 
@@ -128,6 +218,11 @@ func (s *server) ServiceUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := unsupportedContainerFields(body, true); err != nil {
+		handleError(w, err)
+		return
+	}
+
 	log.Debugf("decoded request into service (%s/%s) orchestration request:\n%+v", cluster, service, req)
 
 	orchestrator, err := s.newOrchestrator(account)
@@ -136,12 +231,82 @@ func (s *server) ServiceUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	start := time.Now()
 	output, err := orchestrator.UpdateService(r.Context(), cluster, service, &req)
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
+	// the update is applied, so running out of wait time isn't an error
+	status := http.StatusOK
+	if wait > 0 {
+		// the update (and its rollout wait) and waiting for the service to become stable share the
+		// MaxDeploymentStatusWait so the response is written before the server write timeout
+		if remaining := orchestration.MaxDeploymentStatusWait - time.Since(start); remaining < wait {
+			wait = remaining
+		}
+
+		if wait <= 0 {
+			log.Infof("no time left to wait for service %s/%s to become stable", cluster, service)
+			status = http.StatusAccepted
+		} else {
+			stability, err := orchestrator.ECS.WaitUntilServiceStable(r.Context(), cluster, service, wait)
+			if err != nil {
+				handleError(w, err)
+				return
+			}
+
+			// the response has the current deployments, the service isn't stable yet if the wait has passed
+			if output.Service != nil {
+				output.Service.Deployments = stability.Deployments
+			}
+
+			if !stability.Stable {
+				status = http.StatusAccepted
+			}
+		}
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(j)
+}
+
+// ServiceCloneHandler creates a new service from the configuration and active task definition of an existing service
+func (s *server) ServiceCloneHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	service := vars["service"]
+
+	var req orchestration.ServiceCloneInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to decode json into input", err))
+		return
+	}
+
+	log.Debugf("decoded request into service (%s/%s) clone request:\n%+v", cluster, service, req)
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.CloneService(r.Context(), cluster, service, &req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
 	j, err := json.Marshal(output)
 	if err != nil {
 		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
@@ -166,8 +331,14 @@ func (s *server) ServiceListHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Collect all of the task
-	output, err := ecsService.ListServices(r.Context(), cluster)
+	tags, err := parseTagFilterQuery(r)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "invalid tag filter", err))
+		return
+	}
+
+	// Collect all of the services, filtered by tags if any are passed
+	output, err := ecsService.ListServicesWithTags(r.Context(), cluster, tags)
 	if err != nil {
 		handleError(w, err)
 		return
@@ -296,9 +467,23 @@ func (s *server) ServiceShowHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
+// newOrchestrator creates an orchestrator for the account.  The token is passed to AWS as a client token, so it's
+// generated for each orchestrator instead of using the caller supplied request id, which is only used for auditing.
 func (s server) newOrchestrator(account string) (*orchestration.Orchestrator, error) {
 	log.Debugf("creating new orchestrator for account %s", account)
 
+	aasService, ok := s.aasServices[account]
+	if !ok {
+		msg := fmt.Sprintf("application autoscaling service not found for account: %s", account)
+		return nil, apierror.New(apierror.ErrNotFound, msg, nil)
+	}
+
+	cwService, ok := s.cwServices[account]
+	if !ok {
+		msg := fmt.Sprintf("cloudwatch service not found for account: %s", account)
+		return nil, apierror.New(apierror.ErrNotFound, msg, nil)
+	}
+
 	cwlService, ok := s.cwLogsServices[account]
 	if !ok {
 		msg := fmt.Sprintf("cloudwatchlogs service not found for account: %s", account)
@@ -311,12 +496,24 @@ func (s server) newOrchestrator(account string) (*orchestration.Orchestrator, er
 		return nil, apierror.New(apierror.ErrNotFound, msg, nil)
 	}
 
+	ebService, ok := s.ebServices[account]
+	if !ok {
+		msg := fmt.Sprintf("eventbridge service not found for account: %s", account)
+		return nil, apierror.New(apierror.ErrNotFound, msg, nil)
+	}
+
 	iamService, ok := s.iamServices[account]
 	if !ok {
 		msg := fmt.Sprintf("iam service not found for account: %s", account)
 		return nil, apierror.New(apierror.ErrNotFound, msg, nil)
 	}
 
+	kmsService, ok := s.kmsServices[account]
+	if !ok {
+		msg := fmt.Sprintf("kms service not found for account: %s", account)
+		return nil, apierror.New(apierror.ErrNotFound, msg, nil)
+	}
+
 	rgTaggingAPIService, ok := s.rgTaggingAPIServices[account]
 	if !ok {
 		msg := fmt.Sprintf("resourcegroups tagging service not found for account: %s", account)
@@ -336,17 +533,42 @@ func (s server) newOrchestrator(account string) (*orchestration.Orchestrator, er
 	}
 
 	return &orchestration.Orchestrator{
-		CloudWatchLogs:           cwlService,
-		ECS:                      ecsService,
-		IAM:                      iamService,
-		ResourceGroupsTaggingAPI: rgTaggingAPIService,
-		SecretsManager:           smService,
-		ServiceDiscovery:         sdService,
-		DefaultSecurityGroups:    ecsService.DefaultSgs,
-		DefaultSubnets:           ecsService.DefaultSubnets,
-		DefaultPublic:            "DISABLED",
-		Token:                    uuid.NewV4().String(),
-		Org:                      s.org,
+		ApplicationAutoScaling:           aasService,
+		CloudWatch:                       cwService,
+		CloudWatchLogs:                   cwlService,
+		ECS:                              ecsService,
+		EventBridge:                      ebService,
+		IAM:                              iamService,
+		KMS:                              kmsService,
+		ResourceGroupsTaggingAPI:         rgTaggingAPIService,
+		SecretsManager:                   smService,
+		ServiceDiscovery:                 sdService,
+		DefaultSecurityGroups:            ecsService.DefaultSgs,
+		DefaultSubnets:                   ecsService.DefaultSubnets,
+		DefaultSecurityGroupLabels:       ecsService.DefaultSgLabels,
+		DefaultSubnetLabels:              ecsService.DefaultSubnetLabels,
+		DefaultPublic:                    "DISABLED",
+		Token:                            uuid.NewV4().String(),
+		Account:                          account,
+		Org:                              s.org,
+		AllowedOrgs:                      s.orgs,
+		DefaultTags:                      s.defaultTags,
+		ServicePropagateTags:             s.servicePropagateTags,
+		DeleteTimeout:                    s.deleteTimeout,
+		DeleteConcurrency:                s.deleteConcurrency,
+		DeleteGracePeriod:                s.deleteGracePeriod,
+		PendingDeletes:                   s.pendingDeletes,
+		SecretReferences:                 s.secretReferences,
+		StrictImageReferences:            s.strictImages,
+		AllowedRegistries:                s.allowedRegistries,
+		DeniedRegistries:                 s.deniedRegistries,
+		StrictRepositoryCredentials:      s.strictCredentials,
+		UniqueRepositoryCredentialsNames: s.uniqueCredentials,
+		DisableTaskExecutionRoleCreation: s.disableRoleCreation,
+		AwslogsMode:                      s.awslogs.Mode,
+		AwslogsMaxBufferSize:             s.awslogs.MaxBufferSize,
+		AwslogsGroupNamePattern:          s.awslogs.GroupNamePattern,
+		PrefixTaskDefinitionFamilies:     s.prefixFamilies,
 	}, nil
 }
 
@@ -364,13 +586,19 @@ func (s *server) ServiceEventsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query, err := parseServiceEventsQuery(r)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "failed to parse events query", err))
+		return
+	}
+
 	output, err := ecsService.GetService(r.Context(), cluster, service)
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
-	events := output.Events
+	events := filterServiceEvents(output.Events, query)
 	j, err := json.Marshal(events)
 	if err != nil {
 		handleError(w, err)
@@ -382,8 +610,303 @@ func (s *server) ServiceEventsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
-// ServiceLogsHandler gets the logs for a task/container by using the cluster name as
-// the log group name and constructing the log stream from the service name, the task id, and the container name
+// ServiceDeploymentFailuresHandler classifies the recent events of a service in a cluster into the likely causes of a
+// failing deployment
+func (s *server) ServiceDeploymentFailuresHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	service := vars["service"]
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.ServiceDeploymentFailures(r.Context(), cluster, service)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ServiceDeploymentStatusHandler gets the deployments for a service in a cluster and whether the service is stable.  The
+// optional wait query param is the number of seconds to wait for the service to become stable.
+func (s *server) ServiceDeploymentStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	service := vars["service"]
+
+	var wait time.Duration
+	if q := r.URL.Query().Get("wait"); q != "" {
+		seconds, err := strconv.Atoi(q)
+		if err != nil || seconds <= 0 {
+			handleError(w, apierror.New(apierror.ErrBadRequest, "wait must be a positive number of seconds", err))
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.ServiceDeploymentStatus(r.Context(), cluster, service, wait)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	// the service didn't become stable before the wait passed
+	status := http.StatusOK
+	if wait > 0 && !output.Stable {
+		status = http.StatusAccepted
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(j)
+}
+
+// ServiceRevisionStatusHandler reports whether a service in a cluster is running the latest ACTIVE revision of its task
+// definition family and how many revisions behind it is
+func (s *server) ServiceRevisionStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	service := vars["service"]
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.ServiceRevisionStatus(r.Context(), cluster, service)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ServiceTasksStopHandler stops all of the running tasks for a service and returns the ids of the stopped tasks, along
+// with the tasks that failed to stop.  The service isn't scaled down, so ECS launches replacement tasks to maintain the
+// desired count.  The optional reason query param is recorded as the stopped reason of each task.
+func (s *server) ServiceTasksStopHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	service := vars["service"]
+	reason := r.URL.Query().Get("reason")
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.StopServiceTasks(r.Context(), cluster, service, reason)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	// the remaining tasks are still stopped when a task fails to stop
+	status := http.StatusOK
+	if len(output.Failed) > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(j)
+}
+
+// ServiceTagsAuditHandler returns the tags of a service, its cluster, task definition and repository credentials
+// side by side along with the tag keys whose values have drifted
+func (s *server) ServiceTagsAuditHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	service := vars["service"]
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.ServiceTagsAudit(r.Context(), cluster, service)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ServiceCredentialsKmsKeyHandler re-encrypts the repository credentials secrets of a service with the KMS key in the body
+func (s *server) ServiceCredentialsKmsKeyHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	service := vars["service"]
+
+	input := struct {
+		KmsKeyId string
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		msg := fmt.Sprintf("cannot decode body into kms key input: %s", err)
+		handleError(w, apierror.New(apierror.ErrBadRequest, msg, err))
+		return
+	}
+	defer r.Body.Close()
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.UpdateServiceCredentialsKmsKey(r.Context(), cluster, service, input.KmsKeyId)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	// report a multi-status if any of the secrets failed to re-encrypt
+	status := http.StatusOK
+	for _, o := range output {
+		if !o.Updated {
+			status = http.StatusMultiStatus
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(j)
+}
+
+// ServiceCredentialsRotationHandler gets the rotation status of the repository credentials secrets of a service
+func (s *server) ServiceCredentialsRotationHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	service := vars["service"]
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.ServiceCredentialsRotationStatus(r.Context(), cluster, service)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ServiceLogsReconcileHandler recreates any missing cloudwatch log groups used by the containers of a service
+func (s *server) ServiceLogsReconcileHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	service := vars["service"]
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.ReconcileServiceLogGroups(r.Context(), cluster, service)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ServiceLogsHandler gets the logs for a task/container by reading the awslogs log group and stream prefix from
+// the container's log configuration in the service's task definition
 func (s *server) ServiceLogsHandler(w http.ResponseWriter, r *http.Request) {
 	w = LogWriter{w}
 	vars := mux.Vars(r)
@@ -393,18 +916,31 @@ func (s *server) ServiceLogsHandler(w http.ResponseWriter, r *http.Request) {
 	task := vars["task"]
 	container := vars["container"]
 
-	logService, ok := s.cwLogsServices[account]
-	if !ok {
-		msg := fmt.Sprintf("cloudwatch logs service not found for account: %s", account)
-		handleError(w, apierror.New(apierror.ErrNotFound, msg, nil))
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	config, err := orchestrator.ServiceContainerLogConfiguration(r.Context(), cluster, service, container)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	// without a stream prefix, awslogs names the stream for the docker container id which can't be derived from the task
+	if config.StreamPrefix == "" {
+		msg := fmt.Sprintf("container %s in service %s/%s has no awslogs stream prefix", container, cluster, service)
+		handleError(w, apierror.New(apierror.ErrBadRequest, msg, nil))
 		return
 	}
 
-	logStream := fmt.Sprintf("%s/%s/%s", service, container, task)
-	log.Debugf("getting events for log group/stream: %s/%s", cluster, logStream)
+	logGroup := config.LogGroup
+	logStream := config.LogStream(task)
+	log.Debugf("getting events for log group/stream: %s/%s", logGroup, logStream)
 
 	input := cloudwatchlogs.GetLogEventsInput{
-		LogGroupName:  aws.String(cluster),
+		LogGroupName:  aws.String(logGroup),
 		LogStreamName: aws.String(logStream),
 	}
 
@@ -414,7 +950,7 @@ func (s *server) ServiceLogsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Debugf("requesting log events with input: %+v", input)
-	output, err := logService.GetLogEvents(r.Context(), &input)
+	output, err := orchestrator.CloudWatchLogs.GetLogEvents(r.Context(), &input)
 	if err != nil {
 		handleError(w, err)
 		return
@@ -431,6 +967,61 @@ func (s *server) ServiceLogsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
+// ServiceLogGroupHandler gets the metadata (ie. retention, stored bytes and creation time) of the log group used by a
+// container in the service's task definition, the first container using the awslogs driver unless one is passed
+func (s *server) ServiceLogGroupHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	service := vars["service"]
+	container := r.URL.Query().Get("container")
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	config, err := orchestrator.ServiceContainerLogConfiguration(r.Context(), cluster, service, container)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	logGroup := config.LogGroup
+	log.Debugf("getting log group %s", logGroup)
+
+	lg, err := orchestrator.CloudWatchLogs.DescribeLogGroup(r.Context(), logGroup)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	// RetentionInDays isn't set when the events never expire
+	j, err := json.Marshal(struct {
+		LogGroupName    string
+		Arn             string
+		RetentionInDays *int64
+		StoredBytes     int64
+		CreationTime    time.Time
+	}{
+		LogGroupName:    aws.StringValue(lg.LogGroupName),
+		Arn:             aws.StringValue(lg.Arn),
+		RetentionInDays: lg.RetentionInDays,
+		StoredBytes:     aws.Int64Value(lg.StoredBytes),
+		CreationTime:    time.UnixMilli(aws.Int64Value(lg.CreationTime)).UTC(),
+	})
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
 // parseLogQuery processes the query parameters for logs
 func parseLogQuery(r *http.Request, input *cloudwatchlogs.GetLogEventsInput) error {
 	for name, values := range r.URL.Query() {
@@ -472,3 +1063,123 @@ func parseLogQuery(r *http.Request, input *cloudwatchlogs.GetLogEventsInput) err
 	}
 	return nil
 }
+
+// serviceEventsQuery is the filter and page of service events to return
+type serviceEventsQuery struct {
+	// filter is a case insensitive substring of the event message
+	filter string
+	// start and end bound the event created time
+	start, end *time.Time
+	// offset is the number of matching events to skip and limit is the maximum number returned (0 is unlimited)
+	offset, limit int
+}
+
+// parseServiceEventsQuery processes the query parameters for service events.  Start and end times are in milliseconds
+// from the unix epoch, the same as the logs query.
+func parseServiceEventsQuery(r *http.Request) (*serviceEventsQuery, error) {
+	query := &serviceEventsQuery{}
+	for name, values := range r.URL.Query() {
+		switch name {
+		case "filter":
+			log.Debugf("processing filter value '%s'", values[0])
+			query.filter = strings.ToLower(values[0])
+		case "start", "end":
+			log.Debugf("processing %s value '%s'", name, values[0])
+			ms, err := strconv.ParseInt(values[0], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			t := time.UnixMilli(ms)
+			if name == "start" {
+				query.start = &t
+			} else {
+				query.end = &t
+			}
+		case "limit", "offset":
+			log.Debugf("processing %s value '%s'", name, values[0])
+			i, err := strconv.Atoi(values[0])
+			if err != nil {
+				return nil, err
+			}
+
+			if i < 0 || (name == "limit" && i == 0) {
+				return nil, fmt.Errorf("invalid %s %d", name, i)
+			}
+
+			if name == "limit" {
+				query.limit = i
+			} else {
+				query.offset = i
+			}
+		default:
+			log.Debugf("ignoring %s parameter", name)
+		}
+	}
+
+	if query.start != nil && query.end != nil && query.end.Before(*query.start) {
+		return nil, fmt.Errorf("end cannot be before start")
+	}
+
+	return query, nil
+}
+
+// filterServiceEvents returns the page of service events matching the query, events keep the (newest first) order
+// returned by ECS
+func filterServiceEvents(events []*ecs.ServiceEvent, query *serviceEventsQuery) []*ecs.ServiceEvent {
+	matched := []*ecs.ServiceEvent{}
+	for _, e := range events {
+		if e == nil {
+			continue
+		}
+
+		if query.filter != "" && !strings.Contains(strings.ToLower(aws.StringValue(e.Message)), query.filter) {
+			continue
+		}
+
+		created := aws.TimeValue(e.CreatedAt)
+		if query.start != nil && created.Before(*query.start) {
+			continue
+		}
+
+		if query.end != nil && created.After(*query.end) {
+			continue
+		}
+
+		matched = append(matched, e)
+	}
+
+	if query.offset >= len(matched) {
+		return []*ecs.ServiceEvent{}
+	}
+	matched = matched[query.offset:]
+
+	if query.limit > 0 && query.limit < len(matched) {
+		matched = matched[:query.limit]
+	}
+
+	return matched
+}
+
+// parseTagFilterQuery processes the tag.Key=Value query parameters into a map of tag keys to values
+func parseTagFilterQuery(r *http.Request) (map[string]string, error) {
+	tags := map[string]string{}
+	for name, values := range r.URL.Query() {
+		if !strings.HasPrefix(name, "tag.") {
+			continue
+		}
+
+		key := strings.TrimPrefix(name, "tag.")
+		if key == "" {
+			return nil, fmt.Errorf("tag filter key cannot be empty")
+		}
+
+		if len(values) > 1 {
+			return nil, fmt.Errorf("only one value can be passed for tag filter %s", key)
+		}
+
+		log.Debugf("processing tag filter '%s' = '%s'", key, values[0])
+		tags[key] = values[0]
+	}
+	return tags, nil
+}
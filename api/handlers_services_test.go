@@ -3,14 +3,360 @@ package api
 import (
 	"errors"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/YaleSpinup/ecs-api/applicationautoscaling"
+	"github.com/YaleSpinup/ecs-api/cloudwatch"
+	cwl "github.com/YaleSpinup/ecs-api/cloudwatchlogs"
+	ecsapi "github.com/YaleSpinup/ecs-api/ecs"
+	"github.com/YaleSpinup/ecs-api/eventbridge"
+	"github.com/YaleSpinup/ecs-api/iam"
+	"github.com/YaleSpinup/ecs-api/kms"
+	"github.com/YaleSpinup/ecs-api/resourcegroupstaggingapi"
+	"github.com/YaleSpinup/ecs-api/secretsmanager"
+	"github.com/YaleSpinup/ecs-api/servicediscovery"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/gorilla/mux"
 )
 
+type mockCWLClient struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	t *testing.T
+	// logGroup is the name of the log group events were requested from
+	logGroup string
+	// logStream is the name of the log stream events were requested from
+	logStream string
+}
+
+func (m *mockCWLClient) GetLogEventsWithContext(ctx aws.Context, input *cloudwatchlogs.GetLogEventsInput, opts ...request.Option) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	m.logGroup = aws.StringValue(input.LogGroupName)
+	m.logStream = aws.StringValue(input.LogStreamName)
+	return &cloudwatchlogs.GetLogEventsOutput{}, nil
+}
+
+func (m *mockCWLClient) DescribeLogGroupsPagesWithContext(ctx aws.Context, input *cloudwatchlogs.DescribeLogGroupsInput, fn func(*cloudwatchlogs.DescribeLogGroupsOutput, bool) bool, opts ...request.Option) error {
+	m.logGroup = aws.StringValue(input.LogGroupNamePrefix)
+
+	fn(&cloudwatchlogs.DescribeLogGroupsOutput{
+		LogGroups: []*cloudwatchlogs.LogGroup{
+			{
+				CreationTime:    aws.Int64(1600000000000),
+				LogGroupName:    aws.String("myorg/clu1"),
+				RetentionInDays: aws.Int64(365),
+				StoredBytes:     aws.Int64(2048),
+			},
+		},
+	}, true)
+
+	return nil
+}
+
+// testLogsTaskDefinition is the task definition of the datfam service, with the log configurations of its containers
+var testLogsTaskDefinition = &ecs.TaskDefinition{
+	ContainerDefinitions: []*ecs.ContainerDefinition{
+		{
+			Name:             aws.String("splunk"),
+			LogConfiguration: &ecs.LogConfiguration{LogDriver: aws.String("splunk")},
+		},
+		{
+			Name: aws.String("app"),
+			LogConfiguration: &ecs.LogConfiguration{
+				LogDriver: aws.String("awslogs"),
+				Options: map[string]*string{
+					"awslogs-group":         aws.String("myorg/clu1"),
+					"awslogs-region":        aws.String("us-east-1"),
+					"awslogs-stream-prefix": aws.String("datfam"),
+				},
+			},
+		},
+		{
+			Name: aws.String("legacy"),
+			LogConfiguration: &ecs.LogConfiguration{
+				LogDriver: aws.String("awslogs"),
+				Options: map[string]*string{
+					"awslogs-group":         aws.String("clu1"),
+					"awslogs-stream-prefix": aws.String("oldfam"),
+				},
+			},
+		},
+		{
+			Name: aws.String("noprefix"),
+			LogConfiguration: &ecs.LogConfiguration{
+				LogDriver: aws.String("awslogs"),
+				Options:   map[string]*string{"awslogs-group": aws.String("clu1")},
+			},
+		},
+		{
+			Name: aws.String("west"),
+			LogConfiguration: &ecs.LogConfiguration{
+				LogDriver: aws.String("awslogs"),
+				Options: map[string]*string{
+					"awslogs-group":         aws.String("clu1"),
+					"awslogs-region":        aws.String("us-west-2"),
+					"awslogs-stream-prefix": aws.String("datfam"),
+				},
+			},
+		},
+	},
+	Family:            aws.String("datfam"),
+	Revision:          aws.Int64(3),
+	TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/datfam:3"),
+}
+
+func (m *mockECSClient) DescribeServicesWithContext(ctx aws.Context, input *ecs.DescribeServicesInput, opts ...request.Option) (*ecs.DescribeServicesOutput, error) {
+	name := aws.StringValue(input.Services[0])
+	if name != "datfam" {
+		return &ecs.DescribeServicesOutput{
+			Failures: []*ecs.Failure{{Arn: aws.String("arn:aws:ecs:us-east-1:0123456789:service/clu1/" + name), Reason: aws.String("MISSING")}},
+		}, nil
+	}
+
+	return &ecs.DescribeServicesOutput{
+		Services: []*ecs.Service{
+			{
+				ServiceName:    aws.String(name),
+				Status:         aws.String("ACTIVE"),
+				TaskDefinition: testLogsTaskDefinition.TaskDefinitionArn,
+			},
+		},
+	}, nil
+}
+
+func (m *mockECSClient) DescribeTaskDefinitionWithContext(ctx aws.Context, input *ecs.DescribeTaskDefinitionInput, opts ...request.Option) (*ecs.DescribeTaskDefinitionOutput, error) {
+	return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: testLogsTaskDefinition}, nil
+}
+
+// newLogsTestServer returns a server for acct1 with the mock cloudwatch logs and ecs clients
+func newLogsTestServer(client *mockCWLClient) server {
+	return server{
+		org:                  "myorg",
+		aasServices:          map[string]applicationautoscaling.ApplicationAutoScaling{"acct1": {}},
+		cwServices:           map[string]cloudwatch.CloudWatch{"acct1": {}},
+		cwLogsServices:       map[string]cwl.CloudWatchLogs{"acct1": {Service: client, Region: "us-east-1"}},
+		ecsServices:          map[string]ecsapi.ECS{"acct1": {Service: &mockECSClient{}}},
+		ebServices:           map[string]eventbridge.EventBridge{"acct1": {}},
+		iamServices:          map[string]iam.IAM{"acct1": {}},
+		kmsServices:          map[string]kms.KMS{"acct1": {}},
+		rgTaggingAPIServices: map[string]resourcegroupstaggingapi.ResourceGroupsTaggingAPI{"acct1": {}},
+		sdServices:           map[string]servicediscovery.ServiceDiscovery{"acct1": {}},
+		smServices:           map[string]secretsmanager.SecretsManager{"acct1": {}},
+	}
+}
+
+func TestServiceLogGroupHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		service      string
+		container    string
+		wantStatus   int
+		wantLogGroup string
+		wantBody     string
+	}{
+		{
+			name:         "log group of the first awslogs container",
+			service:      "datfam",
+			wantStatus:   http.StatusOK,
+			wantLogGroup: "myorg/clu1",
+			wantBody:     `{"LogGroupName":"myorg/clu1","Arn":"","RetentionInDays":365,"StoredBytes":2048,"CreationTime":"2020-09-13T12:26:40Z"}`,
+		},
+		{
+			name:         "missing log group of a container",
+			service:      "datfam",
+			container:    "legacy",
+			wantStatus:   http.StatusNotFound,
+			wantLogGroup: "clu1",
+		},
+		{
+			name:       "container without awslogs",
+			service:    "datfam",
+			container:  "splunk",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "missing service",
+			service:    "missing",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockCWLClient{t: t}
+			s := newLogsTestServer(client)
+
+			target := "/v1/ecs/acct1/clusters/clu1/services/" + tt.service + "/logs/group"
+			if tt.container != "" {
+				target = target + "?container=" + tt.container
+			}
+
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req = mux.SetURLVars(req, map[string]string{
+				"account": "acct1",
+				"cluster": "clu1",
+				"service": tt.service,
+			})
+			rr := httptest.NewRecorder()
+
+			s.ServiceLogGroupHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if client.logGroup != tt.wantLogGroup {
+				t.Errorf("expected log group %s, got %s", tt.wantLogGroup, client.logGroup)
+			}
+
+			if tt.wantBody != "" && rr.Body.String() != tt.wantBody {
+				t.Errorf("expected body %s, got %s", tt.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestServiceDeploymentStatusHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		wait       string
+		wantStatus int
+	}{
+		{
+			name:       "current status",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "not stable before the wait passed",
+			wait:       "1",
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "invalid wait",
+			wait:       "soon",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "zero wait",
+			wait:       "0",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "negative wait",
+			wait:       "-1",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newLogsTestServer(&mockCWLClient{t: t})
+
+			target := "/v1/ecs/acct1/clusters/clu1/services/datfam/deployments"
+			if tt.wait != "" {
+				target = target + "?wait=" + tt.wait
+			}
+
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req = mux.SetURLVars(req, map[string]string{
+				"account": "acct1",
+				"cluster": "clu1",
+				"service": "datfam",
+			})
+			rr := httptest.NewRecorder()
+
+			s.ServiceDeploymentStatusHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantStatus == http.StatusAccepted && rr.Body.String() != `{"Deployments":null,"Stable":false}` {
+				t.Errorf("expected the current deployment status, got %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestServiceLogsHandlerLogConfiguration(t *testing.T) {
+	tests := []struct {
+		name          string
+		container     string
+		wantStatus    int
+		wantLogGroup  string
+		wantLogStream string
+	}{
+		{
+			name:          "log group and stream prefix from the container",
+			container:     "app",
+			wantStatus:    http.StatusOK,
+			wantLogGroup:  "myorg/clu1",
+			wantLogStream: "datfam/app/abc",
+		},
+		{
+			name:          "log group created with an earlier pattern",
+			container:     "legacy",
+			wantStatus:    http.StatusOK,
+			wantLogGroup:  "clu1",
+			wantLogStream: "oldfam/legacy/abc",
+		},
+		{
+			name:       "no stream prefix",
+			container:  "noprefix",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "log group in another region",
+			container:  "west",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "container without awslogs",
+			container:  "splunk",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "missing container",
+			container:  "missing",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockCWLClient{t: t}
+			s := newLogsTestServer(client)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/ecs/acct1/clusters/clu1/services/datfam/logs?task=abc&container="+tt.container, nil)
+			req = mux.SetURLVars(req, map[string]string{
+				"account":   "acct1",
+				"cluster":   "clu1",
+				"service":   "datfam",
+				"task":      "abc",
+				"container": tt.container,
+			})
+			rr := httptest.NewRecorder()
+
+			s.ServiceLogsHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if client.logGroup != tt.wantLogGroup {
+				t.Errorf("expected log group %s, got %s", tt.wantLogGroup, client.logGroup)
+			}
+
+			if client.logStream != tt.wantLogStream {
+				t.Errorf("expected log stream %s, got %s", tt.wantLogStream, client.logStream)
+			}
+		})
+	}
+}
+
 func TestParseLogQuery(t *testing.T) {
 	type logQueryParseTest struct {
 		query string
@@ -121,3 +467,209 @@ func TestParseLogQuery(t *testing.T) {
 		}
 	}
 }
+
+func TestParseTagFilterQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			query: "",
+			want:  map[string]string{},
+		},
+		{
+			query: "all=true",
+			want:  map[string]string{},
+		},
+		{
+			query: "tag.spinup:app=myapp",
+			want:  map[string]string{"spinup:app": "myapp"},
+		},
+		{
+			query: "tag.spinup:app=myapp&tag.env=prod&all=true",
+			want:  map[string]string{"spinup:app": "myapp", "env": "prod"},
+		},
+		{
+			query: "tag.env=",
+			want:  map[string]string{"env": ""},
+		},
+		{
+			query:   "tag.=myapp",
+			wantErr: true,
+		},
+		{
+			query:   "tag.env=prod&tag.env=dev",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		got, err := parseTagFilterQuery(r)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTagFilterQuery(%s) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTagFilterQuery(%s) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+var testServiceEvents = []*ecs.ServiceEvent{
+	{
+		CreatedAt: aws.Time(time.UnixMilli(5000)),
+		Id:        aws.String("e5"),
+		Message:   aws.String("(service svc1) has reached a steady state."),
+	},
+	{
+		CreatedAt: aws.Time(time.UnixMilli(4000)),
+		Id:        aws.String("e4"),
+		Message:   aws.String("(service svc1) has started 1 tasks: (task 4)."),
+	},
+	{
+		CreatedAt: aws.Time(time.UnixMilli(3000)),
+		Id:        aws.String("e3"),
+		Message:   aws.String("(service svc1) Task failed to start: (task 3)."),
+	},
+	{
+		CreatedAt: aws.Time(time.UnixMilli(2000)),
+		Id:        aws.String("e2"),
+		Message:   aws.String("(service svc1) has started 1 tasks: (task 2)."),
+	},
+	{
+		CreatedAt: aws.Time(time.UnixMilli(1000)),
+		Id:        aws.String("e1"),
+		Message:   aws.String("(service svc1) task failed ELB health checks: (task 1)."),
+	},
+}
+
+func TestServiceEventsQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			query: "",
+			want:  []string{"e5", "e4", "e3", "e2", "e1"},
+		},
+		{
+			query: "filter=failed",
+			want:  []string{"e3", "e1"},
+		},
+		{
+			query: "filter=TASK+FAILED",
+			want:  []string{"e3", "e1"},
+		},
+		{
+			query: "limit=2",
+			want:  []string{"e5", "e4"},
+		},
+		{
+			query: "limit=2&offset=2",
+			want:  []string{"e3", "e2"},
+		},
+		{
+			query: "offset=10",
+			want:  []string{},
+		},
+		{
+			query: "filter=started&limit=1",
+			want:  []string{"e4"},
+		},
+		{
+			query: "start=2000&end=4000",
+			want:  []string{"e4", "e3", "e2"},
+		},
+		{
+			query: "filter=nothing",
+			want:  []string{},
+		},
+		{
+			query:   "limit=0",
+			wantErr: true,
+		},
+		{
+			query:   "offset=-1",
+			wantErr: true,
+		},
+		{
+			query:   "limit=true",
+			wantErr: true,
+		},
+		{
+			query:   "start=4000&end=2000",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			r := &http.Request{
+				URL: &url.URL{
+					RawQuery: test.query,
+				},
+			}
+
+			query, err := parseServiceEventsQuery(r)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseServiceEventsQuery() error = %v, wantErr %v", err, test.wantErr)
+			}
+
+			if test.wantErr {
+				return
+			}
+
+			got := []string{}
+			for _, e := range filterServiceEvents(testServiceEvents, query) {
+				got = append(got, aws.StringValue(e.Id))
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected events %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestNewOrchestratorToken(t *testing.T) {
+	s := server{
+		org:                  "myorg",
+		aasServices:          map[string]applicationautoscaling.ApplicationAutoScaling{"acct1": {}},
+		cwServices:           map[string]cloudwatch.CloudWatch{"acct1": {}},
+		cwLogsServices:       map[string]cwl.CloudWatchLogs{"acct1": {}},
+		ecsServices:          map[string]ecsapi.ECS{"acct1": {}},
+		ebServices:           map[string]eventbridge.EventBridge{"acct1": {}},
+		iamServices:          map[string]iam.IAM{"acct1": {}},
+		kmsServices:          map[string]kms.KMS{"acct1": {}},
+		rgTaggingAPIServices: map[string]resourcegroupstaggingapi.ResourceGroupsTaggingAPI{"acct1": {}},
+		sdServices:           map[string]servicediscovery.ServiceDiscovery{"acct1": {}},
+		smServices:           map[string]secretsmanager.SecretsManager{"acct1": {}},
+	}
+
+	// the token is passed to AWS as a client token, so it can't be taken from the (unbounded, reusable) request id
+	first, err := s.newOrchestrator("acct1")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	second, err := s.newOrchestrator("acct1")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if first.Token == "" || len(first.Token) > 36 {
+		t.Errorf("expected a client token of up to 36 characters, got %q", first.Token)
+	}
+
+	if first.Token == second.Token {
+		t.Errorf("expected a unique token for each orchestrator, got %s twice", first.Token)
+	}
+
+	if first.Account != "acct1" {
+		t.Errorf("expected orchestrator account acct1, got %s", first.Account)
+	}
+}
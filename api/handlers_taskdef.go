@@ -1,12 +1,16 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 
 	"github.com/YaleSpinup/apierror"
 	"github.com/YaleSpinup/ecs-api/orchestration"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
 
 	"github.com/gorilla/mux"
 
@@ -26,14 +30,26 @@ func (s *server) TaskDefCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	body, _ := ioutil.ReadAll(r.Body)
+
 	var req orchestration.TaskDefCreateOrchestrationInput
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil {
 		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to decode json into input", err))
 		return
 	}
 
+	if err := unsupportedContainerFields(body, true); err != nil {
+		handleError(w, err)
+		return
+	}
+
 	log.Debugf("decoded request into taskdef orchestration request: %+v", req)
 
+	if req.Cluster != nil && req.TaskDefinition != nil {
+		auditResource(r, aws.StringValue(req.Cluster.ClusterName), "", aws.StringValue(req.TaskDefinition.Family))
+	}
+	auditOrg(r, s.requestOrg(req.Tags))
+
 	output, err := orchestrator.CreateTaskDef(r.Context(), &req)
 	if err != nil {
 		handleError(w, err)
@@ -51,6 +67,46 @@ func (s *server) TaskDefCreateHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
+// TaskDefValidateHandler handles validating a task definition without registering it
+func (s *server) TaskDefValidateHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+
+	var req ecs.RegisterTaskDefinitionInput
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to decode json into input", err))
+		return
+	}
+
+	if err := unsupportedContainerFields(body, false); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	log.Debugf("decoded request into taskdef validate request: %+v", req)
+
+	output := orchestrator.ValidateTaskDef(&req)
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
 // TaskDefDeleteHandler handles deleting task definitions and related resources
 func (s *server) TaskDefDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	w = LogWriter{w}
@@ -167,6 +223,240 @@ func (s *server) TaskDefShowHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
+// TaskDefCompatibilityHandler handles getting the declared compatibility and requirements of a task definition in a cluster
+func (s *server) TaskDefCompatibilityHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	taskdef := vars["taskdef"]
+
+	log.Debugf("getting taskdef compatibility %s/%s/%s", account, cluster, taskdef)
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.TaskDefCompatibility(r.Context(), cluster, taskdef)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ScheduledTasksHandler handles listing the EventBridge rules that run a task definition in a cluster on a schedule
+func (s *server) ScheduledTasksHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	taskdef := vars["taskdef"]
+
+	log.Debugf("listing scheduled tasks for taskdef %s/%s/%s", account, cluster, taskdef)
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.ScheduledTasks(r.Context(), cluster, taskdef)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ScheduleTaskHandler handles creating an EventBridge schedule that runs a task definition in a cluster
+func (s *server) ScheduleTaskHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	taskdef := vars["taskdef"]
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	var req orchestration.ScheduledTaskCreateInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to decode json into input", err))
+		return
+	}
+
+	log.Debugf("decoded request into schedule task request: %+v", req)
+
+	output, err := orchestrator.ScheduleTask(r.Context(), cluster, taskdef, &req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// ScheduledTaskDeleteHandler handles deleting an EventBridge schedule (the rule and its targets) for a task definition
+func (s *server) ScheduledTaskDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	taskdef := vars["taskdef"]
+	schedule := vars["schedule"]
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if err := orchestrator.DeleteScheduledTask(r.Context(), cluster, taskdef, schedule); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// TaskDefTagsReconcileHandler handles applying the spinup tags to the latest revision of a task definition family
+// so that legacy task definitions become discoverable
+func (s *server) TaskDefTagsReconcileHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	taskdef := vars["taskdef"]
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.ReconcileTaskDefTags(r.Context(), cluster, taskdef)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// TaskDefContainersHandler handles getting the container definitions of a task definition in a cluster
+func (s *server) TaskDefContainersHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	taskdef := vars["taskdef"]
+
+	log.Debugf("getting taskdef containers %s/%s/%s", account, cluster, taskdef)
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.TaskDefContainers(r.Context(), cluster, taskdef)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// TaskDefRolesHandler gets the execution and task role of a task definition in a cluster.  Passing the policies
+// query param also lists the inline policy names of each role.
+func (s *server) TaskDefRolesHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+	taskdef := vars["taskdef"]
+
+	policies := false
+	if b, err := strconv.ParseBool(r.URL.Query().Get("policies")); err == nil {
+		policies = b
+	}
+
+	log.Debugf("getting taskdef roles %s/%s/%s", account, cluster, taskdef)
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.TaskDefRoles(r.Context(), cluster, taskdef, policies)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
 // TaskDefUpdateHandler handles updating a task definition in a cluster
 func (s *server) TaskDefUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	w = LogWriter{w}
@@ -183,12 +473,19 @@ func (s *server) TaskDefUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	body, _ := ioutil.ReadAll(r.Body)
+
 	var req orchestration.TaskDefUpdateOrchestrationInput
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil {
 		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to decode json into input", err))
 		return
 	}
 
+	if err := unsupportedContainerFields(body, true); err != nil {
+		handleError(w, err)
+		return
+	}
+
 	log.Debugf("decoded request into taskdef orchestration request:\n %+v", req)
 
 	output, err := orchestrator.UpdateTaskDef(r.Context(), cluster, taskdef, &req)
@@ -208,6 +505,8 @@ func (s *server) TaskDefUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
+// TaskDefRunHandler runs a task from a task definition in a cluster.  With the wait query param, a single task is run and
+// polled until it stops, returning the exit code of the essential container, or a 202 if it's still running.
 func (s *server) TaskDefRunHandler(w http.ResponseWriter, r *http.Request) {
 	w = LogWriter{w}
 	vars := mux.Vars(r)
@@ -221,15 +520,51 @@ func (s *server) TaskDefRunHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	body, _ := ioutil.ReadAll(r.Body)
+
 	var req orchestration.TaskDefRunOrchestrationInput
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to decode json into input", err))
+		return
+	}
+
+	// the network selection is passed alongside the run task input
+	var sel struct {
+		NetworkSelection *orchestration.NetworkSelection
+	}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&sel); err != nil {
 		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to decode json into input", err))
 		return
 	}
 
 	log.Debugf("decoded request into taskdef orchestration request: %+v", req)
 
-	output, err := orchestrator.RunTaskDef(r.Context(), cluster, taskdef, req)
+	if wait, _ := strconv.ParseBool(r.URL.Query().Get("wait")); wait {
+		output, err := orchestrator.RunTaskAndWait(r.Context(), cluster, taskdef, req, sel.NetworkSelection, orchestration.MaxTaskRunWait)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+
+		j, err := json.Marshal(output)
+		if err != nil {
+			handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+			return
+		}
+
+		// the task is still running if it didn't stop in time
+		status := http.StatusOK
+		if !output.Stopped {
+			status = http.StatusAccepted
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(j)
+		return
+	}
+
+	output, err := orchestrator.RunTaskDef(r.Context(), cluster, taskdef, req, sel.NetworkSelection)
 	if err != nil {
 		handleError(w, err)
 		return
@@ -287,3 +622,39 @@ func (s *server) TaskDefTaskListHandler(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 	w.Write(j)
 }
+
+// unsupportedContainerFields returns a bad request error if any container definition in the request body
+// sets a field that the ECS client can't send to ECS yet.  Those fields would otherwise be silently dropped
+// when the body is decoded.  When nested is true, the task definition is expected under the TaskDefinition key.
+func unsupportedContainerFields(body []byte, nested bool) error {
+	type containerDefinition struct {
+		// RestartPolicy isn't part of ecs.ContainerDefinition in aws-sdk-go v1
+		RestartPolicy json.RawMessage
+	}
+
+	type taskDefinition struct {
+		ContainerDefinitions []containerDefinition
+	}
+
+	var td taskDefinition
+	if nested {
+		var req struct {
+			TaskDefinition taskDefinition
+		}
+
+		if err := json.Unmarshal(body, &req); err != nil {
+			return apierror.New(apierror.ErrBadRequest, "unable to decode json into input", err)
+		}
+		td = req.TaskDefinition
+	} else if err := json.Unmarshal(body, &td); err != nil {
+		return apierror.New(apierror.ErrBadRequest, "unable to decode json into input", err)
+	}
+
+	for _, c := range td.ContainerDefinitions {
+		if len(c.RestartPolicy) > 0 && string(c.RestartPolicy) != "null" {
+			return apierror.New(apierror.ErrBadRequest, "container restart policies are not supported", nil)
+		}
+	}
+
+	return nil
+}
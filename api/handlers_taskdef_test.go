@@ -0,0 +1,53 @@
+package api
+
+import (
+	"testing"
+)
+
+func Test_unsupportedContainerFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		nested  bool
+		wantErr bool
+	}{
+		{
+			name:   "nested without a restart policy",
+			body:   `{"TaskDefinition":{"Family":"webapp","ContainerDefinitions":[{"Name":"webserver","Image":"nginx:alpine"}]}}`,
+			nested: true,
+		},
+		{
+			name:   "nested with a null restart policy",
+			body:   `{"TaskDefinition":{"ContainerDefinitions":[{"Name":"webserver","RestartPolicy":null}]}}`,
+			nested: true,
+		},
+		{
+			name:    "nested with a restart policy",
+			body:    `{"TaskDefinition":{"ContainerDefinitions":[{"Name":"webserver"},{"name":"worker","restartPolicy":{"enabled":true,"restartAttemptPeriod":60}}]}}`,
+			nested:  true,
+			wantErr: true,
+		},
+		{
+			name: "without a restart policy",
+			body: `{"Family":"webapp","ContainerDefinitions":[{"Name":"webserver","Image":"nginx:alpine"}]}`,
+		},
+		{
+			name:    "with a restart policy",
+			body:    `{"Family":"webapp","ContainerDefinitions":[{"Name":"webserver","RestartPolicy":{"Enabled":true,"IgnoredExitCodes":[0]}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "bad json",
+			body:    `{"TaskDefinition":`,
+			nested:  true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := unsupportedContainerFields([]byte(tt.body), tt.nested); (err != nil) != tt.wantErr {
+				t.Errorf("unsupportedContainerFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
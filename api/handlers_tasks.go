@@ -4,9 +4,46 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/YaleSpinup/apierror"
 	"github.com/gorilla/mux"
 )
 
+// TasksByStartedByHandler lists the tasks in a cluster started by the given invoker, across statuses
+func (s *server) TasksByStartedByHandler(w http.ResponseWriter, r *http.Request) {
+	w = LogWriter{w}
+	vars := mux.Vars(r)
+	account := vars["account"]
+	cluster := vars["cluster"]
+
+	startedBy := r.URL.Query().Get("startedBy")
+	if startedBy == "" {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "startedBy is required", nil))
+		return
+	}
+
+	orchestrator, err := s.newOrchestrator(account)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	output, err := orchestrator.ListStartedByTasks(r.Context(), cluster, startedBy)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	j, err := json.Marshal(output)
+	if err != nil {
+		handleError(w, apierror.New(apierror.ErrBadRequest, "unable to marshal response to json", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
 // TaskShowHandler gets the details for a task in a cluster
 func (s *server) TaskShowHandler(w http.ResponseWriter, r *http.Request) {
 	w = LogWriter{w}
@@ -8,6 +8,8 @@ import (
 
 func (s *server) routes() {
 	api := s.router.PathPrefix("/v1/ecs").Subrouter()
+	api.Use(s.auditMiddleware)
+
 	api.HandleFunc("/ping", s.PingHandler).Methods(http.MethodGet)
 	api.HandleFunc("/version", s.VersionHandler).Methods(http.MethodGet)
 	api.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
@@ -15,28 +17,56 @@ func (s *server) routes() {
 	// Docker image handlers
 	api.HandleFunc("/images", s.ImageVerificationHandler).Methods(http.MethodHead).Queries("image", "{image}")
 
+	// Cluster handlers
+	api.HandleFunc("/{account}/clusters", s.ClusterListHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/tags", s.ClusterTagsHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/summary", s.ClusterSummaryHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/inventory", s.ClusterInventoryHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/settings", s.ClusterSettingsHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/settings", s.ClusterSettingsHandler).Methods(http.MethodPut)
+
 	// Service handlers
 	api.HandleFunc("/{account}/services", s.ServiceCreateHandler).Methods(http.MethodPost)
 	api.HandleFunc("/{account}/clusters/{cluster}/services", s.ServiceListHandler).Methods(http.MethodGet)
 	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}", s.ServiceUpdateHandler).Methods(http.MethodPut)
 	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}", s.ServiceDeleteHandler).Methods(http.MethodDelete)
+	api.HandleFunc("/{account}/clusters/{cluster}/deletes/{token}", s.DeleteCancelHandler).Methods(http.MethodDelete)
 	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}", s.ServiceShowHandler).Methods(http.MethodGet)
 	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/events", s.ServiceEventsHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/clone", s.ServiceCloneHandler).Methods(http.MethodPost)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/deployments", s.ServiceDeploymentStatusHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/deployments/failures", s.ServiceDeploymentFailuresHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/revision", s.ServiceRevisionStatusHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/credentials", s.ServiceCredentialsKmsKeyHandler).Methods(http.MethodPut)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/credentials/rotation", s.ServiceCredentialsRotationHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/tags", s.ServiceTagsAuditHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/tasks", s.ServiceTasksStopHandler).Methods(http.MethodDelete)
 
 	// Log handlers
 	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/logs", s.ServiceLogsHandler).Methods(http.MethodGet).
 		Queries("task", "{task}", "container", "{container}")
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/logs", s.ServiceLogsReconcileHandler).Methods(http.MethodPut)
+	api.HandleFunc("/{account}/clusters/{cluster}/services/{service}/logs/group", s.ServiceLogGroupHandler).Methods(http.MethodGet)
 
 	// Tasks handlers
+	api.HandleFunc("/{account}/clusters/{cluster}/tasks", s.TasksByStartedByHandler).Methods(http.MethodGet).Queries("startedBy", "{startedBy}")
 	api.HandleFunc("/{account}/clusters/{cluster}/tasks/{task}", s.TaskShowHandler).Methods(http.MethodGet)
 	api.HandleFunc("/{account}/clusters/{cluster}/tasks/{task}", s.TaskStopHandler).Methods(http.MethodDelete)
 
 	// TaskDef handlers
 	api.HandleFunc("/{account}/taskdefs", s.TaskDefCreateHandler).Methods(http.MethodPost)
+	api.HandleFunc("/{account}/taskdefs/validate", s.TaskDefValidateHandler).Methods(http.MethodPost)
 	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs", s.TaskDefListHandler).Methods(http.MethodGet)
 	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}", s.TaskDefUpdateHandler).Methods(http.MethodPut)
 	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}", s.TaskDefDeleteHandler).Methods(http.MethodDelete)
 	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}", s.TaskDefShowHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}/compatibility", s.TaskDefCompatibilityHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}/containers", s.TaskDefContainersHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}/roles", s.TaskDefRolesHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}/schedules", s.ScheduledTasksHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}/schedules", s.ScheduleTaskHandler).Methods(http.MethodPost)
+	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}/schedules/{schedule}", s.ScheduledTaskDeleteHandler).Methods(http.MethodDelete)
+	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}/tags", s.TaskDefTagsReconcileHandler).Methods(http.MethodPut)
 
 	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}/tasks", s.TaskDefRunHandler).Methods(http.MethodPost)
 	api.HandleFunc("/{account}/clusters/{cluster}/taskdefs/{taskdef}/tasks", s.TaskDefTaskListHandler).Methods(http.MethodGet)
@@ -49,15 +79,26 @@ func (s *server) routes() {
 	api.HandleFunc("/{account}/secrets/{secret}", s.SecretShowHandler).Methods(http.MethodGet)
 	api.HandleFunc("/{account}/secrets/{secret}", s.SecretDeleteHandler).Methods(http.MethodDelete)
 	api.HandleFunc("/{account}/secrets/{secret}", s.SecretUpdateHandler).Methods(http.MethodPut)
+	api.HandleFunc("/{account}/credentials/orphaned", s.OrphanedCredentialsHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/credentials/orphaned", s.OrphanedCredentialsHandler).Methods(http.MethodDelete)
+
+	// Service quota handlers
+	api.HandleFunc("/{account}/quotas", s.QuotasHandler).Methods(http.MethodGet)
 
 	// Parameter store handlers
 	api.HandleFunc("/{account}/params/{prefix}", s.ParamCreateHandler).Methods(http.MethodPost)
+	api.HandleFunc("/{account}/params/{prefix}", s.ParamExportHandler).Methods(http.MethodGet).Queries("withValues", "{withValues}")
 	api.HandleFunc("/{account}/params/{prefix}", s.ParamListHandler).Methods(http.MethodGet)
 	api.HandleFunc("/{account}/params/{prefix}", s.ParamDeleteAllHandler).Methods(http.MethodDelete)
 	api.HandleFunc("/{account}/params/{prefix}/{param}", s.ParamShowHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/params/{prefix}/{param}/history", s.ParamHistoryHandler).Methods(http.MethodGet)
 	api.HandleFunc("/{account}/params/{prefix}/{param}", s.ParamDeleteHandler).Methods(http.MethodDelete)
 	api.HandleFunc("/{account}/params/{prefix}/{param}", s.ParamUpdateHandler).Methods(http.MethodPut)
 
+	// Service discovery handlers
+	api.HandleFunc("/{account}/servicediscovery", s.ServiceDiscoveryListHandler).Methods(http.MethodGet)
+	api.HandleFunc("/{account}/servicediscovery/{namespace}", s.ServiceDiscoveryListHandler).Methods(http.MethodGet)
+
 	// ALB/NLB Target group handlers
 	api.HandleFunc("/{account}/lbs", s.LoadBalancerListHandler).Methods(http.MethodGet).Queries("space", "{space}")
 	api.HandleFunc("/{account}/lbs/{space}", s.LoadBalancerDescribeHandler).Methods(http.MethodGet)
@@ -2,22 +2,30 @@ package api
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
 	"time"
 
+	"github.com/YaleSpinup/ecs-api/applicationautoscaling"
+	"github.com/YaleSpinup/ecs-api/cloudwatch"
 	"github.com/YaleSpinup/ecs-api/cloudwatchlogs"
 	"github.com/YaleSpinup/ecs-api/common"
 	"github.com/YaleSpinup/ecs-api/ecs"
 	"github.com/YaleSpinup/ecs-api/elbv2"
+	"github.com/YaleSpinup/ecs-api/eventbridge"
 	"github.com/YaleSpinup/ecs-api/iam"
+	"github.com/YaleSpinup/ecs-api/kms"
+	"github.com/YaleSpinup/ecs-api/orchestration"
 	"github.com/YaleSpinup/ecs-api/resourcegroupstaggingapi"
 	"github.com/YaleSpinup/ecs-api/secretsmanager"
 	"github.com/YaleSpinup/ecs-api/servicediscovery"
+	"github.com/YaleSpinup/ecs-api/servicequotas"
 	"github.com/YaleSpinup/ecs-api/ssm"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -33,32 +41,75 @@ type apiVersion struct {
 }
 
 type server struct {
+	aasServices          map[string]applicationautoscaling.ApplicationAutoScaling
+	cwServices           map[string]cloudwatch.CloudWatch
 	cwLogsServices       map[string]cloudwatchlogs.CloudWatchLogs
 	ecsServices          map[string]ecs.ECS
+	ebServices           map[string]eventbridge.EventBridge
 	elbv2Services        map[string]elbv2.ELBV2API
 	iamServices          map[string]iam.IAM
+	kmsServices          map[string]kms.KMS
 	rgTaggingAPIServices map[string]resourcegroupstaggingapi.ResourceGroupsTaggingAPI
 	sdServices           map[string]servicediscovery.ServiceDiscovery
 	smServices           map[string]secretsmanager.SecretsManager
+	sqServices           map[string]servicequotas.ServiceQuotas
 	ssmServices          map[string]ssm.SSM
 	router               *mux.Router
 	version              *apiVersion
 	org                  string
+	orgs                 []string
+	defaultTags          map[string]string
+	servicePropagateTags string
+	deleteTimeout        time.Duration
+	deleteConcurrency    int
+	deleteGracePeriod    time.Duration
+	pendingDeletes       *orchestration.PendingDeletes
+	secretReferences     *orchestration.SecretReferences
+	strictImages         bool
+	allowedRegistries    []string
+	deniedRegistries     []string
+	strictCredentials    bool
+	uniqueCredentials    bool
+	disableRoleCreation  bool
+	prefixFamilies       bool
+	awslogs              common.Awslogs
+	auditor              auditSink
 }
 
 // NewServer creates a new server and starts it
 func NewServer(config common.Config) error {
 	s := server{
+		aasServices:          make(map[string]applicationautoscaling.ApplicationAutoScaling),
+		cwServices:           make(map[string]cloudwatch.CloudWatch),
 		cwLogsServices:       make(map[string]cloudwatchlogs.CloudWatchLogs),
 		ecsServices:          make(map[string]ecs.ECS),
+		ebServices:           make(map[string]eventbridge.EventBridge),
 		elbv2Services:        make(map[string]elbv2.ELBV2API),
 		iamServices:          make(map[string]iam.IAM),
+		kmsServices:          make(map[string]kms.KMS),
 		rgTaggingAPIServices: make(map[string]resourcegroupstaggingapi.ResourceGroupsTaggingAPI),
 		sdServices:           make(map[string]servicediscovery.ServiceDiscovery),
 		smServices:           make(map[string]secretsmanager.SecretsManager),
+		sqServices:           make(map[string]servicequotas.ServiceQuotas),
 		ssmServices:          make(map[string]ssm.SSM),
 		router:               mux.NewRouter(),
 		org:                  config.Org,
+		orgs:                 config.Orgs,
+		defaultTags:          config.DefaultTags,
+		servicePropagateTags: config.ServicePropagateTags,
+		deleteTimeout:        time.Duration(config.RecursiveDelete.Timeout) * time.Second,
+		deleteConcurrency:    config.RecursiveDelete.Concurrency,
+		deleteGracePeriod:    time.Duration(config.RecursiveDelete.GracePeriod) * time.Second,
+		pendingDeletes:       orchestration.NewPendingDeletes(),
+		secretReferences:     orchestration.NewSecretReferences(),
+		strictImages:         config.StrictImageReferences,
+		allowedRegistries:    config.AllowedRegistries,
+		deniedRegistries:     config.DeniedRegistries,
+		strictCredentials:    config.StrictRepositoryCredentials,
+		uniqueCredentials:    config.UniqueRepositoryCredentialsNames,
+		disableRoleCreation:  config.DisableTaskExecutionRoleCreation,
+		prefixFamilies:       config.PrefixTaskDefinitionFamilies,
+		awslogs:              config.Awslogs,
 		version: &apiVersion{
 			Version:    config.Version.Version,
 			GitHash:    config.Version.GitHash,
@@ -66,18 +117,49 @@ func NewServer(config common.Config) error {
 		},
 	}
 
+	if err := orchestration.ValidateDefaultTags(config.DefaultTags); err != nil {
+		return errors.Wrap(err, "invalid default tags")
+	}
+
+	if err := orchestration.ValidateServicePropagateTags(config.ServicePropagateTags); err != nil {
+		return errors.Wrap(err, "invalid service propagate tags")
+	}
+
 	for name, c := range config.Accounts {
 		log.Debugf("Creating new services for account '%s' with key '%s' in region '%s'", name, c.Akid, c.Region)
+		c.AWS = config.AWS
+		s.aasServices[name] = applicationautoscaling.NewSession(c)
+		s.cwServices[name] = cloudwatch.NewSession(c)
 		s.cwLogsServices[name] = cloudwatchlogs.NewSession(c)
 		s.ecsServices[name] = ecs.NewSession(c)
+		s.ebServices[name] = eventbridge.NewSession(c)
 		s.elbv2Services[name] = elbv2.NewSession(c)
 		s.iamServices[name] = iam.NewSession(c)
+		s.kmsServices[name] = kms.NewSession(c)
 		s.rgTaggingAPIServices[name] = resourcegroupstaggingapi.NewSession(c)
 		s.sdServices[name] = servicediscovery.NewSession(c)
 		s.smServices[name] = secretsmanager.NewSession(c)
+		s.sqServices[name] = servicequotas.NewSession(c)
 		s.ssmServices[name] = ssm.NewSession(c)
 	}
 
+	if config.Audit.Enabled {
+		var w io.Writer = os.Stdout
+		dest := "stdout"
+		if config.Audit.File != "" {
+			f, err := os.OpenFile(config.Audit.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+			dest = config.Audit.File
+		}
+
+		log.Infof("writing audit events for mutating requests to %s", dest)
+		s.auditor = &jsonAuditSink{w: w}
+	}
+
 	publicURLs := map[string]string{
 		"/v1/ecs/ping":    "public",
 		"/v1/ecs/version": "public",
@@ -90,7 +172,7 @@ func NewServer(config common.Config) error {
 	if config.ListenAddress == "" {
 		config.ListenAddress = ":8080"
 	}
-	handler := handlers.RecoveryHandler()(handlers.LoggingHandler(os.Stdout, TokenMiddleware([]byte(config.Token), publicURLs, s.router)))
+	handler := handlers.RecoveryHandler()(handlers.LoggingHandler(os.Stdout, requestIDMiddleware(TokenMiddleware([]byte(config.Token), publicURLs, s.router))))
 	srv := &http.Server{
 		Handler:      handler,
 		Addr:         config.ListenAddress,
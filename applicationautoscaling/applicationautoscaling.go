@@ -0,0 +1,109 @@
+package applicationautoscaling
+
+import (
+	"context"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/YaleSpinup/ecs-api/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling/applicationautoscalingiface"
+	log "github.com/sirupsen/logrus"
+)
+
+// ApplicationAutoScaling is a wrapper around the aws application autoscaling service
+type ApplicationAutoScaling struct {
+	Service applicationautoscalingiface.ApplicationAutoScalingAPI
+}
+
+// NewSession creates a new application autoscaling session
+func NewSession(account common.Account) ApplicationAutoScaling {
+	a := ApplicationAutoScaling{}
+	log.Infof("creating new session with key id %s in region %s", account.Akid, account.Region)
+	sess := session.Must(session.NewSession(account.AWSConfig()))
+	a.Service = applicationautoscaling.New(sess)
+	return a
+}
+
+// ListScalableTargets lists the scalable targets for a resource in a service namespace
+func (a *ApplicationAutoScaling) ListScalableTargets(ctx context.Context, namespace, resourceID string) ([]*applicationautoscaling.ScalableTarget, error) {
+	if namespace == "" || resourceID == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing scalable targets for %s resource %s", namespace, resourceID)
+
+	targets := []*applicationautoscaling.ScalableTarget{}
+	if err := a.Service.DescribeScalableTargetsPagesWithContext(ctx,
+		&applicationautoscaling.DescribeScalableTargetsInput{
+			ResourceIds:      aws.StringSlice([]string{resourceID}),
+			ServiceNamespace: aws.String(namespace),
+		},
+		func(out *applicationautoscaling.DescribeScalableTargetsOutput, lastPage bool) bool {
+			targets = append(targets, out.ScalableTargets...)
+			return true
+		}); err != nil {
+		return nil, ErrCode("failed to list scalable targets", err)
+	}
+
+	log.Debugf("got list of scalable targets for %s: %+v", resourceID, targets)
+
+	return targets, nil
+}
+
+// DeregisterScalableTarget deregisters a scalable target, deleting the scaling policies associated with it
+func (a *ApplicationAutoScaling) DeregisterScalableTarget(ctx context.Context, input *applicationautoscaling.DeregisterScalableTargetInput) error {
+	if input == nil {
+		return apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("deregistering scalable target %s for %s", aws.StringValue(input.ScalableDimension), aws.StringValue(input.ResourceId))
+
+	if _, err := a.Service.DeregisterScalableTargetWithContext(ctx, input); err != nil {
+		return ErrCode("failed to deregister scalable target", err)
+	}
+
+	return nil
+}
+
+// ListScalingPolicies lists the scaling policies for a resource in a service namespace
+func (a *ApplicationAutoScaling) ListScalingPolicies(ctx context.Context, namespace, resourceID string) ([]*applicationautoscaling.ScalingPolicy, error) {
+	if namespace == "" || resourceID == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing scaling policies for %s resource %s", namespace, resourceID)
+
+	policies := []*applicationautoscaling.ScalingPolicy{}
+	if err := a.Service.DescribeScalingPoliciesPagesWithContext(ctx,
+		&applicationautoscaling.DescribeScalingPoliciesInput{
+			ResourceId:       aws.String(resourceID),
+			ServiceNamespace: aws.String(namespace),
+		},
+		func(out *applicationautoscaling.DescribeScalingPoliciesOutput, lastPage bool) bool {
+			policies = append(policies, out.ScalingPolicies...)
+			return true
+		}); err != nil {
+		return nil, ErrCode("failed to list scaling policies", err)
+	}
+
+	log.Debugf("got list of scaling policies for %s: %+v", resourceID, policies)
+
+	return policies, nil
+}
+
+// DeleteScalingPolicy deletes a scaling policy and the cloudwatch alarms associated with it
+func (a *ApplicationAutoScaling) DeleteScalingPolicy(ctx context.Context, input *applicationautoscaling.DeleteScalingPolicyInput) error {
+	if input == nil {
+		return apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("deleting scaling policy %s for %s", aws.StringValue(input.PolicyName), aws.StringValue(input.ResourceId))
+
+	if _, err := a.Service.DeleteScalingPolicyWithContext(ctx, input); err != nil {
+		return ErrCode("failed to delete scaling policy", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,308 @@
+package applicationautoscaling
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/YaleSpinup/ecs-api/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling/applicationautoscalingiface"
+)
+
+var testScalableTargets = []*applicationautoscaling.ScalableTarget{
+	{
+		MaxCapacity:       aws.Int64(4),
+		MinCapacity:       aws.Int64(1),
+		ResourceId:        aws.String("service/clu1/svc1"),
+		ScalableDimension: aws.String("ecs:service:DesiredCount"),
+		ServiceNamespace:  aws.String("ecs"),
+	},
+}
+
+var testScalingPolicies = []*applicationautoscaling.ScalingPolicy{
+	{
+		PolicyName:        aws.String("svc1-cpu"),
+		PolicyType:        aws.String("TargetTrackingScaling"),
+		ResourceId:        aws.String("service/clu1/svc1"),
+		ScalableDimension: aws.String("ecs:service:DesiredCount"),
+		ServiceNamespace:  aws.String("ecs"),
+	},
+	{
+		PolicyName:        aws.String("svc1-memory"),
+		PolicyType:        aws.String("TargetTrackingScaling"),
+		ResourceId:        aws.String("service/clu1/svc1"),
+		ScalableDimension: aws.String("ecs:service:DesiredCount"),
+		ServiceNamespace:  aws.String("ecs"),
+	},
+}
+
+type mockAASClient struct {
+	applicationautoscalingiface.ApplicationAutoScalingAPI
+	t   *testing.T
+	err error
+}
+
+func newmockAASClient(t *testing.T, err error) applicationautoscalingiface.ApplicationAutoScalingAPI {
+	return &mockAASClient{
+		t:   t,
+		err: err,
+	}
+}
+
+func (m *mockAASClient) DescribeScalableTargetsPagesWithContext(ctx aws.Context, input *applicationautoscaling.DescribeScalableTargetsInput, fn func(*applicationautoscaling.DescribeScalableTargetsOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	targets := []*applicationautoscaling.ScalableTarget{}
+	for _, t := range testScalableTargets {
+		for _, id := range input.ResourceIds {
+			if aws.StringValue(t.ResourceId) == aws.StringValue(id) && aws.StringValue(t.ServiceNamespace) == aws.StringValue(input.ServiceNamespace) {
+				targets = append(targets, t)
+			}
+		}
+	}
+
+	fn(&applicationautoscaling.DescribeScalableTargetsOutput{ScalableTargets: targets}, true)
+	return nil
+}
+
+func (m *mockAASClient) DescribeScalingPoliciesPagesWithContext(ctx aws.Context, input *applicationautoscaling.DescribeScalingPoliciesInput, fn func(*applicationautoscaling.DescribeScalingPoliciesOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	// return each policy as its own page
+	policies := []*applicationautoscaling.ScalingPolicy{}
+	for _, p := range testScalingPolicies {
+		if aws.StringValue(p.ResourceId) == aws.StringValue(input.ResourceId) && aws.StringValue(p.ServiceNamespace) == aws.StringValue(input.ServiceNamespace) {
+			policies = append(policies, p)
+		}
+	}
+
+	for i, p := range policies {
+		if !fn(&applicationautoscaling.DescribeScalingPoliciesOutput{ScalingPolicies: []*applicationautoscaling.ScalingPolicy{p}}, i == len(policies)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *mockAASClient) DeregisterScalableTargetWithContext(ctx aws.Context, input *applicationautoscaling.DeregisterScalableTargetInput, opts ...request.Option) (*applicationautoscaling.DeregisterScalableTargetOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	for _, t := range testScalableTargets {
+		if aws.StringValue(t.ResourceId) == aws.StringValue(input.ResourceId) && aws.StringValue(t.ScalableDimension) == aws.StringValue(input.ScalableDimension) {
+			return &applicationautoscaling.DeregisterScalableTargetOutput{}, nil
+		}
+	}
+
+	return nil, awserr.New(applicationautoscaling.ErrCodeObjectNotFoundException, "not found", nil)
+}
+
+func (m *mockAASClient) DeleteScalingPolicyWithContext(ctx aws.Context, input *applicationautoscaling.DeleteScalingPolicyInput, opts ...request.Option) (*applicationautoscaling.DeleteScalingPolicyOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	for _, p := range testScalingPolicies {
+		if aws.StringValue(p.PolicyName) == aws.StringValue(input.PolicyName) {
+			return &applicationautoscaling.DeleteScalingPolicyOutput{}, nil
+		}
+	}
+
+	return nil, awserr.New(applicationautoscaling.ErrCodeObjectNotFoundException, "not found", nil)
+}
+
+func TestNewSession(t *testing.T) {
+	a := NewSession(common.Account{})
+	to := reflect.TypeOf(a).String()
+	if to != "applicationautoscaling.ApplicationAutoScaling" {
+		t.Errorf("expected type to be 'applicationautoscaling.ApplicationAutoScaling', got %s", to)
+	}
+}
+
+func TestApplicationAutoScaling_ListScalableTargets(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespace  string
+		resourceID string
+		err        error
+		want       []*applicationautoscaling.ScalableTarget
+		wantErr    bool
+	}{
+		{
+			name:    "empty input",
+			wantErr: true,
+		},
+		{
+			name:       "service with a scalable target",
+			namespace:  "ecs",
+			resourceID: "service/clu1/svc1",
+			want:       testScalableTargets,
+		},
+		{
+			name:       "service without a scalable target",
+			namespace:  "ecs",
+			resourceID: "service/clu1/svc2",
+			want:       []*applicationautoscaling.ScalableTarget{},
+		},
+		{
+			name:       "aws error",
+			namespace:  "ecs",
+			resourceID: "service/clu1/svc1",
+			err:        awserr.New(applicationautoscaling.ErrCodeInternalServiceException, "boom", nil),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &ApplicationAutoScaling{Service: newmockAASClient(t, tt.err)}
+			got, err := a.ListScalableTargets(context.TODO(), tt.namespace, tt.resourceID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ApplicationAutoScaling.ListScalableTargets() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ApplicationAutoScaling.ListScalableTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplicationAutoScaling_ListScalingPolicies(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespace  string
+		resourceID string
+		err        error
+		want       []*applicationautoscaling.ScalingPolicy
+		wantErr    bool
+	}{
+		{
+			name:    "empty input",
+			wantErr: true,
+		},
+		{
+			name:       "paged policies",
+			namespace:  "ecs",
+			resourceID: "service/clu1/svc1",
+			want:       testScalingPolicies,
+		},
+		{
+			name:       "no policies",
+			namespace:  "ecs",
+			resourceID: "service/clu1/svc2",
+			want:       []*applicationautoscaling.ScalingPolicy{},
+		},
+		{
+			name:       "aws error",
+			namespace:  "ecs",
+			resourceID: "service/clu1/svc1",
+			err:        awserr.New(applicationautoscaling.ErrCodeInternalServiceException, "boom", nil),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &ApplicationAutoScaling{Service: newmockAASClient(t, tt.err)}
+			got, err := a.ListScalingPolicies(context.TODO(), tt.namespace, tt.resourceID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ApplicationAutoScaling.ListScalingPolicies() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ApplicationAutoScaling.ListScalingPolicies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplicationAutoScaling_DeregisterScalableTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   *applicationautoscaling.DeregisterScalableTargetInput
+		err     error
+		wantErr bool
+	}{
+		{
+			name:    "nil input",
+			wantErr: true,
+		},
+		{
+			name: "existing target",
+			input: &applicationautoscaling.DeregisterScalableTargetInput{
+				ResourceId:        aws.String("service/clu1/svc1"),
+				ScalableDimension: aws.String("ecs:service:DesiredCount"),
+				ServiceNamespace:  aws.String("ecs"),
+			},
+		},
+		{
+			name: "missing target",
+			input: &applicationautoscaling.DeregisterScalableTargetInput{
+				ResourceId:        aws.String("service/clu1/svc2"),
+				ScalableDimension: aws.String("ecs:service:DesiredCount"),
+				ServiceNamespace:  aws.String("ecs"),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &ApplicationAutoScaling{Service: newmockAASClient(t, tt.err)}
+			if err := a.DeregisterScalableTarget(context.TODO(), tt.input); (err != nil) != tt.wantErr {
+				t.Errorf("ApplicationAutoScaling.DeregisterScalableTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplicationAutoScaling_DeleteScalingPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   *applicationautoscaling.DeleteScalingPolicyInput
+		err     error
+		wantErr bool
+	}{
+		{
+			name:    "nil input",
+			wantErr: true,
+		},
+		{
+			name: "existing policy",
+			input: &applicationautoscaling.DeleteScalingPolicyInput{
+				PolicyName:        aws.String("svc1-cpu"),
+				ResourceId:        aws.String("service/clu1/svc1"),
+				ScalableDimension: aws.String("ecs:service:DesiredCount"),
+				ServiceNamespace:  aws.String("ecs"),
+			},
+		},
+		{
+			name: "missing policy",
+			input: &applicationautoscaling.DeleteScalingPolicyInput{
+				PolicyName:        aws.String("svc1-disk"),
+				ResourceId:        aws.String("service/clu1/svc1"),
+				ScalableDimension: aws.String("ecs:service:DesiredCount"),
+				ServiceNamespace:  aws.String("ecs"),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &ApplicationAutoScaling{Service: newmockAASClient(t, tt.err)}
+			if err := a.DeleteScalingPolicy(context.TODO(), tt.input); (err != nil) != tt.wantErr {
+				t.Errorf("ApplicationAutoScaling.DeleteScalingPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package applicationautoscaling
+
+import (
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/pkg/errors"
+)
+
+func ErrCode(msg string, err error) error {
+	if aerr, ok := errors.Cause(err).(awserr.Error); ok {
+		switch aerr.Code() {
+		case
+
+			// ErrCodeConcurrentUpdateException for service response error code
+			// "ConcurrentUpdateException".
+			//
+			// Concurrent updates caused an exception, for example, if you request an update
+			// to an Application Auto Scaling resource that already has a pending update.
+			applicationautoscaling.ErrCodeConcurrentUpdateException:
+
+			return apierror.New(apierror.ErrConflict, msg, aerr)
+		case
+
+			// ErrCodeFailedResourceAccessException for service response error code
+			// "FailedResourceAccessException".
+			//
+			// Failed access to resources caused an exception.
+			applicationautoscaling.ErrCodeFailedResourceAccessException:
+
+			return apierror.New(apierror.ErrForbidden, msg, aerr)
+		case
+
+			// ErrCodeInternalServiceException for service response error code
+			// "InternalServiceException".
+			//
+			// The service encountered an internal error.
+			applicationautoscaling.ErrCodeInternalServiceException:
+
+			return apierror.New(apierror.ErrInternalError, msg, aerr)
+		case
+
+			// ErrCodeInvalidNextTokenException for service response error code
+			// "InvalidNextTokenException".
+			//
+			// The next token supplied was invalid.
+			applicationautoscaling.ErrCodeInvalidNextTokenException,
+
+			// ErrCodeValidationException for service response error code
+			// "ValidationException".
+			//
+			// An exception was thrown for a validation issue. Review the available parameters
+			// for the API request.
+			applicationautoscaling.ErrCodeValidationException:
+
+			return apierror.New(apierror.ErrBadRequest, msg, aerr)
+		case
+
+			// ErrCodeLimitExceededException for service response error code
+			// "LimitExceededException".
+			//
+			// A per-account resource limit is exceeded.
+			applicationautoscaling.ErrCodeLimitExceededException:
+
+			return apierror.New(apierror.ErrLimitExceeded, msg, aerr)
+		case
+
+			// ErrCodeObjectNotFoundException for service response error code
+			// "ObjectNotFoundException".
+			//
+			// The specified object could not be found.
+			applicationautoscaling.ErrCodeObjectNotFoundException:
+
+			return apierror.New(apierror.ErrNotFound, msg, aerr)
+		default:
+			m := msg + ": " + aerr.Message()
+			return apierror.New(apierror.ErrBadRequest, m, aerr)
+		}
+	}
+
+	return apierror.New(apierror.ErrInternalError, msg, err)
+}
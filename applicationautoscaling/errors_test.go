@@ -0,0 +1,42 @@
+package applicationautoscaling
+
+import (
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/pkg/errors"
+)
+
+func TestErrCode(t *testing.T) {
+	apiErrorTestCases := map[string]string{
+		"": apierror.ErrBadRequest,
+
+		applicationautoscaling.ErrCodeConcurrentUpdateException:     apierror.ErrConflict,
+		applicationautoscaling.ErrCodeFailedResourceAccessException: apierror.ErrForbidden,
+		applicationautoscaling.ErrCodeInternalServiceException:      apierror.ErrInternalError,
+		applicationautoscaling.ErrCodeInvalidNextTokenException:     apierror.ErrBadRequest,
+		applicationautoscaling.ErrCodeValidationException:           apierror.ErrBadRequest,
+		applicationautoscaling.ErrCodeLimitExceededException:        apierror.ErrLimitExceeded,
+		applicationautoscaling.ErrCodeObjectNotFoundException:       apierror.ErrNotFound,
+	}
+
+	for awsErr, apiErr := range apiErrorTestCases {
+		err := ErrCode("test error", awserr.New(awsErr, awsErr, nil))
+		if aerr, ok := errors.Cause(err).(apierror.Error); ok {
+			if aerr.Code != apiErr {
+				t.Errorf("expected application autoscaling error %s to be an apierror %s, got %s", awsErr, apiErr, aerr.Code)
+			}
+		} else {
+			t.Errorf("expected application autoscaling error %s to be an apierror.Error %s, got %s", awsErr, apiErr, err)
+		}
+	}
+
+	err := ErrCode("test error", errors.New("Unknown"))
+	if aerr, ok := errors.Cause(err).(apierror.Error); ok {
+		t.Logf("got apierror '%s'", aerr)
+	} else {
+		t.Errorf("expected unknown error to be an apierror.ErrInternalError, got %s", err)
+	}
+}
@@ -0,0 +1,70 @@
+package cloudwatch
+
+import (
+	"context"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/YaleSpinup/ecs-api/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	log "github.com/sirupsen/logrus"
+)
+
+// DescribeAlarmsBatchSize is the maximum number of alarm names that can be described in a single call
+const DescribeAlarmsBatchSize = 100
+
+// CloudWatch is a wrapper around the aws cloudwatch service
+type CloudWatch struct {
+	Service cloudwatchiface.CloudWatchAPI
+}
+
+// NewSession creates a new cloudwatch session
+func NewSession(account common.Account) CloudWatch {
+	c := CloudWatch{}
+	log.Infof("creating new session with key id %s in region %s", account.Akid, account.Region)
+	sess := session.Must(session.NewSession(account.AWSConfig()))
+	c.Service = cloudwatch.New(sess)
+	return c
+}
+
+// ListAlarmNames returns the names of the given metric and composite alarms that exist
+func (c *CloudWatch) ListAlarmNames(ctx context.Context, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing cloudwatch alarms %v", names)
+
+	alarms := []string{}
+	for i := 0; i < len(names); i += DescribeAlarmsBatchSize {
+		end := i + DescribeAlarmsBatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+
+		if err := c.Service.DescribeAlarmsPagesWithContext(ctx,
+			&cloudwatch.DescribeAlarmsInput{
+				AlarmNames: aws.StringSlice(names[i:end]),
+				AlarmTypes: aws.StringSlice(cloudwatch.AlarmType_Values()),
+			},
+			func(out *cloudwatch.DescribeAlarmsOutput, lastPage bool) bool {
+				for _, a := range out.MetricAlarms {
+					alarms = append(alarms, aws.StringValue(a.AlarmName))
+				}
+
+				for _, a := range out.CompositeAlarms {
+					alarms = append(alarms, aws.StringValue(a.AlarmName))
+				}
+
+				return true
+			}); err != nil {
+			return nil, ErrCode("failed to describe alarms", err)
+		}
+	}
+
+	log.Debugf("got list of cloudwatch alarms: %v", alarms)
+
+	return alarms, nil
+}
@@ -0,0 +1,150 @@
+package cloudwatch
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/YaleSpinup/ecs-api/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+var testMetricAlarms = []*cloudwatch.MetricAlarm{
+	{AlarmName: aws.String("svc1-5xx")},
+	{AlarmName: aws.String("svc1-latency")},
+}
+
+var testCompositeAlarms = []*cloudwatch.CompositeAlarm{
+	{AlarmName: aws.String("svc1-health")},
+}
+
+type mockCWClient struct {
+	cloudwatchiface.CloudWatchAPI
+	t   *testing.T
+	err error
+}
+
+func newmockCWClient(t *testing.T, err error) cloudwatchiface.CloudWatchAPI {
+	return &mockCWClient{
+		t:   t,
+		err: err,
+	}
+}
+
+func (m *mockCWClient) DescribeAlarmsPagesWithContext(ctx aws.Context, input *cloudwatch.DescribeAlarmsInput, fn func(*cloudwatch.DescribeAlarmsOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	if len(input.AlarmNames) > DescribeAlarmsBatchSize {
+		return awserr.New(cloudwatch.ErrCodeInvalidParameterValueException, "too many alarm names", nil)
+	}
+
+	names := map[string]struct{}{}
+	for _, n := range input.AlarmNames {
+		names[aws.StringValue(n)] = struct{}{}
+	}
+
+	// return the metric and composite alarms as separate pages
+	metric := &cloudwatch.DescribeAlarmsOutput{}
+	for _, a := range testMetricAlarms {
+		if _, ok := names[aws.StringValue(a.AlarmName)]; ok {
+			metric.MetricAlarms = append(metric.MetricAlarms, a)
+		}
+	}
+
+	if !fn(metric, false) {
+		return nil
+	}
+
+	composite := &cloudwatch.DescribeAlarmsOutput{}
+	for _, a := range testCompositeAlarms {
+		if _, ok := names[aws.StringValue(a.AlarmName)]; ok {
+			composite.CompositeAlarms = append(composite.CompositeAlarms, a)
+		}
+	}
+
+	fn(composite, true)
+	return nil
+}
+
+func TestNewSession(t *testing.T) {
+	e := NewSession(common.Account{})
+	to := reflect.TypeOf(e).String()
+	if to != "cloudwatch.CloudWatch" {
+		t.Errorf("expected type to be 'cloudwatch.CloudWatch', got %s", to)
+	}
+}
+
+func TestCloudWatch_ListAlarmNames(t *testing.T) {
+	many := []string{}
+	for i := 0; i < 150; i++ {
+		many = append(many, "missing")
+	}
+	many = append(many, "svc1-health")
+
+	tests := []struct {
+		name     string
+		names    []string
+		err      error
+		want     []string
+		wantCode string
+	}{
+		{
+			name:  "metric and composite alarms",
+			names: []string{"svc1-5xx", "svc1-health", "missing"},
+			want:  []string{"svc1-5xx", "svc1-health"},
+		},
+		{
+			name:  "no alarms exist",
+			names: []string{"missing"},
+			want:  []string{},
+		},
+		{
+			name:  "more names than a single batch",
+			names: many,
+			want:  []string{"svc1-health"},
+		},
+		{
+			name:     "empty names",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "cloudwatch error",
+			names:    []string{"svc1-5xx"},
+			err:      awserr.New(cloudwatch.ErrCodeInternalServiceFault, "boom", nil),
+			wantCode: apierror.ErrInternalError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := CloudWatch{Service: newmockCWClient(t, tt.err)}
+
+			got, err := c.ListAlarmNames(context.TODO(), tt.names)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CloudWatch.ListAlarmNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package cloudwatch
+
+import (
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/pkg/errors"
+)
+
+func TestErrCode(t *testing.T) {
+	apiErrorTestCases := map[string]string{
+		"": apierror.ErrBadRequest,
+
+		cloudwatch.ErrCodeConcurrentModificationException:      apierror.ErrConflict,
+		cloudwatch.ErrCodeInternalServiceFault:                 apierror.ErrInternalError,
+		cloudwatch.ErrCodeDashboardInvalidInputError:           apierror.ErrBadRequest,
+		cloudwatch.ErrCodeInvalidFormatFault:                   apierror.ErrBadRequest,
+		cloudwatch.ErrCodeInvalidNextToken:                     apierror.ErrBadRequest,
+		cloudwatch.ErrCodeInvalidParameterCombinationException: apierror.ErrBadRequest,
+		cloudwatch.ErrCodeInvalidParameterValueException:       apierror.ErrBadRequest,
+		cloudwatch.ErrCodeMissingRequiredParameterException:    apierror.ErrBadRequest,
+		cloudwatch.ErrCodeLimitExceededException:               apierror.ErrLimitExceeded,
+		cloudwatch.ErrCodeLimitExceededFault:                   apierror.ErrLimitExceeded,
+		cloudwatch.ErrCodeResourceNotFound:                     apierror.ErrNotFound,
+		cloudwatch.ErrCodeResourceNotFoundException:            apierror.ErrNotFound,
+	}
+
+	for awsErr, apiErr := range apiErrorTestCases {
+		err := ErrCode("test error", awserr.New(awsErr, awsErr, nil))
+		if aerr, ok := errors.Cause(err).(apierror.Error); ok {
+			if aerr.Code != apiErr {
+				t.Errorf("expected cloudwatch error %s to be an apierror %s, got %s", awsErr, apiErr, aerr.Code)
+			}
+		} else {
+			t.Errorf("expected cloudwatch error %s to be an apierror.Error %s, got %s", awsErr, apiErr, err)
+		}
+	}
+
+	err := ErrCode("test error", errors.New("Unknown"))
+	if aerr, ok := errors.Cause(err).(apierror.Error); ok {
+		t.Logf("got apierror '%s'", aerr)
+	} else {
+		t.Errorf("expected unknown error to be an apierror.ErrInternalError, got %s", err)
+	}
+}
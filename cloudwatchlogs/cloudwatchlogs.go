@@ -7,7 +7,6 @@ import (
 	"github.com/YaleSpinup/apierror"
 	"github.com/YaleSpinup/ecs-api/common"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
@@ -18,17 +17,17 @@ import (
 // and configuration information
 type CloudWatchLogs struct {
 	Service cloudwatchlogsiface.CloudWatchLogsAPI
+	// Region is the region of the session, used as the default awslogs-region of container log configurations
+	Region string
 }
 
 // NewSession builds a new aws cloudwatchlogs session
 func NewSession(account common.Account) CloudWatchLogs {
 	c := CloudWatchLogs{}
 	log.Infof("Creating new session with key id %s in region %s", account.Akid, account.Region)
-	sess := session.Must(session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(account.Akid, account.Secret, ""),
-		Region:      aws.String(account.Region),
-	}))
+	sess := session.Must(session.NewSession(account.AWSConfig()))
 	c.Service = cloudwatchlogs.New(sess)
+	c.Region = account.Region
 	return c
 }
 
@@ -103,3 +102,64 @@ func (c *CloudWatchLogs) GetLogGroup(ctx context.Context, prefix string) (*cloud
 
 	return out.LogGroups[0], nil
 }
+
+// LogGroupExists determines if a log group with exactly the given name exists
+func (c *CloudWatchLogs) LogGroupExists(ctx context.Context, name string) (bool, error) {
+	if name == "" {
+		return false, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("checking if log group %s exists", name)
+
+	lg, err := c.findLogGroup(ctx, name)
+	if err != nil {
+		return false, err
+	}
+
+	return lg != nil, nil
+}
+
+// DescribeLogGroup gets the details (ie. retention and stored bytes) of the log group with exactly the given name
+func (c *CloudWatchLogs) DescribeLogGroup(ctx context.Context, name string) (*cloudwatchlogs.LogGroup, error) {
+	if name == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("describing log group %s", name)
+
+	lg, err := c.findLogGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if lg == nil {
+		msg := fmt.Sprintf("log group %s not found", name)
+		return nil, apierror.New(apierror.ErrNotFound, msg, nil)
+	}
+
+	log.Debugf("got log group %+v", lg)
+
+	return lg, nil
+}
+
+// findLogGroup pages through the log groups with the name as a prefix and returns the exact match, or nil
+func (c *CloudWatchLogs) findLogGroup(ctx context.Context, name string) (*cloudwatchlogs.LogGroup, error) {
+	var logGroup *cloudwatchlogs.LogGroup
+	if err := c.Service.DescribeLogGroupsPagesWithContext(ctx,
+		&cloudwatchlogs.DescribeLogGroupsInput{
+			LogGroupNamePrefix: aws.String(name),
+		},
+		func(out *cloudwatchlogs.DescribeLogGroupsOutput, lastPage bool) bool {
+			for _, lg := range out.LogGroups {
+				if aws.StringValue(lg.LogGroupName) == name {
+					logGroup = lg
+					return false
+				}
+			}
+			return true
+		}); err != nil {
+		return nil, ErrCode("failed describing log groups", err)
+	}
+
+	return logGroup, nil
+}
@@ -3,6 +3,7 @@ package cloudwatchlogs
 import (
 	"context"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/YaleSpinup/apierror"
@@ -53,6 +54,43 @@ func (m *mockCWLClient) PutRetentionPolicyWithContext(ctx context.Context, input
 	return &cloudwatchlogs.PutRetentionPolicyOutput{}, nil
 }
 
+func (m *mockCWLClient) DescribeLogGroupsPagesWithContext(ctx context.Context, input *cloudwatchlogs.DescribeLogGroupsInput, fn func(*cloudwatchlogs.DescribeLogGroupsOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	pages := [][]*cloudwatchlogs.LogGroup{
+		{
+			{LogGroupName: aws.String("clu1-svc1")},
+			{LogGroupName: aws.String("clu1-svc10")},
+		},
+		{
+			{
+				Arn:             aws.String("arn:aws:logs:us-east-1:1234567890:log-group:clu1-svc2:*"),
+				CreationTime:    aws.Int64(1600000000000),
+				LogGroupName:    aws.String("clu1-svc2"),
+				RetentionInDays: aws.Int64(365),
+				StoredBytes:     aws.Int64(2048),
+			},
+		},
+	}
+
+	for i, p := range pages {
+		logGroups := []*cloudwatchlogs.LogGroup{}
+		for _, lg := range p {
+			if strings.HasPrefix(aws.StringValue(lg.LogGroupName), aws.StringValue(input.LogGroupNamePrefix)) {
+				logGroups = append(logGroups, lg)
+			}
+		}
+
+		if !fn(&cloudwatchlogs.DescribeLogGroupsOutput{LogGroups: logGroups}, i == len(pages)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
 func TestNewSession(t *testing.T) {
 	cw := NewSession(common.Account{})
 	to := reflect.TypeOf(cw).String()
@@ -144,3 +182,125 @@ func TestUpdateRetention(t *testing.T) {
 		}
 	}
 }
+
+func TestLogGroupExists(t *testing.T) {
+	tests := []struct {
+		name     string
+		logGroup string
+		err      error
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name:     "exists on first page",
+			logGroup: "clu1-svc1",
+			want:     true,
+		},
+		{
+			name:     "exists on last page",
+			logGroup: "clu1-svc2",
+			want:     true,
+		},
+		{
+			name:     "only a prefix match",
+			logGroup: "clu1-svc",
+			want:     false,
+		},
+		{
+			name:     "missing",
+			logGroup: "clu2-svc1",
+			want:     false,
+		},
+		{
+			name:    "empty name",
+			wantErr: true,
+		},
+		{
+			name:     "aws error",
+			logGroup: "clu1-svc1",
+			err:      awserr.New(cloudwatchlogs.ErrCodeServiceUnavailableException, "boom", nil),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := CloudWatchLogs{Service: newmockCWLClient(t, tt.err)}
+			got, err := c.LogGroupExists(context.TODO(), tt.logGroup)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CloudWatchLogs.LogGroupExists() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("CloudWatchLogs.LogGroupExists() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeLogGroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		logGroup string
+		err      error
+		want     *cloudwatchlogs.LogGroup
+		wantCode string
+	}{
+		{
+			name:     "log group metadata",
+			logGroup: "clu1-svc2",
+			want: &cloudwatchlogs.LogGroup{
+				Arn:             aws.String("arn:aws:logs:us-east-1:1234567890:log-group:clu1-svc2:*"),
+				CreationTime:    aws.Int64(1600000000000),
+				LogGroupName:    aws.String("clu1-svc2"),
+				RetentionInDays: aws.Int64(365),
+				StoredBytes:     aws.Int64(2048),
+			},
+		},
+		{
+			name:     "only a prefix match",
+			logGroup: "clu1-svc",
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "missing",
+			logGroup: "clu2-svc1",
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "empty name",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "aws error",
+			logGroup: "clu1-svc2",
+			err:      awserr.New(cloudwatchlogs.ErrCodeServiceUnavailableException, "boom", nil),
+			wantCode: apierror.ErrInternalError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := CloudWatchLogs{Service: newmockCWLClient(t, tt.err)}
+			got, err := c.DescribeLogGroup(context.TODO(), tt.logGroup)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CloudWatchLogs.DescribeLogGroup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
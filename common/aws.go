@@ -0,0 +1,48 @@
+package common
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// AWSConfig returns the configuration for the AWS clients of an account with its credentials, region
+// and the configured retries and timeouts.  Unset retry and timeout values are left to the sdk defaults.
+func (a Account) AWSConfig() *aws.Config {
+	config := &aws.Config{
+		Credentials: credentials.NewStaticCredentials(a.Akid, a.Secret, ""),
+		Region:      aws.String(a.Region),
+	}
+
+	if a.AWS.HTTPTimeout > 0 {
+		config.HTTPClient = &http.Client{
+			Timeout: time.Duration(a.AWS.HTTPTimeout) * time.Second,
+		}
+	}
+
+	if a.AWS.MaxRetries == nil && a.AWS.MinRetryDelay == 0 && a.AWS.MaxRetryDelay == 0 &&
+		a.AWS.MinThrottleDelay == 0 && a.AWS.MaxThrottleDelay == 0 {
+		return config
+	}
+
+	// zero delays are set to the sdk defaults by the retryer
+	retryer := client.DefaultRetryer{
+		NumMaxRetries:    client.DefaultRetryerMaxNumRetries,
+		MinRetryDelay:    time.Duration(a.AWS.MinRetryDelay) * time.Millisecond,
+		MaxRetryDelay:    time.Duration(a.AWS.MaxRetryDelay) * time.Millisecond,
+		MinThrottleDelay: time.Duration(a.AWS.MinThrottleDelay) * time.Millisecond,
+		MaxThrottleDelay: time.Duration(a.AWS.MaxThrottleDelay) * time.Millisecond,
+	}
+
+	if a.AWS.MaxRetries != nil {
+		retryer.NumMaxRetries = *a.AWS.MaxRetries
+	}
+
+	config.MaxRetries = aws.Int(retryer.NumMaxRetries)
+	config.Retryer = retryer
+
+	return config
+}
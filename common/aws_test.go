@@ -0,0 +1,86 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+)
+
+func TestAccount_AWSConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		aws         AWS
+		wantRetries *int
+		wantRetryer *client.DefaultRetryer
+		wantTimeout time.Duration
+	}{
+		{
+			name: "sdk defaults",
+		},
+		{
+			name:        "max retries",
+			aws:         AWS{MaxRetries: aws.Int(5)},
+			wantRetries: aws.Int(5),
+			wantRetryer: &client.DefaultRetryer{NumMaxRetries: 5},
+		},
+		{
+			name:        "no retries",
+			aws:         AWS{MaxRetries: aws.Int(0)},
+			wantRetries: aws.Int(0),
+			wantRetryer: &client.DefaultRetryer{},
+		},
+		{
+			name:        "backoff with default retries",
+			aws:         AWS{MinRetryDelay: 100, MaxRetryDelay: 5000, MinThrottleDelay: 1000, MaxThrottleDelay: 60000},
+			wantRetries: aws.Int(client.DefaultRetryerMaxNumRetries),
+			wantRetryer: &client.DefaultRetryer{
+				NumMaxRetries:    client.DefaultRetryerMaxNumRetries,
+				MinRetryDelay:    100 * time.Millisecond,
+				MaxRetryDelay:    5 * time.Second,
+				MinThrottleDelay: time.Second,
+				MaxThrottleDelay: time.Minute,
+			},
+		},
+		{
+			name:        "http timeout",
+			aws:         AWS{HTTPTimeout: 30},
+			wantTimeout: 30 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Account{Akid: "key1", Secret: "secret1", Region: "us-east-1", AWS: tt.aws}
+
+			config := a.AWSConfig()
+			if aws.StringValue(config.Region) != "us-east-1" {
+				t.Errorf("expected region us-east-1, got %s", aws.StringValue(config.Region))
+			}
+
+			if creds, err := config.Credentials.Get(); err != nil || creds.AccessKeyID != "key1" {
+				t.Errorf("expected static credentials for key1, got %+v (%v)", creds, err)
+			}
+
+			if (config.MaxRetries == nil) != (tt.wantRetries == nil) || aws.IntValue(config.MaxRetries) != aws.IntValue(tt.wantRetries) {
+				t.Errorf("expected max retries %v, got %v", aws.IntValue(tt.wantRetries), aws.IntValue(config.MaxRetries))
+			}
+
+			if tt.wantRetryer == nil {
+				if config.Retryer != nil {
+					t.Errorf("expected the default retryer, got %+v", config.Retryer)
+				}
+			} else if retryer, ok := config.Retryer.(client.DefaultRetryer); !ok || retryer != *tt.wantRetryer {
+				t.Errorf("expected retryer %+v, got %+v", *tt.wantRetryer, config.Retryer)
+			}
+
+			if tt.wantTimeout == 0 {
+				if config.HTTPClient != nil {
+					t.Errorf("expected the default http client, got %+v", config.HTTPClient)
+				}
+			} else if config.HTTPClient == nil || config.HTTPClient.Timeout != tt.wantTimeout {
+				t.Errorf("expected http client timeout %s, got %+v", tt.wantTimeout, config.HTTPClient)
+			}
+		})
+	}
+}
@@ -15,7 +15,82 @@ type Config struct {
 	Token         string
 	LogLevel      string
 	Org           string
-	Version       Version
+	// Orgs are additional orgs (beyond Org) that resources managed by this instance may belong to
+	Orgs []string
+	// DefaultTags are added to the tags of the services, task definitions, secrets and parameters created by this
+	// instance, tags passed with a request take precedence
+	DefaultTags map[string]string
+	// ServicePropagateTags is where the tasks started by services created by this instance get their tags from
+	// (SERVICE, TASK_DEFINITION or NONE) unless the service is created with PropagateTags, defaults to SERVICE
+	ServicePropagateTags string
+	// RecursiveDelete configures the cleanup of dependencies when resources are deleted recursively
+	RecursiveDelete RecursiveDelete
+	// StrictImageReferences rejects container images that don't specify a tag or digest
+	StrictImageReferences bool
+	// AllowedRegistries limits the registries container images are pulled from (ie. docker.io or *.dkr.ecr.us-east-1.amazonaws.com)
+	AllowedRegistries []string
+	// DeniedRegistries rejects container images pulled from the registries
+	DeniedRegistries []string
+	// StrictRepositoryCredentials rejects repository credentials that aren't JSON with a username and password
+	StrictRepositoryCredentials bool
+	// UniqueRepositoryCredentialsNames suffixes repository credentials secret names that already exist instead of failing
+	UniqueRepositoryCredentialsNames bool
+	// DisableTaskExecutionRoleCreation requires the {cluster}-ecsTaskExecution roles to be created ahead of time
+	DisableTaskExecutionRoleCreation bool
+	// PrefixTaskDefinitionFamilies namespaces the families of managed task definitions as {org}-{space}-{family}
+	PrefixTaskDefinitionFamilies bool
+	// Audit configures the audit events for mutating api calls
+	Audit Audit
+	// Awslogs configures the delivery options of the default awslogs log configuration
+	Awslogs Awslogs
+	// AWS configures the retries and timeouts of the AWS clients for all accounts
+	AWS     AWS
+	Version Version
+}
+
+// AWS is the configuration for the retries and timeouts of the AWS clients
+type AWS struct {
+	// MaxRetries is the number of times a failed or throttled request is retried, defaults to the sdk default (3)
+	MaxRetries *int
+	// HTTPTimeout is the number of seconds before a request to AWS times out, requests don't time out by default
+	HTTPTimeout int
+	// MinRetryDelay and MaxRetryDelay bound the backoff in milliseconds between retries of failed requests,
+	// defaults to the sdk defaults (30ms and 300s)
+	MinRetryDelay int
+	MaxRetryDelay int
+	// MinThrottleDelay and MaxThrottleDelay bound the backoff in milliseconds between retries of throttled
+	// requests, defaults to the sdk defaults (500ms and 300s)
+	MinThrottleDelay int
+	MaxThrottleDelay int
+}
+
+// Audit is the configuration for the audit events of mutating api calls
+type Audit struct {
+	// Enabled turns on the audit events
+	Enabled bool
+	// File is the path the audit events are appended to as lines of JSON, they are written to stdout if it's not set
+	File string
+}
+
+// Awslogs is the configuration for delivering container logs with the awslogs driver
+type Awslogs struct {
+	// Mode is the awslogs-mode, blocking or non-blocking.  The option is left unset (blocking) by default
+	Mode string
+	// MaxBufferSize is the max-buffer-size used in non-blocking mode, ie. 25m
+	MaxBufferSize string
+	// GroupNamePattern is the name of the default log group, {org}, {cluster} and {family} are replaced with the org,
+	// the cluster name and the task definition family.  Defaults to {cluster}
+	GroupNamePattern string
+}
+
+// RecursiveDelete is the configuration for recursively deleting service and task definition dependencies
+type RecursiveDelete struct {
+	// Timeout is the number of seconds to wait for a cluster or service registry to be deleted, defaults to 120
+	Timeout int
+	// Concurrency is the number of task definition revisions to delete at once, defaults to 1
+	Concurrency int
+	// GracePeriod is the number of seconds to wait before cleaning up dependencies in the background, defaults to 30
+	GracePeriod int
 }
 
 // Account is the configuration for an individual account
@@ -24,8 +99,29 @@ type Account struct {
 	DefaultKmsKeyId string
 	DefaultSgs      []string
 	DefaultSubnets  []string
-	Region          string
-	Secret          string
+	// DefaultSgLabels maps names (ie. a tier) to subsets of the default security groups
+	DefaultSgLabels map[string][]string
+	// DefaultSubnetLabels maps names (ie. an availability zone) to subsets of the default subnets
+	DefaultSubnetLabels map[string][]string
+	Region              string
+	Secret              string
+	// TaskExecutionTrustedServices are additional services (beyond ecs-tasks.amazonaws.com) trusted to assume
+	// the task execution roles created by the api
+	TaskExecutionTrustedServices []string
+	// TaskExecutionTrustedPrincipals are AWS principals (ie. role ARNs) trusted to assume the task execution
+	// roles created by the api
+	TaskExecutionTrustedPrincipals []string
+	// SecretKmsKeyId is the KMS key (id, ARN or alias) used to encrypt the repository credentials secrets, the
+	// aws/secretsmanager key of the account is used if it's not set
+	SecretKmsKeyId string
+	// SecretReplicaRegions are the regions repository credentials secrets are replicated to, ie. for disaster recovery
+	SecretReplicaRegions []string
+	// SecretReplicaKmsKeyIds maps replica regions to the KMS key used to encrypt the replicas, replicas in regions
+	// without a key are encrypted with the aws/secretsmanager key of the region
+	SecretReplicaKmsKeyIds map[string]string
+	// AWS is the retry and timeout configuration of the clients for the account, it's set from the
+	// top level configuration
+	AWS AWS `json:"-"`
 }
 
 // Version carries around the API version information
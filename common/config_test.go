@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"reflect"
 	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
 )
 
 var testConfig = []byte(
@@ -15,7 +17,9 @@ var testConfig = []byte(
 				"akid": "key1",
 				"secret": "secret1",
 				"defaultSgs": ["sg-xxxxxx", "sg-yyyyyy"],
-				"defaultSubnets": ["subnet-xxxxxxx", "subnet-yyyyyy"]
+				"defaultSubnets": ["subnet-xxxxxxx", "subnet-yyyyyy"],
+				"defaultSgLabels": {"web": ["sg-xxxxxx"]},
+				"defaultSubnetLabels": {"us-east-1a": ["subnet-xxxxxxx"], "private": ["subnet-xxxxxxx", "subnet-yyyyyy"]}
 			},
 			"provider2": {
 				"region": "us-west-1",
@@ -25,7 +29,12 @@ var testConfig = []byte(
 		},
 		"token": "SEKRET",
 		"logLevel": "info",
-		"org": "test"
+		"org": "test",
+		"aws": {
+			"maxRetries": 5,
+			"httpTimeout": 30,
+			"minThrottleDelay": 1000
+		}
 	}`)
 
 func TestReadConfig(t *testing.T) {
@@ -38,6 +47,13 @@ func TestReadConfig(t *testing.T) {
 				Secret:         "secret1",
 				DefaultSgs:     []string{"sg-xxxxxx", "sg-yyyyyy"},
 				DefaultSubnets: []string{"subnet-xxxxxxx", "subnet-yyyyyy"},
+				DefaultSgLabels: map[string][]string{
+					"web": {"sg-xxxxxx"},
+				},
+				DefaultSubnetLabels: map[string][]string{
+					"us-east-1a": {"subnet-xxxxxxx"},
+					"private":    {"subnet-xxxxxxx", "subnet-yyyyyy"},
+				},
 			},
 			"provider2": Account{
 				Region: "us-west-1",
@@ -48,6 +64,11 @@ func TestReadConfig(t *testing.T) {
 		Token:    "SEKRET",
 		LogLevel: "info",
 		Org:      "test",
+		AWS: AWS{
+			MaxRetries:       aws.Int(5),
+			HTTPTimeout:      30,
+			MinThrottleDelay: 1000,
+		},
 	}
 
 	actualConfig, err := ReadConfig(bytes.NewReader(testConfig))
@@ -0,0 +1,98 @@
+package ecs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultClusterCacheTTL is the amount of time a described cluster is cached
+var DefaultClusterCacheTTL = 5 * time.Second
+
+// clusterCache is a short lived cache of described clusters keyed by the name (or ARN) used to look them up.
+// It is safe for concurrent use and a nil cache never caches.  Clusters are copied in and out of the cache so
+// callers can't modify the cached clusters.
+type clusterCache struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	clusters map[string]clusterCacheEntry
+}
+
+type clusterCacheEntry struct {
+	cluster *ecs.Cluster
+	expires time.Time
+}
+
+// newClusterCache returns a new cluster cache with the given TTL
+func newClusterCache(ttl time.Duration) *clusterCache {
+	return &clusterCache{
+		ttl:      ttl,
+		clusters: make(map[string]clusterCacheEntry),
+	}
+}
+
+// get returns the cached cluster if it exists and hasn't expired
+func (c *clusterCache) get(name string) (*ecs.Cluster, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.clusters[name]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(c.clusters, name)
+		return nil, false
+	}
+
+	return copyCluster(entry.cluster), true
+}
+
+// set caches the cluster with the given name.  INACTIVE (deleted) clusters aren't cached so a cluster
+// recreated with the same name is found right away.
+func (c *clusterCache) set(name string, cluster *ecs.Cluster) {
+	if c == nil || cluster == nil || aws.StringValue(cluster.Status) == "INACTIVE" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clusters[name] = clusterCacheEntry{
+		cluster: copyCluster(cluster),
+		expires: time.Now().Add(c.ttl),
+	}
+}
+
+// invalidate removes any cached entries for the given cluster name or ARN
+func (c *clusterCache) invalidate(id string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, entry := range c.clusters {
+		if k == id || aws.StringValue(entry.cluster.ClusterName) == id || aws.StringValue(entry.cluster.ClusterArn) == id {
+			log.Debugf("invalidating cached cluster %s", k)
+			delete(c.clusters, k)
+		}
+	}
+}
+
+// copyCluster returns a deep copy of the cluster
+func copyCluster(cluster *ecs.Cluster) *ecs.Cluster {
+	out := &ecs.Cluster{}
+	awsutil.Copy(out, cluster)
+	return out
+}
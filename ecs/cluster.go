@@ -26,17 +26,25 @@ func (e *ECS) CreateCluster(ctx context.Context, cluster *ecs.CreateClusterInput
 		return nil, ErrCode("failed to create cluster "+aws.StringValue(cluster.ClusterName), err)
 	}
 
+	e.InvalidateCluster(aws.StringValue(cluster.ClusterName))
+
 	log.Debugf("created cluster %+v", output)
 
 	return output.Cluster, err
 }
 
-// GetCluster gets the details of a cluster with context by the cluster name
+// GetCluster gets the details of a cluster with context by the cluster name.  Clusters are cached
+// for a short time to reduce repeated calls to describe the same cluster.
 func (e *ECS) GetCluster(ctx context.Context, name *string) (*ecs.Cluster, error) {
 	if name == nil {
 		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
 	}
 
+	if cluster, ok := e.clusterCache.get(aws.StringValue(name)); ok {
+		log.Debugf("returning cached cluster %s", aws.StringValue(name))
+		return cluster, nil
+	}
+
 	log.Infof("getting cluster %s", aws.StringValue(name))
 
 	output, err := e.Service.DescribeClustersWithContext(ctx, &ecs.DescribeClustersInput{
@@ -55,14 +63,130 @@ func (e *ECS) GetCluster(ctx context.Context, name *string) (*ecs.Cluster, error
 
 	if len(output.Clusters) == 0 {
 		msg := fmt.Sprintf("cluster %s not found", aws.StringValue(name))
-		return nil, errors.New(msg)
+		return nil, apierror.New(apierror.ErrNotFound, msg, nil)
 	} else if len(output.Clusters) > 1 {
 		return nil, errors.New("unexpected number of clusters returned")
 	}
 
+	e.clusterCache.set(aws.StringValue(name), output.Clusters[0])
+
 	return output.Clusters[0], err
 }
 
+// GetClusterSettings describes the settings (ie. containerInsights) of a cluster by the cluster name.  Settings are
+// only returned when explicitly included, so the cluster cache is bypassed.
+func (e *ECS) GetClusterSettings(ctx context.Context, name *string) ([]*ecs.ClusterSetting, error) {
+	if name == nil {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("getting settings for cluster %s", aws.StringValue(name))
+
+	output, err := e.Service.DescribeClustersWithContext(ctx, &ecs.DescribeClustersInput{
+		Clusters: []*string{name},
+		Include:  aws.StringSlice([]string{ecs.ClusterFieldSettings}),
+	})
+	if err != nil {
+		return nil, ErrCode("failed to describe cluster "+aws.StringValue(name), err)
+	}
+
+	if len(output.Clusters) == 0 || aws.StringValue(output.Clusters[0].Status) == "INACTIVE" {
+		msg := fmt.Sprintf("cluster %s not found", aws.StringValue(name))
+		return nil, apierror.New(apierror.ErrNotFound, msg, nil)
+	}
+
+	return output.Clusters[0].Settings, nil
+}
+
+// UpdateClusterSettings updates the settings of a cluster.  Setting names must be one of the supported
+// cluster setting names and the values must be either 'enabled' or 'disabled'.
+func (e *ECS) UpdateClusterSettings(ctx context.Context, name *string, settings []*ecs.ClusterSetting) ([]*ecs.ClusterSetting, error) {
+	if name == nil || len(settings) == 0 {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	for _, s := range settings {
+		if err := validateClusterSetting(s); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Infof("updating settings for cluster %s", aws.StringValue(name))
+
+	output, err := e.Service.UpdateClusterSettingsWithContext(ctx, &ecs.UpdateClusterSettingsInput{
+		Cluster:  name,
+		Settings: settings,
+	})
+	if err != nil {
+		return nil, ErrCode("failed to update settings for cluster "+aws.StringValue(name), err)
+	}
+
+	e.InvalidateCluster(aws.StringValue(name))
+
+	log.Debugf("update cluster settings output %+v", output)
+
+	if output.Cluster == nil {
+		return nil, nil
+	}
+
+	return output.Cluster.Settings, nil
+}
+
+// validateClusterSetting makes sure the setting name and value are supported
+func validateClusterSetting(setting *ecs.ClusterSetting) error {
+	if setting == nil {
+		return apierror.New(apierror.ErrBadRequest, "invalid cluster setting", nil)
+	}
+
+	name := aws.StringValue(setting.Name)
+	valid := false
+	for _, n := range ecs.ClusterSettingName_Values() {
+		if name == n {
+			valid = true
+			break
+		}
+	}
+
+	if !valid {
+		msg := fmt.Sprintf("unsupported cluster setting name '%s'", name)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	if v := aws.StringValue(setting.Value); v != "enabled" && v != "disabled" {
+		msg := fmt.Sprintf("unsupported value '%s' for cluster setting %s, expected enabled or disabled", v, name)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	return nil
+}
+
+// PutClusterCapacityProviders sets the capacity providers and the default capacity provider strategy of a cluster
+func (e *ECS) PutClusterCapacityProviders(ctx context.Context, input *ecs.PutClusterCapacityProvidersInput) (*ecs.Cluster, error) {
+	if input == nil || aws.StringValue(input.Cluster) == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("putting capacity providers %s for cluster %s", aws.StringValueSlice(input.CapacityProviders), aws.StringValue(input.Cluster))
+
+	output, err := e.Service.PutClusterCapacityProvidersWithContext(ctx, input)
+
+	// the capacity providers may have changed even if the call failed
+	e.InvalidateCluster(aws.StringValue(input.Cluster))
+
+	if err != nil {
+		return nil, ErrCode("failed to put capacity providers for cluster "+aws.StringValue(input.Cluster), err)
+	}
+
+	log.Debugf("put cluster capacity providers output %+v", output)
+
+	return output.Cluster, nil
+}
+
+// InvalidateCluster removes the cluster with the given name or ARN from the cache
+func (e *ECS) InvalidateCluster(name string) {
+	e.clusterCache.invalidate(name)
+}
+
 // DeleteCluster deletes a(n empty) cluster
 func (e *ECS) DeleteCluster(ctx context.Context, name *string) error {
 	if name == nil {
@@ -75,6 +199,9 @@ func (e *ECS) DeleteCluster(ctx context.Context, name *string) error {
 	if err != nil {
 		return err
 	}
+
+	e.InvalidateCluster(aws.StringValue(name))
+
 	return nil
 }
 
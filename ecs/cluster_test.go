@@ -7,8 +7,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ecs"
 )
@@ -58,6 +60,19 @@ func (m *mockECSClient) CreateClusterWithContext(ctx aws.Context, input *ecs.Cre
 	return nil, errors.New("Failed to create mock cluster")
 }
 
+func (m *mockECSClient) PutClusterCapacityProvidersWithContext(ctx aws.Context, input *ecs.PutClusterCapacityProvidersInput, opts ...request.Option) (*ecs.PutClusterCapacityProvidersOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	cluster := &ecs.Cluster{}
+	awsutil.Copy(cluster, goodClu)
+	cluster.CapacityProviders = input.CapacityProviders
+	cluster.DefaultCapacityProviderStrategy = input.DefaultCapacityProviderStrategy
+
+	return &ecs.PutClusterCapacityProvidersOutput{Cluster: cluster}, nil
+}
+
 func (m *mockECSClient) DeleteClusterWithContext(ctx aws.Context, input *ecs.DeleteClusterInput, opts ...request.Option) (*ecs.DeleteClusterOutput, error) {
 	if m.err != nil {
 		return nil, m.err
@@ -247,8 +262,8 @@ func TestGetCluster(t *testing.T) {
 	t.Log("got cluster response for missing cluster", cluster)
 	if err == nil {
 		t.Fatal("expected error from get missing cluster, got nil")
-	} else if err.Error() != "cluster missingclu not found" {
-		t.Fatalf("expected error 'cluster missingclu not found' from get cluster, got '%s'", err)
+	} else if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound || aerr.Message != "cluster missingclu not found" {
+		t.Fatalf("expected not found error 'cluster missingclu not found' from get cluster, got '%s'", err)
 	}
 
 	_, err = client.GetCluster(context.TODO(), aws.String("multiclu"))
@@ -269,3 +284,173 @@ func TestGetCluster(t *testing.T) {
 		t.Fatal("expected error from get cluster, got nil")
 	}
 }
+
+// countingECSClient counts the calls to DescribeClusters
+type countingECSClient struct {
+	*mockECSClient
+	describeClustersCalls int
+}
+
+func (m *countingECSClient) DescribeClustersWithContext(ctx aws.Context, input *ecs.DescribeClustersInput, opts ...request.Option) (*ecs.DescribeClustersOutput, error) {
+	m.describeClustersCalls++
+	return m.mockECSClient.DescribeClustersWithContext(ctx, input, opts...)
+}
+
+func TestGetClusterCache(t *testing.T) {
+	mock := &countingECSClient{mockECSClient: &mockECSClient{t: t}}
+	client := ECS{Service: mock, clusterCache: newClusterCache(time.Minute)}
+
+	for i := 0; i < 2; i++ {
+		cluster, err := client.GetCluster(context.TODO(), aws.String("goodclu"))
+		if err != nil {
+			t.Fatal("expected no error from get cluster, got", err)
+		}
+
+		if !reflect.DeepEqual(goodClu, cluster) {
+			t.Fatalf("Expected %+v\nGot %+v", goodClu, cluster)
+		}
+	}
+
+	if mock.describeClustersCalls != 1 {
+		t.Errorf("expected 1 call to describe clusters, got %d", mock.describeClustersCalls)
+	}
+
+	// the cached cluster is a copy, changing the returned cluster doesn't change the cache
+	cluster, err := client.GetCluster(context.TODO(), aws.String("goodclu"))
+	if err != nil {
+		t.Fatal("expected no error from get cluster, got", err)
+	}
+	cluster.Status = aws.String("DRAINING")
+
+	if cluster, err = client.GetCluster(context.TODO(), aws.String("goodclu")); err != nil {
+		t.Fatal("expected no error from get cluster, got", err)
+	} else if !reflect.DeepEqual(goodClu, cluster) {
+		t.Fatalf("Expected %+v\nGot %+v", goodClu, cluster)
+	}
+
+	if mock.describeClustersCalls != 1 {
+		t.Errorf("expected 1 call to describe clusters, got %d", mock.describeClustersCalls)
+	}
+
+	client.InvalidateCluster("arn:aws:ecs:us-east-1:1234567890:cluster/goodclu")
+	if _, err := client.GetCluster(context.TODO(), aws.String("goodclu")); err != nil {
+		t.Fatal("expected no error from get cluster, got", err)
+	}
+
+	if mock.describeClustersCalls != 2 {
+		t.Errorf("expected 2 calls to describe clusters after invalidating, got %d", mock.describeClustersCalls)
+	}
+
+	// expired entries are not returned
+	client.clusterCache = newClusterCache(0)
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetCluster(context.TODO(), aws.String("goodclu")); err != nil {
+			t.Fatal("expected no error from get cluster, got", err)
+		}
+	}
+
+	if mock.describeClustersCalls != 4 {
+		t.Errorf("expected 4 calls to describe clusters with expired cache, got %d", mock.describeClustersCalls)
+	}
+
+	// a nil cache never caches
+	client.clusterCache = nil
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetCluster(context.TODO(), aws.String("goodclu")); err != nil {
+			t.Fatal("expected no error from get cluster, got", err)
+		}
+	}
+
+	if mock.describeClustersCalls != 6 {
+		t.Errorf("expected 6 calls to describe clusters without a cache, got %d", mock.describeClustersCalls)
+	}
+}
+
+func TestClusterCacheInvalidation(t *testing.T) {
+	mock := &countingECSClient{mockECSClient: &mockECSClient{t: t}}
+	client := ECS{Service: mock, clusterCache: newClusterCache(time.Minute)}
+
+	get := func() {
+		if _, err := client.GetCluster(context.TODO(), aws.String("goodclu")); err != nil {
+			t.Fatal("expected no error from get cluster, got", err)
+		}
+	}
+
+	get()
+	if _, err := client.CreateCluster(context.TODO(), &ecs.CreateClusterInput{ClusterName: aws.String("goodclu")}); err != nil {
+		t.Fatal("expected no error from create cluster, got", err)
+	}
+	get()
+
+	if mock.describeClustersCalls != 2 {
+		t.Errorf("expected 2 calls to describe clusters after creating the cluster, got %d", mock.describeClustersCalls)
+	}
+
+	if _, err := client.PutClusterCapacityProviders(context.TODO(), &ecs.PutClusterCapacityProvidersInput{
+		Cluster:                         aws.String("goodclu"),
+		CapacityProviders:               aws.StringSlice([]string{"FARGATE"}),
+		DefaultCapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE")}},
+	}); err != nil {
+		t.Fatal("expected no error from put cluster capacity providers, got", err)
+	}
+	get()
+
+	if mock.describeClustersCalls != 3 {
+		t.Errorf("expected 3 calls to describe clusters after changing the capacity providers, got %d", mock.describeClustersCalls)
+	}
+
+	// inactive clusters are never cached
+	c := newClusterCache(time.Minute)
+	c.set("deletedclu", &ecs.Cluster{ClusterName: aws.String("deletedclu"), Status: aws.String("INACTIVE")})
+	if cluster, ok := c.get("deletedclu"); ok {
+		t.Errorf("expected inactive cluster not to be cached, got %+v", cluster)
+	}
+}
+
+func TestPutClusterCapacityProviders(t *testing.T) {
+	client := ECS{Service: &mockECSClient{t: t}}
+
+	if _, err := client.PutClusterCapacityProviders(context.TODO(), nil); err == nil {
+		t.Error("expected error for nil input, got nil")
+	}
+
+	cluster, err := client.PutClusterCapacityProviders(context.TODO(), &ecs.PutClusterCapacityProvidersInput{
+		Cluster:                         aws.String("goodclu"),
+		CapacityProviders:               aws.StringSlice([]string{"FARGATE"}),
+		DefaultCapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE")}},
+	})
+	if err != nil {
+		t.Fatal("expected no error from put cluster capacity providers, got", err)
+	}
+
+	if got := aws.StringValueSlice(cluster.CapacityProviders); !reflect.DeepEqual(got, []string{"FARGATE"}) {
+		t.Errorf("expected capacity providers [FARGATE], got %v", got)
+	}
+
+	client = ECS{Service: &mockECSClient{t: t, err: awserr.New(ecs.ErrCodeClusterNotFoundException, "not found", nil)}}
+	if _, err := client.PutClusterCapacityProviders(context.TODO(), &ecs.PutClusterCapacityProvidersInput{
+		Cluster:                         aws.String("missingclu"),
+		CapacityProviders:               aws.StringSlice([]string{"FARGATE"}),
+		DefaultCapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE")}},
+	}); err == nil {
+		t.Error("expected error for a missing cluster, got nil")
+	}
+}
+
+func TestClusterCacheConcurrency(t *testing.T) {
+	c := newClusterCache(time.Minute)
+
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			c.set("goodclu", goodClu)
+			c.get("goodclu")
+			c.invalidate("goodclu")
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+}
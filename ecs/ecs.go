@@ -6,7 +6,6 @@ import (
 	"github.com/YaleSpinup/apierror"
 	"github.com/YaleSpinup/ecs-api/common"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
@@ -15,23 +14,26 @@ import (
 
 // ECS is a wrapper around the aws ECS service with some default config info
 type ECS struct {
-	Service        ecsiface.ECSAPI
-	DefaultSgs     []string
-	DefaultSubnets []string
+	Service             ecsiface.ECSAPI
+	DefaultSgs          []string
+	DefaultSubnets      []string
+	DefaultSgLabels     map[string][]string
+	DefaultSubnetLabels map[string][]string
+	clusterCache        *clusterCache
 }
 
 // NewSession creates a new ECS session
 func NewSession(account common.Account) ECS {
 	e := ECS{}
 	log.Infof("creating new session with key id %s in region %s", account.Akid, account.Region)
-	sess := session.Must(session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(account.Akid, account.Secret, ""),
-		Region:      aws.String(account.Region),
-	}))
+	sess := session.Must(session.NewSession(account.AWSConfig()))
 	e.Service = ecs.New(sess)
 
 	e.DefaultSgs = account.DefaultSgs
 	e.DefaultSubnets = account.DefaultSubnets
+	e.DefaultSgLabels = account.DefaultSgLabels
+	e.DefaultSubnetLabels = account.DefaultSubnetLabels
+	e.clusterCache = newClusterCache(DefaultClusterCacheTTL)
 
 	return e
 }
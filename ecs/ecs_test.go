@@ -20,6 +20,8 @@ type mockECSClient struct {
 	ecsiface.ECSAPI
 	t   *testing.T
 	err error
+	// describes counts the calls to describe services
+	describes int
 }
 
 func newmockECSClient(t *testing.T, err error) ecsiface.ECSAPI {
@@ -74,6 +76,26 @@ func TestNewSession(t *testing.T) {
 	}
 }
 
+func TestNewSessionMaxRetries(t *testing.T) {
+	e := NewSession(common.Account{
+		Region: "us-east-1",
+		AWS:    common.AWS{MaxRetries: aws.Int(7), HTTPTimeout: 30},
+	})
+
+	client, ok := e.Service.(*ecs.ECS)
+	if !ok {
+		t.Fatalf("expected service to be *ecs.ECS, got %T", e.Service)
+	}
+
+	if retries := aws.IntValue(client.Config.MaxRetries); retries != 7 {
+		t.Errorf("expected session max retries to be 7, got %d", retries)
+	}
+
+	if retries := client.Retryer.MaxRetries(); retries != 7 {
+		t.Errorf("expected retryer max retries to be 7, got %d", retries)
+	}
+}
+
 func TestListTags(t *testing.T) {
 	client := ECS{Service: &mockECSClient{t: t}}
 	tags, err := client.ListTags(context.TODO(), "myarn")
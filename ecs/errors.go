@@ -135,7 +135,8 @@ func ErrCode(msg string, err error) error {
 			// The specified task is not supported in this Region.
 			ecs.ErrCodeUnsupportedFeatureException:
 
-			return apierror.New(apierror.ErrBadRequest, msg, aerr)
+			// the detail (ie. "The container X does not exist") is usually actionable by the client
+			return apierror.New(apierror.ErrBadRequest, withDetail(msg, aerr), aerr)
 		case
 			// ErrCodeClusterNotFoundException for service response error code
 			// "ClusterNotFoundException".
@@ -191,10 +192,18 @@ func ErrCode(msg string, err error) error {
 
 			return apierror.New(apierror.ErrLimitExceeded, msg, aerr)
 		default:
-			m := msg + ": " + aerr.Message()
-			return apierror.New(apierror.ErrBadRequest, m, aerr)
+			return apierror.New(apierror.ErrBadRequest, withDetail(msg, aerr), aerr)
 		}
 	}
 
 	return apierror.New(apierror.ErrInternalError, msg, err)
 }
+
+// withDetail appends the message of the AWS error to the error message, if there is one
+func withDetail(msg string, aerr awserr.Error) string {
+	if aerr.Message() == "" {
+		return msg
+	}
+
+	return msg + ": " + aerr.Message()
+}
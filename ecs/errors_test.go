@@ -59,3 +59,66 @@ func TestErrCode(t *testing.T) {
 		t.Errorf("expected unknown error to be an apierror.ErrInternalError, got %s", err)
 	}
 }
+
+func TestErrCodeDetail(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    string
+		message string
+	}{
+		{
+			name:    "invalid parameter detail",
+			err:     awserr.New(ecs.ErrCodeInvalidParameterException, "The container sidecar does not exist in the task definition.", nil),
+			code:    apierror.ErrBadRequest,
+			message: "failed to create service: The container sidecar does not exist in the task definition.",
+		},
+		{
+			name:    "client exception detail",
+			err:     awserr.New(ecs.ErrCodeClientException, "Container.image should not be null or empty.", nil),
+			code:    apierror.ErrBadRequest,
+			message: "failed to create service: Container.image should not be null or empty.",
+		},
+		{
+			name:    "wrapped invalid parameter detail",
+			err:     errors.Wrap(awserr.New(ecs.ErrCodeInvalidParameterException, "Invalid revision number. Number: 0", nil), "wrapped"),
+			code:    apierror.ErrBadRequest,
+			message: "failed to create service: Invalid revision number. Number: 0",
+		},
+		{
+			name:    "invalid parameter without detail",
+			err:     awserr.New(ecs.ErrCodeInvalidParameterException, "", nil),
+			code:    apierror.ErrBadRequest,
+			message: "failed to create service",
+		},
+		{
+			name:    "unknown error code detail",
+			err:     awserr.New("ValidationException", "1 validation error detected", nil),
+			code:    apierror.ErrBadRequest,
+			message: "failed to create service: 1 validation error detected",
+		},
+		{
+			name:    "not found",
+			err:     awserr.New(ecs.ErrCodeServiceNotFoundException, "Service not found.", nil),
+			code:    apierror.ErrNotFound,
+			message: "failed to create service",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ErrCode("failed to create service", tt.err)
+			aerr, ok := errors.Cause(err).(apierror.Error)
+			if !ok {
+				t.Fatalf("expected an apierror.Error, got %s", err)
+			}
+
+			if aerr.Code != tt.code {
+				t.Errorf("expected code %s, got %s", tt.code, aerr.Code)
+			}
+
+			if aerr.Message != tt.message {
+				t.Errorf("expected message '%s', got '%s'", tt.message, aerr.Message)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package ecs
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
@@ -9,6 +12,12 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// DescribeServicesBatchSize is the maximum number of services that can be described in a single call
+const DescribeServicesBatchSize = 10
+
+// ServiceStablePollInterval is the interval between polls when waiting for a service to become stable
+var ServiceStablePollInterval = 2 * time.Second
+
 // GetService describes an ECS service in a cluster by the service name
 func (e *ECS) GetService(ctx context.Context, cluster, service string) (*ecs.Service, error) {
 	if cluster == "" || service == "" {
@@ -29,6 +38,21 @@ func (e *ECS) GetService(ctx context.Context, cluster, service string) (*ecs.Ser
 
 	log.Debugf("got output from DescribeServices: %+v", output)
 
+	// describe services succeeds with a failure entry for services that don't exist (or can't be described)
+	for _, f := range output.Failures {
+		if !serviceFailureMatches(f, service) {
+			log.Warnf("describe services %s/%s returned unrelated failure %+v", cluster, service, f)
+			continue
+		}
+
+		msg := fmt.Sprintf("service %s not found in cluster %s: %s", service, cluster, aws.StringValue(f.Reason))
+		if detail := aws.StringValue(f.Detail); detail != "" {
+			msg = msg + " (" + detail + ")"
+		}
+
+		return nil, apierror.New(apierror.ErrNotFound, msg, nil)
+	}
+
 	active := []*ecs.Service{}
 	for _, s := range output.Services {
 		if aws.StringValue(s.Status) == "ACTIVE" {
@@ -45,6 +69,16 @@ func (e *ECS) GetService(ctx context.Context, cluster, service string) (*ecs.Ser
 	return active[0], nil
 }
 
+// serviceFailureMatches returns true if the describe services failure is for the given service name or ARN
+func serviceFailureMatches(f *ecs.Failure, service string) bool {
+	if f == nil {
+		return false
+	}
+
+	a := aws.StringValue(f.Arn)
+	return a == service || strings.HasSuffix(a, "/"+service)
+}
+
 // DeleteService removes an ECS service in a cluster by the service name (forcefully)
 func (e *ECS) DeleteService(ctx context.Context, input *ecs.DeleteServiceInput) error {
 	if input == nil {
@@ -58,6 +92,9 @@ func (e *ECS) DeleteService(ctx context.Context, input *ecs.DeleteServiceInput)
 		return ErrCode("failed to delete service", err)
 	}
 
+	// deleting a service changes the cluster's active services count
+	e.InvalidateCluster(aws.StringValue(input.Cluster))
+
 	log.Debugf("output from delete service:\n%+v", output)
 
 	return err
@@ -98,6 +135,135 @@ func (e *ECS) ListServices(ctx context.Context, cluster string) ([]string, error
 	return output, nil
 }
 
+// ListServicesWithTags lists the ECS services in a cluster that have all of the given tag keys and values.  The services
+// are described in batches (with their tags) to filter the list.
+func (e *ECS) ListServicesWithTags(ctx context.Context, cluster string, tags map[string]string) ([]string, error) {
+	services, err := e.ListServices(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tags) == 0 {
+		return services, nil
+	}
+
+	log.Infof("filtering services in cluster %s with tags %+v", cluster, tags)
+
+	output := []string{}
+	for i := 0; i < len(services); i += DescribeServicesBatchSize {
+		end := i + DescribeServicesBatchSize
+		if end > len(services) {
+			end = len(services)
+		}
+
+		out, err := e.Service.DescribeServicesWithContext(ctx, &ecs.DescribeServicesInput{
+			Cluster:  aws.String(cluster),
+			Include:  aws.StringSlice([]string{"TAGS"}),
+			Services: aws.StringSlice(services[i:end]),
+		})
+		if err != nil {
+			return nil, ErrCode("failed to describe services", err)
+		}
+
+		for _, s := range out.Services {
+			if matchTags(s.Tags, tags) {
+				output = append(output, aws.StringValue(s.ServiceArn))
+			}
+		}
+	}
+
+	log.Debugf("got list of services on cluster '%s' with tags %+v: %+v", cluster, tags, output)
+
+	return output, nil
+}
+
+// ListServiceTaskDefinitions lists the task definitions used by the active services in a cluster, of any launch type,
+// including the task definitions of deployments that are still in progress.  The services are described in batches.
+func (e *ECS) ListServiceTaskDefinitions(ctx context.Context, cluster string) ([]string, error) {
+	if cluster == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing task definitions of the services in cluster %s", cluster)
+
+	input := ecs.ListServicesInput{Cluster: aws.String(cluster)}
+
+	services := []*string{}
+	for {
+		out, err := e.Service.ListServicesWithContext(ctx, &input)
+		if err != nil {
+			return nil, ErrCode("failed to list services", err)
+		}
+
+		services = append(services, out.ServiceArns...)
+
+		if out.NextToken == nil {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+
+	seen := map[string]struct{}{}
+	output := []string{}
+	add := func(td *string) {
+		if aws.StringValue(td) == "" {
+			return
+		}
+
+		if _, ok := seen[aws.StringValue(td)]; !ok {
+			seen[aws.StringValue(td)] = struct{}{}
+			output = append(output, aws.StringValue(td))
+		}
+	}
+
+	for i := 0; i < len(services); i += DescribeServicesBatchSize {
+		end := i + DescribeServicesBatchSize
+		if end > len(services) {
+			end = len(services)
+		}
+
+		out, err := e.Service.DescribeServicesWithContext(ctx, &ecs.DescribeServicesInput{
+			Cluster:  aws.String(cluster),
+			Services: services[i:end],
+		})
+		if err != nil {
+			return nil, ErrCode("failed to describe services", err)
+		}
+
+		for _, s := range out.Services {
+			// deleted services are listed while they drain
+			if aws.StringValue(s.Status) != "ACTIVE" {
+				continue
+			}
+
+			add(s.TaskDefinition)
+			for _, d := range s.Deployments {
+				add(d.TaskDefinition)
+			}
+		}
+	}
+
+	log.Debugf("got task definitions of the services in cluster '%s': %+v", cluster, output)
+
+	return output, nil
+}
+
+// matchTags returns true if all of the filter tag keys and values are in the list of tags
+func matchTags(tags []*ecs.Tag, filters map[string]string) bool {
+	tagMap := make(map[string]string, len(tags))
+	for _, t := range tags {
+		tagMap[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+
+	for k, v := range filters {
+		if value, ok := tagMap[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
 // CreateService creates an ECS Service
 func (e *ECS) CreateService(ctx context.Context, input *ecs.CreateServiceInput) (*ecs.CreateServiceOutput, error) {
 	if input == nil {
@@ -111,6 +277,9 @@ func (e *ECS) CreateService(ctx context.Context, input *ecs.CreateServiceInput)
 		return nil, ErrCode("failed to create service", err)
 	}
 
+	// creating a service changes the cluster's active services count
+	e.InvalidateCluster(aws.StringValue(input.Cluster))
+
 	return output, nil
 }
 
@@ -129,3 +298,97 @@ func (e *ECS) UpdateService(ctx context.Context, input *ecs.UpdateServiceInput)
 
 	return output, nil
 }
+
+// DeploymentsStable returns true if the primary deployment is the only deployment of a service, it hasn't failed and
+// its running count matches the desired count with no pending tasks
+func DeploymentsStable(deployments []*ecs.Deployment) bool {
+	if len(deployments) != 1 {
+		return false
+	}
+
+	primary := deployments[0]
+	if aws.StringValue(primary.Status) != "PRIMARY" {
+		return false
+	}
+
+	if aws.StringValue(primary.RolloutState) == ecs.DeploymentRolloutStateFailed {
+		return false
+	}
+
+	return aws.Int64Value(primary.RunningCount) == aws.Int64Value(primary.DesiredCount) && aws.Int64Value(primary.PendingCount) == 0
+}
+
+// ServiceStability is the state of the service deployments when WaitUntilServiceStable returns.  A service that isn't
+// stable before the timeout isn't an error, Stable is false and Deployments are the deployments of the last poll.
+type ServiceStability struct {
+	Deployments []*ecs.Deployment
+	Stable      bool
+}
+
+// WaitUntilServiceStable polls the service until its deployments are stable (see DeploymentsStable).  The service is
+// described with GetService (instead of the SDK waiter) so errors are mapped and the context is respected.  An error
+// is returned if the primary deployment fails, or if the service couldn't be described before the timeout.
+func (e *ECS) WaitUntilServiceStable(ctx context.Context, cluster, service string, timeout time.Duration) (*ServiceStability, error) {
+	if cluster == "" || service == "" || timeout <= 0 {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("waiting up to %s for service %s/%s to become stable", timeout, cluster, service)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(ServiceStablePollInterval)
+	defer ticker.Stop()
+
+	var output *ServiceStability
+	timedOut := func() (*ServiceStability, error) {
+		if output == nil {
+			msg := fmt.Sprintf("timed out after %s waiting for service %s/%s to become stable", timeout, cluster, service)
+			return nil, apierror.New(apierror.ErrServiceUnavailable, msg, ctx.Err())
+		}
+
+		log.Infof("service %s/%s isn't stable after %s", cluster, service, timeout)
+		return output, nil
+	}
+
+	for {
+		svc, err := e.GetService(ctx, cluster, service)
+		if err != nil {
+			if ctx.Err() != nil {
+				return timedOut()
+			}
+			return nil, err
+		}
+
+		for _, d := range svc.Deployments {
+			if aws.StringValue(d.Status) != "PRIMARY" {
+				continue
+			}
+
+			if aws.StringValue(d.RolloutState) == ecs.DeploymentRolloutStateFailed {
+				msg := fmt.Sprintf("service %s/%s deployment %s failed: %s", cluster, service, aws.StringValue(d.Id), aws.StringValue(d.RolloutStateReason))
+				return nil, apierror.New(apierror.ErrConflict, msg, nil)
+			}
+
+			log.Debugf("service %s/%s primary deployment has %d of %d running tasks (%d pending) and %d other deployments", cluster, service,
+				aws.Int64Value(d.RunningCount), aws.Int64Value(d.DesiredCount), aws.Int64Value(d.PendingCount), len(svc.Deployments)-1)
+		}
+
+		output = &ServiceStability{
+			Deployments: svc.Deployments,
+			Stable:      DeploymentsStable(svc.Deployments),
+		}
+
+		if output.Stable {
+			log.Infof("service %s/%s is stable with %d running tasks", cluster, service, aws.Int64Value(svc.Deployments[0].RunningCount))
+			return output, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return timedOut()
+		case <-ticker.C:
+		}
+	}
+}
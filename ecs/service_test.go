@@ -0,0 +1,498 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YaleSpinup/apierror"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// testServices is a list of 12 services (more than a single describe batch), every third service belongs to the
+// "frontend" app (running the frontend task definition) and every other service is in the "prod" environment
+var testServices = func() []*ecs.Service {
+	services := []*ecs.Service{}
+	for i := 0; i < 12; i++ {
+		app := "backend"
+		if i%3 == 0 {
+			app = "frontend"
+		}
+
+		env := "dev"
+		if i%2 == 0 {
+			env = "prod"
+		}
+
+		services = append(services, &ecs.Service{
+			ServiceArn:     aws.String(fmt.Sprintf("arn:aws:ecs:us-east-1:0123456789:service/clu0/svc%d", i)),
+			ServiceName:    aws.String(fmt.Sprintf("svc%d", i)),
+			Status:         aws.String("ACTIVE"),
+			TaskDefinition: aws.String(fmt.Sprintf("arn:aws:ecs:us-east-1:0123456789:task-definition/%s:1", app)),
+			Tags: []*ecs.Tag{
+				{Key: aws.String("spinup:app"), Value: aws.String(app)},
+				{Key: aws.String("env"), Value: aws.String(env)},
+			},
+		})
+	}
+	return services
+}()
+
+// testServiceDeployments returns the deployments of test services for the number of times services have been described
+var testServiceDeployments = map[string]func(describes int) []*ecs.Deployment{
+	// one more task is running each time the service is described until both are running
+	"rolling": func(describes int) []*ecs.Deployment {
+		running := int64(describes - 1)
+		if running > 2 {
+			running = 2
+		}
+
+		deployments := []*ecs.Deployment{
+			{
+				DesiredCount: aws.Int64(2),
+				Id:           aws.String("ecs-svc/0000000000000000002"),
+				RolloutState: aws.String("IN_PROGRESS"),
+				RunningCount: aws.Int64(running),
+				Status:       aws.String("PRIMARY"),
+			},
+		}
+
+		// the old deployment is removed once it's drained
+		if running < 2 {
+			deployments = append(deployments, &ecs.Deployment{
+				DesiredCount: aws.Int64(2),
+				Id:           aws.String("ecs-svc/0000000000000000001"),
+				RolloutState: aws.String("COMPLETED"),
+				RunningCount: aws.Int64(2 - running),
+				Status:       aws.String("ACTIVE"),
+			})
+		}
+
+		return deployments
+	},
+	"draining": func(int) []*ecs.Deployment {
+		return []*ecs.Deployment{
+			{
+				DesiredCount: aws.Int64(2),
+				Id:           aws.String("ecs-svc/0000000000000000005"),
+				RolloutState: aws.String("IN_PROGRESS"),
+				RunningCount: aws.Int64(2),
+				Status:       aws.String("PRIMARY"),
+			},
+			{
+				DesiredCount: aws.Int64(2),
+				Id:           aws.String("ecs-svc/0000000000000000006"),
+				RolloutState: aws.String("COMPLETED"),
+				RunningCount: aws.Int64(1),
+				Status:       aws.String("ACTIVE"),
+			},
+		}
+	},
+	"pending": func(int) []*ecs.Deployment {
+		return []*ecs.Deployment{
+			{
+				DesiredCount: aws.Int64(2),
+				Id:           aws.String("ecs-svc/0000000000000000007"),
+				PendingCount: aws.Int64(1),
+				RolloutState: aws.String("IN_PROGRESS"),
+				RunningCount: aws.Int64(2),
+				Status:       aws.String("PRIMARY"),
+			},
+		}
+	},
+	"stuck": func(int) []*ecs.Deployment {
+		return []*ecs.Deployment{
+			{
+				DesiredCount: aws.Int64(2),
+				Id:           aws.String("ecs-svc/0000000000000000003"),
+				RolloutState: aws.String("IN_PROGRESS"),
+				RunningCount: aws.Int64(0),
+				Status:       aws.String("PRIMARY"),
+			},
+		}
+	},
+	"failing": func(int) []*ecs.Deployment {
+		return []*ecs.Deployment{
+			{
+				DesiredCount:       aws.Int64(2),
+				Id:                 aws.String("ecs-svc/0000000000000000004"),
+				RolloutState:       aws.String("FAILED"),
+				RolloutStateReason: aws.String("ECS deployment circuit breaker: tasks failed to start."),
+				RunningCount:       aws.Int64(0),
+				Status:             aws.String("PRIMARY"),
+			},
+		}
+	},
+}
+
+func (m *mockECSClient) ListServicesWithContext(ctx aws.Context, input *ecs.ListServicesInput, opts ...request.Option) (*ecs.ListServicesOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	// return the services in two pages
+	services := testServices[:7]
+	var next *string
+	if input.NextToken == nil {
+		next = aws.String("page2")
+	} else {
+		services = testServices[7:]
+	}
+
+	output := &ecs.ListServicesOutput{NextToken: next}
+	for _, s := range services {
+		output.ServiceArns = append(output.ServiceArns, s.ServiceArn)
+	}
+
+	return output, nil
+}
+
+func (m *mockECSClient) DescribeServicesWithContext(ctx aws.Context, input *ecs.DescribeServicesInput, opts ...request.Option) (*ecs.DescribeServicesOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if len(input.Services) > DescribeServicesBatchSize {
+		return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "too many services", nil)
+	}
+
+	m.describes++
+
+	output := &ecs.DescribeServicesOutput{}
+	for _, id := range input.Services {
+		if deployments, ok := testServiceDeployments[aws.StringValue(id)]; ok {
+			output.Services = append(output.Services, &ecs.Service{
+				Deployments: deployments(m.describes),
+				ServiceName: id,
+				Status:      aws.String("ACTIVE"),
+			})
+			continue
+		}
+
+		found := false
+		for _, s := range testServices {
+			if aws.StringValue(id) == aws.StringValue(s.ServiceArn) || aws.StringValue(id) == aws.StringValue(s.ServiceName) {
+				svc := *s
+				if len(input.Include) == 0 {
+					svc.Tags = nil
+				}
+				output.Services = append(output.Services, &svc)
+				found = true
+			}
+		}
+
+		// services that don't exist are returned as failures, like the real api
+		if !found {
+			arn := aws.StringValue(id)
+			if !strings.HasPrefix(arn, "arn:") {
+				arn = fmt.Sprintf("arn:aws:ecs:us-east-1:0123456789:service/%s/%s", aws.StringValue(input.Cluster), arn)
+			}
+
+			output.Failures = append(output.Failures, &ecs.Failure{
+				Arn:    aws.String(arn),
+				Reason: aws.String("MISSING"),
+			})
+		}
+	}
+
+	return output, nil
+}
+
+func TestECS_ListServicesWithTags(t *testing.T) {
+	arns := func(ids ...int) []string {
+		out := []string{}
+		for _, i := range ids {
+			out = append(out, aws.StringValue(testServices[i].ServiceArn))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		cluster string
+		tags    map[string]string
+		err     error
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "empty cluster",
+			wantErr: true,
+		},
+		{
+			name:    "no tag filter",
+			cluster: "clu0",
+			want:    arns(0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11),
+		},
+		{
+			name:    "single tag filter",
+			cluster: "clu0",
+			tags:    map[string]string{"spinup:app": "frontend"},
+			want:    arns(0, 3, 6, 9),
+		},
+		{
+			name:    "multiple tag filters",
+			cluster: "clu0",
+			tags:    map[string]string{"spinup:app": "frontend", "env": "prod"},
+			want:    arns(0, 6),
+		},
+		{
+			name:    "no matches",
+			cluster: "clu0",
+			tags:    map[string]string{"spinup:app": "frontend", "env": "staging"},
+			want:    []string{},
+		},
+		{
+			name:    "missing tag key",
+			cluster: "clu0",
+			tags:    map[string]string{"owner": "me"},
+			want:    []string{},
+		},
+		{
+			name:    "aws error",
+			cluster: "clu0",
+			tags:    map[string]string{"spinup:app": "frontend"},
+			err:     awserr.New(ecs.ErrCodeServerException, "boom", nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ECS{Service: newmockECSClient(t, tt.err)}
+			got, err := e.ListServicesWithTags(context.TODO(), tt.cluster, tt.tags)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ECS.ListServicesWithTags() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ECS.ListServicesWithTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestECS_ListServiceTaskDefinitions(t *testing.T) {
+	e := ECS{Service: newmockECSClient(t, nil)}
+	if _, err := e.ListServiceTaskDefinitions(context.TODO(), ""); err == nil {
+		t.Error("expected error for empty cluster, got nil")
+	}
+
+	got, err := e.ListServiceTaskDefinitions(context.TODO(), "clu0")
+	if err != nil {
+		t.Fatalf("ECS.ListServiceTaskDefinitions() unexpected error = %v", err)
+	}
+
+	want := []string{
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/frontend:1",
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/backend:1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ECS.ListServiceTaskDefinitions() = %v, want %v", got, want)
+	}
+
+	e = ECS{Service: newmockECSClient(t, awserr.New(ecs.ErrCodeServerException, "boom", nil))}
+	if _, err := e.ListServiceTaskDefinitions(context.TODO(), "clu0"); err == nil {
+		t.Error("expected error from aws, got nil")
+	}
+}
+
+func TestECS_GetService(t *testing.T) {
+	tests := []struct {
+		name     string
+		cluster  string
+		service  string
+		err      error
+		want     *ecs.Service
+		wantCode string
+	}{
+		{
+			name:     "empty input",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:    "service by name",
+			cluster: "clu0",
+			service: "svc1",
+			want:    testServices[1],
+		},
+		{
+			name:    "service by arn",
+			cluster: "clu0",
+			service: "arn:aws:ecs:us-east-1:0123456789:service/clu0/svc2",
+			want:    testServices[2],
+		},
+		{
+			name:     "missing service by name",
+			cluster:  "clu0",
+			service:  "svc99",
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "missing service by arn",
+			cluster:  "clu0",
+			service:  "arn:aws:ecs:us-east-1:0123456789:service/clu0/svc99",
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "aws error",
+			cluster:  "clu0",
+			service:  "svc1",
+			err:      awserr.New(ecs.ErrCodeClusterNotFoundException, "cluster not found", nil),
+			wantCode: apierror.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ECS{Service: newmockECSClient(t, tt.err)}
+			got, err := e.GetService(context.TODO(), tt.cluster, tt.service)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			want := *tt.want
+			want.Tags = nil
+			if !reflect.DeepEqual(got, &want) {
+				t.Errorf("ECS.GetService() = %+v, want %+v", got, &want)
+			}
+		})
+	}
+}
+
+func TestDeploymentsStable(t *testing.T) {
+	for _, service := range []string{"draining", "pending", "failing", "stuck"} {
+		if DeploymentsStable(testServiceDeployments[service](1)) {
+			t.Errorf("expected %s deployments not to be stable", service)
+		}
+	}
+
+	if !DeploymentsStable(testServiceDeployments["rolling"](3)) {
+		t.Error("expected drained rolling deployments to be stable")
+	}
+
+	if DeploymentsStable(nil) {
+		t.Error("expected no deployments not to be stable")
+	}
+}
+
+func TestECS_WaitUntilServiceStable(t *testing.T) {
+	interval := ServiceStablePollInterval
+	ServiceStablePollInterval = 10 * time.Millisecond
+	defer func() { ServiceStablePollInterval = interval }()
+
+	tests := []struct {
+		name          string
+		service       string
+		timeout       time.Duration
+		err           error
+		wantCode      string
+		wantStable    bool
+		wantDescribes int
+	}{
+		{
+			name:          "service becomes stable",
+			service:       "rolling",
+			timeout:       time.Second,
+			wantStable:    true,
+			wantDescribes: 3,
+		},
+		{
+			name:    "service times out",
+			service: "stuck",
+			timeout: 50 * time.Millisecond,
+		},
+		{
+			name:    "old deployment is still draining",
+			service: "draining",
+			timeout: 50 * time.Millisecond,
+		},
+		{
+			name:    "tasks are pending",
+			service: "pending",
+			timeout: 50 * time.Millisecond,
+		},
+		{
+			name:          "deployment fails",
+			service:       "failing",
+			timeout:       time.Second,
+			wantCode:      apierror.ErrConflict,
+			wantDescribes: 1,
+		},
+		{
+			name:     "missing service",
+			service:  "missing",
+			timeout:  time.Second,
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "no timeout",
+			service:  "rolling",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "ecs error",
+			service:  "rolling",
+			timeout:  time.Second,
+			err:      awserr.New(ecs.ErrCodeServerException, "boom", nil),
+			wantCode: apierror.ErrInternalError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockECSClient{t: t, err: tt.err}
+			e := ECS{Service: client}
+
+			out, err := e.WaitUntilServiceStable(context.TODO(), "clu0", tt.service, tt.timeout)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("expected nil error, got %s", err)
+				}
+
+				if out.Stable != tt.wantStable {
+					t.Errorf("expected stable %t, got %t", tt.wantStable, out.Stable)
+				}
+
+				// a service that isn't stable in time returns its current deployments
+				if len(out.Deployments) == 0 {
+					t.Error("expected the current deployments, got none")
+				}
+			} else {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s (%s)", tt.wantCode, aerr.Code, aerr)
+				}
+			}
+
+			if tt.wantDescribes > 0 && client.describes != tt.wantDescribes {
+				t.Errorf("expected %d describes, got %d", tt.wantDescribes, client.describes)
+			}
+		})
+	}
+}
@@ -45,6 +45,120 @@ func (e *ECS) ListTasks(ctx context.Context, input *ecs.ListTasksInput) ([]*stri
 	return tasks, nil
 }
 
+// DescribeTasksBatchSize is the maximum number of tasks that can be described in a single call
+const DescribeTasksBatchSize = 100
+
+// ListClusterTasks lists the ids of all of the tasks in a cluster with the given desired status, following the pagination
+func (e *ECS) ListClusterTasks(ctx context.Context, cluster, desiredStatus string) ([]string, error) {
+	if cluster == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing %s tasks in cluster %s", desiredStatus, cluster)
+
+	input := ecs.ListTasksInput{
+		Cluster: aws.String(cluster),
+	}
+
+	if desiredStatus != "" {
+		input.DesiredStatus = aws.String(desiredStatus)
+	}
+
+	tasks, err := e.listTaskIds(ctx, &input)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("got list of %s tasks in cluster %s: %+v", desiredStatus, cluster, tasks)
+
+	return tasks, nil
+}
+
+// ListServiceTasks lists the ids of all of the tasks for a service with the given desired status, following the pagination
+func (e *ECS) ListServiceTasks(ctx context.Context, cluster, service, desiredStatus string) ([]string, error) {
+	if cluster == "" || service == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing %s tasks for service %s/%s", desiredStatus, cluster, service)
+
+	input := ecs.ListTasksInput{
+		Cluster:     aws.String(cluster),
+		ServiceName: aws.String(service),
+	}
+
+	if desiredStatus != "" {
+		input.DesiredStatus = aws.String(desiredStatus)
+	}
+
+	tasks, err := e.listTaskIds(ctx, &input)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("got list of %s tasks for service %s/%s: %+v", desiredStatus, cluster, service, tasks)
+
+	return tasks, nil
+}
+
+// ListStartedByTasks lists the ids of all of the tasks in a cluster started by the given invoker with the given desired status,
+// following the pagination
+func (e *ECS) ListStartedByTasks(ctx context.Context, cluster, startedBy, desiredStatus string) ([]string, error) {
+	if cluster == "" || startedBy == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing %s tasks started by %s in cluster %s", desiredStatus, startedBy, cluster)
+
+	input := ecs.ListTasksInput{
+		Cluster:   aws.String(cluster),
+		StartedBy: aws.String(startedBy),
+	}
+
+	if desiredStatus != "" {
+		input.DesiredStatus = aws.String(desiredStatus)
+	}
+
+	tasks, err := e.listTaskIds(ctx, &input)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("got list of %s tasks started by %s in cluster %s: %+v", desiredStatus, startedBy, cluster, tasks)
+
+	return tasks, nil
+}
+
+// listTaskIds lists the ids of the tasks matching the input, following the pagination
+func (e *ECS) listTaskIds(ctx context.Context, input *ecs.ListTasksInput) ([]string, error) {
+	tasks := []string{}
+	for {
+		out, err := e.Service.ListTasksWithContext(ctx, input)
+		if err != nil {
+			return nil, ErrCode("failed listing tasks", err)
+		}
+
+		for _, t := range out.TaskArns {
+			taskArn, err := arn.Parse(aws.StringValue(t))
+			if err != nil {
+				msg := fmt.Sprintf("failed to parse '%s'", aws.StringValue(t))
+				return nil, ErrCode(msg, err)
+			}
+
+			// task resource is the form task/xxxxxxxxxxxxx or task/cluster/xxxxxxxxxxxxx
+			r := strings.Split(taskArn.Resource, "/")
+			tasks = append(tasks, r[len(r)-1])
+		}
+
+		if out.NextToken == nil {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+
+	return tasks, nil
+}
+
 // GetTasks describes the given tasks in the give cluster
 func (e *ECS) GetTasks(ctx context.Context, input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
 	if input == nil {
@@ -90,7 +204,7 @@ func (e *ECS) StopTask(ctx context.Context, input *ecs.StopTaskInput) (*ecs.Task
 
 	out, err := e.Service.StopTaskWithContext(ctx, input)
 	if err != nil {
-		return nil, ErrCode("failed to describe tasks", err)
+		return nil, ErrCode("failed to stop task", err)
 	}
 
 	log.Debugf("output from stopping task %s/%+v: %+v", aws.StringValue(input.Cluster), aws.StringValue(input.Task), out)
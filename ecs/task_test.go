@@ -415,6 +415,64 @@ func TestECS_ListTasks(t *testing.T) {
 	}
 }
 
+func TestECS_ListServiceTasks(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *mockECSClient
+		cluster string
+		service string
+		status  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "empty service",
+			client:  &mockECSClient{t: t},
+			cluster: "clu1",
+			wantErr: true,
+		},
+		{
+			name: "error from aws",
+			client: &mockECSClient{
+				t:   t,
+				err: awserr.New(ecs.ErrCodeServiceNotFoundException, "service not found", nil),
+			},
+			cluster: "clu1",
+			service: "svc1",
+			wantErr: true,
+		},
+		{
+			name:    "running tasks",
+			client:  &mockECSClient{t: t},
+			cluster: "clu1",
+			service: "svc1",
+			status:  "RUNNING",
+			want:    []string{"task2:2"},
+		},
+		{
+			name:    "no matching tasks",
+			client:  &mockECSClient{t: t},
+			cluster: "clu2",
+			service: "svc2",
+			status:  "STOPPED",
+			want:    []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &ECS{Service: tt.client}
+			got, err := e.ListServiceTasks(context.TODO(), tt.cluster, tt.service, tt.status)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ECS.ListServiceTasks() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ECS.ListServiceTasks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestECS_StopTask(t *testing.T) {
 	type fields struct {
 		Service        ecsiface.ECSAPI
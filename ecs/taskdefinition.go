@@ -71,18 +71,28 @@ func (e *ECS) GetTaskDefinition(ctx context.Context, taskdefinition *string, tag
 	return output.TaskDefinition, output.Tags, err
 }
 
-// ListTaskDefinitionRevisions lists all of the task definition [revisions] in a family
+// ListTaskDefinitionRevisions lists all of the ACTIVE task definition [revisions] in a family
 func (e *ECS) ListTaskDefinitionRevisions(ctx context.Context, family *string) ([]string, error) {
+	return e.ListTaskDefinitionRevisionsWithStatus(ctx, family, "")
+}
+
+// ListTaskDefinitionRevisionsWithStatus lists the task definition [revisions] in a family with the given status (ACTIVE
+// or INACTIVE).  ECS only lists the ACTIVE revisions if the status is empty.
+func (e *ECS) ListTaskDefinitionRevisionsWithStatus(ctx context.Context, family *string, status string) ([]string, error) {
 	if aws.StringValue(family) == "" {
 		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
 	}
 
-	log.Infof("listing task definition revisions with family '%s'", aws.StringValue(family))
+	log.Infof("listing task definition revisions with family '%s' and status '%s'", aws.StringValue(family), status)
 
 	input := ecs.ListTaskDefinitionsInput{
 		FamilyPrefix: family,
 	}
 
+	if status != "" {
+		input.Status = aws.String(status)
+	}
+
 	output := []string{}
 	for {
 		out, err := e.Service.ListTaskDefinitionsWithContext(ctx, &input)
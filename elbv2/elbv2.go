@@ -6,7 +6,6 @@ import (
 	"github.com/YaleSpinup/apierror"
 	"github.com/YaleSpinup/ecs-api/common"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
@@ -22,10 +21,7 @@ type ELBV2API struct {
 func NewSession(account common.Account) ELBV2API {
 	s := ELBV2API{}
 	log.Infof("creating new aws session for elbv2 with key id %s in region %s", account.Akid, account.Region)
-	sess := session.Must(session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(account.Akid, account.Secret, ""),
-		Region:      aws.String(account.Region),
-	}))
+	sess := session.Must(session.NewSession(account.AWSConfig()))
 	s.Service = elbv2.New(sess)
 	return s
 }
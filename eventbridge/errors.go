@@ -0,0 +1,110 @@
+package eventbridge
+
+import (
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/pkg/errors"
+)
+
+func ErrCode(msg string, err error) error {
+	if aerr, ok := errors.Cause(err).(awserr.Error); ok {
+		switch aerr.Code() {
+		case
+
+			// ErrCodeConcurrentModificationException for service response error code
+			// "ConcurrentModificationException".
+			//
+			// There is concurrent modification on a rule, target, archive, or replay.
+			eventbridge.ErrCodeConcurrentModificationException,
+
+			// ErrCodeResourceAlreadyExistsException for service response error code
+			// "ResourceAlreadyExistsException".
+			//
+			// The resource you are trying to create already exists.
+			eventbridge.ErrCodeResourceAlreadyExistsException:
+
+			return apierror.New(apierror.ErrConflict, msg, aerr)
+		case
+
+			// ErrCodeInternalException for service response error code
+			// "InternalException".
+			//
+			// This exception occurs due to unexpected causes.
+			eventbridge.ErrCodeInternalException:
+
+			return apierror.New(apierror.ErrInternalError, msg, aerr)
+		case
+
+			// ErrCodeLimitExceededException for service response error code
+			// "LimitExceededException".
+			//
+			// The request failed because it attempted to create resource beyond the allowed
+			// service quota.
+			eventbridge.ErrCodeLimitExceededException,
+
+			// ErrCodePolicyLengthExceededException for service response error code
+			// "PolicyLengthExceededException".
+			//
+			// The event bus policy is too long. For more information, see the limits.
+			eventbridge.ErrCodePolicyLengthExceededException:
+
+			return apierror.New(apierror.ErrLimitExceeded, msg, aerr)
+		case
+
+			// ErrCodeManagedRuleException for service response error code
+			// "ManagedRuleException".
+			//
+			// This rule was created by an Amazon Web Services service on behalf of your
+			// account. It is managed by that service.
+			eventbridge.ErrCodeManagedRuleException:
+
+			return apierror.New(apierror.ErrForbidden, msg, aerr)
+		case
+
+			// ErrCodeOperationDisabledException for service response error code
+			// "OperationDisabledException".
+			//
+			// The operation you are attempting is not available in this region.
+			eventbridge.ErrCodeOperationDisabledException:
+
+			return apierror.New(apierror.ErrServiceUnavailable, msg, aerr)
+		case
+
+			// ErrCodeResourceNotFoundException for service response error code
+			// "ResourceNotFoundException".
+			//
+			// An entity that you specified does not exist.
+			eventbridge.ErrCodeResourceNotFoundException:
+
+			return apierror.New(apierror.ErrNotFound, msg, aerr)
+		case
+
+			// ErrCodeIllegalStatusException for service response error code
+			// "IllegalStatusException".
+			//
+			// An error occurred because a replay can be canceled only when the state is
+			// Running or Starting.
+			eventbridge.ErrCodeIllegalStatusException,
+
+			// ErrCodeInvalidEventPatternException for service response error code
+			// "InvalidEventPatternException".
+			//
+			// The event pattern is not valid.
+			eventbridge.ErrCodeInvalidEventPatternException,
+
+			// ErrCodeInvalidStateException for service response error code
+			// "InvalidStateException".
+			//
+			// The specified state is not a valid state for an event source.
+			eventbridge.ErrCodeInvalidStateException:
+
+			return apierror.New(apierror.ErrBadRequest, msg, aerr)
+		default:
+			m := msg + ": " + aerr.Message()
+			return apierror.New(apierror.ErrBadRequest, m, aerr)
+		}
+	}
+
+	return apierror.New(apierror.ErrInternalError, msg, err)
+}
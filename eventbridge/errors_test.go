@@ -0,0 +1,46 @@
+package eventbridge
+
+import (
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/pkg/errors"
+)
+
+func TestErrCode(t *testing.T) {
+	apiErrorTestCases := map[string]string{
+		"": apierror.ErrBadRequest,
+
+		eventbridge.ErrCodeConcurrentModificationException: apierror.ErrConflict,
+		eventbridge.ErrCodeResourceAlreadyExistsException:  apierror.ErrConflict,
+		eventbridge.ErrCodeInternalException:               apierror.ErrInternalError,
+		eventbridge.ErrCodeLimitExceededException:          apierror.ErrLimitExceeded,
+		eventbridge.ErrCodePolicyLengthExceededException:   apierror.ErrLimitExceeded,
+		eventbridge.ErrCodeManagedRuleException:            apierror.ErrForbidden,
+		eventbridge.ErrCodeOperationDisabledException:      apierror.ErrServiceUnavailable,
+		eventbridge.ErrCodeResourceNotFoundException:       apierror.ErrNotFound,
+		eventbridge.ErrCodeIllegalStatusException:          apierror.ErrBadRequest,
+		eventbridge.ErrCodeInvalidEventPatternException:    apierror.ErrBadRequest,
+		eventbridge.ErrCodeInvalidStateException:           apierror.ErrBadRequest,
+	}
+
+	for awsErr, apiErr := range apiErrorTestCases {
+		err := ErrCode("test error", awserr.New(awsErr, awsErr, nil))
+		if aerr, ok := errors.Cause(err).(apierror.Error); ok {
+			if aerr.Code != apiErr {
+				t.Errorf("expected eventbridge error %s to be an apierror %s, got %s", awsErr, apiErr, aerr.Code)
+			}
+		} else {
+			t.Errorf("expected eventbridge error %s to be an apierror.Error %s, got %s", awsErr, apiErr, err)
+		}
+	}
+
+	err := ErrCode("test error", errors.New("Unknown"))
+	if aerr, ok := errors.Cause(err).(apierror.Error); ok {
+		t.Logf("got apierror '%s'", aerr)
+	} else {
+		t.Errorf("expected unknown error to be an apierror.ErrInternalError, got %s", err)
+	}
+}
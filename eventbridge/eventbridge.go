@@ -0,0 +1,184 @@
+package eventbridge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/YaleSpinup/ecs-api/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	log "github.com/sirupsen/logrus"
+)
+
+// EventBridge is a wrapper around the aws eventbridge service
+type EventBridge struct {
+	Service eventbridgeiface.EventBridgeAPI
+}
+
+// NewSession creates a new eventbridge session
+func NewSession(account common.Account) EventBridge {
+	e := EventBridge{}
+	log.Infof("creating new session with key id %s in region %s", account.Akid, account.Region)
+	sess := session.Must(session.NewSession(account.AWSConfig()))
+	e.Service = eventbridge.New(sess)
+	return e
+}
+
+// ListRuleNamesByTarget returns the names of the rules on the default event bus that target the given ARN
+func (e *EventBridge) ListRuleNamesByTarget(ctx context.Context, target string) ([]string, error) {
+	if target == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing eventbridge rules targeting %s", target)
+
+	rules := []string{}
+	input := &eventbridge.ListRuleNamesByTargetInput{TargetArn: aws.String(target)}
+	for {
+		out, err := e.Service.ListRuleNamesByTargetWithContext(ctx, input)
+		if err != nil {
+			return nil, ErrCode("failed to list rules by target", err)
+		}
+
+		rules = append(rules, aws.StringValueSlice(out.RuleNames)...)
+
+		if aws.StringValue(out.NextToken) == "" {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+
+	log.Debugf("got list of eventbridge rules targeting %s: %v", target, rules)
+
+	return rules, nil
+}
+
+// ListTargetsByRule returns the targets of a rule on the default event bus
+func (e *EventBridge) ListTargetsByRule(ctx context.Context, rule string) ([]*eventbridge.Target, error) {
+	if rule == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing targets of eventbridge rule %s", rule)
+
+	targets := []*eventbridge.Target{}
+	input := &eventbridge.ListTargetsByRuleInput{Rule: aws.String(rule)}
+	for {
+		out, err := e.Service.ListTargetsByRuleWithContext(ctx, input)
+		if err != nil {
+			return nil, ErrCode("failed to list targets by rule", err)
+		}
+
+		targets = append(targets, out.Targets...)
+
+		if aws.StringValue(out.NextToken) == "" {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+
+	return targets, nil
+}
+
+// GetRule describes a rule on the default event bus
+func (e *EventBridge) GetRule(ctx context.Context, rule string) (*eventbridge.DescribeRuleOutput, error) {
+	if rule == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("describing eventbridge rule %s", rule)
+
+	out, err := e.Service.DescribeRuleWithContext(ctx, &eventbridge.DescribeRuleInput{Name: aws.String(rule)})
+	if err != nil {
+		return nil, ErrCode("failed to describe rule", err)
+	}
+
+	log.Debugf("describe eventbridge rule output %+v", out)
+
+	return out, nil
+}
+
+// CreateRule creates (or updates) a rule on the default event bus and returns the rule ARN
+func (e *EventBridge) CreateRule(ctx context.Context, input *eventbridge.PutRuleInput) (string, error) {
+	if input == nil || aws.StringValue(input.Name) == "" {
+		return "", apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("creating eventbridge rule %s", aws.StringValue(input.Name))
+
+	out, err := e.Service.PutRuleWithContext(ctx, input)
+	if err != nil {
+		return "", ErrCode("failed to create rule", err)
+	}
+
+	return aws.StringValue(out.RuleArn), nil
+}
+
+// PutTargets adds (or updates) the targets of a rule on the default event bus
+func (e *EventBridge) PutTargets(ctx context.Context, rule string, targets []*eventbridge.Target) error {
+	if rule == "" || len(targets) == 0 {
+		return apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("putting %d targets for eventbridge rule %s", len(targets), rule)
+
+	out, err := e.Service.PutTargetsWithContext(ctx, &eventbridge.PutTargetsInput{
+		Rule:    aws.String(rule),
+		Targets: targets,
+	})
+	if err != nil {
+		return ErrCode("failed to put targets", err)
+	}
+
+	if aws.Int64Value(out.FailedEntryCount) > 0 {
+		f := out.FailedEntries[0]
+		msg := fmt.Sprintf("failed to put target %s: %s", aws.StringValue(f.TargetId), aws.StringValue(f.ErrorMessage))
+		return ErrCode(msg, awserr.New(aws.StringValue(f.ErrorCode), aws.StringValue(f.ErrorMessage), nil))
+	}
+
+	return nil
+}
+
+// RemoveTargets removes the targets (by id) from a rule on the default event bus
+func (e *EventBridge) RemoveTargets(ctx context.Context, rule string, ids []string) error {
+	if rule == "" || len(ids) == 0 {
+		return apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("removing targets %v from eventbridge rule %s", ids, rule)
+
+	out, err := e.Service.RemoveTargetsWithContext(ctx, &eventbridge.RemoveTargetsInput{
+		Rule: aws.String(rule),
+		Ids:  aws.StringSlice(ids),
+	})
+	if err != nil {
+		return ErrCode("failed to remove targets", err)
+	}
+
+	if aws.Int64Value(out.FailedEntryCount) > 0 {
+		f := out.FailedEntries[0]
+		msg := fmt.Sprintf("failed to remove target %s: %s", aws.StringValue(f.TargetId), aws.StringValue(f.ErrorMessage))
+		return ErrCode(msg, awserr.New(aws.StringValue(f.ErrorCode), aws.StringValue(f.ErrorMessage), nil))
+	}
+
+	return nil
+}
+
+// DeleteRule deletes a rule from the default event bus, the rule's targets must be removed first
+func (e *EventBridge) DeleteRule(ctx context.Context, rule string) error {
+	if rule == "" {
+		return apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("deleting eventbridge rule %s", rule)
+
+	if _, err := e.Service.DeleteRuleWithContext(ctx, &eventbridge.DeleteRuleInput{Name: aws.String(rule)}); err != nil {
+		return ErrCode("failed to delete rule", err)
+	}
+
+	return nil
+}
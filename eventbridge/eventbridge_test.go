@@ -0,0 +1,379 @@
+package eventbridge
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/YaleSpinup/ecs-api/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+)
+
+var testClusterArn = "arn:aws:ecs:us-east-1:1234567890:cluster/cluster0"
+
+// testRules are the rules returned by the mock, split across pages
+var testRules = [][]string{
+	{"nightly-report", "hourly-cleanup"},
+	{"weekly-backup"},
+}
+
+var testTargets = map[string][][]*eventbridge.Target{
+	"nightly-report": {
+		{{Id: aws.String("task"), Arn: aws.String(testClusterArn)}},
+		{{Id: aws.String("notify"), Arn: aws.String("arn:aws:sns:us-east-1:1234567890:topic")}},
+	},
+}
+
+type mockEBClient struct {
+	eventbridgeiface.EventBridgeAPI
+	t   *testing.T
+	err error
+}
+
+func newmockEBClient(t *testing.T, err error) eventbridgeiface.EventBridgeAPI {
+	return &mockEBClient{
+		t:   t,
+		err: err,
+	}
+}
+
+func (m *mockEBClient) ListRuleNamesByTargetWithContext(ctx aws.Context, input *eventbridge.ListRuleNamesByTargetInput, opts ...request.Option) (*eventbridge.ListRuleNamesByTargetOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if aws.StringValue(input.TargetArn) != testClusterArn {
+		return &eventbridge.ListRuleNamesByTargetOutput{RuleNames: []*string{}}, nil
+	}
+
+	if aws.StringValue(input.NextToken) == "" {
+		return &eventbridge.ListRuleNamesByTargetOutput{
+			RuleNames: aws.StringSlice(testRules[0]),
+			NextToken: aws.String("next"),
+		}, nil
+	}
+
+	return &eventbridge.ListRuleNamesByTargetOutput{RuleNames: aws.StringSlice(testRules[1])}, nil
+}
+
+func (m *mockEBClient) ListTargetsByRuleWithContext(ctx aws.Context, input *eventbridge.ListTargetsByRuleInput, opts ...request.Option) (*eventbridge.ListTargetsByRuleOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	pages, ok := testTargets[aws.StringValue(input.Rule)]
+	if !ok {
+		return nil, awserr.New(eventbridge.ErrCodeResourceNotFoundException, "rule not found", nil)
+	}
+
+	if aws.StringValue(input.NextToken) == "" {
+		return &eventbridge.ListTargetsByRuleOutput{Targets: pages[0], NextToken: aws.String("next")}, nil
+	}
+
+	return &eventbridge.ListTargetsByRuleOutput{Targets: pages[1]}, nil
+}
+
+func (m *mockEBClient) DescribeRuleWithContext(ctx aws.Context, input *eventbridge.DescribeRuleInput, opts ...request.Option) (*eventbridge.DescribeRuleOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if aws.StringValue(input.Name) != "nightly-report" {
+		return nil, awserr.New(eventbridge.ErrCodeResourceNotFoundException, "rule not found", nil)
+	}
+
+	return &eventbridge.DescribeRuleOutput{
+		Name:               input.Name,
+		ScheduleExpression: aws.String("cron(0 2 * * ? *)"),
+		State:              aws.String(eventbridge.RuleStateEnabled),
+	}, nil
+}
+
+func TestNewSession(t *testing.T) {
+	e := NewSession(common.Account{})
+	to := reflect.TypeOf(e).String()
+	if to != "eventbridge.EventBridge" {
+		t.Errorf("expected type to be 'eventbridge.EventBridge', got %s", to)
+	}
+}
+
+func TestEventBridge_ListRuleNamesByTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		err      error
+		want     []string
+		wantCode string
+	}{
+		{
+			name:   "rules across pages",
+			target: testClusterArn,
+			want:   []string{"nightly-report", "hourly-cleanup", "weekly-backup"},
+		},
+		{
+			name:   "no rules",
+			target: "arn:aws:ecs:us-east-1:1234567890:cluster/other",
+			want:   []string{},
+		},
+		{
+			name:     "empty target",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "eventbridge error",
+			target:   testClusterArn,
+			err:      awserr.New(eventbridge.ErrCodeInternalException, "boom", nil),
+			wantCode: apierror.ErrInternalError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := EventBridge{Service: newmockEBClient(t, tt.err)}
+
+			got, err := e.ListRuleNamesByTarget(context.TODO(), tt.target)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EventBridge.ListRuleNamesByTarget() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventBridge_ListTargetsByRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		want     []string
+		wantCode string
+	}{
+		{
+			name: "targets across pages",
+			rule: "nightly-report",
+			want: []string{"task", "notify"},
+		},
+		{
+			name:     "missing rule",
+			rule:     "missing",
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "empty rule",
+			wantCode: apierror.ErrBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := EventBridge{Service: newmockEBClient(t, nil)}
+
+			got, err := e.ListTargetsByRule(context.TODO(), tt.rule)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			ids := []string{}
+			for _, target := range got {
+				ids = append(ids, aws.StringValue(target.Id))
+			}
+
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("EventBridge.ListTargetsByRule() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventBridge_GetRule(t *testing.T) {
+	e := EventBridge{Service: newmockEBClient(t, nil)}
+
+	got, err := e.GetRule(context.TODO(), "nightly-report")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if aws.StringValue(got.ScheduleExpression) != "cron(0 2 * * ? *)" || aws.StringValue(got.State) != eventbridge.RuleStateEnabled {
+		t.Errorf("unexpected rule %+v", got)
+	}
+
+	_, err = e.GetRule(context.TODO(), "missing")
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	_, err = e.GetRule(context.TODO(), "")
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+		t.Errorf("expected bad request error, got %v", err)
+	}
+}
+
+func (m *mockEBClient) PutRuleWithContext(ctx aws.Context, input *eventbridge.PutRuleInput, opts ...request.Option) (*eventbridge.PutRuleOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &eventbridge.PutRuleOutput{RuleArn: aws.String("arn:aws:events:us-east-1:1234567890:rule/" + aws.StringValue(input.Name))}, nil
+}
+
+func (m *mockEBClient) PutTargetsWithContext(ctx aws.Context, input *eventbridge.PutTargetsInput, opts ...request.Option) (*eventbridge.PutTargetsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	out := &eventbridge.PutTargetsOutput{FailedEntryCount: aws.Int64(0)}
+	for _, t := range input.Targets {
+		if t.RoleArn == nil {
+			out.FailedEntryCount = aws.Int64(aws.Int64Value(out.FailedEntryCount) + 1)
+			out.FailedEntries = append(out.FailedEntries, &eventbridge.PutTargetsResultEntry{
+				TargetId:     t.Id,
+				ErrorCode:    aws.String("ValidationException"),
+				ErrorMessage: aws.String("RoleArn is required for target"),
+			})
+		}
+	}
+
+	return out, nil
+}
+
+func (m *mockEBClient) RemoveTargetsWithContext(ctx aws.Context, input *eventbridge.RemoveTargetsInput, opts ...request.Option) (*eventbridge.RemoveTargetsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &eventbridge.RemoveTargetsOutput{FailedEntryCount: aws.Int64(0)}, nil
+}
+
+func (m *mockEBClient) DeleteRuleWithContext(ctx aws.Context, input *eventbridge.DeleteRuleInput, opts ...request.Option) (*eventbridge.DeleteRuleOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &eventbridge.DeleteRuleOutput{}, nil
+}
+
+func TestEventBridge_CreateRule(t *testing.T) {
+	e := EventBridge{Service: newmockEBClient(t, nil)}
+
+	got, err := e.CreateRule(context.TODO(), &eventbridge.PutRuleInput{
+		Name:               aws.String("nightly-report"),
+		ScheduleExpression: aws.String("cron(0 2 * * ? *)"),
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if want := "arn:aws:events:us-east-1:1234567890:rule/nightly-report"; got != want {
+		t.Errorf("EventBridge.CreateRule() = %s, want %s", got, want)
+	}
+
+	_, err = e.CreateRule(context.TODO(), &eventbridge.PutRuleInput{})
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+		t.Errorf("expected bad request error, got %v", err)
+	}
+
+	e = EventBridge{Service: newmockEBClient(t, awserr.New(eventbridge.ErrCodeLimitExceededException, "too many rules", nil))}
+	_, err = e.CreateRule(context.TODO(), &eventbridge.PutRuleInput{Name: aws.String("nightly-report")})
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrLimitExceeded {
+		t.Errorf("expected limit exceeded error, got %v", err)
+	}
+}
+
+func TestEventBridge_PutTargets(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		targets  []*eventbridge.Target
+		wantCode string
+	}{
+		{
+			name:    "put target",
+			rule:    "nightly-report",
+			targets: []*eventbridge.Target{{Id: aws.String("task"), Arn: aws.String(testClusterArn), RoleArn: aws.String("arn:aws:iam::1234567890:role/events")}},
+		},
+		{
+			name:     "failed entry",
+			rule:     "nightly-report",
+			targets:  []*eventbridge.Target{{Id: aws.String("task"), Arn: aws.String(testClusterArn)}},
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "no targets",
+			rule:     "nightly-report",
+			wantCode: apierror.ErrBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := EventBridge{Service: newmockEBClient(t, nil)}
+
+			err := e.PutTargets(context.TODO(), tt.rule, tt.targets)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("expected nil error, got %s", err)
+				}
+				return
+			}
+
+			if aerr, ok := err.(apierror.Error); !ok || aerr.Code != tt.wantCode {
+				t.Errorf("expected error code %s, got %v", tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestEventBridge_RemoveTargetsAndDeleteRule(t *testing.T) {
+	e := EventBridge{Service: newmockEBClient(t, nil)}
+
+	if err := e.RemoveTargets(context.TODO(), "nightly-report", []string{"task"}); err != nil {
+		t.Errorf("expected nil error, got %s", err)
+	}
+
+	if err := e.DeleteRule(context.TODO(), "nightly-report"); err != nil {
+		t.Errorf("expected nil error, got %s", err)
+	}
+
+	if err := e.RemoveTargets(context.TODO(), "nightly-report", nil); err == nil {
+		t.Error("expected error removing no targets, got nil")
+	}
+
+	if err := e.DeleteRule(context.TODO(), ""); err == nil {
+		t.Error("expected error deleting empty rule, got nil")
+	}
+
+	e = EventBridge{Service: newmockEBClient(t, awserr.New(eventbridge.ErrCodeManagedRuleException, "managed", nil))}
+	if err := e.DeleteRule(context.TODO(), "nightly-report"); err == nil {
+		t.Error("expected error deleting managed rule, got nil")
+	} else if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrForbidden {
+		t.Errorf("expected forbidden error, got %v", err)
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"github.com/YaleSpinup/apierror"
 	"github.com/YaleSpinup/ecs-api/common"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
@@ -32,19 +30,22 @@ type PolicyDoc struct {
 type IAM struct {
 	Service         iamiface.IAMAPI
 	DefaultKmsKeyID string
+	// TaskExecutionTrustedServices are additional services trusted to assume task execution roles
+	TaskExecutionTrustedServices []string
+	// TaskExecutionTrustedPrincipals are AWS principals trusted to assume task execution roles
+	TaskExecutionTrustedPrincipals []string
 }
 
 // NewSession creates a new IAM session
 func NewSession(account common.Account) IAM {
 	i := IAM{}
 	log.Infof("creating new aws session for IAM with key id %s in region %s", account.Akid, account.Region)
-	sess := session.Must(session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(account.Akid, account.Secret, ""),
-		Region:      aws.String(account.Region),
-	}))
+	sess := session.Must(session.NewSession(account.AWSConfig()))
 
 	i.Service = iam.New(sess)
 	i.DefaultKmsKeyID = account.DefaultKmsKeyId
+	i.TaskExecutionTrustedServices = account.TaskExecutionTrustedServices
+	i.TaskExecutionTrustedPrincipals = account.TaskExecutionTrustedPrincipals
 
 	return i
 }
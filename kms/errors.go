@@ -0,0 +1,88 @@
+package kms
+
+import (
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/pkg/errors"
+)
+
+func ErrCode(msg string, err error) error {
+	if aerr, ok := errors.Cause(err).(awserr.Error); ok {
+		switch aerr.Code() {
+		case
+			// AccessDeniedException isn't modeled by the sdk, but is returned when
+			// the caller isn't allowed to use the key
+			"AccessDeniedException":
+
+			return apierror.New(apierror.ErrForbidden, msg, aerr)
+		case
+
+			// ErrCodeNotFoundException for service response error code
+			// "NotFoundException".
+			//
+			// The request was rejected because the specified entity or resource could
+			// not be found.
+			kms.ErrCodeNotFoundException:
+
+			return apierror.New(apierror.ErrNotFound, msg, aerr)
+		case
+
+			// ErrCodeDisabledException for service response error code
+			// "DisabledException".
+			//
+			// The request was rejected because the specified KMS key is not enabled.
+			kms.ErrCodeDisabledException,
+
+			// ErrCodeInvalidStateException for service response error code
+			// "KMSInvalidStateException".
+			//
+			// The request was rejected because the state of the specified resource is
+			// not valid for this request.
+			kms.ErrCodeInvalidStateException:
+
+			return apierror.New(apierror.ErrConflict, msg, aerr)
+		case
+
+			// ErrCodeLimitExceededException for service response error code
+			// "LimitExceededException".
+			//
+			// The request was rejected because a quota was exceeded.
+			kms.ErrCodeLimitExceededException:
+
+			return apierror.New(apierror.ErrLimitExceeded, msg, aerr)
+		case
+
+			// ErrCodeDependencyTimeoutException for service response error code
+			// "DependencyTimeoutException".
+			//
+			// The system timed out while trying to fulfill the request. You can retry
+			// the request.
+			kms.ErrCodeDependencyTimeoutException,
+
+			// ErrCodeInternalException for service response error code
+			// "KMSInternalException".
+			//
+			// The request was rejected because an internal exception occurred. The request
+			// can be retried.
+			kms.ErrCodeInternalException:
+
+			return apierror.New(apierror.ErrServiceUnavailable, msg, aerr)
+		case
+
+			// ErrCodeInvalidArnException for service response error code
+			// "InvalidArnException".
+			//
+			// The request was rejected because a specified ARN, or an ARN in a key policy,
+			// is not valid.
+			kms.ErrCodeInvalidArnException:
+
+			return apierror.New(apierror.ErrBadRequest, msg, aerr)
+		default:
+			m := msg + ": " + aerr.Message()
+			return apierror.New(apierror.ErrBadRequest, m, aerr)
+		}
+	}
+
+	return apierror.New(apierror.ErrInternalError, msg, err)
+}
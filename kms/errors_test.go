@@ -0,0 +1,43 @@
+package kms
+
+import (
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/pkg/errors"
+)
+
+func TestErrCode(t *testing.T) {
+	apiErrorTestCases := map[string]string{
+		"": apierror.ErrBadRequest,
+
+		"AccessDeniedException":               apierror.ErrForbidden,
+		kms.ErrCodeNotFoundException:          apierror.ErrNotFound,
+		kms.ErrCodeDisabledException:          apierror.ErrConflict,
+		kms.ErrCodeInvalidStateException:      apierror.ErrConflict,
+		kms.ErrCodeLimitExceededException:     apierror.ErrLimitExceeded,
+		kms.ErrCodeDependencyTimeoutException: apierror.ErrServiceUnavailable,
+		kms.ErrCodeInternalException:          apierror.ErrServiceUnavailable,
+		kms.ErrCodeInvalidArnException:        apierror.ErrBadRequest,
+	}
+
+	for awsErr, apiErr := range apiErrorTestCases {
+		err := ErrCode("test error", awserr.New(awsErr, awsErr, nil))
+		if aerr, ok := errors.Cause(err).(apierror.Error); ok {
+			if aerr.Code != apiErr {
+				t.Errorf("expected kms error %s to be an apierror %s, got %s", awsErr, apiErr, aerr.Code)
+			}
+		} else {
+			t.Errorf("expected kms error %s to be an apierror.Error %s, got %s", awsErr, apiErr, err)
+		}
+	}
+
+	err := ErrCode("test error", errors.New("Unknown"))
+	if aerr, ok := errors.Cause(err).(apierror.Error); ok {
+		t.Logf("got apierror '%s'", aerr)
+	} else {
+		t.Errorf("expected unknown error to be an apierror.ErrInternalError, got %s", err)
+	}
+}
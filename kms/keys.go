@@ -0,0 +1,34 @@
+package kms
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	log "github.com/sirupsen/logrus"
+)
+
+// KeyArn resolves a KMS key id, key ARN, alias name or alias ARN to the ARN of the key
+func (k *KMS) KeyArn(ctx context.Context, id string) (string, error) {
+	if id == "" {
+		return "", apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("describing kms key %s", id)
+
+	out, err := k.Service.DescribeKeyWithContext(ctx, &kms.DescribeKeyInput{KeyId: aws.String(id)})
+	if err != nil {
+		return "", ErrCode(fmt.Sprintf("failed to describe kms key %s", id), err)
+	}
+
+	if out.KeyMetadata == nil || aws.StringValue(out.KeyMetadata.Arn) == "" {
+		msg := fmt.Sprintf("unexpected empty response describing kms key %s", id)
+		return "", apierror.New(apierror.ErrInternalError, msg, nil)
+	}
+
+	log.Debugf("resolved kms key %s to %s", id, aws.StringValue(out.KeyMetadata.Arn))
+
+	return aws.StringValue(out.KeyMetadata.Arn), nil
+}
@@ -0,0 +1,121 @@
+package kms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+const testKeyArn = "arn:aws:kms:us-east-1:012345678901:key/0a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d"
+
+// testKeys maps the key ids and aliases known to the mock to the key ARN
+var testKeys = map[string]string{
+	"0a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d": testKeyArn,
+	testKeyArn:                             testKeyArn,
+	"alias/secrets":                        testKeyArn,
+	"arn:aws:kms:us-east-1:012345678901:alias/secrets": testKeyArn,
+}
+
+type mockKMSClient struct {
+	kmsiface.KMSAPI
+	t   *testing.T
+	err error
+}
+
+func newmockKMSClient(t *testing.T, err error) kmsiface.KMSAPI {
+	return &mockKMSClient{
+		t:   t,
+		err: err,
+	}
+}
+
+func (m *mockKMSClient) DescribeKeyWithContext(ctx aws.Context, input *kms.DescribeKeyInput, opts ...request.Option) (*kms.DescribeKeyOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	a, ok := testKeys[aws.StringValue(input.KeyId)]
+	if !ok {
+		return nil, awserr.New(kms.ErrCodeNotFoundException, "key not found", nil)
+	}
+
+	return &kms.DescribeKeyOutput{KeyMetadata: &kms.KeyMetadata{Arn: aws.String(a)}}, nil
+}
+
+func TestKMS_KeyArn(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		err      error
+		want     string
+		wantCode string
+	}{
+		{
+			name: "key id",
+			id:   "0a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d",
+			want: testKeyArn,
+		},
+		{
+			name: "key arn",
+			id:   testKeyArn,
+			want: testKeyArn,
+		},
+		{
+			name: "alias name",
+			id:   "alias/secrets",
+			want: testKeyArn,
+		},
+		{
+			name: "alias arn",
+			id:   "arn:aws:kms:us-east-1:012345678901:alias/secrets",
+			want: testKeyArn,
+		},
+		{
+			name:     "empty id",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "unknown key",
+			id:       "alias/nope",
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "kms error",
+			id:       "alias/secrets",
+			err:      awserr.New("AccessDeniedException", "denied", nil),
+			wantCode: apierror.ErrForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := KMS{Service: newmockKMSClient(t, tt.err)}
+
+			got, err := k.KeyArn(context.TODO(), tt.id)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("KMS.KeyArn() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package kms
+
+import (
+	"github.com/YaleSpinup/ecs-api/common"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	log "github.com/sirupsen/logrus"
+)
+
+// KMS is a wrapper around the aws kms service
+type KMS struct {
+	Service kmsiface.KMSAPI
+}
+
+// NewSession creates a new kms session
+func NewSession(account common.Account) KMS {
+	k := KMS{}
+	log.Infof("creating new aws session for kms with key id %s in region %s", account.Akid, account.Region)
+	sess := session.Must(session.NewSession(account.AWSConfig()))
+	k.Service = kms.New(sess)
+	return k
+}
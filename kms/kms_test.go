@@ -0,0 +1,16 @@
+package kms
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/YaleSpinup/ecs-api/common"
+)
+
+func TestNewSession(t *testing.T) {
+	k := NewSession(common.Account{})
+	to := reflect.TypeOf(k).String()
+	if to != "kms.KMS" {
+		t.Errorf("expected type to be 'kms.KMS', got %s", to)
+	}
+}
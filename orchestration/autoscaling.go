@@ -0,0 +1,59 @@
+package orchestration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	log "github.com/sirupsen/logrus"
+)
+
+// deleteServiceScaling deletes the scaling policies and deregisters the scalable targets for a service so they
+// (and their cloudwatch alarms) aren't orphaned when the service is deleted.  Policies and targets that are already
+// gone are ignored.
+func (o *Orchestrator) deleteServiceScaling(ctx context.Context, cluster, service string) error {
+	resourceID := fmt.Sprintf("service/%s/%s", cluster, service)
+
+	policies, err := o.ApplicationAutoScaling.ListScalingPolicies(ctx, applicationautoscaling.ServiceNamespaceEcs, resourceID)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range policies {
+		if err := o.ApplicationAutoScaling.DeleteScalingPolicy(ctx, &applicationautoscaling.DeleteScalingPolicyInput{
+			PolicyName:        p.PolicyName,
+			ResourceId:        p.ResourceId,
+			ScalableDimension: p.ScalableDimension,
+			ServiceNamespace:  p.ServiceNamespace,
+		}); err != nil {
+			if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+				return err
+			}
+		}
+
+		log.Infof("deleted scaling policy %s for %s", aws.StringValue(p.PolicyName), resourceID)
+	}
+
+	targets, err := o.ApplicationAutoScaling.ListScalableTargets(ctx, applicationautoscaling.ServiceNamespaceEcs, resourceID)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		if err := o.ApplicationAutoScaling.DeregisterScalableTarget(ctx, &applicationautoscaling.DeregisterScalableTargetInput{
+			ResourceId:        t.ResourceId,
+			ScalableDimension: t.ScalableDimension,
+			ServiceNamespace:  t.ServiceNamespace,
+		}); err != nil {
+			if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+				return err
+			}
+		}
+
+		log.Infof("deregistered scalable target %s for %s", aws.StringValue(t.ScalableDimension), resourceID)
+	}
+
+	return nil
+}
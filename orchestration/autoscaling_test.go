@@ -0,0 +1,167 @@
+package orchestration
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+var testScalingPolicies = map[string][]*applicationautoscaling.ScalingPolicy{
+	"service/clu1/svc1": {
+		{
+			PolicyName:        aws.String("svc1-cpu"),
+			ResourceId:        aws.String("service/clu1/svc1"),
+			ScalableDimension: aws.String("ecs:service:DesiredCount"),
+			ServiceNamespace:  aws.String("ecs"),
+		},
+		{
+			PolicyName:        aws.String("svc1-memory"),
+			ResourceId:        aws.String("service/clu1/svc1"),
+			ScalableDimension: aws.String("ecs:service:DesiredCount"),
+			ServiceNamespace:  aws.String("ecs"),
+		},
+	},
+}
+
+var testScalableTargets = map[string][]*applicationautoscaling.ScalableTarget{
+	"service/clu1/svc1": {
+		{
+			ResourceId:        aws.String("service/clu1/svc1"),
+			ScalableDimension: aws.String("ecs:service:DesiredCount"),
+			ServiceNamespace:  aws.String("ecs"),
+		},
+	},
+}
+
+func (m *mockAASClient) DescribeScalingPoliciesPagesWithContext(ctx aws.Context, input *applicationautoscaling.DescribeScalingPoliciesInput, fn func(*applicationautoscaling.DescribeScalingPoliciesOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	fn(&applicationautoscaling.DescribeScalingPoliciesOutput{ScalingPolicies: testScalingPolicies[aws.StringValue(input.ResourceId)]}, true)
+	return nil
+}
+
+func (m *mockAASClient) DescribeScalableTargetsPagesWithContext(ctx aws.Context, input *applicationautoscaling.DescribeScalableTargetsInput, fn func(*applicationautoscaling.DescribeScalableTargetsOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	targets := []*applicationautoscaling.ScalableTarget{}
+	for _, id := range input.ResourceIds {
+		targets = append(targets, testScalableTargets[aws.StringValue(id)]...)
+	}
+
+	fn(&applicationautoscaling.DescribeScalableTargetsOutput{ScalableTargets: targets}, true)
+	return nil
+}
+
+func (m *mockAASClient) DeleteScalingPolicyWithContext(ctx aws.Context, input *applicationautoscaling.DeleteScalingPolicyInput, opts ...request.Option) (*applicationautoscaling.DeleteScalingPolicyOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	m.deletedPolicies = append(m.deletedPolicies, aws.StringValue(input.PolicyName))
+	return &applicationautoscaling.DeleteScalingPolicyOutput{}, nil
+}
+
+func (m *mockAASClient) DeregisterScalableTargetWithContext(ctx aws.Context, input *applicationautoscaling.DeregisterScalableTargetInput, opts ...request.Option) (*applicationautoscaling.DeregisterScalableTargetOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	// the target is already gone once it's been deregistered
+	for _, d := range m.deregisteredTargets {
+		if d == aws.StringValue(input.ResourceId) {
+			return nil, awserr.New(applicationautoscaling.ErrCodeObjectNotFoundException, "not found", nil)
+		}
+	}
+
+	m.deregisteredTargets = append(m.deregisteredTargets, aws.StringValue(input.ResourceId))
+	return &applicationautoscaling.DeregisterScalableTargetOutput{}, nil
+}
+
+func TestOrchestrator_deleteServiceScaling(t *testing.T) {
+	tests := []struct {
+		name             string
+		service          string
+		aaserr           error
+		wantPolicies     []string
+		wantDeregistered []string
+		wantErr          bool
+	}{
+		{
+			name:             "service with autoscaling",
+			service:          "svc1",
+			wantPolicies:     []string{"svc1-cpu", "svc1-memory"},
+			wantDeregistered: []string{"service/clu1/svc1"},
+		},
+		{
+			name:    "service without autoscaling",
+			service: "svc2",
+		},
+		{
+			name:    "application autoscaling error",
+			service: "svc1",
+			aaserr:  awserr.New(applicationautoscaling.ErrCodeInternalServiceException, "boom", nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			m := &mockAASClient{t: t, err: tt.aaserr}
+			o.ApplicationAutoScaling.Service = m
+
+			if err := o.deleteServiceScaling(context.TODO(), "clu1", tt.service); (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.deleteServiceScaling() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(m.deletedPolicies, tt.wantPolicies) {
+				t.Errorf("expected deleted policies %v, got %v", tt.wantPolicies, m.deletedPolicies)
+			}
+
+			if !reflect.DeepEqual(m.deregisteredTargets, tt.wantDeregistered) {
+				t.Errorf("expected deregistered targets %v, got %v", tt.wantDeregistered, m.deregisteredTargets)
+			}
+		})
+	}
+
+	t.Run("already deregistered target", func(t *testing.T) {
+		o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+		m := &mockAASClient{t: t, deregisteredTargets: []string{"service/clu1/svc1"}}
+		o.ApplicationAutoScaling.Service = m
+
+		if err := o.deleteServiceScaling(context.TODO(), "clu1", "svc1"); err != nil {
+			t.Errorf("expected nil error for an already deregistered target, got %s", err)
+		}
+	})
+}
+
+func TestOrchestrator_deleteServiceDependencies(t *testing.T) {
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	m := &mockAASClient{t: t}
+	o.ApplicationAutoScaling.Service = m
+
+	o.deleteServiceDependencies(context.TODO(), "clu1", &ecs.Service{
+		ClusterArn:     aws.String("arn:aws:ecs:us-east-1:0123456789:cluster/clu1"),
+		ServiceArn:     aws.String("arn:aws:ecs:us-east-1:0123456789:service/clu1/svc1"),
+		ServiceName:    aws.String("svc1"),
+		TaskDefinition: aws.String("missing:1"),
+	})
+
+	if want := []string{"svc1-cpu", "svc1-memory"}; !reflect.DeepEqual(m.deletedPolicies, want) {
+		t.Errorf("expected recursive delete to delete scaling policies %v, got %v", want, m.deletedPolicies)
+	}
+
+	if want := []string{"service/clu1/svc1"}; !reflect.DeepEqual(m.deregisteredTargets, want) {
+		t.Errorf("expected recursive delete to deregister scalable targets %v, got %v", want, m.deregisteredTargets)
+	}
+}
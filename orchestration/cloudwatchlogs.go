@@ -2,44 +2,255 @@ package orchestration
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
+	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	log "github.com/sirupsen/logrus"
 )
 
+// LogGroupName returns the name of the default log group for a task definition family in a cluster.  The {org}, {cluster}
+// and {family} placeholders in the pattern are replaced, DefaultLogGroupNamePattern is used if the pattern is empty.
+func LogGroupName(pattern, org, cluster, family string) string {
+	if pattern == "" {
+		pattern = DefaultLogGroupNamePattern
+	}
+
+	return strings.NewReplacer("{org}", org, "{cluster}", cluster, "{family}", family).Replace(pattern)
+}
+
+// logGroupName returns the name of the default log group for a task definition family in a cluster
+func (o *Orchestrator) logGroupName(cluster, family string) string {
+	return LogGroupName(o.AwslogsGroupNamePattern, o.Org, cluster, family)
+}
+
 // cloudwatchLogGroups collects all of the log group arns for the passed container definitions
 func (o *Orchestrator) cloudwatchLogGroups(ctx context.Context, containerDefs []*ecs.ContainerDefinition) ([]string, error) {
 	logGroupArns := []string{}
-	logGroupNames := map[string]struct{}{}
+
+	for _, lgn := range containerLogGroupNames(containerDefs) {
+		lg, err := o.CloudWatchLogs.GetLogGroup(ctx, lgn)
+		if err != nil {
+			log.Errorf("failed to get details about log group")
+			continue
+		}
+
+		// log group ARNs are returned with a :* on the end, remove it if it exists
+		cleanArn := strings.TrimSuffix(aws.StringValue(lg.Arn), ":*")
+		logGroupArns = append(logGroupArns, cleanArn)
+	}
+
+	return logGroupArns, nil
+}
+
+// containerLogGroup is an awslogs log group used by a container definition and the region it's in
+type containerLogGroup struct {
+	name   string
+	region string
+}
+
+// containerLogGroups collects the unique awslogs log groups for the passed container definitions.  Containers using
+// another log driver are skipped and the region defaults to the passed region if the awslogs-region isn't set.
+func containerLogGroups(containerDefs []*ecs.ContainerDefinition, region string) []containerLogGroup {
+	groups := []containerLogGroup{}
+	seen := map[containerLogGroup]struct{}{}
 
 	for _, cd := range containerDefs {
-		if cd.LogConfiguration != nil {
-			logGroupName, ok := cd.LogConfiguration.Options["awslogs-group"]
-			if !ok {
-				continue
-			}
+		if cd.LogConfiguration == nil || aws.StringValue(cd.LogConfiguration.LogDriver) != "awslogs" {
+			continue
+		}
 
-			lgn := aws.StringValue(logGroupName)
+		lg := containerLogGroup{
+			name:   aws.StringValue(cd.LogConfiguration.Options["awslogs-group"]),
+			region: aws.StringValue(cd.LogConfiguration.Options["awslogs-region"]),
+		}
 
-			// if the log group has already been fetched, skip it
-			if _, ok := logGroupNames[lgn]; ok {
-				continue
-			}
-			logGroupNames[lgn] = struct{}{}
+		if lg.name == "" {
+			continue
+		}
+
+		if lg.region == "" {
+			lg.region = region
+		}
+
+		// if the log group has already been collected, skip it
+		if _, ok := seen[lg]; ok {
+			continue
+		}
+		seen[lg] = struct{}{}
+
+		groups = append(groups, lg)
+	}
+
+	return groups
+}
+
+// containerLogGroupNames collects the unique awslogs log group names for the passed container definitions
+func containerLogGroupNames(containerDefs []*ecs.ContainerDefinition) []string {
+	names := []string{}
+	seen := map[string]struct{}{}
+
+	for _, lg := range containerLogGroups(containerDefs, "") {
+		if _, ok := seen[lg.name]; ok {
+			continue
+		}
+		seen[lg.name] = struct{}{}
+
+		names = append(names, lg.name)
+	}
+
+	return names
+}
+
+// ServiceLogConfiguration is the awslogs log configuration of a container in the task definition of a service
+type ServiceLogConfiguration struct {
+	Container    string
+	LogGroup     string
+	StreamPrefix string
+	Region       string
+}
+
+// LogStream returns the name of the awslogs log stream of the container in a task, {prefix}/{container}/{task}
+func (c *ServiceLogConfiguration) LogStream(task string) string {
+	return fmt.Sprintf("%s/%s/%s", c.StreamPrefix, c.Container, task)
+}
+
+// ServiceContainerLogConfiguration returns the awslogs log group and stream prefix of a container in the task definition
+// of a service.  If the container is empty, the first container using the awslogs driver is used.  Log groups in a region
+// other than the region of the account's cloudwatch logs session can't be read and are rejected.
+func (o *Orchestrator) ServiceContainerLogConfiguration(ctx context.Context, cluster, service, container string) (*ServiceLogConfiguration, error) {
+	if cluster == "" || service == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "cluster and service are required", nil)
+	}
+
+	svc, err := o.ECS.GetService(ctx, cluster, service)
+	if err != nil {
+		return nil, err
+	}
 
-			lg, err := o.CloudWatchLogs.GetLogGroup(ctx, lgn)
-			if err != nil {
-				log.Errorf("failed to get details about log group")
+	td, _, err := o.ECS.GetTaskDefinition(ctx, svc.TaskDefinition, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cd := range td.ContainerDefinitions {
+		name := aws.StringValue(cd.Name)
+		if container != "" && name != container {
+			continue
+		}
+
+		lc := cd.LogConfiguration
+		if lc == nil || aws.StringValue(lc.LogDriver) != "awslogs" || aws.StringValue(lc.Options["awslogs-group"]) == "" {
+			if container == "" {
 				continue
 			}
 
-			// log group ARNs are returned with a :* on the end, remove it if it exists
-			cleanArn := strings.TrimSuffix(aws.StringValue(lg.Arn), ":*")
-			logGroupArns = append(logGroupArns, cleanArn)
+			msg := fmt.Sprintf("container %s in service %s/%s doesn't log to an awslogs log group", container, cluster, service)
+			return nil, apierror.New(apierror.ErrNotFound, msg, nil)
+		}
+
+		config := &ServiceLogConfiguration{
+			Container:    name,
+			LogGroup:     aws.StringValue(lc.Options["awslogs-group"]),
+			StreamPrefix: aws.StringValue(lc.Options["awslogs-stream-prefix"]),
+			Region:       o.awslogsRegion(),
+		}
+
+		if region := aws.StringValue(lc.Options["awslogs-region"]); region != "" && region != config.Region {
+			msg := fmt.Sprintf("log group %s of container %s is in region %s, only %s is supported", config.LogGroup, name, region, config.Region)
+			return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
 		}
+
+		return config, nil
 	}
 
-	return logGroupArns, nil
+	if container != "" {
+		msg := fmt.Sprintf("container %s not found in service %s/%s", container, cluster, service)
+		return nil, apierror.New(apierror.ErrNotFound, msg, nil)
+	}
+
+	msg := fmt.Sprintf("no containers in service %s/%s log to an awslogs log group", cluster, service)
+	return nil, apierror.New(apierror.ErrNotFound, msg, nil)
+}
+
+// ReconcileServiceLogGroupsOutput is the output of reconciling the log groups of a service
+type ReconcileServiceLogGroupsOutput struct {
+	// Recreated are the names of the log groups that were missing and have been recreated
+	Recreated []string
+	// Skipped are the names of the log groups in another region than the account's cloudwatch logs session, they
+	// can't be checked or recreated
+	Skipped []string
+}
+
+// ReconcileServiceLogGroups recreates any of the awslogs log groups used by the containers of a service that no longer exist,
+// with the default retention and the tags of the service's task definition.  Containers using another log driver are ignored
+// and log groups in another region are reported as skipped.
+func (o *Orchestrator) ReconcileServiceLogGroups(ctx context.Context, cluster, service string) (*ReconcileServiceLogGroupsOutput, error) {
+	if cluster == "" || service == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "cluster and service are required", nil)
+	}
+
+	svc, err := o.ECS.GetService(ctx, cluster, service)
+	if err != nil {
+		return nil, err
+	}
+
+	td, tags, err := o.ECS.GetTaskDefinition(ctx, svc.TaskDefinition, true)
+	if err != nil {
+		return nil, err
+	}
+
+	tagsMap := make(map[string]*string, len(tags))
+	for _, t := range tags {
+		tagsMap[aws.StringValue(t.Key)] = t.Value
+	}
+
+	output := &ReconcileServiceLogGroupsOutput{
+		Recreated: []string{},
+		Skipped:   []string{},
+	}
+
+	region := o.awslogsRegion()
+	for _, lg := range containerLogGroups(td.ContainerDefinitions, region) {
+		lgn := lg.name
+
+		if lg.region != region {
+			log.Warnf("log group %s for service %s/%s is in region %s, not %s, skipping", lgn, cluster, service, lg.region, region)
+			output.Skipped = append(output.Skipped, lgn)
+			continue
+		}
+
+		exists, err := o.CloudWatchLogs.LogGroupExists(ctx, lgn)
+		if err != nil {
+			return output, err
+		}
+
+		if exists {
+			log.Debugf("log group %s for service %s/%s exists", lgn, cluster, service)
+			continue
+		}
+
+		log.Warnf("log group %s for service %s/%s is missing, recreating", lgn, cluster, service)
+
+		if err := o.CloudWatchLogs.CreateLogGroup(ctx, &cloudwatchlogs.CreateLogGroupInput{
+			LogGroupName: aws.String(lgn),
+			Tags:         tagsMap,
+		}); err != nil {
+			return output, err
+		}
+
+		if err := o.CloudWatchLogs.UpdateRetention(ctx, &cloudwatchlogs.PutRetentionPolicyInput{
+			LogGroupName:    aws.String(lgn),
+			RetentionInDays: DefaultCloudwatchLogsRetention,
+		}); err != nil {
+			return output, err
+		}
+
+		output.Recreated = append(output.Recreated, lgn)
+	}
+
+	return output, nil
 }
@@ -0,0 +1,195 @@
+package orchestration
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func (m *mockCWLClient) DescribeLogGroupsPagesWithContext(ctx context.Context, input *cloudwatchlogs.DescribeLogGroupsInput, fn func(*cloudwatchlogs.DescribeLogGroupsOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	out := &cloudwatchlogs.DescribeLogGroupsOutput{}
+	for _, lg := range m.logGroups {
+		if strings.HasPrefix(lg, aws.StringValue(input.LogGroupNamePrefix)) {
+			out.LogGroups = append(out.LogGroups, &cloudwatchlogs.LogGroup{LogGroupName: aws.String(lg)})
+		}
+	}
+
+	fn(out, true)
+	return nil
+}
+
+func (m *mockCWLClient) DescribeLogGroupsWithContext(ctx context.Context, input *cloudwatchlogs.DescribeLogGroupsInput, opts ...request.Option) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	out := &cloudwatchlogs.DescribeLogGroupsOutput{}
+	for _, lg := range m.logGroups {
+		if strings.HasPrefix(lg, aws.StringValue(input.LogGroupNamePrefix)) {
+			out.LogGroups = append(out.LogGroups, &cloudwatchlogs.LogGroup{
+				Arn:          aws.String("arn:aws:logs:us-east-1:0123456789:log-group:" + lg + ":*"),
+				LogGroupName: aws.String(lg),
+			})
+		}
+	}
+
+	return out, nil
+}
+
+func Test_containerLogGroupNames(t *testing.T) {
+	awslogs := func(name string) *ecs.LogConfiguration {
+		return &ecs.LogConfiguration{
+			LogDriver: aws.String("awslogs"),
+			Options:   map[string]*string{"awslogs-group": aws.String(name)},
+		}
+	}
+
+	got := containerLogGroupNames([]*ecs.ContainerDefinition{
+		{Name: aws.String("web"), LogConfiguration: awslogs("clu1")},
+		{Name: aws.String("nolog")},
+		{Name: aws.String("splunk"), LogConfiguration: &ecs.LogConfiguration{
+			LogDriver: aws.String("splunk"),
+			Options:   map[string]*string{"awslogs-group": aws.String("clu1-splunk")},
+		}},
+		{Name: aws.String("worker"), LogConfiguration: awslogs("clu1")},
+		{Name: aws.String("sidecar"), LogConfiguration: awslogs("clu1-sidecar")},
+	})
+
+	if want := []string{"clu1", "clu1-sidecar"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("containerLogGroupNames() = %v, want %v", got, want)
+	}
+}
+
+func TestOrchestrator_ReconcileServiceLogGroups(t *testing.T) {
+	tests := []struct {
+		name          string
+		service       string
+		logGroups     []string
+		cwlerr        error
+		want          *ReconcileServiceLogGroupsOutput
+		wantLogGroups []string
+		wantErr       bool
+	}{
+		{
+			name:          "all log groups present",
+			service:       "logged",
+			logGroups:     []string{"clu1", "clu1-sidecar"},
+			want:          &ReconcileServiceLogGroupsOutput{Recreated: []string{}, Skipped: []string{"clu1-west"}},
+			wantLogGroups: []string{"clu1", "clu1-sidecar"},
+		},
+		{
+			name:          "missing log group is recreated",
+			service:       "logged",
+			logGroups:     []string{"clu1-sidecar"},
+			want:          &ReconcileServiceLogGroupsOutput{Recreated: []string{"clu1"}, Skipped: []string{"clu1-west"}},
+			wantLogGroups: []string{"clu1-sidecar", "clu1"},
+		},
+		{
+			name:          "only a prefix match",
+			service:       "logged",
+			logGroups:     []string{"clu1-sidecar", "clu1-sidecar-old"},
+			want:          &ReconcileServiceLogGroupsOutput{Recreated: []string{"clu1"}, Skipped: []string{"clu1-west"}},
+			wantLogGroups: []string{"clu1-sidecar", "clu1-sidecar-old", "clu1"},
+		},
+		{
+			name:    "missing service",
+			service: "missing",
+			wantErr: true,
+		},
+		{
+			name:    "cloudwatch logs error",
+			service: "logged",
+			cwlerr:  awserr.New(cloudwatchlogs.ErrCodeServiceUnavailableException, "boom", nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			m := &mockCWLClient{t: t, err: tt.cwlerr, logGroups: tt.logGroups}
+			o.CloudWatchLogs.Service = m
+
+			got, err := o.ReconcileServiceLogGroups(context.TODO(), "clu1", tt.service)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.ReconcileServiceLogGroups() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.ReconcileServiceLogGroups() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(m.logGroups, tt.wantLogGroups) {
+				t.Errorf("expected log groups %v, got %v", tt.wantLogGroups, m.logGroups)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ServiceContainerLogConfiguration(t *testing.T) {
+	tests := []struct {
+		name      string
+		service   string
+		container string
+		want      *ServiceLogConfiguration
+		wantErr   bool
+	}{
+		{
+			name:    "first awslogs container",
+			service: "logged",
+			want:    &ServiceLogConfiguration{Container: "web", LogGroup: "clu1", Region: "us-east-1"},
+		},
+		{
+			name:      "container with its own log group",
+			service:   "logged",
+			container: "sidecar",
+			want:      &ServiceLogConfiguration{Container: "sidecar", LogGroup: "clu1-sidecar", Region: "us-east-1"},
+		},
+		{
+			name:      "container without a log configuration",
+			service:   "logged",
+			container: "nolog",
+			wantErr:   true,
+		},
+		{
+			name:      "missing container",
+			service:   "logged",
+			container: "missing",
+			wantErr:   true,
+		},
+		{
+			name:    "missing service",
+			service: "missing",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+			got, err := o.ServiceContainerLogConfiguration(context.TODO(), "clu1", tt.service, tt.container)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Orchestrator.ServiceContainerLogConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.ServiceContainerLogConfiguration() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
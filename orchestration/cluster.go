@@ -3,10 +3,16 @@ package orchestration
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/YaleSpinup/apierror"
+	ecsapi "github.com/YaleSpinup/ecs-api/ecs"
+	"github.com/YaleSpinup/ecs-api/resourcegroupstaggingapi"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
 
 	"github.com/aws/aws-sdk-go/service/ecs"
 	log "github.com/sirupsen/logrus"
@@ -102,6 +108,9 @@ func (o *Orchestrator) createCluster(ctx context.Context, input *ecs.CreateClust
 }
 
 func (o *Orchestrator) deleteCluster(ctx context.Context, arn *string) (bool, error) {
+	// make sure we're looking at the current active services count
+	o.ECS.InvalidateCluster(aws.StringValue(arn))
+
 	cluster, err := o.ECS.GetCluster(ctx, arn)
 	if err != nil {
 		return false, err
@@ -126,7 +135,7 @@ func (o *Orchestrator) deleteCluster(ctx context.Context, arn *string) (bool, er
 		return false, nil
 	}
 
-	cluCtx, cluCancel := context.WithTimeout(ctx, 120*time.Second)
+	cluCtx, cluCancel := context.WithTimeout(ctx, o.deleteTimeout())
 	defer cluCancel()
 
 	cluChan := o.ECS.DeleteClusterWithRetry(cluCtx, arn)
@@ -141,3 +150,131 @@ func (o *Orchestrator) deleteCluster(ctx context.Context, arn *string) (bool, er
 
 	return true, nil
 }
+
+// ListClusters gets the sorted names of the clusters in the org and the allowed orgs using tags
+func (o *Orchestrator) ListClusters(ctx context.Context) ([]string, error) {
+	log.Infof("listing clusters in orgs '%s'", strings.Join(o.orgs(), ", "))
+
+	clusterArns, err := o.ResourceGroupsTaggingAPI.GetResourcesWithTags(ctx, []string{"ecs:cluster"}, []*resourcegroupstaggingapi.TagFilter{
+		{
+			Key:   "spinup:org",
+			Value: o.orgs(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := make([]string, 0, len(clusterArns))
+	for _, c := range clusterArns {
+		clusterArn, err := arn.Parse(c)
+		if err != nil {
+			log.Warnf("failed to parse cluster ARN %s: %s", c, err)
+			continue
+		}
+
+		clusters = append(clusters, strings.TrimPrefix(clusterArn.Resource, "cluster/"))
+	}
+
+	sort.Strings(clusters)
+
+	return clusters, nil
+}
+
+// ClusterSummaryOutput summarizes the resources used by a cluster
+type ClusterSummaryOutput struct {
+	Cluster      string
+	Services     int
+	RunningTasks int
+	PendingTasks int
+	// Cpu and Memory are the summed cpu units and MiB requested by the running tasks
+	Cpu    int64
+	Memory int64
+}
+
+// ClusterSummary counts the services and tasks in a cluster and sums the cpu and memory requested by the running tasks
+func (o *Orchestrator) ClusterSummary(ctx context.Context, cluster string) (*ClusterSummaryOutput, error) {
+	if cluster == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "cluster is required", nil)
+	}
+
+	services, err := o.ECS.ListServices(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	// tasks with a desired status of RUNNING include the tasks that are still starting
+	tasks, err := o.ECS.ListClusterTasks(ctx, cluster, "RUNNING")
+	if err != nil {
+		return nil, err
+	}
+
+	output := &ClusterSummaryOutput{
+		Cluster:  cluster,
+		Services: len(services),
+	}
+
+	for i := 0; i < len(tasks); i += ecsapi.DescribeTasksBatchSize {
+		end := i + ecsapi.DescribeTasksBatchSize
+		if end > len(tasks) {
+			end = len(tasks)
+		}
+
+		out, err := o.ECS.GetTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(cluster),
+			Tasks:   aws.StringSlice(tasks[i:end]),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range out.Tasks {
+			if aws.StringValue(t.LastStatus) != "RUNNING" {
+				output.PendingTasks++
+				continue
+			}
+
+			output.RunningTasks++
+			output.Cpu += taskResourceValue(t.TaskArn, "cpu", t.Cpu)
+			output.Memory += taskResourceValue(t.TaskArn, "memory", t.Memory)
+		}
+	}
+
+	return output, nil
+}
+
+// taskResourceValue parses the cpu or memory value of a task, values that can't be parsed are logged and counted as 0
+func taskResourceValue(taskArn *string, resource string, value *string) int64 {
+	if value == nil {
+		return 0
+	}
+
+	v, err := strconv.ParseInt(aws.StringValue(value), 10, 64)
+	if err != nil {
+		log.Warnf("unable to parse %s value %s of task %s: %s", resource, aws.StringValue(value), aws.StringValue(taskArn), err)
+		return 0
+	}
+
+	return v
+}
+
+// validateCapacityProviderStrategy ensures each capacity provider in the strategy is attached to the cluster
+func validateCapacityProviderStrategy(cluster *ecs.Cluster, strategy []*ecs.CapacityProviderStrategyItem) error {
+	attached := make(map[string]struct{}, len(cluster.CapacityProviders))
+	for _, cp := range cluster.CapacityProviders {
+		attached[aws.StringValue(cp)] = struct{}{}
+	}
+
+	for _, s := range strategy {
+		if s == nil || aws.StringValue(s.CapacityProvider) == "" {
+			return apierror.New(apierror.ErrBadRequest, "capacity provider strategy items must have a capacity provider", nil)
+		}
+
+		if _, ok := attached[aws.StringValue(s.CapacityProvider)]; !ok {
+			msg := fmt.Sprintf("capacity provider %s is not attached to cluster %s", aws.StringValue(s.CapacityProvider), aws.StringValue(cluster.ClusterName))
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	return nil
+}
@@ -3,13 +3,19 @@ package orchestration
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
 )
 
 var testClusters = []*ecs.Cluster{
@@ -111,6 +117,16 @@ var testClusters = []*ecs.Cluster{
 			},
 		},
 	},
+	{
+		ActiveServicesCount:               aws.Int64(0),
+		CapacityProviders:                 []*string{aws.String("FARGATE")},
+		ClusterArn:                        aws.String("arn:aws:ecs:us-east-1:1234567890:cluster/cluster4"),
+		ClusterName:                       aws.String("cluster4"),
+		PendingTasksCount:                 aws.Int64(0),
+		RegisteredContainerInstancesCount: aws.Int64(0),
+		RunningTasksCount:                 aws.Int64(1),
+		Status:                            aws.String("ACTIVE"),
+	},
 }
 
 func (m *mockECSClient) CreateClusterWithContext(ctx context.Context, input *ecs.CreateClusterInput, opts ...request.Option) (*ecs.CreateClusterOutput, error) {
@@ -148,6 +164,159 @@ func (m *mockECSClient) DescribeClustersWithContext(ctx aws.Context, input *ecs.
 	return &ecs.DescribeClustersOutput{Clusters: clusters}, nil
 }
 
+func (m *mockECSClient) DeleteClusterWithContext(ctx aws.Context, input *ecs.DeleteClusterInput, opts ...request.Option) (*ecs.DeleteClusterOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	for _, cluster := range testClusters {
+		if aws.StringValue(input.Cluster) == aws.StringValue(cluster.ClusterName) || aws.StringValue(input.Cluster) == aws.StringValue(cluster.ClusterArn) {
+			if aws.Int64Value(cluster.RunningTasksCount) > 0 {
+				return nil, awserr.New(ecs.ErrCodeClusterContainsTasksException, "cluster contains tasks", nil)
+			}
+
+			return &ecs.DeleteClusterOutput{Cluster: cluster}, nil
+		}
+	}
+
+	return nil, awserr.New(ecs.ErrCodeClusterNotFoundException, "cluster not found", nil)
+}
+
+func (m *mockRGTAClient) GetResourcesWithContext(ctx aws.Context, input *resourcegroupstaggingapi.GetResourcesInput, opts ...request.Option) (*resourcegroupstaggingapi.GetResourcesOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	output := &resourcegroupstaggingapi.GetResourcesOutput{}
+	for _, r := range m.resources {
+		output.ResourceTagMappingList = append(output.ResourceTagMappingList, &resourcegroupstaggingapi.ResourceTagMapping{ResourceARN: aws.String(r)})
+	}
+
+	for r, tags := range m.tagged {
+		if !resourceMatchesFilters(r, tags, input) {
+			continue
+		}
+		output.ResourceTagMappingList = append(output.ResourceTagMappingList, &resourcegroupstaggingapi.ResourceTagMapping{ResourceARN: aws.String(r)})
+	}
+
+	return output, nil
+}
+
+// resourceMatchesFilters returns true if the resource ARN matches one of the resource type filters (ie. ecs:cluster) and
+// its tags match all of the tag filters
+func resourceMatchesFilters(resource string, tags map[string]string, input *resourcegroupstaggingapi.GetResourcesInput) bool {
+	if len(input.ResourceTypeFilters) > 0 {
+		typeMatch := false
+		for _, t := range aws.StringValueSlice(input.ResourceTypeFilters) {
+			parts := strings.SplitN(t, ":", 2)
+			if strings.Contains(resource, ":"+parts[0]+":") && (len(parts) == 1 || strings.Contains(resource, ":"+parts[1]+"/")) {
+				typeMatch = true
+				break
+			}
+		}
+
+		if !typeMatch {
+			return false
+		}
+	}
+
+	for _, f := range input.TagFilters {
+		value, ok := tags[aws.StringValue(f.Key)]
+		if !ok {
+			return false
+		}
+
+		if len(f.Values) == 0 {
+			continue
+		}
+
+		valueMatch := false
+		for _, v := range aws.StringValueSlice(f.Values) {
+			if v == value {
+				valueMatch = true
+				break
+			}
+		}
+
+		if !valueMatch {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestOrchestrator_ListClusters(t *testing.T) {
+	t.Log("testing ListClusters")
+
+	tagged := map[string]map[string]string{
+		"arn:aws:ecs:us-east-1:0123456789:cluster/myclu2":   {"spinup:org": "myorg", "spinup:spaceid": "myclu2"},
+		"arn:aws:ecs:us-east-1:0123456789:cluster/myclu1":   {"spinup:org": "myorg", "spinup:spaceid": "myclu1"},
+		"arn:aws:ecs:us-east-1:0123456789:cluster/other1":   {"spinup:org": "otherorg", "spinup:spaceid": "other1"},
+		"arn:aws:ecs:us-east-1:0123456789:cluster/untagged": {},
+		// resources of other types in the org are not clusters
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/myclu1-webapp:1": {"spinup:org": "myorg", "spinup:spaceid": "myclu1"},
+		"arn:aws:ecs:us-east-1:0123456789:service/myclu1/webapp":           {"spinup:org": "myorg", "spinup:spaceid": "myclu1"},
+	}
+
+	tests := []struct {
+		name        string
+		org         string
+		allowedOrgs []string
+		rgtaerr     error
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name: "clusters in org",
+			org:  "myorg",
+			want: []string{"myclu1", "myclu2"},
+		},
+		{
+			name:        "clusters in org and allowed orgs",
+			org:         "myorg",
+			allowedOrgs: []string{"otherorg"},
+			want:        []string{"myclu1", "myclu2", "other1"},
+		},
+		{
+			name: "clusters in other org",
+			org:  "otherorg",
+			want: []string{"other1"},
+		},
+		{
+			name: "no clusters in org",
+			org:  "emptyorg",
+			want: []string{},
+		},
+		{
+			name:    "tagging api error",
+			org:     "myorg",
+			rgtaerr: awserr.New(resourcegroupstaggingapi.ErrCodeInternalServiceException, "boom", nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, tt.org, nil, nil, nil, tt.rgtaerr, nil, nil)
+			o.AllowedOrgs = tt.allowedOrgs
+			o.ResourceGroupsTaggingAPI.Service.(*mockRGTAClient).tagged = tagged
+
+			got, err := o.ListClusters(context.TODO())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Orchestrator.ListClusters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.ListClusters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestProcessCluster(t *testing.T) {
 	orchestrator := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
 
@@ -236,3 +405,216 @@ func TestProcessTaskDefCluster(t *testing.T) {
 		t.Error("expected error, got nil")
 	}
 }
+
+func TestOrchestrator_deleteCluster(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster string
+		timeout time.Duration
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "empty cluster",
+			cluster: "cluster0",
+			want:    true,
+		},
+		{
+			name:    "cluster with active services",
+			cluster: "cluster1",
+			want:    false,
+		},
+		{
+			name:    "cluster with running tasks times out",
+			cluster: "cluster4",
+			timeout: 50 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.DeleteTimeout = tt.timeout
+
+			start := time.Now()
+			got, err := o.deleteCluster(context.TODO(), aws.String(tt.cluster))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.deleteCluster() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("Orchestrator.deleteCluster() = %t, want %t", got, tt.want)
+			}
+
+			if tt.timeout > 0 {
+				if elapsed := time.Since(start); elapsed > time.Second {
+					t.Errorf("expected deleteCluster to give up after the configured timeout %s, took %s", tt.timeout, elapsed)
+				}
+			}
+		})
+	}
+}
+
+// testSummaryClusterTasks is the number of tasks in the "busy" cluster, the first testSummaryClusterPending of them are still pending
+const (
+	testSummaryClusterTasks   = 250
+	testSummaryClusterPending = 20
+)
+
+func (m *mockECSClient) ListServicesWithContext(ctx aws.Context, input *ecs.ListServicesInput, opts ...request.Option) (*ecs.ListServicesOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	output := &ecs.ListServicesOutput{}
+	for _, name := range m.services[aws.StringValue(input.Cluster)] {
+		output.ServiceArns = append(output.ServiceArns, aws.String(name))
+	}
+
+	if aws.StringValue(input.Cluster) == "busy" {
+		for i := 0; i < 3; i++ {
+			output.ServiceArns = append(output.ServiceArns, aws.String(fmt.Sprintf("arn:aws:ecs:us-east-1:1234567890:service/busy/svc%d", i)))
+		}
+	}
+
+	return output, nil
+}
+
+func (m *mockECSClient) ListTasksWithContext(ctx aws.Context, input *ecs.ListTasksInput, opts ...request.Option) (*ecs.ListTasksOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if input.StartedBy != nil {
+		m.startedBy = append(m.startedBy, aws.StringValue(input.StartedBy))
+		return listStartedByTasks(input), nil
+	}
+
+	if input.ServiceName != nil {
+		return listServiceTasks(input), nil
+	}
+
+	if aws.StringValue(input.Cluster) != "busy" {
+		return &ecs.ListTasksOutput{}, nil
+	}
+
+	// return the tasks in pages of 100
+	start := 0
+	if input.NextToken != nil {
+		start, _ = strconv.Atoi(aws.StringValue(input.NextToken))
+	}
+
+	end := start + 100
+	output := &ecs.ListTasksOutput{}
+	if end < testSummaryClusterTasks {
+		output.NextToken = aws.String(strconv.Itoa(end))
+	} else {
+		end = testSummaryClusterTasks
+	}
+
+	for i := start; i < end; i++ {
+		output.TaskArns = append(output.TaskArns, aws.String(fmt.Sprintf("arn:aws:ecs:us-east-1:1234567890:task/busy/task%03d", i)))
+	}
+
+	return output, nil
+}
+
+func (m *mockECSClient) DescribeTasksWithContext(ctx aws.Context, input *ecs.DescribeTasksInput, opts ...request.Option) (*ecs.DescribeTasksOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if len(input.Tasks) > 100 {
+		return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "too many tasks", nil)
+	}
+
+	output := &ecs.DescribeTasksOutput{}
+	for _, id := range input.Tasks {
+		if t, ok := testTasks[aws.StringValue(id)]; ok {
+			output.Tasks = append(output.Tasks, t)
+			continue
+		}
+
+		i, err := strconv.Atoi(strings.TrimPrefix(aws.StringValue(id), "task"))
+		if err != nil {
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: id, Reason: aws.String("MISSING")})
+			continue
+		}
+
+		status := "RUNNING"
+		if i < testSummaryClusterPending {
+			status = "PENDING"
+		}
+
+		output.Tasks = append(output.Tasks, &ecs.Task{
+			TaskArn:    aws.String(fmt.Sprintf("arn:aws:ecs:us-east-1:1234567890:task/busy/task%03d", i)),
+			LastStatus: aws.String(status),
+			Cpu:        aws.String("256"),
+			Memory:     aws.String("512"),
+		})
+	}
+
+	return output, nil
+}
+
+func TestOrchestrator_ClusterSummary(t *testing.T) {
+	running := int64(testSummaryClusterTasks - testSummaryClusterPending)
+
+	tests := []struct {
+		name     string
+		cluster  string
+		ecserr   error
+		want     *ClusterSummaryOutput
+		wantCode string
+	}{
+		{
+			name:     "empty cluster name",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:    "empty cluster",
+			cluster: "quiet",
+			want:    &ClusterSummaryOutput{Cluster: "quiet"},
+		},
+		{
+			name:    "busy cluster",
+			cluster: "busy",
+			want: &ClusterSummaryOutput{
+				Cluster:      "busy",
+				Services:     3,
+				RunningTasks: int(running),
+				PendingTasks: testSummaryClusterPending,
+				Cpu:          running * 256,
+				Memory:       running * 512,
+			},
+		},
+		{
+			name:     "aws error",
+			cluster:  "busy",
+			ecserr:   awserr.New(ecs.ErrCodeClusterNotFoundException, "not found", nil),
+			wantCode: apierror.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, tt.ecserr, nil, nil, nil, nil)
+			got, err := o.ClusterSummary(context.TODO(), tt.cluster)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok || aerr.Code != tt.wantCode {
+					t.Errorf("expected %s error, got %v", tt.wantCode, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.ClusterSummary() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,877 @@
+package orchestration
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/docker/distribution/reference"
+	log "github.com/sirupsen/logrus"
+)
+
+// linuxCapabilities are the linux kernel capabilities that can be added to or dropped from a container
+// https://docs.aws.amazon.com/AmazonECS/latest/APIReference/API_KernelCapabilities.html
+var linuxCapabilities = map[string]struct{}{
+	"ALL":              {},
+	"AUDIT_CONTROL":    {},
+	"AUDIT_WRITE":      {},
+	"BLOCK_SUSPEND":    {},
+	"CHOWN":            {},
+	"DAC_OVERRIDE":     {},
+	"DAC_READ_SEARCH":  {},
+	"FOWNER":           {},
+	"FSETID":           {},
+	"IPC_LOCK":         {},
+	"IPC_OWNER":        {},
+	"KILL":             {},
+	"LEASE":            {},
+	"LINUX_IMMUTABLE":  {},
+	"MAC_ADMIN":        {},
+	"MAC_OVERRIDE":     {},
+	"MKNOD":            {},
+	"NET_ADMIN":        {},
+	"NET_BIND_SERVICE": {},
+	"NET_BROADCAST":    {},
+	"NET_RAW":          {},
+	"SETFCAP":          {},
+	"SETGID":           {},
+	"SETPCAP":          {},
+	"SETUID":           {},
+	"SYS_ADMIN":        {},
+	"SYS_BOOT":         {},
+	"SYS_CHROOT":       {},
+	"SYS_MODULE":       {},
+	"SYS_NICE":         {},
+	"SYS_PACCT":        {},
+	"SYS_PTRACE":       {},
+	"SYS_RAWIO":        {},
+	"SYS_RESOURCE":     {},
+	"SYS_TIME":         {},
+	"SYS_TTY_CONFIG":   {},
+	"SYSLOG":           {},
+	"WAKE_ALARM":       {},
+}
+
+// namespacedSystemControls are the namespaced kernel parameters (sysctls) that can be set for a container.  The
+// values are prefixes when the key ends with a dot.  Fargate doesn't support the message queue (fs.mqueue.*) sysctls.
+// https://docs.aws.amazon.com/AmazonECS/latest/APIReference/API_SystemControl.html
+var namespacedSystemControls = map[string]bool{
+	"kernel.msgmax":          true,
+	"kernel.msgmnb":          true,
+	"kernel.msgmni":          true,
+	"kernel.sem":             true,
+	"kernel.shmall":          true,
+	"kernel.shmmax":          true,
+	"kernel.shmmni":          true,
+	"kernel.shm_rmid_forced": true,
+	"net.":                   true,
+	"fs.mqueue.":             false,
+}
+
+// dockerLabelReservedPrefix is the prefix of the docker labels set by the ECS agent
+const dockerLabelReservedPrefix = "com.amazonaws.ecs."
+
+// health check limits enforced by ECS, in seconds for the interval, timeout and start period
+// https://docs.aws.amazon.com/AmazonECS/latest/APIReference/API_HealthCheck.html
+const (
+	healthCheckMinInterval    = 5
+	healthCheckMaxInterval    = 300
+	healthCheckMinTimeout     = 2
+	healthCheckMaxTimeout     = 60
+	healthCheckMinRetries     = 1
+	healthCheckMaxRetries     = 10
+	healthCheckMaxStartPeriod = 300
+)
+
+// container start and stop timeout limits enforced by ECS, in seconds.  Fargate caps both timeouts at 120 seconds.
+// https://docs.aws.amazon.com/AmazonECS/latest/APIReference/API_ContainerDefinition.html
+const (
+	containerMinTimeout        = 2
+	containerMaxFargateTimeout = 120
+)
+
+// validateTaskDefinition validates the caller supplied task definition before it's registered and returns the first
+// error.  The compatibilities are the launch types the task definition will be registered with.
+func (o *Orchestrator) validateTaskDefinition(td *ecs.RegisterTaskDefinitionInput, compatibilities []*string) error {
+	if errs := o.taskDefinitionErrors(td, compatibilities); len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// taskDefinitionErrors runs each of the task definition validators and collects their errors.  It's shared by registering
+// and linting a task definition so both apply the same validation.
+func (o *Orchestrator) taskDefinitionErrors(td *ecs.RegisterTaskDefinitionInput, compatibilities []*string) []error {
+	fargate := requiresFargate(compatibilities)
+
+	errs := []error{validateTaskSize(td.Cpu, td.Memory, fargate)}
+
+	// containers are validated one at a time to collect the errors of each of them
+	for _, cd := range td.ContainerDefinitions {
+		errs = append(errs,
+			validateContainerDefinitions([]*ecs.ContainerDefinition{cd}, compatibilities),
+			o.validateImageReferences([]*ecs.ContainerDefinition{cd}),
+		)
+	}
+
+	errs = append(errs, containerNameErrors(td.ContainerDefinitions)...)
+	errs = append(errs,
+		validateEssentialContainers(td.ContainerDefinitions),
+		validateInferenceAccelerators(td.InferenceAccelerators, td.ContainerDefinitions),
+		validateProxyConfiguration(td.ProxyConfiguration, td.ContainerDefinitions),
+		validateNamespaceModes(td.PidMode, td.IpcMode, fargate),
+		validateVolumes(td.Volumes, td.ContainerDefinitions, fargate),
+	)
+
+	failed := []error{}
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	return failed
+}
+
+// containerNameErrors ensures each container definition has a unique name and only depends on containers defined
+// in the task definition
+func containerNameErrors(containerDefinitions []*ecs.ContainerDefinition) []error {
+	errs := []error{}
+
+	names := make(map[string]struct{}, len(containerDefinitions))
+	for _, cd := range containerDefinitions {
+		if cd == nil {
+			continue
+		}
+
+		name := aws.StringValue(cd.Name)
+		if name == "" {
+			errs = append(errs, apierror.New(apierror.ErrBadRequest, "container definition name cannot be empty", nil))
+		} else if _, ok := names[name]; ok {
+			errs = append(errs, apierror.New(apierror.ErrBadRequest, "duplicate container definition name "+name, nil))
+		}
+		names[name] = struct{}{}
+	}
+
+	for _, cd := range containerDefinitions {
+		if cd == nil {
+			continue
+		}
+
+		for _, d := range cd.DependsOn {
+			if d == nil {
+				continue
+			}
+
+			if _, ok := names[aws.StringValue(d.ContainerName)]; !ok {
+				msg := fmt.Sprintf("container %s depends on undefined container '%s'", aws.StringValue(cd.Name), aws.StringValue(d.ContainerName))
+				errs = append(errs, apierror.New(apierror.ErrBadRequest, msg, nil))
+			}
+		}
+	}
+
+	return errs
+}
+
+// fargateTaskSizes are the supported Fargate task memory sizes (in MiB) for each task cpu size (in cpu units)
+// https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task-cpu-memory-error.html
+var fargateTaskSizes = map[int64][]int64{
+	256:   {512, 1024, 2048},
+	512:   taskMemorySizes(1024, 4096, 1024),
+	1024:  taskMemorySizes(2048, 8192, 1024),
+	2048:  taskMemorySizes(4096, 16384, 1024),
+	4096:  taskMemorySizes(8192, 30720, 1024),
+	8192:  taskMemorySizes(16384, 61440, 4096),
+	16384: taskMemorySizes(32768, 122880, 8192),
+}
+
+// taskMemorySizes returns the memory sizes from min to max (inclusive) in increments of step
+func taskMemorySizes(min, max, step int64) []int64 {
+	sizes := []int64{}
+	for m := min; m <= max; m += step {
+		sizes = append(sizes, m)
+	}
+	return sizes
+}
+
+// validateTaskSize validates the task level cpu (in cpu units or vCPU, ie. '1024' or '1 vCPU') and memory (in MiB
+// or GB, ie. '2048' or '2 GB').  Fargate requires both and only supports certain combinations.
+func validateTaskSize(cpu, memory *string, fargate bool) error {
+	if !fargate && cpu == nil && memory == nil {
+		return nil
+	}
+
+	if fargate && (cpu == nil || memory == nil) {
+		return apierror.New(apierror.ErrBadRequest, "task cpu and memory are required with "+ecs.CompatibilityFargate, nil)
+	}
+
+	c, err := parseTaskSize(aws.StringValue(cpu), "vcpu")
+	if cpu != nil && err != nil {
+		msg := fmt.Sprintf("invalid task cpu '%s'", aws.StringValue(cpu))
+		return apierror.New(apierror.ErrBadRequest, msg, err)
+	}
+
+	m, err := parseTaskSize(aws.StringValue(memory), "gb")
+	if memory != nil && err != nil {
+		msg := fmt.Sprintf("invalid task memory '%s'", aws.StringValue(memory))
+		return apierror.New(apierror.ErrBadRequest, msg, err)
+	}
+
+	if !fargate {
+		return nil
+	}
+
+	for _, size := range fargateTaskSizes[c] {
+		if m == size {
+			return nil
+		}
+	}
+
+	msg := fmt.Sprintf("unsupported %s task cpu %d and memory %d combination", ecs.CompatibilityFargate, c, m)
+	return apierror.New(apierror.ErrBadRequest, msg, nil)
+}
+
+// parseTaskSize parses a task cpu or memory size, converting values with the given unit suffix (vcpu or gb) to
+// cpu units or MiB
+func parseTaskSize(size, unit string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(size))
+	multiplier := 1.0
+	if strings.HasSuffix(s, unit) {
+		s = strings.TrimSpace(strings.TrimSuffix(s, unit))
+		multiplier = 1024
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if f <= 0 {
+		return 0, fmt.Errorf("size must be greater than 0")
+	}
+
+	return int64(f * multiplier), nil
+}
+
+// taskDefinitionCompatibilities returns the launch types required by the task definition, the task definition
+// is validated and registered with the DefaultCompatabilities if none were passed
+func taskDefinitionCompatibilities(td *ecs.RegisterTaskDefinitionInput) []*string {
+	if len(td.RequiresCompatibilities) == 0 {
+		return DefaultCompatabilities
+	}
+	return td.RequiresCompatibilities
+}
+
+// requiresFargate returns true if the FARGATE launch type is in the list of compatibilities
+func requiresFargate(compatibilities []*string) bool {
+	for _, c := range compatibilities {
+		if aws.StringValue(c) == ecs.CompatibilityFargate {
+			return true
+		}
+	}
+	return false
+}
+
+// validateContainerDefinitions validates the caller supplied container definitions before they are
+// registered, returning a bad request error describing the first invalid value.  This prevents opaque
+// errors when registering the task definition.  The compatibilities are the launch types the task
+// definition will be registered with.
+func validateContainerDefinitions(containerDefinitions []*ecs.ContainerDefinition, compatibilities []*string) error {
+	fargate := requiresFargate(compatibilities)
+
+	for _, cd := range containerDefinitions {
+		if cd == nil {
+			return apierror.New(apierror.ErrBadRequest, "container definition cannot be nil", nil)
+		}
+
+		name := aws.StringValue(cd.Name)
+
+		log.Debugf("validating container definition %s", name)
+
+		if err := validateUlimits(name, cd.Ulimits); err != nil {
+			return err
+		}
+
+		if err := validateLinuxParameters(name, cd.LinuxParameters, fargate); err != nil {
+			return err
+		}
+
+		if err := validateDockerLabels(name, cd.DockerLabels); err != nil {
+			return err
+		}
+
+		if err := validateSystemControls(name, cd.SystemControls, fargate); err != nil {
+			return err
+		}
+
+		if err := validateHealthCheck(name, cd.HealthCheck); err != nil {
+			return err
+		}
+
+		if err := validateContainerTimeout(name, "start", cd.StartTimeout, fargate); err != nil {
+			return err
+		}
+
+		if err := validateContainerTimeout(name, "stop", cd.StopTimeout, fargate); err != nil {
+			return err
+		}
+
+		if err := validateSecrets(name, cd.Secrets); err != nil {
+			return err
+		}
+
+		if err := validateEnvironmentFiles(name, cd.EnvironmentFiles); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateEssentialContainers ensures at least one container in the task definition is essential, ECS won't
+// register a task definition without one.  Like ECS, containers are essential unless they're marked otherwise.
+func validateEssentialContainers(containerDefinitions []*ecs.ContainerDefinition) error {
+	if len(containerDefinitions) == 0 {
+		return nil
+	}
+
+	for _, cd := range containerDefinitions {
+		if cd != nil && (cd.Essential == nil || aws.BoolValue(cd.Essential)) {
+			return nil
+		}
+	}
+
+	return apierror.New(apierror.ErrBadRequest, "at least one container in the task definition must be essential", nil)
+}
+
+// validateUlimits ensures the ulimit names are valid and the soft limit doesn't exceed the hard limit
+func validateUlimits(container string, ulimits []*ecs.Ulimit) error {
+	validNames := make(map[string]struct{}, len(ecs.UlimitName_Values()))
+	for _, n := range ecs.UlimitName_Values() {
+		validNames[n] = struct{}{}
+	}
+
+	for _, u := range ulimits {
+		if u == nil {
+			continue
+		}
+
+		name := aws.StringValue(u.Name)
+		if _, ok := validNames[name]; !ok {
+			msg := fmt.Sprintf("invalid ulimit name '%s' for container %s", name, container)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if u.SoftLimit == nil || u.HardLimit == nil {
+			msg := fmt.Sprintf("ulimit '%s' for container %s requires a soft and hard limit", name, container)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if aws.Int64Value(u.SoftLimit) > aws.Int64Value(u.HardLimit) {
+			msg := fmt.Sprintf("ulimit '%s' soft limit (%d) cannot be greater than the hard limit (%d) for container %s", name, aws.Int64Value(u.SoftLimit), aws.Int64Value(u.HardLimit), container)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	return nil
+}
+
+// validateLinuxParameters validates the linux parameters for a container definition.  Shared memory and tmpfs
+// mounts aren't supported by Fargate.
+func validateLinuxParameters(container string, params *ecs.LinuxParameters, fargate bool) error {
+	if params == nil {
+		return nil
+	}
+
+	if params.SharedMemorySize != nil {
+		if fargate {
+			msg := fmt.Sprintf("shared memory size for container %s is not supported with FARGATE", container)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if aws.Int64Value(params.SharedMemorySize) <= 0 {
+			msg := fmt.Sprintf("shared memory size for container %s must be greater than 0", container)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	if len(params.Tmpfs) > 0 && fargate {
+		msg := fmt.Sprintf("tmpfs mounts for container %s are not supported with FARGATE", container)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	for _, t := range params.Tmpfs {
+		if t == nil {
+			continue
+		}
+
+		path := aws.StringValue(t.ContainerPath)
+		if !strings.HasPrefix(path, "/") {
+			msg := fmt.Sprintf("tmpfs container path '%s' for container %s must be absolute", path, container)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if aws.Int64Value(t.Size) <= 0 {
+			msg := fmt.Sprintf("tmpfs size for '%s' in container %s must be greater than 0", path, container)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	if params.Capabilities != nil {
+		capabilities := append([]*string{}, params.Capabilities.Add...)
+		capabilities = append(capabilities, params.Capabilities.Drop...)
+		for _, c := range capabilities {
+			if _, ok := linuxCapabilities[aws.StringValue(c)]; !ok {
+				msg := fmt.Sprintf("invalid linux capability '%s' for container %s", aws.StringValue(c), container)
+				return apierror.New(apierror.ErrBadRequest, msg, nil)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateDockerLabels ensures the docker label keys are set and don't use the prefix reserved for the labels set by ECS
+func validateDockerLabels(container string, labels map[string]*string) error {
+	for k := range labels {
+		if strings.TrimSpace(k) == "" {
+			msg := fmt.Sprintf("docker label key for container %s cannot be empty", container)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if strings.HasPrefix(strings.ToLower(k), dockerLabelReservedPrefix) {
+			msg := fmt.Sprintf("docker label '%s' for container %s cannot start with the reserved prefix %s", k, container, dockerLabelReservedPrefix)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	return nil
+}
+
+// validateSystemControls ensures the kernel parameters (sysctls) of a container are namespaced, have a value and are
+// supported by Fargate when the task definition requires it
+func validateSystemControls(container string, controls []*ecs.SystemControl, fargate bool) error {
+	for _, c := range controls {
+		if c == nil {
+			continue
+		}
+
+		namespace := aws.StringValue(c.Namespace)
+		if c.Value == nil {
+			msg := fmt.Sprintf("system control '%s' for container %s requires a value", namespace, container)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		supported, ok := systemControlFargateSupport(namespace)
+		if !ok {
+			msg := fmt.Sprintf("invalid system control '%s' for container %s, only namespaced kernel parameters can be set", namespace, container)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if fargate && !supported {
+			msg := fmt.Sprintf("system control '%s' for container %s is not supported with FARGATE", namespace, container)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	return nil
+}
+
+// systemControlFargateSupport returns whether a namespaced kernel parameter is supported by Fargate, and false for
+// the second value if it isn't a namespaced kernel parameter
+func systemControlFargateSupport(namespace string) (bool, bool) {
+	if fargate, ok := namespacedSystemControls[namespace]; ok {
+		return fargate, true
+	}
+
+	for prefix, fargate := range namespacedSystemControls {
+		if strings.HasSuffix(prefix, ".") && strings.HasPrefix(namespace, prefix) && len(namespace) > len(prefix) {
+			return fargate, true
+		}
+	}
+
+	return false, false
+}
+
+// validateHealthCheck validates the health check command and ensures the interval, timeout, retries and start period
+// are within the ranges allowed by ECS.  Unset values are left to the ECS defaults.
+func validateHealthCheck(container string, hc *ecs.HealthCheck) error {
+	if hc == nil {
+		return nil
+	}
+
+	command := aws.StringValueSlice(hc.Command)
+	if len(command) < 2 || (command[0] != "CMD" && command[0] != "CMD-SHELL") {
+		msg := fmt.Sprintf("health check command for container %s must start with CMD or CMD-SHELL followed by the command", container)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	if hc.Interval != nil {
+		if i := aws.Int64Value(hc.Interval); i < healthCheckMinInterval || i > healthCheckMaxInterval {
+			msg := fmt.Sprintf("health check interval for container %s must be between %d and %d seconds", container, healthCheckMinInterval, healthCheckMaxInterval)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	if hc.Timeout != nil {
+		if t := aws.Int64Value(hc.Timeout); t < healthCheckMinTimeout || t > healthCheckMaxTimeout {
+			msg := fmt.Sprintf("health check timeout for container %s must be between %d and %d seconds", container, healthCheckMinTimeout, healthCheckMaxTimeout)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	if hc.Retries != nil {
+		if r := aws.Int64Value(hc.Retries); r < healthCheckMinRetries || r > healthCheckMaxRetries {
+			msg := fmt.Sprintf("health check retries for container %s must be between %d and %d", container, healthCheckMinRetries, healthCheckMaxRetries)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	if hc.StartPeriod != nil {
+		if sp := aws.Int64Value(hc.StartPeriod); sp < 0 || sp > healthCheckMaxStartPeriod {
+			msg := fmt.Sprintf("health check start period for container %s must be between 0 and %d seconds", container, healthCheckMaxStartPeriod)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	return nil
+}
+
+// validateContainerTimeout ensures a container start or stop timeout is at least the ECS minimum and, with
+// Fargate, doesn't exceed the Fargate maximum.  Unset timeouts are left to the ECS defaults.
+func validateContainerTimeout(container, kind string, timeout *int64, fargate bool) error {
+	if timeout == nil {
+		return nil
+	}
+
+	t := aws.Int64Value(timeout)
+	if t < containerMinTimeout {
+		msg := fmt.Sprintf("%s timeout for container %s must be at least %d seconds", kind, container, containerMinTimeout)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	if fargate && t > containerMaxFargateTimeout {
+		msg := fmt.Sprintf("%s timeout for container %s cannot be greater than %d seconds with FARGATE", kind, container, containerMaxFargateTimeout)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	return nil
+}
+
+// validateImageReferences ensures each container definition image is a valid image reference.  Images without
+// a tag or digest will pull 'latest', which is rejected if StrictImageReferences is set and logged otherwise.
+func (o *Orchestrator) validateImageReferences(containerDefinitions []*ecs.ContainerDefinition) error {
+	for _, cd := range containerDefinitions {
+		if cd == nil {
+			continue
+		}
+
+		name := aws.StringValue(cd.Name)
+		image := aws.StringValue(cd.Image)
+		pinned, err := validateImageReference(name, image)
+		if err != nil {
+			return err
+		}
+
+		if err := o.validateImageRegistry(name, image); err != nil {
+			return err
+		}
+
+		if !pinned {
+			if o.StrictImageReferences {
+				msg := fmt.Sprintf("image reference '%s' for container %s must include a tag or digest", image, name)
+				return apierror.New(apierror.ErrBadRequest, msg, nil)
+			}
+
+			log.Warnf("image reference '%s' for container %s doesn't include a tag or digest, latest will be pulled", image, name)
+		}
+	}
+
+	return nil
+}
+
+// validateImageReference ensures the container image is a valid image reference and returns true if it includes
+// a tag or digest
+func validateImageReference(container, image string) (bool, error) {
+	if image == "" {
+		msg := fmt.Sprintf("image is required for container %s", container)
+		return false, apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		msg := fmt.Sprintf("invalid image reference '%s' for container %s: %s", image, container, err)
+		return false, apierror.New(apierror.ErrBadRequest, msg, err)
+	}
+
+	_, tagged := ref.(reference.Tagged)
+	_, digested := ref.(reference.Digested)
+
+	return tagged || digested, nil
+}
+
+// validateImageRegistry ensures the registry of a container image isn't one of the DeniedRegistries and, if any
+// AllowedRegistries are configured, that it's one of them.  Registries are hostnames (ie. docker.io for Docker Hub)
+// and can be matched with a pattern (ie. *.dkr.ecr.us-east-1.amazonaws.com).  All registries are allowed by default.
+func (o *Orchestrator) validateImageRegistry(container, image string) error {
+	if len(o.AllowedRegistries) == 0 && len(o.DeniedRegistries) == 0 {
+		return nil
+	}
+
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		msg := fmt.Sprintf("invalid image reference '%s' for container %s: %s", image, container, err)
+		return apierror.New(apierror.ErrBadRequest, msg, err)
+	}
+
+	registry := reference.Domain(ref)
+	if registryMatches(registry, o.DeniedRegistries) || (len(o.AllowedRegistries) > 0 && !registryMatches(registry, o.AllowedRegistries)) {
+		msg := fmt.Sprintf("registry %s of image '%s' for container %s is not allowed", registry, image, container)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	return nil
+}
+
+// registryMatches returns true if the registry hostname matches one of the registry patterns
+func registryMatches(registry string, patterns []string) bool {
+	registry = strings.ToLower(registry)
+	for _, p := range patterns {
+		if ok, err := path.Match(strings.ToLower(p), registry); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateInferenceAccelerators ensures the inference accelerators are named and typed, and that container
+// resource requirements only reference accelerator device names defined on the task definition.
+func validateInferenceAccelerators(accelerators []*ecs.InferenceAccelerator, containerDefinitions []*ecs.ContainerDefinition) error {
+	devices := make(map[string]struct{}, len(accelerators))
+	for _, a := range accelerators {
+		if a == nil {
+			continue
+		}
+
+		name := aws.StringValue(a.DeviceName)
+		if name == "" || aws.StringValue(a.DeviceType) == "" {
+			return apierror.New(apierror.ErrBadRequest, "inference accelerators require a device name and device type", nil)
+		}
+
+		if _, ok := devices[name]; ok {
+			msg := fmt.Sprintf("duplicate inference accelerator device name '%s'", name)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+		devices[name] = struct{}{}
+	}
+
+	for _, cd := range containerDefinitions {
+		if cd == nil {
+			continue
+		}
+
+		for _, r := range cd.ResourceRequirements {
+			if r == nil || aws.StringValue(r.Type) != ecs.ResourceTypeInferenceAccelerator {
+				continue
+			}
+
+			if _, ok := devices[aws.StringValue(r.Value)]; !ok {
+				msg := fmt.Sprintf("container %s references undefined inference accelerator device '%s'", aws.StringValue(cd.Name), aws.StringValue(r.Value))
+				return apierror.New(apierror.ErrBadRequest, msg, nil)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateProxyConfiguration ensures an App Mesh proxy configuration names a container in the task definition and has the
+// properties required by the Envoy proxy.  The type defaults to APPMESH, the only supported type.
+func validateProxyConfiguration(proxy *ecs.ProxyConfiguration, containerDefinitions []*ecs.ContainerDefinition) error {
+	if proxy == nil {
+		return nil
+	}
+
+	if proxy.Type == nil {
+		proxy.Type = aws.String(ecs.ProxyConfigurationTypeAppmesh)
+	}
+
+	if t := aws.StringValue(proxy.Type); t != ecs.ProxyConfigurationTypeAppmesh {
+		msg := fmt.Sprintf("invalid proxy configuration type '%s', must be %s", t, ecs.ProxyConfigurationTypeAppmesh)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	name := aws.StringValue(proxy.ContainerName)
+	if name == "" {
+		return apierror.New(apierror.ErrBadRequest, "proxy configuration requires a container name", nil)
+	}
+
+	found := false
+	for _, cd := range containerDefinitions {
+		if cd != nil && aws.StringValue(cd.Name) == name {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		msg := fmt.Sprintf("proxy configuration references undefined container '%s'", name)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	properties := make(map[string]string, len(proxy.Properties))
+	for _, p := range proxy.Properties {
+		if p == nil {
+			continue
+		}
+
+		key := aws.StringValue(p.Name)
+		value := aws.StringValue(p.Value)
+
+		switch key {
+		case "IgnoredUID", "IgnoredGID":
+			if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+				msg := fmt.Sprintf("invalid proxy configuration property %s value '%s'", key, value)
+				return apierror.New(apierror.ErrBadRequest, msg, nil)
+			}
+		case "ProxyIngressPort", "ProxyEgressPort":
+			if !validPort(value) {
+				msg := fmt.Sprintf("invalid proxy configuration property %s port '%s'", key, value)
+				return apierror.New(apierror.ErrBadRequest, msg, nil)
+			}
+		case "AppPorts", "EgressIgnoredPorts":
+			// no ports need to be ignored
+			if key == "EgressIgnoredPorts" && value == "" {
+				break
+			}
+
+			for _, port := range strings.Split(value, ",") {
+				if !validPort(strings.TrimSpace(port)) {
+					msg := fmt.Sprintf("invalid proxy configuration property %s port '%s'", key, port)
+					return apierror.New(apierror.ErrBadRequest, msg, nil)
+				}
+			}
+		case "EgressIgnoredIPs":
+		default:
+			msg := fmt.Sprintf("unknown proxy configuration property '%s'", key)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		properties[key] = value
+	}
+
+	for _, required := range []string{"AppPorts", "ProxyIngressPort", "ProxyEgressPort"} {
+		if _, ok := properties[required]; !ok {
+			msg := fmt.Sprintf("proxy configuration requires the %s property", required)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	_, uid := properties["IgnoredUID"]
+	_, gid := properties["IgnoredGID"]
+	if !uid && !gid {
+		return apierror.New(apierror.ErrBadRequest, "proxy configuration requires the IgnoredUID or IgnoredGID property", nil)
+	}
+
+	return nil
+}
+
+// validateNamespaceModes validates the process (host or task) and IPC (host, task or none) namespace modes of a task
+// definition.  Fargate only supports sharing the process namespace between the containers in a task and doesn't
+// support setting the IPC namespace mode.
+func validateNamespaceModes(pidMode, ipcMode *string, fargate bool) error {
+	if pidMode != nil {
+		mode := aws.StringValue(pidMode)
+		if !stringInSlice(mode, ecs.PidMode_Values()) {
+			msg := fmt.Sprintf("invalid pid mode '%s', must be one of %s", mode, strings.Join(ecs.PidMode_Values(), ", "))
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if fargate && mode != ecs.PidModeTask {
+			msg := fmt.Sprintf("pid mode '%s' is not supported with FARGATE, only '%s' is supported", mode, ecs.PidModeTask)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	if ipcMode != nil {
+		mode := aws.StringValue(ipcMode)
+		if !stringInSlice(mode, ecs.IpcMode_Values()) {
+			msg := fmt.Sprintf("invalid ipc mode '%s', must be one of %s", mode, strings.Join(ecs.IpcMode_Values(), ", "))
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if fargate {
+			return apierror.New(apierror.ErrBadRequest, "ipc mode is not supported with FARGATE", nil)
+		}
+	}
+
+	return nil
+}
+
+// validateVolumes validates the task definition volumes and the container mount points that reference them.
+// Host volumes with a source path bind mount a directory from the container instance, so they are only
+// supported by the EC2 launch type and the source path must be absolute.
+func validateVolumes(volumes []*ecs.Volume, containerDefinitions []*ecs.ContainerDefinition, fargate bool) error {
+	names := make(map[string]struct{}, len(volumes))
+	for _, v := range volumes {
+		if v == nil {
+			return apierror.New(apierror.ErrBadRequest, "volume cannot be nil", nil)
+		}
+
+		name := aws.StringValue(v.Name)
+		if name == "" {
+			return apierror.New(apierror.ErrBadRequest, "volume name cannot be empty", nil)
+		}
+
+		if _, ok := names[name]; ok {
+			msg := fmt.Sprintf("duplicate volume name '%s'", name)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+		names[name] = struct{}{}
+
+		if v.Host == nil || v.Host.SourcePath == nil {
+			continue
+		}
+
+		if fargate {
+			msg := fmt.Sprintf("host volume '%s' is not supported with %s, use the %s launch type", name, ecs.CompatibilityFargate, ecs.CompatibilityEc2)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if path := aws.StringValue(v.Host.SourcePath); !strings.HasPrefix(path, "/") {
+			msg := fmt.Sprintf("invalid source path '%s' for host volume '%s', must be an absolute path", path, name)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	for _, cd := range containerDefinitions {
+		if cd == nil {
+			continue
+		}
+
+		for _, mp := range cd.MountPoints {
+			if mp == nil {
+				continue
+			}
+
+			if _, ok := names[aws.StringValue(mp.SourceVolume)]; !ok {
+				msg := fmt.Sprintf("mount point for container %s references undefined volume '%s'", aws.StringValue(cd.Name), aws.StringValue(mp.SourceVolume))
+				return apierror.New(apierror.ErrBadRequest, msg, nil)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validPort returns true if the string is a port number between 1 and 65535
+func validPort(port string) bool {
+	p, err := strconv.ParseUint(port, 10, 16)
+	return err == nil && p > 0
+}
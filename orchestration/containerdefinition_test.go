@@ -0,0 +1,1121 @@
+package orchestration
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func Test_validateContainerDefinitions(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           []*ecs.ContainerDefinition
+		compatibilities []string
+		wantErr         bool
+	}{
+		{
+			name:  "empty",
+			input: []*ecs.ContainerDefinition{},
+		},
+		{
+			name: "valid nofile ulimit",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					Ulimits: []*ecs.Ulimit{
+						{
+							Name:      aws.String("nofile"),
+							SoftLimit: aws.Int64(1024),
+							HardLimit: aws.Int64(4096),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid ulimit name",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					Ulimits: []*ecs.Ulimit{
+						{
+							Name:      aws.String("nofiles"),
+							SoftLimit: aws.Int64(1024),
+							HardLimit: aws.Int64(4096),
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "soft limit greater than hard limit",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					Ulimits: []*ecs.Ulimit{
+						{
+							Name:      aws.String("nofile"),
+							SoftLimit: aws.Int64(8192),
+							HardLimit: aws.Int64(4096),
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid capabilities",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					LinuxParameters: &ecs.LinuxParameters{
+						Capabilities: &ecs.KernelCapabilities{
+							Add:  aws.StringSlice([]string{"NET_ADMIN", "SYS_PTRACE"}),
+							Drop: aws.StringSlice([]string{"ALL"}),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid capability",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					LinuxParameters: &ecs.LinuxParameters{
+						Capabilities: &ecs.KernelCapabilities{
+							Add: aws.StringSlice([]string{"CAP_EVERYTHING"}),
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid CMD-SHELL health check",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					HealthCheck: &ecs.HealthCheck{
+						Command:     aws.StringSlice([]string{"CMD-SHELL", "curl -f http://localhost/ || exit 1"}),
+						Interval:    aws.Int64(30),
+						Timeout:     aws.Int64(5),
+						Retries:     aws.Int64(3),
+						StartPeriod: aws.Int64(60),
+					},
+				},
+			},
+		},
+		{
+			name: "empty health check command",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					HealthCheck: &ecs.HealthCheck{
+						Command: []*string{},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health check command without CMD or CMD-SHELL",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					HealthCheck: &ecs.HealthCheck{
+						Command: aws.StringSlice([]string{"curl -f http://localhost/"}),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health check interval too short",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					HealthCheck: &ecs.HealthCheck{
+						Command:  aws.StringSlice([]string{"CMD-SHELL", "curl -f http://localhost/ || exit 1"}),
+						Interval: aws.Int64(1),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health check timeout too long",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					HealthCheck: &ecs.HealthCheck{
+						Command: aws.StringSlice([]string{"CMD-SHELL", "curl -f http://localhost/ || exit 1"}),
+						Timeout: aws.Int64(120),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health check zero retries",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					HealthCheck: &ecs.HealthCheck{
+						Command: aws.StringSlice([]string{"CMD-SHELL", "curl -f http://localhost/ || exit 1"}),
+						Retries: aws.Int64(0),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health check start period too long",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					HealthCheck: &ecs.HealthCheck{
+						Command:     aws.StringSlice([]string{"CMD-SHELL", "curl -f http://localhost/ || exit 1"}),
+						StartPeriod: aws.Int64(301),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid EC2 tmpfs and shared memory",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("chromium"),
+					LinuxParameters: &ecs.LinuxParameters{
+						SharedMemorySize: aws.Int64(512),
+						Tmpfs: []*ecs.Tmpfs{
+							{
+								ContainerPath: aws.String("/tmp/cache"),
+								MountOptions:  aws.StringSlice([]string{"rw", "noexec"}),
+								Size:          aws.Int64(256),
+							},
+						},
+					},
+				},
+			},
+			compatibilities: []string{"EC2"},
+		},
+		{
+			name: "FARGATE tmpfs",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("chromium"),
+					LinuxParameters: &ecs.LinuxParameters{
+						Tmpfs: []*ecs.Tmpfs{
+							{
+								ContainerPath: aws.String("/tmp/cache"),
+								Size:          aws.Int64(256),
+							},
+						},
+					},
+				},
+			},
+			compatibilities: []string{"FARGATE"},
+			wantErr:         true,
+		},
+		{
+			name: "FARGATE shared memory size",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("chromium"),
+					LinuxParameters: &ecs.LinuxParameters{
+						SharedMemorySize: aws.Int64(512),
+					},
+				},
+			},
+			compatibilities: []string{"EC2", "FARGATE"},
+			wantErr:         true,
+		},
+		{
+			name: "zero shared memory size",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("chromium"),
+					LinuxParameters: &ecs.LinuxParameters{
+						SharedMemorySize: aws.Int64(0),
+					},
+				},
+			},
+			compatibilities: []string{"EC2"},
+			wantErr:         true,
+		},
+		{
+			name: "relative tmpfs path",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("chromium"),
+					LinuxParameters: &ecs.LinuxParameters{
+						Tmpfs: []*ecs.Tmpfs{
+							{
+								ContainerPath: aws.String("tmp/cache"),
+								Size:          aws.Int64(256),
+							},
+						},
+					},
+				},
+			},
+			compatibilities: []string{"EC2"},
+			wantErr:         true,
+		},
+		{
+			name: "valid start and stop timeouts",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name:         aws.String("webserver"),
+					StartTimeout: aws.Int64(60),
+					StopTimeout:  aws.Int64(120),
+				},
+			},
+			compatibilities: []string{"FARGATE"},
+		},
+		{
+			name: "long stop timeout with ec2",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name:        aws.String("webserver"),
+					StopTimeout: aws.Int64(300),
+				},
+			},
+			compatibilities: []string{"EC2"},
+		},
+		{
+			name: "stop timeout over the fargate maximum",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name:        aws.String("webserver"),
+					StopTimeout: aws.Int64(300),
+				},
+			},
+			compatibilities: []string{"EC2", "FARGATE"},
+			wantErr:         true,
+		},
+		{
+			name: "start timeout over the fargate maximum",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name:         aws.String("webserver"),
+					StartTimeout: aws.Int64(121),
+				},
+			},
+			compatibilities: []string{"FARGATE"},
+			wantErr:         true,
+		},
+		{
+			name: "stop timeout under the minimum",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name:        aws.String("webserver"),
+					StopTimeout: aws.Int64(1),
+				},
+			},
+			compatibilities: []string{"EC2"},
+			wantErr:         true,
+		},
+		{
+			name: "valid environment file",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					EnvironmentFiles: []*ecs.EnvironmentFile{
+						{Type: aws.String("s3"), Value: aws.String("arn:aws:s3:::my-bucket/config/app.env")},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid environment file type",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					EnvironmentFiles: []*ecs.EnvironmentFile{
+						{Type: aws.String("file"), Value: aws.String("arn:aws:s3:::my-bucket/app.env")},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "environment file that isn't an s3 arn",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					EnvironmentFiles: []*ecs.EnvironmentFile{
+						{Type: aws.String("s3"), Value: aws.String("s3://my-bucket/app.env")},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "environment file bucket arn",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					EnvironmentFiles: []*ecs.EnvironmentFile{
+						{Type: aws.String("s3"), Value: aws.String("arn:aws:s3:::my-bucket")},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "environment file without the env extension",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					EnvironmentFiles: []*ecs.EnvironmentFile{
+						{Type: aws.String("s3"), Value: aws.String("arn:aws:s3:::my-bucket/app.txt")},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid docker labels",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					DockerLabels: aws.StringMap(map[string]string{
+						"com.datadoghq.ad.check_names": `["nginx"]`,
+						"traefik.enable":               "true",
+					}),
+				},
+			},
+			compatibilities: []string{"FARGATE"},
+		},
+		{
+			name: "reserved docker label",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name:         aws.String("webserver"),
+					DockerLabels: aws.StringMap(map[string]string{"com.amazonaws.ecs.cluster": "clu1"}),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid FARGATE system controls",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					SystemControls: []*ecs.SystemControl{
+						{Namespace: aws.String("net.core.somaxconn"), Value: aws.String("4096")},
+						{Namespace: aws.String("kernel.shm_rmid_forced"), Value: aws.String("1")},
+					},
+				},
+			},
+			compatibilities: []string{"FARGATE"},
+		},
+		{
+			name: "message queue system control with EC2",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					SystemControls: []*ecs.SystemControl{
+						{Namespace: aws.String("fs.mqueue.msg_max"), Value: aws.String("100")},
+					},
+				},
+			},
+			compatibilities: []string{"EC2"},
+		},
+		{
+			name: "FARGATE unsupported system control",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					SystemControls: []*ecs.SystemControl{
+						{Namespace: aws.String("fs.mqueue.msg_max"), Value: aws.String("100")},
+					},
+				},
+			},
+			compatibilities: []string{"EC2", "FARGATE"},
+			wantErr:         true,
+		},
+		{
+			name: "system control that isn't namespaced",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					SystemControls: []*ecs.SystemControl{
+						{Namespace: aws.String("vm.max_map_count"), Value: aws.String("262144")},
+					},
+				},
+			},
+			compatibilities: []string{"EC2"},
+			wantErr:         true,
+		},
+		{
+			name: "system control without a value",
+			input: []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					SystemControls: []*ecs.SystemControl{
+						{Namespace: aws.String("net.ipv4.tcp_keepalive_time")},
+					},
+				},
+			},
+			compatibilities: []string{"EC2"},
+			wantErr:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateContainerDefinitions(tt.input, aws.StringSlice(tt.compatibilities))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateContainerDefinitions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err != nil {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+					t.Errorf("expected bad request apierror, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestOrchestrator_validateImageReferences(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   string
+		strict  bool
+		wantErr bool
+	}{
+		{
+			name:  "docker hub image with tag",
+			image: "nginx:1.23",
+		},
+		{
+			name:  "ecr image with tag",
+			image: "012345678901.dkr.ecr.us-east-1.amazonaws.com/myorg/webapp:v1.2.3",
+		},
+		{
+			name:  "image with digest",
+			image: "nginx@sha256:0f1e6f7ddd18f8a4eeb5e8a5d3f8d7f1b2b6b0b5e6bd4c1d5b0a5c4c1d2e3f4a",
+		},
+		{
+			name:   "strict image with tag",
+			image:  "ghcr.io/yalespinup/ecs-api:latest",
+			strict: true,
+		},
+		{
+			name:  "image without tag",
+			image: "nginx",
+		},
+		{
+			name:    "strict image without tag",
+			image:   "nginx",
+			strict:  true,
+			wantErr: true,
+		},
+		{
+			name:    "malformed image",
+			image:   "Nginx:1.23",
+			wantErr: true,
+		},
+		{
+			name:    "malformed tag",
+			image:   "nginx:1.23:latest",
+			wantErr: true,
+		},
+		{
+			name:    "empty image",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Orchestrator{StrictImageReferences: tt.strict}
+			err := o.validateImageReferences([]*ecs.ContainerDefinition{
+				{
+					Name:  aws.String("webserver"),
+					Image: aws.String(tt.image),
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.validateImageReferences() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err != nil {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+					t.Errorf("expected bad request apierror, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestOrchestrator_validateImageRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   string
+		allowed []string
+		denied  []string
+		wantErr bool
+	}{
+		{
+			name:  "empty lists allow docker hub",
+			image: "nginx:1.23",
+		},
+		{
+			name:  "empty lists allow any registry",
+			image: "registry.example.com/myorg/webapp:v1",
+		},
+		{
+			name:    "allowed registry",
+			image:   "ghcr.io/yalespinup/ecs-api:v1",
+			allowed: []string{"docker.io", "ghcr.io"},
+		},
+		{
+			name:    "allowed docker hub",
+			image:   "nginx:1.23",
+			allowed: []string{"docker.io"},
+		},
+		{
+			name:    "allowed registry pattern",
+			image:   "012345678901.dkr.ecr.us-east-1.amazonaws.com/myorg/webapp:v1.2.3",
+			allowed: []string{"*.dkr.ecr.us-east-1.amazonaws.com"},
+		},
+		{
+			name:    "registry not in the allowed list",
+			image:   "registry.example.com/myorg/webapp:v1",
+			allowed: []string{"docker.io", "ghcr.io"},
+			wantErr: true,
+		},
+		{
+			name:    "denied registry",
+			image:   "registry.example.com/myorg/webapp:v1",
+			denied:  []string{"registry.example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "denied docker hub",
+			image:   "nginx:1.23",
+			denied:  []string{"DOCKER.IO"},
+			wantErr: true,
+		},
+		{
+			name:   "registry not in the denied list",
+			image:  "ghcr.io/yalespinup/ecs-api:v1",
+			denied: []string{"registry.example.com"},
+		},
+		{
+			name:    "denied registry in the allowed pattern",
+			image:   "untrusted.example.com/webapp:v1",
+			allowed: []string{"*.example.com"},
+			denied:  []string{"untrusted.example.com"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Orchestrator{AllowedRegistries: tt.allowed, DeniedRegistries: tt.denied}
+			err := o.validateImageReferences([]*ecs.ContainerDefinition{
+				{
+					Name:  aws.String("webserver"),
+					Image: aws.String(tt.image),
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.validateImageReferences() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err != nil {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+					t.Errorf("expected bad request apierror, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func Test_validateInferenceAccelerators(t *testing.T) {
+	tests := []struct {
+		name         string
+		accelerators []*ecs.InferenceAccelerator
+		requirements []*ecs.ResourceRequirement
+		wantErr      bool
+	}{
+		{
+			name: "no accelerators",
+		},
+		{
+			name: "defined accelerator",
+			accelerators: []*ecs.InferenceAccelerator{
+				{DeviceName: aws.String("device1"), DeviceType: aws.String("eia2.medium")},
+			},
+			requirements: []*ecs.ResourceRequirement{
+				{Type: aws.String("InferenceAccelerator"), Value: aws.String("device1")},
+				{Type: aws.String("GPU"), Value: aws.String("1")},
+			},
+		},
+		{
+			name: "undefined accelerator",
+			requirements: []*ecs.ResourceRequirement{
+				{Type: aws.String("InferenceAccelerator"), Value: aws.String("device1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing device type",
+			accelerators: []*ecs.InferenceAccelerator{
+				{DeviceName: aws.String("device1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate device name",
+			accelerators: []*ecs.InferenceAccelerator{
+				{DeviceName: aws.String("device1"), DeviceType: aws.String("eia2.medium")},
+				{DeviceName: aws.String("device1"), DeviceType: aws.String("eia2.large")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateInferenceAccelerators(tt.accelerators, []*ecs.ContainerDefinition{
+				{
+					Name:                 aws.String("inference"),
+					ResourceRequirements: tt.requirements,
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateInferenceAccelerators() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateProxyConfiguration(t *testing.T) {
+	properties := func(kv ...string) []*ecs.KeyValuePair {
+		out := []*ecs.KeyValuePair{}
+		for i := 0; i < len(kv); i += 2 {
+			out = append(out, &ecs.KeyValuePair{Name: aws.String(kv[i]), Value: aws.String(kv[i+1])})
+		}
+		return out
+	}
+
+	appmesh := properties(
+		"IgnoredUID", "1337",
+		"ProxyIngressPort", "15000",
+		"ProxyEgressPort", "15001",
+		"AppPorts", "8080,8443",
+		"EgressIgnoredIPs", "169.254.170.2,169.254.169.254",
+		"EgressIgnoredPorts", "",
+	)
+
+	tests := []struct {
+		name    string
+		proxy   *ecs.ProxyConfiguration
+		wantErr bool
+	}{
+		{
+			name: "no proxy configuration",
+		},
+		{
+			name: "valid app mesh proxy configuration",
+			proxy: &ecs.ProxyConfiguration{
+				Type:          aws.String("APPMESH"),
+				ContainerName: aws.String("envoy"),
+				Properties:    appmesh,
+			},
+		},
+		{
+			name: "default type",
+			proxy: &ecs.ProxyConfiguration{
+				ContainerName: aws.String("envoy"),
+				Properties:    appmesh,
+			},
+		},
+		{
+			name: "missing container",
+			proxy: &ecs.ProxyConfiguration{
+				ContainerName: aws.String("sidecar"),
+				Properties:    appmesh,
+			},
+			wantErr: true,
+		},
+		{
+			name: "no container name",
+			proxy: &ecs.ProxyConfiguration{
+				Properties: appmesh,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid type",
+			proxy: &ecs.ProxyConfiguration{
+				Type:          aws.String("ISTIO"),
+				ContainerName: aws.String("envoy"),
+				Properties:    appmesh,
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing egress port",
+			proxy: &ecs.ProxyConfiguration{
+				ContainerName: aws.String("envoy"),
+				Properties:    properties("IgnoredUID", "1337", "ProxyIngressPort", "15000", "AppPorts", "8080"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing ignored uid and gid",
+			proxy: &ecs.ProxyConfiguration{
+				ContainerName: aws.String("envoy"),
+				Properties:    properties("ProxyIngressPort", "15000", "ProxyEgressPort", "15001", "AppPorts", "8080"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid app port",
+			proxy: &ecs.ProxyConfiguration{
+				ContainerName: aws.String("envoy"),
+				Properties:    properties("IgnoredGID", "1337", "ProxyIngressPort", "15000", "ProxyEgressPort", "15001", "AppPorts", "8080,http"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown property",
+			proxy: &ecs.ProxyConfiguration{
+				ContainerName: aws.String("envoy"),
+				Properties:    append(properties("Mesh", "default"), appmesh...),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateProxyConfiguration(tt.proxy, []*ecs.ContainerDefinition{
+				{Name: aws.String("app")},
+				{Name: aws.String("envoy")},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateProxyConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && tt.proxy != nil && aws.StringValue(tt.proxy.Type) != "APPMESH" {
+				t.Errorf("expected proxy configuration type APPMESH, got %s", aws.StringValue(tt.proxy.Type))
+			}
+		})
+	}
+}
+
+func Test_validateVolumes(t *testing.T) {
+	host := func(name, path string) *ecs.Volume {
+		return &ecs.Volume{
+			Name: aws.String(name),
+			Host: &ecs.HostVolumeProperties{SourcePath: aws.String(path)},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		volumes []*ecs.Volume
+		mounts  []string
+		fargate bool
+		wantErr bool
+	}{
+		{
+			name: "no volumes",
+		},
+		{
+			name:    "ec2 host volume",
+			volumes: []*ecs.Volume{host("docker", "/var/run/docker.sock")},
+			mounts:  []string{"docker"},
+		},
+		{
+			name:    "fargate host volume",
+			volumes: []*ecs.Volume{host("docker", "/var/run/docker.sock")},
+			mounts:  []string{"docker"},
+			fargate: true,
+			wantErr: true,
+		},
+		{
+			name:    "fargate bind mount volume",
+			volumes: []*ecs.Volume{{Name: aws.String("scratch")}},
+			mounts:  []string{"scratch"},
+			fargate: true,
+		},
+		{
+			name:    "relative source path",
+			volumes: []*ecs.Volume{host("data", "var/data")},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate volume name",
+			volumes: []*ecs.Volume{host("data", "/data"), host("data", "/var/data")},
+			wantErr: true,
+		},
+		{
+			name:    "empty volume name",
+			volumes: []*ecs.Volume{{}},
+			wantErr: true,
+		},
+		{
+			name:    "nil volume",
+			volumes: []*ecs.Volume{nil},
+			wantErr: true,
+		},
+		{
+			name:    "undefined mount point volume",
+			volumes: []*ecs.Volume{host("data", "/data")},
+			mounts:  []string{"logs"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mountPoints := []*ecs.MountPoint{}
+			for _, m := range tt.mounts {
+				mountPoints = append(mountPoints, &ecs.MountPoint{
+					SourceVolume:  aws.String(m),
+					ContainerPath: aws.String("/mnt/" + m),
+				})
+			}
+
+			err := validateVolumes(tt.volumes, []*ecs.ContainerDefinition{
+				{Name: aws.String("app"), MountPoints: mountPoints},
+			}, tt.fargate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateVolumes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateEssentialContainers(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers []*ecs.ContainerDefinition
+		wantErr    bool
+	}{
+		{
+			name: "no containers",
+		},
+		{
+			name:       "only container defaults to essential",
+			containers: []*ecs.ContainerDefinition{{Name: aws.String("web")}},
+		},
+		{
+			name: "one essential container",
+			containers: []*ecs.ContainerDefinition{
+				{Name: aws.String("web"), Essential: aws.Bool(true)},
+				{Name: aws.String("sidecar"), Essential: aws.Bool(false)},
+			},
+		},
+		{
+			name: "no essential containers",
+			containers: []*ecs.ContainerDefinition{
+				{Name: aws.String("web"), Essential: aws.Bool(false)},
+				{Name: aws.String("sidecar"), Essential: aws.Bool(false)},
+			},
+			wantErr: true,
+		},
+		{
+			name:       "only container not essential",
+			containers: []*ecs.ContainerDefinition{{Name: aws.String("web"), Essential: aws.Bool(false)}},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateEssentialContainers(tt.containers); (err != nil) != tt.wantErr {
+				t.Errorf("validateEssentialContainers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateNamespaceModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		pidMode *string
+		ipcMode *string
+		fargate bool
+		wantErr bool
+	}{
+		{
+			name:    "unset",
+			fargate: true,
+		},
+		{
+			name:    "fargate task pid mode",
+			pidMode: aws.String("task"),
+			fargate: true,
+		},
+		{
+			name:    "fargate host pid mode",
+			pidMode: aws.String("host"),
+			fargate: true,
+			wantErr: true,
+		},
+		{
+			name:    "ec2 host pid mode",
+			pidMode: aws.String("host"),
+		},
+		{
+			name:    "invalid pid mode",
+			pidMode: aws.String("none"),
+			wantErr: true,
+		},
+		{
+			name:    "ec2 ipc modes",
+			pidMode: aws.String("task"),
+			ipcMode: aws.String("none"),
+		},
+		{
+			name:    "fargate ipc mode",
+			ipcMode: aws.String("task"),
+			fargate: true,
+			wantErr: true,
+		},
+		{
+			name:    "invalid ipc mode",
+			ipcMode: aws.String("shareable"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNamespaceModes(tt.pidMode, tt.ipcMode, tt.fargate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateNamespaceModes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err != nil {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+					t.Errorf("expected bad request apierror, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func Test_validateTaskSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		cpu     *string
+		memory  *string
+		fargate bool
+		wantErr bool
+	}{
+		{
+			name:    "fargate smallest task",
+			cpu:     aws.String("256"),
+			memory:  aws.String("512"),
+			fargate: true,
+		},
+		{
+			name:    "fargate vcpu and gb units",
+			cpu:     aws.String("1 vCPU"),
+			memory:  aws.String("4 GB"),
+			fargate: true,
+		},
+		{
+			name:    "fargate fractional vcpu",
+			cpu:     aws.String(".25 vcpu"),
+			memory:  aws.String("2GB"),
+			fargate: true,
+		},
+		{
+			name:    "fargate unsupported memory step",
+			cpu:     aws.String("256"),
+			memory:  aws.String("1536"),
+			fargate: true,
+			wantErr: true,
+		},
+		{
+			name:    "fargate memory too small for cpu",
+			cpu:     aws.String("4096"),
+			memory:  aws.String("4096"),
+			fargate: true,
+			wantErr: true,
+		},
+		{
+			name:    "fargate unsupported cpu",
+			cpu:     aws.String("384"),
+			memory:  aws.String("1024"),
+			fargate: true,
+			wantErr: true,
+		},
+		{
+			name:    "fargate missing memory",
+			cpu:     aws.String("256"),
+			fargate: true,
+			wantErr: true,
+		},
+		{
+			name: "ec2 without task size",
+		},
+		{
+			name:   "ec2 any task size",
+			cpu:    aws.String("384"),
+			memory: aws.String("1536"),
+		},
+		{
+			name:    "invalid cpu",
+			cpu:     aws.String("lots"),
+			wantErr: true,
+		},
+		{
+			name:    "negative memory",
+			memory:  aws.String("-512"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateTaskSize(tt.cpu, tt.memory, tt.fargate); (err != nil) != tt.wantErr {
+				t.Errorf("validateTaskSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_taskDefinitionErrors(t *testing.T) {
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+	td := &ecs.RegisterTaskDefinitionInput{
+		Cpu:    aws.String("256"),
+		Memory: aws.String("4096"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name:      aws.String("web"),
+				Image:     aws.String("nginx:1.23"),
+				DependsOn: []*ecs.ContainerDependency{{ContainerName: aws.String("missing"), Condition: aws.String("START")}},
+			},
+			{
+				Name:  aws.String("web"),
+				Image: aws.String("nginx:1.23"),
+			},
+		},
+	}
+
+	// registering stops at the first error
+	err := o.validateTaskDefinition(td, aws.StringSlice([]string{"FARGATE"}))
+	if err == nil || !strings.Contains(err.Error(), "4096") {
+		t.Errorf("expected the unsupported fargate task size to be rejected when registering, got %v", err)
+	}
+
+	// linting reports each of the errors found by the same validators
+	out := o.ValidateTaskDef(td)
+	if out.Valid || len(out.Errors) != 3 {
+		t.Errorf("expected the task size, duplicate name and undefined dependency errors, got %+v", out.Errors)
+	}
+}
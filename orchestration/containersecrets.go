@@ -0,0 +1,156 @@
+package orchestration
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	log "github.com/sirupsen/logrus"
+)
+
+// secretsManagerValueFrom is the parsed form of a container secret valueFrom referencing a secretsmanager
+// secret, optionally with a JSON key, version stage or version id suffix.
+// https://docs.aws.amazon.com/AmazonECS/latest/developerguide/specifying-sensitive-data-secrets.html
+type secretsManagerValueFrom struct {
+	arn          string
+	jsonKey      string
+	versionStage string
+	versionID    string
+}
+
+// parseSecretsManagerValueFrom parses a secretsmanager valueFrom reference.  The reference is either the plain
+// secret ARN or the secret ARN followed by all three of the json-key, version-stage and version-id fields, any
+// of which may be empty (ie. 'arn:aws:secretsmanager:us-east-1:0123456789:secret:name-AbCdEf:jsonkey::').
+func parseSecretsManagerValueFrom(valueFrom string) (*secretsManagerValueFrom, error) {
+	a, err := arn.Parse(valueFrom)
+	if err != nil || a.Service != "secretsmanager" {
+		msg := fmt.Sprintf("invalid secretsmanager secret reference '%s'", valueFrom)
+		return nil, apierror.New(apierror.ErrBadRequest, msg, err)
+	}
+
+	// resource is secret:name[:json-key:version-stage:version-id]
+	parts := strings.Split(a.Resource, ":")
+	if parts[0] != "secret" || len(parts) < 2 || parts[1] == "" {
+		msg := fmt.Sprintf("invalid secretsmanager secret reference '%s'", valueFrom)
+		return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	a.Resource = strings.Join(parts[0:2], ":")
+	ref := &secretsManagerValueFrom{arn: a.String()}
+
+	switch len(parts) {
+	case 2:
+		return ref, nil
+	case 5:
+		ref.jsonKey, ref.versionStage, ref.versionID = parts[2], parts[3], parts[4]
+	default:
+		msg := fmt.Sprintf("invalid secretsmanager secret reference '%s', expected the json-key, version-stage and version-id fields (ie. '%s:jsonkey::')", valueFrom, a.String())
+		return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	if ref.versionStage != "" && ref.versionID != "" {
+		msg := fmt.Sprintf("invalid secretsmanager secret reference '%s', only one of version-stage or version-id can be set", valueFrom)
+		return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	return ref, nil
+}
+
+// String returns the valueFrom reference for the secret
+func (s *secretsManagerValueFrom) String() string {
+	if s.jsonKey == "" && s.versionStage == "" && s.versionID == "" {
+		return s.arn
+	}
+
+	return strings.Join([]string{s.arn, s.jsonKey, s.versionStage, s.versionID}, ":")
+}
+
+// secretKeyValueFrom returns the valueFrom reference to a single JSON key in a secretsmanager secret
+func secretKeyValueFrom(secretArn, key string) (string, error) {
+	if key == "" || strings.Contains(key, ":") {
+		msg := fmt.Sprintf("invalid json key '%s' for secret %s", key, secretArn)
+		return "", apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	ref, err := parseSecretsManagerValueFrom(secretArn)
+	if err != nil {
+		return "", err
+	}
+
+	if ref.jsonKey != "" && ref.jsonKey != key {
+		msg := fmt.Sprintf("secret reference %s already references the json key '%s'", secretArn, ref.jsonKey)
+		return "", apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+	ref.jsonKey = key
+
+	return ref.String(), nil
+}
+
+// applySecretKeys points container secrets at a single JSON key in their secretsmanager secret.  The secretKeys
+// map container definition names to a map of the container secret names and the JSON key to extract.
+func applySecretKeys(containerDefinitions []*ecs.ContainerDefinition, secretKeys map[string]map[string]string) error {
+	for containerName, keys := range secretKeys {
+		var container *ecs.ContainerDefinition
+		for _, cd := range containerDefinitions {
+			if cd != nil && aws.StringValue(cd.Name) == containerName {
+				container = cd
+				break
+			}
+		}
+
+		if container == nil {
+			msg := fmt.Sprintf("secret keys container definition %s not found", containerName)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		for secretName, key := range keys {
+			var secret *ecs.Secret
+			for _, s := range container.Secrets {
+				if s != nil && aws.StringValue(s.Name) == secretName {
+					secret = s
+					break
+				}
+			}
+
+			if secret == nil {
+				msg := fmt.Sprintf("secret %s not found in container definition %s", secretName, containerName)
+				return apierror.New(apierror.ErrBadRequest, msg, nil)
+			}
+
+			valueFrom, err := secretKeyValueFrom(aws.StringValue(secret.ValueFrom), key)
+			if err != nil {
+				return err
+			}
+
+			log.Debugf("setting container %s secret %s to reference %s", containerName, secretName, valueFrom)
+			secret.ValueFrom = aws.String(valueFrom)
+		}
+	}
+
+	return nil
+}
+
+// validateSecrets validates the container secrets that reference secretsmanager secrets.  Parameter store
+// references are left to ECS.
+func validateSecrets(container string, secrets []*ecs.Secret) error {
+	for _, s := range secrets {
+		if s == nil {
+			continue
+		}
+
+		valueFrom := aws.StringValue(s.ValueFrom)
+		if !strings.HasPrefix(valueFrom, "arn:") || !strings.Contains(valueFrom, ":secretsmanager:") {
+			continue
+		}
+
+		if _, err := parseSecretsManagerValueFrom(valueFrom); err != nil {
+			log.Warnf("invalid secret %s for container %s: %s", aws.StringValue(s.Name), container, err)
+			return err
+		}
+	}
+
+	return nil
+}
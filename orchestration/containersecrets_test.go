@@ -0,0 +1,196 @@
+package orchestration
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func Test_secretKeyValueFrom(t *testing.T) {
+	secretArn := "arn:aws:secretsmanager:us-east-1:0123456789:secret:spinup/myorg/clu1/dbcreds-AbCdEf"
+
+	tests := []struct {
+		name      string
+		secretArn string
+		key       string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "keyed reference",
+			secretArn: secretArn,
+			key:       "password",
+			want:      secretArn + ":password::",
+		},
+		{
+			name:      "keyed reference with a version stage",
+			secretArn: secretArn + "::AWSPREVIOUS:",
+			key:       "password",
+			want:      secretArn + ":password:AWSPREVIOUS:",
+		},
+		{
+			name:      "already keyed reference",
+			secretArn: secretArn + ":username::",
+			key:       "password",
+			wantErr:   true,
+		},
+		{
+			name:      "malformed key suffix",
+			secretArn: secretArn + ":password",
+			key:       "password",
+			wantErr:   true,
+		},
+		{
+			name:      "empty key",
+			secretArn: secretArn,
+			wantErr:   true,
+		},
+		{
+			name:      "key with a colon",
+			secretArn: secretArn,
+			key:       "pass:word",
+			wantErr:   true,
+		},
+		{
+			name:      "parameter store reference",
+			secretArn: "arn:aws:ssm:us-east-1:0123456789:parameter/myorg/clu1/password",
+			key:       "password",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := secretKeyValueFrom(tt.secretArn, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("secretKeyValueFrom() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("secretKeyValueFrom() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateSecrets(t *testing.T) {
+	secretArn := "arn:aws:secretsmanager:us-east-1:0123456789:secret:spinup/myorg/clu1/dbcreds-AbCdEf"
+
+	tests := []struct {
+		name      string
+		valueFrom string
+		wantErr   bool
+	}{
+		{
+			name:      "plain secret",
+			valueFrom: secretArn,
+		},
+		{
+			name:      "keyed secret",
+			valueFrom: secretArn + ":password::",
+		},
+		{
+			name:      "keyed secret with version id",
+			valueFrom: secretArn + ":password::EXAMPLE1-90ab-cdef-fedc-ba987EXAMPLE",
+		},
+		{
+			name:      "parameter store reference",
+			valueFrom: "arn:aws:ssm:us-east-1:0123456789:parameter/myorg/clu1/password",
+		},
+		{
+			name:      "parameter name",
+			valueFrom: "password",
+		},
+		{
+			name:      "malformed key suffix",
+			valueFrom: secretArn + ":password:",
+			wantErr:   true,
+		},
+		{
+			name:      "version stage and version id",
+			valueFrom: secretArn + ":password:AWSCURRENT:EXAMPLE1-90ab-cdef-fedc-ba987EXAMPLE",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSecrets("webserver", []*ecs.Secret{
+				{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String(tt.valueFrom)},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_applySecretKeys(t *testing.T) {
+	secretArn := "arn:aws:secretsmanager:us-east-1:0123456789:secret:spinup/myorg/clu1/dbcreds-AbCdEf"
+
+	tests := []struct {
+		name       string
+		secretKeys map[string]map[string]string
+		want       []*ecs.Secret
+		wantErr    bool
+	}{
+		{
+			name: "no secret keys",
+			want: []*ecs.Secret{
+				{Name: aws.String("DB_USERNAME"), ValueFrom: aws.String(secretArn)},
+				{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String(secretArn)},
+			},
+		},
+		{
+			name: "secret keys",
+			secretKeys: map[string]map[string]string{
+				"webserver": {
+					"DB_USERNAME": "username",
+					"DB_PASSWORD": "password",
+				},
+			},
+			want: []*ecs.Secret{
+				{Name: aws.String("DB_USERNAME"), ValueFrom: aws.String(secretArn + ":username::")},
+				{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String(secretArn + ":password::")},
+			},
+		},
+		{
+			name: "missing container",
+			secretKeys: map[string]map[string]string{
+				"sidecar": {"DB_PASSWORD": "password"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing secret",
+			secretKeys: map[string]map[string]string{
+				"webserver": {"API_KEY": "apikey"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cds := []*ecs.ContainerDefinition{
+				{
+					Name: aws.String("webserver"),
+					Secrets: []*ecs.Secret{
+						{Name: aws.String("DB_USERNAME"), ValueFrom: aws.String(secretArn)},
+						{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String(secretArn)},
+					},
+				},
+			}
+
+			err := applySecretKeys(cds, tt.secretKeys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("applySecretKeys() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err == nil && !reflect.DeepEqual(cds[0].Secrets, tt.want) {
+				t.Errorf("applySecretKeys() secrets = %+v, want %+v", cds[0].Secrets, tt.want)
+			}
+		})
+	}
+}
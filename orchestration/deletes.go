@@ -0,0 +1,130 @@
+package orchestration
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/YaleSpinup/apierror"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// PendingDeletes tracks the background cleanup of recursive deletes that hasn't started yet, keyed by
+// the token generated for the delete.  It's shared by all of the orchestrators of an api server.
+type PendingDeletes struct {
+	mu      sync.Mutex
+	pending map[string]*pendingDelete
+}
+
+// pendingDelete is the account and cluster of a pending delete and the function canceling its cleanup
+type pendingDelete struct {
+	account string
+	cluster string
+	cancel  context.CancelFunc
+}
+
+// NewPendingDeletes returns an empty set of pending deletes
+func NewPendingDeletes() *PendingDeletes {
+	return &PendingDeletes{pending: map[string]*pendingDelete{}}
+}
+
+// add registers a pending delete and returns false if the token is already in use
+func (p *PendingDeletes) add(token string, d *pendingDelete) bool {
+	if p == nil {
+		return true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.pending[token]; ok {
+		return false
+	}
+	p.pending[token] = d
+
+	return true
+}
+
+// remove removes a pending delete and returns true if it was still pending
+func (p *PendingDeletes) remove(token string) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.pending[token]; !ok {
+		return false
+	}
+	delete(p.pending, token)
+
+	return true
+}
+
+// cancel cancels and removes a pending delete in the account and cluster and returns true if it was still pending
+func (p *PendingDeletes) cancel(account, cluster, token string) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d, ok := p.pending[token]
+	if !ok || d.account != account || d.cluster != cluster {
+		return false
+	}
+	delete(p.pending, token)
+	d.cancel()
+
+	return true
+}
+
+// deleteAfterGracePeriod runs the cleanup of a recursive delete in the cluster in the background once the grace period
+// has passed.  Until then, the cleanup can be canceled with CancelDelete using the returned token.
+func (o *Orchestrator) deleteAfterGracePeriod(cluster string, cleanup func(ctx context.Context)) (string, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	token := uuid.NewV4().String()
+	if !o.PendingDeletes.add(token, &pendingDelete{account: o.Account, cluster: cluster, cancel: cancel}) {
+		cancel()
+		msg := fmt.Sprintf("pending delete token %s is already in use", token)
+		return "", apierror.New(apierror.ErrConflict, msg, nil)
+	}
+
+	go func() {
+		defer cancel()
+
+		timer := time.NewTimer(o.deleteGracePeriod())
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			log.Infof("pending delete %s was canceled, skipping the cleanup", token)
+			return
+		case <-timer.C:
+		}
+
+		// once the cleanup starts it can no longer be canceled
+		if o.PendingDeletes != nil && !o.PendingDeletes.remove(token) {
+			log.Infof("pending delete %s was canceled, skipping the cleanup", token)
+			return
+		}
+
+		cleanup(ctx)
+	}()
+
+	return token, nil
+}
+
+// CancelDelete cancels the background cleanup of a recursive delete in the cluster by its token, as long as the
+// cleanup hasn't started
+func (o *Orchestrator) CancelDelete(cluster, token string) error {
+	if !o.PendingDeletes.cancel(o.Account, cluster, token) {
+		msg := fmt.Sprintf("no pending delete with token %s in cluster %s, it may have already started", token, cluster)
+		return apierror.New(apierror.ErrNotFound, msg, nil)
+	}
+
+	log.Infof("canceled pending delete %s", token)
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestOrchestrator_deleteAfterGracePeriod(t *testing.T) {
+	tests := []struct {
+		name        string
+		cancel      bool
+		wantCleanup bool
+	}{
+		{
+			name:        "cleanup after the grace period",
+			wantCleanup: true,
+		},
+		{
+			name:   "canceled within the grace period",
+			cancel: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.Account = "acct1"
+			o.DeleteGracePeriod = 50 * time.Millisecond
+			o.PendingDeletes = NewPendingDeletes()
+
+			cleaned := make(chan struct{}, 1)
+			token, err := o.deleteAfterGracePeriod("clu1", func(ctx context.Context) {
+				cleaned <- struct{}{}
+			})
+			if err != nil {
+				t.Fatalf("expected nil error scheduling the cleanup, got %s", err)
+			}
+
+			if tt.cancel {
+				if err := o.CancelDelete("clu1", token); err != nil {
+					t.Fatalf("expected nil error canceling the pending delete, got %s", err)
+				}
+			}
+
+			select {
+			case <-cleaned:
+				if !tt.wantCleanup {
+					t.Error("expected the canceled cleanup not to run")
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.wantCleanup {
+					t.Error("expected the cleanup to run after the grace period")
+				}
+			}
+
+			// the delete can't be canceled once it's canceled or the cleanup has started
+			var aerr apierror.Error
+			if err := o.CancelDelete("clu1", token); !errors.As(err, &aerr) || aerr.Code != apierror.ErrNotFound {
+				t.Errorf("expected not found error canceling the delete again, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_deleteAfterGracePeriodTokens(t *testing.T) {
+	pending := NewPendingDeletes()
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	o.Account = "acct1"
+	o.Token = "0123-4567"
+	o.DeleteGracePeriod = time.Minute
+	o.PendingDeletes = pending
+
+	// deletes sent with the same request id get their own tokens
+	first, err := o.deleteAfterGracePeriod("clu1", func(ctx context.Context) {})
+	if err != nil {
+		t.Fatalf("expected nil error scheduling the first cleanup, got %s", err)
+	}
+
+	second, err := o.deleteAfterGracePeriod("clu1", func(ctx context.Context) {})
+	if err != nil {
+		t.Fatalf("expected nil error scheduling the second cleanup, got %s", err)
+	}
+
+	if first == second || first == o.Token {
+		t.Fatalf("expected unique server side tokens, got %s and %s", first, second)
+	}
+
+	if pending.add(first, &pendingDelete{account: "acct1", cluster: "clu1", cancel: func() {}}) {
+		t.Error("expected a token already in use to be rejected")
+	}
+
+	other := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	other.Account = "acct2"
+	other.PendingDeletes = pending
+
+	var aerr apierror.Error
+	if err := other.CancelDelete("clu1", first); !errors.As(err, &aerr) || aerr.Code != apierror.ErrNotFound {
+		t.Errorf("expected not found error canceling a delete in another account, got %v", err)
+	}
+
+	if err := o.CancelDelete("clu2", first); !errors.As(err, &aerr) || aerr.Code != apierror.ErrNotFound {
+		t.Errorf("expected not found error canceling a delete in another cluster, got %v", err)
+	}
+
+	for _, token := range []string{first, second} {
+		if err := o.CancelDelete("clu1", token); err != nil {
+			t.Errorf("expected nil error canceling pending delete %s, got %s", token, err)
+		}
+	}
+}
+
+func TestOrchestrator_DeleteServiceCancel(t *testing.T) {
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	o.Account = "acct1"
+	o.DeleteGracePeriod = time.Minute
+	o.PendingDeletes = NewPendingDeletes()
+	aas := &mockAASClient{t: t}
+	o.ApplicationAutoScaling.Service = aas
+
+	out, err := o.DeleteService(context.TODO(), &ServiceDeleteInput{
+		Cluster:   aws.String("clu1"),
+		Service:   aws.String("registered"),
+		Recursive: true,
+	})
+	if err != nil {
+		t.Fatalf("Orchestrator.DeleteService() unexpected error = %v", err)
+	}
+
+	if out.DeleteToken == "" {
+		t.Fatal("expected a delete token for the background cleanup")
+	}
+
+	if err := o.CancelDelete("clu1", out.DeleteToken); err != nil {
+		t.Fatalf("expected nil error canceling the recursive delete, got %s", err)
+	}
+
+	var aerr apierror.Error
+	if err := o.CancelDelete("clu1", "unknown"); !errors.As(err, &aerr) || aerr.Code != apierror.ErrNotFound {
+		t.Errorf("expected not found error canceling an unknown delete, got %v", err)
+	}
+
+	sd := o.ServiceDiscovery.Service.(*mockSDClient)
+	if len(sd.deleted) != 0 || len(aas.deregisteredTargets) != 0 {
+		t.Errorf("expected no dependencies to be deleted, got service registries %v and scalable targets %v", sd.deleted, aas.deregisteredTargets)
+	}
+}
@@ -0,0 +1,124 @@
+package orchestration
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/YaleSpinup/apierror"
+	yiam "github.com/YaleSpinup/aws-go/services/iam"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// environmentFileAction is the action granted to the task execution role on the referenced environment files
+const environmentFileAction = "s3:GetObject"
+
+// validateEnvironmentFiles ensures the environment files for a container are s3 objects referenced by ARN
+// (arn:aws:s3:::bucket/key.env).  ECS only supports files with the .env extension.
+func validateEnvironmentFiles(container string, files []*ecs.EnvironmentFile) error {
+	for _, f := range files {
+		if f == nil {
+			continue
+		}
+
+		if t := aws.StringValue(f.Type); t != ecs.EnvironmentFileTypeS3 {
+			msg := fmt.Sprintf("invalid environment file type '%s' for container %s, only %s is supported", t, container, ecs.EnvironmentFileTypeS3)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		value := aws.StringValue(f.Value)
+		if _, _, err := parseEnvironmentFileArn(value); err != nil {
+			msg := fmt.Sprintf("invalid environment file '%s' for container %s: %s", value, container, err)
+			return apierror.New(apierror.ErrBadRequest, msg, err)
+		}
+	}
+
+	return nil
+}
+
+// parseEnvironmentFileArn parses an s3 object ARN and returns the bucket and key
+func parseEnvironmentFileArn(a string) (string, string, error) {
+	parsed, err := arn.Parse(a)
+	if err != nil {
+		return "", "", err
+	}
+
+	if parsed.Service != "s3" || parsed.Region != "" || parsed.AccountID != "" {
+		return "", "", fmt.Errorf("expected an s3 object arn (arn:aws:s3:::bucket/key)")
+	}
+
+	bucket, key, ok := strings.Cut(parsed.Resource, "/")
+	if !ok || bucket == "" || key == "" {
+		return "", "", fmt.Errorf("expected an s3 object arn (arn:aws:s3:::bucket/key)")
+	}
+
+	if !strings.HasSuffix(key, ".env") {
+		return "", "", fmt.Errorf("environment file key must have the .env extension")
+	}
+
+	return bucket, key, nil
+}
+
+// environmentFileArns returns the sorted, unique s3 object ARNs of the environment files referenced by the container definitions
+func environmentFileArns(containerDefinitions []*ecs.ContainerDefinition) []string {
+	arns := []string{}
+	for _, cd := range containerDefinitions {
+		if cd == nil {
+			continue
+		}
+
+		for _, f := range cd.EnvironmentFiles {
+			if f == nil || aws.StringValue(f.Type) != ecs.EnvironmentFileTypeS3 {
+				continue
+			}
+			arns = append(arns, aws.StringValue(f.Value))
+		}
+	}
+
+	return uniqueSortedStrings(arns)
+}
+
+// policyEnvironmentFileArns returns the environment file ARNs already granted by a task execution policy
+func policyEnvironmentFileArns(policy yiam.PolicyDocument) []string {
+	arns := []string{}
+	for _, s := range policy.Statement {
+		if len(s.Action) != 1 || s.Action[0] != environmentFileAction {
+			continue
+		}
+		arns = append(arns, s.Resource...)
+	}
+
+	return arns
+}
+
+// environmentFileBucketArns returns the sorted, unique s3 bucket ARNs for the environment file ARNs
+func environmentFileBucketArns(arns []string) []string {
+	buckets := []string{}
+	for _, a := range arns {
+		bucket, _, err := parseEnvironmentFileArn(a)
+		if err != nil {
+			continue
+		}
+		buckets = append(buckets, "arn:aws:s3:::"+bucket)
+	}
+
+	return uniqueSortedStrings(buckets)
+}
+
+// uniqueSortedStrings returns the sorted unique elements of the list
+func uniqueSortedStrings(list []string) []string {
+	seen := map[string]struct{}{}
+	out := []string{}
+	for _, s := range list {
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+	sort.Strings(out)
+
+	return out
+}
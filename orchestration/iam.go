@@ -4,22 +4,41 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/YaleSpinup/apierror"
 	yiam "github.com/YaleSpinup/aws-go/services/iam"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/iam"
 	log "github.com/sirupsen/logrus"
 )
 
 var assumeRolePolicyDoc []byte
 
-// defaultTaskExecutionPolicy generates the default policy for ECS task execution
-func defaultTaskExecutionPolicy(path, kms string) yiam.PolicyDocument {
+const (
+	scheduledTaskPolicyName     = "ECSScheduledTaskPolicy"
+	scheduledTaskRunAction      = "ecs:RunTask"
+	scheduledTaskPassRoleAction = "iam:PassRole"
+)
+
+// defaultTaskExecutionPolicy generates the default policy for ECS task execution.  Decrypt is granted with the
+// default kms key and any kms key ARNs passed (ie. the keys encrypting the repository credentials).  If any environment
+// files are passed, read access is granted to those s3 objects.
+func defaultTaskExecutionPolicy(path, kms string, kmsKeyArns []string, envFiles ...string) yiam.PolicyDocument {
 	log.Debugf("generating default task execution policy for %s", path)
 
-	return yiam.PolicyDocument{
+	decryptResources := []string{
+		fmt.Sprintf("arn:aws:secretsmanager:*:*:secret:spinup/%s/*", path),
+		fmt.Sprintf("arn:aws:ssm:*:*:parameter/%s/*", path),
+		fmt.Sprintf("arn:aws:kms:*:*:key/%s", kms),
+	}
+	decryptResources = append(decryptResources, kmsKeyArns...)
+
+	policy := yiam.PolicyDocument{
 		Version: "2012-10-17",
 		Statement: []yiam.StatementEntry{
 			{
@@ -39,11 +58,7 @@ func defaultTaskExecutionPolicy(path, kms string) yiam.PolicyDocument {
 					"ssm:GetParameters",
 					"kms:Decrypt",
 				},
-				Resource: []string{
-					fmt.Sprintf("arn:aws:secretsmanager:*:*:secret:spinup/%s/*", path),
-					fmt.Sprintf("arn:aws:ssm:*:*:parameter/%s/*", path),
-					fmt.Sprintf("arn:aws:kms:*:*:key/%s", kms),
-				},
+				Resource: decryptResources,
 			},
 			{
 				Effect: "Allow",
@@ -69,17 +84,60 @@ func defaultTaskExecutionPolicy(path, kms string) yiam.PolicyDocument {
 			},
 		},
 	}
+
+	if len(envFiles) > 0 {
+		policy.Statement = append(policy.Statement,
+			yiam.StatementEntry{
+				Effect:   "Allow",
+				Action:   []string{environmentFileAction},
+				Resource: envFiles,
+			},
+			yiam.StatementEntry{
+				Effect:   "Allow",
+				Action:   []string{"s3:GetBucketLocation"},
+				Resource: environmentFileBucketArns(envFiles),
+			},
+		)
+	}
+
+	return policy
 }
 
-// DefaultTaskExecutionRole generates the default role (if it doesn't exist) for ECS task execution and returns the ARN
-func (o *Orchestrator) DefaultTaskExecutionRole(ctx context.Context, path, role string, tags []*Tag) (string, error) {
+// policyKmsKeyArns returns the kms key ARNs granted decrypt by a task execution policy, in addition to the
+// default key which is granted in any region and account
+func policyKmsKeyArns(policy yiam.PolicyDocument) []string {
+	keys := []string{}
+	for _, s := range policy.Statement {
+		if !stringInSlice("kms:Decrypt", s.Action) {
+			continue
+		}
+
+		for _, r := range s.Resource {
+			a, err := arn.Parse(r)
+			if err != nil || a.Service != "kms" || a.Region == "*" || a.AccountID == "*" {
+				continue
+			}
+
+			keys = append(keys, r)
+		}
+	}
+
+	return keys
+}
+
+// DefaultTaskExecutionRole generates the default role (if it doesn't exist) for ECS task execution and returns the ARN.  If
+// DisableTaskExecutionRoleCreation is set, the role must already exist and is returned without being modified.  The role
+// is shared by the task definitions in a cluster, so the environment files and kms keys are merged with those already granted.
+func (o *Orchestrator) DefaultTaskExecutionRole(ctx context.Context, path, role string, tags []*Tag, envFiles, kmsKeyArns []string) (string, error) {
 	if path == "" || role == "" {
 		return "", apierror.New(apierror.ErrBadRequest, "invalid path", nil)
 	}
 
-	log.Infof("generating default task execution role %s/%s if it doesn't exist ", path, role)
+	if o.DisableTaskExecutionRoleCreation {
+		return o.existingTaskExecutionRole(ctx, path, role)
+	}
 
-	defaultPolicy := defaultTaskExecutionPolicy(path, o.IAM.DefaultKmsKeyID)
+	log.Infof("generating default task execution role %s/%s if it doesn't exist ", path, role)
 
 	var roleArn string
 	if out, err := o.IAM.GetRole(ctx, role); err != nil {
@@ -117,6 +175,10 @@ func (o *Orchestrator) DefaultTaskExecutionRole(ctx context.Context, path, role
 				return "", err
 			}
 
+			envFiles = append(envFiles, policyEnvironmentFileArns(currentPolicy)...)
+			kmsKeyArns = append(kmsKeyArns, policyKmsKeyArns(currentPolicy)...)
+			defaultPolicy := defaultTaskExecutionPolicy(path, o.IAM.DefaultKmsKeyID, uniqueSortedStrings(kmsKeyArns), uniqueSortedStrings(envFiles)...)
+
 			// if the current policy matches the generated (default) policy, return
 			// the role ARN otherwise, keep going and update the policy doc
 			if yiam.PolicyDeepEqual(defaultPolicy, currentPolicy) {
@@ -129,6 +191,7 @@ func (o *Orchestrator) DefaultTaskExecutionRole(ctx context.Context, path, role
 
 	}
 
+	defaultPolicy := defaultTaskExecutionPolicy(path, o.IAM.DefaultKmsKeyID, uniqueSortedStrings(kmsKeyArns), uniqueSortedStrings(envFiles)...)
 	defaultPolicyDoc, err := json.Marshal(defaultPolicy)
 	if err != nil {
 		log.Errorf("failed creating default IAM task execution policy for %s: %s", path, err.Error())
@@ -160,6 +223,25 @@ func (o *Orchestrator) DefaultTaskExecutionRole(ctx context.Context, path, role
 	return roleArn, nil
 }
 
+// existingTaskExecutionRole returns the ARN of a pre-created task execution role.  It's used when the creation of task execution
+// roles is disabled, so a missing role is a bad request that has to be fixed by the operator.
+func (o *Orchestrator) existingTaskExecutionRole(ctx context.Context, path, role string) (string, error) {
+	out, err := o.IAM.GetRole(ctx, role)
+	if err != nil {
+		if aerr, ok := err.(apierror.Error); ok && aerr.Code == apierror.ErrNotFound {
+			msg := fmt.Sprintf("task execution role %s for %s doesn't exist and role creation is disabled, the role must be created before it can be used", role, path)
+			return "", apierror.New(apierror.ErrBadRequest, msg, err)
+		}
+		return "", err
+	}
+
+	roleArn := aws.StringValue(out.Arn)
+
+	log.Infof("using existing task execution role %s with ARN: %s", role, roleArn)
+
+	return roleArn, nil
+}
+
 // createDefaultTaskExecutionRole handles creating the default task execution role.  it does not leverage the
 // path for the role currently since we already have many container services with the "/" path.
 // TODO: revisit moving to a non-default path for the task execution role
@@ -170,7 +252,7 @@ func (o *Orchestrator) createDefaultTaskExecutionRole(ctx context.Context, path,
 
 	log.Debugf("creating default task execution role %s", role)
 
-	assumeRolePolicyDoc, err := assumeRolePolicy()
+	assumeRolePolicyDoc, err := assumeRolePolicy(o.IAM.TaskExecutionTrustedServices, o.IAM.TaskExecutionTrustedPrincipals)
 	if err != nil {
 		log.Errorf("failed to generate default task execution role assume policy for %s: %s", path, err)
 		return "", err
@@ -191,12 +273,36 @@ func (o *Orchestrator) createDefaultTaskExecutionRole(ctx context.Context, path,
 	return aws.StringValue(roleOutput.Arn), nil
 }
 
-// assumeRolePolicy generates the policy document to allow the ecs service to assume a role
-func assumeRolePolicy() (string, error) {
-	if assumeRolePolicyDoc != nil {
+// assumeRolePolicy generates the policy document to allow the ecs service to assume a role.  Additional services and
+// AWS principals are merged into the trusted principals, ecs-tasks.amazonaws.com is always trusted.
+func assumeRolePolicy(services, principals []string) (string, error) {
+	extended := len(services) > 0 || len(principals) > 0
+	if !extended && assumeRolePolicyDoc != nil {
 		return string(assumeRolePolicyDoc), nil
 	}
 
+	trustedServices := []string{"ecs-tasks.amazonaws.com"}
+	for _, s := range services {
+		if !stringInSlice(s, trustedServices) {
+			trustedServices = append(trustedServices, s)
+		}
+	}
+
+	principal := yiam.Principal{
+		"Service": trustedServices,
+	}
+
+	trustedPrincipals := []string{}
+	for _, p := range principals {
+		if !stringInSlice(p, trustedPrincipals) {
+			trustedPrincipals = append(trustedPrincipals, p)
+		}
+	}
+
+	if len(trustedPrincipals) > 0 {
+		principal["AWS"] = trustedPrincipals
+	}
+
 	policyDoc, err := json.Marshal(yiam.PolicyDocument{
 		Version: "2012-10-17",
 		Statement: []yiam.StatementEntry{
@@ -205,9 +311,7 @@ func assumeRolePolicy() (string, error) {
 				Action: []string{
 					"sts:AssumeRole",
 				},
-				Principal: yiam.Principal{
-					"Service": {"ecs-tasks.amazonaws.com"},
-				},
+				Principal: principal,
 			},
 		},
 	})
@@ -215,13 +319,21 @@ func assumeRolePolicy() (string, error) {
 		return "", err
 	}
 
-	// cache result since it doesn't change
-	assumeRolePolicyDoc = policyDoc
+	// cache the default result since it doesn't change
+	if !extended {
+		assumeRolePolicyDoc = policyDoc
+	}
 
 	return string(policyDoc), nil
 }
 
 func (o *Orchestrator) deleteDefaultTaskExecutionRole(ctx context.Context, role string) error {
+	// roles that weren't created by the api are left alone
+	if o.DisableTaskExecutionRoleCreation {
+		log.Infof("task execution role creation is disabled, not deleting role %s", role)
+		return nil
+	}
+
 	policies, err := o.IAM.ListRolePolicies(ctx, role)
 	if err != nil {
 		return err
@@ -241,3 +353,329 @@ func (o *Orchestrator) deleteDefaultTaskExecutionRole(ctx context.Context, role
 
 	return nil
 }
+
+// scheduledTaskPolicy generates the policy that allows EventBridge to run the task definition families in the cluster.
+// RunTask is conditioned on the cluster and the roles of the task definitions can only be passed to ECS tasks.
+func scheduledTaskPolicy(clusterArn string, taskDefs, passRoles []string) yiam.PolicyDocument {
+	a, _ := arn.Parse(clusterArn)
+	cluster := strings.TrimPrefix(a.Resource, "cluster/")
+
+	return yiam.PolicyDocument{
+		Version: "2012-10-17",
+		Statement: []yiam.StatementEntry{
+			{
+				Effect:   "Allow",
+				Action:   []string{scheduledTaskRunAction},
+				Resource: taskDefs,
+				Condition: yiam.Condition{
+					"ArnEquals": yiam.ConditionStatement{
+						"ecs:cluster": []string{clusterArn},
+					},
+				},
+			},
+			{
+				Effect:   "Allow",
+				Action:   []string{"ecs:TagResource"},
+				Resource: []string{fmt.Sprintf("arn:%s:ecs:%s:%s:task/%s/*", a.Partition, a.Region, a.AccountID, cluster)},
+				Condition: yiam.Condition{
+					"StringEquals": yiam.ConditionStatement{
+						"ecs:CreateAction": []string{"RunTask"},
+					},
+				},
+			},
+			{
+				Effect:   "Allow",
+				Action:   []string{scheduledTaskPassRoleAction},
+				Resource: passRoles,
+				Condition: yiam.Condition{
+					"StringLike": yiam.ConditionStatement{
+						"iam:PassedToService": []string{"ecs-tasks.amazonaws.com"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// policyActionResources returns the resources of the statements in the policy that only grant the action
+func policyActionResources(policy yiam.PolicyDocument, action string) []string {
+	resources := []string{}
+	for _, s := range policy.Statement {
+		if len(s.Action) != 1 || s.Action[0] != action {
+			continue
+		}
+		resources = append(resources, s.Resource...)
+	}
+
+	return resources
+}
+
+// ScheduledTaskRole generates the role (if it doesn't exist) that EventBridge assumes to run the task definition in the
+// cluster and returns the ARN.  The role trusts events.amazonaws.com and is shared by the schedules in a cluster, so the
+// task definition families and the roles they pass are merged with those already granted.  If
+// DisableTaskExecutionRoleCreation is set, the role must already exist and is returned without being modified.
+func (o *Orchestrator) ScheduledTaskRole(ctx context.Context, cluster, clusterArn string, td *ecs.TaskDefinition, tags []*Tag) (string, error) {
+	role := fmt.Sprintf("%s-ecsEvents", cluster)
+
+	if o.DisableTaskExecutionRoleCreation {
+		out, err := o.IAM.GetRole(ctx, role)
+		if err != nil {
+			if aerr, ok := err.(apierror.Error); ok && aerr.Code == apierror.ErrNotFound {
+				msg := fmt.Sprintf("scheduled task role %s doesn't exist and role creation is disabled, the role must be created before tasks can be scheduled", role)
+				return "", apierror.New(apierror.ErrBadRequest, msg, err)
+			}
+			return "", err
+		}
+		return aws.StringValue(out.Arn), nil
+	}
+
+	if !arn.IsARN(clusterArn) {
+		msg := fmt.Sprintf("invalid cluster arn %s", clusterArn)
+		return "", apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	// grant every revision of the family, schedules can run the latest revision
+	tdArn := aws.StringValue(td.TaskDefinitionArn)
+	taskDefs := []string{strings.TrimSuffix(tdArn, fmt.Sprintf(":%d", aws.Int64Value(td.Revision))) + ":*"}
+	passRoles := []string{aws.StringValue(td.ExecutionRoleArn)}
+	if taskRoleArn := aws.StringValue(td.TaskRoleArn); taskRoleArn != "" {
+		passRoles = append(passRoles, taskRoleArn)
+	}
+
+	log.Infof("generating scheduled task role %s if it doesn't exist", role)
+
+	var roleArn string
+	if out, err := o.IAM.GetRole(ctx, role); err != nil {
+		if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+			return "", err
+		}
+
+		log.Debugf("unable to find role %s, creating", role)
+
+		policyDoc, err := json.Marshal(yiam.PolicyDocument{
+			Version: "2012-10-17",
+			Statement: []yiam.StatementEntry{
+				{
+					Effect:    "Allow",
+					Action:    []string{"sts:AssumeRole"},
+					Principal: yiam.Principal{"Service": []string{"events.amazonaws.com"}},
+				},
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+
+		out, err := o.IAM.CreateRole(ctx, &iam.CreateRoleInput{
+			AssumeRolePolicyDocument: aws.String(string(policyDoc)),
+			Description:              aws.String(fmt.Sprintf("EventBridge role to run scheduled tasks in %s", cluster)),
+			Path:                     aws.String("/"),
+			RoleName:                 aws.String(role),
+		})
+		if err != nil {
+			return "", err
+		}
+
+		roleArn = aws.StringValue(out.Arn)
+
+		log.Infof("created role %s with ARN: %s", role, roleArn)
+	} else {
+		roleArn = aws.StringValue(out.Arn)
+
+		currentDoc, err := o.IAM.GetRolePolicy(ctx, role, scheduledTaskPolicyName)
+		if err != nil {
+			if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+				return "", err
+			}
+		} else {
+			var currentPolicy yiam.PolicyDocument
+			if err := json.Unmarshal([]byte(currentDoc), &currentPolicy); err != nil {
+				log.Errorf("failed to unmarshall policy from document: %s", err)
+				return "", err
+			}
+
+			taskDefs = append(taskDefs, policyActionResources(currentPolicy, scheduledTaskRunAction)...)
+			passRoles = append(passRoles, policyActionResources(currentPolicy, scheduledTaskPassRoleAction)...)
+
+			if yiam.PolicyDeepEqual(scheduledTaskPolicy(clusterArn, uniqueSortedStrings(taskDefs), uniqueSortedStrings(passRoles)), currentPolicy) {
+				log.Debugf("inline policy for role %s is up to date", role)
+				return roleArn, nil
+			}
+		}
+	}
+
+	policyDoc, err := json.Marshal(scheduledTaskPolicy(clusterArn, uniqueSortedStrings(taskDefs), uniqueSortedStrings(passRoles)))
+	if err != nil {
+		log.Errorf("failed creating scheduled task policy for %s: %s", cluster, err)
+		return "", err
+	}
+
+	if err := o.IAM.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		PolicyDocument: aws.String(string(policyDoc)),
+		PolicyName:     aws.String(scheduledTaskPolicyName),
+		RoleName:       aws.String(role),
+	}); err != nil {
+		return "", err
+	}
+
+	if len(tags) > 0 {
+		iamTags := make([]*iam.Tag, len(tags))
+		for i, t := range tags {
+			iamTags[i] = &iam.Tag{Key: t.Key, Value: t.Value}
+		}
+
+		if err := o.IAM.TagRole(ctx, role, iamTags); err != nil {
+			return "", err
+		}
+	}
+
+	return roleArn, nil
+}
+
+// deleteScheduledTaskRole deletes the role used by the schedules in a cluster, if one was created
+func (o *Orchestrator) deleteScheduledTaskRole(ctx context.Context, cluster string) error {
+	role := fmt.Sprintf("%s-ecsEvents", cluster)
+	if err := o.deleteDefaultTaskExecutionRole(ctx, role); err != nil {
+		if aerr, ok := err.(apierror.Error); ok && aerr.Code == apierror.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// taskRole returns the role assumed by the containers in a task.  If the caller supplied a task role ARN, it's
+// validated to exist in the account of the default role and to belong to the org (or one of the allowed orgs),
+// either by its spinup:org tag or by its path (ie. /{org}/...).  Otherwise the default task execution role is
+// shared for backwards compatibility.
+func (o *Orchestrator) taskRole(ctx context.Context, taskRoleArn *string, defaultRoleArn string) (*string, error) {
+	if aws.StringValue(taskRoleArn) == "" {
+		return aws.String(defaultRoleArn), nil
+	}
+
+	roleArn := aws.StringValue(taskRoleArn)
+	a, err := arn.Parse(roleArn)
+	if err != nil || a.Service != "iam" || !strings.HasPrefix(a.Resource, "role/") {
+		msg := fmt.Sprintf("invalid task role arn %s", roleArn)
+		return nil, apierror.New(apierror.ErrBadRequest, msg, err)
+	}
+
+	if d, err := arn.Parse(defaultRoleArn); err == nil && d.AccountID != a.AccountID {
+		msg := fmt.Sprintf("task role %s isn't in account %s", roleArn, d.AccountID)
+		return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	// the role name is the last element of the resource, after any path
+	roleName := a.Resource[strings.LastIndex(a.Resource, "/")+1:]
+
+	log.Debugf("validating task role %s exists", roleName)
+
+	role, err := o.IAM.GetRole(ctx, roleName)
+	if err != nil {
+		if aerr, ok := err.(apierror.Error); ok && aerr.Code == apierror.ErrNotFound {
+			msg := fmt.Sprintf("task role %s doesn't exist", roleArn)
+			return nil, apierror.New(apierror.ErrBadRequest, msg, err)
+		}
+		return nil, err
+	}
+
+	// role names are unique in the account, a different path is a different (possibly deleted and recreated) role
+	if aws.StringValue(role.Arn) != roleArn {
+		msg := fmt.Sprintf("task role %s doesn't exist", roleArn)
+		return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	if !o.orgTaskRole(role) {
+		msg := fmt.Sprintf("task role %s doesn't belong to the org", roleArn)
+		return nil, apierror.New(apierror.ErrForbidden, msg, nil)
+	}
+
+	return role.Arn, nil
+}
+
+// orgTaskRole returns true if the role is tagged with the org (or one of the allowed orgs), or is under the org path
+func (o *Orchestrator) orgTaskRole(role *iam.Role) bool {
+	for _, t := range role.Tags {
+		if aws.StringValue(t.Key) == "spinup:org" && AllowedOrg(o.Org, o.AllowedOrgs, aws.StringValue(t.Value)) {
+			return true
+		}
+	}
+
+	for _, org := range o.orgs() {
+		if org != "" && strings.HasPrefix(aws.StringValue(role.Path), "/"+org+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TaskDefRole is an IAM role referenced by a task definition
+type TaskDefRole struct {
+	Arn  string
+	Name string
+	// Policies are the names of the inline policies of the role, they're only listed when requested
+	Policies []string `json:",omitempty"`
+}
+
+// TaskDefRolesOutput is the execution and task role referenced by a task definition
+type TaskDefRolesOutput struct {
+	TaskDefinition string
+	ExecutionRole  *TaskDefRole `json:",omitempty"`
+	TaskRole       *TaskDefRole `json:",omitempty"`
+}
+
+// TaskDefRoles returns the execution and task role referenced by a task definition in a cluster.  When policies is
+// true, the inline policy names of each role are listed as well and a role that no longer exists is not found.
+func (o *Orchestrator) TaskDefRoles(ctx context.Context, cluster, family string, policies bool) (*TaskDefRolesOutput, error) {
+	if cluster == "" || family == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "cluster and task def family are required", nil)
+	}
+
+	log.Debugf("getting task definition roles for %s/%s", cluster, family)
+
+	td, err := o.clusterTaskDefinition(ctx, cluster, family)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &TaskDefRolesOutput{TaskDefinition: aws.StringValue(td.TaskDefinitionArn)}
+	if output.ExecutionRole, err = o.taskDefRole(ctx, td.ExecutionRoleArn, policies); err != nil {
+		return nil, err
+	}
+
+	if output.TaskRole, err = o.taskDefRole(ctx, td.TaskRoleArn, policies); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// taskDefRole returns the role for a role ARN referenced by a task definition, optionally listing its inline policies
+func (o *Orchestrator) taskDefRole(ctx context.Context, roleArn *string, policies bool) (*TaskDefRole, error) {
+	if aws.StringValue(roleArn) == "" {
+		return nil, nil
+	}
+
+	// the role name is the last element of the resource, after any path
+	role := &TaskDefRole{Arn: aws.StringValue(roleArn)}
+	role.Name = role.Arn[strings.LastIndex(role.Arn, "/")+1:]
+
+	if !policies {
+		return role, nil
+	}
+
+	names, err := o.IAM.ListRolePolicies(ctx, role.Name)
+	if err != nil {
+		if aerr, ok := err.(apierror.Error); ok && aerr.Code == apierror.ErrNotFound {
+			msg := fmt.Sprintf("role %s not found", role.Arn)
+			return nil, apierror.New(apierror.ErrNotFound, msg, err)
+		}
+		return nil, err
+	}
+	sort.Strings(names)
+	role.Policies = names
+
+	return role, nil
+}
@@ -3,16 +3,19 @@ package orchestration
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/YaleSpinup/apierror"
 	yiam "github.com/YaleSpinup/aws-go/services/iam"
 	im "github.com/YaleSpinup/ecs-api/iam"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/iam"
 )
@@ -92,7 +95,27 @@ var outdatedPolicyDoc = yiam.PolicyDocument{
 	},
 }
 
+var envFilesPolicyDoc = defaultTaskExecutionPolicy("org/envfiles", "123", nil, "arn:aws:s3:::other-bucket/app.env")
+
+var kmsKeysPolicyDoc = defaultTaskExecutionPolicy("org/kmskeys", "123", []string{testSecretKmsKeyArn})
+
 var testRoles = map[string]iam.Role{
+	"envfiles-ecsTaskExecution": {
+		Arn:         aws.String("arn:aws:iam::12345678910:role/envfiles-ecsTaskExecution"),
+		CreateDate:  &testTime,
+		Description: aws.String("role model"),
+		Path:        aws.String("/"),
+		RoleId:      aws.String("TESTROLEID789"),
+		RoleName:    aws.String("envfiles-ecsTaskExecution"),
+	},
+	"kmskeys-ecsTaskExecution": {
+		Arn:         aws.String("arn:aws:iam::12345678910:role/kmskeys-ecsTaskExecution"),
+		CreateDate:  &testTime,
+		Description: aws.String("role model"),
+		Path:        aws.String("/"),
+		RoleId:      aws.String("TESTROLEID790"),
+		RoleName:    aws.String("kmskeys-ecsTaskExecution"),
+	},
 	"super-why-ecsTaskExecution": {
 		Arn:         aws.String("arn:aws:iam::12345678910:role/super-why-ecsTaskExecution"),
 		CreateDate:  &testTime,
@@ -125,6 +148,40 @@ var testRoles = map[string]iam.Role{
 		RoleId:      aws.String("TESTROLEID000"),
 		RoleName:    aws.String("badpolicy-ecsTaskExecution"),
 	},
+	"super-why-app": {
+		Arn:         aws.String("arn:aws:iam::12345678910:role/org/super-why/super-why-app"),
+		CreateDate:  &testTime,
+		Description: aws.String("application role"),
+		Path:        aws.String("/org/super-why/"),
+		RoleId:      aws.String("TESTROLEID456"),
+		RoleName:    aws.String("super-why-app"),
+		Tags:        []*iam.Tag{{Key: aws.String("spinup:org"), Value: aws.String("myorg")}},
+	},
+	"myorg-path-app": {
+		Arn:         aws.String("arn:aws:iam::12345678910:role/myorg/super-why/myorg-path-app"),
+		CreateDate:  &testTime,
+		Description: aws.String("application role under the org path"),
+		Path:        aws.String("/myorg/super-why/"),
+		RoleId:      aws.String("TESTROLEID457"),
+		RoleName:    aws.String("myorg-path-app"),
+	},
+	"otherorg-app": {
+		Arn:         aws.String("arn:aws:iam::12345678910:role/otherorg-app"),
+		CreateDate:  &testTime,
+		Description: aws.String("application role of another org"),
+		Path:        aws.String("/"),
+		RoleId:      aws.String("TESTROLEID458"),
+		RoleName:    aws.String("otherorg-app"),
+		Tags:        []*iam.Tag{{Key: aws.String("spinup:org"), Value: aws.String("otherorg")}},
+	},
+	"admin": {
+		Arn:         aws.String("arn:aws:iam::12345678910:role/admin"),
+		CreateDate:  &testTime,
+		Description: aws.String("account administrator role"),
+		Path:        aws.String("/"),
+		RoleId:      aws.String("TESTROLEID459"),
+		RoleName:    aws.String("admin"),
+	},
 }
 
 func (m *mockIAMClient) GetRoleWithContext(ctx context.Context, input *iam.GetRoleInput, opts ...request.Option) (*iam.GetRoleOutput, error) {
@@ -166,6 +223,8 @@ func (m *mockIAMClient) CreateRoleWithContext(ctx context.Context, input *iam.Cr
 		return nil, m.err
 	}
 
+	m.assumeRolePolicy = aws.StringValue(input.AssumeRolePolicyDocument)
+
 	return output, nil
 }
 
@@ -190,6 +249,10 @@ func (m *mockIAMClient) GetRolePolicyWithContext(ctx context.Context, input *iam
 		p = defaultPolicyDoc
 	} else if aws.StringValue(input.RoleName) == "mr-rogers-ecsTaskExecution" {
 		p = outdatedPolicyDoc
+	} else if aws.StringValue(input.RoleName) == "envfiles-ecsTaskExecution" {
+		p = envFilesPolicyDoc
+	} else if aws.StringValue(input.RoleName) == "kmskeys-ecsTaskExecution" {
+		p = kmsKeysPolicyDoc
 	} else {
 		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "role not found", nil)
 	}
@@ -205,6 +268,25 @@ func (m *mockIAMClient) GetRolePolicyWithContext(ctx context.Context, input *iam
 	}, nil
 }
 
+// testRolePolicies are the inline policy names of each role
+var testRolePolicies = map[string][]string{
+	"mr-rogers-ecsTaskExecution": {"ECSTaskAccessPolicy"},
+	"super-why-app":              {"sqs-access", "s3-access"},
+}
+
+func (m *mockIAMClient) ListRolePoliciesWithContext(ctx context.Context, input *iam.ListRolePoliciesInput, opts ...request.Option) (*iam.ListRolePoliciesOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	names, ok := testRolePolicies[aws.StringValue(input.RoleName)]
+	if !ok {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "role not found", nil)
+	}
+
+	return &iam.ListRolePoliciesOutput{PolicyNames: aws.StringSlice(names)}, nil
+}
+
 func (m *mockIAMClient) PutRolePolicyWithContext(ctx context.Context, input *iam.PutRolePolicyInput, opts ...request.Option) (*iam.PutRolePolicyOutput, error) {
 	var output = &iam.PutRolePolicyOutput{}
 
@@ -217,13 +299,16 @@ func (m *mockIAMClient) PutRolePolicyWithContext(ctx context.Context, input *iam
 		return nil, m.err
 	}
 
+	m.rolePolicy = aws.StringValue(input.PolicyDocument)
+
 	return output, nil
 }
 
 func Test_defaultTaskExecutionPolicy(t *testing.T) {
 	type args struct {
-		path string
-		kms  string
+		path       string
+		kms        string
+		kmsKeyArns []string
 	}
 	tests := []struct {
 		name           string
@@ -239,12 +324,20 @@ func Test_defaultTaskExecutionPolicy(t *testing.T) {
 			},
 			want:           defaultPolicyDoc,
 			wantMarshalled: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["ecr:GetAuthorizationToken","logs:CreateLogGroup","logs:CreateLogStream","logs:PutLogEvents"],"Resource":["*"]},{"Effect":"Allow","Action":["secretsmanager:GetSecretValue","ssm:GetParameters","kms:Decrypt"],"Resource":["arn:aws:secretsmanager:*:*:secret:spinup/org/super-why/*","arn:aws:ssm:*:*:parameter/org/super-why/*","arn:aws:kms:*:*:key/123"]},{"Effect":"Allow","Action":["elasticfilesystem:ClientRootAccess","elasticfilesystem:ClientWrite","elasticfilesystem:ClientMount"],"Resource":["*"],"Condition":{"Bool":{"elasticfilesystem:AccessedViaMountTarget":["true"]},"StringEqualsIgnoreCase":{"aws:ResourceTag/spinup:org":["${aws:PrincipalTag/spinup:org}"],"aws:ResourceTag/spinup:spaceid":["${aws:PrincipalTag/spinup:spaceid}"]}}}]}`,
+		}, {
+			name: "secret kms key",
+			args: args{
+				path:       pathPrefix,
+				kms:        "123",
+				kmsKeyArns: []string{testSecretKmsKeyArn},
+			},
+			wantMarshalled: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["ecr:GetAuthorizationToken","logs:CreateLogGroup","logs:CreateLogStream","logs:PutLogEvents"],"Resource":["*"]},{"Effect":"Allow","Action":["secretsmanager:GetSecretValue","ssm:GetParameters","kms:Decrypt"],"Resource":["arn:aws:secretsmanager:*:*:secret:spinup/org/super-why/*","arn:aws:ssm:*:*:parameter/org/super-why/*","arn:aws:kms:*:*:key/123","arn:aws:kms:us-east-1:012345678901:key/12121212-3333-4444-5555-676767676767"]},{"Effect":"Allow","Action":["elasticfilesystem:ClientRootAccess","elasticfilesystem:ClientWrite","elasticfilesystem:ClientMount"],"Resource":["*"],"Condition":{"Bool":{"elasticfilesystem:AccessedViaMountTarget":["true"]},"StringEqualsIgnoreCase":{"aws:ResourceTag/spinup:org":["${aws:PrincipalTag/spinup:org}"],"aws:ResourceTag/spinup:spaceid":["${aws:PrincipalTag/spinup:spaceid}"]}}}]}`,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := defaultTaskExecutionPolicy(tt.args.path, tt.args.kms)
-			if !reflect.DeepEqual(got, tt.want) {
+			got := defaultTaskExecutionPolicy(tt.args.path, tt.args.kms, tt.args.kmsKeyArns)
+			if tt.want.Version != "" && !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Orchestrator.DefaultTaskExecutionPolicy() = %v, want %v", got, tt.want)
 			}
 
@@ -388,7 +481,7 @@ func TestOrchestrator_DefaultTaskExecutionRole(t *testing.T) {
 			o := &Orchestrator{
 				IAM: tt.fields.IAM,
 			}
-			got, err := o.DefaultTaskExecutionRole(tt.args.ctx, tt.args.pathPrefix, tt.args.role, nil)
+			got, err := o.DefaultTaskExecutionRole(tt.args.ctx, tt.args.pathPrefix, tt.args.role, nil, nil, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Orchestrator.DefaultTaskExecutionRole() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -400,6 +493,179 @@ func TestOrchestrator_DefaultTaskExecutionRole(t *testing.T) {
 	}
 }
 
+// mockIAMClientReadOnly fails the test on any attempt to create or modify a role
+type mockIAMClientReadOnly struct {
+	mockIAMClient
+}
+
+func (m *mockIAMClientReadOnly) CreateRoleWithContext(ctx context.Context, input *iam.CreateRoleInput, opts ...request.Option) (*iam.CreateRoleOutput, error) {
+	m.t.Errorf("unexpected create of role %s", aws.StringValue(input.RoleName))
+	return nil, awserr.New(iam.ErrCodeUnmodifiableEntityException, "read only", nil)
+}
+
+func (m *mockIAMClientReadOnly) PutRolePolicyWithContext(ctx context.Context, input *iam.PutRolePolicyInput, opts ...request.Option) (*iam.PutRolePolicyOutput, error) {
+	m.t.Errorf("unexpected put of role policy for %s", aws.StringValue(input.RoleName))
+	return nil, awserr.New(iam.ErrCodeUnmodifiableEntityException, "read only", nil)
+}
+
+func (m *mockIAMClientReadOnly) TagRoleWithContext(ctx context.Context, input *iam.TagRoleInput, opts ...request.Option) (*iam.TagRoleOutput, error) {
+	m.t.Errorf("unexpected tagging of role %s", aws.StringValue(input.RoleName))
+	return nil, awserr.New(iam.ErrCodeUnmodifiableEntityException, "read only", nil)
+}
+
+func TestOrchestrator_DefaultTaskExecutionRoleCreationDisabled(t *testing.T) {
+	tests := []struct {
+		name       string
+		pathPrefix string
+		role       string
+		want       string
+		wantCode   string
+	}{
+		{
+			name:       "existing role",
+			pathPrefix: pathPrefix,
+			role:       "super-why-ecsTaskExecution",
+			want:       "arn:aws:iam::12345678910:role/super-why-ecsTaskExecution",
+		},
+		{
+			name:       "existing role with an outdated policy document",
+			pathPrefix: "org/mr-rogers",
+			role:       "mr-rogers-ecsTaskExecution",
+			want:       "arn:aws:iam::12345678910:role/mr-rogers-ecsTaskExecution",
+		},
+		{
+			name:       "missing role",
+			pathPrefix: "missing",
+			role:       "missing-ecsTaskExecution",
+			wantCode:   apierror.ErrBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Orchestrator{
+				IAM: im.IAM{
+					Service:         &mockIAMClientReadOnly{mockIAMClient{t: t}},
+					DefaultKmsKeyID: "123",
+				},
+				DisableTaskExecutionRoleCreation: true,
+			}
+
+			got, err := o.DefaultTaskExecutionRole(context.TODO(), tt.pathPrefix, tt.role, nil, nil, nil)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok || aerr.Code != tt.wantCode {
+					t.Errorf("expected %s error, got %v", tt.wantCode, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Orchestrator.DefaultTaskExecutionRole() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_DefaultTaskExecutionRoleEnvironmentFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		pathPrefix  string
+		role        string
+		envFiles    []string
+		wantObjects []string
+		wantBuckets []string
+	}{
+		{
+			name:        "new role with an environment file",
+			pathPrefix:  "org/missing",
+			role:        "missing-ecsTaskExecution",
+			envFiles:    []string{"arn:aws:s3:::my-bucket/config/app.env"},
+			wantObjects: []string{"arn:aws:s3:::my-bucket/config/app.env"},
+			wantBuckets: []string{"arn:aws:s3:::my-bucket"},
+		},
+		{
+			name:        "existing role without environment files",
+			pathPrefix:  pathPrefix,
+			role:        "super-why-ecsTaskExecution",
+			envFiles:    []string{"arn:aws:s3:::my-bucket/app.env"},
+			wantObjects: []string{"arn:aws:s3:::my-bucket/app.env"},
+			wantBuckets: []string{"arn:aws:s3:::my-bucket"},
+		},
+		{
+			name:        "existing role keeps previously granted environment files",
+			pathPrefix:  "org/envfiles",
+			role:        "envfiles-ecsTaskExecution",
+			envFiles:    []string{"arn:aws:s3:::my-bucket/app.env"},
+			wantObjects: []string{"arn:aws:s3:::my-bucket/app.env", "arn:aws:s3:::other-bucket/app.env"},
+			wantBuckets: []string{"arn:aws:s3:::my-bucket", "arn:aws:s3:::other-bucket"},
+		},
+		{
+			name:        "existing role already granted the environment file",
+			pathPrefix:  "org/envfiles",
+			role:        "envfiles-ecsTaskExecution",
+			envFiles:    []string{"arn:aws:s3:::other-bucket/app.env"},
+			wantObjects: []string{"arn:aws:s3:::other-bucket/app.env"},
+			wantBuckets: []string{"arn:aws:s3:::other-bucket"},
+		},
+		{
+			name:        "existing role without new environment files",
+			pathPrefix:  "org/envfiles",
+			role:        "envfiles-ecsTaskExecution",
+			wantObjects: []string{"arn:aws:s3:::other-bucket/app.env"},
+			wantBuckets: []string{"arn:aws:s3:::other-bucket"},
+		},
+		{
+			name:       "new role without environment files",
+			pathPrefix: "org/missing",
+			role:       "missing-ecsTaskExecution",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockIAMClient{t: t}
+			o := &Orchestrator{
+				IAM: im.IAM{
+					Service:         client,
+					DefaultKmsKeyID: "123",
+				},
+			}
+
+			if _, err := o.DefaultTaskExecutionRole(context.TODO(), tt.pathPrefix, tt.role, nil, tt.envFiles, nil); err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			want, err := json.Marshal(defaultTaskExecutionPolicy(tt.pathPrefix, "123", nil, tt.wantObjects...))
+			if err != nil {
+				t.Fatalf("failed to marshal expected role policy: %s", err)
+			}
+
+			if client.rolePolicy != string(want) {
+				t.Errorf("expected role policy %s, got %s", string(want), client.rolePolicy)
+			}
+
+			var policy yiam.PolicyDocument
+			if err := json.Unmarshal([]byte(client.rolePolicy), &policy); err != nil {
+				t.Fatalf("failed to unmarshal put role policy: %s", err)
+			}
+
+			var buckets []string
+			for _, st := range policy.Statement {
+				if len(st.Action) == 1 && st.Action[0] == "s3:GetBucketLocation" {
+					buckets = st.Resource
+				}
+			}
+
+			if !reflect.DeepEqual(buckets, tt.wantBuckets) {
+				t.Errorf("expected buckets %v, got %v", tt.wantBuckets, buckets)
+			}
+		})
+	}
+}
+
 func TestOrchestrator_createDefaultTaskExecutionRole(t *testing.T) {
 	type fields struct {
 		IAM im.IAM
@@ -496,18 +762,34 @@ func TestOrchestrator_createDefaultTaskExecutionRole(t *testing.T) {
 
 func Test_assumeRolePolicy(t *testing.T) {
 	tests := []struct {
-		name    string
-		want    string
-		wantErr bool
+		name       string
+		services   []string
+		principals []string
+		want       string
+		wantErr    bool
 	}{
 		{
 			name: "assume role policy document",
 			want: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":["ecs-tasks.amazonaws.com"]},"Action":["sts:AssumeRole"]}]}`,
 		},
+		{
+			name:     "additional trusted services",
+			services: []string{"ecs-tasks.amazonaws.com", "events.amazonaws.com"},
+			want:     `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":["ecs-tasks.amazonaws.com","events.amazonaws.com"]},"Action":["sts:AssumeRole"]}]}`,
+		},
+		{
+			name:       "additional trusted principals",
+			principals: []string{"arn:aws:iam::12345678910:role/ci", "arn:aws:iam::12345678910:role/ci"},
+			want:       `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::12345678910:role/ci"],"Service":["ecs-tasks.amazonaws.com"]},"Action":["sts:AssumeRole"]}]}`,
+		},
+		{
+			name: "default assume role policy document after extended documents",
+			want: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":["ecs-tasks.amazonaws.com"]},"Action":["sts:AssumeRole"]}]}`,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := assumeRolePolicy()
+			got, err := assumeRolePolicy(tt.services, tt.principals)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("assumeRolePolicy() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -519,11 +801,284 @@ func Test_assumeRolePolicy(t *testing.T) {
 	}
 }
 
+func TestOrchestrator_createDefaultTaskExecutionRoleTrust(t *testing.T) {
+	m := &mockIAMClient{t: t}
+	o := &Orchestrator{
+		IAM: im.IAM{
+			Service:                        m,
+			TaskExecutionTrustedServices:   []string{"events.amazonaws.com"},
+			TaskExecutionTrustedPrincipals: []string{"arn:aws:iam::12345678910:role/ci"},
+		},
+	}
+
+	if _, err := o.createDefaultTaskExecutionRole(context.TODO(), "org/super-why", "super-why-ecsTaskExecution"); err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	want := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::12345678910:role/ci"],"Service":["ecs-tasks.amazonaws.com","events.amazonaws.com"]},"Action":["sts:AssumeRole"]}]}`
+	if m.assumeRolePolicy != want {
+		t.Errorf("expected assume role policy %s, got %s", want, m.assumeRolePolicy)
+	}
+}
+
+func TestOrchestrator_taskRole(t *testing.T) {
+	defaultRoleArn := "arn:aws:iam::12345678910:role/super-why-ecsTaskExecution"
+
+	tests := []struct {
+		name        string
+		taskRoleArn *string
+		allowedOrgs []string
+		iamErr      error
+		want        string
+		wantCode    string
+	}{
+		{
+			name: "no task role defaults to the execution role",
+			want: defaultRoleArn,
+		},
+		{
+			name:        "empty task role defaults to the execution role",
+			taskRoleArn: aws.String(""),
+			want:        defaultRoleArn,
+		},
+		{
+			name:        "distinct task role",
+			taskRoleArn: aws.String("arn:aws:iam::12345678910:role/org/super-why/super-why-app"),
+			want:        "arn:aws:iam::12345678910:role/org/super-why/super-why-app",
+		},
+		{
+			name:        "nonexistent task role",
+			taskRoleArn: aws.String("arn:aws:iam::12345678910:role/super-why-missing"),
+			wantCode:    apierror.ErrBadRequest,
+		},
+		{
+			name:        "task role under the org path",
+			taskRoleArn: aws.String("arn:aws:iam::12345678910:role/myorg/super-why/myorg-path-app"),
+			want:        "arn:aws:iam::12345678910:role/myorg/super-why/myorg-path-app",
+		},
+		{
+			name:        "task role of an allowed org",
+			taskRoleArn: aws.String("arn:aws:iam::12345678910:role/otherorg-app"),
+			allowedOrgs: []string{"otherorg"},
+			want:        "arn:aws:iam::12345678910:role/otherorg-app",
+		},
+		{
+			name:        "task role of another org",
+			taskRoleArn: aws.String("arn:aws:iam::12345678910:role/otherorg-app"),
+			wantCode:    apierror.ErrForbidden,
+		},
+		{
+			name:        "task role without an org",
+			taskRoleArn: aws.String("arn:aws:iam::12345678910:role/admin"),
+			wantCode:    apierror.ErrForbidden,
+		},
+		{
+			name:        "task role in another account",
+			taskRoleArn: aws.String("arn:aws:iam::999999999999:role/org/super-why/super-why-app"),
+			wantCode:    apierror.ErrBadRequest,
+		},
+		{
+			name:        "task role with another path",
+			taskRoleArn: aws.String("arn:aws:iam::12345678910:role/super-why-app"),
+			wantCode:    apierror.ErrBadRequest,
+		},
+		{
+			name:        "invalid task role arn",
+			taskRoleArn: aws.String("super-why-app"),
+			wantCode:    apierror.ErrBadRequest,
+		},
+		{
+			name:        "not a role arn",
+			taskRoleArn: aws.String("arn:aws:iam::12345678910:user/super-why-app"),
+			wantCode:    apierror.ErrBadRequest,
+		},
+		{
+			name:        "iam error",
+			taskRoleArn: aws.String("arn:aws:iam::12345678910:role/org/super-why/super-why-app"),
+			iamErr:      awserr.New(iam.ErrCodeServiceFailureException, "boom", nil),
+			wantCode:    apierror.ErrServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, tt.iamErr, nil, nil, nil)
+			o.AllowedOrgs = tt.allowedOrgs
+
+			got, err := o.taskRole(context.TODO(), tt.taskRoleArn, defaultRoleArn)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok || aerr.Code != tt.wantCode {
+					t.Errorf("Orchestrator.taskRole() error = %v, want code %s", err, tt.wantCode)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Orchestrator.taskRole() unexpected error = %v", err)
+			}
+
+			if aws.StringValue(got) != tt.want {
+				t.Errorf("Orchestrator.taskRole() = %v, want %v", aws.StringValue(got), tt.want)
+			}
+		})
+	}
+}
+
 func Benchmark_assumeRolePolicy(b *testing.B) {
 	for n := 0; n < b.N; n++ {
-		_, err := assumeRolePolicy()
+		_, err := assumeRolePolicy(nil, nil)
 		if err != nil {
 			b.Errorf("expected nil error, got %s", err)
 		}
 	}
 }
+
+func TestOrchestrator_TaskDefRoles(t *testing.T) {
+	tests := []struct {
+		name     string
+		family   string
+		policies bool
+		iamerr   error
+		want     *TaskDefRolesOutput
+		wantCode string
+	}{
+		{
+			name:   "role arns",
+			family: "roleapp",
+			want: &TaskDefRolesOutput{
+				TaskDefinition: "arn:aws:ecs:us-east-1:0123456789:task-definition/roleapp:1",
+				ExecutionRole: &TaskDefRole{
+					Arn:  "arn:aws:iam::12345678910:role/mr-rogers-ecsTaskExecution",
+					Name: "mr-rogers-ecsTaskExecution",
+				},
+				TaskRole: &TaskDefRole{
+					Arn:  "arn:aws:iam::12345678910:role/org/super-why/super-why-app",
+					Name: "super-why-app",
+				},
+			},
+		},
+		{
+			name:     "role arns and policies",
+			family:   "roleapp",
+			policies: true,
+			want: &TaskDefRolesOutput{
+				TaskDefinition: "arn:aws:ecs:us-east-1:0123456789:task-definition/roleapp:1",
+				ExecutionRole: &TaskDefRole{
+					Arn:      "arn:aws:iam::12345678910:role/mr-rogers-ecsTaskExecution",
+					Name:     "mr-rogers-ecsTaskExecution",
+					Policies: []string{"ECSTaskAccessPolicy"},
+				},
+				TaskRole: &TaskDefRole{
+					Arn:      "arn:aws:iam::12345678910:role/org/super-why/super-why-app",
+					Name:     "super-why-app",
+					Policies: []string{"s3-access", "sqs-access"},
+				},
+			},
+		},
+		{
+			name:   "no roles",
+			family: "batchapp",
+			want: &TaskDefRolesOutput{
+				TaskDefinition: "arn:aws:ecs:us-east-1:0123456789:task-definition/batchapp:1",
+			},
+		},
+		{
+			name:     "missing role",
+			family:   "loggedapp",
+			policies: true,
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "missing task definition",
+			family:   "missingapp",
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "iam error",
+			family:   "roleapp",
+			policies: true,
+			iamerr:   awserr.New(iam.ErrCodeServiceFailureException, "boom", nil),
+			wantCode: apierror.ErrServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, tt.iamerr, nil, nil, nil)
+
+			got, err := o.TaskDefRoles(context.TODO(), "clu1", tt.family, tt.policies)
+			if tt.wantCode != "" {
+				var aerr apierror.Error
+				if !errors.As(err, &aerr) || aerr.Code != tt.wantCode {
+					t.Errorf("expected %s error, got %v", tt.wantCode, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %s, got %s", awsutil.Prettify(tt.want), awsutil.Prettify(got))
+			}
+		})
+	}
+}
+
+func TestOrchestrator_DefaultTaskExecutionRoleKmsKeys(t *testing.T) {
+	otherKeyArn := "arn:aws:kms:us-east-1:012345678901:key/abababab-3333-4444-5555-676767676767"
+
+	tests := []struct {
+		name       string
+		pathPrefix string
+		role       string
+		kmsKeyArns []string
+		want       []string
+	}{
+		{
+			name:       "new role with a secret kms key",
+			pathPrefix: "org/missing",
+			role:       "missing-ecsTaskExecution",
+			kmsKeyArns: []string{testSecretKmsKeyArn},
+			want:       []string{testSecretKmsKeyArn},
+		},
+		{
+			name:       "existing role keeps previously granted kms keys",
+			pathPrefix: "org/kmskeys",
+			role:       "kmskeys-ecsTaskExecution",
+			kmsKeyArns: []string{otherKeyArn},
+			want:       []string{testSecretKmsKeyArn, otherKeyArn},
+		},
+		{
+			name:       "existing role without kms keys",
+			pathPrefix: pathPrefix,
+			role:       "super-why-ecsTaskExecution",
+			kmsKeyArns: []string{testSecretKmsKeyArn},
+			want:       []string{testSecretKmsKeyArn},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockIAMClient{t: t}
+			o := &Orchestrator{
+				IAM: im.IAM{
+					Service:         client,
+					DefaultKmsKeyID: "123",
+				},
+			}
+
+			if _, err := o.DefaultTaskExecutionRole(context.TODO(), tt.pathPrefix, tt.role, nil, nil, tt.kmsKeyArns); err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			var policy yiam.PolicyDocument
+			if err := json.Unmarshal([]byte(client.rolePolicy), &policy); err != nil {
+				t.Fatalf("failed to unmarshal put role policy: %s", err)
+			}
+
+			if got := policyKmsKeyArns(policy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected kms keys %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
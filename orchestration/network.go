@@ -0,0 +1,83 @@
+package orchestration
+
+import (
+	"fmt"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	log "github.com/sirupsen/logrus"
+)
+
+// NetworkSelection selects a subset of the configured default subnets and security groups.  Each entry is
+// either the id of a default subnet (or security group) or the name of a configured label for a set of them.
+type NetworkSelection struct {
+	Subnets        []string
+	SecurityGroups []string
+}
+
+// defaultNetworkConfiguration returns the awsvpc network configuration built from the configured defaults.  If a
+// selection is passed, only the selected subnets and security groups are used.
+func (o *Orchestrator) defaultNetworkConfiguration(selection *NetworkSelection) (*ecs.NetworkConfiguration, error) {
+	subnets := o.DefaultSubnets
+	sgs := o.DefaultSecurityGroups
+
+	if selection != nil {
+		var err error
+		if subnets, err = selectDefaults("subnet", selection.Subnets, o.DefaultSubnets, o.DefaultSubnetLabels); err != nil {
+			return nil, err
+		}
+
+		if sgs, err = selectDefaults("security group", selection.SecurityGroups, o.DefaultSecurityGroups, o.DefaultSecurityGroupLabels); err != nil {
+			return nil, err
+		}
+
+		log.Debugf("selected subnets %v and security groups %v from the defaults", subnets, sgs)
+	}
+
+	return &ecs.NetworkConfiguration{
+		AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+			AssignPublicIp: aws.String(o.DefaultPublic),
+			SecurityGroups: aws.StringSlice(sgs),
+			Subnets:        aws.StringSlice(subnets),
+		},
+	}, nil
+}
+
+// selectDefaults resolves the selected ids and labels to a list of ids, validating that every one of them is in the
+// list of defaults.  An empty selection returns all of the defaults.
+func selectDefaults(kind string, selected, defaults []string, labels map[string][]string) ([]string, error) {
+	if len(selected) == 0 {
+		return defaults, nil
+	}
+
+	allowed := make(map[string]struct{}, len(defaults))
+	for _, d := range defaults {
+		allowed[d] = struct{}{}
+	}
+
+	output := []string{}
+	seen := map[string]struct{}{}
+	for _, s := range selected {
+		ids := []string{s}
+		if l, ok := labels[s]; ok {
+			ids = l
+		}
+
+		for _, id := range ids {
+			if _, ok := allowed[id]; !ok {
+				msg := fmt.Sprintf("%s %s is not one of the configured defaults", kind, id)
+				return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+			}
+
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+
+			output = append(output, id)
+		}
+	}
+
+	return output, nil
+}
@@ -0,0 +1,114 @@
+package orchestration
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestOrchestrator_defaultNetworkConfiguration(t *testing.T) {
+	o := &Orchestrator{
+		DefaultPublic:         "DISABLED",
+		DefaultSubnets:        []string{"subnet-a", "subnet-b", "subnet-c"},
+		DefaultSecurityGroups: []string{"sg-web", "sg-db", "sg-mgmt"},
+		DefaultSubnetLabels: map[string][]string{
+			"us-east-1a": {"subnet-a"},
+			"us-east-1b": {"subnet-b"},
+			"private":    {"subnet-b", "subnet-c"},
+			"stale":      {"subnet-z"},
+		},
+		DefaultSecurityGroupLabels: map[string][]string{
+			"web": {"sg-web", "sg-mgmt"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		selection   *NetworkSelection
+		wantSubnets []string
+		wantSgs     []string
+		wantErr     bool
+	}{
+		{
+			name:        "no selection",
+			wantSubnets: []string{"subnet-a", "subnet-b", "subnet-c"},
+			wantSgs:     []string{"sg-web", "sg-db", "sg-mgmt"},
+		},
+		{
+			name:        "empty selection",
+			selection:   &NetworkSelection{},
+			wantSubnets: []string{"subnet-a", "subnet-b", "subnet-c"},
+			wantSgs:     []string{"sg-web", "sg-db", "sg-mgmt"},
+		},
+		{
+			name: "subset by id",
+			selection: &NetworkSelection{
+				Subnets:        []string{"subnet-c", "subnet-a"},
+				SecurityGroups: []string{"sg-db"},
+			},
+			wantSubnets: []string{"subnet-c", "subnet-a"},
+			wantSgs:     []string{"sg-db"},
+		},
+		{
+			name: "subset by label",
+			selection: &NetworkSelection{
+				Subnets:        []string{"us-east-1a", "private", "subnet-c"},
+				SecurityGroups: []string{"web"},
+			},
+			wantSubnets: []string{"subnet-a", "subnet-b", "subnet-c"},
+			wantSgs:     []string{"sg-web", "sg-mgmt"},
+		},
+		{
+			name: "subnet out of set",
+			selection: &NetworkSelection{
+				Subnets: []string{"subnet-a", "subnet-x"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "security group out of set",
+			selection: &NetworkSelection{
+				SecurityGroups: []string{"sg-other"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "label out of set",
+			selection: &NetworkSelection{
+				Subnets: []string{"stale"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := o.defaultNetworkConfiguration(tt.selection)
+			if tt.wantErr {
+				aerr, ok := err.(apierror.Error)
+				if !ok || aerr.Code != apierror.ErrBadRequest {
+					t.Errorf("expected bad request error, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			want := &ecs.NetworkConfiguration{
+				AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+					AssignPublicIp: aws.String("DISABLED"),
+					SecurityGroups: aws.StringSlice(tt.wantSgs),
+					Subnets:        aws.StringSlice(tt.wantSubnets),
+				},
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Orchestrator.defaultNetworkConfiguration() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
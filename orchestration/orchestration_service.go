@@ -6,8 +6,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/YaleSpinup/apierror"
+	ecsapi "github.com/YaleSpinup/ecs-api/ecs"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 
@@ -30,12 +34,23 @@ type ServiceOrchestrationInput struct {
 	// map of container definition names to private repository credentials
 	// https://docs.aws.amazon.com/sdk-for-go/api/service/secretsmanager/#CreateSecretInput
 	Credentials map[string]*secretsmanager.CreateSecretInput
+	// map of container definition names to existing secretsmanager secret ARNs to use as private repository credentials
+	ImportCredentials map[string]string
 	// https://docs.aws.amazon.com/sdk-for-go/api/service/ecs/#CreateServiceInput
 	Service *ecs.CreateServiceInput
 	// https://docs.aws.amazon.com/sdk-for-go/api/service/servicediscovery/#CreateServiceInput
 	ServiceRegistry *servicediscovery.CreateServiceInput
 	// slice of tags to be applied to all resources
 	Tags []*Tag
+	// list of container definition names that keep their own log configuration (or none)
+	// instead of having the default awslogs configuration applied
+	SkipLogConfiguration []string
+	// region of the awslogs log configuration, defaults to the region of the account
+	AwslogsRegion string
+	// map of container definition names to a map of container secret names and the JSON key to extract from the secretsmanager secret
+	SecretKeys map[string]map[string]string
+	// subset of the default subnets and security groups to use when no network configuration is passed
+	NetworkSelection *NetworkSelection
 }
 
 // ServiceOrchestrationOutput is the output structure for service orchestration
@@ -51,6 +66,43 @@ type ServiceOrchestrationOutput struct {
 	Service *ecs.Service
 	// https://docs.aws.amazon.com/sdk-for-go/api/service/servicediscovery/#Service
 	ServiceDiscoveryService *servicediscovery.Service
+	// outcome of deleting each dependency, only set when waiting for a recursive delete
+	Dependencies []*DependencyDeleteOutput `json:",omitempty"`
+	// token to cancel the background cleanup of a recursive delete, only set when not waiting for a recursive delete
+	DeleteToken string `json:",omitempty"`
+}
+
+// Status of a dependency after a recursive delete
+const (
+	DependencyDeleted      = "deleted"
+	DependencyRetained     = "retained"
+	DependencyDeleteFailed = "failed"
+)
+
+// DependencyDeleteOutput is the outcome of deleting a single dependency of a service
+type DependencyDeleteOutput struct {
+	// type of the dependency, ie. autoscaling, cluster, role, serviceregistry or taskdefinition
+	Type string
+	// name or ARN of the dependency
+	Resource string
+	// deleted, retained or failed
+	Status string
+	// the error deleting the dependency, if it failed
+	Error string `json:",omitempty"`
+}
+
+// dependencyDeletes collects the outcome of deleting the dependencies of a service
+type dependencyDeletes []*DependencyDeleteOutput
+
+// add records the outcome of deleting a dependency, logging any failure
+func (d *dependencyDeletes) add(typ, resource, status string, err error) {
+	out := &DependencyDeleteOutput{Type: typ, Resource: resource, Status: status}
+	if err != nil {
+		log.Errorf("failed cleaning up %s %s: %s", typ, resource, err)
+		out.Status = DependencyDeleteFailed
+		out.Error = err.Error()
+	}
+	*d = append(*d, out)
 }
 
 // ServiceOrchestrationUpdateInput is in the input for service orchestration updates.  The following are supported:
@@ -68,6 +120,26 @@ type ServiceOrchestrationUpdateInput struct {
 	Service            *ecs.UpdateServiceInput
 	Tags               []*Tag
 	ForceNewDeployment bool
+	// list of container definition names that keep their own log configuration (or none)
+	// instead of having the default awslogs configuration applied
+	SkipLogConfiguration []string
+	// region of the awslogs log configuration, defaults to the region of the account
+	AwslogsRegion string
+	// existing task definition revision (ARN or family:revision) to pin the service to instead of
+	// registering a new revision, this is mutually exclusive with TaskDefinition
+	TaskDefinitionRevision string
+	// map of container definition names to a map of container secret names and the JSON key to extract from the secretsmanager secret
+	SecretKeys map[string]map[string]string
+	// optional task definition (ARN or family:revision) the service is expected to be running, the update
+	// fails with a conflict if the service's active task definition doesn't match
+	ExpectedTaskDefinition string
+	// optional number of seconds (up to MaxDeploymentStatusWait) to poll the deployment started by the update
+	// and report if the deployment circuit breaker rolled it back
+	RolloutWait int
+	// optional changes to the DNS records (ie. TTL), health check configuration or description of the service
+	// discovery service the service is registered with
+	// https://docs.aws.amazon.com/sdk-for-go/api/service/servicediscovery/#ServiceChange
+	ServiceRegistry *servicediscovery.ServiceChange
 }
 
 // ServiceOrchestrationUpdateOutput is the output for service orchestration updates
@@ -78,6 +150,64 @@ type ServiceOrchestrationUpdateOutput struct {
 	Credentials         map[string]interface{}
 	CloudwatchLogGroups []string
 	Tags                []*Tag
+	// Rollout is the outcome of the deployment started by the update, it's only set when RolloutWait is passed
+	Rollout *ServiceRolloutOutput `json:",omitempty"`
+	// ServiceDiscoveryService is the updated service registry, it's only set when ServiceRegistry changes are passed
+	ServiceDiscoveryService *servicediscovery.Service `json:",omitempty"`
+}
+
+// ServiceRolloutOutput is the rollout state of the deployment started by a service update
+type ServiceRolloutOutput struct {
+	DeploymentId       string
+	RolloutState       string
+	RolloutStateReason string `json:",omitempty"`
+	// RolledBack is true when the deployment failed and the circuit breaker replaced it with a rollback deployment
+	RolledBack bool
+	// RolledBackTo is the task definition of the rollback deployment
+	RolledBackTo string `json:",omitempty"`
+}
+
+// ServiceDeploymentStatusOutput is the deployment status of a service
+type ServiceDeploymentStatusOutput struct {
+	Deployments []*ecs.Deployment
+	// Stable is true when the primary deployment is the only deployment and its running count matches the desired count
+	Stable bool
+}
+
+// ServiceDeploymentFailure is a likely cause of a failing deployment and the service events pointing to it
+type ServiceDeploymentFailure struct {
+	Category    string
+	Description string
+	// Count is the number of recent events in the category
+	Count int
+	// LastSeen and Message are from the newest event in the category
+	LastSeen *time.Time
+	Message  string
+}
+
+// ServiceRevisionStatusOutput compares the task definition revision a service is running with the latest revision in its family
+type ServiceRevisionStatusOutput struct {
+	Family string
+	// TaskDefinition and Revision are the task definition the service is running
+	TaskDefinition string
+	Revision       int64
+	// LatestTaskDefinition and LatestRevision are the latest ACTIVE task definition in the family
+	LatestTaskDefinition string
+	LatestRevision       int64
+	// UpToDate is true when the service is running the latest ACTIVE revision
+	UpToDate bool
+	// RevisionsBehind is the number of ACTIVE revisions registered after the one the service is running
+	RevisionsBehind int64
+}
+
+// ServiceCloneInput is the input for cloning an existing service under a new name
+type ServiceCloneInput struct {
+	// name of the new service
+	Name string
+	// cluster to create the new service in, defaults to the cluster of the existing service
+	Cluster string
+	// desired count of the new service, defaults to the desired count of the existing service
+	DesiredCount *int64
 }
 
 // ServiceDeleteInput encapsulates a request to delete a service with optional recursion
@@ -85,6 +215,11 @@ type ServiceDeleteInput struct {
 	Cluster   *string
 	Service   *string
 	Recursive bool
+	// Wait (up to MaxDeleteWait) for the recursive deletion of the dependencies and report the outcome of each
+	Wait bool
+	// CleanupServiceRegistry deletes the service discovery services of the service on a non-recursive delete, after
+	// deregistering their instances, waiting up to MaxDeleteWait.  They are always deleted on a recursive delete.
+	CleanupServiceRegistry bool
 }
 
 // CreateService takes service orchestration input, builds up a service and returns the service orchestration output
@@ -96,7 +231,7 @@ func (o *Orchestrator) CreateService(ctx context.Context, input *ServiceOrchestr
 
 	spaceid := aws.StringValue(input.Cluster.ClusterName)
 
-	ct, err := cleanTags(o.Org, spaceid, "container", "service", input.Tags)
+	ct, err := cleanTags(o.Org, o.AllowedOrgs, spaceid, "container", "service", MergeDefaultTags(o.DefaultTags, input.Tags))
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +254,7 @@ func (o *Orchestrator) CreateService(ctx context.Context, input *ServiceOrchestr
 	output.Cluster = cluster
 	rollBackTasks = append(rollBackTasks, rbfunc)
 
-	creds, rbfunc, err := o.processRepositoryCredentialsCreate(ctx, input)
+	creds, rbfunc, err := o.processRepositoryCredentialsCreate(ctx, input, cluster)
 	if err != nil {
 		return nil, err
 	}
@@ -150,6 +285,103 @@ func (o *Orchestrator) CreateService(ctx context.Context, input *ServiceOrchestr
 	return output, nil
 }
 
+// CloneService creates a new service with the configuration, tags and active task definition revision of an existing
+// service.  The task definition is reused as is, so any repository credentials (and the execution role of the source
+// cluster) are referenced rather than re-created.  A recursive delete of either service retains the dependencies the
+// other still uses.  Load balancers and service registries aren't cloned since they can't be shared with the existing service.
+func (o *Orchestrator) CloneService(ctx context.Context, cluster, service string, input *ServiceCloneInput) (*ServiceOrchestrationOutput, error) {
+	if input == nil || input.Name == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "name is required to clone a service", nil)
+	}
+
+	if input.Cluster == "" {
+		input.Cluster = cluster
+	}
+
+	if input.Cluster == cluster && input.Name == service {
+		return nil, apierror.New(apierror.ErrBadRequest, "name of the cloned service must be different from the existing service", nil)
+	}
+
+	source, err := o.ECS.GetService(ctx, cluster, service)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := o.ECS.ListTags(ctx, aws.StringValue(source.ServiceArn))
+	if err != nil {
+		return nil, err
+	}
+
+	tdef, _, err := o.ECS.GetTaskDefinition(ctx, source.TaskDefinition, false)
+	if err != nil {
+		return nil, err
+	}
+
+	clu, err := o.ECS.GetCluster(ctx, aws.String(input.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	inputTags := make([]*Tag, len(tags))
+	for i, t := range tags {
+		inputTags[i] = &Tag{Key: t.Key, Value: t.Value}
+	}
+
+	ct, err := cleanTags(o.Org, o.AllowedOrgs, aws.StringValue(clu.ClusterName), "container", "service", MergeDefaultTags(o.DefaultTags, inputTags))
+	if err != nil {
+		return nil, err
+	}
+
+	// daemon services run one task per container instance and don't take a desired count
+	desiredCount := source.DesiredCount
+	if aws.StringValue(source.SchedulingStrategy) == ecs.SchedulingStrategyDaemon {
+		desiredCount = nil
+	}
+
+	if input.DesiredCount != nil {
+		desiredCount = input.DesiredCount
+	}
+
+	log.Infof("cloning service %s/%s to %s/%s with task definition %s", cluster, service, input.Cluster, input.Name, aws.StringValue(tdef.TaskDefinitionArn))
+
+	serviceInput := &ecs.CreateServiceInput{
+		CapacityProviderStrategy: source.CapacityProviderStrategy,
+		Cluster:                  clu.ClusterName,
+		DeploymentConfiguration:  source.DeploymentConfiguration,
+		DeploymentController:     source.DeploymentController,
+		DesiredCount:             desiredCount,
+		EnableECSManagedTags:     source.EnableECSManagedTags,
+		EnableExecuteCommand:     source.EnableExecuteCommand,
+		NetworkConfiguration:     source.NetworkConfiguration,
+		PlacementConstraints:     source.PlacementConstraints,
+		PlacementStrategy:        source.PlacementStrategy,
+		PlatformVersion:          source.PlatformVersion,
+		PropagateTags:            source.PropagateTags,
+		SchedulingStrategy:       source.SchedulingStrategy,
+		ServiceName:              aws.String(input.Name),
+		TaskDefinition:           tdef.TaskDefinitionArn,
+	}
+
+	// the launch type and capacity provider strategy are mutually exclusive
+	if len(source.CapacityProviderStrategy) == 0 {
+		serviceInput.LaunchType = source.LaunchType
+	}
+
+	svc, _, err := o.processService(ctx, &ServiceOrchestrationInput{
+		Service: serviceInput,
+		Tags:    ct,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceOrchestrationOutput{
+		Cluster:        clu,
+		Service:        svc,
+		TaskDefinition: tdef,
+	}, nil
+}
+
 // DeleteService takes a service orchestrator, service name and a cluster to delete and removes
 // the service and the service registry
 func (o *Orchestrator) DeleteService(ctx context.Context, input *ServiceDeleteInput) (*ServiceOrchestrationOutput, error) {
@@ -171,107 +403,223 @@ func (o *Orchestrator) DeleteService(ctx context.Context, input *ServiceDeleteIn
 		return nil, err
 	}
 
+	output := &ServiceOrchestrationOutput{Service: service}
+
 	// recursively remove the service registry and the cluster if it's empty
 	// TODO: this should return a 202, not a 200
 	if input.Recursive {
-		log.Infof("removing '%s' dependencies recursively, asynchronously", aws.StringValue(service.ServiceArn))
-		go func() {
-			cleanupCtx := context.Background()
+		if input.Wait {
+			log.Infof("removing '%s' dependencies recursively, waiting up to %s", aws.StringValue(service.ServiceArn), MaxDeleteWait)
 
-			deletedCluster, err := o.deleteCluster(cleanupCtx, service.ClusterArn)
+			// the outcome has to be returned before the server write timeout, so the cluster and service registry waits
+			// are bounded by MaxDeleteWait and whatever isn't removed by then is reported as failed
+			waitCtx, cancel := context.WithTimeout(ctx, MaxDeleteWait)
+			defer cancel()
+
+			output.Dependencies = o.deleteServiceDependencies(waitCtx, aws.StringValue(input.Cluster), service)
+		} else {
+			log.Infof("removing '%s' dependencies recursively, asynchronously after %s", aws.StringValue(service.ServiceArn), o.deleteGracePeriod())
+			token, err := o.deleteAfterGracePeriod(aws.StringValue(input.Cluster), func(ctx context.Context) {
+				o.deleteServiceDependencies(ctx, aws.StringValue(input.Cluster), service)
+			})
 			if err != nil {
-				log.Errorf("failed cleaning up cluster: %s", err)
+				return nil, err
 			}
+			output.DeleteToken = token
+		}
+	} else if input.CleanupServiceRegistry {
+		log.Infof("removing '%s' service registries, waiting up to %s", aws.StringValue(service.ServiceArn), MaxDeleteWait)
 
-			// if we cleaned up the cluster, we should also cleanup the default task execution role
-			if deletedCluster {
-				executionRoleName := fmt.Sprintf("%s-ecsTaskExecution", aws.StringValue(input.Cluster))
-				if err := o.deleteDefaultTaskExecutionRole(cleanupCtx, executionRoleName); err != nil {
-					log.Errorf("failed to cleanup default task execution role: %s", err)
-				}
-			}
+		// like a recursive delete with wait, the deregistration and delete retries are bounded by MaxDeleteWait
+		waitCtx, cancel := context.WithTimeout(ctx, MaxDeleteWait)
+		defer cancel()
+
+		deletes := dependencyDeletes{}
+		for _, r := range service.ServiceRegistries {
+			status, err := o.cleanupServiceRegistry(waitCtx, r.RegistryArn)
+			deletes.add("serviceregistry", aws.StringValue(r.RegistryArn), status, err)
+		}
+		output.Dependencies = deletes
+	}
+
+	return output, nil
+}
+
+// sharedDependencies are the task definition families and task definitions used by the active services in the org
+// clusters, ie. by a service cloned from a deleted service
+type sharedDependencies struct {
+	families        map[string]struct{}
+	taskDefinitions map[string]struct{}
+}
+
+// servicesDependencies finds the task definition families and task definitions used by the active services in the
+// org clusters, so a recursive delete doesn't remove dependencies that another service still uses.  The families are
+// parsed from the task definition arns, the task definitions are only described to find the shared execution roles.
+func (o *Orchestrator) servicesDependencies(ctx context.Context) (*sharedDependencies, error) {
+	clusters, err := o.ListClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := &sharedDependencies{
+		families:        map[string]struct{}{},
+		taskDefinitions: map[string]struct{}{},
+	}
 
-			if len(service.ServiceRegistries) > 0 {
-				for _, r := range service.ServiceRegistries {
-					srCtx, srCancel := context.WithTimeout(cleanupCtx, 120*time.Second)
-					defer srCancel()
-
-					srChan := o.ServiceDiscovery.DeleteServiceRegistryWithRetry(srCtx, r.RegistryArn)
-
-					// wait for a done context
-					select {
-					case <-srCtx.Done():
-						log.Errorf("timeout waiting for successful service registry %s deletion", aws.StringValue(r.RegistryArn))
-					case out := <-srChan:
-						if out == "success" {
-							log.Infof("successfully deleted service registry %s", aws.StringValue(r.RegistryArn))
-						}
-					}
+	for _, cluster := range clusters {
+		taskDefinitions, err := o.ECS.ListServiceTaskDefinitions(ctx, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range taskDefinitions {
+			shared.families[taskDefinitionArnFamily(t)] = struct{}{}
+			shared.taskDefinitions[t] = struct{}{}
+		}
+	}
+
+	return shared, nil
+}
+
+// executionRoleShared checks if the execution role is used by one of the shared task definitions.  It's only called
+// for the default task execution role of a deleted cluster, each task definition is described once.
+func (o *Orchestrator) executionRoleShared(ctx context.Context, shared *sharedDependencies, roleName string) (bool, error) {
+	for t := range shared.taskDefinitions {
+		td, _, err := o.ECS.GetTaskDefinition(ctx, aws.String(t), false)
+		if err != nil {
+			return false, err
+		}
+
+		// the role name is the last element of the resource, after any path
+		if roleArn := aws.StringValue(td.ExecutionRoleArn); roleArn[strings.LastIndex(roleArn, "/")+1:] == roleName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// deleteServiceDependencies removes the dependencies of a deleted service: its autoscaling configuration, the schedules of
+// its task definition family, the cluster (and default task execution role) if it's empty, the service registries and the
+// task definition revisions.  The task
+// definition family (with its repository credentials) and the execution role are retained if another service still uses
+// them.  It returns the outcome of deleting each dependency.
+func (o *Orchestrator) deleteServiceDependencies(ctx context.Context, cluster string, service *ecs.Service) []*DependencyDeleteOutput {
+	deletes := dependencyDeletes{}
+
+	// the service is deleted (draining) by now, so it isn't one of the services sharing its dependencies
+	shared, sharedErr := o.servicesDependencies(ctx)
+
+	// get the active task definition to find the task definition family
+	taskDefinition, _, tdErr := o.ECS.GetTaskDefinition(ctx, service.TaskDefinition, false)
+
+	// schedules are found by their cluster target, so they're removed before the cluster.  Like the task definition
+	// family, they're retained if another service still uses the family.
+	if tdErr == nil && sharedErr == nil {
+		family := aws.StringValue(taskDefinition.Family)
+		if _, ok := shared.families[family]; !ok {
+			// without the cluster, there's nothing left for a schedule to target
+			if err := o.deleteScheduledTasks(ctx, cluster, family); err != nil {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+					deletes.add("schedule", family, DependencyDeleted, err)
 				}
 			}
+		}
+	}
 
-			// get the active task definition to find the task definition family
-			taskDefinition, _, err := o.ECS.GetTaskDefinition(cleanupCtx, service.TaskDefinition, false)
-			if err != nil {
-				log.Errorf("failed to get active task definition '%s': %s", aws.StringValue(service.TaskDefinition), err)
-			} else {
-				// list all of the revisions in the task definition family
-				taskDefinitionRevisions, err := o.ECS.ListTaskDefinitionRevisions(cleanupCtx, taskDefinition.Family)
-				if err != nil {
-					log.Errorf("failed to get a list of task definition revisions to delete")
-				} else {
-					for _, revision := range taskDefinitionRevisions {
-
-						taskDefinition, _, err := o.ECS.GetTaskDefinition(cleanupCtx, aws.String(revision), false)
-						if err != nil {
-							log.Errorf("failed to get task definition revisions '%s' to delete: %s", revision, err)
-							continue
-						}
-
-						deletedCredentials := make(map[string]struct{})
-						// for each task definition revision in the task definition family, delete any existing repository credentials, keeping track
-						// of ones we delete so we don't try to re-delete them.
-						// TODO: if we want to share repository credentials, we need to look for multiple container definitions using the same credentials.
-						for _, cd := range taskDefinition.ContainerDefinitions {
-							log.Debugf("cleaning '%s' container definition '%s' components", aws.StringValue(service.ServiceArn), aws.StringValue(cd.Name))
-
-							if cd.RepositoryCredentials != nil && aws.StringValue(cd.RepositoryCredentials.CredentialsParameter) != "" {
-								credsArn := aws.StringValue(cd.RepositoryCredentials.CredentialsParameter)
-
-								if _, ok := deletedCredentials[credsArn]; !ok {
-									_, err = o.SecretsManager.DeleteSecret(cleanupCtx, credsArn, 0)
-									if err != nil {
-										log.Errorf("failed to delete secretsmanager secret '%s' for %s", credsArn, aws.StringValue(service.ServiceArn))
-									} else {
-										deletedCredentials[credsArn] = struct{}{}
-										log.Infof("successfully deleted secretsmanager secret '%s'", credsArn)
-									}
-								}
-							}
-						}
-
-						out, err := o.ECS.DeleteTaskDefinition(cleanupCtx, aws.String(revision))
-						if err != nil {
-							log.Errorf("failed to delete task definition '%s': %s", revision, err)
-						} else {
-							log.Debugf("successfully deleted task definition revision %s: %+v", revision, out)
-						}
-
-					}
-				}
+	scalingResource := fmt.Sprintf("service/%s/%s", cluster, aws.StringValue(service.ServiceName))
+	deletes.add("autoscaling", scalingResource, DependencyDeleted, o.deleteServiceScaling(ctx, cluster, aws.StringValue(service.ServiceName)))
+
+	deletedCluster, err := o.deleteCluster(ctx, service.ClusterArn)
+	if deletedCluster {
+		deletes.add("cluster", aws.StringValue(service.ClusterArn), DependencyDeleted, nil)
+	} else {
+		deletes.add("cluster", aws.StringValue(service.ClusterArn), DependencyRetained, err)
+	}
+
+	// if we cleaned up the cluster, we should also cleanup the default task execution role
+	if deletedCluster {
+		executionRoleName := fmt.Sprintf("%s-ecsTaskExecution", cluster)
+		if sharedErr != nil {
+			deletes.add("role", executionRoleName, DependencyRetained, sharedErr)
+		} else if inUse, err := o.executionRoleShared(ctx, shared, executionRoleName); err != nil {
+			deletes.add("role", executionRoleName, DependencyRetained, err)
+		} else if inUse {
+			log.Infof("not deleting execution role %s, it's used by another service", executionRoleName)
+			deletes.add("role", executionRoleName, DependencyRetained, nil)
+		} else {
+			deletes.add("role", executionRoleName, DependencyDeleted, o.deleteDefaultTaskExecutionRole(ctx, executionRoleName))
+		}
+
+		// the scheduled task role only exists if a task definition in the cluster was scheduled
+		if err := o.deleteScheduledTaskRole(ctx, cluster); err != nil {
+			deletes.add("role", fmt.Sprintf("%s-ecsEvents", cluster), DependencyDeleted, err)
+		}
+	}
+
+	for _, r := range service.ServiceRegistries {
+		deletes.add("serviceregistry", aws.StringValue(r.RegistryArn), DependencyDeleted, o.deleteServiceRegistry(ctx, r.RegistryArn))
+	}
+
+	if tdErr != nil {
+		deletes.add("taskdefinition", aws.StringValue(service.TaskDefinition), DependencyRetained, tdErr)
+		return deletes
+	}
+
+	if sharedErr != nil {
+		deletes.add("taskdefinition", aws.StringValue(taskDefinition.Family), DependencyRetained, sharedErr)
+		return deletes
+	}
+
+	if _, ok := shared.families[aws.StringValue(taskDefinition.Family)]; ok {
+		log.Infof("not deleting task definition family %s, it's used by another service", aws.StringValue(taskDefinition.Family))
+		deletes.add("taskdefinition", aws.StringValue(taskDefinition.Family), DependencyRetained, nil)
+		return deletes
+	}
+
+	// list all of the revisions in the task definition family
+	taskDefinitionRevisions, err := o.ECS.ListTaskDefinitionRevisions(ctx, taskDefinition.Family)
+	if err != nil {
+		deletes.add("taskdefinition", aws.StringValue(taskDefinition.Family), DependencyRetained, err)
+		return deletes
+	}
+
+	// for each task definition revision in the task definition family, delete any existing repository credentials, keeping track
+	// of ones we delete so we don't try to re-delete them.
+	deletedCredentials := newCredentialsSet()
+	failures := o.deleteTaskDefinitionRevisions(ctx, taskDefinitionRevisions, deletedCredentials)
+	for _, revision := range taskDefinitionRevisions {
+		var err error
+		if errs, ok := failures[revision]; ok {
+			msgs := make([]string, len(errs))
+			for i, e := range errs {
+				msgs[i] = e.Error()
 			}
-		}()
+			err = errors.New(strings.Join(msgs, ", "))
+		}
+		deletes.add("taskdefinition", revision, DependencyDeleted, err)
 	}
 
-	return &ServiceOrchestrationOutput{Service: service}, nil
+	return deletes
 }
 
 // UpdateService updates a service and related services
 func (o *Orchestrator) UpdateService(ctx context.Context, cluster, service string, input *ServiceOrchestrationUpdateInput) (*ServiceOrchestrationUpdateOutput, error) {
-	if input.Service == nil && input.TaskDefinition == nil && input.Tags == nil && !input.ForceNewDeployment {
+	if input.Service == nil && input.TaskDefinition == nil && input.TaskDefinitionRevision == "" && input.Tags == nil && !input.ForceNewDeployment && input.ServiceRegistry == nil {
 		return nil, errors.New("expected update")
 	}
 
+	if input.TaskDefinition != nil && input.TaskDefinitionRevision != "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "only one of task definition or task definition revision can be specified", nil)
+	}
+
+	// the rollout wait (up to MaxDeploymentStatusWait) includes the time spent updating the service
+	rolloutWait := time.Duration(input.RolloutWait) * time.Second
+	if rolloutWait > MaxDeploymentStatusWait {
+		rolloutWait = MaxDeploymentStatusWait
+	}
+	rolloutDeadline := time.Now().Add(rolloutWait)
+
 	active := &ServiceOrchestrationUpdateOutput{}
 
 	clu, err := o.ECS.GetCluster(ctx, aws.String(cluster))
@@ -288,6 +636,12 @@ func (o *Orchestrator) UpdateService(ctx context.Context, cluster, service strin
 	if err != nil {
 		return nil, err
 	}
+
+	if input.ExpectedTaskDefinition != "" && !taskDefinitionMatches(input.ExpectedTaskDefinition, aws.StringValue(svc.TaskDefinition)) {
+		msg := fmt.Sprintf("service %s/%s is running task definition %s, expected %s", cluster, service, aws.StringValue(svc.TaskDefinition), input.ExpectedTaskDefinition)
+		return nil, apierror.New(apierror.ErrConflict, msg, nil)
+	}
+
 	// GetService doesn't include tag information, lets add it
 	tags, err := o.ECS.ListTags(ctx, aws.StringValue(svc.ServiceArn))
 	if err != nil {
@@ -319,6 +673,11 @@ func (o *Orchestrator) UpdateService(ctx context.Context, cluster, service strin
 		if err := o.processTaskDefinitionUpdate(ctx, input, active); err != nil {
 			return nil, err
 		}
+	} else if input.TaskDefinitionRevision != "" {
+		// updates active.TaskDefinition
+		if err := o.processTaskDefinitionRevisionUpdate(ctx, input, active); err != nil {
+			return nil, err
+		}
 	}
 
 	cwlgs, err := o.cloudwatchLogGroups(ctx, active.TaskDefinition.ContainerDefinitions)
@@ -327,6 +686,11 @@ func (o *Orchestrator) UpdateService(ctx context.Context, cluster, service strin
 	}
 	active.CloudwatchLogGroups = cwlgs
 
+	// updates active.ServiceDiscoveryService
+	if err := o.processServiceRegistryUpdate(ctx, input, active); err != nil {
+		return nil, err
+	}
+
 	// process updating the service
 	// updates active.Service
 	if err = o.processServiceUpdate(ctx, input, active); err != nil {
@@ -335,7 +699,7 @@ func (o *Orchestrator) UpdateService(ctx context.Context, cluster, service strin
 
 	// if the input tags are passed, clean them and use them, otherwise set to the active service tags
 	if input.Tags != nil {
-		ct, err := cleanTags(o.Org, cluster, "container", "service", input.Tags)
+		ct, err := cleanTags(o.Org, o.AllowedOrgs, cluster, "container", "service", MergeDefaultTags(o.DefaultTags, input.Tags))
 		if err != nil {
 			return nil, err
 		}
@@ -353,5 +717,361 @@ func (o *Orchestrator) UpdateService(ctx context.Context, cluster, service strin
 		return nil, err
 	}
 
+	if input.RolloutWait > 0 {
+		deploymentId := primaryDeploymentId(active.Service.Deployments)
+		if deploymentId == "" {
+			log.Warnf("no primary deployment found for service %s/%s, not checking the rollout", cluster, service)
+			return active, nil
+		}
+
+		// the update has been applied, so failing to get the rollout state is not an error
+		rollout, err := o.serviceRollout(ctx, cluster, service, deploymentId, time.Until(rolloutDeadline))
+		if err != nil {
+			log.Warnf("failed to get the rollout state of service %s/%s deployment %s: %s", cluster, service, deploymentId, err)
+			return active, nil
+		}
+		active.Rollout = rollout
+	}
+
 	return active, nil
 }
+
+// serviceRollout polls the service until the given deployment is no longer in progress or the wait time (up to
+// MaxDeploymentStatusWait) has passed.  When the deployment circuit breaker fails a deployment with rollback
+// enabled, it starts a new primary deployment of the last completed task definition.
+func (o *Orchestrator) serviceRollout(ctx context.Context, cluster, service, deploymentId string, wait time.Duration) (*ServiceRolloutOutput, error) {
+	if wait > MaxDeploymentStatusWait {
+		wait = MaxDeploymentStatusWait
+	}
+
+	output := &ServiceRolloutOutput{DeploymentId: deploymentId}
+	err := pollUntil(ctx, wait, DefaultDeploymentStatusPollInterval, func(ctx context.Context) (bool, error) {
+		svc, err := o.ECS.GetService(ctx, cluster, service)
+		if err != nil {
+			return false, err
+		}
+
+		var deployment, primary *ecs.Deployment
+		for _, d := range svc.Deployments {
+			if aws.StringValue(d.Id) == deploymentId {
+				deployment = d
+			}
+
+			if aws.StringValue(d.Status) == "PRIMARY" {
+				primary = d
+			}
+		}
+
+		if deployment == nil {
+			log.Warnf("deployment %s not found for service %s/%s", deploymentId, cluster, service)
+			return true, nil
+		}
+
+		output.RolloutState = aws.StringValue(deployment.RolloutState)
+		output.RolloutStateReason = aws.StringValue(deployment.RolloutStateReason)
+
+		if output.RolloutState == ecs.DeploymentRolloutStateFailed && primary != nil && aws.StringValue(primary.Id) != deploymentId {
+			output.RolledBack = true
+			output.RolledBackTo = aws.StringValue(primary.TaskDefinition)
+		}
+
+		if output.RolloutState != ecs.DeploymentRolloutStateInProgress {
+			return true, nil
+		}
+
+		log.Infof("waiting for service %s/%s deployment %s rollout", cluster, service, deploymentId)
+
+		return false, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// primaryDeploymentId returns the id of the PRIMARY deployment
+func primaryDeploymentId(deployments []*ecs.Deployment) string {
+	for _, d := range deployments {
+		if aws.StringValue(d.Status) == "PRIMARY" {
+			return aws.StringValue(d.Id)
+		}
+	}
+
+	return ""
+}
+
+// taskDefinitionMatches returns true if the expected task definition (ARN or family:revision) is the same as the
+// current task definition
+func taskDefinitionMatches(expected, current string) bool {
+	if expected == current {
+		return true
+	}
+
+	short := func(td string) string {
+		if i := strings.LastIndex(td, "task-definition/"); i >= 0 {
+			return td[i+len("task-definition/"):]
+		}
+		return td
+	}
+
+	return short(expected) == short(current)
+}
+
+// ServiceDeploymentStatus gets the deployments for a service and determines if the service is stable.  If wait is
+// greater than zero, the service is polled until it's stable or the wait time (up to MaxDeploymentStatusWait) has passed.
+func (o *Orchestrator) ServiceDeploymentStatus(ctx context.Context, cluster, service string, wait time.Duration) (*ServiceDeploymentStatusOutput, error) {
+	if wait > MaxDeploymentStatusWait {
+		wait = MaxDeploymentStatusWait
+	}
+
+	output := &ServiceDeploymentStatusOutput{}
+	err := pollUntil(ctx, wait, DefaultDeploymentStatusPollInterval, func(ctx context.Context) (bool, error) {
+		svc, err := o.ECS.GetService(ctx, cluster, service)
+		if err != nil {
+			return false, err
+		}
+
+		output.Deployments = svc.Deployments
+		output.Stable = ecsapi.DeploymentsStable(svc.Deployments)
+
+		if !output.Stable {
+			log.Infof("waiting for service %s/%s deployment to become stable", cluster, service)
+		}
+
+		return output.Stable, nil
+	})
+
+	// the service not becoming stable in time is not an error, the caller gets the current status
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// ServiceDeploymentFailures gets the recent events of a service and returns the likely causes of a failing deployment
+func (o *Orchestrator) ServiceDeploymentFailures(ctx context.Context, cluster, service string) ([]*ServiceDeploymentFailure, error) {
+	svc, err := o.ECS.GetService(ctx, cluster, service)
+	if err != nil {
+		return nil, err
+	}
+
+	return classifyServiceEvents(svc.Events), nil
+}
+
+// deploymentFailureCategory matches service event messages to a likely cause of a failing deployment
+type deploymentFailureCategory struct {
+	name        string
+	description string
+	// patterns are lower case substrings of the event messages, anchored to the wording of the ecs (and the
+	// underlying service) errors so an event merely mentioning a subnet, security group or key doesn't match
+	patterns []string
+}
+
+// deploymentFailureCategories are checked in order and an event belongs to the first matching category, so the more specific
+// patterns (ie. unable to pull secrets) come before the broader ones (ie. pull)
+var deploymentFailureCategories = []deploymentFailureCategory{
+	{
+		name:        "secret-access",
+		description: "the task execution role can't read the secrets or parameters of the task definition",
+		patterns: []string{
+			"unable to pull secrets",
+			"unable to retrieve secret",
+			"retrieve secrets",
+			"fetching secret data",
+			"kmsaccessdenied",
+			"access to kms is not allowed",
+			"kms key",
+		},
+	},
+	{
+		name:        "image-pull",
+		description: "a container image couldn't be pulled, check the image name, tag and repository credentials",
+		patterns: []string{
+			"cannotpullcontainererror",
+			"pull image",
+			"pull registry auth",
+			"image manifest",
+			"failed to resolve ref",
+			"repository does not exist",
+		},
+	},
+	{
+		name:        "network",
+		description: "a network interface couldn't be attached, check the subnets have free ip addresses and the security groups exist",
+		patterns: []string{
+			"elastic network interface",
+			"networkinterface",
+			"free addresses in subnet",
+			"no free ip addresses in subnet",
+			"invalidsubnetid",
+			"invalidgroup.notfound",
+			"invalidsecuritygroupid",
+		},
+	},
+	{
+		name:        "capacity",
+		description: "tasks couldn't be placed, the cluster or capacity provider doesn't have enough cpu, memory or instances",
+		patterns: []string{
+			"unable to place a task",
+			"unable to place task",
+			"no container instance met all of its requirements",
+			"insufficient memory",
+			"insufficient cpu",
+			"capacity is unavailable",
+			"capacity unavailable",
+		},
+	},
+}
+
+// classifyServiceEvents returns the likely causes of a failing deployment found in the recent service events.  Events are newest
+// first, so only the events since the service last reached a steady state are considered.  The failures are in the order of the
+// categories.
+func classifyServiceEvents(events []*ecs.ServiceEvent) []*ServiceDeploymentFailure {
+	byCategory := map[string]*ServiceDeploymentFailure{}
+	for _, e := range events {
+		if e == nil {
+			continue
+		}
+
+		message := strings.ToLower(aws.StringValue(e.Message))
+		if strings.Contains(message, "has reached a steady state") {
+			break
+		}
+
+		category := classifyServiceEvent(message)
+		if category == nil {
+			continue
+		}
+
+		f, ok := byCategory[category.name]
+		if !ok {
+			f = &ServiceDeploymentFailure{
+				Category:    category.name,
+				Description: category.description,
+				LastSeen:    e.CreatedAt,
+				Message:     aws.StringValue(e.Message),
+			}
+			byCategory[category.name] = f
+		}
+		f.Count++
+	}
+
+	failures := []*ServiceDeploymentFailure{}
+	for _, c := range deploymentFailureCategories {
+		if f, ok := byCategory[c.name]; ok {
+			failures = append(failures, f)
+		}
+	}
+
+	return failures
+}
+
+// classifyServiceEvent returns the first failure category matching the lower case event message, or nil
+func classifyServiceEvent(message string) *deploymentFailureCategory {
+	for i, c := range deploymentFailureCategories {
+		for _, p := range c.patterns {
+			if strings.Contains(message, p) {
+				return &deploymentFailureCategories[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// pollUntil calls f until it's done, it returns an error or the wait time has passed.  f is called right away and
+// then every interval.  The context passed to f is canceled once the wait time has passed, so polling never runs
+// past the wait time.  Running out of time after the first successful call isn't an error, the caller keeps the
+// result of the last call.  When wait isn't greater than zero, f is called once.
+func pollUntil(ctx context.Context, wait, interval time.Duration, f func(ctx context.Context) (bool, error)) error {
+	if wait <= 0 {
+		_, err := f(ctx)
+		return err
+	}
+
+	pollCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for polled := false; ; polled = true {
+		done, err := f(pollCtx)
+		if err != nil {
+			if polled && ctx.Err() == nil && pollCtx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if done {
+			return nil
+		}
+
+		select {
+		case <-pollCtx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServiceRevisionStatus compares the task definition revision the service is running against the latest ACTIVE revision
+// in the task definition family and reports how many revisions behind the service is
+func (o *Orchestrator) ServiceRevisionStatus(ctx context.Context, cluster, service string) (*ServiceRevisionStatusOutput, error) {
+	svc, err := o.ECS.GetService(ctx, cluster, service)
+	if err != nil {
+		return nil, err
+	}
+
+	taskDefinition, _, err := o.ECS.GetTaskDefinition(ctx, svc.TaskDefinition, false)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := o.ECS.ListTaskDefinitionRevisions(ctx, taskDefinition.Family)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &ServiceRevisionStatusOutput{
+		Family:               aws.StringValue(taskDefinition.Family),
+		TaskDefinition:       aws.StringValue(taskDefinition.TaskDefinitionArn),
+		Revision:             aws.Int64Value(taskDefinition.Revision),
+		LatestTaskDefinition: aws.StringValue(taskDefinition.TaskDefinitionArn),
+		LatestRevision:       aws.Int64Value(taskDefinition.Revision),
+	}
+
+	for _, r := range revisions {
+		revision, err := taskDefinitionRevision(r)
+		if err != nil {
+			return nil, apierror.New(apierror.ErrInternalError, "failed to determine task definition revision", err)
+		}
+
+		if revision > output.Revision {
+			output.RevisionsBehind++
+		}
+
+		if revision > output.LatestRevision {
+			output.LatestRevision = revision
+			output.LatestTaskDefinition = r
+		}
+	}
+
+	output.UpToDate = output.RevisionsBehind == 0
+
+	return output, nil
+}
+
+// taskDefinitionRevision returns the revision from a task definition ARN or family:revision
+func taskDefinitionRevision(td string) (int64, error) {
+	i := strings.LastIndex(td, ":")
+	if i < 0 {
+		return 0, fmt.Errorf("task definition %s has no revision", td)
+	}
+
+	return strconv.ParseInt(td[i+1:], 10, 64)
+}
@@ -3,7 +3,9 @@ package orchestration
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/YaleSpinup/apierror"
@@ -23,6 +25,14 @@ type TaskDefCreateOrchestrationInput struct {
 	TaskDefinition *ecs.RegisterTaskDefinitionInput
 	Credentials    map[string]*secretsmanager.CreateSecretInput
 	Tags           []*Tag
+	// map of container definition names to existing secretsmanager secret ARNs to use as private repository credentials
+	ImportCredentials map[string]string
+	// list of container definition names that keep their own log configuration (or none)
+	SkipLogConfiguration []string
+	// region of the awslogs log configuration, defaults to the region of the account
+	AwslogsRegion string
+	// map of container definition names to a map of container secret names and the JSON key to extract from the secretsmanager secret
+	SecretKeys map[string]map[string]string
 }
 
 // TaskCreateOrchestrationOutput is the output payload for a task creation
@@ -40,6 +50,12 @@ type TaskDefUpdateOrchestrationInput struct {
 	TaskDefinition *ecs.RegisterTaskDefinitionInput
 	Credentials    map[string]*secretsmanager.CreateSecretInput
 	Tags           []*Tag
+	// list of container definition names that keep their own log configuration (or none)
+	SkipLogConfiguration []string
+	// region of the awslogs log configuration, defaults to the region of the account
+	AwslogsRegion string
+	// map of container definition names to a map of container secret names and the JSON key to extract from the secretsmanager secret
+	SecretKeys map[string]map[string]string
 }
 
 // TaskDefUpdateOrchestrationOutput is the output payload for updating a taskdef
@@ -73,6 +89,27 @@ type TaskDefShowOutput struct {
 	Tags           []*ecs.Tag
 }
 
+// TaskDefCompatibilityOutput is the declared compatibility and requirements of a task definition
+type TaskDefCompatibilityOutput struct {
+	Family                  *string
+	Revision                *int64
+	Compatibilities         []*string
+	RequiresCompatibilities []*string
+	RequiresAttributes      []*ecs.Attribute
+	Cpu                     *string
+	Memory                  *string
+	NetworkMode             *string
+	FargateCompatible       bool
+}
+
+// TaskDefValidateOutput is the outcome of validating a task definition without registering it.  Errors would cause
+// the task definition to be rejected, warnings are accepted but likely unintended.
+type TaskDefValidateOutput struct {
+	Valid    bool
+	Errors   []string
+	Warnings []string
+}
+
 type TaskDefRunOrchestrationInput *ecs.RunTaskInput
 
 // CreateTask orchestrates the creation of a task.  It creates a cluster, creates repository credrentials in
@@ -85,7 +122,7 @@ func (o *Orchestrator) CreateTaskDef(ctx context.Context, input *TaskDefCreateOr
 
 	spaceid := aws.StringValue(input.Cluster.ClusterName)
 
-	ct, err := cleanTags(o.Org, spaceid, "container", "task", input.Tags)
+	ct, err := cleanTags(o.Org, o.AllowedOrgs, spaceid, "container", "task", MergeDefaultTags(o.DefaultTags, input.Tags))
 	if err != nil {
 		return nil, err
 	}
@@ -109,7 +146,7 @@ func (o *Orchestrator) CreateTaskDef(ctx context.Context, input *TaskDefCreateOr
 	output.Cluster = cluster
 	rollBackTasks = append(rollBackTasks, rbfunc)
 
-	creds, rbfunc, err := o.processTaskDefRepositoryCredentialsCreate(ctx, input)
+	creds, rbfunc, err := o.processTaskDefRepositoryCredentialsCreate(ctx, input, cluster)
 	if err != nil {
 		return nil, err
 	}
@@ -138,7 +175,7 @@ func (o *Orchestrator) UpdateTaskDef(ctx context.Context, cluster, family string
 
 	input.ClusterName = cluster
 
-	taskdef, tags, err := o.ECS.GetTaskDefinition(ctx, aws.String(family), true)
+	taskdef, tags, err := o.getTaskDefinition(ctx, cluster, family, true)
 	if err != nil {
 		return nil, err
 	}
@@ -172,7 +209,7 @@ func (o *Orchestrator) UpdateTaskDef(ctx context.Context, cluster, family string
 
 	// if the input tags are passed, clean them and use them, otherwise set to the active tags
 	if input.Tags != nil {
-		ct, err := cleanTags(o.Org, cluster, "container", "service", input.Tags)
+		ct, err := cleanTags(o.Org, o.AllowedOrgs, cluster, "container", "service", MergeDefaultTags(o.DefaultTags, input.Tags))
 		if err != nil {
 			return nil, err
 		}
@@ -200,12 +237,12 @@ func (o *Orchestrator) DeleteTaskDef(ctx context.Context, input *TaskDefDeleteIn
 		TaskDefinition: input.TaskDefinition,
 	}
 
-	taskDefinition, _, err := o.ECS.GetTaskDefinition(ctx, aws.String(input.TaskDefinition), false)
+	taskDefinition, _, err := o.getTaskDefinition(ctx, input.Cluster, input.TaskDefinition, false)
 	if err != nil {
 		return nil, err
 	}
 
-	runningTasks, err := o.ListTaskDefTasks(ctx, input.Cluster, *taskDefinition.Family, "", []string{"RUNNING"})
+	runningTasks, err := o.listFamilyTasks(ctx, input.Cluster, aws.StringValue(taskDefinition.Family), "", []string{"RUNNING"})
 	if err != nil {
 		return nil, err
 	}
@@ -228,7 +265,7 @@ func (o *Orchestrator) DeleteTaskDef(ctx context.Context, input *TaskDefDeleteIn
 	// for each task definition revision in the task definition family, delete any existing repository credentials, keeping track
 	// of ones we delete so we don't try to re-delete them.
 	// TODO: if we want to share repository credentials, we need to look for multiple container definitions using the same credentials.
-	deletedCredentials := make(map[string]struct{})
+	deletedCredentials := newCredentialsSet()
 
 	// delete the first task definition
 	if err := o.deleteTaskDefinitionRevision(ctx, taskDefinitionRevisions[0], deletedCredentials); err != nil {
@@ -238,12 +275,8 @@ func (o *Orchestrator) DeleteTaskDef(ctx context.Context, input *TaskDefDeleteIn
 	// delete the remaining revisions in the background
 	if len(taskDefinitionRevisions) > 1 {
 		go func(revList []string) {
-			cleanupCtx := context.Background()
-			for _, revision := range taskDefinitionRevisions {
-				if err := o.deleteTaskDefinitionRevision(cleanupCtx, revision, deletedCredentials); err != nil {
-					log.Errorf("failed to delete task def revision %s: %+v", revision, err)
-					continue
-				}
+			for revision, errs := range o.deleteTaskDefinitionRevisions(context.Background(), revList, deletedCredentials) {
+				log.Errorf("failed to delete task def revision %s: %+v", revision, errs)
 			}
 		}(taskDefinitionRevisions[1:])
 	}
@@ -254,6 +287,11 @@ func (o *Orchestrator) DeleteTaskDef(ctx context.Context, input *TaskDefDeleteIn
 		cleanupCtx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
+		// remove any schedules so the deleted task definition isn't run again
+		if err := o.deleteScheduledTasks(cleanupCtx, input.Cluster, aws.StringValue(taskDefinition.Family)); err != nil {
+			log.Errorf("failed to delete schedules for task definition %s: %s", input.TaskDefinition, err)
+		}
+
 		if l := len(runningTasks); l > 0 {
 			taskIds := make([]string, len(runningTasks))
 			reason := fmt.Sprintf("Deleting task definition %s", input.TaskDefinition)
@@ -319,6 +357,10 @@ func (o *Orchestrator) DeleteTaskDef(ctx context.Context, input *TaskDefDeleteIn
 				}
 
 				log.Infof("deleted default task execution role: %s", executionRoleName)
+
+				if err := o.deleteScheduledTaskRole(cleanupCtx, input.Cluster); err != nil {
+					log.Errorf("failed to cleanup scheduled task role: %s", err)
+				}
 			}
 		}
 	}()
@@ -326,9 +368,95 @@ func (o *Orchestrator) DeleteTaskDef(ctx context.Context, input *TaskDefDeleteIn
 	return &output, nil
 }
 
+// credentialsSet tracks the repository credentials deleted while removing task definition revisions.  It's safe
+// for concurrent use so revisions can be deleted in parallel.
+type credentialsSet struct {
+	mu     sync.Mutex
+	claims map[string]*credentialsClaim
+}
+
+// credentialsClaim is a claim on deleting repository credentials, done is closed once the delete is finished
+type credentialsClaim struct {
+	done    chan struct{}
+	deleted bool
+}
+
+func newCredentialsSet() *credentialsSet {
+	return &credentialsSet{claims: make(map[string]*credentialsClaim)}
+}
+
+// claim claims the credentials for deleting, returning false if they were already deleted.  If another revision
+// holds the claim, it waits for that delete to finish and claims the credentials again if the delete failed, so
+// the failed credentials are retried instead of being skipped.
+func (c *credentialsSet) claim(arn string) bool {
+	for {
+		c.mu.Lock()
+		claim, ok := c.claims[arn]
+		if !ok {
+			c.claims[arn] = &credentialsClaim{done: make(chan struct{})}
+			c.mu.Unlock()
+			return true
+		}
+		c.mu.Unlock()
+
+		<-claim.done
+		if claim.deleted {
+			return false
+		}
+	}
+}
+
+// finish finishes the delete of claimed credentials, releasing the claim if the delete failed
+func (c *credentialsSet) finish(arn string, deleted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	claim, ok := c.claims[arn]
+	if !ok {
+		return
+	}
+
+	if !deleted {
+		delete(c.claims, arn)
+	}
+
+	claim.deleted = deleted
+	close(claim.done)
+}
+
+// deleteTaskDefinitionRevisions deletes the list of task definition revisions, deleting up to the configured delete
+// concurrency at a time.  It returns a map of the revisions that failed to delete to their errors.
+func (o *Orchestrator) deleteTaskDefinitionRevisions(ctx context.Context, revisions []string, deletedCredentials *credentialsSet) map[string][]error {
+	failures := map[string][]error{}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, o.deleteConcurrency())
+	for _, revision := range revisions {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(revision string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if errs := o.deleteTaskDefinitionRevision(ctx, revision, deletedCredentials); len(errs) > 0 {
+				mu.Lock()
+				failures[revision] = errs
+				mu.Unlock()
+			}
+		}(revision)
+	}
+	wg.Wait()
+
+	return failures
+}
+
 // deleteTaskDefinitionRevision deletes a task definition revision and associated secretsmanager secrets.  It keeps track
-// of deleted secrets through the deleteCredentials map
-func (o *Orchestrator) deleteTaskDefinitionRevision(ctx context.Context, revision string, deletedCredentials map[string]struct{}) []error {
+// of deleted secrets through the deletedCredentials set
+func (o *Orchestrator) deleteTaskDefinitionRevision(ctx context.Context, revision string, deletedCredentials *credentialsSet) []error {
 	var errors []error
 	taskDefinition, _, err := o.ECS.GetTaskDefinition(ctx, aws.String(revision), false)
 	if err != nil {
@@ -336,23 +464,41 @@ func (o *Orchestrator) deleteTaskDefinitionRevision(ctx context.Context, revisio
 		return []error{err}
 	}
 
+	referenced := map[string]struct{}{}
 	for _, cd := range taskDefinition.ContainerDefinitions {
 		tdArn := aws.StringValue(taskDefinition.TaskDefinitionArn)
 		log.Debugf("cleaning '%s' container definition '%s' components", tdArn, aws.StringValue(cd.Name))
 
 		if cd.RepositoryCredentials != nil && aws.StringValue(cd.RepositoryCredentials.CredentialsParameter) != "" {
-			credsArn := aws.StringValue(cd.RepositoryCredentials.CredentialsParameter)
+			referenced[aws.StringValue(cd.RepositoryCredentials.CredentialsParameter)] = struct{}{}
+		}
+	}
 
-			if _, ok := deletedCredentials[credsArn]; !ok {
-				_, err = o.SecretsManager.DeleteSecret(ctx, credsArn, 0)
-				if err != nil {
-					errors = append(errors, err)
-					continue
-				}
+	// claim the credentials in order so revisions waiting on each other's claims can't deadlock
+	credsArns := make([]string, 0, len(referenced))
+	for credsArn := range referenced {
+		credsArns = append(credsArns, credsArn)
+	}
+	sort.Strings(credsArns)
+
+	credentials := []string{}
+	for _, credsArn := range credsArns {
+		if deletedCredentials.claim(credsArn) {
+			credentials = append(credentials, credsArn)
+		}
+	}
 
-				deletedCredentials[credsArn] = struct{}{}
-				log.Infof("successfully deleted secretsmanager secret '%s'", credsArn)
+	if len(credentials) > 0 {
+		errs := o.SecretsManager.DeleteSecrets(ctx, credentials, 0)
+		for _, credsArn := range credentials {
+			if err, ok := errs[credsArn]; ok {
+				deletedCredentials.finish(credsArn, false)
+				errors = append(errors, err)
+				continue
 			}
+
+			deletedCredentials.finish(credsArn, true)
+			log.Infof("successfully deleted secretsmanager secret '%s'", credsArn)
 		}
 	}
 
@@ -371,19 +517,37 @@ func (o *Orchestrator) deleteTaskDefinitionRevision(ctx context.Context, revisio
 func (o *Orchestrator) ListTaskDefs(ctx context.Context, cluster string) ([]string, error) {
 	log.Infof("listing task definitions in cluster '%s'", cluster)
 
+	families, err := o.taskDefinitionFamilies(ctx, cluster, "task")
+	if err != nil {
+		return nil, err
+	}
+
+	for i, f := range families {
+		families[i] = o.trimTaskDefFamily(cluster, f)
+	}
+
+	return families, nil
+}
+
+// taskDefinitionFamilies gets the unique list of task definition families in the org and the allowed orgs using tags, optionally limited to a
+// cluster and the spinup:flavor (task or service) of the task definitions
+func (o *Orchestrator) taskDefinitionFamilies(ctx context.Context, cluster, flavor string) ([]string, error) {
 	tagFilters := []*resourcegroupstaggingapi.TagFilter{
 		{
 			Key:   "spinup:org",
-			Value: []string{o.Org},
+			Value: o.orgs(),
 		},
 		{
 			Key:   "spinup:type",
 			Value: []string{"container"},
 		},
-		{
+	}
+
+	if flavor != "" {
+		tagFilters = append(tagFilters, &resourcegroupstaggingapi.TagFilter{
 			Key:   "spinup:flavor",
-			Value: []string{"task"},
-		},
+			Value: []string{flavor},
+		})
 	}
 
 	if cluster != "" {
@@ -439,11 +603,14 @@ func (o *Orchestrator) GetTaskDef(ctx context.Context, cluster, family string) (
 		return nil, err
 	}
 
-	tdOutput, tags, err := o.ECS.GetTaskDefinition(ctx, aws.String(family), true)
+	td, tags, err := o.getTaskDefinition(ctx, cluster, family, true)
 	if err != nil {
 		return nil, err
 	}
 
+	tdOutput := *td
+	tdOutput.Family = aws.String(o.trimTaskDefFamily(cluster, aws.StringValue(td.Family)))
+
 	for _, t := range tags {
 		if aws.StringValue(t.Key) != "spinup:spaceid" {
 			continue
@@ -458,19 +625,189 @@ func (o *Orchestrator) GetTaskDef(ctx context.Context, cluster, family string) (
 
 	return &TaskDefShowOutput{
 		Cluster:        cluOutput,
-		TaskDefinition: tdOutput,
+		TaskDefinition: &tdOutput,
 		Tags:           tags,
 	}, nil
 }
 
-func (o *Orchestrator) RunTaskDef(ctx context.Context, cluster, family string, input TaskDefRunOrchestrationInput) (*TaskOutput, error) {
+// TaskDefCompatibility gets the declared compatibilities and requirements of a task definition in a cluster
+func (o *Orchestrator) TaskDefCompatibility(ctx context.Context, cluster, family string) (*TaskDefCompatibilityOutput, error) {
+	if cluster == "" || family == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "cluster and task def family are required", nil)
+	}
+
+	log.Debugf("getting task definition compatibility for %s/%s", cluster, family)
+
+	td, err := o.clusterTaskDefinition(ctx, cluster, family)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TaskDefCompatibilityOutput{
+		Family:                  aws.String(o.trimTaskDefFamily(cluster, aws.StringValue(td.Family))),
+		Revision:                td.Revision,
+		Compatibilities:         td.Compatibilities,
+		RequiresCompatibilities: td.RequiresCompatibilities,
+		RequiresAttributes:      td.RequiresAttributes,
+		Cpu:                     td.Cpu,
+		Memory:                  td.Memory,
+		NetworkMode:             td.NetworkMode,
+		FargateCompatible:       fargateCompatible(td),
+	}, nil
+}
+
+// ValidateTaskDef validates a task definition the same way it's validated before it's registered, without calling
+// any AWS APIs.  Unlike registering, it doesn't stop at the first error so all of the problems are reported at once.
+// Task definitions are validated with the FARGATE launch type unless RequiresCompatibilities is set.
+func (o *Orchestrator) ValidateTaskDef(td *ecs.RegisterTaskDefinitionInput) *TaskDefValidateOutput {
+	output := &TaskDefValidateOutput{
+		Errors:   []string{},
+		Warnings: []string{},
+	}
+
+	addError := func(err error) {
+		if err == nil {
+			return
+		}
+
+		if aerr, ok := err.(apierror.Error); ok {
+			output.Errors = append(output.Errors, aerr.Message)
+			return
+		}
+		output.Errors = append(output.Errors, err.Error())
+	}
+
+	if td == nil || len(td.ContainerDefinitions) == 0 {
+		addError(apierror.New(apierror.ErrBadRequest, "at least one container definition is required", nil))
+		return output
+	}
+
+	log.Debugf("validating task definition %s", aws.StringValue(td.Family))
+
+	for _, err := range o.taskDefinitionErrors(td, taskDefinitionCompatibilities(td)) {
+		addError(err)
+	}
+
+	if _, err := o.awslogsDeliveryOptions(); err != nil {
+		addError(fmt.Errorf("invalid default log configuration: %s", err))
+	}
+
+	for _, cd := range td.ContainerDefinitions {
+		if cd == nil {
+			continue
+		}
+
+		name := aws.StringValue(cd.Name)
+
+		// unpinned images are only an error with strict image references
+		if pinned, err := validateImageReference(name, aws.StringValue(cd.Image)); err == nil && !pinned && !o.StrictImageReferences {
+			msg := fmt.Sprintf("image reference '%s' for container %s doesn't include a tag or digest, latest will be pulled", aws.StringValue(cd.Image), name)
+			output.Warnings = append(output.Warnings, msg)
+		}
+
+		if rc := cd.RepositoryCredentials; rc != nil {
+			ref, err := parseSecretsManagerValueFrom(aws.StringValue(rc.CredentialsParameter))
+			if err != nil || ref.jsonKey != "" || ref.versionStage != "" || ref.versionID != "" {
+				msg := fmt.Sprintf("invalid repository credentials '%s' for container %s, must be a secretsmanager secret arn", aws.StringValue(rc.CredentialsParameter), name)
+				addError(apierror.New(apierror.ErrBadRequest, msg, err))
+			}
+		}
+
+		if cd.LogConfiguration != nil {
+			msg := fmt.Sprintf("log configuration for container %s will be replaced by the default awslogs configuration unless the container is skipped", name)
+			output.Warnings = append(output.Warnings, msg)
+		}
+	}
+
+	output.Valid = len(output.Errors) == 0
+
+	return output
+}
+
+// TaskDefContainers gets the container definitions of a task definition in a cluster.  Secrets and repository
+// credentials are only referenced by their ARNs, so no secret values are returned.
+func (o *Orchestrator) TaskDefContainers(ctx context.Context, cluster, family string) ([]*ecs.ContainerDefinition, error) {
+	if cluster == "" || family == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "cluster and task def family are required", nil)
+	}
+
+	log.Debugf("getting task definition containers for %s/%s", cluster, family)
+
+	td, err := o.clusterTaskDefinition(ctx, cluster, family)
+	if err != nil {
+		return nil, err
+	}
+
+	if td.ContainerDefinitions == nil {
+		return []*ecs.ContainerDefinition{}, nil
+	}
+
+	return td.ContainerDefinitions, nil
+}
+
+// clusterTaskDefinition gets a task definition (by family or family:revision) in a cluster, mapping a missing task
+// definition or one belonging to another cluster to not found
+func (o *Orchestrator) clusterTaskDefinition(ctx context.Context, cluster, family string) (*ecs.TaskDefinition, error) {
+	td, tags, err := o.getTaskDefinition(ctx, cluster, family, true)
+	if err != nil {
+		// ECS responds with a client exception when the task definition doesn't exist
+		if aerr, ok := err.(apierror.Error); ok && aerr.Code == apierror.ErrBadRequest {
+			msg := fmt.Sprintf("taskdef %s not found", family)
+			return nil, apierror.New(apierror.ErrNotFound, msg, err)
+		}
+		return nil, err
+	}
+
+	for _, t := range tags {
+		if aws.StringValue(t.Key) == "spinup:spaceid" && aws.StringValue(t.Value) != cluster {
+			return nil, apierror.New(apierror.ErrNotFound, "taskdef not found in cluster", nil)
+		}
+	}
+
+	return td, nil
+}
+
+// fargateCompatible determines if the task definition can be run with the FARGATE launch type.  ECS
+// computes the compatibilities it validated the task definition against, falling back to the declared
+// requirements and awsvpc networking when they aren't available.
+func fargateCompatible(td *ecs.TaskDefinition) bool {
+	compatibilities := td.Compatibilities
+	if len(compatibilities) == 0 {
+		if aws.StringValue(td.NetworkMode) != ecs.NetworkModeAwsvpc {
+			return false
+		}
+		compatibilities = td.RequiresCompatibilities
+	}
+
+	for _, c := range compatibilities {
+		if aws.StringValue(c) == ecs.CompatibilityFargate {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RunTaskDef runs the task definition family in the cluster.  If no network configuration is passed, the default subnets
+// and security groups are used, optionally narrowed down by the network selection.
+func (o *Orchestrator) RunTaskDef(ctx context.Context, cluster, family string, input TaskDefRunOrchestrationInput, selection *NetworkSelection) (*TaskOutput, error) {
+	if input.NetworkConfiguration != nil && selection != nil {
+		return nil, apierror.New(apierror.ErrBadRequest, "network configuration and network selection cannot both be passed", nil)
+	}
+
+	if input.Count != nil {
+		if err := validateTaskCount(aws.Int64Value(input.Count)); err != nil {
+			return nil, err
+		}
+	}
+
 	clu, err := o.ECS.GetCluster(ctx, aws.String(cluster))
 	if err != nil {
 		return nil, err
 	}
 	input.Cluster = clu.ClusterArn
 
-	taskdef, _, err := o.ECS.GetTaskDefinition(ctx, aws.String(family), false)
+	taskdef, _, err := o.getTaskDefinition(ctx, cluster, family, false)
 	if err != nil {
 		return nil, err
 	}
@@ -478,24 +815,56 @@ func (o *Orchestrator) RunTaskDef(ctx context.Context, cluster, family string, i
 
 	log.Debugf("got task definition %+v", taskdef)
 
-	input.EnableECSManagedTags = aws.Bool(true)
+	if input.EnableECSManagedTags == nil {
+		input.EnableECSManagedTags = DefaultEnableECSManagedTags
+	}
+
+	// tags passed by the caller are applied to the tasks instead of propagating the task definition's tags
+	if len(input.Tags) > 0 {
+		if input.PropagateTags == nil {
+			input.PropagateTags = aws.String(ecs.PropagateTagsNone)
+		}
+
+		if aws.StringValue(input.PropagateTags) != ecs.PropagateTagsNone {
+			msg := fmt.Sprintf("tags cannot be passed with propagateTags %s, set propagateTags to %s to apply the passed tags", aws.StringValue(input.PropagateTags), ecs.PropagateTagsNone)
+			return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		inputTags := make([]*Tag, len(input.Tags))
+		for i, t := range input.Tags {
+			inputTags[i] = &Tag{Key: t.Key, Value: t.Value}
+		}
+
+		ct, err := cleanTags(o.Org, o.AllowedOrgs, cluster, "container", "task", MergeDefaultTags(o.DefaultTags, inputTags))
+		if err != nil {
+			return nil, err
+		}
+		input.Tags = ecsTags(ct)
+	}
+
+	if input.PropagateTags == nil {
+		input.PropagateTags = DefaultTaskPropagateTags
+	}
+
+	// tasks run outside of a service can't propagate tags from one
+	if err := validatePropagateTags(input.PropagateTags, []string{ecs.PropagateTagsTaskDefinition, ecs.PropagateTagsNone}); err != nil {
+		return nil, err
+	}
 
 	if input.CapacityProviderStrategy == nil {
 		input.LaunchType = aws.String("FARGATE")
+	} else if err := validateCapacityProviderStrategy(clu, input.CapacityProviderStrategy); err != nil {
+		return nil, err
 	}
 
 	if input.NetworkConfiguration == nil {
-		input.NetworkConfiguration = &ecs.NetworkConfiguration{
-			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
-				AssignPublicIp: aws.String(o.DefaultPublic),
-				SecurityGroups: aws.StringSlice(o.DefaultSecurityGroups),
-				Subnets:        aws.StringSlice(o.DefaultSubnets),
-			},
+		nc, err := o.defaultNetworkConfiguration(selection)
+		if err != nil {
+			return nil, err
 		}
+		input.NetworkConfiguration = nc
 	}
 
-	input.PropagateTags = aws.String("TASK_DEFINITION")
-
 	in := ecs.RunTaskInput(*input)
 	out, err := o.ECS.RunTask(ctx, &in)
 	if err != nil {
@@ -510,11 +879,124 @@ func (o *Orchestrator) RunTaskDef(ctx context.Context, cluster, family string, i
 	return output, nil
 }
 
+// validateTaskCount ensures the number of tasks to run at once is within the ECS limit
+func validateTaskCount(count int64) error {
+	if count < 1 || count > MaxTaskRunCount {
+		msg := fmt.Sprintf("invalid task count %d, must be between 1 and %d", count, MaxTaskRunCount)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	return nil
+}
+
+// TaskRunWaitOutput is the outcome of running a task and waiting for it to stop
+type TaskRunWaitOutput struct {
+	Task *Task
+	// Stopped is true if the task stopped before the wait timed out
+	Stopped bool
+	// Container is the name of the essential container the exit code is reported for
+	Container string `json:",omitempty"`
+	// ExitCode of the essential container, it's not set if the task didn't stop or the container never ran
+	ExitCode      *int64 `json:",omitempty"`
+	StopCode      string `json:",omitempty"`
+	StoppedReason string `json:",omitempty"`
+}
+
+// RunTaskAndWait runs a single task from a task definition and polls the task until it's STOPPED or the wait time (up
+// to MaxTaskRunWait) has passed.  A task that doesn't stop in time is not an error, the current status is returned with
+// Stopped set to false.
+func (o *Orchestrator) RunTaskAndWait(ctx context.Context, cluster, family string, input TaskDefRunOrchestrationInput, selection *NetworkSelection, wait time.Duration) (*TaskRunWaitOutput, error) {
+	if input.Count != nil && aws.Int64Value(input.Count) != 1 {
+		return nil, apierror.New(apierror.ErrBadRequest, "only a single task can be run when waiting for completion", nil)
+	}
+
+	if wait > MaxTaskRunWait {
+		wait = MaxTaskRunWait
+	}
+	deadline := time.Now().Add(wait)
+
+	run, err := o.RunTaskDef(ctx, cluster, family, input, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(run.Tasks) == 0 {
+		reasons := make([]string, 0, len(run.Failures))
+		for _, f := range run.Failures {
+			reasons = append(reasons, aws.StringValue(f.Reason))
+		}
+		msg := fmt.Sprintf("failed to start task: %s", strings.Join(reasons, ", "))
+		return nil, apierror.New(apierror.ErrInternalError, msg, nil)
+	}
+
+	taskArn := aws.StringValue(run.Tasks[0].TaskArn)
+	output := &TaskRunWaitOutput{Task: run.Tasks[0]}
+	for {
+		tasks, err := o.GetTask(ctx, cluster, taskArn)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tasks.Tasks) > 0 {
+			output.Task = tasks.Tasks[0]
+		}
+
+		if aws.StringValue(output.Task.LastStatus) == ecs.DesiredStatusStopped {
+			break
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			log.Infof("task %s didn't stop in %s", taskArn, wait)
+			return output, nil
+		}
+
+		sleep := DefaultTaskStatusPollInterval
+		if remaining < sleep {
+			sleep = remaining
+		}
+
+		log.Debugf("waiting %s for task %s to stop", sleep, taskArn)
+
+		select {
+		case <-ctx.Done():
+			return nil, apierror.New(apierror.ErrInternalError, "cancelled waiting for task "+taskArn+" to stop", ctx.Err())
+		case <-time.After(sleep):
+		}
+	}
+
+	taskDefinition, _, err := o.ECS.GetTaskDefinition(ctx, output.Task.TaskDefinitionArn, false)
+	if err != nil {
+		return nil, err
+	}
+
+	output.Stopped = true
+	output.StopCode = aws.StringValue(output.Task.StopCode)
+	output.StoppedReason = aws.StringValue(output.Task.StoppedReason)
+	if c := essentialContainer(output.Task.Task, taskDefinition.ContainerDefinitions); c != nil {
+		output.Container = aws.StringValue(c.Name)
+		output.ExitCode = c.ExitCode
+	}
+
+	return output, nil
+}
+
+// ListTaskDefTasks lists the tasks of a task definition family in the cluster with the given statuses
 func (o *Orchestrator) ListTaskDefTasks(ctx context.Context, cluster, taskdef, startedBy string, status []string) ([]string, error) {
+	family, err := o.resolveTaskDefFamily(ctx, cluster, taskdef)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.listFamilyTasks(ctx, cluster, family, startedBy, status)
+}
+
+// listFamilyTasks lists the tasks of the (full) task definition family in the cluster with the given statuses
+func (o *Orchestrator) listFamilyTasks(ctx context.Context, cluster, family, startedBy string, status []string) ([]string, error) {
 	input := ecs.ListTasksInput{
 		MaxResults: aws.Int64(100),
 		Cluster:    aws.String(cluster),
-		Family:     aws.String(taskdef),
+		Family:     aws.String(family),
 	}
 
 	if startedBy != "" {
@@ -535,3 +1017,61 @@ func (o *Orchestrator) ListTaskDefTasks(ctx context.Context, cluster, taskdef, s
 
 	return aws.StringValueSlice(tasks), nil
 }
+
+// taskDefFamily returns the family name of a managed task definition in the cluster.  When PrefixTaskDefinitionFamilies
+// is enabled, the family is namespaced as org-space-family (unless it's already prefixed), otherwise it's used verbatim.
+func (o *Orchestrator) taskDefFamily(cluster, family string) string {
+	if !o.PrefixTaskDefinitionFamilies || family == "" {
+		return family
+	}
+
+	prefix := o.taskDefFamilyPrefix(cluster)
+	if strings.HasPrefix(family, prefix) {
+		return family
+	}
+
+	return prefix + family
+}
+
+// getTaskDefinition gets a managed task definition (by family or family:revision) in the cluster.  When
+// PrefixTaskDefinitionFamilies is enabled, legacy families registered before they were prefixed are found
+// by their bare name if the prefixed family doesn't exist.
+func (o *Orchestrator) getTaskDefinition(ctx context.Context, cluster, family string, includeTags bool) (*ecs.TaskDefinition, []*ecs.Tag, error) {
+	fullFamily := o.taskDefFamily(cluster, family)
+
+	td, tags, err := o.ECS.GetTaskDefinition(ctx, aws.String(fullFamily), includeTags)
+	if aerr, ok := err.(apierror.Error); ok && aerr.Code == apierror.ErrBadRequest && fullFamily != family {
+		// ECS responds with a client exception when the task definition doesn't exist
+		log.Debugf("task definition %s not found, trying legacy family %s", fullFamily, family)
+		td, tags, err = o.ECS.GetTaskDefinition(ctx, aws.String(family), includeTags)
+	}
+
+	return td, tags, err
+}
+
+// resolveTaskDefFamily returns the full name of a managed task definition family in the cluster, the legacy family
+// if only it exists.  If neither exists (ie. the family was deleted), the managed family name is returned.
+func (o *Orchestrator) resolveTaskDefFamily(ctx context.Context, cluster, family string) (string, error) {
+	td, _, err := o.getTaskDefinition(ctx, cluster, family, false)
+	if err != nil {
+		if aerr, ok := err.(apierror.Error); ok && aerr.Code == apierror.ErrBadRequest {
+			return taskDefinitionArnFamily(o.taskDefFamily(cluster, family)), nil
+		}
+		return "", err
+	}
+
+	return aws.StringValue(td.Family), nil
+}
+
+// trimTaskDefFamily strips the org-space- prefix from a managed task definition family
+func (o *Orchestrator) trimTaskDefFamily(cluster, family string) string {
+	if !o.PrefixTaskDefinitionFamilies || cluster == "" {
+		return family
+	}
+
+	return strings.TrimPrefix(family, o.taskDefFamilyPrefix(cluster))
+}
+
+func (o *Orchestrator) taskDefFamilyPrefix(cluster string) string {
+	return fmt.Sprintf("%s-%s-", o.Org, cluster)
+}
@@ -1,53 +1,166 @@
 package orchestration
 
 import (
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling/applicationautoscalingiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
 	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
 	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
 
+	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
 )
 
+type mockAASClient struct {
+	applicationautoscalingiface.ApplicationAutoScalingAPI
+	t   *testing.T
+	err error
+	// deletedPolicies and deregisteredTargets record the scaling resources removed through the mock
+	deletedPolicies     []string
+	deregisteredTargets []string
+}
+
+type mockCWClient struct {
+	cloudwatchiface.CloudWatchAPI
+	t   *testing.T
+	err error
+}
+
 type mockCWLClient struct {
 	cloudwatchlogsiface.CloudWatchLogsAPI
 	t   *testing.T
 	err error
+	// logGroups are the names of the log groups that exist, created log groups are appended
+	logGroups []string
 }
 
 type mockECSClient struct {
 	ecsiface.ECSAPI
 	t   *testing.T
 	err error
+	// tags are the tags listed for each resource arn, overriding the default tags
+	tags map[string][]*ecs.Tag
+	// stopped records the tasks stopped through the mock
+	stopped []string
+	// stopErrs maps task ids to the error returned when stopping them
+	stopErrs map[string]error
+	// startedBy records the startedBy filter of each call listing tasks
+	startedBy []string
+	// describeDelay is how long describing services takes, unless the context is canceled first
+	describeDelay time.Duration
+	// services are the names of the services listed for each cluster
+	services map[string][]string
+	// describedTaskDefinitions records the task definitions described through the mock
+	describedTaskDefinitions []string
+}
+
+type mockEBClient struct {
+	eventbridgeiface.EventBridgeAPI
+	t   *testing.T
+	err error
+	// rules are the rules described by the mock
+	rules []*eventbridge.DescribeRuleOutput
+	// targets are the targets listed for each rule
+	targets map[string][]*eventbridge.Target
 }
 
 type mockIAMClient struct {
 	iamiface.IAMAPI
 	t   *testing.T
 	err error
+	// assumeRolePolicy records the assume role policy document of the last role created through the mock
+	assumeRolePolicy string
+	// rolePolicy records the last inline policy document put through the mock
+	rolePolicy string
 }
 
-type mockRGTAClient struct {
-	resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+type mockKMSClient struct {
+	kmsiface.KMSAPI
 	t   *testing.T
 	err error
 }
 
+type mockRGTAClient struct {
+	resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+	t         *testing.T
+	err       error
+	resources []string
+	// tagged maps resource ARNs to their tags, tagged resources are only returned if they match the type and tag filters
+	tagged map[string]map[string]string
+}
+
 type mockSDClient struct {
 	servicediscoveryiface.ServiceDiscoveryAPI
 	t   *testing.T
 	err error
+	// created records the inputs of the service discovery services created through the mock
+	created []*servicediscovery.CreateServiceInput
+	// updated records the inputs of the service discovery service updates made through the mock
+	updated []*servicediscovery.UpdateServiceInput
+	// deregistered records the ids of the instances deregistered through the mock
+	deregistered []string
+	// deleted records the ids of the service discovery services deleted through the mock
+	deleted []string
 }
 
 type mockSMClient struct {
 	secretsmanageriface.SecretsManagerAPI
 	t   *testing.T
 	err error
+	// deleted records the ids of the secrets deleted through the mock
+	mu      sync.Mutex
+	deleted []string
+	// tags are the tags described for each secret arn
+	tags map[string][]*secretsmanager.Tag
+	// rotation are the rotation rules described for each rotating secret arn
+	rotation map[string]*secretsmanager.RotationRulesType
+	// secrets are the secrets listed by the mock
+	secrets []*secretsmanager.SecretListEntry
+	// replicated records the inputs of the secret replications requested through the mock
+	replicated []*secretsmanager.ReplicateSecretToRegionsInput
+	// kmsKeyIds records the kms key passed when creating each secret
+	kmsKeyIds map[string]string
+	// rekeyed records the kms key passed when updating the key of each secret
+	rekeyed map[string]string
+	// windows records the recovery window of each secret deleted through the mock, 0 if it was forced
+	windows map[string]int64
+	// deleteFailures are the number of times deleting each secret arn fails before it succeeds
+	deleteFailures map[string]int
+}
+
+func newMockAASClient(t *testing.T, err error) applicationautoscalingiface.ApplicationAutoScalingAPI {
+	m := mockAASClient{
+		t:   t,
+		err: err,
+	}
+
+	log.Infof("returning mock application autoscaling client %+v", m)
+
+	return &m
+}
+
+func newMockCWClient(t *testing.T, err error) cloudwatchiface.CloudWatchAPI {
+	m := mockCWClient{
+		t:   t,
+		err: err,
+	}
+
+	log.Infof("returning mock cloudwatch client %+v", m)
+
+	return &m
 }
 
 func newMockCWLClient(t *testing.T, err error) cloudwatchlogsiface.CloudWatchLogsAPI {
@@ -72,6 +185,17 @@ func newMockECSClient(t *testing.T, err error) ecsiface.ECSAPI {
 	return &m
 }
 
+func newMockEBClient(t *testing.T, err error) eventbridgeiface.EventBridgeAPI {
+	m := mockEBClient{
+		t:   t,
+		err: err,
+	}
+
+	log.Infof("returning mock eventbridge client %+v", m)
+
+	return &m
+}
+
 func newMockIAMClient(t *testing.T, err error) iamiface.IAMAPI {
 	m := mockIAMClient{
 		t:   t,
@@ -83,6 +207,17 @@ func newMockIAMClient(t *testing.T, err error) iamiface.IAMAPI {
 	return &m
 }
 
+func newMockKMSClient(t *testing.T, err error) kmsiface.KMSAPI {
+	m := mockKMSClient{
+		t:   t,
+		err: err,
+	}
+
+	log.Infof("returning mock kms client %+v", m)
+
+	return &m
+}
+
 func newMockResourceGroupTaggingApiClient(t *testing.T, err error) resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI {
 	m := mockRGTAClient{
 		t:   t,
@@ -95,14 +230,14 @@ func newMockResourceGroupTaggingApiClient(t *testing.T, err error) resourcegroup
 }
 
 func newMockSMClient(t *testing.T, err error) secretsmanageriface.SecretsManagerAPI {
-	m := mockSMClient{
+	m := &mockSMClient{
 		t:   t,
 		err: err,
 	}
 
 	log.Infof("returning mock secretsmanager client %+v", m)
 
-	return &m
+	return m
 }
 
 func newMockSDClient(t *testing.T, err error) servicediscoveryiface.ServiceDiscoveryAPI {
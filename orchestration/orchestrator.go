@@ -7,9 +7,13 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/YaleSpinup/ecs-api/applicationautoscaling"
+	"github.com/YaleSpinup/ecs-api/cloudwatch"
 	"github.com/YaleSpinup/ecs-api/cloudwatchlogs"
 	"github.com/YaleSpinup/ecs-api/ecs"
+	"github.com/YaleSpinup/ecs-api/eventbridge"
 	"github.com/YaleSpinup/ecs-api/iam"
+	"github.com/YaleSpinup/ecs-api/kms"
 	"github.com/YaleSpinup/ecs-api/resourcegroupstaggingapi"
 	"github.com/YaleSpinup/ecs-api/secretsmanager"
 	"github.com/YaleSpinup/ecs-api/servicediscovery"
@@ -36,17 +40,68 @@ var (
 	DefaultNetworkMode = aws.String("awsvpc")
 	// DefaultLaunchType sets the default launch type to Fargate
 	DefaultLaunchType = aws.String("FARGATE")
+	// DefaultSchedulingStrategy sets the default service scheduling strategy to replica
+	DefaultSchedulingStrategy = aws.String("REPLICA")
 	// DefaultCloudwatchLogsRetention sets the detfault retention (in days) for logs in cloudwatch
 	DefaultCloudwatchLogsRetention = aws.Int64(int64(365))
+	// DefaultLogGroupNamePattern names the default log group after the cluster
+	DefaultLogGroupNamePattern = "{cluster}"
+	// DefaultEnableECSManagedTags enables ECS managed tags on services created and tasks run by the api
+	DefaultEnableECSManagedTags = aws.Bool(true)
+	// DefaultServicePropagateTags sets where tags are propagated from for tasks started by
+	// services created by the api, so the service tags (ie. cost allocation tags) reach the tasks.
+	// It can be configured with ServicePropagateTags or overridden in the service create input.
+	DefaultServicePropagateTags = aws.String("SERVICE")
+	// DefaultTaskPropagateTags sets where tags are propagated from for tasks run by the api.  It
+	// can be overridden in the task run input.
+	DefaultTaskPropagateTags = aws.String("TASK_DEFINITION")
+	// DefaultDeploymentStatusPollInterval is the interval between polls when waiting for a service
+	// deployment to become stable
+	DefaultDeploymentStatusPollInterval = 2 * time.Second
+	// MaxDeploymentStatusWait is the maximum amount of time to wait for a service deployment to become stable.  It
+	// must stay below the api server write timeout or the response will be dropped.
+	MaxDeploymentStatusWait = 10 * time.Second
+	// DefaultTaskStatusPollInterval is the interval between polls when waiting for a task to stop
+	DefaultTaskStatusPollInterval = 2 * time.Second
+	// MaxTaskRunWait is the maximum amount of time to wait for a task to stop.  Like MaxDeploymentStatusWait, it
+	// must stay below the api server write timeout.
+	MaxTaskRunWait = 10 * time.Second
+	// MaxTaskRunCount is the maximum number of tasks ECS can run from a task definition in a single call
+	MaxTaskRunCount = int64(10)
+	// OrphanedCredentialsRecoveryWindow is the recovery window (in days) of the orphaned secrets deleted by the api,
+	// the secretsmanager default, so a secret that was still in use can be restored
+	OrphanedCredentialsRecoveryWindow = int64(30)
+	// DefaultDeleteTimeout is the default amount of time to wait for a cluster or service registry
+	// to be deleted when removing dependencies recursively
+	DefaultDeleteTimeout = 120 * time.Second
+	// MaxDeleteWait is the maximum amount of time to spend removing the dependencies of a deleted service while the
+	// caller waits for the outcome.  Like MaxDeploymentStatusWait, it must stay below the api server write timeout.
+	MaxDeleteWait = 10 * time.Second
+	// DefaultDeleteGracePeriod is the default amount of time to wait before removing dependencies
+	// recursively in the background, the delete can be canceled until then
+	DefaultDeleteGracePeriod = 30 * time.Second
+	// DefaultRepositoryCredentialsConcurrency is the number of repository credentials secrets created at once
+	DefaultRepositoryCredentialsConcurrency = 5
+	// DefaultDeleteConcurrency is the default number of task definition revisions deleted at once
+	// when removing dependencies recursively
+	DefaultDeleteConcurrency = 1
 )
 
 // Orchestrator holds the service discovery client, iam client, ecs client, secretsmanager client, input, and output
 type Orchestrator struct {
+	// https://docs.aws.amazon.com/sdk-for-go/api/service/applicationautoscaling/
+	ApplicationAutoScaling applicationautoscaling.ApplicationAutoScaling
+	// https://docs.aws.amazon.com/sdk-for-go/api/service/cloudwatch/
+	CloudWatch     cloudwatch.CloudWatch
 	CloudWatchLogs cloudwatchlogs.CloudWatchLogs
 	// https://docs.aws.amazon.com/sdk-for-go/api/service/ecs/#ECS
 	ECS ecs.ECS
+	// https://docs.aws.amazon.com/sdk-for-go/api/service/eventbridge/
+	EventBridge eventbridge.EventBridge
 	// https://docs.aws.amazon.com/sdk-for-go/api/service/iam/#IAM
 	IAM iam.IAM
+	// https://docs.aws.amazon.com/sdk-for-go/api/service/kms/
+	KMS kms.KMS
 	// https://docs.aws.amazon.com/sdk-for-go/api/service/resourcegroupstaggingapi/
 	ResourceGroupsTaggingAPI resourcegroupstaggingapi.ResourceGroupsTaggingAPI
 	// https://docs.aws.amazon.com/sdk-for-go/api/service/secretsmanager/#SecretsManager
@@ -55,14 +110,89 @@ type Orchestrator struct {
 	ServiceDiscovery servicediscovery.ServiceDiscovery
 	// Token is a uniqueness token for calls to AWS
 	Token string
+	// Account is the name of the account this orchestration runs in
+	Account string
 	// DefaultPublic disables the setting of public IPs on ENIs by default
 	DefaultPublic string
 	// DefaultSubnets sets a list of default subnets to attach ENIs
 	DefaultSubnets []string
 	// DefaultSecurityGroups sets a list of default sgs to attach to ENIs
 	DefaultSecurityGroups []string
+	// DefaultSubnetLabels maps names to subsets of the default subnets that can be selected per request
+	DefaultSubnetLabels map[string][]string
+	// DefaultSecurityGroupLabels maps names to subsets of the default sgs that can be selected per request
+	DefaultSecurityGroupLabels map[string][]string
 	// Org is the organization where this orchestration runs
 	Org string
+	// AllowedOrgs are additional organizations that resources managed by this orchestration may belong to
+	AllowedOrgs []string
+	// DefaultTags are added to the tags of the resources created by this orchestration, unless the caller
+	// passes a tag with the same key
+	DefaultTags map[string]string
+	// ServicePropagateTags is where tags are propagated from for tasks started by services created by this
+	// orchestration when the caller doesn't pass one, DefaultServicePropagateTags is used if it's not set
+	ServicePropagateTags string
+	// DeleteTimeout is the amount of time to wait for a cluster or service registry to be deleted
+	// when removing dependencies recursively, DefaultDeleteTimeout is used if it's not set
+	DeleteTimeout time.Duration
+	// DeleteConcurrency is the number of task definition revisions deleted at once when removing
+	// dependencies recursively, DefaultDeleteConcurrency is used if it's not set
+	DeleteConcurrency int
+	// DeleteGracePeriod is the amount of time to wait before removing dependencies recursively in the
+	// background, DefaultDeleteGracePeriod is used if it's not set
+	DeleteGracePeriod time.Duration
+	// PendingDeletes tracks the recursive deletes that can still be canceled
+	PendingDeletes *PendingDeletes
+	// SecretReferences caches the secrets referenced by task definition revisions when finding orphaned credentials
+	SecretReferences *SecretReferences
+	// StrictImageReferences rejects container images that don't specify a tag or digest
+	StrictImageReferences bool
+	// AllowedRegistries are the registry hostnames (or patterns) container images may be pulled from, all
+	// registries are allowed if it's empty
+	AllowedRegistries []string
+	// DeniedRegistries are the registry hostnames (or patterns) container images may not be pulled from
+	DeniedRegistries []string
+	// StrictRepositoryCredentials rejects repository credentials that aren't the docker registry credentials JSON
+	StrictRepositoryCredentials bool
+	// UniqueRepositoryCredentialsNames appends a unique suffix to the name of a repository credentials secret when
+	// a secret with the same name already exists, instead of returning a conflict
+	UniqueRepositoryCredentialsNames bool
+	// DisableTaskExecutionRoleCreation requires the task execution roles to be created ahead of time, they
+	// are used as-is and never created, updated or deleted
+	DisableTaskExecutionRoleCreation bool
+	// AwslogsMode sets the awslogs-mode (blocking or non-blocking) of the default log configuration, the
+	// option is omitted (blocking) if it's not set
+	AwslogsMode string
+	// AwslogsMaxBufferSize sets the max-buffer-size of the default log configuration in non-blocking mode
+	AwslogsMaxBufferSize string
+	// AwslogsGroupNamePattern is the name pattern of the default log group, DefaultLogGroupNamePattern is used if it's not set
+	AwslogsGroupNamePattern string
+	// PrefixTaskDefinitionFamilies namespaces the families of managed task definitions as org-space-family
+	PrefixTaskDefinitionFamilies bool
+}
+
+// deleteTimeout returns the configured recursive delete timeout or the default
+func (o *Orchestrator) deleteTimeout() time.Duration {
+	if o.DeleteTimeout > 0 {
+		return o.DeleteTimeout
+	}
+	return DefaultDeleteTimeout
+}
+
+// deleteGracePeriod returns the configured recursive delete grace period or the default
+func (o *Orchestrator) deleteGracePeriod() time.Duration {
+	if o.DeleteGracePeriod > 0 {
+		return o.DeleteGracePeriod
+	}
+	return DefaultDeleteGracePeriod
+}
+
+// deleteConcurrency returns the configured recursive delete concurrency or the default
+func (o *Orchestrator) deleteConcurrency() int {
+	if o.DeleteConcurrency > 0 {
+		return o.DeleteConcurrency
+	}
+	return DefaultDeleteConcurrency
 }
 
 type rollbackFunc func(ctx context.Context) error
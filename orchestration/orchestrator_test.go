@@ -3,9 +3,13 @@ package orchestration
 import (
 	"testing"
 
+	"github.com/YaleSpinup/ecs-api/applicationautoscaling"
+	"github.com/YaleSpinup/ecs-api/cloudwatch"
 	"github.com/YaleSpinup/ecs-api/cloudwatchlogs"
 	"github.com/YaleSpinup/ecs-api/ecs"
+	"github.com/YaleSpinup/ecs-api/eventbridge"
 	"github.com/YaleSpinup/ecs-api/iam"
+	"github.com/YaleSpinup/ecs-api/kms"
 	"github.com/YaleSpinup/ecs-api/resourcegroupstaggingapi"
 	"github.com/YaleSpinup/ecs-api/secretsmanager"
 	"github.com/YaleSpinup/ecs-api/servicediscovery"
@@ -15,9 +19,13 @@ import (
 
 func newMockOrchestrator(t *testing.T, org string, cwlerr, ecserr, iamerr, rgtaerr, smerr, sderr error) *Orchestrator {
 	o := Orchestrator{
+		ApplicationAutoScaling:   applicationautoscaling.ApplicationAutoScaling{Service: newMockAASClient(t, nil)},
+		CloudWatch:               cloudwatch.CloudWatch{Service: newMockCWClient(t, nil)},
 		CloudWatchLogs:           cloudwatchlogs.CloudWatchLogs{Service: newMockCWLClient(t, cwlerr)},
 		ECS:                      ecs.ECS{Service: newMockECSClient(t, ecserr)},
+		EventBridge:              eventbridge.EventBridge{Service: newMockEBClient(t, nil)},
 		IAM:                      iam.IAM{Service: newMockIAMClient(t, iamerr)},
+		KMS:                      kms.KMS{Service: newMockKMSClient(t, nil)},
 		ResourceGroupsTaggingAPI: resourcegroupstaggingapi.ResourceGroupsTaggingAPI{Service: newMockResourceGroupTaggingApiClient(t, rgtaerr)},
 		SecretsManager:           secretsmanager.SecretsManager{Service: newMockSMClient(t, smerr)},
 		ServiceDiscovery:         servicediscovery.ServiceDiscovery{Service: newMockSDClient(t, sderr)},
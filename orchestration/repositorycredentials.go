@@ -2,23 +2,32 @@ package orchestration
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"path"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
+	"github.com/YaleSpinup/apierror"
+	sm "github.com/YaleSpinup/ecs-api/secretsmanager"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
 // processRepositoryCredentialsCreate processes the Credentials portion of the input.  If the credentials are defined as input,
 // they are created in the secretsmanager service and the ARN is applied to the task definition as repository credentials.
-func (o *Orchestrator) processRepositoryCredentialsCreate(ctx context.Context, input *ServiceOrchestrationInput) (map[string]*secretsmanager.CreateSecretOutput, rollbackFunc, error) {
+// Imported credentials must be in the account and region of the cluster.
+func (o *Orchestrator) processRepositoryCredentialsCreate(ctx context.Context, input *ServiceOrchestrationInput, clu *ecs.Cluster) (map[string]*secretsmanager.CreateSecretOutput, rollbackFunc, error) {
 	rbfunc := defaultRbfunc("processRepositoryCredentialsCreate")
 
-	if len(input.Credentials) == 0 {
+	if len(input.Credentials) == 0 && len(input.ImportCredentials) == 0 {
 		log.Debugf("no private repository credentials passed")
 		return nil, rbfunc, nil
 	}
@@ -31,11 +40,26 @@ func (o *Orchestrator) processRepositoryCredentialsCreate(ctx context.Context, i
 	// prefix for secret names is 'spinup/org/clustername'
 	prefix := "spinup/" + o.Org + "/" + cluster
 
-	creds, err := o.createRepostitoryCredentials(ctx, prefix, input.Credentials, input.Tags)
+	// validate any imported credentials before creating new ones so there's nothing to roll back
+	imported, err := o.importRepositoryCredentials(ctx, clu, prefix, input.ImportCredentials, input.Credentials, input.TaskDefinition.ContainerDefinitions)
 	if err != nil {
 		return nil, rbfunc, err
 	}
 
+	created, err := o.createRepostitoryCredentials(ctx, prefix, input.Credentials, input.Tags)
+	if err != nil {
+		return nil, rbfunc, err
+	}
+
+	creds := make(map[string]*secretsmanager.CreateSecretOutput, len(created)+len(imported))
+	for containerName, secret := range created {
+		creds[containerName] = secret
+	}
+
+	for containerName, secret := range imported {
+		creds[containerName] = secret
+	}
+
 	for _, cd := range input.TaskDefinition.ContainerDefinitions {
 		containerName := aws.StringValue(cd.Name)
 		log.Debugf("processing container definition %s", containerName)
@@ -48,15 +72,18 @@ func (o *Orchestrator) processRepositoryCredentialsCreate(ctx context.Context, i
 		}
 	}
 
+	// only roll back the secrets we created, imported secrets are left alone
 	rbfunc = func(ctx context.Context) error {
-		for _, secret := range creds {
+		for _, secret := range created {
 			id := aws.StringValue(secret.ARN)
 
 			log.Debugf("rolling back secret %s", id)
 
-			out, err := o.SecretsManager.DeleteSecret(ctx, id, 0)
+			// the secret was created by this request, so it's deleted even if it was tagged as protected
+			out, err := o.SecretsManager.DeleteProtectedSecret(ctx, id, 0)
 			if err != nil {
 				log.Errorf("failed deleting secret %s: %s", id, err)
+				continue
 			}
 
 			log.Infof("successfully rolled back secret: %s", aws.StringValue(out.ARN))
@@ -72,10 +99,10 @@ func (o *Orchestrator) processRepositoryCredentialsCreate(ctx context.Context, i
 
 // processTaskDefRepositoryCredentialsCreate processes the Credentials portion of the input for a task.  If the credentials are defined
 // as input, they are created as secrets in the secretsmanager service and the ARN is applied to the task definition as repository credentials.
-func (o *Orchestrator) processTaskDefRepositoryCredentialsCreate(ctx context.Context, input *TaskDefCreateOrchestrationInput) (map[string]*secretsmanager.CreateSecretOutput, rollbackFunc, error) {
+func (o *Orchestrator) processTaskDefRepositoryCredentialsCreate(ctx context.Context, input *TaskDefCreateOrchestrationInput, clu *ecs.Cluster) (map[string]*secretsmanager.CreateSecretOutput, rollbackFunc, error) {
 	rbfunc := defaultRbfunc("processTaskRepositoryCredentialsCreate")
 
-	if len(input.Credentials) == 0 {
+	if len(input.Credentials) == 0 && len(input.ImportCredentials) == 0 {
 		log.Debugf("no private repository credentials passed")
 		return nil, rbfunc, nil
 	}
@@ -88,11 +115,26 @@ func (o *Orchestrator) processTaskDefRepositoryCredentialsCreate(ctx context.Con
 	// prefix for secret names is 'spinup/org/clustername'
 	prefix := "spinup/" + o.Org + "/" + cluster
 
-	creds, err := o.createRepostitoryCredentials(ctx, prefix, input.Credentials, input.Tags)
+	// validate any imported credentials before creating new ones so there's nothing to roll back
+	imported, err := o.importRepositoryCredentials(ctx, clu, prefix, input.ImportCredentials, input.Credentials, input.TaskDefinition.ContainerDefinitions)
 	if err != nil {
 		return nil, rbfunc, err
 	}
 
+	created, err := o.createRepostitoryCredentials(ctx, prefix, input.Credentials, input.Tags)
+	if err != nil {
+		return nil, rbfunc, err
+	}
+
+	creds := make(map[string]*secretsmanager.CreateSecretOutput, len(created)+len(imported))
+	for containerName, secret := range created {
+		creds[containerName] = secret
+	}
+
+	for containerName, secret := range imported {
+		creds[containerName] = secret
+	}
+
 	for _, cd := range input.TaskDefinition.ContainerDefinitions {
 		containerName := aws.StringValue(cd.Name)
 		log.Debugf("processing container definition %s", containerName)
@@ -105,15 +147,18 @@ func (o *Orchestrator) processTaskDefRepositoryCredentialsCreate(ctx context.Con
 		}
 	}
 
+	// only roll back the secrets we created, imported secrets are left alone
 	rbfunc = func(ctx context.Context) error {
-		for _, secret := range creds {
+		for _, secret := range created {
 			id := aws.StringValue(secret.ARN)
 
 			log.Debugf("rolling back secret %s", id)
 
-			out, err := o.SecretsManager.DeleteSecret(ctx, id, 0)
+			// the secret was created by this request, so it's deleted even if it was tagged as protected
+			out, err := o.SecretsManager.DeleteProtectedSecret(ctx, id, 0)
 			if err != nil {
 				log.Errorf("failed deleting secret %s: %s", id, err)
+				continue
 			}
 
 			log.Infof("successfully rolled back secret: %s", aws.StringValue(out.ARN))
@@ -251,6 +296,12 @@ func (o *Orchestrator) updateRepositoryCredentials(ctx context.Context, cluster
 	// inputCredentials is the new secret values passed to be created
 	log.Debugf("input credentials %+v", inputCredentials)
 
+	// validate all of the new secret values before changing anything, credentials migrated from
+	// existing secrets are used as-is
+	if err := o.validateRepositoryCredentials(inputCredentials); err != nil {
+		return nil, nil, err
+	}
+
 	creds := make(map[string]interface{}, len(inputCredentials))
 	markedForDeletion := []string{}
 	for _, cd := range inputContainerDefinitions {
@@ -333,7 +384,7 @@ func (o *Orchestrator) updateRepositoryCredentials(ctx context.Context, cluster
 
 			creds[containerName] = out
 		} else if hasInputCredential {
-			out, err := o.createNewRepositoryCredentials(ctx, prefix, inputCredential, tags)
+			out, err := o.createNewRepositoryCredentials(ctx, prefix, containerName, inputCredential, tags)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -353,7 +404,7 @@ func (o *Orchestrator) updateRepositoryCredentials(ctx context.Context, cluster
 	return creds, markedForDeletion, nil
 }
 
-func (o *Orchestrator) createNewRepositoryCredentials(ctx context.Context, prefix string, input *secretsmanager.CreateSecretInput, tags []*ecs.Tag) (*secretsmanager.CreateSecretOutput, error) {
+func (o *Orchestrator) createNewRepositoryCredentials(ctx context.Context, prefix, containerName string, input *secretsmanager.CreateSecretInput, tags []*ecs.Tag) (*secretsmanager.CreateSecretOutput, error) {
 	client := o.SecretsManager
 
 	name := prefix + aws.StringValue(input.Name)
@@ -361,6 +412,10 @@ func (o *Orchestrator) createNewRepositoryCredentials(ctx context.Context, prefi
 	log.Infof("creating new repository credentials secret: '%s'", name)
 
 	input.Name = aws.String(name)
+	if aws.StringValue(input.Description) == "" {
+		input.Description = aws.String(o.repositoryCredentialsDescription(prefix, containerName))
+	}
+	o.defaultRepositoryCredentialsKmsKey(input)
 
 	smTags := make([]*secretsmanager.Tag, len(tags))
 	for i, t := range tags {
@@ -398,9 +453,11 @@ func (o *Orchestrator) updateRepositoryCredentialsInPlace(ctx context.Context, a
 func (o *Orchestrator) purgeMarkedRepositoryCredentials(ctx context.Context, markedForDeletion []string) error {
 	client := o.SecretsManager
 
+	log.Infof("deleting secrets manager secrets %v (marked for deletion)", markedForDeletion)
+
+	errs := client.DeleteSecrets(ctx, markedForDeletion, 0)
 	for _, m := range markedForDeletion {
-		log.Infof("deleting secrets mamanger secret %s (marked for deletion)", m)
-		if _, err := client.DeleteSecret(ctx, m, 0); err != nil {
+		if err, ok := errs[m]; ok {
 			return err
 		}
 	}
@@ -408,30 +465,369 @@ func (o *Orchestrator) purgeMarkedRepositoryCredentials(ctx context.Context, mar
 	return nil
 }
 
-// createRepostitoryCredentials takes the map of container names to secret inputs and creates the given secrets in secretsmanager with the prefix
+// createRepostitoryCredentials takes the map of container names to secret inputs and creates the given secrets in secretsmanager with the prefix.
+// Secrets are created concurrently, up to DefaultRepositoryCredentialsConcurrency at a time.  If any of the secrets fail to be created, the
+// secrets that were created are deleted and the error for the first failed container (by name) is returned.
 func (o *Orchestrator) createRepostitoryCredentials(ctx context.Context, prefix string, input map[string]*secretsmanager.CreateSecretInput, tags []*Tag) (map[string]*secretsmanager.CreateSecretOutput, error) {
 	log.Debugf("creating repository credentials with prefix %s: %+v", prefix, input)
 
-	creds := make(map[string]*secretsmanager.CreateSecretOutput, len(input))
+	if !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+
+	if err := o.validateRepositoryCredentials(input); err != nil {
+		return nil, err
+	}
 
-	for containerName, secretInput := range input {
-		log.Infof("creating repository credentials secret for %s", containerName)
+	containerNames := make([]string, 0, len(input))
+	for containerName := range input {
+		containerNames = append(containerNames, containerName)
+	}
+	sort.Strings(containerNames)
 
+	type result struct {
+		out *secretsmanager.CreateSecretOutput
+		err error
+	}
+
+	results := make([]result, len(containerNames))
+	sem := make(chan struct{}, DefaultRepositoryCredentialsConcurrency)
+	wg := sync.WaitGroup{}
+	for i, containerName := range containerNames {
+		secretInput := input[containerName]
 		secretInput.Tags = secretsmanagerTags(tags)
+		secretInput.Name = aws.String(prefix + aws.StringValue(secretInput.Name))
+		if aws.StringValue(secretInput.Description) == "" {
+			secretInput.Description = aws.String(o.repositoryCredentialsDescription(prefix, containerName))
+		}
+		o.defaultRepositoryCredentialsKmsKey(secretInput)
+
+		wg.Add(1)
+		go func(i int, containerName string, secretInput *secretsmanager.CreateSecretInput) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-		if !strings.HasSuffix(prefix, "/") {
-			prefix = prefix + "/"
+			log.Infof("creating repository credentials secret for %s", containerName)
+
+			out, err := o.createRepositoryCredentialsSecret(ctx, containerName, secretInput)
+			if err == nil {
+				err = o.replicateRepositoryCredentialsSecret(ctx, containerName, out)
+			}
+			results[i] = result{out: out, err: err}
+		}(i, containerName, secretInput)
+	}
+	wg.Wait()
+
+	creds := make(map[string]*secretsmanager.CreateSecretOutput, len(input))
+	var failed error
+	for i, containerName := range containerNames {
+		if err := results[i].err; err != nil {
+			log.Errorf("failed to create repository credentials secret for %s: %s", containerName, err)
+			if failed == nil {
+				failed = err
+			}
+			continue
 		}
 
-		secretInput.Name = aws.String(prefix + aws.StringValue(secretInput.Name))
+		creds[containerName] = results[i].out
+	}
+
+	if failed == nil {
+		return creds, nil
+	}
+
+	// the caller doesn't get a rollback function when creation fails, so clean up the secrets that were created
+	for _, containerName := range containerNames {
+		secret, ok := creds[containerName]
+		if !ok {
+			continue
+		}
+
+		id := aws.StringValue(secret.ARN)
+		log.Debugf("cleaning up repository credentials secret %s for %s", id, containerName)
+
+		if _, err := o.SecretsManager.DeleteProtectedSecret(ctx, id, 0); err != nil {
+			log.Errorf("failed to clean up repository credentials secret %s: %s", id, err)
+		}
+	}
 
-		out, err := o.SecretsManager.CreateSecret(ctx, secretInput)
+	return nil, failed
+}
+
+// createRepositoryCredentialsSecret creates the repository credentials secret for a container.  When a secret with the
+// same name already exists, a conflict is returned unless UniqueRepositoryCredentialsNames is set, in which case the
+// secret is created again with a unique suffix appended to the name.
+func (o *Orchestrator) createRepositoryCredentialsSecret(ctx context.Context, containerName string, input *secretsmanager.CreateSecretInput) (*secretsmanager.CreateSecretOutput, error) {
+	out, err := o.SecretsManager.CreateSecret(ctx, input)
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrConflict {
+		return out, err
+	}
+
+	name := aws.StringValue(input.Name)
+	if !o.UniqueRepositoryCredentialsNames {
+		msg := fmt.Sprintf("repository credentials secret %s for container %s already exists", name, containerName)
+		return nil, apierror.New(apierror.ErrConflict, msg, err)
+	}
+
+	input.Name = aws.String(uniqueSecretName(name))
+
+	log.Warnf("repository credentials secret %s for container %s already exists, creating %s", name, containerName, aws.StringValue(input.Name))
+
+	return o.SecretsManager.CreateSecret(ctx, input)
+}
+
+// replicateRepositoryCredentialsSecret replicates a new repository credentials secret to the configured replica regions
+// of the account.  If the replication can't be requested, the secret is deleted since it's not part of the creation
+// results that are cleaned up.
+func (o *Orchestrator) replicateRepositoryCredentialsSecret(ctx context.Context, containerName string, secret *secretsmanager.CreateSecretOutput) error {
+	regions := o.SecretsManager.ReplicaRegions
+	if len(regions) == 0 {
+		return nil
+	}
+
+	id := aws.StringValue(secret.ARN)
+
+	log.Infof("replicating repository credentials secret %s for %s to %s", id, containerName, strings.Join(regions, ", "))
+
+	if _, err := o.SecretsManager.ReplicateSecret(ctx, id, regions, o.SecretsManager.ReplicaKmsKeyIds); err != nil {
+		if _, derr := o.SecretsManager.DeleteProtectedSecret(ctx, id, 0); derr != nil {
+			log.Errorf("failed to clean up repository credentials secret %s: %s", id, derr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// uniqueSecretName appends a short random suffix to a secret name
+func uniqueSecretName(name string) string {
+	return name + "-" + strings.ReplaceAll(uuid.New().String(), "-", "")[:8]
+}
+
+// validateRepositoryCredentials makes sure the KMS key passed with each of the repository credentials is a valid key id, key ARN,
+// alias name or alias ARN, and that the secret string is the docker registry credentials JSON (ie. {"username": "foo", "password": "bar"})
+// when StrictRepositoryCredentials is set
+func (o *Orchestrator) validateRepositoryCredentials(input map[string]*secretsmanager.CreateSecretInput) error {
+	containerNames := make([]string, 0, len(input))
+	for containerName := range input {
+		containerNames = append(containerNames, containerName)
+	}
+	sort.Strings(containerNames)
+
+	for _, containerName := range containerNames {
+		if input[containerName] == nil || input[containerName].KmsKeyId == nil {
+			continue
+		}
+
+		if err := validateKmsKeyId(aws.StringValue(input[containerName].KmsKeyId)); err != nil {
+			msg := fmt.Sprintf("invalid kms key for the repository credentials of container %s: %s", containerName, err)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	if !o.StrictRepositoryCredentials {
+		return nil
+	}
+
+	for _, containerName := range containerNames {
+		if err := validateRegistryCredentials(input[containerName]); err != nil {
+			msg := fmt.Sprintf("invalid repository credentials for container %s: %s", containerName, err)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	return nil
+}
+
+// defaultRepositoryCredentialsKmsKey sets the KMS key of a repository credentials secret to the configured SecretKmsKeyId
+// when one isn't passed, otherwise the secret is encrypted with the aws/secretsmanager key of the account
+func (o *Orchestrator) defaultRepositoryCredentialsKmsKey(input *secretsmanager.CreateSecretInput) {
+	if aws.StringValue(input.KmsKeyId) == "" && o.SecretsManager.SecretKmsKeyId != "" {
+		input.KmsKeyId = aws.String(o.SecretsManager.SecretKmsKeyId)
+	}
+}
+
+// repositoryCredentialsKmsKeyArns resolves the kms keys encrypting the repository credentials secrets (the configured
+// SecretKmsKeyId and any passed with the credentials) to key ARNs, so the task execution role can be granted decrypt.  Secrets
+// encrypted with the aws/secretsmanager key don't need a grant.
+func (o *Orchestrator) repositoryCredentialsKmsKeyArns(ctx context.Context, credentials map[string]*secretsmanager.CreateSecretInput) ([]string, error) {
+	ids := []string{}
+	if o.SecretsManager.SecretKmsKeyId != "" {
+		ids = append(ids, o.SecretsManager.SecretKmsKeyId)
+	}
+
+	for _, c := range credentials {
+		if c != nil && aws.StringValue(c.KmsKeyId) != "" {
+			ids = append(ids, aws.StringValue(c.KmsKeyId))
+		}
+	}
+
+	keyArns := []string{}
+	for _, id := range uniqueSortedStrings(ids) {
+		keyArn, err := o.KMS.KeyArn(ctx, id)
 		if err != nil {
-			log.Errorf("boom! %s", err)
 			return nil, err
 		}
+		keyArns = append(keyArns, keyArn)
+	}
+
+	return uniqueSortedStrings(keyArns), nil
+}
+
+// kmsKeyIdPattern matches KMS key ids, including multi-region keys
+var kmsKeyIdPattern = regexp.MustCompile(`^([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|mrk-[0-9a-f]{32})$`)
+
+// kmsAliasPattern matches KMS alias names
+var kmsAliasPattern = regexp.MustCompile(`^alias/[a-zA-Z0-9/_-]{1,250}$`)
+
+// validateKmsKeyId checks that the KMS key is a key id, key ARN, alias name or alias ARN
+func validateKmsKeyId(id string) error {
+	resource := id
+	if strings.HasPrefix(id, "arn:") {
+		a, err := arn.Parse(id)
+		if err != nil {
+			return err
+		}
+
+		if a.Service != "kms" || a.Region == "" || a.AccountID == "" {
+			return fmt.Errorf("'%s' is not a kms key or alias arn", id)
+		}
+
+		if strings.HasPrefix(a.Resource, "alias/") {
+			resource = a.Resource
+		} else if strings.HasPrefix(a.Resource, "key/") {
+			resource = strings.TrimPrefix(a.Resource, "key/")
+		} else {
+			return fmt.Errorf("'%s' is not a kms key or alias arn", id)
+		}
+	}
+
+	if strings.HasPrefix(resource, "alias/") {
+		if !kmsAliasPattern.MatchString(resource) {
+			return fmt.Errorf("'%s' is not a valid kms alias", id)
+		}
+		return nil
+	}
+
+	if !kmsKeyIdPattern.MatchString(resource) {
+		return fmt.Errorf("'%s' is not a valid kms key id", id)
+	}
+
+	return nil
+}
+
+// validateRegistryCredentials checks that the secret string is a JSON object with non-empty username and password
+// strings.  The JSON parsing error isn't returned since it can include part of the secret.
+func validateRegistryCredentials(input *secretsmanager.CreateSecretInput) error {
+	if input == nil || input.SecretString == nil {
+		return errors.New("secret string is required")
+	}
 
-		creds[containerName] = out
+	var creds map[string]interface{}
+	if err := json.Unmarshal([]byte(aws.StringValue(input.SecretString)), &creds); err != nil {
+		return errors.New("secret string is not a JSON object")
+	}
+
+	for _, k := range []string{"username", "password"} {
+		if v, ok := creds[k].(string); !ok || v == "" {
+			return fmt.Errorf("secret string is missing the %s", k)
+		}
+	}
+
+	return nil
+}
+
+// repositoryCredentialsDescription returns the default description for a repository credentials secret
+// created under the prefix (spinup/org/clustername/) for the given container, ie. "repository credentials for clustername/container"
+func (o *Orchestrator) repositoryCredentialsDescription(prefix, containerName string) string {
+	cluster := strings.TrimPrefix(prefix, "spinup/")
+	if o.Org != "" {
+		cluster = strings.TrimPrefix(cluster, o.Org+"/")
+	}
+	cluster = strings.Trim(cluster, "/")
+
+	if cluster == "" {
+		return "repository credentials for " + containerName
+	}
+
+	return "repository credentials for " + cluster + "/" + containerName
+}
+
+// importRepositoryCredentials validates the map of container names to existing secretsmanager secret ARNs and returns them
+// in the same form as newly created repository credentials.  The secrets must live under the repository credentials prefix
+// (spinup/org/clustername/) in the account and region of the cluster and a container cannot both import and create credentials.
+func (o *Orchestrator) importRepositoryCredentials(ctx context.Context, cluster *ecs.Cluster, prefix string, input map[string]string, newCredentials map[string]*secretsmanager.CreateSecretInput, containerDefinitions []*ecs.ContainerDefinition) (map[string]*secretsmanager.CreateSecretOutput, error) {
+	creds := make(map[string]*secretsmanager.CreateSecretOutput, len(input))
+	if len(input) == 0 {
+		return creds, nil
+	}
+
+	if !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+
+	if cluster == nil {
+		return nil, apierror.New(apierror.ErrInternalError, "cluster is required to import credentials", nil)
+	}
+
+	// the cluster arn determines the account and region the secrets must be in
+	clusterArn, err := arn.Parse(aws.StringValue(cluster.ClusterArn))
+	if err != nil {
+		return nil, apierror.New(apierror.ErrInternalError, "failed to determine the account and region of the cluster", err)
+	}
+
+	containers := make(map[string]*ecs.ContainerDefinition, len(containerDefinitions))
+	for _, cd := range containerDefinitions {
+		containers[aws.StringValue(cd.Name)] = cd
+	}
+
+	for containerName, secretArn := range input {
+		log.Infof("importing existing repository credentials secret %s for %s", secretArn, containerName)
+
+		cd, ok := containers[containerName]
+		if !ok {
+			msg := fmt.Sprintf("imported credentials container definition %s not found", containerName)
+			return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if _, ok := newCredentials[containerName]; ok {
+			msg := fmt.Sprintf("container definition %s cannot both import and create credentials", containerName)
+			return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		parsedArn, err := arn.Parse(secretArn)
+		if err != nil || parsedArn.Service != "secretsmanager" {
+			msg := fmt.Sprintf("invalid secretsmanager secret arn %s for container definition %s", secretArn, containerName)
+			return nil, apierror.New(apierror.ErrBadRequest, msg, err)
+		}
+
+		if parsedArn.AccountID != clusterArn.AccountID || parsedArn.Region != clusterArn.Region {
+			msg := fmt.Sprintf("imported credentials %s for container definition %s must be in account %s and region %s", secretArn, containerName, clusterArn.AccountID, clusterArn.Region)
+			return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if !strings.HasPrefix(parsedArn.Resource, "secret:"+prefix) {
+			msg := fmt.Sprintf("imported credentials %s for container definition %s must be under %s", secretArn, containerName, prefix)
+			return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		// ensure the secret exists and isn't scheduled for deletion
+		secret, err := o.SecretsManager.GetSecretMetaDataWithFilter(ctx, secretArn, func(out *secretsmanager.DescribeSecretOutput) bool {
+			return out.DeletedDate == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		log.Infof("setting repository credentials secret for container definition: %s to %s", containerName, aws.StringValue(secret.ARN))
+		cd.SetRepositoryCredentials(&ecs.RepositoryCredentials{CredentialsParameter: secret.ARN})
+
+		creds[containerName] = &secretsmanager.CreateSecretOutput{
+			ARN:  secret.ARN,
+			Name: secret.Name,
+		}
 	}
 
 	return creds, nil
@@ -449,3 +845,283 @@ func containterDefinitionCredsMap(containerDefinitions []*ecs.ContainerDefinitio
 	}
 	return creds
 }
+
+// CredentialsKmsKeyOutput is the outcome of re-encrypting the repository credentials secret used by a container
+type CredentialsKmsKeyOutput struct {
+	Secret string
+	// Updated is set when the secret was re-encrypted with the key
+	Updated bool
+	// Error is the reason the secret wasn't re-encrypted
+	Error string `json:",omitempty"`
+}
+
+// UpdateServiceCredentialsKmsKey re-encrypts the repository credentials secrets used by the containers in the
+// active task definition of a service with the given KMS key.  The default task execution role of the cluster is
+// granted decrypt with the key first, so the images can still be pulled.  Each secret is re-encrypted even if
+// another one fails, the outcome is returned by container name.
+func (o *Orchestrator) UpdateServiceCredentialsKmsKey(ctx context.Context, cluster, service, kmsKeyId string) (map[string]*CredentialsKmsKeyOutput, error) {
+	if kmsKeyId == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "kms key id is required", nil)
+	}
+
+	if err := validateKmsKeyId(kmsKeyId); err != nil {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid kms key id: "+err.Error(), nil)
+	}
+
+	svc, err := o.ECS.GetService(ctx, cluster, service)
+	if err != nil {
+		return nil, err
+	}
+
+	tdef, _, err := o.ECS.GetTaskDefinition(ctx, svc.TaskDefinition, false)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := containterDefinitionCredsMap(tdef.ContainerDefinitions)
+	if len(creds) == 0 {
+		return map[string]*CredentialsKmsKeyOutput{}, nil
+	}
+
+	keyArn, err := o.KMS.KeyArn(ctx, kmsKeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.grantTaskExecutionKmsKey(ctx, cluster, aws.StringValue(tdef.ExecutionRoleArn), keyArn); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(creds))
+	for name := range creds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// containers can share a secret, only re-encrypt each secret once
+	updated := map[string]error{}
+	output := map[string]*CredentialsKmsKeyOutput{}
+	for _, name := range names {
+		credsArn := creds[name]
+
+		err, ok := updated[credsArn]
+		if !ok {
+			log.Infof("updating kms key for repository credentials of container %s in service %s/%s", name, cluster, service)
+
+			_, err = o.SecretsManager.UpdateSecretKmsKey(ctx, credsArn, kmsKeyId)
+			if err != nil {
+				log.Errorf("failed to update kms key for repository credentials %s: %s", credsArn, err)
+			}
+			updated[credsArn] = err
+		}
+
+		out := &CredentialsKmsKeyOutput{Secret: credsArn, Updated: err == nil}
+		if err != nil {
+			out.Error = err.Error()
+		}
+		output[name] = out
+	}
+
+	return output, nil
+}
+
+// grantTaskExecutionKmsKey grants the default task execution role of the cluster decrypt with the kms key.  Other
+// execution roles aren't managed by the api, they have to be granted decrypt by the operator.
+func (o *Orchestrator) grantTaskExecutionKmsKey(ctx context.Context, cluster, roleArn, keyArn string) error {
+	roleName := fmt.Sprintf("%s-ecsTaskExecution", cluster)
+	if roleArn == "" || !strings.HasSuffix(roleArn, "/"+roleName) {
+		log.Warnf("task execution role '%s' isn't the default role for cluster %s, not granting decrypt with %s", roleArn, cluster, keyArn)
+		return nil
+	}
+
+	path := fmt.Sprintf("%s/%s", o.Org, cluster)
+	if _, err := o.DefaultTaskExecutionRole(ctx, path, roleName, nil, nil, []string{keyArn}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CredentialsRotationStatus is the rotation status of the repository credentials secret used by a container
+type CredentialsRotationStatus struct {
+	Secret string
+	*sm.RotationStatus
+}
+
+// ServiceCredentialsRotationStatus gets the rotation status of the repository credentials secrets used by the containers in the
+// active task definition of a service.  It returns the rotation status by container name.
+func (o *Orchestrator) ServiceCredentialsRotationStatus(ctx context.Context, cluster, service string) (map[string]*CredentialsRotationStatus, error) {
+	svc, err := o.ECS.GetService(ctx, cluster, service)
+	if err != nil {
+		return nil, err
+	}
+
+	tdef, _, err := o.ECS.GetTaskDefinition(ctx, svc.TaskDefinition, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// containers can share a secret, only get the rotation status of each secret once
+	statuses := map[string]*sm.RotationStatus{}
+	output := map[string]*CredentialsRotationStatus{}
+	for name, credsArn := range containterDefinitionCredsMap(tdef.ContainerDefinitions) {
+		status, ok := statuses[credsArn]
+		if !ok {
+			log.Infof("getting rotation status for repository credentials of container %s in service %s/%s", name, cluster, service)
+
+			status, err = o.SecretsManager.GetRotationStatus(ctx, credsArn)
+			if err != nil {
+				return nil, err
+			}
+			statuses[credsArn] = status
+		}
+
+		output[name] = &CredentialsRotationStatus{
+			Secret:         credsArn,
+			RotationStatus: status,
+		}
+	}
+
+	return output, nil
+}
+
+// OrphanedCredentialsOutput is the list of secrets under the org prefix that aren't referenced by a task definition
+type OrphanedCredentialsOutput struct {
+	Orphaned []string
+	// Deleted is the list of orphaned secrets scheduled for deletion
+	Deleted []string `json:",omitempty"`
+	// Failed maps the orphaned secrets that failed to delete to the error
+	Failed map[string]string `json:",omitempty"`
+}
+
+// OrphanedCredentials finds the secrets under the org prefix (spinup/org/) that aren't referenced by any revision (active or
+// inactive) of the task definitions in the org or by the task definitions of the services running in the org clusters, either
+// as repository credentials or container secrets.  Secrets already scheduled for deletion are ignored.  If deleteOrphans is
+// true, the orphaned secrets are scheduled for deletion with the default recovery window (OrphanedCredentialsRecoveryWindow).
+func (o *Orchestrator) OrphanedCredentials(ctx context.Context, deleteOrphans bool) (*OrphanedCredentialsOutput, error) {
+	prefix := "spinup/" + o.Org + "/"
+
+	log.Infof("finding orphaned secrets under %s", prefix)
+
+	secrets, err := o.SecretsManager.ListSecretsWithFilter(ctx, func(s *secretsmanager.SecretListEntry) bool {
+		return strings.HasPrefix(aws.StringValue(s.Name), prefix) && s.DeletedDate == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	referenced, err := o.referencedSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &OrphanedCredentialsOutput{Orphaned: []string{}}
+	for _, s := range secrets {
+		if _, ok := referenced[aws.StringValue(s)]; !ok {
+			output.Orphaned = append(output.Orphaned, aws.StringValue(s))
+		}
+	}
+	sort.Strings(output.Orphaned)
+
+	log.Infof("found %d orphaned secrets of %d under %s", len(output.Orphaned), len(secrets), prefix)
+
+	if !deleteOrphans || len(output.Orphaned) == 0 {
+		return output, nil
+	}
+
+	errs := o.SecretsManager.DeleteSecrets(ctx, output.Orphaned, OrphanedCredentialsRecoveryWindow)
+	for _, s := range output.Orphaned {
+		if err, ok := errs[s]; ok {
+			if output.Failed == nil {
+				output.Failed = map[string]string{}
+			}
+			output.Failed[s] = err.Error()
+			continue
+		}
+
+		output.Deleted = append(output.Deleted, s)
+	}
+
+	return output, nil
+}
+
+// referencedSecrets returns the set of secretsmanager secret ARNs referenced by the active and inactive revisions of all of
+// the task definition families in the org and by the task definitions of the services in the org clusters.  A service can
+// still run a revision that was deregistered, or a family that isn't tagged with the org.  Any failure is returned since a
+// partial set would report referenced secrets as orphaned.  The references of each revision are cached in SecretReferences,
+// so only the revisions registered since the last scan are described.
+func (o *Orchestrator) referencedSecrets(ctx context.Context) (map[string]struct{}, error) {
+	families, err := o.taskDefinitionFamilies(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	taskDefinitions := map[string]struct{}{}
+	for _, family := range families {
+		listed := map[string]struct{}{}
+		for _, status := range []string{"ACTIVE", "INACTIVE"} {
+			revisions, err := o.ECS.ListTaskDefinitionRevisionsWithStatus(ctx, aws.String(family), status)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, revision := range revisions {
+				listed[revision] = struct{}{}
+				taskDefinitions[revision] = struct{}{}
+			}
+		}
+		o.SecretReferences.prune(listed)
+	}
+
+	clusters, err := o.ListClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cluster := range clusters {
+		running, err := o.ECS.ListServiceTaskDefinitions(ctx, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, td := range running {
+			taskDefinitions[td] = struct{}{}
+		}
+	}
+
+	referenced := map[string]struct{}{}
+	described := 0
+	for revision := range taskDefinitions {
+		refs, ok := o.SecretReferences.get(revision)
+		if !ok {
+			td, _, err := o.ECS.GetTaskDefinition(ctx, aws.String(revision), false)
+			if err != nil {
+				return nil, err
+			}
+			described++
+
+			refs = []string{}
+			for _, cd := range td.ContainerDefinitions {
+				if cd.RepositoryCredentials != nil && cd.RepositoryCredentials.CredentialsParameter != nil {
+					refs = append(refs, aws.StringValue(cd.RepositoryCredentials.CredentialsParameter))
+				}
+
+				for _, s := range cd.Secrets {
+					if ref, err := parseSecretsManagerValueFrom(aws.StringValue(s.ValueFrom)); err == nil {
+						refs = append(refs, ref.arn)
+					}
+				}
+			}
+
+			o.SecretReferences.set(revision, refs)
+		}
+
+		for _, r := range refs {
+			referenced[r] = struct{}{}
+		}
+	}
+
+	log.Debugf("found %d secrets referenced by %d task definitions (%d described) of %d families and %d clusters", len(referenced), len(taskDefinitions), described, len(families), len(clusters))
+
+	return referenced, nil
+}
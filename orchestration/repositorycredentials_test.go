@@ -2,21 +2,52 @@ package orchestration
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/YaleSpinup/apierror"
+	yiam "github.com/YaleSpinup/aws-go/services/iam"
 	sm "github.com/YaleSpinup/ecs-api/secretsmanager"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/awsutil"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 )
 
+// testSecretKmsKeyArn is the ARN of the kms key encrypting the repository credentials secrets
+const testSecretKmsKeyArn = "arn:aws:kms:us-east-1:012345678901:key/12121212-3333-4444-5555-676767676767"
+
+// testKmsKeys maps the kms key ids and aliases known to the mock to the key ARN
+var testKmsKeys = map[string]string{
+	"alias/spinup-mock-secrets":            testSecretKmsKeyArn,
+	"12121212-3333-4444-5555-676767676767": testSecretKmsKeyArn,
+	testSecretKmsKeyArn:                    testSecretKmsKeyArn,
+	"alias/other":                          "arn:aws:kms:us-east-1:012345678901:key/abababab-3333-4444-5555-676767676767",
+}
+
+func (m *mockKMSClient) DescribeKeyWithContext(ctx aws.Context, input *kms.DescribeKeyInput, opts ...request.Option) (*kms.DescribeKeyOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	a, ok := testKmsKeys[aws.StringValue(input.KeyId)]
+	if !ok {
+		return nil, awserr.New(kms.ErrCodeNotFoundException, "key not found", nil)
+	}
+
+	return &kms.DescribeKeyOutput{KeyMetadata: &kms.KeyMetadata{Arn: aws.String(a)}}, nil
+}
+
 var (
 	goodContainerDefs = []*ecs.ContainerDefinition{
 		{
@@ -59,6 +90,23 @@ func (m *mockSMClient) CreateSecretWithContext(ctx context.Context, input *secre
 		return nil, awserr.New(secretsmanager.ErrCodeInvalidRequestException, "secret string OR secretbinary is required", nil)
 	}
 
+	if strings.HasSuffix(aws.StringValue(input.Name), "-fail") {
+		return nil, awserr.New(secretsmanager.ErrCodeLimitExceededException, "failed creating "+aws.StringValue(input.Name), nil)
+	}
+
+	if strings.HasSuffix(aws.StringValue(input.Name), "-exists") {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceExistsException, "the secret "+aws.StringValue(input.Name)+" already exists", nil)
+	}
+
+	if input.KmsKeyId != nil {
+		m.mu.Lock()
+		if m.kmsKeyIds == nil {
+			m.kmsKeyIds = map[string]string{}
+		}
+		m.kmsKeyIds[aws.StringValue(input.Name)] = aws.StringValue(input.KmsKeyId)
+		m.mu.Unlock()
+	}
+
 	arn := fmt.Sprintf("arn:aws:secretsmanager:us-east-1:12345678910:secret:%s", aws.StringValue(input.Name))
 	return &secretsmanager.CreateSecretOutput{
 		ARN:       aws.String(arn),
@@ -96,7 +144,7 @@ func TestProcessRepositoryCredentialsCreate(t *testing.T) {
 		SecretsManager: sm.SecretsManager{Service: &mockSMClient{t: t}},
 		Org:            "mock",
 	}
-	out, _, err := o.processRepositoryCredentialsCreate(context.TODO(), &ServiceOrchestrationInput{})
+	out, _, err := o.processRepositoryCredentialsCreate(context.TODO(), &ServiceOrchestrationInput{}, nil)
 	if err != nil {
 		t.Errorf("expected nil error for processRepositoryCredentials, got %s", err)
 	}
@@ -110,7 +158,7 @@ func TestProcessRepositoryCredentialsCreate(t *testing.T) {
 		TaskDefinition: tdInput,
 		Credentials:    credentialsMapIn,
 		Service:        svcInput,
-	})
+	}, &ecs.Cluster{ClusterArn: aws.String("arn:aws:ecs:us-east-1:12345678910:cluster/getAClu1")})
 	if err != nil {
 		t.Errorf("expected nil error for processRepositoryCredentials, got %s", err)
 	}
@@ -141,6 +189,60 @@ func TestProcessRepositoryCredentialsCreate(t *testing.T) {
 
 }
 
+func TestProcessTaskDefRepositoryCredentialsCreateRollback(t *testing.T) {
+	protected := []*secretsmanager.Tag{{Key: aws.String(sm.ProtectedTagKey), Value: aws.String("true")}}
+	created := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/getAClu1/container1"
+
+	tests := []struct {
+		name        string
+		secrets     []*secretsmanager.SecretListEntry
+		failures    map[string]int
+		wantDeleted []string
+	}{
+		{
+			name:        "protected secrets created by the request are rolled back",
+			secrets:     []*secretsmanager.SecretListEntry{{ARN: aws.String(created), Name: aws.String("spinup/mock/getAClu1/container1")}},
+			wantDeleted: []string{created},
+		},
+		{
+			name:     "failed rollback",
+			secrets:  []*secretsmanager.SecretListEntry{{ARN: aws.String(created), Name: aws.String("spinup/mock/getAClu1/container1")}},
+			failures: map[string]int{created: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockSMClient{t: t, secrets: tt.secrets, deleteFailures: tt.failures, tags: map[string][]*secretsmanager.Tag{created: protected}}
+			o := Orchestrator{
+				SecretsManager: sm.SecretsManager{Service: m},
+				Org:            "mock",
+			}
+
+			_, rbfunc, err := o.processTaskDefRepositoryCredentialsCreate(context.TODO(), &TaskDefCreateOrchestrationInput{
+				Cluster: &ecs.CreateClusterInput{ClusterName: aws.String("getAClu1")},
+				TaskDefinition: &ecs.RegisterTaskDefinitionInput{
+					ContainerDefinitions: []*ecs.ContainerDefinition{{Name: aws.String("container1"), Image: aws.String("secretImage1")}},
+				},
+				Credentials: map[string]*secretsmanager.CreateSecretInput{
+					"container1": {Name: aws.String("container1"), SecretString: aws.String("shhhhhhh")},
+				},
+				Tags: []*Tag{{Key: aws.String(sm.ProtectedTagKey), Value: aws.String("true")}},
+			}, nil)
+			if err != nil {
+				t.Fatalf("expected nil error creating repository credentials, got %s", err)
+			}
+
+			if err := rbfunc(context.TODO()); err != nil {
+				t.Errorf("expected nil error rolling back repository credentials, got %s", err)
+			}
+
+			if !reflect.DeepEqual(m.deleted, tt.wantDeleted) {
+				t.Errorf("expected deleted secrets %v, got %v", tt.wantDeleted, m.deleted)
+			}
+		})
+	}
+}
+
 func TestProcessTaskDefRepositoryCredentialsCreate(t *testing.T) {
 	credentialsMapIn := map[string]*secretsmanager.CreateSecretInput{
 		"container1": {
@@ -170,7 +272,7 @@ func TestProcessTaskDefRepositoryCredentialsCreate(t *testing.T) {
 		SecretsManager: sm.SecretsManager{Service: &mockSMClient{t: t}},
 		Org:            "mock",
 	}
-	out, _, err := o.processTaskDefRepositoryCredentialsCreate(context.TODO(), &TaskDefCreateOrchestrationInput{})
+	out, _, err := o.processTaskDefRepositoryCredentialsCreate(context.TODO(), &TaskDefCreateOrchestrationInput{}, nil)
 	if err != nil {
 		t.Errorf("expected nil error for processTaskDefRepositoryCredentialsCreate, got %s", err)
 	}
@@ -183,7 +285,7 @@ func TestProcessTaskDefRepositoryCredentialsCreate(t *testing.T) {
 		Cluster:        &ecs.CreateClusterInput{ClusterName: aws.String("getAClu1")},
 		TaskDefinition: tdInput,
 		Credentials:    credentialsMapIn,
-	})
+	}, &ecs.Cluster{ClusterArn: aws.String("arn:aws:ecs:us-east-1:12345678910:cluster/getAClu1")})
 	if err != nil {
 		t.Errorf("expected nil error for processTaskDefRepositoryCredentialsCreate, got %s", err)
 	}
@@ -282,6 +384,19 @@ func (m *mockSMClient) DeleteSecretWithContext(ctx context.Context, input *secre
 		return nil, awserr.New(secretsmanager.ErrCodeInvalidRequestException, "invalid input", nil)
 	}
 
+	m.mu.Lock()
+	if m.deleteFailures[aws.StringValue(input.SecretId)] > 0 {
+		m.deleteFailures[aws.StringValue(input.SecretId)]--
+		m.mu.Unlock()
+		return nil, awserr.New(secretsmanager.ErrCodeInternalServiceError, "internal error", nil)
+	}
+	m.deleted = append(m.deleted, aws.StringValue(input.SecretId))
+	if m.windows == nil {
+		m.windows = map[string]int64{}
+	}
+	m.windows[aws.StringValue(input.SecretId)] = aws.Int64Value(input.RecoveryWindowInDays)
+	m.mu.Unlock()
+
 	for _, secret := range testSecrets {
 		if aws.StringValue(input.SecretId) == secret.ARN {
 			return &secretsmanager.DeleteSecretOutput{
@@ -292,6 +407,16 @@ func (m *mockSMClient) DeleteSecretWithContext(ctx context.Context, input *secre
 		}
 	}
 
+	for _, secret := range m.secrets {
+		if aws.StringValue(input.SecretId) == aws.StringValue(secret.ARN) {
+			return &secretsmanager.DeleteSecretOutput{
+				ARN:          secret.ARN,
+				Name:         secret.Name,
+				DeletionDate: aws.Time(time.Now()),
+			}, nil
+		}
+	}
+
 	return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "secret doesn't exist", nil)
 }
 
@@ -917,7 +1042,7 @@ func TestProcessRepositoryCredentialsUpdate(t *testing.T) {
 	// error deleting secret
 	tests = append(tests, test{
 		inputerr: errors.New("boom"),
-		err:      errors.New("InternalError: failed to delete secret with id arn:aws:secretsmanager:us-east-1:12345678910:secret:test-cred-1 (boom)"),
+		err:      errors.New("InternalError: failed to describe secret with id arn:aws:secretsmanager:us-east-1:12345678910:secret:test-cred-1 (boom)"),
 		desc:     "error deleting secret with active repo creds AND NO input creds AND NO input repo creds",
 		tdinput: ServiceOrchestrationUpdateInput{
 			ClusterName: "testClu",
@@ -1153,3 +1278,1084 @@ func TestOrchestrator_createRepostitoryCredentials(t *testing.T) {
 		})
 	}
 }
+
+func TestOrchestrator_createRepostitoryCredentialsConcurrently(t *testing.T) {
+	input := func(names ...string) map[string]*secretsmanager.CreateSecretInput {
+		in := map[string]*secretsmanager.CreateSecretInput{}
+		for _, n := range names {
+			in[n] = &secretsmanager.CreateSecretInput{
+				Name:         aws.String(n),
+				SecretString: aws.String("shhhhh"),
+			}
+		}
+		return in
+	}
+
+	t.Run("many containers", func(t *testing.T) {
+		m := &mockSMClient{t: t}
+		o := &Orchestrator{SecretsManager: sm.SecretsManager{Service: m}, Org: "mock"}
+
+		names := []string{}
+		for i := 0; i < 3*DefaultRepositoryCredentialsConcurrency; i++ {
+			names = append(names, fmt.Sprintf("container%02d", i))
+		}
+
+		got, err := o.createRepostitoryCredentials(context.TODO(), "spinup/mock/clu1", input(names...), nil)
+		if err != nil {
+			t.Fatalf("expected nil error, got %s", err)
+		}
+
+		if len(got) != len(names) {
+			t.Fatalf("expected %d secrets to be created, got %d", len(names), len(got))
+		}
+
+		for _, n := range names {
+			want := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/clu1/" + n
+			if out, ok := got[n]; !ok || aws.StringValue(out.ARN) != want {
+				t.Errorf("expected secret %s for %s, got %+v", want, n, out)
+			}
+		}
+
+		if len(m.deleted) != 0 {
+			t.Errorf("expected no secrets to be deleted, got %v", m.deleted)
+		}
+	})
+
+	t.Run("mid-batch failure", func(t *testing.T) {
+		m := &mockSMClient{t: t}
+		o := &Orchestrator{SecretsManager: sm.SecretsManager{Service: m}, Org: "mock"}
+
+		got, err := o.createRepostitoryCredentials(context.TODO(), "spinup/mock/clu1", input("container1", "container2", "container3-fail", "container4", "container5-fail", "container6"), nil)
+		if err == nil {
+			t.Fatalf("expected error, got nil with %+v", got)
+		}
+
+		if got != nil {
+			t.Errorf("expected nil output, got %+v", got)
+		}
+
+		// the error for the first failed container is always returned
+		aerr, ok := err.(apierror.Error)
+		if !ok {
+			t.Fatalf("expected apierror.Error, got %s", reflect.TypeOf(err).String())
+		}
+
+		if !strings.Contains(aerr.OrigErr.Error(), "spinup/mock/clu1/container3-fail") {
+			t.Errorf("expected error for container3-fail, got %s", aerr.OrigErr)
+		}
+
+		deleted := append([]string{}, m.deleted...)
+		sort.Strings(deleted)
+
+		want := []string{}
+		for _, n := range []string{"container1", "container2", "container4", "container6"} {
+			want = append(want, "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/clu1/"+n)
+		}
+
+		if !reflect.DeepEqual(deleted, want) {
+			t.Errorf("expected created secrets %v to be deleted, got %v", want, deleted)
+		}
+	})
+}
+
+func TestOrchestrator_createRepostitoryCredentialsDescription(t *testing.T) {
+	type args struct {
+		prefix string
+		input  map[string]*secretsmanager.CreateSecretInput
+	}
+	tests := []struct {
+		name string
+		args args
+		want map[string]string
+	}{
+		{
+			name: "preserves description",
+			args: args{
+				prefix: "spinup/mock/clu1",
+				input: map[string]*secretsmanager.CreateSecretInput{
+					"container1": {
+						Name:         aws.String("container1"),
+						Description:  aws.String("secret for container1"),
+						SecretString: aws.String("shhhhh"),
+					},
+				},
+			},
+			want: map[string]string{
+				"container1": "secret for container1",
+			},
+		},
+		{
+			name: "defaults missing and empty descriptions",
+			args: args{
+				prefix: "spinup/mock/clu1/",
+				input: map[string]*secretsmanager.CreateSecretInput{
+					"container1": {
+						Name:         aws.String("container1-creds"),
+						SecretString: aws.String("shhhhh"),
+					},
+					"container2": {
+						Name:         aws.String("container2-creds"),
+						Description:  aws.String(""),
+						SecretString: aws.String("shhhhh"),
+					},
+					"container3": {
+						Name:         aws.String("container3-creds"),
+						Description:  aws.String("secret for container3"),
+						SecretString: aws.String("shhhhh"),
+					},
+				},
+			},
+			want: map[string]string{
+				"container1": "repository credentials for clu1/container1",
+				"container2": "repository credentials for clu1/container2",
+				"container3": "secret for container3",
+			},
+		},
+		{
+			name: "defaults description without cluster",
+			args: args{
+				prefix: "spinup/mock/",
+				input: map[string]*secretsmanager.CreateSecretInput{
+					"container1": {
+						Name:         aws.String("container1"),
+						SecretString: aws.String("shhhhh"),
+					},
+				},
+			},
+			want: map[string]string{
+				"container1": "repository credentials for container1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Orchestrator{SecretsManager: sm.SecretsManager{Service: &mockSMClient{t: t}}, Org: "mock"}
+
+			if _, err := o.createRepostitoryCredentials(context.TODO(), tt.args.prefix, tt.args.input, nil); err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			got := map[string]string{}
+			for containerName, in := range tt.args.input {
+				got[containerName] = aws.StringValue(in.Description)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.createRepostitoryCredentials() descriptions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func (m *mockSMClient) DescribeSecretWithContext(ctx context.Context, input *secretsmanager.DescribeSecretInput, opts ...request.Option) (*secretsmanager.DescribeSecretOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	secrets := map[string]*secretsmanager.DescribeSecretOutput{
+		"arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/getAClu1/existing-AbCdEf": {
+			ARN:  aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/getAClu1/existing-AbCdEf"),
+			Name: aws.String("spinup/mock/getAClu1/existing"),
+		},
+		"arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/getAClu1/deleted-AbCdEf": {
+			ARN:         aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/getAClu1/deleted-AbCdEf"),
+			Name:        aws.String("spinup/mock/getAClu1/deleted"),
+			DeletedDate: aws.Time(time.Now()),
+		},
+	}
+
+	if s, ok := secrets[aws.StringValue(input.SecretId)]; ok {
+		return s, nil
+	}
+
+	if rules, ok := m.rotation[aws.StringValue(input.SecretId)]; ok {
+		return &secretsmanager.DescribeSecretOutput{
+			ARN:               input.SecretId,
+			LastRotatedDate:   aws.Time(testRotatedDate),
+			RotationEnabled:   aws.Bool(true),
+			RotationLambdaARN: aws.String("arn:aws:lambda:us-east-1:12345678910:function:rotate-creds"),
+			RotationRules:     rules,
+		}, nil
+	}
+
+	if tags, ok := m.tags[aws.StringValue(input.SecretId)]; ok {
+		return &secretsmanager.DescribeSecretOutput{ARN: input.SecretId, Tags: tags}, nil
+	}
+
+	return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "Secret not found", nil)
+}
+
+// testRotatedDate is the last rotated date of the rotating secrets described by the mock
+var testRotatedDate = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func (m *mockSMClient) UpdateSecretWithContext(ctx aws.Context, input *secretsmanager.UpdateSecretInput, opts ...request.Option) (*secretsmanager.UpdateSecretOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if strings.Contains(aws.StringValue(input.SecretId), "locked") {
+		return nil, awserr.New("AccessDeniedException", "denied", nil)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.rekeyed == nil {
+		m.rekeyed = map[string]string{}
+	}
+	m.rekeyed[aws.StringValue(input.SecretId)] = aws.StringValue(input.KmsKeyId)
+
+	return &secretsmanager.UpdateSecretOutput{ARN: input.SecretId}, nil
+}
+
+func TestOrchestrator_UpdateServiceCredentialsKmsKey(t *testing.T) {
+	rekeyArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/kmskeys/rekey-AbCdEf"
+	lockedArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/kmskeys/locked-AbCdEf"
+
+	o := newMockOrchestrator(t, "mock", nil, nil, nil, nil, nil, nil)
+	o.IAM.DefaultKmsKeyID = "123"
+
+	got, err := o.UpdateServiceCredentialsKmsKey(context.TODO(), "kmskeys", "rekey", "alias/other")
+	if err != nil {
+		t.Fatalf("Orchestrator.UpdateServiceCredentialsKmsKey() unexpected error = %v", err)
+	}
+
+	// the secret shared by the webserver and the worker is re-keyed once, the sidecar secret fails
+	// without stopping the others
+	if len(got) != 3 {
+		t.Fatalf("expected 3 outcomes, got %s", awsutil.Prettify(got))
+	}
+
+	for _, name := range []string{"webserver", "worker"} {
+		if got[name].Secret != rekeyArn || !got[name].Updated || got[name].Error != "" {
+			t.Errorf("expected the %s secret to be re-keyed, got %+v", name, got[name])
+		}
+	}
+
+	if got["sidecar"].Secret != lockedArn || got["sidecar"].Updated || got["sidecar"].Error == "" {
+		t.Errorf("expected the sidecar secret to fail with an error, got %+v", got["sidecar"])
+	}
+
+	if want := map[string]string{rekeyArn: "alias/other"}; !reflect.DeepEqual(o.SecretsManager.Service.(*mockSMClient).rekeyed, want) {
+		t.Errorf("expected re-keyed secrets %v, got %v", want, o.SecretsManager.Service.(*mockSMClient).rekeyed)
+	}
+
+	// the execution role keeps the key it was granted and is granted the new key
+	var policy yiam.PolicyDocument
+	if err := json.Unmarshal([]byte(o.IAM.Service.(*mockIAMClient).rolePolicy), &policy); err != nil {
+		t.Fatalf("failed to unmarshal put role policy: %s", err)
+	}
+
+	want := []string{testSecretKmsKeyArn, "arn:aws:kms:us-east-1:012345678901:key/abababab-3333-4444-5555-676767676767"}
+	if keys := policyKmsKeyArns(policy); !reflect.DeepEqual(keys, want) {
+		t.Errorf("expected the execution role to be granted %v, got %v", want, keys)
+	}
+
+	if _, err := o.UpdateServiceCredentialsKmsKey(context.TODO(), "kmskeys", "rekey", "alias/missing"); err == nil {
+		t.Error("expected an error for an unknown kms key, got nil")
+	}
+}
+
+func TestOrchestrator_ServiceCredentialsRotationStatus(t *testing.T) {
+	secretArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/creds-AbCdEf"
+	rules := &secretsmanager.RotationRulesType{ScheduleExpression: aws.String("rate(30 days)")}
+
+	tests := []struct {
+		name     string
+		service  string
+		rotation map[string]*secretsmanager.RotationRulesType
+		want     map[string]*CredentialsRotationStatus
+		wantErr  bool
+	}{
+		{
+			name:     "rotation enabled",
+			service:  "creds",
+			rotation: map[string]*secretsmanager.RotationRulesType{secretArn: rules},
+			want: map[string]*CredentialsRotationStatus{
+				"webserver": {
+					Secret: secretArn,
+					RotationStatus: &sm.RotationStatus{
+						RotationEnabled:   true,
+						RotationLambdaARN: "arn:aws:lambda:us-east-1:12345678910:function:rotate-creds",
+						RotationRules:     rules,
+						LastRotatedDate:   &testRotatedDate,
+					},
+				},
+				"worker": {
+					Secret: secretArn,
+					RotationStatus: &sm.RotationStatus{
+						RotationEnabled:   true,
+						RotationLambdaARN: "arn:aws:lambda:us-east-1:12345678910:function:rotate-creds",
+						RotationRules:     rules,
+						LastRotatedDate:   &testRotatedDate,
+					},
+				},
+			},
+		},
+		{
+			name:    "not rotating",
+			service: "creds",
+			want: map[string]*CredentialsRotationStatus{
+				"webserver": {Secret: secretArn, RotationStatus: &sm.RotationStatus{}},
+				"worker":    {Secret: secretArn, RotationStatus: &sm.RotationStatus{}},
+			},
+		},
+		{
+			name:    "service without credentials",
+			service: "logged",
+			want:    map[string]*CredentialsRotationStatus{},
+		},
+		{
+			name:    "missing service",
+			service: "missing",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "mock", nil, nil, nil, nil, nil, nil)
+			client := o.SecretsManager.Service.(*mockSMClient)
+			client.rotation = tt.rotation
+			client.tags = map[string][]*secretsmanager.Tag{
+				secretArn: {{Key: aws.String("spinup:org"), Value: aws.String("mock")}},
+			}
+
+			got, err := o.ServiceCredentialsRotationStatus(context.TODO(), "cluster1", tt.service)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.ServiceCredentialsRotationStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.ServiceCredentialsRotationStatus() = %s, want %s", awsutil.Prettify(got), awsutil.Prettify(tt.want))
+			}
+		})
+	}
+}
+
+func TestOrchestrator_importRepositoryCredentials(t *testing.T) {
+	existingArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/getAClu1/existing-AbCdEf"
+
+	tests := []struct {
+		name           string
+		input          map[string]string
+		newCredentials map[string]*secretsmanager.CreateSecretInput
+		want           map[string]*secretsmanager.CreateSecretOutput
+		wantErr        bool
+	}{
+		{
+			name:  "nothing to import",
+			input: map[string]string{},
+			want:  map[string]*secretsmanager.CreateSecretOutput{},
+		},
+		{
+			name:  "valid existing secret",
+			input: map[string]string{"container1": existingArn},
+			want: map[string]*secretsmanager.CreateSecretOutput{
+				"container1": {
+					ARN:  aws.String(existingArn),
+					Name: aws.String("spinup/mock/getAClu1/existing"),
+				},
+			},
+		},
+		{
+			name:    "non-org prefixed arn",
+			input:   map[string]string{"container1": "arn:aws:secretsmanager:us-east-1:12345678910:secret:someoneelse/existing-AbCdEf"},
+			wantErr: true,
+		},
+		{
+			name:    "other cluster prefixed arn",
+			input:   map[string]string{"container1": "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/otherClu/existing-AbCdEf"},
+			wantErr: true,
+		},
+		{
+			name:    "other account arn",
+			input:   map[string]string{"container1": "arn:aws:secretsmanager:us-east-1:10987654321:secret:spinup/mock/getAClu1/existing-AbCdEf"},
+			wantErr: true,
+		},
+		{
+			name:    "other region arn",
+			input:   map[string]string{"container1": "arn:aws:secretsmanager:us-west-2:12345678910:secret:spinup/mock/getAClu1/existing-AbCdEf"},
+			wantErr: true,
+		},
+		{
+			name:    "not a secretsmanager arn",
+			input:   map[string]string{"container1": "arn:aws:ssm:us-east-1:12345678910:parameter/spinup/mock/getAClu1/existing"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid arn",
+			input:   map[string]string{"container1": "spinup/mock/getAClu1/existing"},
+			wantErr: true,
+		},
+		{
+			name:    "missing secret",
+			input:   map[string]string{"container1": "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/getAClu1/missing-AbCdEf"},
+			wantErr: true,
+		},
+		{
+			name:    "secret scheduled for deletion",
+			input:   map[string]string{"container1": "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/getAClu1/deleted-AbCdEf"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown container definition",
+			input:   map[string]string{"container99": existingArn},
+			wantErr: true,
+		},
+		{
+			name:  "import and create for the same container",
+			input: map[string]string{"container1": existingArn},
+			newCredentials: map[string]*secretsmanager.CreateSecretInput{
+				"container1": {Name: aws.String("container1"), SecretString: aws.String("shhhhhhh")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := Orchestrator{
+				SecretsManager: sm.SecretsManager{Service: &mockSMClient{t: t}},
+				Org:            "mock",
+			}
+
+			containerDefinitions := []*ecs.ContainerDefinition{
+				{Name: aws.String("webserver"), Image: aws.String("nginx:alpine")},
+				{Name: aws.String("container1"), Image: aws.String("secretImage1")},
+			}
+
+			cluster := &ecs.Cluster{ClusterArn: aws.String("arn:aws:ecs:us-east-1:12345678910:cluster/getAClu1")}
+
+			got, err := o.importRepositoryCredentials(context.TODO(), cluster, "spinup/mock/getAClu1/", tt.input, tt.newCredentials, containerDefinitions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.importRepositoryCredentials() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.importRepositoryCredentials() = %s, want %s", awsutil.Prettify(got), awsutil.Prettify(tt.want))
+			}
+
+			for _, cd := range containerDefinitions {
+				secret, ok := tt.want[aws.StringValue(cd.Name)]
+				if !ok {
+					if cd.RepositoryCredentials != nil {
+						t.Errorf("expected no repository credentials for %s, got %+v", aws.StringValue(cd.Name), cd.RepositoryCredentials)
+					}
+					continue
+				}
+
+				if cd.RepositoryCredentials == nil || aws.StringValue(cd.RepositoryCredentials.CredentialsParameter) != aws.StringValue(secret.ARN) {
+					t.Errorf("expected repository credentials %s for %s, got %+v", aws.StringValue(secret.ARN), aws.StringValue(cd.Name), cd.RepositoryCredentials)
+				}
+			}
+		})
+	}
+}
+
+func (m *mockSMClient) ListSecretsWithContext(ctx context.Context, input *secretsmanager.ListSecretsInput, opts ...request.Option) (*secretsmanager.ListSecretsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &secretsmanager.ListSecretsOutput{SecretList: m.secrets}, nil
+}
+
+func TestOrchestrator_OrphanedCredentials(t *testing.T) {
+	referencedArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/creds-AbCdEf"
+	orphanedArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/orphan-AbCdEf"
+	// archivedArn is only referenced by an inactive revision and deployedArn by an inactive revision a service still runs
+	archivedArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/archived-AbCdEf"
+	deployedArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/deployed-AbCdEf"
+
+	secrets := []*secretsmanager.SecretListEntry{
+		{ARN: aws.String(referencedArn), Name: aws.String("spinup/mock/cluster1/creds")},
+		{ARN: aws.String(orphanedArn), Name: aws.String("spinup/mock/cluster1/orphan")},
+		{ARN: aws.String(archivedArn), Name: aws.String("spinup/mock/cluster1/archived")},
+		{ARN: aws.String(deployedArn), Name: aws.String("spinup/mock/cluster1/deployed")},
+		{
+			ARN:         aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/deleted-AbCdEf"),
+			Name:        aws.String("spinup/mock/cluster1/deleted"),
+			DeletedDate: aws.Time(time.Now()),
+		},
+		{
+			ARN:  aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/otherorg/cluster1/creds-AbCdEf"),
+			Name: aws.String("spinup/otherorg/cluster1/creds"),
+		},
+	}
+
+	resources := []string{
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/credsapp:1",
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:1",
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/archivedapp:1",
+	}
+
+	clusters := map[string]map[string]string{
+		"arn:aws:ecs:us-east-1:0123456789:cluster/clu1": {"spinup:org": "mock"},
+	}
+
+	services := map[string][]string{"clu1": {"deployed"}}
+
+	tests := []struct {
+		name        string
+		delete      bool
+		resources   []string
+		clusters    map[string]map[string]string
+		services    map[string][]string
+		ecserr      error
+		want        *OrphanedCredentialsOutput
+		wantDeleted []string
+		wantErr     bool
+	}{
+		{
+			name:      "list orphaned credentials",
+			resources: resources,
+			clusters:  clusters,
+			services:  services,
+			want:      &OrphanedCredentialsOutput{Orphaned: []string{orphanedArn}},
+		},
+		{
+			name:        "delete orphaned credentials",
+			delete:      true,
+			resources:   resources,
+			clusters:    clusters,
+			services:    services,
+			want:        &OrphanedCredentialsOutput{Orphaned: []string{orphanedArn}, Deleted: []string{orphanedArn}},
+			wantDeleted: []string{orphanedArn},
+		},
+		{
+			name:      "no running services",
+			resources: resources,
+			want:      &OrphanedCredentialsOutput{Orphaned: []string{deployedArn, orphanedArn}},
+		},
+		{
+			name:     "no task definitions",
+			clusters: clusters,
+			services: services,
+			want:     &OrphanedCredentialsOutput{Orphaned: []string{archivedArn, referencedArn, orphanedArn}},
+		},
+		{
+			name: "no task definitions or services",
+			want: &OrphanedCredentialsOutput{Orphaned: []string{archivedArn, referencedArn, deployedArn, orphanedArn}},
+		},
+		{
+			name:      "ecs error",
+			delete:    true,
+			resources: resources,
+			ecserr:    awserr.New(ecs.ErrCodeServerException, "boom", nil),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "mock", nil, tt.ecserr, nil, nil, nil, nil)
+			o.ResourceGroupsTaggingAPI.Service.(*mockRGTAClient).resources = tt.resources
+			o.ResourceGroupsTaggingAPI.Service.(*mockRGTAClient).tagged = tt.clusters
+			o.ECS.Service.(*mockECSClient).services = tt.services
+			sm := o.SecretsManager.Service.(*mockSMClient)
+			sm.secrets = secrets
+
+			got, err := o.OrphanedCredentials(context.TODO(), tt.delete)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Orchestrator.OrphanedCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(sm.deleted, tt.wantDeleted) {
+				t.Errorf("expected deleted secrets %v, got %v", tt.wantDeleted, sm.deleted)
+			}
+
+			// orphans are scheduled for deletion with a recovery window, never force deleted
+			for _, s := range tt.wantDeleted {
+				if w := sm.windows[s]; w != OrphanedCredentialsRecoveryWindow {
+					t.Errorf("expected %s to be deleted with a %d day recovery window, got %d", s, OrphanedCredentialsRecoveryWindow, w)
+				}
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.OrphanedCredentials() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_OrphanedCredentialsManyRevisions(t *testing.T) {
+	revisions := testTaskDefinitionRevisions
+	defer func() { testTaskDefinitionRevisions = revisions }()
+
+	// the busyapp family has 100 revisions, only the newest revision references the credentials
+	referencedArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/busy-AbCdEf"
+	orphanedArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/stale-AbCdEf"
+	for i := 1; i <= 100; i++ {
+		td := &ecs.TaskDefinition{
+			ContainerDefinitions: []*ecs.ContainerDefinition{{Name: aws.String("busy")}},
+			Family:               aws.String("busyapp"),
+			Revision:             aws.Int64(int64(i)),
+			Status:               aws.String("INACTIVE"),
+			TaskDefinitionArn:    aws.String(fmt.Sprintf("arn:aws:ecs:us-east-1:0123456789:task-definition/busyapp:%d", i)),
+		}
+
+		if i == 100 {
+			td.Status = aws.String("ACTIVE")
+			td.ContainerDefinitions[0].RepositoryCredentials = &ecs.RepositoryCredentials{CredentialsParameter: aws.String(referencedArn)}
+		}
+
+		testTaskDefinitionRevisions = append(testTaskDefinitionRevisions, td)
+	}
+
+	o := newMockOrchestrator(t, "mock", nil, nil, nil, nil, nil, nil)
+	o.SecretReferences = NewSecretReferences()
+	o.ResourceGroupsTaggingAPI.Service.(*mockRGTAClient).resources = []string{"arn:aws:ecs:us-east-1:0123456789:task-definition/busyapp:100"}
+	o.SecretsManager.Service.(*mockSMClient).secrets = []*secretsmanager.SecretListEntry{
+		{ARN: aws.String(referencedArn), Name: aws.String("spinup/mock/cluster1/busy")},
+		{ARN: aws.String(orphanedArn), Name: aws.String("spinup/mock/cluster1/stale")},
+	}
+	e := o.ECS.Service.(*mockECSClient)
+
+	want := &OrphanedCredentialsOutput{Orphaned: []string{orphanedArn}}
+	for _, wantDescribed := range []int{100, 0} {
+		e.describedTaskDefinitions = nil
+
+		got, err := o.OrphanedCredentials(context.TODO(), false)
+		if err != nil {
+			t.Fatalf("expected nil error, got %s", err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+
+		// each revision is only described by the first scan
+		if len(e.describedTaskDefinitions) != wantDescribed {
+			t.Errorf("expected %d task definitions to be described, got %d", wantDescribed, len(e.describedTaskDefinitions))
+		}
+	}
+
+	// a deleted revision is dropped from the cache
+	testTaskDefinitionRevisions = testTaskDefinitionRevisions[:len(testTaskDefinitionRevisions)-1]
+	if _, err := o.OrphanedCredentials(context.TODO(), false); err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if _, ok := o.SecretReferences.get("arn:aws:ecs:us-east-1:0123456789:task-definition/busyapp:100"); ok {
+		t.Error("expected the deleted revision to be dropped from the cache")
+	}
+}
+
+func TestOrchestrator_validateRepositoryCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		strict  bool
+		input   map[string]*secretsmanager.CreateSecretInput
+		wantErr bool
+	}{
+		{
+			name:   "valid credentials",
+			strict: true,
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), SecretString: aws.String(`{"username": "foo", "password": "bar"}`)},
+				"sidecar":   {Name: aws.String("sidecreds"), SecretString: aws.String(`{"username": "baz", "password": "qux", "email": "baz@example.com"}`)},
+			},
+		},
+		{
+			name:   "no credentials",
+			strict: true,
+		},
+		{
+			name:   "malformed json",
+			strict: true,
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), SecretString: aws.String(`{"username": "foo", "password": `)},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "not a json object",
+			strict: true,
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), SecretString: aws.String(`foo:bar`)},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "missing password",
+			strict: true,
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), SecretString: aws.String(`{"username": "foo"}`)},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "empty username",
+			strict: true,
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), SecretString: aws.String(`{"username": "", "password": "bar"}`)},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "non-string password",
+			strict: true,
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), SecretString: aws.String(`{"username": "foo", "password": 1234}`)},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "binary secret",
+			strict: true,
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), SecretBinary: []byte("foo:bar")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed json without strict validation",
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), SecretString: aws.String(`foo:bar`)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Orchestrator{StrictRepositoryCredentials: tt.strict}
+
+			err := o.validateRepositoryCredentials(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Orchestrator.validateRepositoryCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil {
+				return
+			}
+
+			var aerr apierror.Error
+			if !errors.As(err, &aerr) || aerr.Code != apierror.ErrBadRequest {
+				t.Errorf("expected bad request error, got %v", err)
+			}
+
+			if strings.Contains(err.Error(), "foo") {
+				t.Errorf("expected the error not to include the secret string, got %s", err)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_createRepostitoryCredentialsStrict(t *testing.T) {
+	o := newMockOrchestrator(t, "mock", nil, nil, nil, nil, nil, nil)
+	o.StrictRepositoryCredentials = true
+
+	_, err := o.createRepostitoryCredentials(context.TODO(), "spinup/mock/clu1", map[string]*secretsmanager.CreateSecretInput{
+		"webserver": {Name: aws.String("creds"), SecretString: aws.String(`{"username": "foo", "password": "bar"}`)},
+		"sidecar":   {Name: aws.String("sidecreds"), SecretString: aws.String(`{"user": "foo", "pass": "bar"}`)},
+	}, nil)
+
+	var aerr apierror.Error
+	if !errors.As(err, &aerr) || aerr.Code != apierror.ErrBadRequest {
+		t.Fatalf("expected bad request error, got %v", err)
+	}
+
+	if deleted := o.SecretsManager.Service.(*mockSMClient).deleted; len(deleted) > 0 {
+		t.Errorf("expected no secrets to be created and cleaned up, got %v", deleted)
+	}
+}
+
+func TestOrchestrator_createRepostitoryCredentialsKmsKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		defaultKey string
+		input      map[string]*secretsmanager.CreateSecretInput
+		want       map[string]string
+		wantErr    bool
+	}{
+		{
+			name: "account default key",
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), SecretString: aws.String("shhhhh")},
+			},
+		},
+		{
+			name:       "configured key",
+			defaultKey: "alias/spinup-mock-secrets",
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), SecretString: aws.String("shhhhh")},
+			},
+			want: map[string]string{
+				"spinup/mock/clu1/creds": "alias/spinup-mock-secrets",
+			},
+		},
+		{
+			name:       "override",
+			defaultKey: "alias/spinup-mock-secrets",
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {
+					Name:         aws.String("creds"),
+					KmsKeyId:     aws.String("arn:aws:kms:us-east-1:012345678901:key/12121212-3333-4444-5555-676767676767"),
+					SecretString: aws.String("shhhhh"),
+				},
+				"sidecar": {Name: aws.String("sidecreds"), SecretString: aws.String("shhhhh")},
+			},
+			want: map[string]string{
+				"spinup/mock/clu1/creds":     "arn:aws:kms:us-east-1:012345678901:key/12121212-3333-4444-5555-676767676767",
+				"spinup/mock/clu1/sidecreds": "alias/spinup-mock-secrets",
+			},
+		},
+		{
+			name:       "invalid override",
+			defaultKey: "alias/spinup-mock-secrets",
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), KmsKeyId: aws.String("mykey"), SecretString: aws.String("shhhhh")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "mock", nil, nil, nil, nil, nil, nil)
+			o.SecretsManager.SecretKmsKeyId = tt.defaultKey
+
+			_, err := o.createRepostitoryCredentials(context.TODO(), "spinup/mock/clu1", tt.input, nil)
+			if tt.wantErr {
+				var aerr apierror.Error
+				if !errors.As(err, &aerr) || aerr.Code != apierror.ErrBadRequest {
+					t.Errorf("expected bad request error, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if got := o.SecretsManager.Service.(*mockSMClient).kmsKeyIds; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected secret kms keys %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateKmsKeyId(t *testing.T) {
+	tests := []struct {
+		id      string
+		wantErr bool
+	}{
+		{id: "12121212-3333-4444-5555-676767676767"},
+		{id: "mrk-1234abcd12ab34cd56ef1234567890ab"},
+		{id: "arn:aws:kms:us-east-1:012345678901:key/12121212-3333-4444-5555-676767676767"},
+		{id: "alias/spinup-mock-secrets"},
+		{id: "alias/aws/secretsmanager"},
+		{id: "arn:aws:kms:us-east-1:012345678901:alias/spinup-mock-secrets"},
+		{id: "", wantErr: true},
+		{id: "mykey", wantErr: true},
+		{id: "alias/", wantErr: true},
+		{id: "alias/bad alias", wantErr: true},
+		{id: "arn:aws:kms:us-east-1:012345678901:key/mykey", wantErr: true},
+		{id: "arn:aws:kms:us-east-1:012345678901:grant/12121212-3333-4444-5555-676767676767", wantErr: true},
+		{id: "arn:aws:s3:::bucket/12121212-3333-4444-5555-676767676767", wantErr: true},
+		{id: "arn:aws:kms", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if err := validateKmsKeyId(tt.id); (err != nil) != tt.wantErr {
+				t.Errorf("validateKmsKeyId() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_createRepostitoryCredentialsExisting(t *testing.T) {
+	t.Run("conflict", func(t *testing.T) {
+		o := newMockOrchestrator(t, "mock", nil, nil, nil, nil, nil, nil)
+
+		_, err := o.createRepostitoryCredentials(context.TODO(), "spinup/mock/clu1", map[string]*secretsmanager.CreateSecretInput{
+			"webserver": {Name: aws.String("creds"), SecretString: aws.String(`{"username": "foo", "password": "bar"}`)},
+			"sidecar":   {Name: aws.String("sidecreds-exists"), SecretString: aws.String(`{"username": "foo", "password": "bar"}`)},
+		}, nil)
+
+		var aerr apierror.Error
+		if !errors.As(err, &aerr) || aerr.Code != apierror.ErrConflict {
+			t.Fatalf("expected conflict error, got %v", err)
+		}
+
+		expected := "repository credentials secret spinup/mock/clu1/sidecreds-exists for container sidecar already exists"
+		if aerr.Message != expected {
+			t.Errorf("expected error message %q, got %q", expected, aerr.Message)
+		}
+
+		// the secret created for the other container is cleaned up
+		deleted := o.SecretsManager.Service.(*mockSMClient).deleted
+		if !reflect.DeepEqual(deleted, []string{"arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/clu1/creds"}) {
+			t.Errorf("expected the webserver secret to be cleaned up, got %v", deleted)
+		}
+	})
+
+	t.Run("unique suffix", func(t *testing.T) {
+		o := newMockOrchestrator(t, "mock", nil, nil, nil, nil, nil, nil)
+		o.UniqueRepositoryCredentialsNames = true
+
+		out, err := o.createRepostitoryCredentials(context.TODO(), "spinup/mock/clu1", map[string]*secretsmanager.CreateSecretInput{
+			"webserver": {Name: aws.String("creds"), SecretString: aws.String(`{"username": "foo", "password": "bar"}`)},
+			"sidecar":   {Name: aws.String("sidecreds-exists"), SecretString: aws.String(`{"username": "foo", "password": "bar"}`)},
+		}, nil)
+		if err != nil {
+			t.Fatalf("expected nil error, got %s", err)
+		}
+
+		if name := aws.StringValue(out["webserver"].Name); name != "spinup/mock/clu1/creds" {
+			t.Errorf("expected webserver secret name to be unchanged, got %s", name)
+		}
+
+		name := aws.StringValue(out["sidecar"].Name)
+		if !regexp.MustCompile(`^spinup/mock/clu1/sidecreds-exists-[0-9a-f]{8}$`).MatchString(name) {
+			t.Errorf("expected sidecar secret name with a unique suffix, got %s", name)
+		}
+	})
+}
+
+func (m *mockSMClient) ReplicateSecretToRegionsWithContext(ctx context.Context, input *secretsmanager.ReplicateSecretToRegionsInput, opts ...request.Option) (*secretsmanager.ReplicateSecretToRegionsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := &secretsmanager.ReplicateSecretToRegionsOutput{ARN: input.SecretId}
+	for _, r := range input.AddReplicaRegions {
+		if aws.StringValue(r.Region) == "xx-fail-1" {
+			return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, "invalid region", nil)
+		}
+
+		out.ReplicationStatus = append(out.ReplicationStatus, &secretsmanager.ReplicationStatusType{
+			KmsKeyId: r.KmsKeyId,
+			Region:   r.Region,
+			Status:   aws.String(secretsmanager.StatusTypeInProgress),
+		})
+	}
+
+	m.replicated = append(m.replicated, input)
+
+	return out, nil
+}
+
+func TestOrchestrator_createRepostitoryCredentialsReplicated(t *testing.T) {
+	input := func() map[string]*secretsmanager.CreateSecretInput {
+		return map[string]*secretsmanager.CreateSecretInput{
+			"webserver": {Name: aws.String("creds"), SecretString: aws.String(`{"username": "foo", "password": "bar"}`)},
+		}
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		o := newMockOrchestrator(t, "mock", nil, nil, nil, nil, nil, nil)
+
+		if _, err := o.createRepostitoryCredentials(context.TODO(), "spinup/mock/clu1", input(), nil); err != nil {
+			t.Fatalf("expected nil error, got %s", err)
+		}
+
+		if replicated := o.SecretsManager.Service.(*mockSMClient).replicated; len(replicated) != 0 {
+			t.Errorf("expected no replication, got %+v", replicated)
+		}
+	})
+
+	t.Run("replica regions", func(t *testing.T) {
+		o := newMockOrchestrator(t, "mock", nil, nil, nil, nil, nil, nil)
+		o.SecretsManager.ReplicaRegions = []string{"us-west-2", "us-east-2"}
+		o.SecretsManager.ReplicaKmsKeyIds = map[string]string{"us-west-2": "arn:aws:kms:us-west-2:12345678910:key/abc"}
+
+		if _, err := o.createRepostitoryCredentials(context.TODO(), "spinup/mock/clu1", input(), nil); err != nil {
+			t.Fatalf("expected nil error, got %s", err)
+		}
+
+		replicated := o.SecretsManager.Service.(*mockSMClient).replicated
+		if len(replicated) != 1 {
+			t.Fatalf("expected 1 replication, got %d", len(replicated))
+		}
+
+		if id := aws.StringValue(replicated[0].SecretId); id != "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/clu1/creds" {
+			t.Errorf("expected the webserver secret to be replicated, got %s", id)
+		}
+
+		expected := []*secretsmanager.ReplicaRegionType{
+			{Region: aws.String("us-west-2"), KmsKeyId: aws.String("arn:aws:kms:us-west-2:12345678910:key/abc")},
+			{Region: aws.String("us-east-2")},
+		}
+		if !reflect.DeepEqual(replicated[0].AddReplicaRegions, expected) {
+			t.Errorf("expected replica regions %+v, got %+v", expected, replicated[0].AddReplicaRegions)
+		}
+	})
+
+	t.Run("replication failure", func(t *testing.T) {
+		o := newMockOrchestrator(t, "mock", nil, nil, nil, nil, nil, nil)
+		o.SecretsManager.ReplicaRegions = []string{"xx-fail-1"}
+
+		if _, err := o.createRepostitoryCredentials(context.TODO(), "spinup/mock/clu1", input(), nil); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+
+		// the secret that couldn't be replicated is cleaned up
+		deleted := o.SecretsManager.Service.(*mockSMClient).deleted
+		if !reflect.DeepEqual(deleted, []string{"arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/clu1/creds"}) {
+			t.Errorf("expected the webserver secret to be cleaned up, got %v", deleted)
+		}
+	})
+}
+
+func TestOrchestrator_repositoryCredentialsKmsKeyArns(t *testing.T) {
+	tests := []struct {
+		name       string
+		defaultKey string
+		input      map[string]*secretsmanager.CreateSecretInput
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name: "aws managed key",
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds")},
+			},
+			want: []string{},
+		},
+		{
+			name:       "configured key alias",
+			defaultKey: "alias/spinup-mock-secrets",
+			want:       []string{testSecretKmsKeyArn},
+		},
+		{
+			name:       "configured and per container keys",
+			defaultKey: "alias/spinup-mock-secrets",
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), KmsKeyId: aws.String("alias/other")},
+				"sidecar":   {Name: aws.String("sidecreds"), KmsKeyId: aws.String("12121212-3333-4444-5555-676767676767")},
+			},
+			want: []string{
+				"arn:aws:kms:us-east-1:012345678901:key/12121212-3333-4444-5555-676767676767",
+				"arn:aws:kms:us-east-1:012345678901:key/abababab-3333-4444-5555-676767676767",
+			},
+		},
+		{
+			name: "unknown key",
+			input: map[string]*secretsmanager.CreateSecretInput{
+				"webserver": {Name: aws.String("creds"), KmsKeyId: aws.String("alias/missing")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "mock", nil, nil, nil, nil, nil, nil)
+			o.SecretsManager.SecretKmsKeyId = tt.defaultKey
+
+			got, err := o.repositoryCredentialsKmsKeyArns(context.TODO(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("repositoryCredentialsKmsKeyArns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("repositoryCredentialsKmsKeyArns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
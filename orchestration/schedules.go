@@ -0,0 +1,379 @@
+package orchestration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	log "github.com/sirupsen/logrus"
+)
+
+// ScheduledTask is an EventBridge rule that runs a task definition in a cluster
+type ScheduledTask struct {
+	Name               string
+	Arn                string
+	Description        string `json:",omitempty"`
+	ScheduleExpression string `json:",omitempty"`
+	EventPattern       string `json:",omitempty"`
+	State              string
+	TargetId           string
+	TaskDefinitionArn  string
+	TaskCount          int64
+	LaunchType         string `json:",omitempty"`
+}
+
+// ScheduledTaskCreateInput is the input for scheduling a task definition to run in a cluster
+type ScheduledTaskCreateInput struct {
+	// Name of the EventBridge rule
+	Name        string
+	Description string
+	// ScheduleExpression is a cron() or rate() expression
+	ScheduleExpression string
+	// Enabled defaults to true
+	Enabled *bool
+	// TaskCount is the number of tasks to run on each invocation, it defaults to 1
+	TaskCount *int64
+	// NetworkConfiguration defaults to the configured subnets and security groups, optionally narrowed
+	// down by the NetworkSelection
+	NetworkConfiguration *ecs.NetworkConfiguration
+	NetworkSelection     *NetworkSelection
+	// CapacityProviderStrategy runs the task with the cluster's capacity providers instead of the FARGATE
+	// launch type, it's required for task definitions that aren't compatible with FARGATE
+	CapacityProviderStrategy []*ecs.CapacityProviderStrategyItem
+	Tags                     []*Tag
+}
+
+// ScheduledTasks lists the EventBridge rules with an ECS target that runs the task definition family in the cluster
+func (o *Orchestrator) ScheduledTasks(ctx context.Context, cluster, family string) ([]*ScheduledTask, error) {
+	if cluster == "" || family == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "cluster and task def family are required", nil)
+	}
+
+	fullFamily, err := o.resolveTaskDefFamily(ctx, cluster, family)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.scheduledTasks(ctx, cluster, fullFamily)
+}
+
+// scheduledTasks lists the EventBridge rules with an ECS target that runs the (full) task definition family in the cluster
+func (o *Orchestrator) scheduledTasks(ctx context.Context, cluster, fullFamily string) ([]*ScheduledTask, error) {
+	log.Debugf("listing scheduled tasks for %s/%s", cluster, fullFamily)
+
+	clu, err := o.ECS.GetCluster(ctx, aws.String(cluster))
+	if err != nil {
+		return nil, err
+	}
+	clusterArn := aws.StringValue(clu.ClusterArn)
+
+	// ECS targets use the cluster as the target ARN
+	rules, err := o.EventBridge.ListRuleNamesByTarget(ctx, clusterArn)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduled := []*ScheduledTask{}
+	for _, r := range rules {
+		targets, err := o.EventBridge.ListTargetsByRule(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range targets {
+			if aws.StringValue(t.Arn) != clusterArn || t.EcsParameters == nil {
+				continue
+			}
+
+			tdArn := aws.StringValue(t.EcsParameters.TaskDefinitionArn)
+			if taskDefinitionArnFamily(tdArn) != fullFamily {
+				continue
+			}
+
+			rule, err := o.EventBridge.GetRule(ctx, r)
+			if err != nil {
+				return nil, err
+			}
+
+			scheduled = append(scheduled, &ScheduledTask{
+				Name:               aws.StringValue(rule.Name),
+				Arn:                aws.StringValue(rule.Arn),
+				Description:        aws.StringValue(rule.Description),
+				ScheduleExpression: aws.StringValue(rule.ScheduleExpression),
+				EventPattern:       aws.StringValue(rule.EventPattern),
+				State:              aws.StringValue(rule.State),
+				TargetId:           aws.StringValue(t.Id),
+				TaskDefinitionArn:  tdArn,
+				TaskCount:          aws.Int64Value(t.EcsParameters.TaskCount),
+				LaunchType:         aws.StringValue(t.EcsParameters.LaunchType),
+			})
+		}
+	}
+
+	return scheduled, nil
+}
+
+// ScheduleTask creates an EventBridge rule with the schedule expression and an ECS target that runs the task definition
+// in the cluster.  The target assumes the cluster's scheduled task role, which is granted RunTask on the task definition
+// family and PassRole on its roles.  If the family doesn't include a revision, the latest revision is run.
+func (o *Orchestrator) ScheduleTask(ctx context.Context, cluster, family string, input *ScheduledTaskCreateInput) (*ScheduledTask, error) {
+	if cluster == "" || family == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "cluster and task def family are required", nil)
+	}
+
+	if input == nil || input.Name == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "schedule name is required", nil)
+	}
+
+	if err := validateScheduleExpression(input.ScheduleExpression); err != nil {
+		return nil, err
+	}
+
+	if input.NetworkConfiguration != nil && input.NetworkSelection != nil {
+		return nil, apierror.New(apierror.ErrBadRequest, "network configuration and network selection cannot both be passed", nil)
+	}
+
+	taskCount := int64(1)
+	if input.TaskCount != nil {
+		taskCount = aws.Int64Value(input.TaskCount)
+		if err := validateTaskCount(taskCount); err != nil {
+			return nil, err
+		}
+	}
+
+	state := eventbridge.RuleStateEnabled
+	if input.Enabled != nil && !aws.BoolValue(input.Enabled) {
+		state = eventbridge.RuleStateDisabled
+	}
+
+	tags, err := cleanTags(o.Org, o.AllowedOrgs, cluster, "container", "schedule", MergeDefaultTags(o.DefaultTags, input.Tags))
+	if err != nil {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid tags", err)
+	}
+
+	log.Debugf("scheduling task %s/%s with rule %s", cluster, family, input.Name)
+
+	clu, err := o.ECS.GetCluster(ctx, aws.String(cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	td, err := o.clusterTaskDefinition(ctx, cluster, family)
+	if err != nil {
+		return nil, err
+	}
+
+	if aws.StringValue(td.ExecutionRoleArn) == "" {
+		msg := fmt.Sprintf("taskdef %s doesn't have a task execution role", family)
+		return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	// like running a task definition, scheduled tasks use the FARGATE launch type unless a capacity provider strategy is passed
+	launchType := ""
+	if input.CapacityProviderStrategy == nil {
+		if !fargateCompatible(td) {
+			msg := fmt.Sprintf("taskdef %s isn't compatible with %s, a capacity provider strategy is required", family, ecs.LaunchTypeFargate)
+			return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+		launchType = ecs.LaunchTypeFargate
+	} else if err := validateCapacityProviderStrategy(clu, input.CapacityProviderStrategy); err != nil {
+		return nil, err
+	}
+
+	// run the latest revision unless a specific revision was requested
+	tdArn := aws.StringValue(td.TaskDefinitionArn)
+	if !strings.Contains(family, ":") {
+		tdArn = strings.TrimSuffix(tdArn, fmt.Sprintf(":%d", aws.Int64Value(td.Revision)))
+	}
+
+	// the network configuration is only used (and allowed) with awsvpc networking, which FARGATE requires
+	nc := input.NetworkConfiguration
+	if nc == nil && (launchType == ecs.LaunchTypeFargate || aws.StringValue(td.NetworkMode) == ecs.NetworkModeAwsvpc) {
+		if nc, err = o.defaultNetworkConfiguration(input.NetworkSelection); err != nil {
+			return nil, err
+		}
+	}
+
+	// PutRule updates an existing rule, so make sure we don't take over someone else's
+	if _, err := o.EventBridge.GetRule(ctx, input.Name); err == nil {
+		msg := fmt.Sprintf("schedule %s already exists", input.Name)
+		return nil, apierror.New(apierror.ErrConflict, msg, nil)
+	} else if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+		return nil, err
+	}
+
+	roleArn, err := o.ScheduledTaskRole(ctx, cluster, aws.StringValue(clu.ClusterArn), td, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	ruleTags := make([]*eventbridge.Tag, len(tags))
+	for i, t := range tags {
+		ruleTags[i] = &eventbridge.Tag{Key: t.Key, Value: t.Value}
+	}
+
+	ruleArn, err := o.EventBridge.CreateRule(ctx, &eventbridge.PutRuleInput{
+		Name:               aws.String(input.Name),
+		Description:        aws.String(input.Description),
+		ScheduleExpression: aws.String(input.ScheduleExpression),
+		State:              aws.String(state),
+		Tags:               ruleTags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	target := &eventbridge.Target{
+		Id:      aws.String(scheduledTaskTargetId(td)),
+		Arn:     clu.ClusterArn,
+		RoleArn: aws.String(roleArn),
+		EcsParameters: &eventbridge.EcsParameters{
+			TaskDefinitionArn:    aws.String(tdArn),
+			TaskCount:            aws.Int64(taskCount),
+			NetworkConfiguration: toEventBridgeNetworkConfiguration(nc),
+			EnableECSManagedTags: DefaultEnableECSManagedTags,
+			PropagateTags:        aws.String(ecs.PropagateTagsTaskDefinition),
+		},
+	}
+
+	if launchType != "" {
+		target.EcsParameters.LaunchType = aws.String(launchType)
+	}
+
+	for _, c := range input.CapacityProviderStrategy {
+		target.EcsParameters.CapacityProviderStrategy = append(target.EcsParameters.CapacityProviderStrategy, &eventbridge.CapacityProviderStrategyItem{
+			Base:             c.Base,
+			CapacityProvider: c.CapacityProvider,
+			Weight:           c.Weight,
+		})
+	}
+
+	if err := o.EventBridge.PutTargets(ctx, input.Name, []*eventbridge.Target{target}); err != nil {
+		// don't leave a rule without a target behind
+		if derr := o.EventBridge.DeleteRule(ctx, input.Name); derr != nil {
+			log.Errorf("failed to rollback creation of rule %s: %s", input.Name, derr)
+		}
+		return nil, err
+	}
+
+	return &ScheduledTask{
+		Name:               input.Name,
+		Arn:                ruleArn,
+		Description:        input.Description,
+		ScheduleExpression: input.ScheduleExpression,
+		State:              state,
+		TargetId:           aws.StringValue(target.Id),
+		TaskDefinitionArn:  tdArn,
+		TaskCount:          taskCount,
+		LaunchType:         launchType,
+	}, nil
+}
+
+// DeleteScheduledTask removes the targets of a schedule for the task definition in the cluster and deletes the
+// EventBridge rule.  Rules that don't run the task definition in the cluster are not found.
+func (o *Orchestrator) DeleteScheduledTask(ctx context.Context, cluster, family, name string) error {
+	if cluster == "" || family == "" || name == "" {
+		return apierror.New(apierror.ErrBadRequest, "cluster, task def family and schedule name are required", nil)
+	}
+
+	scheduled, err := o.ScheduledTasks(ctx, cluster, family)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range scheduled {
+		if s.Name == name {
+			return o.deleteScheduleRule(ctx, name)
+		}
+	}
+
+	msg := fmt.Sprintf("schedule %s not found for taskdef %s", name, family)
+	return apierror.New(apierror.ErrNotFound, msg, nil)
+}
+
+// deleteScheduledTasks deletes all of the schedules for the (full) task definition family in the cluster
+func (o *Orchestrator) deleteScheduledTasks(ctx context.Context, cluster, family string) error {
+	scheduled, err := o.scheduledTasks(ctx, cluster, family)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range scheduled {
+		if err := o.deleteScheduleRule(ctx, s.Name); err != nil {
+			return err
+		}
+		log.Infof("deleted schedule %s for %s/%s", s.Name, cluster, family)
+	}
+
+	return nil
+}
+
+// deleteScheduleRule removes all of the targets from a rule and deletes it
+func (o *Orchestrator) deleteScheduleRule(ctx context.Context, name string) error {
+	targets, err := o.EventBridge.ListTargetsByRule(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if len(targets) > 0 {
+		ids := make([]string, len(targets))
+		for i, t := range targets {
+			ids[i] = aws.StringValue(t.Id)
+		}
+
+		if err := o.EventBridge.RemoveTargets(ctx, name, ids); err != nil {
+			return err
+		}
+	}
+
+	return o.EventBridge.DeleteRule(ctx, name)
+}
+
+// validateScheduleExpression ensures the expression is an EventBridge cron() or rate() expression
+func validateScheduleExpression(expr string) error {
+	if (strings.HasPrefix(expr, "cron(") || strings.HasPrefix(expr, "rate(")) && strings.HasSuffix(expr, ")") && len(expr) > 6 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("invalid schedule expression '%s', expected cron(...) or rate(...)", expr)
+	return apierror.New(apierror.ErrBadRequest, msg, nil)
+}
+
+// scheduledTaskTargetId returns the id of the ECS target for the task definition, ids are limited to 64 characters
+func scheduledTaskTargetId(td *ecs.TaskDefinition) string {
+	id := aws.StringValue(td.Family)
+	if len(id) > 64 {
+		id = id[:64]
+	}
+	return id
+}
+
+// toEventBridgeNetworkConfiguration converts the ECS network configuration to the EventBridge ECS target equivalent
+func toEventBridgeNetworkConfiguration(nc *ecs.NetworkConfiguration) *eventbridge.NetworkConfiguration {
+	if nc == nil || nc.AwsvpcConfiguration == nil {
+		return nil
+	}
+
+	return &eventbridge.NetworkConfiguration{
+		AwsvpcConfiguration: &eventbridge.AwsVpcConfiguration{
+			AssignPublicIp: nc.AwsvpcConfiguration.AssignPublicIp,
+			SecurityGroups: nc.AwsvpcConfiguration.SecurityGroups,
+			Subnets:        nc.AwsvpcConfiguration.Subnets,
+		},
+	}
+}
+
+// taskDefinitionArnFamily returns the family of a task definition referenced by ARN or by family, with or without a revision
+func taskDefinitionArnFamily(td string) string {
+	if a, err := arn.Parse(td); err == nil {
+		td = strings.TrimPrefix(a.Resource, "task-definition/")
+	}
+
+	family, _, _ := strings.Cut(td, ":")
+	return family
+}
@@ -0,0 +1,686 @@
+package orchestration
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	yiam "github.com/YaleSpinup/aws-go/services/iam"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+)
+
+func (m *mockEBClient) ListRuleNamesByTargetWithContext(ctx aws.Context, input *eventbridge.ListRuleNamesByTargetInput, opts ...request.Option) (*eventbridge.ListRuleNamesByTargetOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	names := []*string{}
+	for rule, targets := range m.targets {
+		for _, t := range targets {
+			if aws.StringValue(t.Arn) == aws.StringValue(input.TargetArn) {
+				names = append(names, aws.String(rule))
+				break
+			}
+		}
+	}
+
+	return &eventbridge.ListRuleNamesByTargetOutput{RuleNames: names}, nil
+}
+
+func (m *mockEBClient) ListTargetsByRuleWithContext(ctx aws.Context, input *eventbridge.ListTargetsByRuleInput, opts ...request.Option) (*eventbridge.ListTargetsByRuleOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	targets, ok := m.targets[aws.StringValue(input.Rule)]
+	if !ok {
+		return nil, awserr.New(eventbridge.ErrCodeResourceNotFoundException, "rule not found", nil)
+	}
+
+	return &eventbridge.ListTargetsByRuleOutput{Targets: targets}, nil
+}
+
+func (m *mockEBClient) DescribeRuleWithContext(ctx aws.Context, input *eventbridge.DescribeRuleInput, opts ...request.Option) (*eventbridge.DescribeRuleOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	for _, r := range m.rules {
+		if aws.StringValue(r.Name) == aws.StringValue(input.Name) {
+			return r, nil
+		}
+	}
+
+	return nil, awserr.New(eventbridge.ErrCodeResourceNotFoundException, "rule not found", nil)
+}
+
+func (m *mockEBClient) PutRuleWithContext(ctx aws.Context, input *eventbridge.PutRuleInput, opts ...request.Option) (*eventbridge.PutRuleOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	ruleArn := aws.String("arn:aws:events:us-east-1:1234567890:rule/" + aws.StringValue(input.Name))
+	m.rules = append(m.rules, &eventbridge.DescribeRuleOutput{
+		Arn:                ruleArn,
+		Description:        input.Description,
+		Name:               input.Name,
+		ScheduleExpression: input.ScheduleExpression,
+		State:              input.State,
+	})
+
+	return &eventbridge.PutRuleOutput{RuleArn: ruleArn}, nil
+}
+
+func (m *mockEBClient) PutTargetsWithContext(ctx aws.Context, input *eventbridge.PutTargetsInput, opts ...request.Option) (*eventbridge.PutTargetsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if m.targets == nil {
+		m.targets = map[string][]*eventbridge.Target{}
+	}
+	m.targets[aws.StringValue(input.Rule)] = append(m.targets[aws.StringValue(input.Rule)], input.Targets...)
+
+	return &eventbridge.PutTargetsOutput{FailedEntryCount: aws.Int64(0)}, nil
+}
+
+func (m *mockEBClient) RemoveTargetsWithContext(ctx aws.Context, input *eventbridge.RemoveTargetsInput, opts ...request.Option) (*eventbridge.RemoveTargetsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	remove := map[string]struct{}{}
+	for _, id := range input.Ids {
+		remove[aws.StringValue(id)] = struct{}{}
+	}
+
+	targets := []*eventbridge.Target{}
+	for _, t := range m.targets[aws.StringValue(input.Rule)] {
+		if _, ok := remove[aws.StringValue(t.Id)]; !ok {
+			targets = append(targets, t)
+		}
+	}
+	m.targets[aws.StringValue(input.Rule)] = targets
+
+	return &eventbridge.RemoveTargetsOutput{FailedEntryCount: aws.Int64(0)}, nil
+}
+
+func (m *mockEBClient) DeleteRuleWithContext(ctx aws.Context, input *eventbridge.DeleteRuleInput, opts ...request.Option) (*eventbridge.DeleteRuleOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	name := aws.StringValue(input.Name)
+	if len(m.targets[name]) > 0 {
+		return nil, awserr.New("ValidationException", "Rule can't be deleted since it has targets.", nil)
+	}
+
+	rules := []*eventbridge.DescribeRuleOutput{}
+	for _, r := range m.rules {
+		if aws.StringValue(r.Name) != name {
+			rules = append(rules, r)
+		}
+	}
+	m.rules = rules
+	delete(m.targets, name)
+
+	return &eventbridge.DeleteRuleOutput{}, nil
+}
+
+func TestOrchestrator_ScheduledTasks(t *testing.T) {
+	clusterArn := "arn:aws:ecs:us-east-1:1234567890:cluster/cluster0"
+
+	rules := []*eventbridge.DescribeRuleOutput{
+		{
+			Name:               aws.String("nightly-report"),
+			Arn:                aws.String("arn:aws:events:us-east-1:1234567890:rule/nightly-report"),
+			ScheduleExpression: aws.String("cron(0 2 * * ? *)"),
+			State:              aws.String(eventbridge.RuleStateEnabled),
+		},
+		{
+			Name:               aws.String("weekly-cleanup"),
+			Arn:                aws.String("arn:aws:events:us-east-1:1234567890:rule/weekly-cleanup"),
+			ScheduleExpression: aws.String("rate(7 days)"),
+			State:              aws.String(eventbridge.RuleStateDisabled),
+		},
+	}
+
+	targets := map[string][]*eventbridge.Target{
+		"nightly-report": {
+			{
+				Id:  aws.String("report"),
+				Arn: aws.String(clusterArn),
+				EcsParameters: &eventbridge.EcsParameters{
+					TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:1234567890:task-definition/report:3"),
+					TaskCount:         aws.Int64(1),
+					LaunchType:        aws.String("FARGATE"),
+				},
+			},
+			{
+				Id:  aws.String("notify"),
+				Arn: aws.String("arn:aws:sns:us-east-1:1234567890:report-topic"),
+			},
+		},
+		"weekly-cleanup": {
+			{
+				Id:  aws.String("cleanup"),
+				Arn: aws.String(clusterArn),
+				EcsParameters: &eventbridge.EcsParameters{
+					TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:1234567890:task-definition/cleanup"),
+					TaskCount:         aws.Int64(1),
+				},
+			},
+		},
+		"other-cluster": {
+			{
+				Id:  aws.String("report"),
+				Arn: aws.String("arn:aws:ecs:us-east-1:1234567890:cluster/cluster1"),
+				EcsParameters: &eventbridge.EcsParameters{
+					TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:1234567890:task-definition/report:1"),
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		cluster  string
+		family   string
+		err      error
+		want     []*ScheduledTask
+		wantCode string
+	}{
+		{
+			name:    "scheduled report",
+			cluster: "cluster0",
+			family:  "report",
+			want: []*ScheduledTask{
+				{
+					Name:               "nightly-report",
+					Arn:                "arn:aws:events:us-east-1:1234567890:rule/nightly-report",
+					ScheduleExpression: "cron(0 2 * * ? *)",
+					State:              eventbridge.RuleStateEnabled,
+					TargetId:           "report",
+					TaskDefinitionArn:  "arn:aws:ecs:us-east-1:1234567890:task-definition/report:3",
+					TaskCount:          1,
+					LaunchType:         "FARGATE",
+				},
+			},
+		},
+		{
+			name:    "disabled schedule without a revision",
+			cluster: "cluster0",
+			family:  "cleanup",
+			want: []*ScheduledTask{
+				{
+					Name:               "weekly-cleanup",
+					Arn:                "arn:aws:events:us-east-1:1234567890:rule/weekly-cleanup",
+					ScheduleExpression: "rate(7 days)",
+					State:              eventbridge.RuleStateDisabled,
+					TargetId:           "cleanup",
+					TaskDefinitionArn:  "arn:aws:ecs:us-east-1:1234567890:task-definition/cleanup",
+					TaskCount:          1,
+				},
+			},
+		},
+		{
+			name:    "no schedules",
+			cluster: "cluster0",
+			family:  "webapp",
+			want:    []*ScheduledTask{},
+		},
+		{
+			name:     "missing family",
+			cluster:  "cluster0",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "eventbridge error",
+			cluster:  "cluster0",
+			family:   "report",
+			err:      awserr.New(eventbridge.ErrCodeInternalException, "boom", nil),
+			wantCode: apierror.ErrInternalError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "", nil, nil, nil, nil, nil, nil)
+			o.EventBridge.Service = &mockEBClient{t: t, err: tt.err, rules: rules, targets: targets}
+
+			got, err := o.ScheduledTasks(context.TODO(), tt.cluster, tt.family)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.ScheduledTasks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_taskDefinitionArnFamily(t *testing.T) {
+	tests := map[string]string{
+		"arn:aws:ecs:us-east-1:1234567890:task-definition/report:3": "report",
+		"arn:aws:ecs:us-east-1:1234567890:task-definition/report":   "report",
+		"report:3": "report",
+		"report":   "report",
+	}
+
+	for td, want := range tests {
+		if got := taskDefinitionArnFamily(td); got != want {
+			t.Errorf("taskDefinitionArnFamily(%s) = %s, want %s", td, got, want)
+		}
+	}
+}
+
+func TestOrchestrator_ScheduleTask(t *testing.T) {
+	tests := []struct {
+		name     string
+		family   string
+		input    *ScheduledTaskCreateInput
+		want     *ScheduledTask
+		wantCode string
+	}{
+		{
+			name:   "schedule latest revision",
+			family: "loggedapp",
+			input: &ScheduledTaskCreateInput{
+				Name:               "loggedapp-nightly",
+				ScheduleExpression: "cron(0 2 * * ? *)",
+				TaskCount:          aws.Int64(2),
+			},
+			want: &ScheduledTask{
+				Name:               "loggedapp-nightly",
+				Arn:                "arn:aws:events:us-east-1:1234567890:rule/loggedapp-nightly",
+				ScheduleExpression: "cron(0 2 * * ? *)",
+				State:              eventbridge.RuleStateEnabled,
+				TargetId:           "loggedapp",
+				TaskDefinitionArn:  "arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp",
+				TaskCount:          2,
+				LaunchType:         "FARGATE",
+			},
+		},
+		{
+			name:   "disabled schedule for a revision",
+			family: "loggedapp:1",
+			input: &ScheduledTaskCreateInput{
+				Name:               "loggedapp-hourly",
+				ScheduleExpression: "rate(1 hour)",
+				Enabled:            aws.Bool(false),
+			},
+			want: &ScheduledTask{
+				Name:               "loggedapp-hourly",
+				Arn:                "arn:aws:events:us-east-1:1234567890:rule/loggedapp-hourly",
+				ScheduleExpression: "rate(1 hour)",
+				State:              eventbridge.RuleStateDisabled,
+				TargetId:           "loggedapp",
+				TaskDefinitionArn:  "arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:1",
+				TaskCount:          1,
+				LaunchType:         "FARGATE",
+			},
+		},
+		{
+			name:     "existing schedule",
+			family:   "loggedapp",
+			input:    &ScheduledTaskCreateInput{Name: "existing", ScheduleExpression: "rate(1 day)"},
+			wantCode: apierror.ErrConflict,
+		},
+		{
+			name:     "no task execution role",
+			family:   "webapp",
+			input:    &ScheduledTaskCreateInput{Name: "webapp-nightly", ScheduleExpression: "rate(1 day)"},
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "invalid schedule expression",
+			family:   "loggedapp",
+			input:    &ScheduledTaskCreateInput{Name: "loggedapp-nightly", ScheduleExpression: "0 2 * * *"},
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "task count exceeds the limit",
+			family:   "loggedapp",
+			input:    &ScheduledTaskCreateInput{Name: "loggedapp-nightly", ScheduleExpression: "rate(1 day)", TaskCount: aws.Int64(11)},
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "missing name",
+			family:   "loggedapp",
+			input:    &ScheduledTaskCreateInput{ScheduleExpression: "rate(1 day)"},
+			wantCode: apierror.ErrBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "", nil, nil, nil, nil, nil, nil)
+			o.DefaultPublic = "DISABLED"
+			o.DefaultSubnets = []string{"subnet-1", "subnet-2"}
+			o.DefaultSecurityGroups = []string{"sg-1"}
+
+			eb := &mockEBClient{t: t, rules: []*eventbridge.DescribeRuleOutput{{Name: aws.String("existing")}}}
+			o.EventBridge.Service = eb
+
+			got, err := o.ScheduleTask(context.TODO(), "cluster0", tt.family, tt.input)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+
+				if len(eb.rules) != 1 {
+					t.Errorf("expected no rules to be created, got %d rules", len(eb.rules))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.ScheduleTask() = %+v, want %+v", got, tt.want)
+			}
+
+			targets := eb.targets[tt.input.Name]
+			if len(targets) != 1 {
+				t.Fatalf("expected 1 target for rule %s, got %d", tt.input.Name, len(targets))
+			}
+
+			target := targets[0]
+			if arn := aws.StringValue(target.Arn); arn != "arn:aws:ecs:us-east-1:1234567890:cluster/cluster0" {
+				t.Errorf("expected target arn to be the cluster, got %s", arn)
+			}
+
+			if role := aws.StringValue(target.RoleArn); role != "arn:aws:iam::12345678910:role/cluster0-ecsEvents" {
+				t.Errorf("expected target role to be the scheduled task role, got %s", role)
+			}
+
+			// the role has to be assumable by EventBridge and allowed to run the task definition in the cluster
+			iamClient := o.IAM.Service.(*mockIAMClient)
+			var trust yiam.PolicyDocument
+			if err := json.Unmarshal([]byte(iamClient.assumeRolePolicy), &trust); err != nil {
+				t.Fatalf("expected valid assume role policy, got %s", err)
+			}
+
+			if len(trust.Statement) != 1 || !reflect.DeepEqual(trust.Statement[0].Principal["Service"], yiam.Value{"events.amazonaws.com"}) {
+				t.Errorf("expected the scheduled task role to trust events.amazonaws.com, got %s", iamClient.assumeRolePolicy)
+			}
+
+			var policy yiam.PolicyDocument
+			if err := json.Unmarshal([]byte(iamClient.rolePolicy), &policy); err != nil {
+				t.Fatalf("expected valid scheduled task policy, got %s", err)
+			}
+
+			taskDef := "arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:*"
+			if !policyAllows(policy, "ecs:RunTask", taskDef, "ecs:cluster", "arn:aws:ecs:us-east-1:1234567890:cluster/cluster0") {
+				t.Errorf("expected the scheduled task role to be allowed to run %s in the cluster, got %s", taskDef, iamClient.rolePolicy)
+			}
+
+			executionRole := "arn:aws:iam::12345678910:role/clu1-ecsTaskExecution"
+			if !policyAllows(policy, "iam:PassRole", executionRole, "iam:PassedToService", "ecs-tasks.amazonaws.com") {
+				t.Errorf("expected the scheduled task role to be allowed to pass %s, got %s", executionRole, iamClient.rolePolicy)
+			}
+
+			wantParams := &eventbridge.EcsParameters{
+				TaskDefinitionArn: aws.String(tt.want.TaskDefinitionArn),
+				TaskCount:         aws.Int64(tt.want.TaskCount),
+				LaunchType:        aws.String("FARGATE"),
+				NetworkConfiguration: &eventbridge.NetworkConfiguration{
+					AwsvpcConfiguration: &eventbridge.AwsVpcConfiguration{
+						AssignPublicIp: aws.String("DISABLED"),
+						SecurityGroups: aws.StringSlice([]string{"sg-1"}),
+						Subnets:        aws.StringSlice([]string{"subnet-1", "subnet-2"}),
+					},
+				},
+				EnableECSManagedTags: aws.Bool(true),
+				PropagateTags:        aws.String("TASK_DEFINITION"),
+			}
+
+			if !reflect.DeepEqual(target.EcsParameters, wantParams) {
+				t.Errorf("expected ecs parameters %s, got %s", wantParams, target.EcsParameters)
+			}
+
+			// the new schedule is listed for the task definition
+			scheduled, err := o.ScheduledTasks(context.TODO(), "cluster0", "loggedapp")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if len(scheduled) != 1 || !reflect.DeepEqual(scheduled[0], tt.want) {
+				t.Errorf("expected scheduled tasks to be [%+v], got %+v", tt.want, scheduled)
+			}
+		})
+	}
+}
+
+// policyAllows returns true if a statement in the policy allows the action on the resource with the condition key and value
+func policyAllows(policy yiam.PolicyDocument, action, resource, key, value string) bool {
+	for _, s := range policy.Statement {
+		if s.Effect != "Allow" || !stringInSlice(action, s.Action) || !stringInSlice(resource, s.Resource) {
+			continue
+		}
+
+		for _, c := range s.Condition {
+			if stringInSlice(value, c[key]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func Test_scheduledTaskPolicy(t *testing.T) {
+	clusterArn := "arn:aws:ecs:us-east-1:1234567890:cluster/cluster0"
+	taskDefs := []string{
+		"arn:aws:ecs:us-east-1:1234567890:task-definition/loggedapp:*",
+		"arn:aws:ecs:us-east-1:1234567890:task-definition/roleapp:*",
+	}
+	roles := []string{
+		"arn:aws:iam::1234567890:role/cluster0-ecsTaskExecution",
+		"arn:aws:iam::1234567890:role/org/super-why/super-why-app",
+	}
+
+	policy := scheduledTaskPolicy(clusterArn, taskDefs, roles)
+
+	for _, td := range taskDefs {
+		if !policyAllows(policy, "ecs:RunTask", td, "ecs:cluster", clusterArn) {
+			t.Errorf("expected RunTask to be allowed for %s in %s", td, clusterArn)
+		}
+	}
+
+	if policyAllows(policy, "ecs:RunTask", "arn:aws:ecs:us-east-1:1234567890:task-definition/otherapp:*", "ecs:cluster", clusterArn) {
+		t.Error("expected RunTask not to be allowed for other task definitions")
+	}
+
+	for _, r := range roles {
+		if !policyAllows(policy, "iam:PassRole", r, "iam:PassedToService", "ecs-tasks.amazonaws.com") {
+			t.Errorf("expected PassRole to be allowed for %s", r)
+		}
+	}
+
+	if !policyAllows(policy, "ecs:TagResource", "arn:aws:ecs:us-east-1:1234567890:task/cluster0/*", "ecs:CreateAction", "RunTask") {
+		t.Error("expected tagging tasks started by RunTask in the cluster to be allowed")
+	}
+
+	// the merged resources are read back from the current policy
+	if got := policyActionResources(policy, "ecs:RunTask"); !reflect.DeepEqual(got, taskDefs) {
+		t.Errorf("expected RunTask resources %v, got %v", taskDefs, got)
+	}
+
+	if got := policyActionResources(policy, "iam:PassRole"); !reflect.DeepEqual(got, roles) {
+		t.Errorf("expected PassRole resources %v, got %v", roles, got)
+	}
+}
+
+func TestOrchestrator_ScheduleTaskLaunchType(t *testing.T) {
+	tests := []struct {
+		name     string
+		family   string
+		strategy []*ecs.CapacityProviderStrategyItem
+		want     *eventbridge.EcsParameters
+		wantCode string
+	}{
+		{
+			name:     "ec2 task definition without a capacity provider strategy",
+			family:   "ec2app",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "capacity provider isn't attached to the cluster",
+			family:   "ec2app",
+			strategy: []*ecs.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: aws.Int64(1)}},
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "ec2 task definition with a capacity provider strategy",
+			family:   "ec2app",
+			strategy: []*ecs.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE"), Base: aws.Int64(1), Weight: aws.Int64(2)}},
+			want: &eventbridge.EcsParameters{
+				TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/ec2app"),
+				TaskCount:         aws.Int64(1),
+				CapacityProviderStrategy: []*eventbridge.CapacityProviderStrategyItem{
+					{CapacityProvider: aws.String("FARGATE"), Base: aws.Int64(1), Weight: aws.Int64(2)},
+				},
+				EnableECSManagedTags: aws.Bool(true),
+				PropagateTags:        aws.String("TASK_DEFINITION"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "", nil, nil, nil, nil, nil, nil)
+			o.DefaultSubnets = []string{"subnet-1", "subnet-2"}
+			o.DefaultSecurityGroups = []string{"sg-1"}
+
+			eb := &mockEBClient{t: t}
+			o.EventBridge.Service = eb
+
+			_, err := o.ScheduleTask(context.TODO(), "cluster1", tt.family, &ScheduledTaskCreateInput{
+				Name:                     "ec2app-nightly",
+				ScheduleExpression:       "rate(1 day)",
+				CapacityProviderStrategy: tt.strategy,
+			})
+			if tt.wantCode != "" {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != tt.wantCode {
+					t.Fatalf("expected error code %s, got %v", tt.wantCode, err)
+				}
+
+				if len(eb.rules) != 0 {
+					t.Errorf("expected no rules to be created, got %d rules", len(eb.rules))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			// without awsvpc networking, no network configuration is passed
+			targets := eb.targets["ec2app-nightly"]
+			if len(targets) != 1 || !reflect.DeepEqual(targets[0].EcsParameters, tt.want) {
+				t.Errorf("expected ecs parameters %s, got %s", tt.want, targets)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_DeleteScheduledTask(t *testing.T) {
+	clusterArn := "arn:aws:ecs:us-east-1:1234567890:cluster/cluster0"
+
+	newMock := func(t *testing.T) *mockEBClient {
+		return &mockEBClient{
+			t: t,
+			rules: []*eventbridge.DescribeRuleOutput{
+				{Name: aws.String("loggedapp-nightly")},
+				{Name: aws.String("webapp-nightly")},
+			},
+			targets: map[string][]*eventbridge.Target{
+				"loggedapp-nightly": {
+					{
+						Id:            aws.String("loggedapp"),
+						Arn:           aws.String(clusterArn),
+						EcsParameters: &eventbridge.EcsParameters{TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp")},
+					},
+				},
+				"webapp-nightly": {
+					{
+						Id:            aws.String("webapp"),
+						Arn:           aws.String(clusterArn),
+						EcsParameters: &eventbridge.EcsParameters{TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/webapp:2")},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("delete schedule", func(t *testing.T) {
+		o := newMockOrchestrator(t, "", nil, nil, nil, nil, nil, nil)
+		eb := newMock(t)
+		o.EventBridge.Service = eb
+
+		if err := o.DeleteScheduledTask(context.TODO(), "cluster0", "loggedapp", "loggedapp-nightly"); err != nil {
+			t.Fatalf("expected nil error, got %s", err)
+		}
+
+		if len(eb.rules) != 1 || aws.StringValue(eb.rules[0].Name) != "webapp-nightly" {
+			t.Errorf("expected only the webapp-nightly rule to remain, got %+v", eb.rules)
+		}
+
+		if _, ok := eb.targets["loggedapp-nightly"]; ok {
+			t.Errorf("expected targets of loggedapp-nightly to be removed, got %+v", eb.targets["loggedapp-nightly"])
+		}
+	})
+
+	t.Run("schedule for another task definition", func(t *testing.T) {
+		o := newMockOrchestrator(t, "", nil, nil, nil, nil, nil, nil)
+		eb := newMock(t)
+		o.EventBridge.Service = eb
+
+		err := o.DeleteScheduledTask(context.TODO(), "cluster0", "loggedapp", "webapp-nightly")
+		if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+			t.Errorf("expected not found error, got %v", err)
+		}
+
+		if len(eb.rules) != 2 {
+			t.Errorf("expected no rules to be deleted, got %+v", eb.rules)
+		}
+	})
+
+	t.Run("delete all schedules", func(t *testing.T) {
+		o := newMockOrchestrator(t, "", nil, nil, nil, nil, nil, nil)
+		eb := newMock(t)
+		o.EventBridge.Service = eb
+
+		if err := o.deleteScheduledTasks(context.TODO(), "cluster0", "webapp"); err != nil {
+			t.Fatalf("expected nil error, got %s", err)
+		}
+
+		if len(eb.rules) != 1 || aws.StringValue(eb.rules[0].Name) != "loggedapp-nightly" {
+			t.Errorf("expected only the loggedapp-nightly rule to remain, got %+v", eb.rules)
+		}
+	})
+}
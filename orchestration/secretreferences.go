@@ -0,0 +1,83 @@
+package orchestration
+
+import (
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SecretReferences caches the secrets referenced by task definition revisions, keyed by the family (the revision arn
+// without the revision number) and the revision arn.  A registered revision can't be changed, so the references of a
+// revision never expire, the revisions that are no longer listed in their family are dropped.  It's shared by all of
+// the orchestrators of an api server and a nil cache never caches.
+type SecretReferences struct {
+	mu       sync.Mutex
+	families map[string]map[string][]string
+}
+
+// NewSecretReferences returns an empty secret references cache
+func NewSecretReferences() *SecretReferences {
+	return &SecretReferences{families: map[string]map[string][]string{}}
+}
+
+// revisionFamily returns the family part of a task definition revision arn
+func revisionFamily(revision string) string {
+	if i := strings.LastIndex(revision, ":"); i >= 0 {
+		return revision[:i]
+	}
+	return revision
+}
+
+// get returns the cached secrets referenced by the revision
+func (c *SecretReferences) get(revision string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	refs, ok := c.families[revisionFamily(revision)][revision]
+	return refs, ok
+}
+
+// set caches the secrets referenced by the revision
+func (c *SecretReferences) set(revision string, refs []string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	family := revisionFamily(revision)
+	if _, ok := c.families[family]; !ok {
+		c.families[family] = map[string][]string{}
+	}
+	c.families[family][revision] = refs
+}
+
+// prune drops the cached revisions of the families of the listed revisions that aren't listed (ie. deleted revisions)
+func (c *SecretReferences) prune(revisions map[string]struct{}) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	families := map[string]struct{}{}
+	for revision := range revisions {
+		families[revisionFamily(revision)] = struct{}{}
+	}
+
+	for family := range families {
+		for revision := range c.families[family] {
+			if _, ok := revisions[revision]; !ok {
+				log.Debugf("dropping cached secret references of task definition %s", revision)
+				delete(c.families[family], revision)
+			}
+		}
+	}
+}
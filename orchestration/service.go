@@ -3,7 +3,9 @@ package orchestration
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
 
 	"github.com/aws/aws-sdk-go/service/ecs"
@@ -19,17 +21,48 @@ func (o *Orchestrator) processService(ctx context.Context, input *ServiceOrchest
 	}
 
 	if input.Service.NetworkConfiguration == nil {
-		input.Service.NetworkConfiguration = &ecs.NetworkConfiguration{
-			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
-				AssignPublicIp: aws.String(o.DefaultPublic),
-				SecurityGroups: aws.StringSlice(o.DefaultSecurityGroups),
-				Subnets:        aws.StringSlice(o.DefaultSubnets),
-			},
+		nc, err := o.defaultNetworkConfiguration(input.NetworkSelection)
+		if err != nil {
+			return nil, rbfunc, err
 		}
+		input.Service.NetworkConfiguration = nc
+	} else if input.NetworkSelection != nil {
+		return nil, rbfunc, apierror.New(apierror.ErrBadRequest, "network configuration and network selection cannot both be passed", nil)
+	}
+
+	if input.Service.EnableECSManagedTags == nil {
+		input.Service.EnableECSManagedTags = DefaultEnableECSManagedTags
 	}
 
 	if input.Service.PropagateTags == nil {
-		input.Service.PropagateTags = aws.String("SERVICE")
+		input.Service.PropagateTags = DefaultServicePropagateTags
+		if o.ServicePropagateTags != "" {
+			input.Service.PropagateTags = aws.String(o.ServicePropagateTags)
+		}
+	}
+
+	if err := validatePropagateTags(input.Service.PropagateTags, ecs.PropagateTags_Values()); err != nil {
+		return nil, rbfunc, err
+	}
+
+	if input.Service.SchedulingStrategy == nil {
+		input.Service.SchedulingStrategy = DefaultSchedulingStrategy
+	}
+
+	if err := validateSchedulingStrategy(input.Service); err != nil {
+		return nil, rbfunc, err
+	}
+
+	if err := validateDeploymentController(input.Service); err != nil {
+		return nil, rbfunc, err
+	}
+
+	if err := validateDeploymentConfiguration(input.Service.DeploymentConfiguration, input.Service.DeploymentController); err != nil {
+		return nil, rbfunc, err
+	}
+
+	if err := o.processDeploymentAlarms(ctx, input.Service.DeploymentConfiguration); err != nil {
+		return nil, rbfunc, err
 	}
 
 	ecsTags := make([]*ecs.Tag, len(input.Tags))
@@ -71,6 +104,15 @@ func (o *Orchestrator) processServiceUpdate(ctx context.Context, input *ServiceO
 		u := input.Service
 		u.Cluster = active.Service.ClusterArn
 		u.Service = active.Service.ServiceArn
+
+		if err := validateDeploymentConfiguration(u.DeploymentConfiguration, active.Service.DeploymentController); err != nil {
+			return err
+		}
+
+		if err := o.processDeploymentAlarms(ctx, u.DeploymentConfiguration); err != nil {
+			return err
+		}
+
 		if u.NetworkConfiguration != nil && u.NetworkConfiguration.AwsvpcConfiguration != nil {
 			subnets := active.Service.NetworkConfiguration.AwsvpcConfiguration.Subnets
 			if u.NetworkConfiguration.AwsvpcConfiguration.Subnets != nil {
@@ -115,3 +157,154 @@ func (o *Orchestrator) processServiceUpdate(ctx context.Context, input *ServiceO
 
 	return nil
 }
+
+// validateDeploymentConfiguration validates the maximum and minimum healthy percent of a deployment configuration.  Unset
+// values are left to the ECS defaults (200 and 100).  The limits only apply to the rolling update (ECS) deployment controller.
+func validateDeploymentConfiguration(dc *ecs.DeploymentConfiguration, controller *ecs.DeploymentController) error {
+	if dc == nil {
+		return nil
+	}
+
+	if controller != nil && aws.StringValue(controller.Type) != ecs.DeploymentControllerTypeEcs {
+		return nil
+	}
+
+	if dc.MinimumHealthyPercent != nil {
+		if min := aws.Int64Value(dc.MinimumHealthyPercent); min < 0 || min > 100 {
+			msg := fmt.Sprintf("minimum healthy percent must be between 0 and 100, got %d", min)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	if dc.MaximumPercent != nil {
+		if max := aws.Int64Value(dc.MaximumPercent); max < 100 {
+			msg := fmt.Sprintf("maximum percent must be at least 100 for rolling deployments, got %d", max)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	return nil
+}
+
+// validateSchedulingStrategy validates the scheduling strategy of a service.  The DAEMON strategy places one task on each
+// container instance, so it requires the EC2 (or EXTERNAL) launch type and cannot be used with a desired count or a
+// capacity provider strategy.
+func validateSchedulingStrategy(input *ecs.CreateServiceInput) error {
+	strategy := aws.StringValue(input.SchedulingStrategy)
+	switch strategy {
+	case ecs.SchedulingStrategyReplica:
+		return nil
+	case ecs.SchedulingStrategyDaemon:
+	default:
+		msg := fmt.Sprintf("invalid scheduling strategy '%s', must be one of %s", strategy, strings.Join(ecs.SchedulingStrategy_Values(), ", "))
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	if input.DesiredCount != nil {
+		return apierror.New(apierror.ErrBadRequest, "desired count cannot be set with the DAEMON scheduling strategy", nil)
+	}
+
+	if len(input.CapacityProviderStrategy) > 0 {
+		return apierror.New(apierror.ErrBadRequest, "capacity provider strategy cannot be set with the DAEMON scheduling strategy", nil)
+	}
+
+	if lt := aws.StringValue(input.LaunchType); lt != ecs.LaunchTypeEc2 && lt != ecs.LaunchTypeExternal {
+		msg := fmt.Sprintf("the DAEMON scheduling strategy requires the %s or %s launch type, got '%s'", ecs.LaunchTypeEc2, ecs.LaunchTypeExternal, lt)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	return nil
+}
+
+// validateDeploymentController validates the deployment controller of a service, services without one use the rolling
+// update (ECS) controller.  The deployment circuit breaker and alarms only apply to rolling updates, blue/green deployments
+// (CODE_DEPLOY) require a load balancer and neither CODE_DEPLOY nor EXTERNAL support the DAEMON scheduling strategy.
+func validateDeploymentController(input *ecs.CreateServiceInput) error {
+	if input.DeploymentController == nil {
+		return nil
+	}
+
+	controller := aws.StringValue(input.DeploymentController.Type)
+	switch controller {
+	case ecs.DeploymentControllerTypeEcs:
+		return nil
+	case ecs.DeploymentControllerTypeCodeDeploy, ecs.DeploymentControllerTypeExternal:
+	default:
+		msg := fmt.Sprintf("invalid deployment controller type '%s', must be one of %s", controller, strings.Join(ecs.DeploymentControllerType_Values(), ", "))
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	if dc := input.DeploymentConfiguration; dc != nil {
+		if dc.DeploymentCircuitBreaker != nil {
+			msg := fmt.Sprintf("deployment circuit breaker cannot be set with the %s deployment controller", controller)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if dc.Alarms != nil {
+			msg := fmt.Sprintf("deployment alarms cannot be set with the %s deployment controller", controller)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	if aws.StringValue(input.SchedulingStrategy) == ecs.SchedulingStrategyDaemon {
+		msg := fmt.Sprintf("the DAEMON scheduling strategy cannot be used with the %s deployment controller", controller)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	if controller == ecs.DeploymentControllerTypeCodeDeploy && len(input.LoadBalancers) == 0 {
+		return apierror.New(apierror.ErrBadRequest, "the CODE_DEPLOY deployment controller requires a load balancer", nil)
+	}
+
+	return nil
+}
+
+// processDeploymentAlarms defaults the enable and rollback flags of the deployment alarms to true and ensures each
+// alarm exists in cloudwatch.  Alarm names can only be omitted when the alarms are explicitly disabled.
+func (o *Orchestrator) processDeploymentAlarms(ctx context.Context, dc *ecs.DeploymentConfiguration) error {
+	if dc == nil || dc.Alarms == nil {
+		return nil
+	}
+
+	alarms := dc.Alarms
+	if alarms.Enable == nil {
+		alarms.Enable = aws.Bool(true)
+	}
+
+	if alarms.Rollback == nil {
+		alarms.Rollback = aws.Bool(true)
+	}
+
+	names := aws.StringValueSlice(alarms.AlarmNames)
+	if len(names) == 0 {
+		if aws.BoolValue(alarms.Enable) {
+			return apierror.New(apierror.ErrBadRequest, "deployment alarms require at least one alarm name", nil)
+		}
+
+		alarms.AlarmNames = []*string{}
+		return nil
+	}
+
+	existing, err := o.CloudWatch.ListAlarmNames(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]struct{}, len(existing))
+	for _, e := range existing {
+		found[e] = struct{}{}
+	}
+
+	missing := []string{}
+	for _, n := range names {
+		if _, ok := found[n]; !ok {
+			missing = append(missing, n)
+		}
+	}
+
+	if len(missing) > 0 {
+		msg := fmt.Sprintf("deployment alarms not found: %s", strings.Join(missing, ", "))
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	return nil
+}
@@ -0,0 +1,1895 @@
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+)
+
+func (m *mockECSClient) CreateServiceWithContext(ctx aws.Context, input *ecs.CreateServiceInput, opts ...request.Option) (*ecs.CreateServiceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &ecs.CreateServiceOutput{
+		Service: &ecs.Service{
+			ClusterArn:              aws.String("arn:aws:ecs:us-east-1:0123456789:cluster/" + aws.StringValue(input.Cluster)),
+			DeploymentConfiguration: input.DeploymentConfiguration,
+			DeploymentController:    input.DeploymentController,
+			DesiredCount:            input.DesiredCount,
+			EnableECSManagedTags:    input.EnableECSManagedTags,
+			LaunchType:              input.LaunchType,
+			NetworkConfiguration:    input.NetworkConfiguration,
+			PropagateTags:           input.PropagateTags,
+			SchedulingStrategy:      input.SchedulingStrategy,
+			ServiceArn:              aws.String("arn:aws:ecs:us-east-1:0123456789:service/" + aws.StringValue(input.ServiceName)),
+			ServiceName:             input.ServiceName,
+			Tags:                    input.Tags,
+			TaskDefinition:          input.TaskDefinition,
+		},
+	}, nil
+}
+
+func (m *mockECSClient) UpdateServiceWithContext(ctx aws.Context, input *ecs.UpdateServiceInput, opts ...request.Option) (*ecs.UpdateServiceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	service := aws.StringValue(input.Service)
+	if i := strings.LastIndex(service, "/"); i >= 0 {
+		service = service[i+1:]
+	}
+
+	return &ecs.UpdateServiceOutput{
+		Service: &ecs.Service{
+			ClusterArn:              input.Cluster,
+			DeploymentConfiguration: input.DeploymentConfiguration,
+			Deployments:             testServiceUpdateDeployments[service],
+			DesiredCount:            input.DesiredCount,
+			ServiceArn:              input.Service,
+			TaskDefinition:          input.TaskDefinition,
+		},
+	}, nil
+}
+
+// testServiceUpdateDeployments maps test service names to the deployments returned when they're updated
+var testServiceUpdateDeployments = map[string][]*ecs.Deployment{
+	"rolledback": {
+		{
+			Id:             aws.String("ecs-svc/0000000000000000012"),
+			RolloutState:   aws.String("IN_PROGRESS"),
+			Status:         aws.String("PRIMARY"),
+			TaskDefinition: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:2"),
+		},
+	},
+	"rolledout": {
+		{
+			Id:             aws.String("ecs-svc/0000000000000000014"),
+			RolloutState:   aws.String("IN_PROGRESS"),
+			Status:         aws.String("PRIMARY"),
+			TaskDefinition: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:2"),
+		},
+	},
+}
+
+// testServiceTaskDefinitions maps test service names to their task definitions
+var testServiceTaskDefinitions = map[string]string{
+	"logged":     "loggedapp:1",
+	"creds":      "credsapp:1",
+	"rolledback": "loggedapp:1",
+	"rolledout":  "loggedapp:1",
+	"behind":     "releasedapp:1",
+	"deployed":   "arn:aws:ecs:us-east-1:0123456789:task-definition/deployedapp:1",
+	"clone":      "arn:aws:ecs:us-east-1:0123456789:task-definition/sharedapp:1",
+	"rekey":      "rekeyapp:1",
+}
+
+// testServiceRegistryArns maps test service names to the service discovery services they're registered with
+var testServiceRegistryArns = map[string][]string{
+	"registered":    {"arn:aws:servicediscovery:us-east-1:1234567890:service/srv-0123456789"},
+	"staleregistry": {"arn:aws:servicediscovery:us-east-1:1234567890:service/srv-missing"},
+	"inuseregistry": {"arn:aws:servicediscovery:us-east-1:1234567890:service/srv-inuse"},
+}
+
+var testServiceDeployments = map[string][]*ecs.Deployment{
+	"inuseregistry": {
+		{
+			DesiredCount: aws.Int64(1),
+			Id:           aws.String("ecs-svc/0000000000000000020"),
+			RolloutState: aws.String("COMPLETED"),
+			RunningCount: aws.Int64(1),
+			Status:       aws.String("PRIMARY"),
+		},
+	},
+	"rekey": {
+		{
+			DesiredCount: aws.Int64(1),
+			Id:           aws.String("ecs-svc/0000000000000000021"),
+			RolloutState: aws.String("COMPLETED"),
+			RunningCount: aws.Int64(1),
+			Status:       aws.String("PRIMARY"),
+		},
+	},
+	"clone": {
+		{
+			DesiredCount: aws.Int64(1),
+			Id:           aws.String("ecs-svc/0000000000000000019"),
+			RolloutState: aws.String("COMPLETED"),
+			RunningCount: aws.Int64(1),
+			Status:       aws.String("PRIMARY"),
+		},
+	},
+	"deployed": {
+		{
+			DesiredCount:   aws.Int64(1),
+			Id:             aws.String("ecs-svc/0000000000000000018"),
+			RolloutState:   aws.String("COMPLETED"),
+			RunningCount:   aws.Int64(1),
+			Status:         aws.String("PRIMARY"),
+			TaskDefinition: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/deployedapp:1"),
+		},
+	},
+	"logged": {
+		{
+			DesiredCount: aws.Int64(1),
+			Id:           aws.String("ecs-svc/0000000000000000009"),
+			RolloutState: aws.String("COMPLETED"),
+			RunningCount: aws.Int64(1),
+			Status:       aws.String("PRIMARY"),
+		},
+	},
+	"creds": {
+		{
+			DesiredCount: aws.Int64(1),
+			Id:           aws.String("ecs-svc/0000000000000000010"),
+			RolloutState: aws.String("COMPLETED"),
+			RunningCount: aws.Int64(1),
+			Status:       aws.String("PRIMARY"),
+		},
+	},
+	"registered": {
+		{
+			DesiredCount: aws.Int64(1),
+			Id:           aws.String("ecs-svc/0000000000000000016"),
+			RolloutState: aws.String("COMPLETED"),
+			RunningCount: aws.Int64(1),
+			Status:       aws.String("PRIMARY"),
+		},
+	},
+	"staleregistry": {
+		{
+			DesiredCount: aws.Int64(1),
+			Id:           aws.String("ecs-svc/0000000000000000017"),
+			RolloutState: aws.String("COMPLETED"),
+			RunningCount: aws.Int64(1),
+			Status:       aws.String("PRIMARY"),
+		},
+	},
+	"behind": {
+		{
+			DesiredCount: aws.Int64(1),
+			Id:           aws.String("ecs-svc/0000000000000000015"),
+			RolloutState: aws.String("COMPLETED"),
+			RunningCount: aws.Int64(1),
+			Status:       aws.String("PRIMARY"),
+		},
+	},
+	"stable": {
+		{
+			DesiredCount: aws.Int64(2),
+			Id:           aws.String("ecs-svc/0000000000000000001"),
+			PendingCount: aws.Int64(0),
+			RolloutState: aws.String("COMPLETED"),
+			RunningCount: aws.Int64(2),
+			Status:       aws.String("PRIMARY"),
+		},
+	},
+	"rolledback": {
+		{
+			DesiredCount:   aws.Int64(1),
+			Id:             aws.String("ecs-svc/0000000000000000013"),
+			RolloutState:   aws.String("IN_PROGRESS"),
+			RunningCount:   aws.Int64(0),
+			Status:         aws.String("PRIMARY"),
+			TaskDefinition: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:1"),
+		},
+		{
+			DesiredCount:       aws.Int64(1),
+			FailedTasks:        aws.Int64(3),
+			Id:                 aws.String("ecs-svc/0000000000000000012"),
+			RolloutState:       aws.String("FAILED"),
+			RolloutStateReason: aws.String("ECS deployment circuit breaker: tasks failed to start."),
+			RunningCount:       aws.Int64(0),
+			Status:             aws.String("ACTIVE"),
+			TaskDefinition:     aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:2"),
+		},
+	},
+	"rolledout": {
+		{
+			DesiredCount:       aws.Int64(1),
+			Id:                 aws.String("ecs-svc/0000000000000000014"),
+			RolloutState:       aws.String("COMPLETED"),
+			RolloutStateReason: aws.String("ECS deployment ecs-svc/0000000000000000014 completed."),
+			RunningCount:       aws.Int64(1),
+			Status:             aws.String("PRIMARY"),
+			TaskDefinition:     aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:2"),
+		},
+	},
+	"inprogress": {
+		{
+			DesiredCount: aws.Int64(2),
+			FailedTasks:  aws.Int64(1),
+			Id:           aws.String("ecs-svc/0000000000000000002"),
+			PendingCount: aws.Int64(1),
+			RolloutState: aws.String("IN_PROGRESS"),
+			RunningCount: aws.Int64(1),
+			Status:       aws.String("PRIMARY"),
+		},
+		{
+			DesiredCount: aws.Int64(2),
+			Id:           aws.String("ecs-svc/0000000000000000001"),
+			PendingCount: aws.Int64(0),
+			RolloutState: aws.String("COMPLETED"),
+			RunningCount: aws.Int64(2),
+			Status:       aws.String("ACTIVE"),
+		},
+	},
+}
+
+func (m *mockECSClient) DescribeServicesWithContext(ctx aws.Context, input *ecs.DescribeServicesInput, opts ...request.Option) (*ecs.DescribeServicesOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if m.describeDelay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+		case <-time.After(m.describeDelay):
+		}
+	}
+
+	output := &ecs.DescribeServicesOutput{}
+	for _, name := range input.Services {
+		deployments, ok := testServiceDeployments[aws.StringValue(name)]
+		if !ok {
+			output.Failures = append(output.Failures, &ecs.Failure{
+				Arn:    name,
+				Reason: aws.String("MISSING"),
+			})
+			continue
+		}
+
+		svc := &ecs.Service{
+			ClusterArn:  aws.String("arn:aws:ecs:us-east-1:0123456789:cluster/" + aws.StringValue(input.Cluster)),
+			Deployments: deployments,
+			ServiceArn:  aws.String("arn:aws:ecs:us-east-1:0123456789:service/" + aws.StringValue(name)),
+			ServiceName: name,
+			Status:      aws.String("ACTIVE"),
+		}
+
+		if td, ok := testServiceTaskDefinitions[aws.StringValue(name)]; ok {
+			svc.TaskDefinition = aws.String(td)
+		}
+
+		for _, r := range testServiceRegistryArns[aws.StringValue(name)] {
+			svc.ServiceRegistries = append(svc.ServiceRegistries, &ecs.ServiceRegistry{RegistryArn: aws.String(r)})
+		}
+
+		output.Services = append(output.Services, svc)
+	}
+
+	return output, nil
+}
+
+func TestOrchestrator_ServiceDeploymentStatus(t *testing.T) {
+	t.Log("testing ServiceDeploymentStatus")
+
+	interval := DefaultDeploymentStatusPollInterval
+	DefaultDeploymentStatusPollInterval = 10 * time.Millisecond
+	defer func() { DefaultDeploymentStatusPollInterval = interval }()
+
+	tests := []struct {
+		name    string
+		service string
+		wait    time.Duration
+		ecserr  error
+		want    *ServiceDeploymentStatusOutput
+		wantErr bool
+	}{
+		{
+			name:    "stable deployment",
+			service: "stable",
+			want: &ServiceDeploymentStatusOutput{
+				Deployments: testServiceDeployments["stable"],
+				Stable:      true,
+			},
+		},
+		{
+			name:    "in progress deployment",
+			service: "inprogress",
+			want: &ServiceDeploymentStatusOutput{
+				Deployments: testServiceDeployments["inprogress"],
+				Stable:      false,
+			},
+		},
+		{
+			name:    "in progress deployment, wait times out",
+			service: "inprogress",
+			wait:    50 * time.Millisecond,
+			want: &ServiceDeploymentStatusOutput{
+				Deployments: testServiceDeployments["inprogress"],
+				Stable:      false,
+			},
+		},
+		{
+			name:    "missing service",
+			service: "missing",
+			wantErr: true,
+		},
+		{
+			name:    "ecs error",
+			service: "stable",
+			ecserr:  awserr.New(ecs.ErrCodeServerException, "boom", nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, tt.ecserr, nil, nil, nil, nil)
+			got, err := o.ServiceDeploymentStatus(context.TODO(), "clu1", tt.service, tt.wait)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.ServiceDeploymentStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.ServiceDeploymentStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ServiceDeploymentStatusWaitBound(t *testing.T) {
+	interval := DefaultDeploymentStatusPollInterval
+	DefaultDeploymentStatusPollInterval = 20 * time.Millisecond
+	defer func() { DefaultDeploymentStatusPollInterval = interval }()
+
+	wait := 150 * time.Millisecond
+	tests := []struct {
+		name          string
+		describeDelay time.Duration
+	}{
+		{
+			name: "fast polls",
+		},
+		{
+			name:          "slow polls",
+			describeDelay: 40 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.ECS.Service.(*mockECSClient).describeDelay = tt.describeDelay
+
+			start := time.Now()
+			got, err := o.ServiceDeploymentStatus(context.TODO(), "clu1", "inprogress", wait)
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if got.Stable {
+				t.Error("expected the in progress deployment not to be stable")
+			}
+
+			if elapsed < wait {
+				t.Errorf("expected to poll for the wait time %s, returned after %s", wait, elapsed)
+			}
+
+			// polling stops at the wait time, even in the middle of a call, with some slack for the scheduler
+			if limit := wait + 50*time.Millisecond; elapsed > limit {
+				t.Errorf("expected to return within %s, returned after %s", limit, elapsed)
+			}
+		})
+	}
+}
+
+func (m *mockECSClient) DeleteServiceWithContext(ctx aws.Context, input *ecs.DeleteServiceInput, opts ...request.Option) (*ecs.DeleteServiceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &ecs.DeleteServiceOutput{
+		Service: &ecs.Service{
+			ServiceName: input.Service,
+			Status:      aws.String("DRAINING"),
+		},
+	}, nil
+}
+
+func TestOrchestrator_DeleteServiceCleanupServiceRegistry(t *testing.T) {
+	t.Log("testing DeleteService service registry cleanup")
+
+	registryArn := "arn:aws:servicediscovery:us-east-1:1234567890:service/srv-0123456789"
+
+	tests := []struct {
+		name             string
+		service          string
+		cleanup          bool
+		sderr            error
+		want             []*DependencyDeleteOutput
+		wantDeregistered []string
+		wantDeleted      []string
+	}{
+		{
+			name:    "non-recursive delete retains the service registry by default",
+			service: "registered",
+		},
+		{
+			name:    "non-recursive delete cleans up the service registry",
+			service: "registered",
+			cleanup: true,
+			want: []*DependencyDeleteOutput{
+				{Type: "serviceregistry", Resource: registryArn, Status: DependencyDeleted},
+			},
+			wantDeregistered: []string{"0a1b2c3d4e5f", "6a7b8c9d0e1f"},
+			wantDeleted:      []string{"srv-0123456789"},
+		},
+		{
+			name:    "missing service registry is retained",
+			service: "staleregistry",
+			cleanup: true,
+			want: []*DependencyDeleteOutput{
+				{Type: "serviceregistry", Resource: "arn:aws:servicediscovery:us-east-1:1234567890:service/srv-missing", Status: DependencyRetained},
+			},
+		},
+		{
+			name:    "servicediscovery error",
+			service: "registered",
+			cleanup: true,
+			sderr:   awserr.New(servicediscovery.ErrCodeInvalidInput, "boom", nil),
+			want: []*DependencyDeleteOutput{
+				{Type: "serviceregistry", Resource: registryArn, Status: DependencyDeleteFailed},
+			},
+		},
+		{
+			name:    "service without a service registry",
+			service: "stable",
+			cleanup: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, tt.sderr)
+			o.DeleteTimeout = 50 * time.Millisecond
+
+			out, err := o.DeleteService(context.TODO(), &ServiceDeleteInput{
+				Cluster:                aws.String("clu1"),
+				Service:                aws.String(tt.service),
+				CleanupServiceRegistry: tt.cleanup,
+			})
+			if err != nil {
+				t.Fatalf("Orchestrator.DeleteService() unexpected error = %v", err)
+			}
+
+			if len(out.Dependencies) != len(tt.want) {
+				t.Fatalf("expected %d dependency outcomes, got %d: %+v", len(tt.want), len(out.Dependencies), out.Dependencies)
+			}
+
+			for i, w := range tt.want {
+				g := out.Dependencies[i]
+				if g.Type != w.Type || g.Resource != w.Resource || g.Status != w.Status {
+					t.Errorf("expected dependency outcome %+v, got %+v", w, g)
+				}
+
+				if (w.Status == DependencyDeleteFailed) != (g.Error != "") {
+					t.Errorf("unexpected error %q for dependency %s with status %s", g.Error, g.Resource, g.Status)
+				}
+			}
+
+			sd := o.ServiceDiscovery.Service.(*mockSDClient)
+			if !reflect.DeepEqual(sd.deregistered, tt.wantDeregistered) {
+				t.Errorf("expected deregistered instances %v, got %v", tt.wantDeregistered, sd.deregistered)
+			}
+
+			if !reflect.DeepEqual(sd.deleted, tt.wantDeleted) {
+				t.Errorf("expected deleted service registries %v, got %v", tt.wantDeleted, sd.deleted)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ServiceRevisionStatus(t *testing.T) {
+	t.Log("testing ServiceRevisionStatus")
+
+	tests := []struct {
+		name    string
+		service string
+		ecserr  error
+		want    *ServiceRevisionStatusOutput
+		wantErr bool
+	}{
+		{
+			name:    "up to date service",
+			service: "logged",
+			want: &ServiceRevisionStatusOutput{
+				Family:               "loggedapp",
+				TaskDefinition:       "arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:1",
+				Revision:             1,
+				LatestTaskDefinition: "arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:1",
+				LatestRevision:       1,
+				UpToDate:             true,
+				RevisionsBehind:      0,
+			},
+		},
+		{
+			name:    "service several revisions behind",
+			service: "behind",
+			want: &ServiceRevisionStatusOutput{
+				Family:               "releasedapp",
+				TaskDefinition:       "arn:aws:ecs:us-east-1:0123456789:task-definition/releasedapp:1",
+				Revision:             1,
+				LatestTaskDefinition: "arn:aws:ecs:us-east-1:0123456789:task-definition/releasedapp:5",
+				LatestRevision:       5,
+				UpToDate:             false,
+				RevisionsBehind:      3,
+			},
+		},
+		{
+			name:    "missing service",
+			service: "missing",
+			wantErr: true,
+		},
+		{
+			name:    "ecs error",
+			service: "logged",
+			ecserr:  awserr.New(ecs.ErrCodeServerException, "boom", nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, tt.ecserr, nil, nil, nil, nil)
+			got, err := o.ServiceRevisionStatus(context.TODO(), "clu1", tt.service)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.ServiceRevisionStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.ServiceRevisionStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processService(t *testing.T) {
+	t.Log("testing processService")
+
+	tests := []struct {
+		name                     string
+		ecserr                   error
+		servicePropagateTags     string
+		service                  *ecs.CreateServiceInput
+		wantEnableECSManagedTags bool
+		wantPropagateTags        string
+		wantErr                  bool
+	}{
+		{
+			name: "defaults",
+			service: &ecs.CreateServiceInput{
+				Cluster:     aws.String("clu1"),
+				ServiceName: aws.String("svc1"),
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "SERVICE",
+		},
+		{
+			name:                 "configured propagate tags",
+			servicePropagateTags: "TASK_DEFINITION",
+			service: &ecs.CreateServiceInput{
+				Cluster:     aws.String("clu1"),
+				ServiceName: aws.String("svc1"),
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "TASK_DEFINITION",
+		},
+		{
+			name: "caller overrides",
+			service: &ecs.CreateServiceInput{
+				Cluster:              aws.String("clu1"),
+				ServiceName:          aws.String("svc1"),
+				EnableECSManagedTags: aws.Bool(false),
+				PropagateTags:        aws.String("NONE"),
+			},
+			wantEnableECSManagedTags: false,
+			wantPropagateTags:        "NONE",
+		},
+		{
+			name: "invalid propagate tags",
+			service: &ecs.CreateServiceInput{
+				Cluster:       aws.String("clu1"),
+				ServiceName:   aws.String("svc1"),
+				PropagateTags: aws.String("EVERYWHERE"),
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "EVERYWHERE",
+			wantErr:                  true,
+		},
+		{
+			name:   "ecs error",
+			ecserr: awserr.New(ecs.ErrCodeServerException, "boom", nil),
+			service: &ecs.CreateServiceInput{
+				Cluster:     aws.String("clu1"),
+				ServiceName: aws.String("svc1"),
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "SERVICE",
+			wantErr:                  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, tt.ecserr, nil, nil, nil, nil)
+			o.ServicePropagateTags = tt.servicePropagateTags
+			input := &ServiceOrchestrationInput{
+				Service: tt.service,
+				Tags: []*Tag{
+					{Key: aws.String("spinup:org"), Value: aws.String("myorg")},
+				},
+			}
+
+			got, _, err := o.processService(context.TODO(), input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.processService() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if aws.BoolValue(input.Service.EnableECSManagedTags) != tt.wantEnableECSManagedTags {
+				t.Errorf("expected create input EnableECSManagedTags %t, got %t", tt.wantEnableECSManagedTags, aws.BoolValue(input.Service.EnableECSManagedTags))
+			}
+
+			if aws.StringValue(input.Service.PropagateTags) != tt.wantPropagateTags {
+				t.Errorf("expected create input PropagateTags %s, got %s", tt.wantPropagateTags, aws.StringValue(input.Service.PropagateTags))
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if aws.BoolValue(got.EnableECSManagedTags) != tt.wantEnableECSManagedTags {
+				t.Errorf("expected service EnableECSManagedTags %t, got %t", tt.wantEnableECSManagedTags, aws.BoolValue(got.EnableECSManagedTags))
+			}
+
+			if aws.StringValue(got.PropagateTags) != tt.wantPropagateTags {
+				t.Errorf("expected service PropagateTags %s, got %s", tt.wantPropagateTags, aws.StringValue(got.PropagateTags))
+			}
+
+			if len(got.Tags) != 1 {
+				t.Errorf("expected 1 service tag, got %d", len(got.Tags))
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processServiceDeploymentConfiguration(t *testing.T) {
+	tests := []struct {
+		name    string
+		dc      *ecs.DeploymentConfiguration
+		wantErr bool
+	}{
+		{
+			name: "ecs defaults",
+		},
+		{
+			name: "zero downtime",
+			dc: &ecs.DeploymentConfiguration{
+				MaximumPercent:        aws.Int64(200),
+				MinimumHealthyPercent: aws.Int64(100),
+			},
+		},
+		{
+			name: "in place",
+			dc: &ecs.DeploymentConfiguration{
+				MaximumPercent:        aws.Int64(100),
+				MinimumHealthyPercent: aws.Int64(0),
+			},
+		},
+		{
+			name: "only minimum healthy percent",
+			dc: &ecs.DeploymentConfiguration{
+				MinimumHealthyPercent: aws.Int64(50),
+			},
+		},
+		{
+			name: "minimum healthy percent over 100",
+			dc: &ecs.DeploymentConfiguration{
+				MaximumPercent:        aws.Int64(200),
+				MinimumHealthyPercent: aws.Int64(150),
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative minimum healthy percent",
+			dc: &ecs.DeploymentConfiguration{
+				MinimumHealthyPercent: aws.Int64(-1),
+			},
+			wantErr: true,
+		},
+		{
+			name: "maximum percent under 100",
+			dc: &ecs.DeploymentConfiguration{
+				MaximumPercent:        aws.Int64(50),
+				MinimumHealthyPercent: aws.Int64(0),
+			},
+			wantErr: true,
+		},
+		{
+			name: "deployment alarms",
+			dc: &ecs.DeploymentConfiguration{
+				Alarms: &ecs.DeploymentAlarms{
+					AlarmNames: aws.StringSlice([]string{"svc1-5xx", "svc1-latency"}),
+					Enable:     aws.Bool(true),
+					Rollback:   aws.Bool(true),
+				},
+			},
+		},
+		{
+			name: "nonexistent deployment alarm",
+			dc: &ecs.DeploymentConfiguration{
+				Alarms: &ecs.DeploymentAlarms{
+					AlarmNames: aws.StringSlice([]string{"svc1-5xx", "svc1-missing"}),
+					Enable:     aws.Bool(true),
+					Rollback:   aws.Bool(true),
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" create", func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+			got, _, err := o.processService(context.TODO(), &ServiceOrchestrationInput{
+				Service: &ecs.CreateServiceInput{
+					Cluster:                 aws.String("clu1"),
+					ServiceName:             aws.String("svc1"),
+					DeploymentConfiguration: tt.dc,
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.processService() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got.DeploymentConfiguration, tt.dc) {
+				t.Errorf("expected deployment configuration %+v, got %+v", tt.dc, got.DeploymentConfiguration)
+			}
+		})
+
+		t.Run(tt.name+" update", func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+			active := &ServiceOrchestrationUpdateOutput{
+				Service: &ecs.Service{
+					ClusterArn: aws.String("arn:aws:ecs:us-east-1:0123456789:cluster/clu1"),
+					ServiceArn: aws.String("arn:aws:ecs:us-east-1:0123456789:service/clu1/svc1"),
+				},
+			}
+
+			err := o.processServiceUpdate(context.TODO(), &ServiceOrchestrationUpdateInput{
+				Service: &ecs.UpdateServiceInput{
+					DeploymentConfiguration: tt.dc,
+				},
+			}, active)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.processServiceUpdate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(active.Service.DeploymentConfiguration, tt.dc) {
+				t.Errorf("expected deployment configuration %+v, got %+v", tt.dc, active.Service.DeploymentConfiguration)
+			}
+		})
+	}
+
+	t.Run("external deployment controller", func(t *testing.T) {
+		err := validateDeploymentConfiguration(&ecs.DeploymentConfiguration{MaximumPercent: aws.Int64(50)}, &ecs.DeploymentController{Type: aws.String("EXTERNAL")})
+		if err != nil {
+			t.Errorf("expected nil error for external deployment controller, got %s", err)
+		}
+	})
+}
+
+func TestOrchestrator_processServiceSchedulingStrategy(t *testing.T) {
+	tests := []struct {
+		name         string
+		service      *ecs.CreateServiceInput
+		wantStrategy string
+		wantErr      bool
+	}{
+		{
+			name: "default replica",
+			service: &ecs.CreateServiceInput{
+				Cluster:      aws.String("clu1"),
+				ServiceName:  aws.String("svc1"),
+				DesiredCount: aws.Int64(2),
+			},
+			wantStrategy: "REPLICA",
+		},
+		{
+			name: "daemon on ec2",
+			service: &ecs.CreateServiceInput{
+				Cluster:            aws.String("clu1"),
+				ServiceName:        aws.String("svc1"),
+				LaunchType:         aws.String("EC2"),
+				SchedulingStrategy: aws.String("DAEMON"),
+			},
+			wantStrategy: "DAEMON",
+		},
+		{
+			name: "daemon on fargate",
+			service: &ecs.CreateServiceInput{
+				Cluster:            aws.String("clu1"),
+				ServiceName:        aws.String("svc1"),
+				LaunchType:         aws.String("FARGATE"),
+				SchedulingStrategy: aws.String("DAEMON"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "daemon with default launch type",
+			service: &ecs.CreateServiceInput{
+				Cluster:            aws.String("clu1"),
+				ServiceName:        aws.String("svc1"),
+				SchedulingStrategy: aws.String("DAEMON"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "daemon with capacity provider strategy",
+			service: &ecs.CreateServiceInput{
+				Cluster:     aws.String("clu1"),
+				ServiceName: aws.String("svc1"),
+				CapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{
+					{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: aws.Int64(1)},
+				},
+				SchedulingStrategy: aws.String("DAEMON"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "daemon with desired count",
+			service: &ecs.CreateServiceInput{
+				Cluster:            aws.String("clu1"),
+				ServiceName:        aws.String("svc1"),
+				DesiredCount:       aws.Int64(1),
+				LaunchType:         aws.String("EC2"),
+				SchedulingStrategy: aws.String("DAEMON"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid strategy",
+			service: &ecs.CreateServiceInput{
+				Cluster:            aws.String("clu1"),
+				ServiceName:        aws.String("svc1"),
+				SchedulingStrategy: aws.String("SOMETIMES"),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			got, _, err := o.processService(context.TODO(), &ServiceOrchestrationInput{Service: tt.service})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.processService() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+					t.Errorf("expected bad request error, got %v", err)
+				}
+				return
+			}
+
+			if s := aws.StringValue(got.SchedulingStrategy); s != tt.wantStrategy {
+				t.Errorf("expected scheduling strategy %s, got %s", tt.wantStrategy, s)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processServiceDeploymentController(t *testing.T) {
+	loadBalancers := []*ecs.LoadBalancer{
+		{
+			ContainerName:  aws.String("web"),
+			ContainerPort:  aws.Int64(443),
+			TargetGroupArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:0123456789:targetgroup/svc1-blue/0123456789abcdef"),
+		},
+	}
+
+	tests := []struct {
+		name           string
+		service        *ecs.CreateServiceInput
+		wantController string
+		wantErr        bool
+	}{
+		{
+			name: "default controller",
+			service: &ecs.CreateServiceInput{
+				Cluster:     aws.String("clu1"),
+				ServiceName: aws.String("svc1"),
+			},
+		},
+		{
+			name: "code deploy",
+			service: &ecs.CreateServiceInput{
+				Cluster:              aws.String("clu1"),
+				ServiceName:          aws.String("svc1"),
+				DeploymentController: &ecs.DeploymentController{Type: aws.String("CODE_DEPLOY")},
+				DeploymentConfiguration: &ecs.DeploymentConfiguration{
+					MaximumPercent:        aws.Int64(200),
+					MinimumHealthyPercent: aws.Int64(100),
+				},
+				LoadBalancers: loadBalancers,
+			},
+			wantController: "CODE_DEPLOY",
+		},
+		{
+			name: "external",
+			service: &ecs.CreateServiceInput{
+				Cluster:              aws.String("clu1"),
+				ServiceName:          aws.String("svc1"),
+				DeploymentController: &ecs.DeploymentController{Type: aws.String("EXTERNAL")},
+			},
+			wantController: "EXTERNAL",
+		},
+		{
+			name: "code deploy without a load balancer",
+			service: &ecs.CreateServiceInput{
+				Cluster:              aws.String("clu1"),
+				ServiceName:          aws.String("svc1"),
+				DeploymentController: &ecs.DeploymentController{Type: aws.String("CODE_DEPLOY")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "code deploy with a circuit breaker",
+			service: &ecs.CreateServiceInput{
+				Cluster:              aws.String("clu1"),
+				ServiceName:          aws.String("svc1"),
+				DeploymentController: &ecs.DeploymentController{Type: aws.String("CODE_DEPLOY")},
+				DeploymentConfiguration: &ecs.DeploymentConfiguration{
+					DeploymentCircuitBreaker: &ecs.DeploymentCircuitBreaker{Enable: aws.Bool(true), Rollback: aws.Bool(true)},
+				},
+				LoadBalancers: loadBalancers,
+			},
+			wantErr: true,
+		},
+		{
+			name: "external with deployment alarms",
+			service: &ecs.CreateServiceInput{
+				Cluster:              aws.String("clu1"),
+				ServiceName:          aws.String("svc1"),
+				DeploymentController: &ecs.DeploymentController{Type: aws.String("EXTERNAL")},
+				DeploymentConfiguration: &ecs.DeploymentConfiguration{
+					Alarms: &ecs.DeploymentAlarms{AlarmNames: aws.StringSlice([]string{"svc1-5xx"})},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "code deploy with the daemon strategy",
+			service: &ecs.CreateServiceInput{
+				Cluster:              aws.String("clu1"),
+				ServiceName:          aws.String("svc1"),
+				DeploymentController: &ecs.DeploymentController{Type: aws.String("CODE_DEPLOY")},
+				LaunchType:           aws.String("EC2"),
+				LoadBalancers:        loadBalancers,
+				SchedulingStrategy:   aws.String("DAEMON"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid controller",
+			service: &ecs.CreateServiceInput{
+				Cluster:              aws.String("clu1"),
+				ServiceName:          aws.String("svc1"),
+				DeploymentController: &ecs.DeploymentController{Type: aws.String("BLUE_GREEN")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			got, _, err := o.processService(context.TODO(), &ServiceOrchestrationInput{Service: tt.service})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.processService() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+					t.Errorf("expected bad request error, got %v", err)
+				}
+				return
+			}
+
+			var controller string
+			if got.DeploymentController != nil {
+				controller = aws.StringValue(got.DeploymentController.Type)
+			}
+
+			if controller != tt.wantController {
+				t.Errorf("expected deployment controller %q, got %q", tt.wantController, controller)
+			}
+		})
+	}
+}
+
+func (m *mockECSClient) ListTagsForResourceWithContext(ctx aws.Context, input *ecs.ListTagsForResourceInput, opts ...request.Option) (*ecs.ListTagsForResourceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if tags, ok := m.tags[aws.StringValue(input.ResourceArn)]; ok {
+		return &ecs.ListTagsForResourceOutput{Tags: tags}, nil
+	}
+
+	return &ecs.ListTagsForResourceOutput{
+		Tags: []*ecs.Tag{
+			{Key: aws.String("spinup:org"), Value: aws.String("myorg")},
+		},
+	}, nil
+}
+
+func (m *mockRGTAClient) TagResourcesWithContext(ctx aws.Context, input *resourcegroupstaggingapi.TagResourcesInput, opts ...request.Option) (*resourcegroupstaggingapi.TagResourcesOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &resourcegroupstaggingapi.TagResourcesOutput{}, nil
+}
+
+// testAlarmNames are the names of the cloudwatch alarms that exist
+var testAlarmNames = []string{"svc1-5xx", "svc1-latency"}
+
+func (m *mockCWClient) DescribeAlarmsPagesWithContext(ctx aws.Context, input *cloudwatch.DescribeAlarmsInput, fn func(*cloudwatch.DescribeAlarmsOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	output := &cloudwatch.DescribeAlarmsOutput{}
+	for _, n := range input.AlarmNames {
+		for _, a := range testAlarmNames {
+			if aws.StringValue(n) == a {
+				output.MetricAlarms = append(output.MetricAlarms, &cloudwatch.MetricAlarm{AlarmName: aws.String(a)})
+			}
+		}
+	}
+
+	fn(output, true)
+	return nil
+}
+
+func TestOrchestrator_processDeploymentAlarms(t *testing.T) {
+	tests := []struct {
+		name       string
+		dc         *ecs.DeploymentConfiguration
+		cwerr      error
+		want       *ecs.DeploymentAlarms
+		wantCode   string
+		wantReason string
+	}{
+		{
+			name: "no deployment configuration",
+		},
+		{
+			name: "no alarms",
+			dc:   &ecs.DeploymentConfiguration{MaximumPercent: aws.Int64(200)},
+		},
+		{
+			name: "default enable and rollback",
+			dc: &ecs.DeploymentConfiguration{
+				Alarms: &ecs.DeploymentAlarms{
+					AlarmNames: aws.StringSlice([]string{"svc1-5xx"}),
+				},
+			},
+			want: &ecs.DeploymentAlarms{
+				AlarmNames: aws.StringSlice([]string{"svc1-5xx"}),
+				Enable:     aws.Bool(true),
+				Rollback:   aws.Bool(true),
+			},
+		},
+		{
+			name: "monitor without rollback",
+			dc: &ecs.DeploymentConfiguration{
+				Alarms: &ecs.DeploymentAlarms{
+					AlarmNames: aws.StringSlice([]string{"svc1-5xx", "svc1-latency"}),
+					Rollback:   aws.Bool(false),
+				},
+			},
+			want: &ecs.DeploymentAlarms{
+				AlarmNames: aws.StringSlice([]string{"svc1-5xx", "svc1-latency"}),
+				Enable:     aws.Bool(true),
+				Rollback:   aws.Bool(false),
+			},
+		},
+		{
+			name: "disable alarms",
+			dc: &ecs.DeploymentConfiguration{
+				Alarms: &ecs.DeploymentAlarms{
+					Enable:   aws.Bool(false),
+					Rollback: aws.Bool(false),
+				},
+			},
+			want: &ecs.DeploymentAlarms{
+				AlarmNames: []*string{},
+				Enable:     aws.Bool(false),
+				Rollback:   aws.Bool(false),
+			},
+		},
+		{
+			name: "enabled without alarm names",
+			dc: &ecs.DeploymentConfiguration{
+				Alarms: &ecs.DeploymentAlarms{},
+			},
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name: "nonexistent alarm",
+			dc: &ecs.DeploymentConfiguration{
+				Alarms: &ecs.DeploymentAlarms{
+					AlarmNames: aws.StringSlice([]string{"svc1-missing", "svc1-5xx", "svc1-gone"}),
+				},
+			},
+			wantCode:   apierror.ErrBadRequest,
+			wantReason: "svc1-missing, svc1-gone",
+		},
+		{
+			name: "cloudwatch error",
+			dc: &ecs.DeploymentConfiguration{
+				Alarms: &ecs.DeploymentAlarms{
+					AlarmNames: aws.StringSlice([]string{"svc1-5xx"}),
+				},
+			},
+			cwerr:    awserr.New(cloudwatch.ErrCodeInternalServiceFault, "boom", nil),
+			wantCode: apierror.ErrInternalError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.CloudWatch.Service.(*mockCWClient).err = tt.cwerr
+
+			err := o.processDeploymentAlarms(context.TODO(), tt.dc)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+
+				if !strings.Contains(aerr.Message, tt.wantReason) {
+					t.Errorf("expected error message to contain %q, got %q", tt.wantReason, aerr.Message)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if tt.dc == nil {
+				return
+			}
+
+			if !reflect.DeepEqual(tt.dc.Alarms, tt.want) {
+				t.Errorf("expected deployment alarms %+v, got %+v", tt.want, tt.dc.Alarms)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_UpdateServiceExpectedTaskDefinition(t *testing.T) {
+	t.Log("testing UpdateService with an expected task definition")
+
+	tests := []struct {
+		name     string
+		expected string
+		wantErr  string
+	}{
+		{
+			name: "no expected task definition",
+		},
+		{
+			name:     "matching family and revision",
+			expected: "loggedapp:1",
+		},
+		{
+			name:     "matching arn",
+			expected: "arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:1",
+		},
+		{
+			name:     "mismatched revision",
+			expected: "loggedapp:2",
+			wantErr:  apierror.ErrConflict,
+		},
+		{
+			name:     "mismatched arn",
+			expected: "arn:aws:ecs:us-east-1:0123456789:task-definition/otherapp:1",
+			wantErr:  apierror.ErrConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+			got, err := o.UpdateService(context.TODO(), "cluster0", "logged", &ServiceOrchestrationUpdateInput{
+				ForceNewDeployment:     true,
+				ExpectedTaskDefinition: tt.expected,
+			})
+
+			if tt.wantErr != "" {
+				var aerr apierror.Error
+				if !errors.As(err, &aerr) || aerr.Code != tt.wantErr {
+					t.Errorf("expected %s error, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if aws.StringValue(got.TaskDefinition.TaskDefinitionArn) != "arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:1" {
+				t.Errorf("expected active task definition loggedapp:1, got %s", aws.StringValue(got.TaskDefinition.TaskDefinitionArn))
+			}
+		})
+	}
+}
+
+func TestOrchestrator_UpdateServiceRollout(t *testing.T) {
+	t.Log("testing UpdateService with a rollout wait")
+
+	interval := DefaultDeploymentStatusPollInterval
+	DefaultDeploymentStatusPollInterval = 10 * time.Millisecond
+	defer func() { DefaultDeploymentStatusPollInterval = interval }()
+
+	tests := []struct {
+		name    string
+		service string
+		wait    int
+		want    *ServiceRolloutOutput
+	}{
+		{
+			name:    "no rollout wait",
+			service: "rolledback",
+		},
+		{
+			name:    "circuit breaker rollback",
+			service: "rolledback",
+			wait:    1,
+			want: &ServiceRolloutOutput{
+				DeploymentId:       "ecs-svc/0000000000000000012",
+				RolloutState:       "FAILED",
+				RolloutStateReason: "ECS deployment circuit breaker: tasks failed to start.",
+				RolledBack:         true,
+				RolledBackTo:       "arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:1",
+			},
+		},
+		{
+			name:    "completed rollout",
+			service: "rolledout",
+			wait:    1,
+			want: &ServiceRolloutOutput{
+				DeploymentId:       "ecs-svc/0000000000000000014",
+				RolloutState:       "COMPLETED",
+				RolloutStateReason: "ECS deployment ecs-svc/0000000000000000014 completed.",
+			},
+		},
+		{
+			name:    "no deployment started",
+			service: "logged",
+			wait:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+			got, err := o.UpdateService(context.TODO(), "cluster0", tt.service, &ServiceOrchestrationUpdateInput{
+				ForceNewDeployment: true,
+				RolloutWait:        tt.wait,
+			})
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if !reflect.DeepEqual(got.Rollout, tt.want) {
+				t.Errorf("expected rollout %+v, got %+v", tt.want, got.Rollout)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_serviceRolloutWaitBound(t *testing.T) {
+	interval := DefaultDeploymentStatusPollInterval
+	DefaultDeploymentStatusPollInterval = 20 * time.Millisecond
+	defer func() { DefaultDeploymentStatusPollInterval = interval }()
+
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	o.ECS.Service.(*mockECSClient).describeDelay = 40 * time.Millisecond
+
+	wait := 150 * time.Millisecond
+	start := time.Now()
+	got, err := o.serviceRollout(context.TODO(), "clu1", "inprogress", "ecs-svc/0000000000000000002", wait)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if got.RolloutState != "IN_PROGRESS" {
+		t.Errorf("expected rollout state IN_PROGRESS, got %s", got.RolloutState)
+	}
+
+	// polling stops at the wait time, even in the middle of a call, with some slack for the scheduler
+	if limit := wait + 50*time.Millisecond; elapsed < wait || elapsed > limit {
+		t.Errorf("expected to return after %s and within %s, returned after %s", wait, limit, elapsed)
+	}
+}
+
+func TestOrchestrator_CloneService(t *testing.T) {
+	t.Log("testing CloneService")
+
+	tests := []struct {
+		name        string
+		service     string
+		input       *ServiceCloneInput
+		wantCluster string
+		wantCount   int64
+		wantErr     string
+	}{
+		{
+			name:        "clone in the same cluster",
+			service:     "logged",
+			input:       &ServiceCloneInput{Name: "logged-copy"},
+			wantCluster: "cluster0",
+		},
+		{
+			name:        "clone to another cluster with a desired count",
+			service:     "logged",
+			input:       &ServiceCloneInput{Name: "logged", Cluster: "cluster1", DesiredCount: aws.Int64(3)},
+			wantCluster: "cluster1",
+			wantCount:   3,
+		},
+		{
+			name:    "missing name",
+			service: "logged",
+			input:   &ServiceCloneInput{},
+			wantErr: apierror.ErrBadRequest,
+		},
+		{
+			name:    "same name in the same cluster",
+			service: "logged",
+			input:   &ServiceCloneInput{Name: "logged", Cluster: "cluster0"},
+			wantErr: apierror.ErrBadRequest,
+		},
+		{
+			name:    "missing service",
+			service: "missing",
+			input:   &ServiceCloneInput{Name: "missing-copy"},
+			wantErr: apierror.ErrNotFound,
+		},
+		{
+			name:    "missing target cluster",
+			service: "logged",
+			input:   &ServiceCloneInput{Name: "logged-copy", Cluster: "missing"},
+			wantErr: apierror.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+			got, err := o.CloneService(context.TODO(), "cluster0", tt.service, tt.input)
+			if tt.wantErr != "" {
+				var aerr apierror.Error
+				if !errors.As(err, &aerr) || aerr.Code != tt.wantErr {
+					t.Errorf("expected %s error, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			wantTaskDef := "arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:1"
+			if aws.StringValue(got.Service.TaskDefinition) != wantTaskDef {
+				t.Errorf("expected cloned service to reference task definition %s, got %s", wantTaskDef, aws.StringValue(got.Service.TaskDefinition))
+			}
+
+			if aws.StringValue(got.TaskDefinition.TaskDefinitionArn) != wantTaskDef {
+				t.Errorf("expected task definition %s, got %s", wantTaskDef, aws.StringValue(got.TaskDefinition.TaskDefinitionArn))
+			}
+
+			if aws.StringValue(got.Service.ServiceName) != tt.input.Name {
+				t.Errorf("expected service name %s, got %s", tt.input.Name, aws.StringValue(got.Service.ServiceName))
+			}
+
+			if aws.StringValue(got.Cluster.ClusterName) != tt.wantCluster {
+				t.Errorf("expected cluster %s, got %s", tt.wantCluster, aws.StringValue(got.Cluster.ClusterName))
+			}
+
+			if aws.Int64Value(got.Service.DesiredCount) != tt.wantCount {
+				t.Errorf("expected desired count %d, got %d", tt.wantCount, aws.Int64Value(got.Service.DesiredCount))
+			}
+
+			tags := map[string]string{}
+			for _, tag := range got.Service.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+
+			if tags["spinup:org"] != "myorg" || tags["spinup:spaceid"] != tt.wantCluster {
+				t.Errorf("expected org and spaceid tags for %s, got %v", tt.wantCluster, tags)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_deleteServiceDependenciesWait(t *testing.T) {
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	o.ApplicationAutoScaling.Service = &mockAASClient{t: t}
+	o.DeleteTimeout = 50 * time.Millisecond
+
+	got := o.deleteServiceDependencies(context.TODO(), "clu1", &ecs.Service{
+		ClusterArn:  aws.String("cluster1"),
+		ServiceArn:  aws.String("arn:aws:ecs:us-east-1:0123456789:service/clu1/svc1"),
+		ServiceName: aws.String("svc1"),
+		ServiceRegistries: []*ecs.ServiceRegistry{
+			{RegistryArn: aws.String("arn:aws:servicediscovery:us-east-1:1234567890:service/srv-inuse")},
+		},
+		TaskDefinition: aws.String("otherapp:1"),
+	})
+
+	want := []*DependencyDeleteOutput{
+		{Type: "autoscaling", Resource: "service/clu1/svc1", Status: DependencyDeleted},
+		{Type: "cluster", Resource: "cluster1", Status: DependencyRetained},
+		{Type: "serviceregistry", Resource: "arn:aws:servicediscovery:us-east-1:1234567890:service/srv-inuse", Status: DependencyDeleteFailed},
+		{Type: "taskdefinition", Resource: "arn:aws:ecs:us-east-1:0123456789:task-definition/otherapp:1", Status: DependencyDeleted},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %d dependency outcomes, got %d: %+v", len(want), len(got), got)
+	}
+
+	for i, w := range want {
+		g := got[i]
+		if g.Type != w.Type || g.Resource != w.Resource || g.Status != w.Status {
+			t.Errorf("expected dependency outcome %+v, got %+v", w, g)
+		}
+
+		if w.Status == DependencyDeleteFailed && g.Error == "" {
+			t.Errorf("expected an error for failed dependency %s", g.Resource)
+		}
+
+		if w.Status != DependencyDeleteFailed && g.Error != "" {
+			t.Errorf("expected no error for dependency %s, got %s", g.Resource, g.Error)
+		}
+	}
+}
+
+func TestOrchestrator_deleteServiceDependenciesSchedules(t *testing.T) {
+	clusterArn := "arn:aws:ecs:us-east-1:1234567890:cluster/cluster0"
+
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	o.ApplicationAutoScaling.Service = &mockAASClient{t: t}
+	eb := &mockEBClient{
+		t: t,
+		rules: []*eventbridge.DescribeRuleOutput{
+			{Name: aws.String("otherapp-nightly")},
+			{Name: aws.String("webapp-nightly")},
+		},
+		targets: map[string][]*eventbridge.Target{
+			"otherapp-nightly": {
+				{
+					Id:            aws.String("otherapp"),
+					Arn:           aws.String(clusterArn),
+					EcsParameters: &eventbridge.EcsParameters{TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/otherapp:1")},
+				},
+			},
+			"webapp-nightly": {
+				{
+					Id:            aws.String("webapp"),
+					Arn:           aws.String(clusterArn),
+					EcsParameters: &eventbridge.EcsParameters{TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/webapp:2")},
+				},
+			},
+		},
+	}
+	o.EventBridge.Service = eb
+
+	got := o.deleteServiceDependencies(context.TODO(), "cluster0", &ecs.Service{
+		ClusterArn:     aws.String("cluster0"),
+		ServiceArn:     aws.String("arn:aws:ecs:us-east-1:0123456789:service/cluster0/svc1"),
+		ServiceName:    aws.String("svc1"),
+		TaskDefinition: aws.String("otherapp:1"),
+	})
+
+	for _, d := range got {
+		if d.Type == "schedule" {
+			t.Errorf("expected the schedules to be deleted without error, got %+v", d)
+		}
+	}
+
+	// the deleted service's family isn't run on a schedule again, other families' schedules are kept
+	if len(eb.rules) != 1 || aws.StringValue(eb.rules[0].Name) != "webapp-nightly" {
+		t.Errorf("expected only the webapp-nightly rule to remain, got %+v", eb.rules)
+	}
+}
+
+func TestOrchestrator_deleteServiceDependenciesShared(t *testing.T) {
+	// the source service in cluster0 was cloned to cluster1, the clone runs the same task definition with the
+	// credentials and execution role of cluster0
+	source := &ecs.Service{
+		ClusterArn:     aws.String("cluster0"),
+		ServiceArn:     aws.String("arn:aws:ecs:us-east-1:0123456789:service/cluster0/source"),
+		ServiceName:    aws.String("source"),
+		TaskDefinition: aws.String("sharedapp:1"),
+	}
+
+	credentials := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/myorg/cluster0/shared-AbCdEf"
+
+	tests := []struct {
+		name        string
+		services    map[string][]string
+		want        []*DependencyDeleteOutput
+		wantDeleted []string
+	}{
+		{
+			name:     "clone still running",
+			services: map[string][]string{"cluster1": {"clone"}},
+			want: []*DependencyDeleteOutput{
+				{Type: "autoscaling", Resource: "service/cluster0/source", Status: DependencyDeleted},
+				{Type: "cluster", Resource: "cluster0", Status: DependencyDeleted},
+				{Type: "role", Resource: "cluster0-ecsTaskExecution", Status: DependencyRetained},
+				{Type: "taskdefinition", Resource: "sharedapp", Status: DependencyRetained},
+			},
+		},
+		{
+			// the mocks don't know the role or the credentials, so their deletes are attempted and fail
+			name: "no clone",
+			want: []*DependencyDeleteOutput{
+				{Type: "autoscaling", Resource: "service/cluster0/source", Status: DependencyDeleted},
+				{Type: "cluster", Resource: "cluster0", Status: DependencyDeleted},
+				{Type: "role", Resource: "cluster0-ecsTaskExecution", Status: DependencyDeleteFailed},
+				{Type: "taskdefinition", Resource: "arn:aws:ecs:us-east-1:0123456789:task-definition/sharedapp:1", Status: DependencyDeleteFailed},
+			},
+			wantDeleted: []string{credentials},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.ApplicationAutoScaling.Service = &mockAASClient{t: t}
+			o.ResourceGroupsTaggingAPI.Service.(*mockRGTAClient).tagged = map[string]map[string]string{
+				"arn:aws:ecs:us-east-1:0123456789:cluster/cluster1": {"spinup:org": "myorg"},
+			}
+			o.ECS.Service.(*mockECSClient).services = tt.services
+			sm := o.SecretsManager.Service.(*mockSMClient)
+
+			got := o.deleteServiceDependencies(context.TODO(), "cluster0", source)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d dependency outcomes, got %d: %+v", len(tt.want), len(got), got)
+			}
+
+			for i, w := range tt.want {
+				if g := got[i]; g.Type != w.Type || g.Resource != w.Resource || g.Status != w.Status {
+					t.Errorf("expected dependency outcome %+v, got %+v", w, g)
+				}
+			}
+
+			if !reflect.DeepEqual(sm.deleted, tt.wantDeleted) {
+				t.Errorf("expected deleted credentials %v, got %v", tt.wantDeleted, sm.deleted)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_DeleteServiceWaitDeadline(t *testing.T) {
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	o.ApplicationAutoScaling.Service = &mockAASClient{t: t}
+	o.DeleteTimeout = time.Hour
+
+	defer func(wait time.Duration) { MaxDeleteWait = wait }(MaxDeleteWait)
+	MaxDeleteWait = 100 * time.Millisecond
+
+	start := time.Now()
+	out, err := o.DeleteService(context.TODO(), &ServiceDeleteInput{
+		Cluster:   aws.String("clu1"),
+		Service:   aws.String("inuseregistry"),
+		Recursive: true,
+		Wait:      true,
+	})
+	if err != nil {
+		t.Fatalf("Orchestrator.DeleteService() unexpected error = %v", err)
+	}
+
+	// the service registry never deletes, the wait is cut short by MaxDeleteWait instead of the delete timeout
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the delete to return after about %s, took %s", MaxDeleteWait, elapsed)
+	}
+
+	found := false
+	for _, d := range out.Dependencies {
+		if d.Type == "serviceregistry" {
+			found = true
+			if d.Status != DependencyDeleteFailed || d.Error == "" {
+				t.Errorf("expected the service registry delete to fail with an error, got %+v", d)
+			}
+		}
+	}
+
+	if !found {
+		t.Errorf("expected a service registry outcome, got %+v", out.Dependencies)
+	}
+}
+
+func TestOrchestrator_DeleteServiceCleanupServiceRegistryDeadline(t *testing.T) {
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	o.DeleteTimeout = time.Hour
+
+	defer func(wait time.Duration) { MaxDeleteWait = wait }(MaxDeleteWait)
+	MaxDeleteWait = 100 * time.Millisecond
+
+	start := time.Now()
+	out, err := o.DeleteService(context.TODO(), &ServiceDeleteInput{
+		Cluster:                aws.String("clu1"),
+		Service:                aws.String("inuseregistry"),
+		CleanupServiceRegistry: true,
+	})
+	if err != nil {
+		t.Fatalf("Orchestrator.DeleteService() unexpected error = %v", err)
+	}
+
+	// the service registry never deletes, the wait is cut short by MaxDeleteWait instead of the delete timeout
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the delete to return after about %s, took %s", MaxDeleteWait, elapsed)
+	}
+
+	if len(out.Dependencies) != 1 {
+		t.Fatalf("expected 1 dependency outcome, got %+v", out.Dependencies)
+	}
+
+	if d := out.Dependencies[0]; d.Type != "serviceregistry" || d.Status != DependencyDeleteFailed || d.Error == "" {
+		t.Errorf("expected the service registry delete to fail with an error, got %+v", d)
+	}
+}
+
+func TestOrchestrator_servicesDependencies(t *testing.T) {
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	o.ResourceGroupsTaggingAPI.Service.(*mockRGTAClient).tagged = map[string]map[string]string{
+		"arn:aws:ecs:us-east-1:0123456789:cluster/cluster1": {"spinup:org": "myorg"},
+		"arn:aws:ecs:us-east-1:0123456789:cluster/cluster2": {"spinup:org": "myorg"},
+	}
+	e := o.ECS.Service.(*mockECSClient)
+	e.services = map[string][]string{"cluster1": {"clone"}, "cluster2": {"clone"}}
+
+	shared, err := o.servicesDependencies(context.TODO())
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if _, ok := shared.families["sharedapp"]; !ok {
+		t.Errorf("expected the sharedapp family to be shared, got %v", shared.families)
+	}
+
+	// the families are parsed from the arns, without describing the task definitions
+	if len(e.describedTaskDefinitions) != 0 {
+		t.Errorf("expected no task definitions to be described, got %v", e.describedTaskDefinitions)
+	}
+
+	inUse, err := o.executionRoleShared(context.TODO(), shared, "cluster0-ecsTaskExecution")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if !inUse {
+		t.Error("expected the cluster0-ecsTaskExecution role to be shared")
+	}
+
+	// the task definition of both clones is only described once
+	if len(e.describedTaskDefinitions) != 1 {
+		t.Errorf("expected 1 task definition to be described, got %v", e.describedTaskDefinitions)
+	}
+
+	if inUse, _ := o.executionRoleShared(context.TODO(), shared, "cluster1-ecsTaskExecution"); inUse {
+		t.Error("expected the cluster1-ecsTaskExecution role not to be shared")
+	}
+}
+
+func TestClassifyServiceEvents(t *testing.T) {
+	event := func(ms int64, message string) *ecs.ServiceEvent {
+		return &ecs.ServiceEvent{
+			CreatedAt: aws.Time(time.UnixMilli(ms)),
+			Message:   aws.String(message),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		events []*ecs.ServiceEvent
+		want   []ServiceDeploymentFailure
+	}{
+		{
+			name:   "no events",
+			events: nil,
+			want:   []ServiceDeploymentFailure{},
+		},
+		{
+			name: "no failures",
+			events: []*ecs.ServiceEvent{
+				event(3000, "(service svc1) has started 1 tasks: (task 1)."),
+				event(2000, "(service svc1) registered 1 targets in (target-group arn:aws:elasticloadbalancing:us-east-1:0123456789:targetgroup/tg1/0123)"),
+				event(1000, "(service svc1) has reached a steady state."),
+			},
+			want: []ServiceDeploymentFailure{},
+		},
+		{
+			name: "events mentioning a subnet, security group or kms",
+			events: []*ecs.ServiceEvent{
+				event(3000, "(service svc1) has started 1 tasks in subnet subnet-0123 with security group sg-0123: (task 1)."),
+				event(2000, "(service svc1) updated the network configuration, security groups: sg-0123, subnets: subnet-0123, subnet-0456."),
+				event(1000, "(service svc1) has started 1 tasks from task definition app-kms:3: (task 2)."),
+			},
+			want: []ServiceDeploymentFailure{},
+		},
+		{
+			name: "capacity",
+			events: []*ecs.ServiceEvent{
+				event(3000, "(service svc1) was unable to place a task because no container instance met all of its requirements. The closest matching (container-instance 0123) has insufficient memory available."),
+				event(2000, "(service svc1) was unable to place a task because no container instance met all of its requirements."),
+			},
+			want: []ServiceDeploymentFailure{
+				{
+					Category: "capacity",
+					Count:    2,
+					LastSeen: aws.Time(time.UnixMilli(3000)),
+					Message:  "(service svc1) was unable to place a task because no container instance met all of its requirements. The closest matching (container-instance 0123) has insufficient memory available.",
+				},
+			},
+		},
+		{
+			name: "secret access before image pull",
+			events: []*ecs.ServiceEvent{
+				event(3000, "(service svc1) failed to launch a task with (error ResourceInitializationError: unable to pull secrets or registry auth: execution resource retrieval failed: unable to retrieve secret from asm: AccessDeniedException)."),
+			},
+			want: []ServiceDeploymentFailure{
+				{
+					Category: "secret-access",
+					Count:    1,
+					LastSeen: aws.Time(time.UnixMilli(3000)),
+					Message:  "(service svc1) failed to launch a task with (error ResourceInitializationError: unable to pull secrets or registry auth: execution resource retrieval failed: unable to retrieve secret from asm: AccessDeniedException).",
+				},
+			},
+		},
+		{
+			name: "multiple categories since the last steady state",
+			events: []*ecs.ServiceEvent{
+				event(6000, "(service svc1) failed to launch a task with (error CannotPullContainerError: pull image manifest has been retried 5 time(s): failed to resolve ref docker.io/org/app:missing: not found)."),
+				event(5000, "(service svc1) was unable to place a task. Reason: You've reached the limit on the number of elastic network interfaces that can be attached."),
+				event(4000, "(service svc1) has started 1 tasks: (task 4)."),
+				event(3000, "(service svc1) failed to launch a task with (error CannotPullContainerError: pull image manifest has been retried 1 time(s))."),
+				event(2000, "(service svc1) has reached a steady state."),
+				event(1000, "(service svc1) was unable to place a task because no container instance met all of its requirements."),
+			},
+			want: []ServiceDeploymentFailure{
+				{
+					Category: "image-pull",
+					Count:    2,
+					LastSeen: aws.Time(time.UnixMilli(6000)),
+					Message:  "(service svc1) failed to launch a task with (error CannotPullContainerError: pull image manifest has been retried 5 time(s): failed to resolve ref docker.io/org/app:missing: not found).",
+				},
+				{
+					Category: "network",
+					Count:    1,
+					LastSeen: aws.Time(time.UnixMilli(5000)),
+					Message:  "(service svc1) was unable to place a task. Reason: You've reached the limit on the number of elastic network interfaces that can be attached.",
+				},
+			},
+		},
+		{
+			name: "subnet addresses",
+			events: []*ecs.ServiceEvent{
+				event(1000, "(service svc1) was unable to launch a task: insufficient free addresses in subnet subnet-0123 to place the task."),
+			},
+			want: []ServiceDeploymentFailure{
+				{
+					Category: "network",
+					Count:    1,
+					LastSeen: aws.Time(time.UnixMilli(1000)),
+					Message:  "(service svc1) was unable to launch a task: insufficient free addresses in subnet subnet-0123 to place the task.",
+				},
+			},
+		},
+		{
+			name: "kms access",
+			events: []*ecs.ServiceEvent{
+				event(1000, "(service svc1) failed to launch a task with (error ResourceInitializationError: failed to fetch secret from asm: KMSAccessDeniedException: The ciphertext refers to a kms key that is not accessible)."),
+			},
+			want: []ServiceDeploymentFailure{
+				{
+					Category: "secret-access",
+					Count:    1,
+					LastSeen: aws.Time(time.UnixMilli(1000)),
+					Message:  "(service svc1) failed to launch a task with (error ResourceInitializationError: failed to fetch secret from asm: KMSAccessDeniedException: The ciphertext refers to a kms key that is not accessible).",
+				},
+			},
+		},
+		{
+			name: "missing security group and no free ip addresses",
+			events: []*ecs.ServiceEvent{
+				event(2000, "(service svc1) failed to launch a task with (error InvalidGroup.NotFound: The security group 'sg-0123' does not exist)."),
+				event(1000, "(service svc1) was unable to launch a task: there are no free ip addresses in subnet subnet-0123."),
+			},
+			want: []ServiceDeploymentFailure{
+				{
+					Category: "network",
+					Count:    2,
+					LastSeen: aws.Time(time.UnixMilli(2000)),
+					Message:  "(service svc1) failed to launch a task with (error InvalidGroup.NotFound: The security group 'sg-0123' does not exist).",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := []ServiceDeploymentFailure{}
+			for _, f := range classifyServiceEvents(test.events) {
+				if f.Description == "" {
+					t.Errorf("expected a description for category %s", f.Category)
+				}
+
+				f.Description = ""
+				got = append(got, *f)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected failures %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
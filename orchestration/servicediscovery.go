@@ -2,9 +2,11 @@ package orchestration
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
+	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/arn"
 
@@ -39,6 +41,10 @@ func (o *Orchestrator) processServiceRegistry(ctx context.Context, input *Servic
 
 		return sd, rbfunc, nil
 	} else if input.ServiceRegistry != nil {
+		if err := validateDnsConfig(input.ServiceRegistry.DnsConfig); err != nil {
+			return nil, rbfunc, err
+		}
+
 		log.Infof("creating service registry %+v", input.ServiceRegistry)
 		sd, err := o.ServiceDiscovery.CreateServiceDiscoveryService(ctx, input.ServiceRegistry)
 		if err != nil {
@@ -72,3 +78,194 @@ func (o *Orchestrator) processServiceRegistry(ctx context.Context, input *Servic
 	log.Warn("service discovery registry was not provided, not registering")
 	return nil, rbfunc, nil
 }
+
+// processServiceRegistryUpdate applies the service registry changes in the update input to the service discovery
+// service the ECS service is registered with.  Service discovery doesn't allow changing the routing policy or the
+// custom health check configuration of an existing service, so the new DNS records are validated against the
+// existing routing policy.
+func (o *Orchestrator) processServiceRegistryUpdate(ctx context.Context, input *ServiceOrchestrationUpdateInput, active *ServiceOrchestrationUpdateOutput) error {
+	if input.ServiceRegistry == nil {
+		log.Debug("no service registry changes, not updating service discovery")
+		return nil
+	}
+
+	if len(active.Service.ServiceRegistries) == 0 {
+		msg := fmt.Sprintf("service %s is not registered with service discovery", aws.StringValue(active.Service.ServiceName))
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	registryArn := aws.StringValue(active.Service.ServiceRegistries[0].RegistryArn)
+	id, err := serviceRegistryId(registryArn)
+	if err != nil {
+		return err
+	}
+
+	sd, err := o.ServiceDiscovery.GetServiceDiscoveryService(ctx, aws.String(id))
+	if err != nil {
+		return err
+	}
+
+	if sd == nil {
+		return apierror.New(apierror.ErrNotFound, "service registry "+registryArn+" not found", nil)
+	}
+
+	if dc := input.ServiceRegistry.DnsConfig; dc != nil {
+		policy := ""
+		if sd.DnsConfig != nil {
+			policy = aws.StringValue(sd.DnsConfig.RoutingPolicy)
+		}
+
+		if err := validateDnsRecords(dc.DnsRecords, policy); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("updating service registry %s %+v", registryArn, input.ServiceRegistry)
+
+	op, err := o.ServiceDiscovery.UpdateServiceDiscoveryService(ctx, id, input.ServiceRegistry)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("service registry %s update operation %s", registryArn, op)
+
+	// the update is asynchronous, so return the service registry with the changes applied
+	if dc := input.ServiceRegistry.DnsConfig; dc != nil {
+		if sd.DnsConfig == nil {
+			sd.DnsConfig = &servicediscovery.DnsConfig{}
+		}
+		sd.DnsConfig.DnsRecords = dc.DnsRecords
+	}
+
+	if input.ServiceRegistry.HealthCheckConfig != nil {
+		sd.HealthCheckConfig = input.ServiceRegistry.HealthCheckConfig
+	}
+
+	if input.ServiceRegistry.Description != nil {
+		sd.Description = input.ServiceRegistry.Description
+	}
+
+	active.ServiceDiscoveryService = sd
+
+	return nil
+}
+
+// MaxDnsRecordTTL is the maximum TTL (in seconds) of a service discovery DNS record
+const MaxDnsRecordTTL = 2147483647
+
+// validateDnsConfig validates the routing policy and the TTL and type of the DNS records of a service registry DNS
+// configuration.  An unset routing policy is left to the service discovery default.
+func validateDnsConfig(dc *servicediscovery.DnsConfig) error {
+	if dc == nil {
+		return nil
+	}
+
+	policy := aws.StringValue(dc.RoutingPolicy)
+	if dc.RoutingPolicy != nil && !stringInSlice(policy, servicediscovery.RoutingPolicy_Values()) {
+		msg := fmt.Sprintf("invalid routing policy '%s', must be one of %s", policy, strings.Join(servicediscovery.RoutingPolicy_Values(), ", "))
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	return validateDnsRecords(dc.DnsRecords, policy)
+}
+
+// validateDnsRecords validates the type and TTL of service registry DNS records for the given routing policy
+func validateDnsRecords(records []*servicediscovery.DnsRecord, policy string) error {
+	if len(records) == 0 {
+		return apierror.New(apierror.ErrBadRequest, "at least one dns record is required in the service registry dns config", nil)
+	}
+
+	for _, r := range records {
+		if r == nil {
+			return apierror.New(apierror.ErrBadRequest, "invalid empty dns record in the service registry dns config", nil)
+		}
+
+		recordType := aws.StringValue(r.Type)
+		if !stringInSlice(recordType, servicediscovery.RecordType_Values()) {
+			msg := fmt.Sprintf("invalid dns record type '%s', must be one of %s", recordType, strings.Join(servicediscovery.RecordType_Values(), ", "))
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+
+		if recordType == servicediscovery.RecordTypeCname && policy == servicediscovery.RoutingPolicyMultivalue {
+			return apierror.New(apierror.ErrBadRequest, "CNAME dns records can't be used with the MULTIVALUE routing policy", nil)
+		}
+
+		if r.TTL == nil || aws.Int64Value(r.TTL) < 0 || aws.Int64Value(r.TTL) > MaxDnsRecordTTL {
+			msg := fmt.Sprintf("invalid ttl for %s dns record, must be between 0 and %d seconds", recordType, MaxDnsRecordTTL)
+			return apierror.New(apierror.ErrBadRequest, msg, nil)
+		}
+	}
+
+	return nil
+}
+
+// stringInSlice returns true if the value is one of the allowed values
+func stringInSlice(value string, allowed []string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceRegistryId returns the ID of a service discovery service from its ARN
+func serviceRegistryId(registryArn string) (string, error) {
+	a, err := arn.Parse(registryArn)
+	if err != nil {
+		return "", apierror.New(apierror.ErrBadRequest, "invalid service registry arn "+registryArn, err)
+	}
+
+	resource := strings.SplitN(a.Resource, "/", 2)
+	if len(resource) != 2 {
+		return "", apierror.New(apierror.ErrBadRequest, "invalid service registry arn "+registryArn, nil)
+	}
+
+	return resource[1], nil
+}
+
+// cleanupServiceRegistry deletes the service discovery service of a deleted service after deregistering any instances
+// that are still registered.  A service discovery service that doesn't exist is retained (not deleted) without an error.
+func (o *Orchestrator) cleanupServiceRegistry(ctx context.Context, registryArn *string) (string, error) {
+	id, err := serviceRegistryId(aws.StringValue(registryArn))
+	if err != nil {
+		return DependencyRetained, err
+	}
+
+	if _, err := o.ServiceDiscovery.GetServiceDiscoveryService(ctx, aws.String(id)); err != nil {
+		if aerr, ok := err.(apierror.Error); ok && aerr.Code == apierror.ErrNotFound {
+			log.Warnf("service registry %s doesn't exist, not deleting", aws.StringValue(registryArn))
+			return DependencyRetained, nil
+		}
+		return DependencyRetained, err
+	}
+
+	if _, err := o.ServiceDiscovery.DeregisterInstances(ctx, id); err != nil {
+		return DependencyRetained, err
+	}
+
+	return DependencyDeleted, o.deleteServiceRegistry(ctx, registryArn)
+}
+
+// deleteServiceRegistry deletes a service registry, waiting up to the configured delete timeout (or the deadline of the
+// context, if it's sooner) for it to be removed
+func (o *Orchestrator) deleteServiceRegistry(ctx context.Context, registryArn *string) error {
+	srCtx, srCancel := context.WithTimeout(ctx, o.deleteTimeout())
+	defer srCancel()
+
+	srChan := o.ServiceDiscovery.DeleteServiceRegistryWithRetry(srCtx, registryArn)
+
+	// wait for a done context
+	select {
+	case <-srCtx.Done():
+		return fmt.Errorf("timeout waiting for successful service registry %s deletion", aws.StringValue(registryArn))
+	case out := <-srChan:
+		if out != "success" {
+			return fmt.Errorf("failed to delete service registry %s", aws.StringValue(registryArn))
+		}
+	}
+
+	log.Infof("successfully deleted service registry %s", aws.StringValue(registryArn))
+
+	return nil
+}
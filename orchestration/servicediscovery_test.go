@@ -0,0 +1,436 @@
+package orchestration
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+)
+
+func (m *mockSDClient) CreateServiceWithContext(ctx aws.Context, input *servicediscovery.CreateServiceInput, opts ...request.Option) (*servicediscovery.CreateServiceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	m.created = append(m.created, input)
+
+	return &servicediscovery.CreateServiceOutput{
+		Service: &servicediscovery.Service{
+			Arn:       aws.String("arn:aws:servicediscovery:us-east-1:1234567890:service/srv-0123456789"),
+			DnsConfig: input.DnsConfig,
+			Id:        aws.String("srv-0123456789"),
+			Name:      input.Name,
+		},
+	}, nil
+}
+
+func (m *mockSDClient) DeleteServiceWithContext(ctx aws.Context, input *servicediscovery.DeleteServiceInput, opts ...request.Option) (*servicediscovery.DeleteServiceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if aws.StringValue(input.Id) == "srv-inuse" {
+		return nil, awserr.New(servicediscovery.ErrCodeResourceInUse, "service has registered instances", nil)
+	}
+
+	m.deleted = append(m.deleted, aws.StringValue(input.Id))
+
+	return &servicediscovery.DeleteServiceOutput{}, nil
+}
+
+// testServiceRegistryInstances are the instances registered with the mock service discovery services, by id
+var testServiceRegistryInstances = map[string][]string{
+	"srv-0123456789": {"0a1b2c3d4e5f", "6a7b8c9d0e1f"},
+}
+
+func (m *mockSDClient) ListInstancesPagesWithContext(ctx aws.Context, input *servicediscovery.ListInstancesInput, fn func(*servicediscovery.ListInstancesOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	out := &servicediscovery.ListInstancesOutput{}
+	for _, i := range testServiceRegistryInstances[aws.StringValue(input.ServiceId)] {
+		out.Instances = append(out.Instances, &servicediscovery.InstanceSummary{Id: aws.String(i)})
+	}
+
+	fn(out, true)
+
+	return nil
+}
+
+func (m *mockSDClient) DeregisterInstanceWithContext(ctx aws.Context, input *servicediscovery.DeregisterInstanceInput, opts ...request.Option) (*servicediscovery.DeregisterInstanceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	m.deregistered = append(m.deregistered, aws.StringValue(input.InstanceId))
+
+	return &servicediscovery.DeregisterInstanceOutput{OperationId: aws.String("op-" + aws.StringValue(input.InstanceId))}, nil
+}
+
+// testServiceRegistries are the service discovery services returned by the mock, by id
+var testServiceRegistries = map[string]*servicediscovery.Service{
+	"srv-0123456789": {
+		Arn: aws.String("arn:aws:servicediscovery:us-east-1:1234567890:service/srv-0123456789"),
+		DnsConfig: &servicediscovery.DnsConfig{
+			DnsRecords:    []*servicediscovery.DnsRecord{{Type: aws.String("A"), TTL: aws.Int64(60)}},
+			NamespaceId:   aws.String("ns-0123456789"),
+			RoutingPolicy: aws.String("MULTIVALUE"),
+		},
+		Id:   aws.String("srv-0123456789"),
+		Name: aws.String("svc1"),
+	},
+	"srv-inuse": {
+		Arn: aws.String("arn:aws:servicediscovery:us-east-1:1234567890:service/srv-inuse"),
+		DnsConfig: &servicediscovery.DnsConfig{
+			DnsRecords:    []*servicediscovery.DnsRecord{{Type: aws.String("A"), TTL: aws.Int64(60)}},
+			NamespaceId:   aws.String("ns-0123456789"),
+			RoutingPolicy: aws.String("MULTIVALUE"),
+		},
+		Id:   aws.String("srv-inuse"),
+		Name: aws.String("inuseregistry"),
+	},
+}
+
+func (m *mockSDClient) GetServiceWithContext(ctx aws.Context, input *servicediscovery.GetServiceInput, opts ...request.Option) (*servicediscovery.GetServiceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	sd, ok := testServiceRegistries[aws.StringValue(input.Id)]
+	if !ok {
+		return nil, awserr.New(servicediscovery.ErrCodeServiceNotFound, "service not found", nil)
+	}
+
+	// return a copy so the fixture isn't modified by the caller
+	out := *sd
+	dc := *sd.DnsConfig
+	out.DnsConfig = &dc
+
+	return &servicediscovery.GetServiceOutput{Service: &out}, nil
+}
+
+func (m *mockSDClient) UpdateServiceWithContext(ctx aws.Context, input *servicediscovery.UpdateServiceInput, opts ...request.Option) (*servicediscovery.UpdateServiceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	m.updated = append(m.updated, input)
+
+	return &servicediscovery.UpdateServiceOutput{OperationId: aws.String("op-0123456789")}, nil
+}
+
+func TestOrchestrator_deleteServiceRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry string
+		timeout  time.Duration
+		sderr    error
+		wantErr  bool
+	}{
+		{
+			name:     "deleted",
+			registry: "arn:aws:servicediscovery:us-east-1:1234567890:service/srv-0123456789",
+		},
+		{
+			name:     "registry in use times out",
+			registry: "arn:aws:servicediscovery:us-east-1:1234567890:service/srv-inuse",
+			timeout:  50 * time.Millisecond,
+			wantErr:  true,
+		},
+		{
+			name:     "servicediscovery error",
+			registry: "arn:aws:servicediscovery:us-east-1:1234567890:service/srv-0123456789",
+			sderr:    awserr.New(servicediscovery.ErrCodeServiceNotFound, "not found", nil),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, tt.sderr)
+			o.DeleteTimeout = tt.timeout
+
+			start := time.Now()
+			err := o.deleteServiceRegistry(context.TODO(), aws.String(tt.registry))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.deleteServiceRegistry() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.timeout > 0 {
+				if elapsed := time.Since(start); elapsed > time.Second {
+					t.Errorf("expected deleteServiceRegistry to give up after the configured timeout %s, took %s", tt.timeout, elapsed)
+				}
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processServiceRegistryDnsConfig(t *testing.T) {
+	record := func(recordType string, ttl *int64) *servicediscovery.DnsRecord {
+		return &servicediscovery.DnsRecord{Type: aws.String(recordType), TTL: ttl}
+	}
+
+	tests := []struct {
+		name       string
+		dnsConfig  *servicediscovery.DnsConfig
+		wantPolicy string
+		wantTTLs   []int64
+		wantErr    bool
+	}{
+		{
+			name: "multivalue with a low ttl",
+			dnsConfig: &servicediscovery.DnsConfig{
+				RoutingPolicy: aws.String("MULTIVALUE"),
+				DnsRecords:    []*servicediscovery.DnsRecord{record("A", aws.Int64(10))},
+			},
+			wantPolicy: "MULTIVALUE",
+			wantTTLs:   []int64{10},
+		},
+		{
+			name: "weighted with a zero ttl",
+			dnsConfig: &servicediscovery.DnsConfig{
+				RoutingPolicy: aws.String("WEIGHTED"),
+				DnsRecords:    []*servicediscovery.DnsRecord{record("A", aws.Int64(0)), record("SRV", aws.Int64(0))},
+			},
+			wantPolicy: "WEIGHTED",
+			wantTTLs:   []int64{0, 0},
+		},
+		{
+			name: "default routing policy",
+			dnsConfig: &servicediscovery.DnsConfig{
+				DnsRecords: []*servicediscovery.DnsRecord{record("A", aws.Int64(60))},
+			},
+			wantTTLs: []int64{60},
+		},
+		{
+			name: "invalid routing policy",
+			dnsConfig: &servicediscovery.DnsConfig{
+				RoutingPolicy: aws.String("FAILOVER"),
+				DnsRecords:    []*servicediscovery.DnsRecord{record("A", aws.Int64(10))},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative ttl",
+			dnsConfig: &servicediscovery.DnsConfig{
+				DnsRecords: []*servicediscovery.DnsRecord{record("A", aws.Int64(-1))},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ttl too large",
+			dnsConfig: &servicediscovery.DnsConfig{
+				DnsRecords: []*servicediscovery.DnsRecord{record("A", aws.Int64(MaxDnsRecordTTL+1))},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing ttl",
+			dnsConfig: &servicediscovery.DnsConfig{
+				DnsRecords: []*servicediscovery.DnsRecord{record("A", nil)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid record type",
+			dnsConfig: &servicediscovery.DnsConfig{
+				DnsRecords: []*servicediscovery.DnsRecord{record("MX", aws.Int64(10))},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cname with multivalue",
+			dnsConfig: &servicediscovery.DnsConfig{
+				RoutingPolicy: aws.String("MULTIVALUE"),
+				DnsRecords:    []*servicediscovery.DnsRecord{record("CNAME", aws.Int64(10))},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "no dns records",
+			dnsConfig: &servicediscovery.DnsConfig{RoutingPolicy: aws.String("MULTIVALUE")},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			m := o.ServiceDiscovery.Service.(*mockSDClient)
+
+			input := &ServiceOrchestrationInput{
+				Service: &ecs.CreateServiceInput{ServiceName: aws.String("svc1")},
+				ServiceRegistry: &servicediscovery.CreateServiceInput{
+					Name:      aws.String("svc1"),
+					DnsConfig: tt.dnsConfig,
+				},
+			}
+
+			_, _, err := o.processServiceRegistry(context.TODO(), input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Orchestrator.processServiceRegistry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if len(m.created) != 0 {
+					t.Errorf("expected no service registry to be created, got %d", len(m.created))
+				}
+				return
+			}
+
+			if len(m.created) != 1 {
+				t.Fatalf("expected 1 service registry to be created, got %d", len(m.created))
+			}
+
+			got := m.created[0].DnsConfig
+			if aws.StringValue(got.RoutingPolicy) != tt.wantPolicy {
+				t.Errorf("expected routing policy %s, got %s", tt.wantPolicy, aws.StringValue(got.RoutingPolicy))
+			}
+
+			ttls := []int64{}
+			for _, r := range got.DnsRecords {
+				ttls = append(ttls, aws.Int64Value(r.TTL))
+			}
+
+			if !reflect.DeepEqual(ttls, tt.wantTTLs) {
+				t.Errorf("expected ttls %v, got %v", tt.wantTTLs, ttls)
+			}
+
+			if len(input.Service.ServiceRegistries) != 1 {
+				t.Errorf("expected the service registry to be added to the service, got %+v", input.Service.ServiceRegistries)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processServiceRegistryUpdate(t *testing.T) {
+	registries := func(arns ...string) []*ecs.ServiceRegistry {
+		out := []*ecs.ServiceRegistry{}
+		for _, a := range arns {
+			out = append(out, &ecs.ServiceRegistry{RegistryArn: aws.String(a)})
+		}
+		return out
+	}
+
+	records := func(recordType string, ttl int64) *servicediscovery.ServiceChange {
+		return &servicediscovery.ServiceChange{
+			DnsConfig: &servicediscovery.DnsConfigChange{
+				DnsRecords: []*servicediscovery.DnsRecord{{Type: aws.String(recordType), TTL: aws.Int64(ttl)}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		registries []*ecs.ServiceRegistry
+		change     *servicediscovery.ServiceChange
+		wantTTL    int64
+		wantUpdate bool
+		wantErr    bool
+	}{
+		{
+			name:       "no changes",
+			registries: registries("arn:aws:servicediscovery:us-east-1:1234567890:service/srv-0123456789"),
+		},
+		{
+			name:       "updated ttl",
+			registries: registries("arn:aws:servicediscovery:us-east-1:1234567890:service/srv-0123456789"),
+			change:     records("A", 10),
+			wantTTL:    10,
+			wantUpdate: true,
+		},
+		{
+			name:       "updated description",
+			registries: registries("arn:aws:servicediscovery:us-east-1:1234567890:service/srv-0123456789"),
+			change:     &servicediscovery.ServiceChange{Description: aws.String("my service")},
+			wantTTL:    60,
+			wantUpdate: true,
+		},
+		{
+			name:       "invalid ttl",
+			registries: registries("arn:aws:servicediscovery:us-east-1:1234567890:service/srv-0123456789"),
+			change:     records("A", -1),
+			wantErr:    true,
+		},
+		{
+			name:       "cname with existing multivalue routing policy",
+			registries: registries("arn:aws:servicediscovery:us-east-1:1234567890:service/srv-0123456789"),
+			change:     records("CNAME", 10),
+			wantErr:    true,
+		},
+		{
+			name:    "service not registered",
+			change:  records("A", 10),
+			wantErr: true,
+		},
+		{
+			name:       "missing service registry",
+			registries: registries("arn:aws:servicediscovery:us-east-1:1234567890:service/srv-missing"),
+			change:     records("A", 10),
+			wantErr:    true,
+		},
+		{
+			name:       "invalid service registry arn",
+			registries: registries("srv-0123456789"),
+			change:     records("A", 10),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			m := o.ServiceDiscovery.Service.(*mockSDClient)
+
+			input := &ServiceOrchestrationUpdateInput{ServiceRegistry: tt.change}
+			active := &ServiceOrchestrationUpdateOutput{
+				Service: &ecs.Service{
+					ServiceName:       aws.String("svc1"),
+					ServiceRegistries: tt.registries,
+				},
+			}
+
+			err := o.processServiceRegistryUpdate(context.TODO(), input, active)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Orchestrator.processServiceRegistryUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantUpdate {
+				if len(m.updated) != 0 {
+					t.Errorf("expected no service registry update, got %+v", m.updated)
+				}
+
+				if active.ServiceDiscoveryService != nil {
+					t.Errorf("expected no service discovery service output, got %+v", active.ServiceDiscoveryService)
+				}
+				return
+			}
+
+			if len(m.updated) != 1 {
+				t.Fatalf("expected 1 service registry update, got %d", len(m.updated))
+			}
+
+			if id := aws.StringValue(m.updated[0].Id); id != "srv-0123456789" {
+				t.Errorf("expected service registry srv-0123456789 to be updated, got %s", id)
+			}
+
+			if !reflect.DeepEqual(m.updated[0].Service, tt.change) {
+				t.Errorf("expected service change %+v, got %+v", tt.change, m.updated[0].Service)
+			}
+
+			sd := active.ServiceDiscoveryService
+			if sd == nil {
+				t.Fatal("expected service discovery service output, got nil")
+			}
+
+			if ttl := aws.Int64Value(sd.DnsConfig.DnsRecords[0].TTL); ttl != tt.wantTTL {
+				t.Errorf("expected ttl %d, got %d", tt.wantTTL, ttl)
+			}
+		})
+	}
+}
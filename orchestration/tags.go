@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/aws/awsutil"
@@ -32,13 +36,28 @@ func secretsmanagerTags(tags []*Tag) []*secretsmanager.Tag {
 	return st
 }
 
-// cleanTags cleanses the tags input and ensures spinup:org and spinup:spaceid are set correctly
-func cleanTags(org, spaceid, stype, flavor string, tags []*Tag) ([]*Tag, error) {
+// validatePropagateTags ensures the given propagate tags value is one of the allowed values
+func validatePropagateTags(value *string, allowed []string) error {
+	for _, a := range allowed {
+		if aws.StringValue(value) == a {
+			return nil
+		}
+	}
+
+	msg := fmt.Sprintf("invalid propagateTags value '%s', must be one of %s", aws.StringValue(value), strings.Join(allowed, ", "))
+	return apierror.New(apierror.ErrBadRequest, msg, nil)
+}
+
+// cleanTags cleanses the tags input and ensures spinup:org and spinup:spaceid are set correctly.  The spinup:org
+// tag defaults to org, but may be set to any of the allowed orgs.
+func cleanTags(org string, allowed []string, spaceid, stype, flavor string, tags []*Tag) ([]*Tag, error) {
+	orgTag := &Tag{
+		Key:   aws.String("spinup:org"),
+		Value: aws.String(org),
+	}
+
 	cleanTags := []*Tag{
-		{
-			Key:   aws.String("spinup:org"),
-			Value: aws.String(org),
-		},
+		orgTag,
 		{
 			Key:   aws.String("spinup:spaceid"),
 			Value: aws.String(spaceid),
@@ -56,10 +75,12 @@ func cleanTags(org, spaceid, stype, flavor string, tags []*Tag) ([]*Tag, error)
 	for _, t := range tags {
 		switch aws.StringValue(t.Key) {
 		case "spinup:org", "yale:org":
-			if aws.StringValue(t.Value) != org {
-				msg := fmt.Sprintf("not a part of our org (%s)", org)
+			value := aws.StringValue(t.Value)
+			if !AllowedOrg(org, allowed, value) {
+				msg := fmt.Sprintf("not a part of our org (%s)", strings.Join(append([]string{org}, allowed...), ", "))
 				return nil, errors.New(msg)
 			}
+			orgTag.Value = aws.String(value)
 		case "spinup:spaceid", "spinup:type", "spinup:flavor":
 			log.Debugf("skipping api controlled tag %s", aws.StringValue(t.Key))
 		default:
@@ -67,9 +88,134 @@ func cleanTags(org, spaceid, stype, flavor string, tags []*Tag) ([]*Tag, error)
 		}
 	}
 
+	// the api controlled tags are always valid, only validate the tags passed by the caller
+	if err := ValidateTags(cleanTags[4:]); err != nil {
+		return nil, err
+	}
+
 	return cleanTags, nil
 }
 
+// apiControlledTag returns true if the tag key is set by the api and can't be defaulted
+func apiControlledTag(key string) bool {
+	switch key {
+	case "spinup:org", "yale:org", "spinup:spaceid", "spinup:type", "spinup:flavor":
+		return true
+	}
+	return false
+}
+
+// MergeDefaultTags returns the tags with the default tags appended in key order.  Tags passed by the caller
+// take precedence over the defaults and the api controlled tags are never defaulted.
+func MergeDefaultTags(defaults map[string]string, tags []*Tag) []*Tag {
+	if len(defaults) == 0 {
+		return tags
+	}
+
+	keys := map[string]struct{}{}
+	for _, t := range tags {
+		if t != nil {
+			keys[aws.StringValue(t.Key)] = struct{}{}
+		}
+	}
+
+	defaultKeys := make([]string, 0, len(defaults))
+	for k := range defaults {
+		if _, ok := keys[k]; ok || apiControlledTag(k) {
+			continue
+		}
+		defaultKeys = append(defaultKeys, k)
+	}
+	sort.Strings(defaultKeys)
+
+	merged := append([]*Tag{}, tags...)
+	for _, k := range defaultKeys {
+		merged = append(merged, &Tag{Key: aws.String(k), Value: aws.String(defaults[k])})
+	}
+
+	return merged
+}
+
+// ValidateDefaultTags ensures the configured default tags are valid tags and don't include the api controlled tags
+func ValidateDefaultTags(defaults map[string]string) error {
+	for k := range defaults {
+		if apiControlledTag(k) {
+			return fmt.Errorf("default tags cannot include the api controlled tag %s", k)
+		}
+	}
+
+	return ValidateTags(MergeDefaultTags(defaults, nil))
+}
+
+// tagCharacters are the characters allowed in tag keys and values: letters, numbers, spaces and _ . : / = + - @
+var tagCharacters = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// ValidateServicePropagateTags ensures the configured service tag propagation is SERVICE, TASK_DEFINITION or NONE
+func ValidateServicePropagateTags(value string) error {
+	if value == "" {
+		return nil
+	}
+	return validatePropagateTags(aws.String(value), ecs.PropagateTags_Values())
+}
+
+// ValidateTags validates the tags against the AWS tag constraints.  Keys must be between 1 and 128 characters and
+// cannot start with the reserved aws: prefix, values can be up to 256 characters and both are limited to the allowed
+// tag characters.  All of the offending tags are listed in the returned error.
+func ValidateTags(tags []*Tag) error {
+	invalid := []string{}
+	for _, t := range tags {
+		key, value := aws.StringValue(t.Key), aws.StringValue(t.Value)
+
+		var reason string
+		switch {
+		case key == "":
+			reason = "key is required"
+		case utf8.RuneCountInString(key) > 128:
+			reason = "key is longer than 128 characters"
+		case strings.HasPrefix(strings.ToLower(key), "aws:"):
+			reason = "key uses the reserved aws: prefix"
+		case !tagCharacters.MatchString(key):
+			reason = "key contains invalid characters"
+		case utf8.RuneCountInString(value) > 256:
+			reason = "value is longer than 256 characters"
+		case !tagCharacters.MatchString(value):
+			reason = "value contains invalid characters"
+		default:
+			continue
+		}
+
+		invalid = append(invalid, fmt.Sprintf("%q (%s)", key, reason))
+	}
+
+	if len(invalid) > 0 {
+		msg := fmt.Sprintf("invalid tags: %s", strings.Join(invalid, ", "))
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	return nil
+}
+
+// orgs returns the org followed by the allowed orgs, it's used to filter the resources managed by the orchestrator
+func (o *Orchestrator) orgs() []string {
+	return Orgs(o.Org, o.AllowedOrgs)
+}
+
+// Orgs returns the org followed by the allowed orgs, without duplicates
+func Orgs(org string, allowed []string) []string {
+	orgs := []string{org}
+	for _, a := range allowed {
+		if !stringInSlice(a, orgs) {
+			orgs = append(orgs, a)
+		}
+	}
+	return orgs
+}
+
+// AllowedOrg returns true if the value is the org or one of the allowed orgs
+func AllowedOrg(org string, allowed []string, value string) bool {
+	return stringInSlice(value, Orgs(org, allowed))
+}
+
 // sharedResourceTags generates a taglist for resources that are shared (clusters, roles, etc)
 func sharedResourceTags(name string, tags []*Tag) map[string]*string {
 	output := map[string]*string{}
@@ -236,3 +382,181 @@ func (o *Orchestrator) processTaskDefTagsUpdate(ctx context.Context, active *Tas
 
 	return err
 }
+
+// ServiceTagsAuditOutput lists the tags of a service, its cluster, its task definition and its repository
+// credentials side by side
+type ServiceTagsAuditOutput struct {
+	// ARNs of the audited resources
+	Service        string
+	Cluster        string
+	TaskDefinition string
+	Credentials    []string
+	// map of tag keys to the value of the tag on each resource, keyed by the resource ARN
+	Tags map[string]map[string]string
+	// sorted list of the tag keys with values that disagree between the resources
+	Drift []string
+}
+
+// ServiceTagsAudit gets the tags of a service and its related resources and reports the keys whose values have
+// drifted apart, generally from a partial tag update.  Resources missing a tag are not considered drift since
+// some tags are only applied to some resources (ie. the Name tag of the cluster).
+func (o *Orchestrator) ServiceTagsAudit(ctx context.Context, cluster, service string) (*ServiceTagsAuditOutput, error) {
+	svc, err := o.ECS.GetService(ctx, cluster, service)
+	if err != nil {
+		return nil, err
+	}
+
+	clu, err := o.ECS.GetCluster(ctx, aws.String(cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	taskDefinition, _, err := o.ECS.GetTaskDefinition(ctx, svc.TaskDefinition, false)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &ServiceTagsAuditOutput{
+		Service:        aws.StringValue(svc.ServiceArn),
+		Cluster:        aws.StringValue(clu.ClusterArn),
+		TaskDefinition: aws.StringValue(taskDefinition.TaskDefinitionArn),
+		Credentials:    []string{},
+		Tags:           map[string]map[string]string{},
+		Drift:          []string{},
+	}
+
+	add := func(resource string, key, value *string) {
+		k := aws.StringValue(key)
+		if _, ok := output.Tags[k]; !ok {
+			output.Tags[k] = map[string]string{}
+		}
+		output.Tags[k][resource] = aws.StringValue(value)
+	}
+
+	for _, resource := range []string{output.Service, output.Cluster, output.TaskDefinition} {
+		tags, err := o.ECS.ListTags(ctx, resource)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range tags {
+			add(resource, t.Key, t.Value)
+		}
+	}
+
+	credentials := map[string]struct{}{}
+	for _, cd := range taskDefinition.ContainerDefinitions {
+		if cd.RepositoryCredentials == nil || aws.StringValue(cd.RepositoryCredentials.CredentialsParameter) == "" {
+			continue
+		}
+
+		// containers can share repository credentials
+		secretArn := aws.StringValue(cd.RepositoryCredentials.CredentialsParameter)
+		if _, ok := credentials[secretArn]; ok {
+			continue
+		}
+		credentials[secretArn] = struct{}{}
+
+		secret, err := o.SecretsManager.GetSecretMetaDataWithFilter(ctx, secretArn, func(*secretsmanager.DescribeSecretOutput) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+
+		output.Credentials = append(output.Credentials, secretArn)
+		for _, t := range secret.Tags {
+			add(secretArn, t.Key, t.Value)
+		}
+	}
+
+	for key, values := range output.Tags {
+		seen := map[string]struct{}{}
+		for _, v := range values {
+			seen[v] = struct{}{}
+		}
+
+		if len(seen) > 1 {
+			output.Drift = append(output.Drift, key)
+		}
+	}
+	sort.Strings(output.Drift)
+
+	return output, nil
+}
+
+// TaskDefTagsReconcileOutput is the outcome of reconciling the spinup tags of a task definition
+type TaskDefTagsReconcileOutput struct {
+	TaskDefinition string
+	// Tagged are the spinup tags applied to the task definition, it's empty if the tags were already correct
+	Tagged []*Tag
+}
+
+// ReconcileTaskDefTags applies the spinup org, space, type and flavor tags to the latest revision of a task definition
+// family, ie. for legacy task definitions registered before they were tagged and aren't discoverable.  An existing org
+// tag is kept if it's one of the allowed orgs and the flavor defaults to task.  A task definition tagged with another
+// space is not found.
+func (o *Orchestrator) ReconcileTaskDefTags(ctx context.Context, cluster, family string) (*TaskDefTagsReconcileOutput, error) {
+	if cluster == "" || family == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "cluster and task def family are required", nil)
+	}
+
+	log.Infof("reconciling tags for task definition %s/%s", cluster, family)
+
+	td, tags, err := o.getTaskDefinition(ctx, cluster, family, true)
+	if err != nil {
+		// ECS responds with a client exception when the task definition doesn't exist
+		if aerr, ok := err.(apierror.Error); ok && aerr.Code == apierror.ErrBadRequest {
+			msg := fmt.Sprintf("taskdef %s not found", family)
+			return nil, apierror.New(apierror.ErrNotFound, msg, err)
+		}
+		return nil, err
+	}
+
+	current := map[string]string{}
+	for _, t := range tags {
+		current[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+
+	if spaceid, ok := current["spinup:spaceid"]; ok && spaceid != cluster {
+		return nil, apierror.New(apierror.ErrNotFound, "taskdef not found in cluster", nil)
+	}
+
+	flavor := "task"
+	if f, ok := current["spinup:flavor"]; ok && f != "" {
+		flavor = f
+	}
+
+	var orgTags []*Tag
+	if org, ok := current["spinup:org"]; ok {
+		orgTags = append(orgTags, &Tag{Key: aws.String("spinup:org"), Value: aws.String(org)})
+	}
+
+	desired, err := cleanTags(o.Org, o.AllowedOrgs, cluster, "container", flavor, orgTags)
+	if err != nil {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid tags on task definition", err)
+	}
+
+	output := &TaskDefTagsReconcileOutput{
+		TaskDefinition: aws.StringValue(td.TaskDefinitionArn),
+		Tagged:         []*Tag{},
+	}
+
+	for _, t := range desired {
+		if v, ok := current[aws.StringValue(t.Key)]; !ok || v != aws.StringValue(t.Value) {
+			output.Tagged = append(output.Tagged, t)
+		}
+	}
+
+	if len(output.Tagged) == 0 {
+		log.Infof("tags for task definition %s are up to date", output.TaskDefinition)
+		return output, nil
+	}
+
+	if err := o.ECS.TagResource(ctx, &ecs.TagResourceInput{
+		ResourceArn: td.TaskDefinitionArn,
+		Tags:        ecsTags(output.Tagged),
+	}); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
@@ -1,10 +1,15 @@
 package orchestration
 
 import (
+	"context"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 )
@@ -319,3 +324,516 @@ func Test_roleTags(t *testing.T) {
 		})
 	}
 }
+
+func Test_cleanTags(t *testing.T) {
+	expected := func(org string, extra ...*Tag) []*Tag {
+		return append([]*Tag{
+			{Key: aws.String("spinup:org"), Value: aws.String(org)},
+			{Key: aws.String("spinup:spaceid"), Value: aws.String("spaceid")},
+			{Key: aws.String("spinup:type"), Value: aws.String("container")},
+			{Key: aws.String("spinup:flavor"), Value: aws.String("service")},
+		}, extra...)
+	}
+
+	type args struct {
+		org     string
+		allowed []string
+		tags    []*Tag
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []*Tag
+		wantErr bool
+	}{
+		{
+			name: "no org supplied defaults to the primary org",
+			args: args{
+				org:  "myorg",
+				tags: []*Tag{{Key: aws.String("foo"), Value: aws.String("bar")}},
+			},
+			want: expected("myorg", &Tag{Key: aws.String("foo"), Value: aws.String("bar")}),
+		},
+		{
+			name: "primary org supplied",
+			args: args{
+				org:     "myorg",
+				allowed: []string{"otherorg"},
+				tags:    []*Tag{{Key: aws.String("spinup:org"), Value: aws.String("myorg")}},
+			},
+			want: expected("myorg"),
+		},
+		{
+			name: "allowed secondary org",
+			args: args{
+				org:     "myorg",
+				allowed: []string{"otherorg", "thirdorg"},
+				tags: []*Tag{
+					{Key: aws.String("spinup:org"), Value: aws.String("otherorg")},
+					{Key: aws.String("spinup:spaceid"), Value: aws.String("notmyspace")},
+				},
+			},
+			want: expected("otherorg"),
+		},
+		{
+			name: "allowed secondary org as yale:org",
+			args: args{
+				org:     "myorg",
+				allowed: []string{"otherorg"},
+				tags:    []*Tag{{Key: aws.String("yale:org"), Value: aws.String("otherorg")}},
+			},
+			want: expected("otherorg"),
+		},
+		{
+			name: "disallowed org",
+			args: args{
+				org:     "myorg",
+				allowed: []string{"otherorg"},
+				tags:    []*Tag{{Key: aws.String("spinup:org"), Value: aws.String("evilorg")}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "reserved tag key",
+			args: args{
+				org:  "myorg",
+				tags: []*Tag{{Key: aws.String("aws:cloudformation:stack-name"), Value: aws.String("bar")}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "over-length tag key",
+			args: args{
+				org:  "myorg",
+				tags: []*Tag{{Key: aws.String(strings.Repeat("k", 129)), Value: aws.String("bar")}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "single org rejects other orgs",
+			args: args{
+				org:  "myorg",
+				tags: []*Tag{{Key: aws.String("spinup:org"), Value: aws.String("otherorg")}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cleanTags(tt.args.org, tt.args.allowed, "spaceid", "container", "service", tt.args.tags)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("cleanTags() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cleanTags() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeDefaultTags(t *testing.T) {
+	defaults := map[string]string{
+		"ManagedBy":  "spinup",
+		"CostCenter": "1234",
+		"spinup:org": "otherorg",
+	}
+
+	tests := []struct {
+		name     string
+		defaults map[string]string
+		tags     []*Tag
+		want     []*Tag
+	}{
+		{
+			name: "no defaults",
+			tags: []*Tag{{Key: aws.String("Application"), Value: aws.String("app")}},
+			want: []*Tag{{Key: aws.String("Application"), Value: aws.String("app")}},
+		},
+		{
+			name:     "no tags",
+			defaults: defaults,
+			want: []*Tag{
+				{Key: aws.String("CostCenter"), Value: aws.String("1234")},
+				{Key: aws.String("ManagedBy"), Value: aws.String("spinup")},
+			},
+		},
+		{
+			name:     "caller tags take precedence",
+			defaults: defaults,
+			tags: []*Tag{
+				{Key: aws.String("Application"), Value: aws.String("app")},
+				{Key: aws.String("CostCenter"), Value: aws.String("5678")},
+			},
+			want: []*Tag{
+				{Key: aws.String("Application"), Value: aws.String("app")},
+				{Key: aws.String("CostCenter"), Value: aws.String("5678")},
+				{Key: aws.String("ManagedBy"), Value: aws.String("spinup")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeDefaultTags(tt.defaults, tt.tags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeDefaultTags() = %s, want %s", awsutil.Prettify(got), awsutil.Prettify(tt.want))
+			}
+		})
+	}
+}
+
+func TestValidateDefaultTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			defaults: map[string]string{"CostCenter": "1234", "ManagedBy": "spinup"},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:     "api controlled tag",
+			defaults: map[string]string{"spinup:spaceid": "space1"},
+			wantErr:  true,
+		},
+		{
+			name:     "reserved prefix",
+			defaults: map[string]string{"aws:cost": "1234"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateDefaultTags(tt.defaults); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDefaultTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateServicePropagateTags(t *testing.T) {
+	for value, wantErr := range map[string]bool{"": false, "SERVICE": false, "TASK_DEFINITION": false, "NONE": false, "EVERYWHERE": true} {
+		if err := ValidateServicePropagateTags(value); (err != nil) != wantErr {
+			t.Errorf("ValidateServicePropagateTags(%q) error = %v, wantErr %v", value, err, wantErr)
+		}
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []*Tag
+		wantErr string
+	}{
+		{
+			name: "valid tags",
+			tags: []*Tag{
+				{Key: aws.String("Application"), Value: aws.String("my app")},
+				{Key: aws.String("team:owner"), Value: aws.String("someone@example.com")},
+				{Key: aws.String("path/to+thing=1_2.3-4"), Value: aws.String("")},
+				{Key: aws.String("ключ"), Value: aws.String("値")},
+				{Key: aws.String(strings.Repeat("k", 128)), Value: aws.String(strings.Repeat("v", 256))},
+			},
+		},
+		{
+			name:    "over-length key",
+			tags:    []*Tag{{Key: aws.String(strings.Repeat("k", 129)), Value: aws.String("bar")}},
+			wantErr: "key is longer than 128 characters",
+		},
+		{
+			name:    "over-length value",
+			tags:    []*Tag{{Key: aws.String("foo"), Value: aws.String(strings.Repeat("v", 257))}},
+			wantErr: "value is longer than 256 characters",
+		},
+		{
+			name:    "reserved prefix",
+			tags:    []*Tag{{Key: aws.String("AWS:foo"), Value: aws.String("bar")}},
+			wantErr: `"AWS:foo" (key uses the reserved aws: prefix)`,
+		},
+		{
+			name:    "empty key",
+			tags:    []*Tag{{Value: aws.String("bar")}},
+			wantErr: "key is required",
+		},
+		{
+			name:    "invalid key characters",
+			tags:    []*Tag{{Key: aws.String("foo*"), Value: aws.String("bar")}},
+			wantErr: `"foo*" (key contains invalid characters)`,
+		},
+		{
+			name:    "invalid value characters",
+			tags:    []*Tag{{Key: aws.String("foo"), Value: aws.String("bar#")}},
+			wantErr: `"foo" (value contains invalid characters)`,
+		},
+		{
+			name: "lists all of the offending tags",
+			tags: []*Tag{
+				{Key: aws.String("aws:foo"), Value: aws.String("bar")},
+				{Key: aws.String("ok"), Value: aws.String("bar")},
+				{Key: aws.String("baz"), Value: aws.String(strings.Repeat("v", 257))},
+			},
+			wantErr: `invalid tags: "aws:foo" (key uses the reserved aws: prefix), "baz" (value is longer than 256 characters)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTags(tt.tags)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected nil error, got %s", err)
+				}
+				return
+			}
+
+			aerr, ok := err.(apierror.Error)
+			if !ok || aerr.Code != apierror.ErrBadRequest {
+				t.Fatalf("expected bad request error, got %v", err)
+			}
+
+			if !strings.Contains(aerr.Message, tt.wantErr) {
+				t.Errorf("expected error message to contain %s, got %s", tt.wantErr, aerr.Message)
+			}
+		})
+	}
+}
+
+func TestOrgs(t *testing.T) {
+	if got := Orgs("myorg", []string{"otherorg", "myorg"}); !reflect.DeepEqual(got, []string{"myorg", "otherorg"}) {
+		t.Errorf("expected orgs [myorg otherorg], got %v", got)
+	}
+
+	if got := Orgs("myorg", nil); !reflect.DeepEqual(got, []string{"myorg"}) {
+		t.Errorf("expected orgs [myorg], got %v", got)
+	}
+}
+
+func TestAllowedOrg(t *testing.T) {
+	for org, want := range map[string]bool{"myorg": true, "otherorg": true, "foreignorg": false, "": false} {
+		if got := AllowedOrg("myorg", []string{"otherorg", "myorg"}, org); got != want {
+			t.Errorf("expected AllowedOrg(%q) to be %t, got %t", org, want, got)
+		}
+	}
+}
+
+func TestOrchestrator_ServiceTagsAudit(t *testing.T) {
+	serviceArn := "arn:aws:ecs:us-east-1:0123456789:service/creds"
+	clusterArn := "arn:aws:ecs:us-east-1:1234567890:cluster/cluster1"
+	taskDefinitionArn := "arn:aws:ecs:us-east-1:0123456789:task-definition/credsapp:1"
+	secretArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/creds-AbCdEf"
+
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	o.ECS.Service.(*mockECSClient).tags = map[string][]*ecs.Tag{
+		serviceArn: {
+			{Key: aws.String("spinup:org"), Value: aws.String("myorg")},
+			{Key: aws.String("Application"), Value: aws.String("website")},
+			{Key: aws.String("CostCenter"), Value: aws.String("1234")},
+		},
+		clusterArn: {
+			{Key: aws.String("spinup:org"), Value: aws.String("myorg")},
+			{Key: aws.String("Name"), Value: aws.String("cluster1")},
+			{Key: aws.String("Application"), Value: aws.String("website")},
+			{Key: aws.String("CostCenter"), Value: aws.String("5678")},
+		},
+		taskDefinitionArn: {
+			{Key: aws.String("spinup:org"), Value: aws.String("myorg")},
+			{Key: aws.String("Application"), Value: aws.String("website")},
+			{Key: aws.String("CostCenter"), Value: aws.String("1234")},
+		},
+	}
+	o.SecretsManager.Service.(*mockSMClient).tags = map[string][]*secretsmanager.Tag{
+		secretArn: {
+			{Key: aws.String("spinup:org"), Value: aws.String("myorg")},
+			{Key: aws.String("Application"), Value: aws.String("intranet")},
+			{Key: aws.String("CostCenter"), Value: aws.String("1234")},
+		},
+	}
+
+	got, err := o.ServiceTagsAudit(context.TODO(), "cluster1", "creds")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	want := &ServiceTagsAuditOutput{
+		Service:        serviceArn,
+		Cluster:        clusterArn,
+		TaskDefinition: taskDefinitionArn,
+		Credentials:    []string{secretArn},
+		Tags: map[string]map[string]string{
+			"spinup:org": {
+				serviceArn:        "myorg",
+				clusterArn:        "myorg",
+				taskDefinitionArn: "myorg",
+				secretArn:         "myorg",
+			},
+			"Name": {
+				clusterArn: "cluster1",
+			},
+			"Application": {
+				serviceArn:        "website",
+				clusterArn:        "website",
+				taskDefinitionArn: "website",
+				secretArn:         "intranet",
+			},
+			"CostCenter": {
+				serviceArn:        "1234",
+				clusterArn:        "5678",
+				taskDefinitionArn: "1234",
+				secretArn:         "1234",
+			},
+		},
+		Drift: []string{"Application", "CostCenter"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	// test a missing service
+	if _, err := o.ServiceTagsAudit(context.TODO(), "cluster1", "missing"); err == nil {
+		t.Error("expected error for missing service, got nil")
+	}
+}
+
+func TestOrchestrator_ReconcileTaskDefTags(t *testing.T) {
+	webappArn := "arn:aws:ecs:us-east-1:0123456789:task-definition/webapp:3"
+	otherappArn := "arn:aws:ecs:us-east-1:0123456789:task-definition/otherapp:1"
+	prefixedArn := "arn:aws:ecs:us-east-1:0123456789:task-definition/myorg-cluster1-prefixedapp:1"
+
+	spinupTags := func(org, spaceid, flavor string) []*Tag {
+		return []*Tag{
+			{Key: aws.String("spinup:org"), Value: aws.String(org)},
+			{Key: aws.String("spinup:spaceid"), Value: aws.String(spaceid)},
+			{Key: aws.String("spinup:type"), Value: aws.String("container")},
+			{Key: aws.String("spinup:flavor"), Value: aws.String(flavor)},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		family   string
+		prefix   bool
+		tags     map[string][]*ecs.Tag
+		wantArn  string
+		want     []*Tag
+		wantTags []*Tag
+		wantCode string
+	}{
+		{
+			name:     "untagged family",
+			family:   "webapp",
+			wantArn:  webappArn,
+			want:     spinupTags("myorg", "cluster1", "task"),
+			wantTags: spinupTags("myorg", "cluster1", "task"),
+		},
+		{
+			name:   "already tagged",
+			family: "otherapp",
+			tags: map[string][]*ecs.Tag{
+				otherappArn: ecsTags(spinupTags("myorg", "cluster1", "task")),
+			},
+			wantArn:  otherappArn,
+			want:     []*Tag{},
+			wantTags: spinupTags("myorg", "cluster1", "task"),
+		},
+		{
+			name:   "partially tagged with an allowed org and flavor",
+			family: "otherapp",
+			tags: map[string][]*ecs.Tag{
+				otherappArn: {
+					{Key: aws.String("spinup:org"), Value: aws.String("otherorg")},
+					{Key: aws.String("spinup:flavor"), Value: aws.String("service")},
+				},
+			},
+			wantArn: otherappArn,
+			want: []*Tag{
+				{Key: aws.String("spinup:spaceid"), Value: aws.String("cluster1")},
+				{Key: aws.String("spinup:type"), Value: aws.String("container")},
+			},
+			wantTags: []*Tag{
+				{Key: aws.String("spinup:org"), Value: aws.String("otherorg")},
+				{Key: aws.String("spinup:flavor"), Value: aws.String("service")},
+				{Key: aws.String("spinup:spaceid"), Value: aws.String("cluster1")},
+				{Key: aws.String("spinup:type"), Value: aws.String("container")},
+			},
+		},
+		{
+			name:     "prefixed family",
+			family:   "prefixedapp",
+			prefix:   true,
+			wantArn:  prefixedArn,
+			want:     spinupTags("myorg", "cluster1", "task"),
+			wantTags: spinupTags("myorg", "cluster1", "task"),
+		},
+		{
+			name:     "legacy unprefixed family",
+			family:   "webapp",
+			prefix:   true,
+			wantArn:  webappArn,
+			want:     spinupTags("myorg", "cluster1", "task"),
+			wantTags: spinupTags("myorg", "cluster1", "task"),
+		},
+		{
+			name:   "tagged with another space",
+			family: "otherapp",
+			tags: map[string][]*ecs.Tag{
+				otherappArn: {{Key: aws.String("spinup:spaceid"), Value: aws.String("cluster2")}},
+			},
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:   "tagged with another org",
+			family: "otherapp",
+			tags: map[string][]*ecs.Tag{
+				otherappArn: {{Key: aws.String("spinup:org"), Value: aws.String("notmyorg")}},
+			},
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "missing family",
+			family:   "missingapp",
+			wantCode: apierror.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.AllowedOrgs = []string{"otherorg"}
+			o.PrefixTaskDefinitionFamilies = tt.prefix
+
+			client := o.ECS.Service.(*mockECSClient)
+			client.tags = tt.tags
+
+			got, err := o.ReconcileTaskDefTags(context.TODO(), "cluster1", tt.family)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if got.TaskDefinition != tt.wantArn {
+				t.Errorf("expected task definition %s, got %s", tt.wantArn, got.TaskDefinition)
+			}
+
+			if !reflect.DeepEqual(got.Tagged, tt.want) {
+				t.Errorf("expected tagged %s, got %s", awsutil.Prettify(tt.want), awsutil.Prettify(got.Tagged))
+			}
+
+			if wantTags := ecsTags(tt.wantTags); !reflect.DeepEqual(client.tags[tt.wantArn], wantTags) {
+				t.Errorf("expected task definition tags %s, got %s", awsutil.Prettify(wantTags), awsutil.Prettify(client.tags[tt.wantArn]))
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 
 	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
@@ -22,6 +23,14 @@ func (o *Orchestrator) processTaskDefinitionCreate(ctx context.Context, input *S
 		return nil, rbfunc, apierror.New(apierror.ErrBadRequest, "task definition cannot be nil", nil)
 	}
 
+	if err := applySecretKeys(input.TaskDefinition.ContainerDefinitions, input.SecretKeys); err != nil {
+		return nil, rbfunc, err
+	}
+
+	if err := o.validateTaskDefinition(input.TaskDefinition, taskDefinitionCompatibilities(input.TaskDefinition)); err != nil {
+		return nil, rbfunc, err
+	}
+
 	if input.Cluster == nil || input.Cluster.ClusterName == nil {
 		return nil, rbfunc, apierror.New(apierror.ErrBadRequest, "cluster cannot be nil", nil)
 	}
@@ -40,23 +49,28 @@ func (o *Orchestrator) processTaskDefinitionCreate(ctx context.Context, input *S
 	// role name is clustername-ecsTaskExecution
 	roleName := fmt.Sprintf("%s-ecsTaskExecution", aws.StringValue(input.Cluster.ClusterName))
 
-	roleARN, err := o.DefaultTaskExecutionRole(ctx, path, roleName, input.Tags)
+	kmsKeyArns, err := o.repositoryCredentialsKmsKeyArns(ctx, input.Credentials)
 	if err != nil {
 		return nil, rbfunc, err
 	}
 
-	input.TaskDefinition.ExecutionRoleArn = aws.String(roleARN)
-	input.TaskDefinition.TaskRoleArn = aws.String(roleARN)
-	input.TaskDefinition.RequiresCompatibilities = DefaultCompatabilities
-	input.TaskDefinition.NetworkMode = DefaultNetworkMode
+	roleARN, err := o.DefaultTaskExecutionRole(ctx, path, roleName, input.Tags, environmentFileArns(input.TaskDefinition.ContainerDefinitions), kmsKeyArns)
+	if err != nil {
+		return nil, rbfunc, err
+	}
 
-	logConfiguration, err := o.defaultLogConfiguration(ctx, aws.StringValue(input.Cluster.ClusterName), aws.StringValue(input.TaskDefinition.Family), input.Tags)
+	taskRoleARN, err := o.taskRole(ctx, input.TaskDefinition.TaskRoleArn, roleARN)
 	if err != nil {
 		return nil, rbfunc, err
 	}
 
-	for _, cd := range input.TaskDefinition.ContainerDefinitions {
-		cd.SetLogConfiguration(logConfiguration)
+	input.TaskDefinition.ExecutionRoleArn = aws.String(roleARN)
+	input.TaskDefinition.TaskRoleArn = taskRoleARN
+	input.TaskDefinition.RequiresCompatibilities = taskDefinitionCompatibilities(input.TaskDefinition)
+	input.TaskDefinition.NetworkMode = DefaultNetworkMode
+
+	if err := o.processLogConfiguration(ctx, aws.StringValue(input.Cluster.ClusterName), aws.StringValue(input.TaskDefinition.Family), input.TaskDefinition.ContainerDefinitions, input.SkipLogConfiguration, input.AwslogsRegion, input.Tags); err != nil {
+		return nil, rbfunc, err
 	}
 
 	taskDefinition, err := o.ECS.CreateTaskDefinition(ctx, input.TaskDefinition)
@@ -89,6 +103,14 @@ func (o *Orchestrator) processTaskDefTaskDefinitionCreate(ctx context.Context, i
 		return nil, rbfunc, apierror.New(apierror.ErrBadRequest, "task definition cannot be nil", nil)
 	}
 
+	if err := applySecretKeys(input.TaskDefinition.ContainerDefinitions, input.SecretKeys); err != nil {
+		return nil, rbfunc, err
+	}
+
+	if err := o.validateTaskDefinition(input.TaskDefinition, taskDefinitionCompatibilities(input.TaskDefinition)); err != nil {
+		return nil, rbfunc, err
+	}
+
 	if input.Cluster == nil || input.Cluster.ClusterName == nil {
 		return nil, rbfunc, apierror.New(apierror.ErrBadRequest, "cluster cannot be nil", nil)
 	}
@@ -103,25 +125,32 @@ func (o *Orchestrator) processTaskDefTaskDefinitionCreate(ctx context.Context, i
 	// role name is clustername-ecsTaskExecution
 	roleName := fmt.Sprintf("%s-ecsTaskExecution", aws.StringValue(input.Cluster.ClusterName))
 
-	roleARN, err := o.DefaultTaskExecutionRole(ctx, path, roleName, input.Tags)
+	kmsKeyArns, err := o.repositoryCredentialsKmsKeyArns(ctx, input.Credentials)
 	if err != nil {
 		return nil, rbfunc, err
 	}
 
-	input.TaskDefinition.ExecutionRoleArn = aws.String(roleARN)
-	input.TaskDefinition.TaskRoleArn = aws.String(roleARN)
-	input.TaskDefinition.RequiresCompatibilities = DefaultCompatabilities
-	input.TaskDefinition.NetworkMode = DefaultNetworkMode
+	roleARN, err := o.DefaultTaskExecutionRole(ctx, path, roleName, input.Tags, environmentFileArns(input.TaskDefinition.ContainerDefinitions), kmsKeyArns)
+	if err != nil {
+		return nil, rbfunc, err
+	}
 
-	logConfiguration, err := o.defaultLogConfiguration(ctx, aws.StringValue(input.Cluster.ClusterName), aws.StringValue(input.TaskDefinition.Family), input.Tags)
+	taskRoleARN, err := o.taskRole(ctx, input.TaskDefinition.TaskRoleArn, roleARN)
 	if err != nil {
 		return nil, rbfunc, err
 	}
 
-	for _, cd := range input.TaskDefinition.ContainerDefinitions {
-		cd.SetLogConfiguration(logConfiguration)
+	input.TaskDefinition.ExecutionRoleArn = aws.String(roleARN)
+	input.TaskDefinition.TaskRoleArn = taskRoleARN
+	input.TaskDefinition.RequiresCompatibilities = taskDefinitionCompatibilities(input.TaskDefinition)
+	input.TaskDefinition.NetworkMode = DefaultNetworkMode
+
+	if err := o.processLogConfiguration(ctx, aws.StringValue(input.Cluster.ClusterName), aws.StringValue(input.TaskDefinition.Family), input.TaskDefinition.ContainerDefinitions, input.SkipLogConfiguration, input.AwslogsRegion, input.Tags); err != nil {
+		return nil, rbfunc, err
 	}
 
+	input.TaskDefinition.Family = aws.String(o.taskDefFamily(aws.StringValue(input.Cluster.ClusterName), aws.StringValue(input.TaskDefinition.Family)))
+
 	taskDefinition, err := o.ECS.CreateTaskDefinition(ctx, input.TaskDefinition)
 	if err != nil {
 		return nil, rbfunc, err
@@ -149,6 +178,14 @@ func (o *Orchestrator) processTaskDefinitionUpdate(ctx context.Context, input *S
 		return apierror.New(apierror.ErrBadRequest, "task definition cannot be nil", nil)
 	}
 
+	if err := applySecretKeys(input.TaskDefinition.ContainerDefinitions, input.SecretKeys); err != nil {
+		return err
+	}
+
+	if err := o.validateTaskDefinition(input.TaskDefinition, taskDefinitionCompatibilities(input.TaskDefinition)); err != nil {
+		return err
+	}
+
 	if input.Service == nil {
 		return apierror.New(apierror.ErrBadRequest, "service cannot be nil", nil)
 	}
@@ -161,14 +198,24 @@ func (o *Orchestrator) processTaskDefinitionUpdate(ctx context.Context, input *S
 	// role name is clustername-ecsTaskExecution
 	roleName := fmt.Sprintf("%s-ecsTaskExecution", input.ClusterName)
 
-	roleARN, err := o.DefaultTaskExecutionRole(ctx, path, roleName, input.Tags)
+	kmsKeyArns, err := o.repositoryCredentialsKmsKeyArns(ctx, input.Credentials)
+	if err != nil {
+		return err
+	}
+
+	roleARN, err := o.DefaultTaskExecutionRole(ctx, path, roleName, input.Tags, environmentFileArns(input.TaskDefinition.ContainerDefinitions), kmsKeyArns)
 	if err != nil {
 		return err
 	}
 
 	log.Debugf("setting roleARN: %s", roleARN)
+	taskRoleARN, err := o.taskRole(ctx, input.TaskDefinition.TaskRoleArn, roleARN)
+	if err != nil {
+		return err
+	}
+
 	input.TaskDefinition.ExecutionRoleArn = aws.String(roleARN)
-	input.TaskDefinition.TaskRoleArn = aws.String(roleARN)
+	input.TaskDefinition.TaskRoleArn = taskRoleARN
 
 	if len(input.TaskDefinition.RequiresCompatibilities) == 0 {
 		log.Debugf("setting default compatabilities: %+v", DefaultCompatabilities)
@@ -189,15 +236,10 @@ func (o *Orchestrator) processTaskDefinitionUpdate(ctx context.Context, input *S
 		tags = et
 	}
 
-	logConfiguration, err := o.defaultLogConfiguration(ctx, input.ClusterName, aws.StringValue(input.TaskDefinition.Family), tags)
-	if err != nil {
+	if err := o.processLogConfiguration(ctx, input.ClusterName, aws.StringValue(input.TaskDefinition.Family), input.TaskDefinition.ContainerDefinitions, input.SkipLogConfiguration, input.AwslogsRegion, tags); err != nil {
 		return err
 	}
 
-	for _, cd := range input.TaskDefinition.ContainerDefinitions {
-		cd.SetLogConfiguration(logConfiguration)
-	}
-
 	log.Infof("creating task definition %+v", input.TaskDefinition)
 
 	out, err := o.ECS.CreateTaskDefinition(ctx, input.TaskDefinition)
@@ -216,11 +258,60 @@ func (o *Orchestrator) processTaskDefinitionUpdate(ctx context.Context, input *S
 	return nil
 }
 
+// processTaskDefinitionRevisionUpdate pins the service to an existing task definition revision instead of registering a
+// new one.  The revision must be active and belong to the same family as the active task definition.
+func (o *Orchestrator) processTaskDefinitionRevisionUpdate(ctx context.Context, input *ServiceOrchestrationUpdateInput, active *ServiceOrchestrationUpdateOutput) error {
+	if input == nil || input.TaskDefinitionRevision == "" {
+		return apierror.New(apierror.ErrBadRequest, "task definition revision cannot be empty", nil)
+	}
+
+	if active == nil || active.TaskDefinition == nil {
+		return apierror.New(apierror.ErrBadRequest, "active task definition cannot be nil", nil)
+	}
+
+	log.Debugf("processing task definition revision update to %s", input.TaskDefinitionRevision)
+
+	taskDefinition, _, err := o.ECS.GetTaskDefinition(ctx, aws.String(input.TaskDefinitionRevision), false)
+	if err != nil {
+		return err
+	}
+
+	family := aws.StringValue(active.TaskDefinition.Family)
+	if aws.StringValue(taskDefinition.Family) != family {
+		msg := fmt.Sprintf("task definition revision %s doesn't belong to the family %s", input.TaskDefinitionRevision, family)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	if status := aws.StringValue(taskDefinition.Status); status != ecs.TaskDefinitionStatusActive {
+		msg := fmt.Sprintf("task definition revision %s is %s", input.TaskDefinitionRevision, status)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	active.TaskDefinition = taskDefinition
+
+	if input.Service == nil {
+		input.Service = &ecs.UpdateServiceInput{}
+	}
+
+	// apply the existing task definition ARN to the service update
+	input.Service.TaskDefinition = taskDefinition.TaskDefinitionArn
+
+	return nil
+}
+
 func (o *Orchestrator) processTaskDefTaskDefinitionUpdate(ctx context.Context, input *TaskDefUpdateOrchestrationInput, active *TaskDefUpdateOrchestrationOutput) error {
 	if input == nil || input.TaskDefinition == nil {
 		return apierror.New(apierror.ErrBadRequest, "task definition cannot be nil", nil)
 	}
 
+	if err := applySecretKeys(input.TaskDefinition.ContainerDefinitions, input.SecretKeys); err != nil {
+		return err
+	}
+
+	if err := o.validateTaskDefinition(input.TaskDefinition, taskDefinitionCompatibilities(input.TaskDefinition)); err != nil {
+		return err
+	}
+
 	log.Debugf("processing task definition update for a task %+v", input.TaskDefinition)
 
 	// path is org/clustername
@@ -229,14 +320,24 @@ func (o *Orchestrator) processTaskDefTaskDefinitionUpdate(ctx context.Context, i
 	// role name is clustername-ecsTaskExecution
 	roleName := fmt.Sprintf("%s-ecsTaskExecution", input.ClusterName)
 
-	roleARN, err := o.DefaultTaskExecutionRole(ctx, path, roleName, input.Tags)
+	kmsKeyArns, err := o.repositoryCredentialsKmsKeyArns(ctx, input.Credentials)
+	if err != nil {
+		return err
+	}
+
+	roleARN, err := o.DefaultTaskExecutionRole(ctx, path, roleName, input.Tags, environmentFileArns(input.TaskDefinition.ContainerDefinitions), kmsKeyArns)
+	if err != nil {
+		return err
+	}
+
+	taskRoleARN, err := o.taskRole(ctx, input.TaskDefinition.TaskRoleArn, roleARN)
 	if err != nil {
 		return err
 	}
 
 	input.TaskDefinition.ExecutionRoleArn = aws.String(roleARN)
-	input.TaskDefinition.TaskRoleArn = aws.String(roleARN)
-	input.TaskDefinition.RequiresCompatibilities = DefaultCompatabilities
+	input.TaskDefinition.TaskRoleArn = taskRoleARN
+	input.TaskDefinition.RequiresCompatibilities = taskDefinitionCompatibilities(input.TaskDefinition)
 	input.TaskDefinition.NetworkMode = DefaultNetworkMode
 
 	tags := input.Tags
@@ -248,13 +349,14 @@ func (o *Orchestrator) processTaskDefTaskDefinitionUpdate(ctx context.Context, i
 		tags = et
 	}
 
-	logConfiguration, err := o.defaultLogConfiguration(ctx, input.ClusterName, aws.StringValue(input.TaskDefinition.Family), tags)
-	if err != nil {
+	streamPrefix := o.trimTaskDefFamily(input.ClusterName, aws.StringValue(input.TaskDefinition.Family))
+	if err := o.processLogConfiguration(ctx, input.ClusterName, streamPrefix, input.TaskDefinition.ContainerDefinitions, input.SkipLogConfiguration, input.AwslogsRegion, tags); err != nil {
 		return err
 	}
 
-	for _, cd := range input.TaskDefinition.ContainerDefinitions {
-		cd.SetLogConfiguration(logConfiguration)
+	// keep registering legacy task definitions found by their bare family name in the same family
+	if active.TaskDefinition == nil || aws.StringValue(active.TaskDefinition.Family) != aws.StringValue(input.TaskDefinition.Family) {
+		input.TaskDefinition.Family = aws.String(o.taskDefFamily(input.ClusterName, aws.StringValue(input.TaskDefinition.Family)))
 	}
 
 	taskDefinition, err := o.ECS.CreateTaskDefinition(ctx, input.TaskDefinition)
@@ -267,12 +369,72 @@ func (o *Orchestrator) processTaskDefTaskDefinitionUpdate(ctx context.Context, i
 	return nil
 }
 
+// processLogConfiguration applies the default log configuration to the container definitions.  Container definitions
+// named in skip keep the log configuration provided by the caller (or none).  The log group is named with the log group
+// name pattern and is only created if at least one container definition ends up using the awslogs driver.  The region overrides the awslogs-region if it's set.
+func (o *Orchestrator) processLogConfiguration(ctx context.Context, cluster, streamPrefix string, containerDefinitions []*ecs.ContainerDefinition, skip []string, region string, tags []*Tag) error {
+	if region != "" && !awsRegion.MatchString(region) {
+		msg := fmt.Sprintf("invalid awslogs region %s", region)
+		return apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	skipped := make(map[string]struct{}, len(skip))
+	for _, name := range skip {
+		skipped[name] = struct{}{}
+	}
+
+	usesAwslogs := false
+	for _, cd := range containerDefinitions {
+		if _, ok := skipped[aws.StringValue(cd.Name)]; !ok {
+			usesAwslogs = true
+			break
+		}
+
+		if cd.LogConfiguration != nil && aws.StringValue(cd.LogConfiguration.LogDriver) == "awslogs" {
+			usesAwslogs = true
+		}
+	}
+
+	logGroup := o.logGroupName(cluster, streamPrefix)
+	if !usesAwslogs {
+		log.Infof("no container definitions in %s use the awslogs driver, not creating log group %s", streamPrefix, logGroup)
+		return nil
+	}
+
+	logConfiguration, err := o.defaultLogConfiguration(ctx, logGroup, streamPrefix, tags)
+	if err != nil {
+		return err
+	}
+
+	if region != "" {
+		logConfiguration.Options["awslogs-region"] = aws.String(region)
+	}
+
+	for _, cd := range containerDefinitions {
+		name := aws.StringValue(cd.Name)
+		if _, ok := skipped[name]; ok {
+			log.Debugf("skipping default log configuration for container definition %s", name)
+			continue
+		}
+
+		cd.SetLogConfiguration(logConfiguration)
+	}
+
+	return nil
+}
+
 // defaultLogConfiguration generates a log group and sets retention on the log group.  It returns the default log configuration.
 func (o *Orchestrator) defaultLogConfiguration(ctx context.Context, logGroup, streamPrefix string, tags []*Tag) (*ecs.LogConfiguration, error) {
 	if logGroup == "" {
 		return nil, errors.New("cloudwatch logs group name cannot be empty")
 	}
 
+	// validate the delivery options before creating anything
+	deliveryOptions, err := o.awslogsDeliveryOptions()
+	if err != nil {
+		return nil, err
+	}
+
 	var tagsMap = make(map[string]*string)
 	for _, tag := range tags {
 		tagsMap[aws.StringValue(tag.Key)] = tag.Value
@@ -301,13 +463,56 @@ func (o *Orchestrator) defaultLogConfiguration(ctx context.Context, logGroup, st
 		return nil, err
 	}
 
+	options := map[string]*string{
+		"awslogs-region":        aws.String(o.awslogsRegion()),
+		"awslogs-create-group":  aws.String("true"),
+		"awslogs-group":         aws.String(logGroup),
+		"awslogs-stream-prefix": aws.String(streamPrefix),
+	}
+
+	for k, v := range deliveryOptions {
+		options[k] = v
+	}
+
 	return &ecs.LogConfiguration{
 		LogDriver: aws.String("awslogs"),
-		Options: map[string]*string{
-			"awslogs-region":        aws.String("us-east-1"),
-			"awslogs-create-group":  aws.String("true"),
-			"awslogs-group":         aws.String(logGroup),
-			"awslogs-stream-prefix": aws.String(streamPrefix),
-		},
+		Options:   options,
 	}, nil
 }
+
+// awsRegion matches the format of an AWS region name, ie. us-east-1 or us-gov-west-1
+var awsRegion = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+
+// awslogsRegion returns the default awslogs-region, the region of the account's cloudwatch logs session.  It falls
+// back to us-east-1 if the region isn't known.
+func (o *Orchestrator) awslogsRegion() string {
+	if o.CloudWatchLogs.Region != "" {
+		return o.CloudWatchLogs.Region
+	}
+	return "us-east-1"
+}
+
+// awslogsDeliveryOptions returns the configured awslogs-mode and max-buffer-size log options.  Nothing is returned if the
+// mode isn't configured, which leaves the awslogs driver in the default blocking mode.
+func (o *Orchestrator) awslogsDeliveryOptions() (map[string]*string, error) {
+	options := map[string]*string{}
+
+	switch o.AwslogsMode {
+	case "", "blocking":
+		if o.AwslogsMaxBufferSize != "" {
+			return nil, errors.New("awslogs max buffer size requires the non-blocking awslogs mode")
+		}
+	case "non-blocking":
+		if o.AwslogsMaxBufferSize != "" {
+			options["max-buffer-size"] = aws.String(o.AwslogsMaxBufferSize)
+		}
+	default:
+		return nil, fmt.Errorf("invalid awslogs mode %s, must be blocking or non-blocking", o.AwslogsMode)
+	}
+
+	if o.AwslogsMode != "" {
+		options["awslogs-mode"] = aws.String(o.AwslogsMode)
+	}
+
+	return options, nil
+}
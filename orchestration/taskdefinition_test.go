@@ -5,14 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
 )
 
 func (m *mockCWLClient) CreateLogGroupWithContext(ctx context.Context, input *cloudwatchlogs.CreateLogGroupInput, opts ...request.Option) (*cloudwatchlogs.CreateLogGroupOutput, error) {
@@ -20,6 +24,8 @@ func (m *mockCWLClient) CreateLogGroupWithContext(ctx context.Context, input *cl
 		return nil, m.err
 	}
 
+	m.logGroups = append(m.logGroups, aws.StringValue(input.LogGroupName))
+
 	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
 }
 
@@ -60,6 +66,414 @@ func (m *mockECSClient) RegisterTaskDefinitionWithContext(ctx aws.Context, input
 	return output, nil
 }
 
+var testTaskDefinitionRevisions = []*ecs.TaskDefinition{
+	{
+		Family:            aws.String("webapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("INACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/webapp:1"),
+	},
+	{
+		Family:            aws.String("webapp"),
+		Revision:          aws.Int64(2),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/webapp:2"),
+	},
+	{
+		Family:            aws.String("webapp"),
+		Revision:          aws.Int64(3),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/webapp:3"),
+	},
+	{
+		Family:            aws.String("otherapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/otherapp:1"),
+	},
+	{
+		Compatibilities:         aws.StringSlice([]string{"EC2", "FARGATE"}),
+		Cpu:                     aws.String("256"),
+		Family:                  aws.String("fargateapp"),
+		Memory:                  aws.String("512"),
+		NetworkMode:             aws.String("awsvpc"),
+		RequiresAttributes:      []*ecs.Attribute{{Name: aws.String("ecs.capability.task-eni")}},
+		RequiresCompatibilities: aws.StringSlice([]string{"FARGATE"}),
+		Revision:                aws.Int64(1),
+		Status:                  aws.String("ACTIVE"),
+		TaskDefinitionArn:       aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/fargateapp:1"),
+	},
+	{
+		Compatibilities:         aws.StringSlice([]string{"EC2"}),
+		ExecutionRoleArn:        aws.String("arn:aws:iam::12345678910:role/clu1-ecsTaskExecution"),
+		Family:                  aws.String("ec2app"),
+		Memory:                  aws.String("1024"),
+		NetworkMode:             aws.String("bridge"),
+		RequiresAttributes:      []*ecs.Attribute{{Name: aws.String("com.amazonaws.ecs.capability.docker-remote-api.1.18")}},
+		RequiresCompatibilities: aws.StringSlice([]string{"EC2"}),
+		Revision:                aws.Int64(1),
+		Status:                  aws.String("ACTIVE"),
+		TaskDefinitionArn:       aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/ec2app:1"),
+	},
+	{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("web"),
+				LogConfiguration: &ecs.LogConfiguration{
+					LogDriver: aws.String("awslogs"),
+					Options:   map[string]*string{"awslogs-group": aws.String("clu1")},
+				},
+			},
+			{
+				Name: aws.String("worker"),
+				LogConfiguration: &ecs.LogConfiguration{
+					LogDriver: aws.String("awslogs"),
+					Options:   map[string]*string{"awslogs-group": aws.String("clu1")},
+				},
+			},
+			{
+				Name: aws.String("sidecar"),
+				LogConfiguration: &ecs.LogConfiguration{
+					LogDriver: aws.String("awslogs"),
+					Options:   map[string]*string{"awslogs-group": aws.String("clu1-sidecar")},
+				},
+			},
+			{
+				Name: aws.String("nolog"),
+			},
+			{
+				Name: aws.String("splunk"),
+				LogConfiguration: &ecs.LogConfiguration{
+					LogDriver: aws.String("splunk"),
+					Options:   map[string]*string{"awslogs-group": aws.String("clu1-splunk")},
+				},
+			},
+			{
+				Name: aws.String("west"),
+				LogConfiguration: &ecs.LogConfiguration{
+					LogDriver: aws.String("awslogs"),
+					Options: map[string]*string{
+						"awslogs-group":  aws.String("clu1-west"),
+						"awslogs-region": aws.String("us-west-2"),
+					},
+				},
+			},
+		},
+		Compatibilities:   aws.StringSlice([]string{"EC2", "FARGATE"}),
+		ExecutionRoleArn:  aws.String("arn:aws:iam::12345678910:role/clu1-ecsTaskExecution"),
+		Family:            aws.String("loggedapp"),
+		NetworkMode:       aws.String("awsvpc"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/loggedapp:1"),
+	},
+	{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("webserver"),
+				RepositoryCredentials: &ecs.RepositoryCredentials{
+					CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/creds-AbCdEf"),
+				},
+			},
+			{
+				Name: aws.String("worker"),
+				RepositoryCredentials: &ecs.RepositoryCredentials{
+					CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/creds-AbCdEf"),
+				},
+			},
+		},
+		Family:            aws.String("credsapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/credsapp:1"),
+	},
+	{
+		Family:            aws.String("myorg-cluster1-prefixedapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/myorg-cluster1-prefixedapp:1"),
+	},
+	{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{Name: aws.String("sidecar"), Essential: aws.Bool(false)},
+			{Name: aws.String("job")},
+		},
+		Family:            aws.String("batchapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/batchapp:1"),
+	},
+	{
+		ExecutionRoleArn:  aws.String("arn:aws:iam::12345678910:role/mr-rogers-ecsTaskExecution"),
+		Family:            aws.String("roleapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/roleapp:1"),
+		TaskRoleArn:       aws.String("arn:aws:iam::12345678910:role/org/super-why/super-why-app"),
+	},
+	{
+		Family:            aws.String("releasedapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/releasedapp:1"),
+	},
+	{
+		Family:            aws.String("releasedapp"),
+		Revision:          aws.Int64(2),
+		Status:            aws.String("INACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/releasedapp:2"),
+	},
+	{
+		Family:            aws.String("releasedapp"),
+		Revision:          aws.Int64(3),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/releasedapp:3"),
+	},
+	{
+		Family:            aws.String("releasedapp"),
+		Revision:          aws.Int64(4),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/releasedapp:4"),
+	},
+	{
+		Family:            aws.String("releasedapp"),
+		Revision:          aws.Int64(5),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/releasedapp:5"),
+	},
+	{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("webserver"),
+				RepositoryCredentials: &ecs.RepositoryCredentials{
+					CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/archived-AbCdEf"),
+				},
+			},
+		},
+		Family:            aws.String("archivedapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("INACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/archivedapp:1"),
+	},
+	{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("webserver"),
+				Secrets: []*ecs.Secret{
+					{
+						Name:      aws.String("TOKEN"),
+						ValueFrom: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/deployed-AbCdEf"),
+					},
+				},
+			},
+		},
+		Family:            aws.String("deployedapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("INACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/deployedapp:1"),
+	},
+	{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("webserver"),
+				RepositoryCredentials: &ecs.RepositoryCredentials{
+					CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/myorg/cluster0/shared-AbCdEf"),
+				},
+			},
+		},
+		ExecutionRoleArn:  aws.String("arn:aws:iam::12345678910:role/cluster0-ecsTaskExecution"),
+		Family:            aws.String("sharedapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/sharedapp:1"),
+	},
+	{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("webserver"),
+				RepositoryCredentials: &ecs.RepositoryCredentials{
+					CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/kmskeys/rekey-AbCdEf"),
+				},
+			},
+			{
+				Name: aws.String("worker"),
+				RepositoryCredentials: &ecs.RepositoryCredentials{
+					CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/kmskeys/rekey-AbCdEf"),
+				},
+			},
+			{
+				Name: aws.String("sidecar"),
+				RepositoryCredentials: &ecs.RepositoryCredentials{
+					CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/kmskeys/locked-AbCdEf"),
+				},
+			},
+		},
+		ExecutionRoleArn:  aws.String("arn:aws:iam::12345678910:role/kmskeys-ecsTaskExecution"),
+		Family:            aws.String("rekeyapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/rekeyapp:1"),
+	},
+	{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("webserver"),
+				RepositoryCredentials: &ecs.RepositoryCredentials{
+					CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/retried-AbCdEf"),
+				},
+			},
+		},
+		Family:            aws.String("retriedapp"),
+		Revision:          aws.Int64(1),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/retriedapp:1"),
+	},
+	{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("webserver"),
+				RepositoryCredentials: &ecs.RepositoryCredentials{
+					CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/retried-AbCdEf"),
+				},
+			},
+		},
+		Family:            aws.String("retriedapp"),
+		Revision:          aws.Int64(2),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/retriedapp:2"),
+	},
+	{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("webserver"),
+				RepositoryCredentials: &ecs.RepositoryCredentials{
+					CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/retried-AbCdEf"),
+				},
+			},
+		},
+		Family:            aws.String("retriedapp"),
+		Revision:          aws.Int64(3),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/retriedapp:3"),
+	},
+	{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name: aws.String("webserver"),
+				RepositoryCredentials: &ecs.RepositoryCredentials{
+					CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/retried-AbCdEf"),
+				},
+			},
+		},
+		Family:            aws.String("retriedapp"),
+		Revision:          aws.Int64(4),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/retriedapp:4"),
+	},
+}
+
+func (m *mockECSClient) DescribeTaskDefinitionWithContext(ctx aws.Context, input *ecs.DescribeTaskDefinitionInput, opts ...request.Option) (*ecs.DescribeTaskDefinitionOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	m.describedTaskDefinitions = append(m.describedTaskDefinitions, aws.StringValue(input.TaskDefinition))
+
+	// like the ECS API, the family alone describes the latest ACTIVE revision
+	var latest *ecs.TaskDefinition
+	for _, td := range testTaskDefinitionRevisions {
+		id := fmt.Sprintf("%s:%d", aws.StringValue(td.Family), aws.Int64Value(td.Revision))
+		if aws.StringValue(input.TaskDefinition) == id || aws.StringValue(input.TaskDefinition) == aws.StringValue(td.TaskDefinitionArn) {
+			return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: td, Tags: m.taskDefinitionTags(input, td)}, nil
+		}
+
+		if aws.StringValue(input.TaskDefinition) == aws.StringValue(td.Family) && aws.StringValue(td.Status) == "ACTIVE" {
+			if latest == nil || aws.Int64Value(td.Revision) > aws.Int64Value(latest.Revision) {
+				latest = td
+			}
+		}
+	}
+
+	if latest != nil {
+		return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: latest, Tags: m.taskDefinitionTags(input, latest)}, nil
+	}
+
+	return nil, awserr.New(ecs.ErrCodeClientException, "Unable to describe task definition.", nil)
+}
+
+// taskDefinitionTags returns the tags set on the task definition through the mock when they're included
+func (m *mockECSClient) taskDefinitionTags(input *ecs.DescribeTaskDefinitionInput, td *ecs.TaskDefinition) []*ecs.Tag {
+	for _, i := range input.Include {
+		if aws.StringValue(i) == ecs.TaskDefinitionFieldTags {
+			return m.tags[aws.StringValue(td.TaskDefinitionArn)]
+		}
+	}
+
+	return nil
+}
+
+func (m *mockECSClient) TagResourceWithContext(ctx aws.Context, input *ecs.TagResourceInput, opts ...request.Option) (*ecs.TagResourceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if m.tags == nil {
+		m.tags = map[string][]*ecs.Tag{}
+	}
+
+	resource := aws.StringValue(input.ResourceArn)
+	for _, tag := range input.Tags {
+		replaced := false
+		for _, t := range m.tags[resource] {
+			if aws.StringValue(t.Key) == aws.StringValue(tag.Key) {
+				t.Value = tag.Value
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			m.tags[resource] = append(m.tags[resource], tag)
+		}
+	}
+
+	return &ecs.TagResourceOutput{}, nil
+}
+
+func (m *mockECSClient) ListTaskDefinitionsWithContext(ctx aws.Context, input *ecs.ListTaskDefinitionsInput, opts ...request.Option) (*ecs.ListTaskDefinitionsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	// like the ECS API, only ACTIVE task definitions are listed by default
+	status := "ACTIVE"
+	if input.Status != nil {
+		status = aws.StringValue(input.Status)
+	}
+
+	output := &ecs.ListTaskDefinitionsOutput{}
+	for _, td := range testTaskDefinitionRevisions {
+		if aws.StringValue(td.Family) == aws.StringValue(input.FamilyPrefix) && aws.StringValue(td.Status) == status {
+			output.TaskDefinitionArns = append(output.TaskDefinitionArns, td.TaskDefinitionArn)
+		}
+	}
+
+	return output, nil
+}
+
+func (m *mockECSClient) DeregisterTaskDefinitionWithContext(ctx aws.Context, input *ecs.DeregisterTaskDefinitionInput, opts ...request.Option) (*ecs.DeregisterTaskDefinitionOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	for _, td := range testTaskDefinitionRevisions {
+		if aws.StringValue(input.TaskDefinition) == aws.StringValue(td.TaskDefinitionArn) {
+			return &ecs.DeregisterTaskDefinitionOutput{TaskDefinition: td}, nil
+		}
+	}
+
+	return nil, awserr.New(ecs.ErrCodeClientException, "The specified task definition does not exist.", nil)
+}
+
 func (m *mockIAMClient) TagRoleWithContext(ctx context.Context, input *iam.TagRoleInput, opts ...request.Option) (*iam.TagRoleOutput, error) {
 	if m.err != nil {
 		return nil, m.err
@@ -325,6 +739,120 @@ func TestOrchestrator_processTaskDefTaskDefinitionCreate(t *testing.T) {
 				TaskRoleArn:             aws.String("arn:aws:iam::12345678910:role/clu1-ecsTaskExecution"),
 			},
 		},
+		{
+			name: "inference accelerator input",
+			fields: fields{
+				org: "myorg",
+			},
+			args: args{
+				ctx: context.TODO(),
+				input: &TaskDefCreateOrchestrationInput{
+					Cluster: &ecs.CreateClusterInput{
+						ClusterName: aws.String("clu1"),
+					},
+					TaskDefinition: &ecs.RegisterTaskDefinitionInput{
+						Cpu:    aws.String("1024"),
+						Memory: aws.String("2048"),
+						ContainerDefinitions: []*ecs.ContainerDefinition{
+							{
+								Name:  aws.String("inference"),
+								Image: aws.String("myorg/model:v1"),
+								ResourceRequirements: []*ecs.ResourceRequirement{
+									{
+										Type:  aws.String("InferenceAccelerator"),
+										Value: aws.String("device1"),
+									},
+								},
+							},
+						},
+						Family: aws.String("datfam"),
+						InferenceAccelerators: []*ecs.InferenceAccelerator{
+							{
+								DeviceName: aws.String("device1"),
+								DeviceType: aws.String("eia2.medium"),
+							},
+						},
+					},
+				},
+			},
+			want: &ecs.TaskDefinition{
+				Compatibilities: aws.StringSlice([]string{"FARGATE"}),
+				ContainerDefinitions: []*ecs.ContainerDefinition{
+					{
+						Image: aws.String("myorg/model:v1"),
+						LogConfiguration: &ecs.LogConfiguration{
+							LogDriver: aws.String("awslogs"),
+							Options: map[string]*string{
+								"awslogs-group":         aws.String("clu1"),
+								"awslogs-stream-prefix": aws.String("datfam"),
+								"awslogs-region":        aws.String("us-east-1"),
+								"awslogs-create-group":  aws.String("true"),
+							},
+						},
+						Name: aws.String("inference"),
+						ResourceRequirements: []*ecs.ResourceRequirement{
+							{
+								Type:  aws.String("InferenceAccelerator"),
+								Value: aws.String("device1"),
+							},
+						},
+					},
+				},
+				Cpu:              aws.String("1024"),
+				Family:           aws.String("datfam"),
+				ExecutionRoleArn: aws.String("arn:aws:iam::12345678910:role/clu1-ecsTaskExecution"),
+				InferenceAccelerators: []*ecs.InferenceAccelerator{
+					{
+						DeviceName: aws.String("device1"),
+						DeviceType: aws.String("eia2.medium"),
+					},
+				},
+				Memory:                  aws.String("2048"),
+				NetworkMode:             aws.String("awsvpc"),
+				RequiresAttributes:      []*ecs.Attribute{},
+				RequiresCompatibilities: aws.StringSlice([]string{"FARGATE"}),
+				Revision:                aws.Int64(1),
+				Status:                  aws.String("ACTIVE"),
+				TaskDefinitionArn:       aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/datfam:1"),
+				TaskRoleArn:             aws.String("arn:aws:iam::12345678910:role/clu1-ecsTaskExecution"),
+			},
+		},
+		{
+			name: "dangling inference accelerator reference",
+			fields: fields{
+				org: "myorg",
+			},
+			args: args{
+				ctx: context.TODO(),
+				input: &TaskDefCreateOrchestrationInput{
+					Cluster: &ecs.CreateClusterInput{
+						ClusterName: aws.String("clu1"),
+					},
+					TaskDefinition: &ecs.RegisterTaskDefinitionInput{
+						ContainerDefinitions: []*ecs.ContainerDefinition{
+							{
+								Name:  aws.String("inference"),
+								Image: aws.String("myorg/model:v1"),
+								ResourceRequirements: []*ecs.ResourceRequirement{
+									{
+										Type:  aws.String("InferenceAccelerator"),
+										Value: aws.String("device2"),
+									},
+								},
+							},
+						},
+						Family: aws.String("datfam"),
+						InferenceAccelerators: []*ecs.InferenceAccelerator{
+							{
+								DeviceName: aws.String("device1"),
+								DeviceType: aws.String("eia2.medium"),
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -639,3 +1167,1410 @@ func TestOrchestrator_defaultLogConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestOrchestrator_defaultLogConfigurationDeliveryOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		maxBufferSize string
+		want          map[string]string
+		wantErr       bool
+	}{
+		{
+			name: "default",
+			want: map[string]string{},
+		},
+		{
+			name: "blocking",
+			mode: "blocking",
+			want: map[string]string{
+				"awslogs-mode": "blocking",
+			},
+		},
+		{
+			name: "non-blocking",
+			mode: "non-blocking",
+			want: map[string]string{
+				"awslogs-mode": "non-blocking",
+			},
+		},
+		{
+			name:          "non-blocking with max buffer size",
+			mode:          "non-blocking",
+			maxBufferSize: "25m",
+			want: map[string]string{
+				"awslogs-mode":    "non-blocking",
+				"max-buffer-size": "25m",
+			},
+		},
+		{
+			name:          "blocking with max buffer size",
+			mode:          "blocking",
+			maxBufferSize: "25m",
+			wantErr:       true,
+		},
+		{
+			name:          "max buffer size without mode",
+			maxBufferSize: "25m",
+			wantErr:       true,
+		},
+		{
+			name:    "invalid mode",
+			mode:    "sometimes",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.AwslogsMode = tt.mode
+			o.AwslogsMaxBufferSize = tt.maxBufferSize
+
+			got, err := o.defaultLogConfiguration(context.TODO(), "mygroup", "myprefix", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Orchestrator.defaultLogConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			want := map[string]string{
+				"awslogs-group":         "mygroup",
+				"awslogs-stream-prefix": "myprefix",
+				"awslogs-region":        "us-east-1",
+				"awslogs-create-group":  "true",
+			}
+			for k, v := range tt.want {
+				want[k] = v
+			}
+
+			if options := aws.StringValueMap(got.Options); !reflect.DeepEqual(options, want) {
+				t.Errorf("Orchestrator.defaultLogConfiguration() options = %v, want %v", options, want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processLogConfiguration(t *testing.T) {
+	t.Log("testing processLogConfiguration")
+
+	defaultLogConfiguration := &ecs.LogConfiguration{
+		LogDriver: aws.String("awslogs"),
+		Options: map[string]*string{
+			"awslogs-group":         aws.String("clu1"),
+			"awslogs-stream-prefix": aws.String("datfam"),
+			"awslogs-region":        aws.String("us-east-1"),
+			"awslogs-create-group":  aws.String("true"),
+		},
+	}
+
+	splunkLogConfiguration := &ecs.LogConfiguration{
+		LogDriver: aws.String("splunk"),
+		Options: map[string]*string{
+			"splunk-url": aws.String("https://splunk.example.com"),
+		},
+	}
+
+	customAwslogsConfiguration := &ecs.LogConfiguration{
+		LogDriver: aws.String("awslogs"),
+		Options: map[string]*string{
+			"awslogs-group":         aws.String("custom"),
+			"awslogs-stream-prefix": aws.String("sidecar"),
+			"awslogs-region":        aws.String("us-east-1"),
+		},
+	}
+
+	type args struct {
+		containerDefinitions []*ecs.ContainerDefinition
+		skip                 []string
+	}
+	tests := []struct {
+		name    string
+		cwlerr  error
+		args    args
+		want    []*ecs.ContainerDefinition
+		wantErr bool
+	}{
+		{
+			name: "no containers skipped",
+			args: args{
+				containerDefinitions: []*ecs.ContainerDefinition{
+					{Name: aws.String("app")},
+					{Name: aws.String("sidecar"), LogConfiguration: splunkLogConfiguration},
+				},
+			},
+			want: []*ecs.ContainerDefinition{
+				{Name: aws.String("app"), LogConfiguration: defaultLogConfiguration},
+				{Name: aws.String("sidecar"), LogConfiguration: defaultLogConfiguration},
+			},
+		},
+		{
+			name: "mix of opted in and opted out containers",
+			args: args{
+				containerDefinitions: []*ecs.ContainerDefinition{
+					{Name: aws.String("app")},
+					{Name: aws.String("sidecar"), LogConfiguration: splunkLogConfiguration},
+					{Name: aws.String("nolog")},
+				},
+				skip: []string{"sidecar", "nolog"},
+			},
+			want: []*ecs.ContainerDefinition{
+				{Name: aws.String("app"), LogConfiguration: defaultLogConfiguration},
+				{Name: aws.String("sidecar"), LogConfiguration: splunkLogConfiguration},
+				{Name: aws.String("nolog")},
+			},
+		},
+		{
+			name:   "all containers opted out, log group is not created",
+			cwlerr: errors.New("boom"),
+			args: args{
+				containerDefinitions: []*ecs.ContainerDefinition{
+					{Name: aws.String("sidecar"), LogConfiguration: splunkLogConfiguration},
+					{Name: aws.String("nolog")},
+				},
+				skip: []string{"sidecar", "nolog"},
+			},
+			want: []*ecs.ContainerDefinition{
+				{Name: aws.String("sidecar"), LogConfiguration: splunkLogConfiguration},
+				{Name: aws.String("nolog")},
+			},
+		},
+		{
+			name:   "opted out container using awslogs still creates the log group",
+			cwlerr: errors.New("boom"),
+			args: args{
+				containerDefinitions: []*ecs.ContainerDefinition{
+					{Name: aws.String("sidecar"), LogConfiguration: customAwslogsConfiguration},
+				},
+				skip: []string{"sidecar"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "opted out container using awslogs keeps its configuration",
+			args: args{
+				containerDefinitions: []*ecs.ContainerDefinition{
+					{Name: aws.String("app")},
+					{Name: aws.String("sidecar"), LogConfiguration: customAwslogsConfiguration},
+				},
+				skip: []string{"sidecar"},
+			},
+			want: []*ecs.ContainerDefinition{
+				{Name: aws.String("app"), LogConfiguration: defaultLogConfiguration},
+				{Name: aws.String("sidecar"), LogConfiguration: customAwslogsConfiguration},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", tt.cwlerr, nil, nil, nil, nil, nil)
+			err := o.processLogConfiguration(context.TODO(), "clu1", "datfam", tt.args.containerDefinitions, tt.args.skip, "", nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.processLogConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(tt.args.containerDefinitions, tt.want) {
+				t.Errorf("Orchestrator.processLogConfiguration() got = %v, want %v", tt.args.containerDefinitions, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processLogConfigurationRegion(t *testing.T) {
+	tests := []struct {
+		name          string
+		sessionRegion string
+		region        string
+		want          string
+		wantErr       bool
+	}{
+		{
+			name: "unknown session region",
+			want: "us-east-1",
+		},
+		{
+			name:          "session region",
+			sessionRegion: "us-west-2",
+			want:          "us-west-2",
+		},
+		{
+			name:          "override takes precedence",
+			sessionRegion: "us-west-2",
+			region:        "eu-west-1",
+			want:          "eu-west-1",
+		},
+		{
+			name:          "invalid override",
+			sessionRegion: "us-west-2",
+			region:        "not a region",
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.CloudWatchLogs.Region = tt.sessionRegion
+
+			containerDefinitions := []*ecs.ContainerDefinition{{Name: aws.String("app")}}
+			err := o.processLogConfiguration(context.TODO(), "clu1", "datfam", containerDefinitions, nil, tt.region, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Orchestrator.processLogConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if containerDefinitions[0].LogConfiguration != nil {
+					t.Errorf("expected no log configuration for an invalid region, got %+v", containerDefinitions[0].LogConfiguration)
+				}
+				return
+			}
+
+			if got := aws.StringValue(containerDefinitions[0].LogConfiguration.Options["awslogs-region"]); got != tt.want {
+				t.Errorf("expected awslogs-region %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processLogConfigurationGroupName(t *testing.T) {
+	t.Log("testing processLogConfiguration log group name pattern")
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+	}{
+		{
+			name: "default pattern",
+			want: "clu1",
+		},
+		{
+			name:    "org and cluster",
+			pattern: "{org}/{cluster}",
+			want:    "myorg/clu1",
+		},
+		{
+			name:    "org, cluster and family",
+			pattern: "{org}/{cluster}/{family}",
+			want:    "myorg/clu1/datfam",
+		},
+		{
+			name:    "static prefix",
+			pattern: "/ecs/{cluster}-{family}",
+			want:    "/ecs/clu1-datfam",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.AwslogsGroupNamePattern = tt.pattern
+
+			containerDefinitions := []*ecs.ContainerDefinition{{Name: aws.String("app")}}
+			if err := o.processLogConfiguration(context.TODO(), "clu1", "datfam", containerDefinitions, nil, "", nil); err != nil {
+				t.Fatalf("Orchestrator.processLogConfiguration() unexpected error = %v", err)
+			}
+
+			if got := o.CloudWatchLogs.Service.(*mockCWLClient).logGroups; !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("expected created log groups [%s], got %v", tt.want, got)
+			}
+
+			if got := aws.StringValue(containerDefinitions[0].LogConfiguration.Options["awslogs-group"]); got != tt.want {
+				t.Errorf("expected awslogs-group %s, got %s", tt.want, got)
+			}
+
+			// the logs handler reads from the group computed for the service name, which is the stream prefix
+			if got := LogGroupName(tt.pattern, "myorg", "clu1", "datfam"); got != tt.want {
+				t.Errorf("expected LogGroupName() %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processTaskDefinitionRevisionUpdate(t *testing.T) {
+	t.Log("testing processTaskDefinitionRevisionUpdate")
+
+	activeTaskDefinition := testTaskDefinitionRevisions[2]
+
+	tests := []struct {
+		name     string
+		revision string
+		service  *ecs.UpdateServiceInput
+		ecserr   error
+		want     *ecs.TaskDefinition
+		wantErr  bool
+	}{
+		{
+			name:     "empty revision",
+			revision: "",
+			wantErr:  true,
+		},
+		{
+			name:     "roll back to existing revision by family:revision",
+			revision: "webapp:2",
+			want:     testTaskDefinitionRevisions[1],
+		},
+		{
+			name:     "roll back to existing revision by arn",
+			revision: "arn:aws:ecs:us-east-1:0123456789:task-definition/webapp:2",
+			service:  &ecs.UpdateServiceInput{DesiredCount: aws.Int64(2)},
+			want:     testTaskDefinitionRevisions[1],
+		},
+		{
+			name:     "inactive revision",
+			revision: "webapp:1",
+			wantErr:  true,
+		},
+		{
+			name:     "revision from another family",
+			revision: "otherapp:1",
+			wantErr:  true,
+		},
+		{
+			name:     "missing revision",
+			revision: "webapp:99",
+			wantErr:  true,
+		},
+		{
+			name:     "ecs error",
+			revision: "webapp:2",
+			ecserr:   awserr.New(ecs.ErrCodeServerException, "boom", nil),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, tt.ecserr, nil, nil, nil, nil)
+			input := &ServiceOrchestrationUpdateInput{
+				ClusterName:            "clu1",
+				Service:                tt.service,
+				TaskDefinitionRevision: tt.revision,
+			}
+			active := &ServiceOrchestrationUpdateOutput{TaskDefinition: activeTaskDefinition}
+
+			err := o.processTaskDefinitionRevisionUpdate(context.TODO(), input, active)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.processTaskDefinitionRevisionUpdate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				if active.TaskDefinition != activeTaskDefinition {
+					t.Errorf("expected active task definition to be unchanged, got %+v", active.TaskDefinition)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(active.TaskDefinition, tt.want) {
+				t.Errorf("Orchestrator.processTaskDefinitionRevisionUpdate() active task definition = %+v, want %+v", active.TaskDefinition, tt.want)
+			}
+
+			if input.Service == nil || aws.StringValue(input.Service.TaskDefinition) != aws.StringValue(tt.want.TaskDefinitionArn) {
+				t.Errorf("expected service update task definition %s, got %+v", aws.StringValue(tt.want.TaskDefinitionArn), input.Service)
+			}
+
+			if tt.service != nil && aws.Int64Value(input.Service.DesiredCount) != aws.Int64Value(tt.service.DesiredCount) {
+				t.Errorf("expected service update input to be preserved, got %+v", input.Service)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_TaskDefCompatibility(t *testing.T) {
+	tests := []struct {
+		name     string
+		cluster  string
+		family   string
+		ecserr   error
+		want     *TaskDefCompatibilityOutput
+		wantCode string
+	}{
+		{
+			name:    "fargate task definition",
+			cluster: "clu1",
+			family:  "fargateapp:1",
+			want: &TaskDefCompatibilityOutput{
+				Family:                  aws.String("fargateapp"),
+				Revision:                aws.Int64(1),
+				Compatibilities:         aws.StringSlice([]string{"EC2", "FARGATE"}),
+				RequiresCompatibilities: aws.StringSlice([]string{"FARGATE"}),
+				RequiresAttributes:      []*ecs.Attribute{{Name: aws.String("ecs.capability.task-eni")}},
+				Cpu:                     aws.String("256"),
+				Memory:                  aws.String("512"),
+				NetworkMode:             aws.String("awsvpc"),
+				FargateCompatible:       true,
+			},
+		},
+		{
+			name:    "ec2 only task definition",
+			cluster: "clu1",
+			family:  "ec2app:1",
+			want: &TaskDefCompatibilityOutput{
+				Family:                  aws.String("ec2app"),
+				Revision:                aws.Int64(1),
+				Compatibilities:         aws.StringSlice([]string{"EC2"}),
+				RequiresCompatibilities: aws.StringSlice([]string{"EC2"}),
+				RequiresAttributes:      []*ecs.Attribute{{Name: aws.String("com.amazonaws.ecs.capability.docker-remote-api.1.18")}},
+				Memory:                  aws.String("1024"),
+				NetworkMode:             aws.String("bridge"),
+				FargateCompatible:       false,
+			},
+		},
+		{
+			name:     "missing family",
+			cluster:  "clu1",
+			family:   "missing:1",
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "empty family",
+			cluster:  "clu1",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "ecs error",
+			cluster:  "clu1",
+			family:   "fargateapp:1",
+			ecserr:   awserr.New(ecs.ErrCodeServerException, "boom", nil),
+			wantCode: apierror.ErrInternalError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, tt.ecserr, nil, nil, nil, nil)
+
+			got, err := o.TaskDefCompatibility(context.TODO(), tt.cluster, tt.family)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.TaskDefCompatibility() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fargateCompatible(t *testing.T) {
+	tests := []struct {
+		name string
+		td   *ecs.TaskDefinition
+		want bool
+	}{
+		{
+			name: "computed fargate compatibility",
+			td:   &ecs.TaskDefinition{Compatibilities: aws.StringSlice([]string{"EC2", "FARGATE"})},
+			want: true,
+		},
+		{
+			name: "computed ec2 compatibility",
+			td:   &ecs.TaskDefinition{Compatibilities: aws.StringSlice([]string{"EC2"})},
+			want: false,
+		},
+		{
+			name: "declared fargate with awsvpc",
+			td: &ecs.TaskDefinition{
+				NetworkMode:             aws.String("awsvpc"),
+				RequiresCompatibilities: aws.StringSlice([]string{"FARGATE"}),
+			},
+			want: true,
+		},
+		{
+			name: "declared fargate with bridge networking",
+			td: &ecs.TaskDefinition{
+				NetworkMode:             aws.String("bridge"),
+				RequiresCompatibilities: aws.StringSlice([]string{"FARGATE"}),
+			},
+			want: false,
+		},
+		{
+			name: "nothing declared",
+			td:   &ecs.TaskDefinition{},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fargateCompatible(tt.td); got != tt.want {
+				t.Errorf("fargateCompatible() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_taskDefFamily(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   bool
+		cluster  string
+		family   string
+		want     string
+		wantTrim string
+	}{
+		{
+			name:     "prefixing disabled",
+			cluster:  "clu1",
+			family:   "webapp",
+			want:     "webapp",
+			wantTrim: "webapp",
+		},
+		{
+			name:     "prefixing enabled",
+			prefix:   true,
+			cluster:  "clu1",
+			family:   "webapp",
+			want:     "myorg-clu1-webapp",
+			wantTrim: "webapp",
+		},
+		{
+			name:     "already prefixed",
+			prefix:   true,
+			cluster:  "clu1",
+			family:   "myorg-clu1-webapp",
+			want:     "myorg-clu1-webapp",
+			wantTrim: "webapp",
+		},
+		{
+			name:     "prefixed in another cluster",
+			prefix:   true,
+			cluster:  "clu1",
+			family:   "myorg-clu2-webapp",
+			want:     "myorg-clu1-myorg-clu2-webapp",
+			wantTrim: "myorg-clu2-webapp",
+		},
+		{
+			name:    "empty family",
+			prefix:  true,
+			cluster: "clu1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Orchestrator{Org: "myorg", PrefixTaskDefinitionFamilies: tt.prefix}
+
+			if got := o.taskDefFamily(tt.cluster, tt.family); got != tt.want {
+				t.Errorf("Orchestrator.taskDefFamily() = %s, want %s", got, tt.want)
+			}
+
+			if got := o.trimTaskDefFamily(tt.cluster, tt.family); got != tt.wantTrim {
+				t.Errorf("Orchestrator.trimTaskDefFamily() = %s, want %s", got, tt.wantTrim)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processTaskDefTaskDefinitionCreatePrefix(t *testing.T) {
+	for _, prefix := range []bool{false, true} {
+		o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+		o.PrefixTaskDefinitionFamilies = prefix
+
+		got, _, err := o.processTaskDefTaskDefinitionCreate(context.TODO(), &TaskDefCreateOrchestrationInput{
+			Cluster: &ecs.CreateClusterInput{ClusterName: aws.String("clu1")},
+			TaskDefinition: &ecs.RegisterTaskDefinitionInput{
+				ContainerDefinitions: []*ecs.ContainerDefinition{
+					{Name: aws.String("web"), Image: aws.String("nginx:alpine")},
+				},
+				Cpu:    aws.String("256"),
+				Family: aws.String("webapp"),
+				Memory: aws.String("512"),
+			},
+		})
+		if err != nil {
+			t.Fatalf("expected nil error, got %s", err)
+		}
+
+		wantFamily := "webapp"
+		if prefix {
+			wantFamily = "myorg-clu1-webapp"
+		}
+
+		if family := aws.StringValue(got.Family); family != wantFamily {
+			t.Errorf("expected family %s, got %s", wantFamily, family)
+		}
+
+		// the log streams keep the unprefixed family
+		if sp := aws.StringValue(got.ContainerDefinitions[0].LogConfiguration.Options["awslogs-stream-prefix"]); sp != "webapp" {
+			t.Errorf("expected awslogs stream prefix webapp, got %s", sp)
+		}
+	}
+}
+
+func TestOrchestrator_processTaskDefTaskDefinitionCreateEnvironmentFiles(t *testing.T) {
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+	envFile := "arn:aws:s3:::my-bucket/config/app.env"
+	got, _, err := o.processTaskDefTaskDefinitionCreate(context.TODO(), &TaskDefCreateOrchestrationInput{
+		Cluster: &ecs.CreateClusterInput{ClusterName: aws.String("clu1")},
+		TaskDefinition: &ecs.RegisterTaskDefinitionInput{
+			ContainerDefinitions: []*ecs.ContainerDefinition{
+				{
+					Name:  aws.String("web"),
+					Image: aws.String("nginx:alpine"),
+					EnvironmentFiles: []*ecs.EnvironmentFile{
+						{Type: aws.String("s3"), Value: aws.String(envFile)},
+					},
+				},
+			},
+			Cpu:    aws.String("256"),
+			Family: aws.String("webapp"),
+			Memory: aws.String("512"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	files := got.ContainerDefinitions[0].EnvironmentFiles
+	if len(files) != 1 || aws.StringValue(files[0].Value) != envFile {
+		t.Errorf("expected environment file %s to be passed through, got %+v", envFile, files)
+	}
+
+	policy := o.IAM.Service.(*mockIAMClient).rolePolicy
+	for _, want := range []string{envFile, "arn:aws:s3:::my-bucket"} {
+		if !strings.Contains(policy, fmt.Sprintf("%q", want)) {
+			t.Errorf("expected task execution policy to grant access to %s, got %s", want, policy)
+		}
+	}
+}
+
+func TestOrchestrator_processTaskDefTaskDefinitionCreateNamespaceModes(t *testing.T) {
+	tests := []struct {
+		name            string
+		pidMode         string
+		ipcMode         *string
+		compatibilities []string
+		wantErr         bool
+	}{
+		{
+			name:    "task pid mode",
+			pidMode: "task",
+		},
+		{
+			name:    "fargate incompatible host pid mode",
+			pidMode: "host",
+			wantErr: true,
+		},
+		{
+			name:    "fargate incompatible ipc mode",
+			pidMode: "task",
+			ipcMode: aws.String("host"),
+			wantErr: true,
+		},
+		{
+			name:            "ec2 host pid and ipc modes",
+			pidMode:         "host",
+			ipcMode:         aws.String("host"),
+			compatibilities: []string{"EC2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+			got, _, err := o.processTaskDefTaskDefinitionCreate(context.TODO(), &TaskDefCreateOrchestrationInput{
+				Cluster: &ecs.CreateClusterInput{ClusterName: aws.String("clu1")},
+				TaskDefinition: &ecs.RegisterTaskDefinitionInput{
+					ContainerDefinitions: []*ecs.ContainerDefinition{
+						{Name: aws.String("web"), Image: aws.String("nginx:alpine")},
+						{Name: aws.String("debug"), Image: aws.String("busybox:1.36")},
+					},
+					Cpu:                     aws.String("256"),
+					Family:                  aws.String("webapp"),
+					IpcMode:                 tt.ipcMode,
+					Memory:                  aws.String("512"),
+					PidMode:                 aws.String(tt.pidMode),
+					RequiresCompatibilities: aws.StringSlice(tt.compatibilities),
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processTaskDefTaskDefinitionCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+					t.Errorf("expected bad request apierror, got %v", err)
+				}
+				return
+			}
+
+			if pidMode := aws.StringValue(got.PidMode); pidMode != tt.pidMode {
+				t.Errorf("expected pid mode %s, got %s", tt.pidMode, pidMode)
+			}
+
+			if ipcMode := aws.StringValue(got.IpcMode); ipcMode != aws.StringValue(tt.ipcMode) {
+				t.Errorf("expected ipc mode %s, got %s", aws.StringValue(tt.ipcMode), ipcMode)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processTaskDefinitionCreateLinuxParameters(t *testing.T) {
+	tests := []struct {
+		name            string
+		compatibilities []string
+		wantErr         bool
+	}{
+		{
+			name:            "ec2 tmpfs and shared memory",
+			compatibilities: []string{"EC2"},
+		},
+		{
+			name:    "fargate tmpfs and shared memory",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+			input := &ServiceOrchestrationInput{
+				Cluster: &ecs.CreateClusterInput{ClusterName: aws.String("clu1")},
+				Service: &ecs.CreateServiceInput{ServiceName: aws.String("webapp")},
+				TaskDefinition: &ecs.RegisterTaskDefinitionInput{
+					ContainerDefinitions: []*ecs.ContainerDefinition{
+						{
+							Name:  aws.String("web"),
+							Image: aws.String("nginx:alpine"),
+							LinuxParameters: &ecs.LinuxParameters{
+								SharedMemorySize: aws.Int64(64),
+								Tmpfs: []*ecs.Tmpfs{
+									{ContainerPath: aws.String("/scratch"), Size: aws.Int64(128)},
+								},
+							},
+						},
+					},
+					Family:                  aws.String("webapp"),
+					RequiresCompatibilities: aws.StringSlice(tt.compatibilities),
+				},
+			}
+
+			got, _, err := o.processTaskDefinitionCreate(context.TODO(), input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processTaskDefinitionCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+					t.Errorf("expected bad request apierror, got %v", err)
+				}
+				return
+			}
+
+			lp := got.ContainerDefinitions[0].LinuxParameters
+			if aws.Int64Value(lp.SharedMemorySize) != 64 || len(lp.Tmpfs) != 1 {
+				t.Errorf("expected shared memory size and tmpfs mounts to be registered, got %s", lp)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_processTaskDefTaskDefinitionUpdateSystemControls(t *testing.T) {
+	tests := []struct {
+		name            string
+		compatibilities []string
+		wantErr         bool
+	}{
+		{
+			name:            "ec2 ipc namespace system control",
+			compatibilities: []string{"EC2"},
+		},
+		{
+			name:    "fargate ipc namespace system control",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+			active := &TaskDefUpdateOrchestrationOutput{}
+			err := o.processTaskDefTaskDefinitionUpdate(context.TODO(), &TaskDefUpdateOrchestrationInput{
+				ClusterName: "clu1",
+				TaskDefinition: &ecs.RegisterTaskDefinitionInput{
+					ContainerDefinitions: []*ecs.ContainerDefinition{
+						{
+							Name:  aws.String("queue"),
+							Image: aws.String("busybox:1.36"),
+							SystemControls: []*ecs.SystemControl{
+								{Namespace: aws.String("fs.mqueue.msg_max"), Value: aws.String("100")},
+							},
+						},
+					},
+					Family:                  aws.String("queueapp"),
+					RequiresCompatibilities: aws.StringSlice(tt.compatibilities),
+				},
+			}, active)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processTaskDefTaskDefinitionUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+					t.Errorf("expected bad request apierror, got %v", err)
+				}
+				return
+			}
+
+			if sc := active.TaskDefinition.ContainerDefinitions[0].SystemControls; len(sc) != 1 {
+				t.Errorf("expected the system control to be registered, got %s", sc)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ListTaskDefsPrefix(t *testing.T) {
+	resources := []string{
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/myorg-cluster1-prefixedapp:1",
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/myorg-cluster1-prefixedapp:2",
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/webapp:1",
+	}
+
+	tests := []struct {
+		name   string
+		prefix bool
+		want   []string
+	}{
+		{
+			name: "prefixing disabled",
+			want: []string{"myorg-cluster1-prefixedapp", "webapp"},
+		},
+		{
+			name:   "prefixing enabled",
+			prefix: true,
+			want:   []string{"prefixedapp", "webapp"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.PrefixTaskDefinitionFamilies = tt.prefix
+			o.ResourceGroupsTaggingAPI.Service.(*mockRGTAClient).resources = resources
+
+			got, err := o.ListTaskDefs(context.TODO(), "cluster1")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.ListTaskDefs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_GetTaskDefPrefix(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefix     bool
+		family     string
+		wantFamily string
+		wantErr    bool
+	}{
+		{
+			name:       "prefixing enabled",
+			prefix:     true,
+			family:     "prefixedapp:1",
+			wantFamily: "prefixedapp",
+		},
+		{
+			name:    "prefixing disabled",
+			family:  "prefixedapp:1",
+			wantErr: true,
+		},
+		{
+			name:       "prefixing disabled with the full family",
+			family:     "myorg-cluster1-prefixedapp:1",
+			wantFamily: "myorg-cluster1-prefixedapp",
+		},
+		{
+			name:       "prefixing enabled with a legacy family",
+			prefix:     true,
+			family:     "batchapp",
+			wantFamily: "batchapp",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.PrefixTaskDefinitionFamilies = tt.prefix
+
+			got, err := o.GetTaskDef(context.TODO(), "cluster1", tt.family)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Orchestrator.GetTaskDef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if family := aws.StringValue(got.TaskDefinition.Family); family != tt.wantFamily {
+				t.Errorf("expected family %s, got %s", tt.wantFamily, family)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_getTaskDefinitionLegacyFamily(t *testing.T) {
+	tests := []struct {
+		name       string
+		family     string
+		wantArn    string
+		wantFamily string
+		wantErr    bool
+	}{
+		{
+			name:       "prefixed family",
+			family:     "prefixedapp",
+			wantArn:    "arn:aws:ecs:us-east-1:0123456789:task-definition/myorg-cluster1-prefixedapp:1",
+			wantFamily: "myorg-cluster1-prefixedapp",
+		},
+		{
+			name:       "legacy family",
+			family:     "batchapp",
+			wantArn:    "arn:aws:ecs:us-east-1:0123456789:task-definition/batchapp:1",
+			wantFamily: "batchapp",
+		},
+		{
+			name:       "legacy family revision",
+			family:     "batchapp:1",
+			wantArn:    "arn:aws:ecs:us-east-1:0123456789:task-definition/batchapp:1",
+			wantFamily: "batchapp",
+		},
+		{
+			name:       "missing family",
+			family:     "missingapp",
+			wantErr:    true,
+			wantFamily: "myorg-cluster1-missingapp",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.PrefixTaskDefinitionFamilies = true
+
+			got, _, err := o.getTaskDefinition(context.TODO(), "cluster1", tt.family, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Orchestrator.getTaskDefinition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && aws.StringValue(got.TaskDefinitionArn) != tt.wantArn {
+				t.Errorf("expected task definition %s, got %s", tt.wantArn, aws.StringValue(got.TaskDefinitionArn))
+			}
+
+			// a deleted family still resolves to the managed family name, ie. to list its schedules
+			family, err := o.resolveTaskDefFamily(context.TODO(), "cluster1", tt.family)
+			if err != nil {
+				t.Fatalf("Orchestrator.resolveTaskDefFamily() unexpected error = %v", err)
+			}
+
+			if family != tt.wantFamily {
+				t.Errorf("expected resolved family %s, got %s", tt.wantFamily, family)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_TaskDefCompatibilityLegacyFamily(t *testing.T) {
+	o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+	o.PrefixTaskDefinitionFamilies = true
+
+	got, err := o.TaskDefCompatibility(context.TODO(), "cluster1", "batchapp")
+	if err != nil {
+		t.Fatalf("Orchestrator.TaskDefCompatibility() unexpected error = %v", err)
+	}
+
+	if family := aws.StringValue(got.Family); family != "batchapp" {
+		t.Errorf("expected family batchapp, got %s", family)
+	}
+}
+
+func TestOrchestrator_processTaskDefTaskDefinitionUpdateLegacyFamily(t *testing.T) {
+	tests := []struct {
+		name       string
+		active     *ecs.TaskDefinition
+		wantFamily string
+	}{
+		{
+			name:       "legacy family",
+			active:     &ecs.TaskDefinition{Family: aws.String("queueapp")},
+			wantFamily: "queueapp",
+		},
+		{
+			name:       "prefixed family",
+			active:     &ecs.TaskDefinition{Family: aws.String("myorg-clu1-queueapp")},
+			wantFamily: "myorg-clu1-queueapp",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.PrefixTaskDefinitionFamilies = true
+
+			active := &TaskDefUpdateOrchestrationOutput{TaskDefinition: tt.active}
+			err := o.processTaskDefTaskDefinitionUpdate(context.TODO(), &TaskDefUpdateOrchestrationInput{
+				ClusterName: "clu1",
+				TaskDefinition: &ecs.RegisterTaskDefinitionInput{
+					ContainerDefinitions: []*ecs.ContainerDefinition{
+						{Name: aws.String("queue"), Image: aws.String("busybox:1.36")},
+					},
+					Cpu:    aws.String("256"),
+					Family: aws.String("queueapp"),
+					Memory: aws.String("512"),
+				},
+			}, active)
+			if err != nil {
+				t.Fatalf("processTaskDefTaskDefinitionUpdate() unexpected error = %v", err)
+			}
+
+			// the new revision is registered in the family that was updated, so the history isn't split
+			if family := aws.StringValue(active.TaskDefinition.Family); family != tt.wantFamily {
+				t.Errorf("expected the revision to be registered in %s, got %s", tt.wantFamily, family)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_TaskDefContainers(t *testing.T) {
+	tests := []struct {
+		name     string
+		cluster  string
+		family   string
+		ecserr   error
+		want     []string
+		wantCode string
+	}{
+		{
+			name:    "known family",
+			cluster: "clu1",
+			family:  "loggedapp:1",
+			want:    []string{"web", "worker", "sidecar", "nolog", "splunk", "west"},
+		},
+		{
+			name:    "no containers",
+			cluster: "clu1",
+			family:  "fargateapp:1",
+			want:    []string{},
+		},
+		{
+			name:     "missing family",
+			cluster:  "clu1",
+			family:   "missing:1",
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "empty family",
+			cluster:  "clu1",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "ecs error",
+			cluster:  "clu1",
+			family:   "loggedapp:1",
+			ecserr:   awserr.New(ecs.ErrCodeServerException, "boom", nil),
+			wantCode: apierror.ErrInternalError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, tt.ecserr, nil, nil, nil, nil)
+
+			got, err := o.TaskDefContainers(context.TODO(), tt.cluster, tt.family)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			names := []string{}
+			for _, c := range got {
+				names = append(names, aws.StringValue(c.Name))
+			}
+
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("Orchestrator.TaskDefContainers() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ValidateTaskDef(t *testing.T) {
+	tests := []struct {
+		name         string
+		td           *ecs.RegisterTaskDefinitionInput
+		strict       bool
+		wantErrors   int
+		wantWarnings int
+	}{
+		{
+			name: "valid task definition",
+			td: &ecs.RegisterTaskDefinitionInput{
+				Family: aws.String("svc1"),
+				Cpu:    aws.String("512"),
+				Memory: aws.String("2048"),
+				ContainerDefinitions: []*ecs.ContainerDefinition{
+					{
+						Name:  aws.String("web"),
+						Image: aws.String("nginx:1.23"),
+						DependsOn: []*ecs.ContainerDependency{
+							{ContainerName: aws.String("sidecar"), Condition: aws.String("START")},
+						},
+						Secrets: []*ecs.Secret{
+							{Name: aws.String("API_KEY"), ValueFrom: aws.String("arn:aws:secretsmanager:us-east-1:0123456789:secret:apikey-AbCdEf:key::")},
+						},
+						RepositoryCredentials: &ecs.RepositoryCredentials{
+							CredentialsParameter: aws.String("arn:aws:secretsmanager:us-east-1:0123456789:secret:creds-AbCdEf"),
+						},
+					},
+					{
+						Name:  aws.String("sidecar"),
+						Image: aws.String("0123456789.dkr.ecr.us-east-1.amazonaws.com/sidecar@sha256:" + strings.Repeat("a", 64)),
+					},
+				},
+			},
+		},
+		{
+			name: "valid task definition with warnings",
+			td: &ecs.RegisterTaskDefinitionInput{
+				Cpu:    aws.String("256"),
+				Memory: aws.String("512"),
+				ContainerDefinitions: []*ecs.ContainerDefinition{
+					{
+						Name:             aws.String("web"),
+						Image:            aws.String("nginx"),
+						LogConfiguration: &ecs.LogConfiguration{LogDriver: aws.String("splunk")},
+					},
+				},
+			},
+			wantWarnings: 2,
+		},
+		{
+			name:   "untagged image with strict image references",
+			strict: true,
+			td: &ecs.RegisterTaskDefinitionInput{
+				Cpu:    aws.String("256"),
+				Memory: aws.String("512"),
+				ContainerDefinitions: []*ecs.ContainerDefinition{
+					{Name: aws.String("web"), Image: aws.String("nginx")},
+				},
+			},
+			wantErrors: 1,
+		},
+		{
+			name: "several validation errors",
+			td: &ecs.RegisterTaskDefinitionInput{
+				Cpu:    aws.String("256"),
+				Memory: aws.String("4096"),
+				ContainerDefinitions: []*ecs.ContainerDefinition{
+					{
+						Name:  aws.String("web"),
+						Image: aws.String("nginx:1.23"),
+						DependsOn: []*ecs.ContainerDependency{
+							{ContainerName: aws.String("db"), Condition: aws.String("START")},
+						},
+						Secrets: []*ecs.Secret{
+							{Name: aws.String("API_KEY"), ValueFrom: aws.String("arn:aws:secretsmanager:us-east-1:0123456789:secret:apikey-AbCdEf:key")},
+						},
+						RepositoryCredentials: &ecs.RepositoryCredentials{
+							CredentialsParameter: aws.String("arn:aws:ssm:us-east-1:0123456789:parameter/creds"),
+						},
+					},
+					{
+						Name:  aws.String("web"),
+						Image: aws.String("Invalid Image"),
+					},
+				},
+				Volumes: []*ecs.Volume{
+					{Name: aws.String("docker"), Host: &ecs.HostVolumeProperties{SourcePath: aws.String("/var/run/docker.sock")}},
+				},
+			},
+			wantErrors: 7,
+		},
+		{
+			name:       "no container definitions",
+			td:         &ecs.RegisterTaskDefinitionInput{Family: aws.String("svc1")},
+			wantErrors: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.StrictImageReferences = tt.strict
+
+			out := o.ValidateTaskDef(tt.td)
+			if len(out.Errors) != tt.wantErrors {
+				t.Errorf("expected %d errors, got %d: %v", tt.wantErrors, len(out.Errors), out.Errors)
+			}
+
+			if len(out.Warnings) != tt.wantWarnings {
+				t.Errorf("expected %d warnings, got %d: %v", tt.wantWarnings, len(out.Warnings), out.Warnings)
+			}
+
+			if out.Valid != (tt.wantErrors == 0) {
+				t.Errorf("expected valid to be %t, got %t", tt.wantErrors == 0, out.Valid)
+			}
+		})
+	}
+}
+
+// ec2TaskDefinition returns a task definition using the EC2 only features documented in the README
+func ec2TaskDefinition() *ecs.RegisterTaskDefinitionInput {
+	return &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name:  aws.String("agent"),
+				Image: aws.String("amazon/amazon-ecs-agent:v1.70.0"),
+				LinuxParameters: &ecs.LinuxParameters{
+					SharedMemorySize: aws.Int64(64),
+					Tmpfs: []*ecs.Tmpfs{
+						{ContainerPath: aws.String("/scratch"), Size: aws.Int64(128)},
+					},
+				},
+				MountPoints: []*ecs.MountPoint{
+					{SourceVolume: aws.String("docker"), ContainerPath: aws.String("/var/run/docker.sock"), ReadOnly: aws.Bool(true)},
+				},
+				SystemControls: []*ecs.SystemControl{
+					{Namespace: aws.String("fs.mqueue.msg_max"), Value: aws.String("100")},
+				},
+			},
+		},
+		Family:                  aws.String("agent"),
+		IpcMode:                 aws.String("host"),
+		PidMode:                 aws.String("host"),
+		RequiresCompatibilities: aws.StringSlice([]string{"EC2"}),
+		Volumes: []*ecs.Volume{
+			{Name: aws.String("docker"), Host: &ecs.HostVolumeProperties{SourcePath: aws.String("/var/run/docker.sock")}},
+		},
+	}
+}
+
+func TestOrchestrator_EC2Compatibility(t *testing.T) {
+	wantCompatibilities := aws.StringSlice([]string{"EC2"})
+
+	t.Run("create task def", func(t *testing.T) {
+		o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+		out, err := o.CreateTaskDef(context.TODO(), &TaskDefCreateOrchestrationInput{
+			Cluster:        &ecs.CreateClusterInput{ClusterName: aws.String("cluster1")},
+			TaskDefinition: ec2TaskDefinition(),
+		})
+		if err != nil {
+			t.Fatalf("expected nil error creating an EC2 task def, got %s", err)
+		}
+
+		if !reflect.DeepEqual(out.TaskDefinition.RequiresCompatibilities, wantCompatibilities) {
+			t.Errorf("expected compatibilities %s, got %s", aws.StringValueSlice(wantCompatibilities), aws.StringValueSlice(out.TaskDefinition.RequiresCompatibilities))
+		}
+	})
+
+	t.Run("create service", func(t *testing.T) {
+		o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+		out, err := o.CreateService(context.TODO(), &ServiceOrchestrationInput{
+			Cluster: &ecs.CreateClusterInput{ClusterName: aws.String("cluster1")},
+			Service: &ecs.CreateServiceInput{
+				LaunchType:  aws.String("EC2"),
+				ServiceName: aws.String("agent"),
+			},
+			TaskDefinition: ec2TaskDefinition(),
+		})
+		if err != nil {
+			t.Fatalf("expected nil error creating a service with an EC2 task def, got %s", err)
+		}
+
+		if !reflect.DeepEqual(out.TaskDefinition.RequiresCompatibilities, wantCompatibilities) {
+			t.Errorf("expected compatibilities %s, got %s", aws.StringValueSlice(wantCompatibilities), aws.StringValueSlice(out.TaskDefinition.RequiresCompatibilities))
+		}
+	})
+
+	t.Run("update task def", func(t *testing.T) {
+		o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+		active := &TaskDefUpdateOrchestrationOutput{}
+		if err := o.processTaskDefTaskDefinitionUpdate(context.TODO(), &TaskDefUpdateOrchestrationInput{
+			ClusterName:    "cluster1",
+			TaskDefinition: ec2TaskDefinition(),
+		}, active); err != nil {
+			t.Fatalf("expected nil error updating an EC2 task def, got %s", err)
+		}
+
+		if !reflect.DeepEqual(active.TaskDefinition.RequiresCompatibilities, wantCompatibilities) {
+			t.Errorf("expected compatibilities %s, got %s", aws.StringValueSlice(wantCompatibilities), aws.StringValueSlice(active.TaskDefinition.RequiresCompatibilities))
+		}
+	})
+
+	t.Run("fargate by default", func(t *testing.T) {
+		o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+
+		td := ec2TaskDefinition()
+		td.RequiresCompatibilities = nil
+
+		_, err := o.CreateTaskDef(context.TODO(), &TaskDefCreateOrchestrationInput{
+			Cluster:        &ecs.CreateClusterInput{ClusterName: aws.String("cluster1")},
+			TaskDefinition: td,
+		})
+		if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+			t.Errorf("expected bad request creating a task def with EC2 only features without compatibilities, got %v", err)
+		}
+	})
+}
+
+func TestOrchestrator_deleteTaskDefinitionRevisionsRetriesCredentials(t *testing.T) {
+	credsArn := "arn:aws:secretsmanager:us-east-1:12345678910:secret:spinup/mock/cluster1/retried-AbCdEf"
+
+	revisions := []string{
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/retriedapp:1",
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/retriedapp:2",
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/retriedapp:3",
+		"arn:aws:ecs:us-east-1:0123456789:task-definition/retriedapp:4",
+	}
+
+	for _, fails := range []int{0, 1, 3, 4} {
+		t.Run(fmt.Sprintf("%d failures", fails), func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.DeleteConcurrency = len(revisions)
+
+			smClient := o.SecretsManager.Service.(*mockSMClient)
+			smClient.secrets = []*secretsmanager.SecretListEntry{{ARN: aws.String(credsArn), Name: aws.String("spinup/mock/cluster1/retried")}}
+			smClient.deleteFailures = map[string]int{credsArn: fails}
+
+			failures := o.deleteTaskDefinitionRevisions(context.TODO(), revisions, newCredentialsSet())
+
+			// every failed delete is reported by the revision that attempted it
+			if len(failures) != fails {
+				t.Errorf("expected %d revisions to report failures, got %+v", fails, failures)
+			}
+
+			// the credentials are retried by the remaining revisions until they're deleted, and only deleted once
+			wantDeleted := []string{credsArn}
+			if fails == len(revisions) {
+				wantDeleted = nil
+			}
+			if !reflect.DeepEqual(smClient.deleted, wantDeleted) {
+				t.Errorf("expected deleted secrets %v, got %v", wantDeleted, smClient.deleted)
+			}
+		})
+	}
+}
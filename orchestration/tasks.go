@@ -2,10 +2,12 @@ package orchestration
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/YaleSpinup/apierror"
+	ecsapi "github.com/YaleSpinup/ecs-api/ecs"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/service/ecs"
@@ -77,6 +79,73 @@ func toTaskOutput(tasks []*ecs.Task, failures []*ecs.Failure) (*TaskOutput, erro
 	return output, nil
 }
 
+// StartedByTask is a task started by an invoker and its status
+type StartedByTask struct {
+	TaskArn       string
+	LastStatus    string
+	DesiredStatus string
+}
+
+// startedByTaskStatuses are the desired statuses listed for the tasks started by an invoker.  ECS doesn't return
+// any tasks for a desired status of PENDING, tasks that are still starting have a desired status of RUNNING.
+var startedByTaskStatuses = []string{"RUNNING", "STOPPED"}
+
+// ListStartedByTasks lists the tasks in a cluster started by the given invoker (ie. ad-hoc task runs) across the desired statuses
+// and returns their ARNs and statuses.  Tasks are described in batches, those that can't be described are skipped.
+func (o *Orchestrator) ListStartedByTasks(ctx context.Context, cluster, startedBy string) ([]*StartedByTask, error) {
+	if cluster == "" || startedBy == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "cluster and startedBy are required", nil)
+	}
+
+	// a task can change desired status between listing calls, so only keep the first occurrence
+	seen := map[string]struct{}{}
+	tasks := []string{}
+	for _, s := range startedByTaskStatuses {
+		out, err := o.ECS.ListStartedByTasks(ctx, cluster, startedBy, s)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range out {
+			if _, ok := seen[t]; ok {
+				continue
+			}
+			seen[t] = struct{}{}
+			tasks = append(tasks, t)
+		}
+	}
+
+	output := make([]*StartedByTask, 0, len(tasks))
+	for i := 0; i < len(tasks); i += ecsapi.DescribeTasksBatchSize {
+		end := i + ecsapi.DescribeTasksBatchSize
+		if end > len(tasks) {
+			end = len(tasks)
+		}
+
+		out, err := o.ECS.GetTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(cluster),
+			Tasks:   aws.StringSlice(tasks[i:end]),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range out.Failures {
+			log.Warnf("failed to describe task %s started by %s: %s", aws.StringValue(f.Arn), startedBy, aws.StringValue(f.Reason))
+		}
+
+		for _, t := range out.Tasks {
+			output = append(output, &StartedByTask{
+				TaskArn:       aws.StringValue(t.TaskArn),
+				LastStatus:    aws.StringValue(t.LastStatus),
+				DesiredStatus: aws.StringValue(t.DesiredStatus),
+			})
+		}
+	}
+
+	return output, nil
+}
+
 func (o *Orchestrator) StopTask(ctx context.Context, cluster, task, reason string) error {
 	if cluster == "" || task == "" {
 		return apierror.New(apierror.ErrBadRequest, "cluster and task are required", nil)
@@ -97,3 +166,78 @@ func (o *Orchestrator) StopTask(ctx context.Context, cluster, task, reason strin
 
 	return nil
 }
+
+// StopServiceTasksOutput is the outcome of stopping the running tasks of a service
+type StopServiceTasksOutput struct {
+	Stopped []string
+	// Failed maps the tasks that failed to stop to the reason
+	Failed map[string]string `json:",omitempty"`
+}
+
+// StopServiceTasks stops all of the running tasks for a service and returns the ids of the stopped tasks.  The service
+// isn't scaled down, so ECS will launch replacement tasks to maintain the desired count.  A task failing to stop doesn't
+// keep the remaining tasks from being stopped, the failures are returned in Failed.
+func (o *Orchestrator) StopServiceTasks(ctx context.Context, cluster, service, reason string) (*StopServiceTasksOutput, error) {
+	if cluster == "" || service == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "cluster and service are required", nil)
+	}
+
+	if reason == "" {
+		reason = fmt.Sprintf("all tasks for service %s stopped by api", service)
+	}
+
+	tasks, err := o.ECS.ListServiceTasks(ctx, cluster, service, "RUNNING")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("stopping %d running task(s) for service %s/%s", len(tasks), cluster, service)
+
+	output := &StopServiceTasksOutput{Stopped: make([]string, 0, len(tasks))}
+	for _, t := range tasks {
+		if _, err := o.ECS.StopTask(ctx, &ecs.StopTaskInput{
+			Cluster: aws.String(cluster),
+			Task:    aws.String(t),
+			Reason:  aws.String(reason),
+		}); err != nil {
+			log.Errorf("failed to stop task %s for service %s/%s: %s", t, cluster, service, err)
+
+			if output.Failed == nil {
+				output.Failed = map[string]string{}
+			}
+			output.Failed[t] = err.Error()
+			continue
+		}
+
+		output.Stopped = append(output.Stopped, t)
+	}
+
+	return output, nil
+}
+
+// essentialContainer returns the container of a stopped task that determines its outcome: the first essential container
+// that exited with a non-zero exit code, otherwise the first essential container.  Containers are essential unless their
+// container definition says otherwise.
+func essentialContainer(task *ecs.Task, containerDefinitions []*ecs.ContainerDefinition) *ecs.Container {
+	essential := make(map[string]bool, len(containerDefinitions))
+	for _, cd := range containerDefinitions {
+		essential[aws.StringValue(cd.Name)] = cd.Essential == nil || aws.BoolValue(cd.Essential)
+	}
+
+	var first *ecs.Container
+	for _, c := range task.Containers {
+		if e, ok := essential[aws.StringValue(c.Name)]; ok && !e {
+			continue
+		}
+
+		if c.ExitCode != nil && aws.Int64Value(c.ExitCode) != 0 {
+			return c
+		}
+
+		if first == nil {
+			first = c
+		}
+	}
+
+	return first
+}
@@ -1,13 +1,452 @@
 package orchestration
 
 import (
+	"context"
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ecs"
 )
 
+func (m *mockECSClient) RunTaskWithContext(ctx aws.Context, input *ecs.RunTaskInput, opts ...request.Option) (*ecs.RunTaskOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	taskArn := "arn:aws:ecs:us-east-1:0123456789:task/cluster1/0123456789abcdef"
+	if strings.HasSuffix(aws.StringValue(input.TaskDefinition), "/batchapp:1") {
+		taskArn = testBatchTaskArn
+	}
+
+	count := int64(1)
+	if input.Count != nil {
+		count = aws.Int64Value(input.Count)
+	}
+
+	// like the ECS API, one task is returned for each task run
+	tasks := []*ecs.Task{}
+	for i := int64(0); i < count; i++ {
+		arn := taskArn
+		if i > 0 {
+			arn = fmt.Sprintf("%s-%d", taskArn, i)
+		}
+
+		tasks = append(tasks, &ecs.Task{
+			ClusterArn:        input.Cluster,
+			LastStatus:        aws.String("PROVISIONING"),
+			TaskArn:           aws.String(arn),
+			TaskDefinitionArn: input.TaskDefinition,
+		})
+	}
+
+	return &ecs.RunTaskOutput{Tasks: tasks}, nil
+}
+
+// testBatchTaskArn is the task run from the batchapp task definition, it has already stopped when it's described
+var testBatchTaskArn = "arn:aws:ecs:us-east-1:0123456789:task/cluster1/ba7c40b0000000000"
+
+// testTasks are the tasks returned by the mock when they're described, by ARN or id
+var testTasks = map[string]*ecs.Task{
+	"adhoc1111": {
+		DesiredStatus: aws.String("RUNNING"),
+		LastStatus:    aws.String("PENDING"),
+		TaskArn:       aws.String("arn:aws:ecs:us-east-1:0123456789:task/cluster1/adhoc1111"),
+	},
+	"adhoc2222": {
+		DesiredStatus: aws.String("STOPPED"),
+		LastStatus:    aws.String("STOPPED"),
+		TaskArn:       aws.String("arn:aws:ecs:us-east-1:0123456789:task/cluster1/adhoc2222"),
+	},
+	testBatchTaskArn: {
+		Containers: []*ecs.Container{
+			{Name: aws.String("sidecar"), ExitCode: aws.Int64(137)},
+			{Name: aws.String("job"), ExitCode: aws.Int64(0)},
+		},
+		LastStatus:        aws.String("STOPPED"),
+		StopCode:          aws.String("EssentialContainerExited"),
+		StoppedReason:     aws.String("Essential container in task exited"),
+		TaskArn:           aws.String(testBatchTaskArn),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:0123456789:task-definition/batchapp:1"),
+	},
+}
+
+// testServiceTasks are the running tasks for each service returned by the mock, in pages of 2
+var testServiceTasks = map[string][]string{
+	"runaway": {
+		"arn:aws:ecs:us-east-1:0123456789:task/cluster1/11111111111111111",
+		"arn:aws:ecs:us-east-1:0123456789:task/cluster1/22222222222222222",
+		"arn:aws:ecs:us-east-1:0123456789:task/cluster1/33333333333333333",
+	},
+}
+
+// testStartedByTasks are the tasks for each invoker returned by the mock, by desired status.  The task listed
+// as both RUNNING and STOPPED changed its desired status between calls.
+var testStartedByTasks = map[string]map[string][]string{
+	"adhoc-runner": {
+		"RUNNING": {"arn:aws:ecs:us-east-1:0123456789:task/cluster1/adhoc1111", "arn:aws:ecs:us-east-1:0123456789:task/cluster1/adhoc2222"},
+		"STOPPED": {"arn:aws:ecs:us-east-1:0123456789:task/cluster1/adhoc2222"},
+	},
+}
+
+// listStartedByTasks returns the tasks started by an invoker with the desired status
+func listStartedByTasks(input *ecs.ListTasksInput) *ecs.ListTasksOutput {
+	tasks := testStartedByTasks[aws.StringValue(input.StartedBy)][aws.StringValue(input.DesiredStatus)]
+	return &ecs.ListTasksOutput{TaskArns: aws.StringSlice(tasks)}
+}
+
+// listServiceTasks returns a page of the running tasks for a service
+func listServiceTasks(input *ecs.ListTasksInput) *ecs.ListTasksOutput {
+	output := &ecs.ListTasksOutput{}
+	if aws.StringValue(input.DesiredStatus) != "RUNNING" {
+		return output
+	}
+
+	tasks := testServiceTasks[aws.StringValue(input.ServiceName)]
+
+	start := 0
+	if input.NextToken != nil {
+		start, _ = strconv.Atoi(aws.StringValue(input.NextToken))
+	}
+
+	end := start + 2
+	if end < len(tasks) {
+		output.NextToken = aws.String(strconv.Itoa(end))
+	} else {
+		end = len(tasks)
+	}
+
+	output.TaskArns = aws.StringSlice(tasks[start:end])
+
+	return output
+}
+
+func (m *mockECSClient) StopTaskWithContext(ctx aws.Context, input *ecs.StopTaskInput, opts ...request.Option) (*ecs.StopTaskOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if aws.StringValue(input.Reason) == "" {
+		m.t.Errorf("expected a reason stopping task %s", aws.StringValue(input.Task))
+	}
+
+	if err, ok := m.stopErrs[aws.StringValue(input.Task)]; ok {
+		return nil, err
+	}
+
+	m.stopped = append(m.stopped, aws.StringValue(input.Task))
+
+	return &ecs.StopTaskOutput{
+		Task: &ecs.Task{
+			DesiredStatus: aws.String("STOPPED"),
+			StoppedReason: input.Reason,
+		},
+	}, nil
+}
+
+func TestOrchestrator_StopServiceTasks(t *testing.T) {
+	tests := []struct {
+		name     string
+		cluster  string
+		service  string
+		reason   string
+		ecserr   error
+		stopErrs map[string]error
+		want     *StopServiceTasksOutput
+		wantErr  bool
+	}{
+		{
+			name:    "empty service",
+			cluster: "cluster1",
+			wantErr: true,
+		},
+		{
+			name:    "stop all running tasks",
+			cluster: "cluster1",
+			service: "runaway",
+			reason:  "stop runaway processing",
+			want:    &StopServiceTasksOutput{Stopped: []string{"11111111111111111", "22222222222222222", "33333333333333333"}},
+		},
+		{
+			name:    "default reason",
+			cluster: "cluster1",
+			service: "runaway",
+			want:    &StopServiceTasksOutput{Stopped: []string{"11111111111111111", "22222222222222222", "33333333333333333"}},
+		},
+		{
+			name:    "no running tasks",
+			cluster: "cluster1",
+			service: "idle",
+			want:    &StopServiceTasksOutput{Stopped: []string{}},
+		},
+		{
+			name:     "a task fails to stop",
+			cluster:  "cluster1",
+			service:  "runaway",
+			stopErrs: map[string]error{"22222222222222222": awserr.New(ecs.ErrCodeServerException, "boom", nil)},
+			want: &StopServiceTasksOutput{
+				Stopped: []string{"11111111111111111", "33333333333333333"},
+				Failed:  map[string]string{"22222222222222222": "InternalError: failed to stop task (ServerException: boom)"},
+			},
+		},
+		{
+			name:    "error from aws",
+			cluster: "cluster1",
+			service: "runaway",
+			ecserr:  awserr.New(ecs.ErrCodeServiceNotFoundException, "service not found", nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "", nil, tt.ecserr, nil, nil, nil, nil)
+			o.ECS.Service.(*mockECSClient).stopErrs = tt.stopErrs
+
+			got, err := o.StopServiceTasks(context.TODO(), tt.cluster, tt.service, tt.reason)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.StopServiceTasks() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.StopServiceTasks() = %+v, want %+v", got, tt.want)
+			}
+
+			var want []string
+			if tt.want != nil {
+				want = tt.want.Stopped
+			}
+
+			if stopped := o.ECS.Service.(*mockECSClient).stopped; len(stopped) != len(want) || (len(stopped) > 0 && !reflect.DeepEqual(stopped, want)) {
+				t.Errorf("expected tasks %v to be stopped, got %v", want, stopped)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ListStartedByTasks(t *testing.T) {
+	tests := []struct {
+		name      string
+		cluster   string
+		startedBy string
+		ecserr    error
+		want      []*StartedByTask
+		wantErr   bool
+	}{
+		{
+			name:    "empty startedBy",
+			cluster: "cluster1",
+			wantErr: true,
+		},
+		{
+			name:      "tasks across statuses",
+			cluster:   "cluster1",
+			startedBy: "adhoc-runner",
+			want: []*StartedByTask{
+				{
+					TaskArn:       "arn:aws:ecs:us-east-1:0123456789:task/cluster1/adhoc1111",
+					LastStatus:    "PENDING",
+					DesiredStatus: "RUNNING",
+				},
+				{
+					TaskArn:       "arn:aws:ecs:us-east-1:0123456789:task/cluster1/adhoc2222",
+					LastStatus:    "STOPPED",
+					DesiredStatus: "STOPPED",
+				},
+			},
+		},
+		{
+			name:      "no tasks",
+			cluster:   "cluster1",
+			startedBy: "nobody",
+			want:      []*StartedByTask{},
+		},
+		{
+			name:      "error from aws",
+			cluster:   "cluster1",
+			startedBy: "adhoc-runner",
+			ecserr:    awserr.New(ecs.ErrCodeClusterNotFoundException, "cluster not found", nil),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "", nil, tt.ecserr, nil, nil, nil, nil)
+
+			got, err := o.ListStartedByTasks(context.TODO(), tt.cluster, tt.startedBy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.ListStartedByTasks() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Orchestrator.ListStartedByTasks() = %s, want %s", awsutil.Prettify(got), awsutil.Prettify(tt.want))
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			// the startedBy filter is passed to ECS for every status
+			want := []string{}
+			for range startedByTaskStatuses {
+				want = append(want, tt.startedBy)
+			}
+
+			if startedBy := o.ECS.Service.(*mockECSClient).startedBy; !reflect.DeepEqual(startedBy, want) {
+				t.Errorf("expected tasks to be listed with startedBy %v, got %v", want, startedBy)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_RunTaskAndWait(t *testing.T) {
+	interval := DefaultTaskStatusPollInterval
+	DefaultTaskStatusPollInterval = 10 * time.Millisecond
+	defer func() { DefaultTaskStatusPollInterval = interval }()
+
+	tests := []struct {
+		name          string
+		family        string
+		input         *ecs.RunTaskInput
+		wait          time.Duration
+		ecserr        error
+		wantStopped   bool
+		wantContainer string
+		wantExitCode  *int64
+		wantErr       bool
+	}{
+		{
+			name:          "task stops with exit code 0",
+			family:        "batchapp:1",
+			input:         &ecs.RunTaskInput{},
+			wait:          time.Second,
+			wantStopped:   true,
+			wantContainer: "job",
+			wantExitCode:  aws.Int64(0),
+		},
+		{
+			name:   "task doesn't stop before the wait times out",
+			family: "otherapp:1",
+			input:  &ecs.RunTaskInput{},
+			wait:   50 * time.Millisecond,
+		},
+		{
+			name:    "more than one task",
+			family:  "batchapp:1",
+			input:   &ecs.RunTaskInput{Count: aws.Int64(2)},
+			wait:    time.Second,
+			wantErr: true,
+		},
+		{
+			name:    "ecs error",
+			family:  "batchapp:1",
+			input:   &ecs.RunTaskInput{},
+			wait:    time.Second,
+			ecserr:  awserr.New(ecs.ErrCodeServerException, "boom", nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, tt.ecserr, nil, nil, nil, nil)
+
+			start := time.Now()
+			got, err := o.RunTaskAndWait(context.TODO(), "cluster1", tt.family, tt.input, nil, tt.wait)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Orchestrator.RunTaskAndWait() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if elapsed := time.Since(start); elapsed > tt.wait+time.Second {
+				t.Errorf("expected RunTaskAndWait to give up after %s, took %s", tt.wait, elapsed)
+			}
+
+			if got.Stopped != tt.wantStopped {
+				t.Errorf("expected stopped %t, got %t", tt.wantStopped, got.Stopped)
+			}
+
+			if got.Container != tt.wantContainer {
+				t.Errorf("expected container %q, got %q", tt.wantContainer, got.Container)
+			}
+
+			if !reflect.DeepEqual(got.ExitCode, tt.wantExitCode) {
+				t.Errorf("expected exit code %v, got %v", aws.Int64Value(tt.wantExitCode), aws.Int64Value(got.ExitCode))
+			}
+
+			if got.Task == nil {
+				t.Fatal("expected a task, got nil")
+			}
+
+			if tt.wantStopped && got.StopCode != "EssentialContainerExited" {
+				t.Errorf("expected stop code EssentialContainerExited, got %s", got.StopCode)
+			}
+		})
+	}
+}
+
+func Test_essentialContainer(t *testing.T) {
+	containerDefinitions := []*ecs.ContainerDefinition{
+		{Name: aws.String("web")},
+		{Name: aws.String("worker"), Essential: aws.Bool(true)},
+		{Name: aws.String("sidecar"), Essential: aws.Bool(false)},
+	}
+
+	tests := []struct {
+		name       string
+		containers []*ecs.Container
+		want       string
+	}{
+		{
+			name: "first essential container",
+			containers: []*ecs.Container{
+				{Name: aws.String("sidecar"), ExitCode: aws.Int64(1)},
+				{Name: aws.String("web"), ExitCode: aws.Int64(0)},
+				{Name: aws.String("worker"), ExitCode: aws.Int64(0)},
+			},
+			want: "web",
+		},
+		{
+			name: "failed essential container",
+			containers: []*ecs.Container{
+				{Name: aws.String("web"), ExitCode: aws.Int64(0)},
+				{Name: aws.String("worker"), ExitCode: aws.Int64(2)},
+			},
+			want: "worker",
+		},
+		{
+			name: "no essential containers",
+			containers: []*ecs.Container{
+				{Name: aws.String("sidecar"), ExitCode: aws.Int64(0)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := essentialContainer(&ecs.Task{Containers: tt.containers}, containerDefinitions)
+
+			name := ""
+			if got != nil {
+				name = aws.StringValue(got.Name)
+			}
+
+			if name != tt.want {
+				t.Errorf("essentialContainer() = %s, want %s", name, tt.want)
+			}
+		})
+	}
+}
+
 func Test_toTaskOutput(t *testing.T) {
 	type args struct {
 		tasks    []*ecs.Task
@@ -70,3 +509,201 @@ func Test_toTaskOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestOrchestrator_RunTaskDef(t *testing.T) {
+	tests := []struct {
+		name                     string
+		input                    *ecs.RunTaskInput
+		defaultTags              map[string]string
+		wantEnableECSManagedTags bool
+		wantPropagateTags        string
+		wantTags                 []*ecs.Tag
+		wantTasks                int
+		wantErr                  bool
+	}{
+		{
+			name:                     "defaults",
+			input:                    &ecs.RunTaskInput{},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "TASK_DEFINITION",
+		},
+		{
+			name: "supplied tags",
+			input: &ecs.RunTaskInput{
+				Tags: []*ecs.Tag{
+					{Key: aws.String("Application"), Value: aws.String("nightly-report")},
+					{Key: aws.String("spinup:spaceid"), Value: aws.String("otherspace")},
+				},
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "NONE",
+			wantTags: []*ecs.Tag{
+				{Key: aws.String("spinup:org"), Value: aws.String("myorg")},
+				{Key: aws.String("spinup:spaceid"), Value: aws.String("cluster1")},
+				{Key: aws.String("spinup:type"), Value: aws.String("container")},
+				{Key: aws.String("spinup:flavor"), Value: aws.String("task")},
+				{Key: aws.String("Application"), Value: aws.String("nightly-report")},
+			},
+		},
+		{
+			name: "supplied tags with default tags",
+			input: &ecs.RunTaskInput{
+				Tags: []*ecs.Tag{
+					{Key: aws.String("Application"), Value: aws.String("nightly-report")},
+					{Key: aws.String("CostCenter"), Value: aws.String("5678")},
+				},
+			},
+			defaultTags: map[string]string{
+				"CostCenter": "1234",
+				"ManagedBy":  "spinup",
+				"spinup:org": "otherorg",
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "NONE",
+			wantTags: []*ecs.Tag{
+				{Key: aws.String("spinup:org"), Value: aws.String("myorg")},
+				{Key: aws.String("spinup:spaceid"), Value: aws.String("cluster1")},
+				{Key: aws.String("spinup:type"), Value: aws.String("container")},
+				{Key: aws.String("spinup:flavor"), Value: aws.String("task")},
+				{Key: aws.String("Application"), Value: aws.String("nightly-report")},
+				{Key: aws.String("CostCenter"), Value: aws.String("5678")},
+				{Key: aws.String("ManagedBy"), Value: aws.String("spinup")},
+			},
+		},
+		{
+			name: "supplied tags with task definition propagation",
+			input: &ecs.RunTaskInput{
+				PropagateTags: aws.String("TASK_DEFINITION"),
+				Tags:          []*ecs.Tag{{Key: aws.String("Application"), Value: aws.String("nightly-report")}},
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "TASK_DEFINITION",
+			wantErr:                  true,
+		},
+		{
+			name: "supplied tags in another org",
+			input: &ecs.RunTaskInput{
+				Tags: []*ecs.Tag{{Key: aws.String("spinup:org"), Value: aws.String("otherorg")}},
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "NONE",
+			wantErr:                  true,
+		},
+		{
+			name: "caller overrides",
+			input: &ecs.RunTaskInput{
+				EnableECSManagedTags: aws.Bool(false),
+				PropagateTags:        aws.String("NONE"),
+			},
+			wantEnableECSManagedTags: false,
+			wantPropagateTags:        "NONE",
+		},
+		{
+			name: "service propagation",
+			input: &ecs.RunTaskInput{
+				PropagateTags: aws.String("SERVICE"),
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "SERVICE",
+			wantErr:                  true,
+		},
+		{
+			name: "invalid propagation",
+			input: &ecs.RunTaskInput{
+				PropagateTags: aws.String("EVERYWHERE"),
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "EVERYWHERE",
+			wantErr:                  true,
+		},
+		{
+			name: "attached capacity provider",
+			input: &ecs.RunTaskInput{
+				CapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{
+					{CapacityProvider: aws.String("FARGATE"), Weight: aws.Int64(1)},
+				},
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "TASK_DEFINITION",
+		},
+		{
+			name: "unattached capacity provider",
+			input: &ecs.RunTaskInput{
+				CapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{
+					{CapacityProvider: aws.String("FARGATE"), Weight: aws.Int64(1)},
+					{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: aws.Int64(1)},
+				},
+			},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "TASK_DEFINITION",
+			wantErr:                  true,
+		},
+		{
+			name:                     "multiple tasks",
+			input:                    &ecs.RunTaskInput{Count: aws.Int64(5)},
+			wantEnableECSManagedTags: true,
+			wantPropagateTags:        "TASK_DEFINITION",
+			wantTasks:                5,
+		},
+		{
+			name:    "count exceeds the limit",
+			input:   &ecs.RunTaskInput{Count: aws.Int64(11)},
+			wantErr: true,
+		},
+		{
+			name:    "zero count",
+			input:   &ecs.RunTaskInput{Count: aws.Int64(0)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newMockOrchestrator(t, "myorg", nil, nil, nil, nil, nil, nil)
+			o.DefaultTags = tt.defaultTags
+
+			got, err := o.RunTaskDef(context.TODO(), "cluster1", "otherapp:1", tt.input, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Orchestrator.RunTaskDef() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if aws.BoolValue(tt.input.EnableECSManagedTags) != tt.wantEnableECSManagedTags {
+				t.Errorf("expected run input EnableECSManagedTags %t, got %t", tt.wantEnableECSManagedTags, aws.BoolValue(tt.input.EnableECSManagedTags))
+			}
+
+			if aws.StringValue(tt.input.PropagateTags) != tt.wantPropagateTags {
+				t.Errorf("expected run input PropagateTags %s, got %s", tt.wantPropagateTags, aws.StringValue(tt.input.PropagateTags))
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(tt.input.Tags, tt.wantTags) {
+				t.Errorf("expected run input Tags %s, got %s", awsutil.Prettify(tt.wantTags), awsutil.Prettify(tt.input.Tags))
+			}
+
+			if tt.input.CapacityProviderStrategy != nil && tt.input.LaunchType != nil {
+				t.Errorf("expected no launch type with a capacity provider strategy, got %s", aws.StringValue(tt.input.LaunchType))
+			}
+
+			wantTasks := tt.wantTasks
+			if wantTasks == 0 {
+				wantTasks = 1
+			}
+
+			if len(got.Tasks) != wantTasks {
+				t.Fatalf("expected %d tasks, got %d", wantTasks, len(got.Tasks))
+			}
+
+			arns := map[string]struct{}{}
+			for _, task := range got.Tasks {
+				arns[aws.StringValue(task.TaskArn)] = struct{}{}
+			}
+
+			if len(arns) != wantTasks {
+				t.Errorf("expected %d unique task arns, got %v", wantTasks, arns)
+			}
+		})
+	}
+}
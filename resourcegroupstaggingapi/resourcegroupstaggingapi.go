@@ -7,7 +7,6 @@ import (
 	"github.com/YaleSpinup/apierror"
 	"github.com/YaleSpinup/ecs-api/common"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
 	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
@@ -29,10 +28,7 @@ type TagFilter struct {
 func NewSession(account common.Account) ResourceGroupsTaggingAPI {
 	s := ResourceGroupsTaggingAPI{}
 	log.Infof("creating new aws session for resourcegroupstaggingapi with key id %s in region %s", account.Akid, account.Region)
-	sess := session.Must(session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(account.Akid, account.Secret, ""),
-		Region:      aws.String(account.Region),
-	}))
+	sess := session.Must(session.NewSession(account.AWSConfig()))
 	s.Service = resourcegroupstaggingapi.New(sess)
 	return s
 }
@@ -3,9 +3,14 @@ package secretsmanager
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	log "github.com/sirupsen/logrus"
 )
@@ -39,12 +44,70 @@ func (s *SecretsManager) CreateSecret(ctx context.Context, input *secretsmanager
 // ListSecretsWithFilter lists all of the secrets with a passed filter function
 func (s *SecretsManager) ListSecretsWithFilter(ctx context.Context, filter func(*secretsmanager.SecretListEntry) bool) ([]*string, error) {
 	log.Info("listing secretsmanager secrets with filter")
+
+	return s.listSecrets(ctx, nil, filter)
+}
+
+// ListSecretsWithTags lists the secrets with all of the passed tags.  The tag keys and values are passed as filters
+// so secretsmanager does the bulk of the filtering server side.  Those filters match any of the keys and any of the
+// values by prefix, so each returned secret is also checked for the exact key/value pairs.
+func (s *SecretsManager) ListSecretsWithTags(ctx context.Context, tags map[string]string) ([]*string, error) {
+	if len(tags) > maxListSecretsFilterValues {
+		msg := fmt.Sprintf("invalid input, at most %d tags can be used to filter secrets", maxListSecretsFilterValues)
+		return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	log.Infof("listing secretsmanager secrets with tags %+v", tags)
+
+	var filters []*secretsmanager.Filter
+	if len(tags) > 0 {
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		values := make([]*string, len(keys))
+		for i, k := range keys {
+			values[i] = aws.String(tags[k])
+		}
+
+		filters = []*secretsmanager.Filter{
+			{Key: aws.String(secretsmanager.FilterNameStringTypeTagKey), Values: aws.StringSlice(keys)},
+			{Key: aws.String(secretsmanager.FilterNameStringTypeTagValue), Values: values},
+		}
+	}
+
+	return s.listSecrets(ctx, filters, func(secret *secretsmanager.SecretListEntry) bool {
+		for k, v := range tags {
+			found := false
+			for _, t := range secret.Tags {
+				if aws.StringValue(t.Key) == k && aws.StringValue(t.Value) == v {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+// listSecrets pages through the secrets matching the server side filters and returns the ARNs of those that pass the filter function
+func (s *SecretsManager) listSecrets(ctx context.Context, filters []*secretsmanager.Filter, filter func(*secretsmanager.SecretListEntry) bool) ([]*string, error) {
 	secrets := []*string{}
 
 	i := 0
 	next := ""
 	for i == 0 || next != "" {
-		input := secretsmanager.ListSecretsInput{MaxResults: aws.Int64(100)}
+		input := secretsmanager.ListSecretsInput{
+			Filters:    filters,
+			MaxResults: aws.Int64(100),
+		}
 		if next != "" {
 			input.NextToken = aws.String(next)
 		}
@@ -108,16 +171,40 @@ func (s *SecretsManager) GetValue(ctx context.Context, id string) (*secretsmanag
 	return out, nil
 }
 
-// DeleteSecret marks a secret for deletion. Optionally, the secret can be forcefully deleted.
+// DeleteSecret marks a secret for deletion. Optionally, the secret can be forcefully deleted.  Secrets tagged
+// with ProtectedTagKey=true are refused, use DeleteProtectedSecret to delete them.
 func (s *SecretsManager) DeleteSecret(ctx context.Context, id string, window int64) (*secretsmanager.DeleteSecretOutput, error) {
+	return s.deleteSecret(ctx, id, window, false)
+}
+
+// DeleteProtectedSecret marks a secret for deletion, overriding the deletion protection tag
+func (s *SecretsManager) DeleteProtectedSecret(ctx context.Context, id string, window int64) (*secretsmanager.DeleteSecretOutput, error) {
+	return s.deleteSecret(ctx, id, window, true)
+}
+
+func (s *SecretsManager) deleteSecret(ctx context.Context, id string, window int64, override bool) (*secretsmanager.DeleteSecretOutput, error) {
 	if id == "" {
 		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
 	}
 
-	log.Infof("deleting secret %s with window %d", id, window)
+	meta, err := s.describeSecret(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !override && secretProtected(meta) {
+		msg := fmt.Sprintf("secret %s is protected from deletion by the %s tag", id, ProtectedTagKey)
+		return nil, apierror.New(apierror.ErrForbidden, msg, nil)
+	}
 
 	input := secretsmanager.DeleteSecretInput{SecretId: aws.String(id)}
 	if window == 0 {
+		// a secret can't be deleted while it's replicated to other regions.  The replicas are only removed when the
+		// secret is deleted without recovery, a windowed delete of a replicated secret is refused by the api
+		// and the replicas are kept.
+		if err := s.removeReplicaRegions(ctx, meta); err != nil {
+			return nil, err
+		}
 		input.ForceDeleteWithoutRecovery = aws.Bool(true)
 	} else {
 		if window < 7 || window > 30 {
@@ -126,6 +213,8 @@ func (s *SecretsManager) DeleteSecret(ctx context.Context, id string, window int
 		input.RecoveryWindowInDays = aws.Int64(window)
 	}
 
+	log.Infof("deleting secret %s with window %d", id, window)
+
 	out, err := s.Service.DeleteSecretWithContext(ctx, &input)
 	if err != nil {
 		msg := fmt.Sprintf("failed to delete secret with id %s", id)
@@ -137,6 +226,142 @@ func (s *SecretsManager) DeleteSecret(ctx context.Context, id string, window int
 	return out, nil
 }
 
+// describeSecret gets the metadata of a secret.  A secret that cannot be found returns nil metadata, the deletion
+// reports the missing secret.
+func (s *SecretsManager) describeSecret(ctx context.Context, id string) (*secretsmanager.DescribeSecretOutput, error) {
+	out, err := s.Service.DescribeSecretWithContext(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(id)})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			return nil, nil
+		}
+		msg := fmt.Sprintf("failed to describe secret with id %s", id)
+		return nil, ErrCode(msg, err)
+	}
+
+	return out, nil
+}
+
+// secretProtected checks the tags of a secret for ProtectedTagKey=true
+func secretProtected(meta *secretsmanager.DescribeSecretOutput) bool {
+	if meta == nil {
+		return false
+	}
+
+	for _, tag := range meta.Tags {
+		if aws.StringValue(tag.Key) == ProtectedTagKey && strings.EqualFold(aws.StringValue(tag.Value), "true") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeReplicaRegions removes the replicas of a secret in other regions so the primary secret can be deleted
+func (s *SecretsManager) removeReplicaRegions(ctx context.Context, meta *secretsmanager.DescribeSecretOutput) error {
+	if meta == nil || len(meta.ReplicationStatus) == 0 {
+		return nil
+	}
+
+	regions := make([]string, 0, len(meta.ReplicationStatus))
+	for _, r := range meta.ReplicationStatus {
+		regions = append(regions, aws.StringValue(r.Region))
+	}
+
+	log.Infof("removing replicas of secret %s in %s", aws.StringValue(meta.ARN), strings.Join(regions, ", "))
+
+	if _, err := s.Service.RemoveRegionsFromReplicationWithContext(ctx, &secretsmanager.RemoveRegionsFromReplicationInput{
+		SecretId:             meta.ARN,
+		RemoveReplicaRegions: aws.StringSlice(regions),
+	}); err != nil {
+		msg := fmt.Sprintf("failed to remove replicas of secret %s", aws.StringValue(meta.ARN))
+		return ErrCode(msg, err)
+	}
+
+	return nil
+}
+
+// ReplicateSecret replicates a secret to the regions.  Each replica is encrypted with the KMS key for its region in
+// kmsKeyIds, or the aws/secretsmanager key of the region if there isn't one.  Existing secrets with the same name in a
+// region aren't overwritten and are reported with a failed status.
+func (s *SecretsManager) ReplicateSecret(ctx context.Context, id string, regions []string, kmsKeyIds map[string]string) ([]*secretsmanager.ReplicationStatusType, error) {
+	if id == "" || len(regions) == 0 {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	replicas := make([]*secretsmanager.ReplicaRegionType, 0, len(regions))
+	for _, r := range regions {
+		replica := &secretsmanager.ReplicaRegionType{Region: aws.String(r)}
+		if k, ok := kmsKeyIds[r]; ok && k != "" {
+			replica.KmsKeyId = aws.String(k)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	log.Infof("replicating secret %s to %s", id, strings.Join(regions, ", "))
+
+	out, err := s.Service.ReplicateSecretToRegionsWithContext(ctx, &secretsmanager.ReplicateSecretToRegionsInput{
+		SecretId:          aws.String(id),
+		AddReplicaRegions: replicas,
+	})
+	if err != nil {
+		msg := fmt.Sprintf("failed to replicate secret %s", id)
+		return nil, ErrCode(msg, err)
+	}
+
+	for _, r := range out.ReplicationStatus {
+		if aws.StringValue(r.Status) == secretsmanager.StatusTypeFailed {
+			log.Warnf("failed to replicate secret %s to %s: %s", id, aws.StringValue(r.Region), aws.StringValue(r.StatusMessage))
+		}
+	}
+
+	return out.ReplicationStatus, nil
+}
+
+// DeleteSecrets deletes a batch of secrets concurrently, with at most DefaultDeleteSecretsConcurrency deletions in flight
+// at a time.  The returned map contains an error for each id that failed to delete and is empty if all deletions succeeded.
+func (s *SecretsManager) DeleteSecrets(ctx context.Context, ids []string, window int64) map[string]error {
+	log.Infof("deleting %d secrets with window %d", len(ids), window)
+
+	errs := make(map[string]error)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	concurrency := DefaultDeleteSecretsConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	seen := make(map[string]struct{}, len(ids))
+
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if _, err := s.DeleteSecret(ctx, id, window); err != nil {
+				log.Errorf("failed to delete secret %s: %s", id, err)
+
+				mu.Lock()
+				errs[id] = err
+				mu.Unlock()
+			}
+		}(id)
+	}
+
+	wg.Wait()
+
+	return errs
+}
+
 // UpdateSecret updates the value of the secret, replacing the current version with the new version
 func (s *SecretsManager) UpdateSecret(ctx context.Context, input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
 	if input == nil {
@@ -181,3 +406,61 @@ func (s *SecretsManager) UpdateSecretTags(ctx context.Context, id string, tags [
 
 	return nil
 }
+
+// UpdateSecretKmsKey changes the KMS key used to encrypt the secret.  Secrets Manager re-encrypts the
+// current version of the secret with the new key.
+func (s *SecretsManager) UpdateSecretKmsKey(ctx context.Context, id, kmsKeyId string) (*secretsmanager.UpdateSecretOutput, error) {
+	if id == "" || kmsKeyId == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("updating kms key for secret %s to %s", id, kmsKeyId)
+
+	out, err := s.Service.UpdateSecretWithContext(ctx, &secretsmanager.UpdateSecretInput{
+		SecretId: aws.String(id),
+		KmsKeyId: aws.String(kmsKeyId),
+	})
+	if err != nil {
+		return nil, ErrCode("failed to update secret kms key", err)
+	}
+
+	log.Debugf("returning secret kms key update output %+v", out)
+
+	return out, nil
+}
+
+// RotationStatus is the rotation configuration of a secret
+type RotationStatus struct {
+	RotationEnabled bool
+	// RotationLambdaARN is the lambda function that rotates the secret
+	RotationLambdaARN string `json:",omitempty"`
+	// RotationRules is the rotation schedule of the secret
+	RotationRules   *secretsmanager.RotationRulesType `json:",omitempty"`
+	LastRotatedDate *time.Time                        `json:",omitempty"`
+}
+
+// GetRotationStatus gets the rotation status of a secret from its metadata
+func (s *SecretsManager) GetRotationStatus(ctx context.Context, id string) (*RotationStatus, error) {
+	if id == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("getting rotation status of secret %s", id)
+
+	out, err := s.Service.DescribeSecretWithContext(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(id)})
+	if err != nil {
+		msg := fmt.Sprintf("failed to describe secret with id %s", id)
+		return nil, ErrCode(msg, err)
+	}
+
+	status := &RotationStatus{
+		RotationEnabled:   aws.BoolValue(out.RotationEnabled),
+		RotationLambdaARN: aws.StringValue(out.RotationLambdaARN),
+		RotationRules:     out.RotationRules,
+		LastRotatedDate:   out.LastRotatedDate,
+	}
+
+	log.Debugf("returning rotation status for secret %s: %+v", id, status)
+
+	return status, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 
@@ -42,6 +43,18 @@ var secretMeta1 = &secretsmanager.DescribeSecretOutput{
 	},
 }
 
+var secretMetaRotated = &secretsmanager.DescribeSecretOutput{
+	ARN:               aws.String("arn:aws:secretsmanager:us-east-1:00000000000:secret:Rotated01-abcdefg"),
+	Name:              aws.String("Rotated01"),
+	LastChangedDate:   &now,
+	LastRotatedDate:   &now,
+	RotationEnabled:   aws.Bool(true),
+	RotationLambdaARN: aws.String("arn:aws:lambda:us-east-1:00000000000:function:rotate"),
+	RotationRules: &secretsmanager.RotationRulesType{
+		AutomaticallyAfterDays: aws.Int64(30),
+	},
+}
+
 var secretMeta2 = &secretsmanager.DescribeSecretOutput{
 	ARN:             aws.String("arn:aws:secretsmanager:us-east-1:00000000000:secret:Secret02-abcdefg"),
 	Name:            aws.String("Secret02"),
@@ -84,6 +97,27 @@ var secretMeta3 = &secretsmanager.DescribeSecretOutput{
 	},
 }
 
+var secretMeta4 = &secretsmanager.DescribeSecretOutput{
+	ARN:             aws.String("arn:aws:secretsmanager:us-east-1:00000000000:secret:Secret04-abcdefg"),
+	Name:            aws.String("Secret04"),
+	LastChangedDate: &now,
+	Tags: []*secretsmanager.Tag{
+		{
+			Key:   aws.String("spinup:org"),
+			Value: aws.String("test"),
+		},
+		{
+			Key:   aws.String("spinup:protected"),
+			Value: aws.String("true"),
+		},
+	},
+	VersionIdsToStages: map[string][]*string{
+		"00000000-1111-2222-3333-444444444444": {
+			aws.String("AWSCURRENT"),
+		},
+	},
+}
+
 var secretList1 = []*secretsmanager.SecretListEntry{
 	{
 		ARN:  aws.String("arn:aws:secretsmanager:us-east-1:00000000000:secret:Secret01-abcdefg"),
@@ -103,10 +137,18 @@ var secretList2 = []*secretsmanager.SecretListEntry{
 	{
 		ARN:  aws.String("arn:aws:secretsmanager:us-east-1:00000000000:secret:Secret11-abcdefg"),
 		Name: aws.String("Secret11"),
+		Tags: []*secretsmanager.Tag{
+			{Key: aws.String("spinup:org"), Value: aws.String("test")},
+			{Key: aws.String("spinup:spaceid"), Value: aws.String("space1")},
+		},
 	},
 	{
 		ARN:  aws.String("arn:aws:secretsmanager:us-east-1:00000000000:secret:Secret12-abcdefg"),
 		Name: aws.String("Secret12"),
+		Tags: []*secretsmanager.Tag{
+			{Key: aws.String("spinup:org"), Value: aws.String("test")},
+			{Key: aws.String("spinup:spaceid"), Value: aws.String("space12")},
+		},
 	},
 	{
 		ARN:  aws.String("arn:aws:secretsmanager:us-east-1:00000000000:secret:Secret13-abcdefg"),
@@ -148,8 +190,19 @@ func (m *mockSecretsManagerClient) DescribeSecretWithContext(ctx context.Context
 		return nil, m.err
 	}
 
-	for _, s := range []*secretsmanager.DescribeSecretOutput{secretMeta1, secretMeta2, secretMeta3} {
+	for _, s := range []*secretsmanager.DescribeSecretOutput{secretMeta1, secretMeta2, secretMeta3, secretMeta4, secretMetaRotated} {
 		if aws.StringValue(input.SecretId) == aws.StringValue(s.ARN) {
+			if regions, ok := m.replicas[aws.StringValue(s.ARN)]; ok {
+				out := *s
+				for _, r := range regions {
+					out.ReplicationStatus = append(out.ReplicationStatus, &secretsmanager.ReplicationStatusType{
+						Region: aws.String(r),
+						Status: aws.String(secretsmanager.StatusTypeInSync),
+					})
+				}
+				return &out, nil
+			}
+
 			return s, nil
 		}
 	}
@@ -162,8 +215,12 @@ func (m *mockSecretsManagerClient) DeleteSecretWithContext(ctx context.Context,
 		return nil, m.err
 	}
 
+	if len(m.replicas[aws.StringValue(input.SecretId)]) > 0 {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidRequestException, "secret is replicated", nil)
+	}
+
 	deleteDate := now.Add(time.Duration(aws.Int64Value(input.RecoveryWindowInDays) * 24))
-	for _, s := range []*secretsmanager.DescribeSecretOutput{secretMeta1, secretMeta2, secretMeta3} {
+	for _, s := range []*secretsmanager.DescribeSecretOutput{secretMeta1, secretMeta2, secretMeta3, secretMeta4} {
 		if aws.StringValue(input.SecretId) == aws.StringValue(s.ARN) {
 			return &secretsmanager.DeleteSecretOutput{
 				ARN:          s.ARN,
@@ -181,6 +238,8 @@ func (m *mockSecretsManagerClient) ListSecretsWithContext(ctx context.Context, i
 		return nil, m.err
 	}
 
+	m.filters = input.Filters
+
 	if aws.StringValue(input.NextToken) == "" {
 		return testSecretsList[0], nil
 	}
@@ -313,6 +372,87 @@ func TestListSecretsWithFilter(t *testing.T) {
 	}
 }
 
+func TestListSecretsWithTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		tags        map[string]string
+		err         error
+		want        []string
+		wantFilters []*secretsmanager.Filter
+		wantCode    string
+	}{
+		{
+			name: "org and space",
+			tags: map[string]string{"spinup:spaceid": "space1", "spinup:org": "test"},
+			want: []string{"arn:aws:secretsmanager:us-east-1:00000000000:secret:Secret11-abcdefg"},
+			wantFilters: []*secretsmanager.Filter{
+				{Key: aws.String("tag-key"), Values: aws.StringSlice([]string{"spinup:org", "spinup:spaceid"})},
+				{Key: aws.String("tag-value"), Values: aws.StringSlice([]string{"test", "space1"})},
+			},
+		},
+		{
+			name: "org",
+			tags: map[string]string{"spinup:org": "test"},
+			want: []string{
+				"arn:aws:secretsmanager:us-east-1:00000000000:secret:Secret11-abcdefg",
+				"arn:aws:secretsmanager:us-east-1:00000000000:secret:Secret12-abcdefg",
+			},
+			wantFilters: []*secretsmanager.Filter{
+				{Key: aws.String("tag-key"), Values: aws.StringSlice([]string{"spinup:org"})},
+				{Key: aws.String("tag-value"), Values: aws.StringSlice([]string{"test"})},
+			},
+		},
+		{
+			name: "no matches",
+			tags: map[string]string{"spinup:org": "other"},
+			want: []string{},
+			wantFilters: []*secretsmanager.Filter{
+				{Key: aws.String("tag-key"), Values: aws.StringSlice([]string{"spinup:org"})},
+				{Key: aws.String("tag-value"), Values: aws.StringSlice([]string{"other"})},
+			},
+		},
+		{
+			name: "too many tags",
+			tags: map[string]string{
+				"t0": "v", "t1": "v", "t2": "v", "t3": "v", "t4": "v", "t5": "v",
+				"t6": "v", "t7": "v", "t8": "v", "t9": "v", "t10": "v",
+			},
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "aws error",
+			tags:     map[string]string{"spinup:org": "test"},
+			err:      awserr.New(secretsmanager.ErrCodeInternalServiceError, "Internal Error", nil),
+			wantCode: apierror.ErrInternalError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := SecretsManager{Service: newmockSecretsManagerClient(t, tt.err)}
+			out, err := s.ListSecretsWithTags(context.TODO(), tt.tags)
+			if tt.wantCode != "" {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %v", tt.wantCode, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error %s", err)
+				return
+			}
+
+			if got := aws.StringValueSlice(out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+
+			if filters := s.Service.(*mockSecretsManagerClient).filters; !reflect.DeepEqual(filters, tt.wantFilters) {
+				t.Errorf("expected list secrets filters %s, got %s", awsutil.Prettify(tt.wantFilters), awsutil.Prettify(filters))
+			}
+		})
+	}
+}
+
 func TestCreateSecrets(t *testing.T) {
 	s := SecretsManager{Service: newmockSecretsManagerClient(t, nil)}
 	expected := &secretsmanager.CreateSecretOutput{
@@ -441,6 +581,189 @@ func TestDeleteSecret(t *testing.T) {
 	}
 }
 
+func TestDeleteProtectedSecret(t *testing.T) {
+	s := SecretsManager{Service: newmockSecretsManagerClient(t, nil)}
+
+	// test that a protected secret is refused
+	_, err := s.DeleteSecret(context.TODO(), aws.StringValue(secretMeta4.ARN), int64(0))
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrForbidden {
+		t.Errorf("expected apierr forbidden deleting protected secret, got %s", err)
+	}
+
+	// test that the override deletes a protected secret
+	out, err := s.DeleteProtectedSecret(context.TODO(), aws.StringValue(secretMeta4.ARN), int64(0))
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if aws.StringValue(out.ARN) != aws.StringValue(secretMeta4.ARN) {
+		t.Errorf("expected deleted secret %s, got %s", aws.StringValue(secretMeta4.ARN), aws.StringValue(out.ARN))
+	}
+
+	// test that an unprotected secret is deleted
+	if _, err := s.DeleteSecret(context.TODO(), aws.StringValue(secretMeta2.ARN), int64(0)); err != nil {
+		t.Errorf("unexpected error deleting unprotected secret: %s", err)
+	}
+
+	// test that a missing secret is reported as not found
+	_, err = s.DeleteSecret(context.TODO(), "arn:aws:secretsmanager:us-east-1:1234567890:secret:missing", int64(0))
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+		t.Errorf("expected apierr not found, got %s", err)
+	}
+}
+
+func (m *mockSecretsManagerClient) RemoveRegionsFromReplicationWithContext(ctx context.Context, input *secretsmanager.RemoveRegionsFromReplicationInput, opts ...request.Option) (*secretsmanager.RemoveRegionsFromReplicationOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	id := aws.StringValue(input.SecretId)
+	remaining := []string{}
+	for _, r := range m.replicas[id] {
+		if !contains(aws.StringValueSlice(input.RemoveReplicaRegions), r) {
+			remaining = append(remaining, r)
+		}
+	}
+	m.replicas[id] = remaining
+
+	return &secretsmanager.RemoveRegionsFromReplicationOutput{ARN: input.SecretId}, nil
+}
+
+func (m *mockSecretsManagerClient) ReplicateSecretToRegionsWithContext(ctx context.Context, input *secretsmanager.ReplicateSecretToRegionsInput, opts ...request.Option) (*secretsmanager.ReplicateSecretToRegionsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	out := &secretsmanager.ReplicateSecretToRegionsOutput{ARN: input.SecretId}
+	for _, r := range input.AddReplicaRegions {
+		status := &secretsmanager.ReplicationStatusType{
+			KmsKeyId: r.KmsKeyId,
+			Region:   r.Region,
+			Status:   aws.String(secretsmanager.StatusTypeInProgress),
+		}
+
+		// a secret with the same name already exists in us-west-1
+		if aws.StringValue(r.Region) == "us-west-1" {
+			status.Status = aws.String(secretsmanager.StatusTypeFailed)
+			status.StatusMessage = aws.String("Destination secret already exists")
+		}
+
+		out.ReplicationStatus = append(out.ReplicationStatus, status)
+	}
+
+	return out, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, l := range list {
+		if l == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReplicateSecret(t *testing.T) {
+	s := SecretsManager{Service: newmockSecretsManagerClient(t, nil)}
+
+	out, err := s.ReplicateSecret(context.TODO(), aws.StringValue(secretMeta1.ARN), []string{"us-west-2", "us-west-1"}, map[string]string{
+		"us-west-2": "arn:aws:kms:us-west-2:00000000000:key/abc",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []*secretsmanager.ReplicationStatusType{
+		{
+			KmsKeyId: aws.String("arn:aws:kms:us-west-2:00000000000:key/abc"),
+			Region:   aws.String("us-west-2"),
+			Status:   aws.String("InProgress"),
+		},
+		{
+			Region:        aws.String("us-west-1"),
+			Status:        aws.String("Failed"),
+			StatusMessage: aws.String("Destination secret already exists"),
+		},
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected %+v, got %+v", expected, out)
+	}
+
+	if _, err := s.ReplicateSecret(context.TODO(), aws.StringValue(secretMeta1.ARN), nil, nil); err == nil {
+		t.Error("expected error for empty regions, got nil")
+	}
+
+	if _, err := s.ReplicateSecret(context.TODO(), "", []string{"us-west-2"}, nil); err == nil {
+		t.Error("expected error for empty id, got nil")
+	}
+
+	s.Service.(*mockSecretsManagerClient).err = awserr.New(secretsmanager.ErrCodeInvalidParameterException, "invalid region", nil)
+	_, err = s.ReplicateSecret(context.TODO(), aws.StringValue(secretMeta1.ARN), []string{"us-wast-2"}, nil)
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+		t.Errorf("expected apierr bad request, got %s", err)
+	}
+}
+
+func TestDeleteReplicatedSecret(t *testing.T) {
+	id := aws.StringValue(secretMeta2.ARN)
+	m := &mockSecretsManagerClient{t: t, replicas: map[string][]string{id: {"us-west-2", "us-east-2"}}}
+	s := SecretsManager{Service: m}
+
+	if _, err := s.DeleteSecret(context.TODO(), id, int64(0)); err != nil {
+		t.Fatalf("unexpected error deleting replicated secret: %s", err)
+	}
+
+	if len(m.replicas[id]) != 0 {
+		t.Errorf("expected the replicas to be removed, got %v", m.replicas[id])
+	}
+
+	// test that a windowed delete keeps the replicas
+	m.replicas[id] = []string{"us-west-2", "us-east-2"}
+	if _, err := s.DeleteSecret(context.TODO(), id, int64(30)); err == nil {
+		t.Error("expected error for windowed delete of replicated secret, got nil")
+	}
+
+	if !reflect.DeepEqual(m.replicas[id], []string{"us-west-2", "us-east-2"}) {
+		t.Errorf("expected the replicas to be kept, got %v", m.replicas[id])
+	}
+}
+
+func TestDeleteSecrets(t *testing.T) {
+	s := SecretsManager{Service: newmockSecretsManagerClient(t, nil)}
+
+	ids := []string{
+		aws.StringValue(secretMeta1.ARN),
+		aws.StringValue(secretMeta2.ARN),
+		"arn:aws:secretsmanager:us-east-1:1234567890:secret:missing",
+		aws.StringValue(secretMeta3.ARN),
+	}
+
+	errs := s.DeleteSecrets(context.TODO(), ids, int64(0))
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %+v", len(errs), errs)
+	}
+
+	err, ok := errs["arn:aws:secretsmanager:us-east-1:1234567890:secret:missing"]
+	if !ok {
+		t.Fatalf("expected error for missing secret, got %+v", errs)
+	}
+
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+		t.Errorf("expected apierr not found, got %s", err)
+	}
+
+	// test an empty batch
+	if errs := s.DeleteSecrets(context.TODO(), []string{}, int64(0)); len(errs) != 0 {
+		t.Errorf("expected no errors for empty batch, got %+v", errs)
+	}
+
+	// test an error from the api for every deletion
+	s.Service.(*mockSecretsManagerClient).err = awserr.New(secretsmanager.ErrCodeInternalServiceError, "Internal Error", nil)
+	if errs := s.DeleteSecrets(context.TODO(), ids, int64(0)); len(errs) != len(ids) {
+		t.Errorf("expected %d errors, got %d", len(ids), len(errs))
+	}
+}
+
 func TestUpdateSecrets(t *testing.T) {
 	s := SecretsManager{Service: newmockSecretsManagerClient(t, nil)}
 	expected := &secretsmanager.PutSecretValueOutput{
@@ -491,6 +814,80 @@ func TestUpdateSecrets(t *testing.T) {
 	}
 }
 
+func (m *mockSecretsManagerClient) UpdateSecretWithContext(ctx context.Context, input *secretsmanager.UpdateSecretInput, opts ...request.Option) (*secretsmanager.UpdateSecretOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	for _, s := range []*secretsmanager.DescribeSecretOutput{secretMeta1, secretMeta2} {
+		if aws.StringValue(input.SecretId) == aws.StringValue(s.ARN) || aws.StringValue(input.SecretId) == aws.StringValue(s.Name) {
+			if m.kmsKeyIds == nil {
+				m.kmsKeyIds = map[string]string{}
+			}
+			m.kmsKeyIds[aws.StringValue(s.ARN)] = aws.StringValue(input.KmsKeyId)
+
+			return &secretsmanager.UpdateSecretOutput{
+				ARN:  s.ARN,
+				Name: s.Name,
+			}, nil
+		}
+	}
+
+	return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "Secrets Manager can't find the specified secret.", nil)
+}
+
+func TestUpdateSecretKmsKey(t *testing.T) {
+	s := SecretsManager{Service: newmockSecretsManagerClient(t, nil)}
+	keyId := "arn:aws:kms:us-east-1:00000000000:key/11111111-2222-3333-4444-555555555555"
+
+	expected := &secretsmanager.UpdateSecretOutput{
+		ARN:  secretMeta1.ARN,
+		Name: secretMeta1.Name,
+	}
+
+	out, err := s.UpdateSecretKmsKey(context.TODO(), aws.StringValue(secretMeta1.ARN), keyId)
+	if err != nil {
+		t.Errorf("expected nil error, got %s", err)
+	}
+
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected %+v, got %+v", expected, out)
+	}
+
+	if got := s.Service.(*mockSecretsManagerClient).kmsKeyIds[aws.StringValue(secretMeta1.ARN)]; got != keyId {
+		t.Errorf("expected kms key id %s to be passed, got %s", keyId, got)
+	}
+
+	if _, err = s.UpdateSecretKmsKey(context.TODO(), aws.StringValue(secretMeta1.ARN), ""); err == nil {
+		t.Error("expected error for empty kms key id, got nil")
+	}
+
+	if _, err = s.UpdateSecretKmsKey(context.TODO(), "", keyId); err == nil {
+		t.Error("expected error for empty id, got nil")
+	}
+
+	// test a missing secret
+	_, err = s.UpdateSecretKmsKey(context.TODO(), "arn:aws:secretsmanager:us-east-1:00000000000:secret:Missing-abcdefg", keyId)
+	if aerr, ok := err.(apierror.Error); ok {
+		if aerr.Code != apierror.ErrNotFound {
+			t.Errorf("expected error code %s, got: %s", apierror.ErrNotFound, aerr.Code)
+		}
+	} else {
+		t.Errorf("expected apierror.Error, got: %s", reflect.TypeOf(err).String())
+	}
+
+	// test an error from the api secretsmanager.ErrCodeInternalServiceError
+	s.Service.(*mockSecretsManagerClient).err = awserr.New(secretsmanager.ErrCodeInternalServiceError, "Internal Error", nil)
+	_, err = s.UpdateSecretKmsKey(context.TODO(), aws.StringValue(secretMeta1.ARN), keyId)
+	if aerr, ok := err.(apierror.Error); ok {
+		if aerr.Code != apierror.ErrInternalError {
+			t.Errorf("expected error code %s, got: %s", apierror.ErrInternalError, aerr.Code)
+		}
+	} else {
+		t.Errorf("expected apierror.Error, got: %s", reflect.TypeOf(err).String())
+	}
+}
+
 func TestUpdateSecretTags(t *testing.T) {
 	s := SecretsManager{Service: newmockSecretsManagerClient(t, nil)}
 
@@ -509,3 +906,60 @@ func TestUpdateSecretTags(t *testing.T) {
 		t.Errorf("expected nil error, got %s", err)
 	}
 }
+
+func TestGetRotationStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		err     error
+		want    *RotationStatus
+		wantErr bool
+	}{
+		{
+			name:    "empty id",
+			wantErr: true,
+		},
+		{
+			name: "rotation enabled",
+			id:   "arn:aws:secretsmanager:us-east-1:00000000000:secret:Rotated01-abcdefg",
+			want: &RotationStatus{
+				RotationEnabled:   true,
+				RotationLambdaARN: "arn:aws:lambda:us-east-1:00000000000:function:rotate",
+				RotationRules: &secretsmanager.RotationRulesType{
+					AutomaticallyAfterDays: aws.Int64(30),
+				},
+				LastRotatedDate: &now,
+			},
+		},
+		{
+			name: "not rotating",
+			id:   "arn:aws:secretsmanager:us-east-1:00000000000:secret:Secret01-abcdefg",
+			want: &RotationStatus{},
+		},
+		{
+			name:    "missing secret",
+			id:      "arn:aws:secretsmanager:us-east-1:00000000000:secret:Missing-abcdefg",
+			wantErr: true,
+		},
+		{
+			name:    "aws error",
+			id:      "arn:aws:secretsmanager:us-east-1:00000000000:secret:Secret01-abcdefg",
+			err:     awserr.New(secretsmanager.ErrCodeInternalServiceError, "boom", nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := SecretsManager{Service: &mockSecretsManagerClient{t: t, err: tt.err}}
+			got, err := s.GetRotationStatus(context.TODO(), tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SecretsManager.GetRotationStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SecretsManager.GetRotationStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
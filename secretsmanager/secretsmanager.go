@@ -2,29 +2,42 @@ package secretsmanager
 
 import (
 	"github.com/YaleSpinup/ecs-api/common"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
 	log "github.com/sirupsen/logrus"
 )
 
+// DefaultDeleteSecretsConcurrency is the maximum number of concurrent secret deletions in a batch
+var DefaultDeleteSecretsConcurrency = 5
+
+// ProtectedTagKey is the tag key which, when set to "true", protects a secret from deletion
+const ProtectedTagKey = "spinup:protected"
+
+// maxListSecretsFilterValues is the maximum number of values secretsmanager accepts in a list secrets filter
+const maxListSecretsFilterValues = 10
+
 // SecretsManager is a wrapper around the aws secretsmanager service with some default config info
 type SecretsManager struct {
 	Service         secretsmanageriface.SecretsManagerAPI
 	DefaultKmsKeyId string
+	// SecretKmsKeyId is the KMS key used to encrypt the repository credentials secrets when one isn't passed
+	SecretKmsKeyId string
+	// ReplicaRegions are the regions repository credentials secrets are replicated to
+	ReplicaRegions []string
+	// ReplicaKmsKeyIds maps replica regions to the KMS key used to encrypt the replica in the region
+	ReplicaKmsKeyIds map[string]string
 }
 
 // NewSession creates a new cloudfront session
 func NewSession(account common.Account) SecretsManager {
 	s := SecretsManager{}
 	log.Infof("creating new aws session for secretsmanager with key id %s in region %s", account.Akid, account.Region)
-	sess := session.Must(session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(account.Akid, account.Secret, ""),
-		Region:      aws.String(account.Region),
-	}))
+	sess := session.Must(session.NewSession(account.AWSConfig()))
 	s.Service = secretsmanager.New(sess)
 	s.DefaultKmsKeyId = account.DefaultKmsKeyId
+	s.SecretKmsKeyId = account.SecretKmsKeyId
+	s.ReplicaRegions = account.SecretReplicaRegions
+	s.ReplicaKmsKeyIds = account.SecretReplicaKmsKeyIds
 	return s
 }
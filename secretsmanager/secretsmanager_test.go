@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/YaleSpinup/ecs-api/common"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
 )
 
@@ -13,6 +14,12 @@ type mockSecretsManagerClient struct {
 	secretsmanageriface.SecretsManagerAPI
 	t   *testing.T
 	err error
+	// kmsKeyIds records the kms key id passed when updating each secret
+	kmsKeyIds map[string]string
+	// replicas are the replica regions of each secret arn
+	replicas map[string][]string
+	// filters records the filters passed when listing secrets
+	filters []*secretsmanager.Filter
 }
 
 func newmockSecretsManagerClient(t *testing.T, err error) secretsmanageriface.SecretsManagerAPI {
@@ -6,11 +6,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/YaleSpinup/apierror"
 	"github.com/YaleSpinup/ecs-api/common"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/servicediscovery"
 	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
@@ -28,10 +28,7 @@ type ServiceDiscovery struct {
 func NewSession(account common.Account) ServiceDiscovery {
 	s := ServiceDiscovery{}
 	log.Infof("Creating new session with key id %s in region %s", account.Akid, account.Region)
-	sess := session.Must(session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(account.Akid, account.Secret, ""),
-		Region:      aws.String(account.Region),
-	}))
+	sess := session.Must(session.NewSession(account.AWSConfig()))
 	s.Service = servicediscovery.New(sess)
 	return s
 }
@@ -40,7 +37,7 @@ func NewSession(account common.Account) ServiceDiscovery {
 func (s *ServiceDiscovery) GetServiceDiscoveryService(ctx context.Context, id *string) (*servicediscovery.Service, error) {
 	output, err := s.Service.GetServiceWithContext(ctx, &servicediscovery.GetServiceInput{Id: id})
 	if err != nil {
-		return nil, err
+		return nil, ErrCode("failed to get service discovery service "+aws.StringValue(id), err)
 	}
 	return output.Service, err
 }
@@ -55,6 +52,28 @@ func (s *ServiceDiscovery) CreateServiceDiscoveryService(ctx context.Context, in
 	return output.Service, err
 }
 
+// UpdateServiceDiscoveryService updates the DNS records, health check configuration or description of a service
+// discovery service and returns the ID of the (asynchronous) update operation
+func (s *ServiceDiscovery) UpdateServiceDiscoveryService(ctx context.Context, id string, change *servicediscovery.ServiceChange) (string, error) {
+	if id == "" || change == nil {
+		return "", apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("updating service discovery service %s", id)
+
+	out, err := s.Service.UpdateServiceWithContext(ctx, &servicediscovery.UpdateServiceInput{
+		Id:      aws.String(id),
+		Service: change,
+	})
+	if err != nil {
+		return "", ErrCode("failed to update service discovery service "+id, err)
+	}
+
+	log.Debugf("started service discovery service %s update operation %s", id, aws.StringValue(out.OperationId))
+
+	return aws.StringValue(out.OperationId), nil
+}
+
 // DeleteServiceRegistry removes a service discovery service by it's ID
 func (s *ServiceDiscovery) DeleteServiceRegistry(ctx context.Context, serviceArn *string) error {
 	// parse the ARN into it's component parts and split the resource/resource-id
@@ -73,6 +92,48 @@ func (s *ServiceDiscovery) DeleteServiceRegistry(ctx context.Context, serviceArn
 	return nil
 }
 
+// DeregisterInstances deregisters all of the instances registered with a service discovery service, removing their DNS
+// records.  It returns the IDs of the deregistered instances.
+func (s *ServiceDiscovery) DeregisterInstances(ctx context.Context, id string) ([]string, error) {
+	if id == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("deregistering instances from service discovery service %s", id)
+
+	instances := []string{}
+	if err := s.Service.ListInstancesPagesWithContext(ctx, &servicediscovery.ListInstancesInput{
+		ServiceId: aws.String(id),
+	}, func(page *servicediscovery.ListInstancesOutput, lastPage bool) bool {
+		for _, i := range page.Instances {
+			instances = append(instances, aws.StringValue(i.Id))
+		}
+		return true
+	}); err != nil {
+		return nil, ErrCode("failed to list instances of service discovery service "+id, err)
+	}
+
+	deregistered := make([]string, 0, len(instances))
+	for _, i := range instances {
+		if _, err := s.Service.DeregisterInstanceWithContext(ctx, &servicediscovery.DeregisterInstanceInput{
+			InstanceId: aws.String(i),
+			ServiceId:  aws.String(id),
+		}); err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == servicediscovery.ErrCodeInstanceNotFound {
+				log.Debugf("instance %s of service discovery service %s is already deregistered", i, id)
+				continue
+			}
+			return deregistered, ErrCode("failed to deregister instance "+i+" from service discovery service "+id, err)
+		}
+
+		deregistered = append(deregistered, i)
+	}
+
+	log.Debugf("deregistered %d instances from service discovery service %s", len(deregistered), id)
+
+	return deregistered, nil
+}
+
 // DeleteServiceRegistryWithRetry continues to retry deleting a service registration until the context is cancelled or it succeeds
 func (s *ServiceDiscovery) DeleteServiceRegistryWithRetry(ctx context.Context, serviceArn *string) chan string {
 	srChan := make(chan string, 1)
@@ -150,3 +211,68 @@ func (s *ServiceDiscovery) ServiceEndpoint(ctx context.Context, registryArn stri
 	log.Warnf("service discovery endpoint not found")
 	return nil, nil
 }
+
+// ListNamespaces lists all of the service discovery namespaces
+func (s *ServiceDiscovery) ListNamespaces(ctx context.Context) ([]*servicediscovery.NamespaceSummary, error) {
+	log.Info("listing service discovery namespaces")
+
+	namespaces := []*servicediscovery.NamespaceSummary{}
+	if err := s.Service.ListNamespacesPagesWithContext(ctx, &servicediscovery.ListNamespacesInput{},
+		func(page *servicediscovery.ListNamespacesOutput, lastPage bool) bool {
+			namespaces = append(namespaces, page.Namespaces...)
+			return true
+		}); err != nil {
+		return nil, ErrCode("failed to list service discovery namespaces", err)
+	}
+
+	log.Debugf("listed %d service discovery namespaces", len(namespaces))
+
+	return namespaces, nil
+}
+
+// GetNamespace gets the details of a service discovery namespace
+func (s *ServiceDiscovery) GetNamespace(ctx context.Context, id string) (*servicediscovery.Namespace, error) {
+	if id == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("getting service discovery namespace %s", id)
+
+	out, err := s.Service.GetNamespaceWithContext(ctx, &servicediscovery.GetNamespaceInput{
+		Id: aws.String(id),
+	})
+	if err != nil {
+		return nil, ErrCode("failed to get service discovery namespace "+id, err)
+	}
+
+	return out.Namespace, nil
+}
+
+// ListServices lists the service discovery services in a namespace
+func (s *ServiceDiscovery) ListServices(ctx context.Context, namespaceID string) ([]*servicediscovery.ServiceSummary, error) {
+	if namespaceID == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing service discovery services in namespace %s", namespaceID)
+
+	services := []*servicediscovery.ServiceSummary{}
+	if err := s.Service.ListServicesPagesWithContext(ctx, &servicediscovery.ListServicesInput{
+		Filters: []*servicediscovery.ServiceFilter{
+			{
+				Name:      aws.String(servicediscovery.ServiceFilterNameNamespaceId),
+				Condition: aws.String(servicediscovery.FilterConditionEq),
+				Values:    aws.StringSlice([]string{namespaceID}),
+			},
+		},
+	}, func(page *servicediscovery.ListServicesOutput, lastPage bool) bool {
+		services = append(services, page.Services...)
+		return true
+	}); err != nil {
+		return nil, ErrCode("failed to list service discovery services in namespace "+namespaceID, err)
+	}
+
+	log.Debugf("listed %d service discovery services in namespace %s", len(services), namespaceID)
+
+	return services, nil
+}
@@ -7,8 +7,10 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/YaleSpinup/apierror"
 	"github.com/YaleSpinup/ecs-api/common"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/servicediscovery"
 	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
@@ -121,3 +123,229 @@ func TestGetServiceDiscovery(t *testing.T) {
 	}
 	t.Log("Got expected error from bad service discovery service", err)
 }
+
+var testNamespaces = []*servicediscovery.NamespaceSummary{
+	{Id: aws.String("ns-p5g6iyxdh5c5h3dr"), Name: aws.String("spinup.internal"), Type: aws.String("DNS_PRIVATE")},
+	{Id: aws.String("ns-emptyemptyempty"), Name: aws.String("empty.internal"), Type: aws.String("DNS_PRIVATE")},
+}
+
+var testNamespaceServices = map[string][]*servicediscovery.ServiceSummary{
+	"ns-p5g6iyxdh5c5h3dr": {
+		{Id: aws.String("srv-goodsd"), Name: aws.String("goodsd"), DnsConfig: goodSd.DnsConfig},
+		{Id: aws.String("srv-othersd"), Name: aws.String("othersd"), DnsConfig: goodSd.DnsConfig},
+	},
+}
+
+func (m *mockSDClient) ListNamespacesPagesWithContext(ctx aws.Context, input *servicediscovery.ListNamespacesInput, fn func(*servicediscovery.ListNamespacesOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	// return each namespace in its own page
+	for i, ns := range testNamespaces {
+		if !fn(&servicediscovery.ListNamespacesOutput{Namespaces: []*servicediscovery.NamespaceSummary{ns}}, i == len(testNamespaces)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *mockSDClient) GetNamespaceWithContext(ctx aws.Context, input *servicediscovery.GetNamespaceInput, opts ...request.Option) (*servicediscovery.GetNamespaceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	for _, ns := range testNamespaces {
+		if aws.StringValue(ns.Id) == aws.StringValue(input.Id) {
+			return &servicediscovery.GetNamespaceOutput{
+				Namespace: &servicediscovery.Namespace{Id: ns.Id, Name: ns.Name, Type: ns.Type},
+			}, nil
+		}
+	}
+
+	return nil, awserr.New(servicediscovery.ErrCodeNamespaceNotFound, "namespace not found", nil)
+}
+
+func (m *mockSDClient) ListServicesPagesWithContext(ctx aws.Context, input *servicediscovery.ListServicesInput, fn func(*servicediscovery.ListServicesOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	services := testNamespaceServices[aws.StringValue(input.Filters[0].Values[0])]
+	for i, s := range services {
+		if !fn(&servicediscovery.ListServicesOutput{Services: []*servicediscovery.ServiceSummary{s}}, i == len(services)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func TestListNamespaces(t *testing.T) {
+	client := ServiceDiscovery{Service: &mockSDClient{t: t}}
+	namespaces, err := client.ListNamespaces(context.TODO())
+	if err != nil {
+		t.Fatalf("expected no error listing namespaces, got %s", err)
+	}
+
+	if !reflect.DeepEqual(namespaces, testNamespaces) {
+		t.Errorf("expected %+v, got %+v", testNamespaces, namespaces)
+	}
+
+	client = ServiceDiscovery{Service: &mockSDClient{t: t, err: awserr.New(servicediscovery.ErrCodeInvalidInput, "bad", nil)}}
+	if _, err := client.ListNamespaces(context.TODO()); err == nil {
+		t.Error("expected error listing namespaces, got nil")
+	}
+}
+
+func TestGetNamespace(t *testing.T) {
+	client := ServiceDiscovery{Service: &mockSDClient{t: t}}
+	ns, err := client.GetNamespace(context.TODO(), "ns-p5g6iyxdh5c5h3dr")
+	if err != nil {
+		t.Fatalf("expected no error getting namespace, got %s", err)
+	}
+
+	if aws.StringValue(ns.Name) != "spinup.internal" {
+		t.Errorf("expected namespace spinup.internal, got %s", aws.StringValue(ns.Name))
+	}
+
+	_, err = client.GetNamespace(context.TODO(), "ns-missing")
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+		t.Errorf("expected not found error for missing namespace, got %v", err)
+	}
+
+	if _, err := client.GetNamespace(context.TODO(), ""); err == nil {
+		t.Error("expected error for empty namespace id, got nil")
+	}
+}
+
+func TestListServices(t *testing.T) {
+	client := ServiceDiscovery{Service: &mockSDClient{t: t}}
+	services, err := client.ListServices(context.TODO(), "ns-p5g6iyxdh5c5h3dr")
+	if err != nil {
+		t.Fatalf("expected no error listing services, got %s", err)
+	}
+
+	if !reflect.DeepEqual(services, testNamespaceServices["ns-p5g6iyxdh5c5h3dr"]) {
+		t.Errorf("expected %+v, got %+v", testNamespaceServices["ns-p5g6iyxdh5c5h3dr"], services)
+	}
+
+	services, err = client.ListServices(context.TODO(), "ns-emptyemptyempty")
+	if err != nil {
+		t.Fatalf("expected no error listing services, got %s", err)
+	}
+
+	if len(services) != 0 {
+		t.Errorf("expected no services, got %+v", services)
+	}
+
+	if _, err := client.ListServices(context.TODO(), ""); err == nil {
+		t.Error("expected error for empty namespace id, got nil")
+	}
+}
+
+func (m *mockSDClient) UpdateServiceWithContext(ctx aws.Context, input *servicediscovery.UpdateServiceInput, opts ...request.Option) (*servicediscovery.UpdateServiceOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if aws.StringValue(input.Id) != "srv-goodsd" {
+		return nil, awserr.New(servicediscovery.ErrCodeServiceNotFound, "service not found", nil)
+	}
+
+	return &servicediscovery.UpdateServiceOutput{OperationId: aws.String("op-goodsd")}, nil
+}
+
+func TestUpdateServiceDiscovery(t *testing.T) {
+	change := &servicediscovery.ServiceChange{
+		DnsConfig: &servicediscovery.DnsConfigChange{
+			DnsRecords: []*servicediscovery.DnsRecord{{TTL: aws.Int64(10), Type: aws.String("A")}},
+		},
+	}
+
+	client := ServiceDiscovery{Service: &mockSDClient{t: t}}
+	op, err := client.UpdateServiceDiscoveryService(context.TODO(), "srv-goodsd", change)
+	if err != nil {
+		t.Fatalf("expected no error updating service discovery service, got %s", err)
+	}
+
+	if op != "op-goodsd" {
+		t.Errorf("expected operation op-goodsd, got %s", op)
+	}
+
+	_, err = client.UpdateServiceDiscoveryService(context.TODO(), "srv-missing", change)
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+		t.Errorf("expected not found error for missing service, got %v", err)
+	}
+
+	if _, err := client.UpdateServiceDiscoveryService(context.TODO(), "srv-goodsd", nil); err == nil {
+		t.Error("expected error for empty service change, got nil")
+	}
+
+	client = ServiceDiscovery{Service: &mockSDClient{t: t, err: awserr.New(servicediscovery.ErrCodeDuplicateRequest, "in progress", nil)}}
+	_, err = client.UpdateServiceDiscoveryService(context.TODO(), "srv-goodsd", change)
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+		t.Errorf("expected bad request error for duplicate request, got %v", err)
+	}
+}
+
+// testInstances are the instances registered with the mock service discovery services, by service id
+var testInstances = map[string][]string{
+	"srv-goodsd": {"i-0123456789", "i-9876543210", "i-deregistered"},
+}
+
+func (m *mockSDClient) ListInstancesPagesWithContext(ctx aws.Context, input *servicediscovery.ListInstancesInput, fn func(*servicediscovery.ListInstancesOutput, bool) bool, opts ...request.Option) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	out := &servicediscovery.ListInstancesOutput{}
+	for _, i := range testInstances[aws.StringValue(input.ServiceId)] {
+		out.Instances = append(out.Instances, &servicediscovery.InstanceSummary{Id: aws.String(i)})
+	}
+
+	fn(out, true)
+
+	return nil
+}
+
+func (m *mockSDClient) DeregisterInstanceWithContext(ctx aws.Context, input *servicediscovery.DeregisterInstanceInput, opts ...request.Option) (*servicediscovery.DeregisterInstanceOutput, error) {
+	if aws.StringValue(input.InstanceId) == "i-deregistered" {
+		return nil, awserr.New(servicediscovery.ErrCodeInstanceNotFound, "instance not found", nil)
+	}
+
+	return &servicediscovery.DeregisterInstanceOutput{OperationId: aws.String("op-" + aws.StringValue(input.InstanceId))}, nil
+}
+
+func TestDeregisterInstances(t *testing.T) {
+	client := ServiceDiscovery{Service: &mockSDClient{t: t}}
+	deregistered, err := client.DeregisterInstances(context.TODO(), "srv-goodsd")
+	if err != nil {
+		t.Fatalf("expected no error deregistering instances, got %s", err)
+	}
+
+	// instances that are already deregistered are skipped
+	if expected := []string{"i-0123456789", "i-9876543210"}; !reflect.DeepEqual(deregistered, expected) {
+		t.Errorf("expected deregistered instances %v, got %v", expected, deregistered)
+	}
+
+	deregistered, err = client.DeregisterInstances(context.TODO(), "srv-empty")
+	if err != nil {
+		t.Fatalf("expected no error deregistering instances of a service without instances, got %s", err)
+	}
+
+	if len(deregistered) != 0 {
+		t.Errorf("expected no deregistered instances, got %v", deregistered)
+	}
+
+	if _, err := client.DeregisterInstances(context.TODO(), ""); err == nil {
+		t.Error("expected error for empty service id, got nil")
+	}
+
+	client = ServiceDiscovery{Service: &mockSDClient{t: t, err: awserr.New(servicediscovery.ErrCodeServiceNotFound, "service not found", nil)}}
+	_, err = client.DeregisterInstances(context.TODO(), "srv-missing")
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+		t.Errorf("expected not found error for missing service, got %v", err)
+	}
+}
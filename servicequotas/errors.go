@@ -0,0 +1,101 @@
+package servicequotas
+
+import (
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/pkg/errors"
+)
+
+func ErrCode(msg string, err error) error {
+	if aerr, ok := errors.Cause(err).(awserr.Error); ok {
+		switch aerr.Code() {
+		case
+
+			// ErrCodeAccessDeniedException for service response error code
+			// "AccessDeniedException".
+			//
+			// You do not have sufficient permission to perform this action.
+			servicequotas.ErrCodeAccessDeniedException,
+
+			// ErrCodeDependencyAccessDeniedException for service response error code
+			// "DependencyAccessDeniedException".
+			//
+			// You can't perform this action because a dependency does not have access.
+			servicequotas.ErrCodeDependencyAccessDeniedException:
+
+			return apierror.New(apierror.ErrForbidden, msg, aerr)
+		case
+
+			// ErrCodeNoSuchResourceException for service response error code
+			// "NoSuchResourceException".
+			//
+			// The specified resource does not exist.
+			servicequotas.ErrCodeNoSuchResourceException:
+
+			return apierror.New(apierror.ErrNotFound, msg, aerr)
+		case
+
+			// ErrCodeResourceAlreadyExistsException for service response error code
+			// "ResourceAlreadyExistsException".
+			//
+			// The specified resource already exists.
+			servicequotas.ErrCodeResourceAlreadyExistsException:
+
+			return apierror.New(apierror.ErrConflict, msg, aerr)
+		case
+
+			// ErrCodeQuotaExceededException for service response error code
+			// "QuotaExceededException".
+			//
+			// You have exceeded your service quota. To perform the requested action, remove
+			// some of the relevant resources, or use Service Quotas to request a service
+			// quota increase.
+			servicequotas.ErrCodeQuotaExceededException,
+
+			// ErrCodeTooManyRequestsException for service response error code
+			// "TooManyRequestsException".
+			//
+			// Due to throttling, the request was denied. Slow down the rate of request
+			// calls, or request an increase for this quota.
+			servicequotas.ErrCodeTooManyRequestsException:
+
+			return apierror.New(apierror.ErrLimitExceeded, msg, aerr)
+		case
+
+			// ErrCodeServiceException for service response error code
+			// "ServiceException".
+			//
+			// Something went wrong.
+			servicequotas.ErrCodeServiceException:
+
+			return apierror.New(apierror.ErrServiceUnavailable, msg, aerr)
+		case
+
+			// ErrCodeIllegalArgumentException for service response error code
+			// "IllegalArgumentException".
+			//
+			// Invalid input was provided.
+			servicequotas.ErrCodeIllegalArgumentException,
+
+			// ErrCodeInvalidPaginationTokenException for service response error code
+			// "InvalidPaginationTokenException".
+			//
+			// Invalid input was provided.
+			servicequotas.ErrCodeInvalidPaginationTokenException,
+
+			// ErrCodeInvalidResourceStateException for service response error code
+			// "InvalidResourceStateException".
+			//
+			// The resource is in an invalid state.
+			servicequotas.ErrCodeInvalidResourceStateException:
+
+			return apierror.New(apierror.ErrBadRequest, msg, aerr)
+		default:
+			m := msg + ": " + aerr.Message()
+			return apierror.New(apierror.ErrBadRequest, m, aerr)
+		}
+	}
+
+	return apierror.New(apierror.ErrInternalError, msg, err)
+}
@@ -0,0 +1,45 @@
+package servicequotas
+
+import (
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/pkg/errors"
+)
+
+func TestErrCode(t *testing.T) {
+	apiErrorTestCases := map[string]string{
+		"": apierror.ErrBadRequest,
+
+		servicequotas.ErrCodeAccessDeniedException:           apierror.ErrForbidden,
+		servicequotas.ErrCodeDependencyAccessDeniedException: apierror.ErrForbidden,
+		servicequotas.ErrCodeNoSuchResourceException:         apierror.ErrNotFound,
+		servicequotas.ErrCodeResourceAlreadyExistsException:  apierror.ErrConflict,
+		servicequotas.ErrCodeQuotaExceededException:          apierror.ErrLimitExceeded,
+		servicequotas.ErrCodeTooManyRequestsException:        apierror.ErrLimitExceeded,
+		servicequotas.ErrCodeServiceException:                apierror.ErrServiceUnavailable,
+		servicequotas.ErrCodeIllegalArgumentException:        apierror.ErrBadRequest,
+		servicequotas.ErrCodeInvalidPaginationTokenException: apierror.ErrBadRequest,
+		servicequotas.ErrCodeInvalidResourceStateException:   apierror.ErrBadRequest,
+	}
+
+	for awsErr, apiErr := range apiErrorTestCases {
+		err := ErrCode("test error", awserr.New(awsErr, awsErr, nil))
+		if aerr, ok := errors.Cause(err).(apierror.Error); ok {
+			if aerr.Code != apiErr {
+				t.Errorf("expected servicequotas error %s to be an apierror %s, got %s", awsErr, apiErr, aerr.Code)
+			}
+		} else {
+			t.Errorf("expected servicequotas error %s to be an apierror.Error %s, got %s", awsErr, apiErr, err)
+		}
+	}
+
+	err := ErrCode("test error", errors.New("Unknown"))
+	if aerr, ok := errors.Cause(err).(apierror.Error); ok {
+		t.Logf("got apierror '%s'", aerr)
+	} else {
+		t.Errorf("expected unknown error to be an apierror.ErrInternalError, got %s", err)
+	}
+}
@@ -0,0 +1,86 @@
+package servicequotas
+
+import (
+	"context"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/YaleSpinup/ecs-api/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	log "github.com/sirupsen/logrus"
+)
+
+// ServiceQuotas is a wrapper around the aws service quotas service
+type ServiceQuotas struct {
+	Service servicequotasiface.ServiceQuotasAPI
+}
+
+// NewSession creates a new service quotas session
+func NewSession(account common.Account) ServiceQuotas {
+	s := ServiceQuotas{}
+	log.Infof("creating new session with key id %s in region %s", account.Akid, account.Region)
+	sess := session.Must(session.NewSession(account.AWSConfig()))
+	s.Service = servicequotas.New(sess)
+	return s
+}
+
+// ListServiceQuotas lists the quota values applied to the account for a service (ie. ecs), following the pagination.  Quotas
+// without an applied value aren't returned, their default values are listed with ListDefaultServiceQuotas.
+func (s *ServiceQuotas) ListServiceQuotas(ctx context.Context, serviceCode string) ([]*servicequotas.ServiceQuota, error) {
+	if serviceCode == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing applied service quotas for %s", serviceCode)
+
+	quotas := []*servicequotas.ServiceQuota{}
+	input := &servicequotas.ListServiceQuotasInput{ServiceCode: aws.String(serviceCode)}
+	for {
+		out, err := s.Service.ListServiceQuotasWithContext(ctx, input)
+		if err != nil {
+			return nil, ErrCode("failed to list service quotas", err)
+		}
+
+		quotas = append(quotas, out.Quotas...)
+
+		if aws.StringValue(out.NextToken) == "" {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+
+	log.Debugf("got %d applied service quotas for %s", len(quotas), serviceCode)
+
+	return quotas, nil
+}
+
+// ListDefaultServiceQuotas lists the AWS default quota values for a service (ie. ecs), following the pagination
+func (s *ServiceQuotas) ListDefaultServiceQuotas(ctx context.Context, serviceCode string) ([]*servicequotas.ServiceQuota, error) {
+	if serviceCode == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("listing default service quotas for %s", serviceCode)
+
+	quotas := []*servicequotas.ServiceQuota{}
+	input := &servicequotas.ListAWSDefaultServiceQuotasInput{ServiceCode: aws.String(serviceCode)}
+	for {
+		out, err := s.Service.ListAWSDefaultServiceQuotasWithContext(ctx, input)
+		if err != nil {
+			return nil, ErrCode("failed to list default service quotas", err)
+		}
+
+		quotas = append(quotas, out.Quotas...)
+
+		if aws.StringValue(out.NextToken) == "" {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+
+	log.Debugf("got %d default service quotas for %s", len(quotas), serviceCode)
+
+	return quotas, nil
+}
@@ -0,0 +1,156 @@
+package servicequotas
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/YaleSpinup/apierror"
+	"github.com/YaleSpinup/ecs-api/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+)
+
+// testAppliedQuotas are the applied ecs quotas returned by the mock, split across pages
+var testAppliedQuotas = [][]*servicequotas.ServiceQuota{
+	{
+		{QuotaCode: aws.String("L-9EF96962"), QuotaName: aws.String("Services per cluster"), Value: aws.Float64(5000)},
+	},
+	{
+		{QuotaCode: aws.String("L-21C621EB"), QuotaName: aws.String("Clusters per account"), Value: aws.Float64(10000)},
+	},
+}
+
+// testDefaultQuotas are the default ecs quotas returned by the mock
+var testDefaultQuotas = []*servicequotas.ServiceQuota{
+	{QuotaCode: aws.String("L-9EF96962"), QuotaName: aws.String("Services per cluster"), Value: aws.Float64(5000)},
+}
+
+type mockSQClient struct {
+	servicequotasiface.ServiceQuotasAPI
+	t   *testing.T
+	err error
+}
+
+func newmockSQClient(t *testing.T, err error) servicequotasiface.ServiceQuotasAPI {
+	return &mockSQClient{
+		t:   t,
+		err: err,
+	}
+}
+
+func (m *mockSQClient) ListServiceQuotasWithContext(ctx aws.Context, input *servicequotas.ListServiceQuotasInput, opts ...request.Option) (*servicequotas.ListServiceQuotasOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if aws.StringValue(input.ServiceCode) != "ecs" {
+		return nil, awserr.New(servicequotas.ErrCodeNoSuchResourceException, "service not found", nil)
+	}
+
+	if aws.StringValue(input.NextToken) == "" {
+		return &servicequotas.ListServiceQuotasOutput{
+			Quotas:    testAppliedQuotas[0],
+			NextToken: aws.String("next"),
+		}, nil
+	}
+
+	return &servicequotas.ListServiceQuotasOutput{Quotas: testAppliedQuotas[1]}, nil
+}
+
+func (m *mockSQClient) ListAWSDefaultServiceQuotasWithContext(ctx aws.Context, input *servicequotas.ListAWSDefaultServiceQuotasInput, opts ...request.Option) (*servicequotas.ListAWSDefaultServiceQuotasOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if aws.StringValue(input.ServiceCode) != "ecs" {
+		return nil, awserr.New(servicequotas.ErrCodeNoSuchResourceException, "service not found", nil)
+	}
+
+	return &servicequotas.ListAWSDefaultServiceQuotasOutput{Quotas: testDefaultQuotas}, nil
+}
+
+func TestNewSession(t *testing.T) {
+	s := NewSession(common.Account{})
+	to := reflect.TypeOf(s).String()
+	if to != "servicequotas.ServiceQuotas" {
+		t.Errorf("expected type to be 'servicequotas.ServiceQuotas', got %s", to)
+	}
+}
+
+func TestServiceQuotas_ListServiceQuotas(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceCode string
+		err         error
+		want        []*servicequotas.ServiceQuota
+		wantCode    string
+	}{
+		{
+			name:        "quotas across pages",
+			serviceCode: "ecs",
+			want:        append(append([]*servicequotas.ServiceQuota{}, testAppliedQuotas[0]...), testAppliedQuotas[1]...),
+		},
+		{
+			name:     "empty service code",
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:        "unknown service code",
+			serviceCode: "nope",
+			wantCode:    apierror.ErrNotFound,
+		},
+		{
+			name:        "service quotas error",
+			serviceCode: "ecs",
+			err:         awserr.New(servicequotas.ErrCodeAccessDeniedException, "denied", nil),
+			wantCode:    apierror.ErrForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := ServiceQuotas{Service: newmockSQClient(t, tt.err)}
+
+			got, err := s.ListServiceQuotas(context.TODO(), tt.serviceCode)
+			if tt.wantCode != "" {
+				aerr, ok := err.(apierror.Error)
+				if !ok {
+					t.Fatalf("expected apierror.Error, got %v", err)
+				}
+
+				if aerr.Code != tt.wantCode {
+					t.Errorf("expected error code %s, got %s", tt.wantCode, aerr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ServiceQuotas.ListServiceQuotas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceQuotas_ListDefaultServiceQuotas(t *testing.T) {
+	s := ServiceQuotas{Service: newmockSQClient(t, nil)}
+
+	got, err := s.ListDefaultServiceQuotas(context.TODO(), "ecs")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if !reflect.DeepEqual(got, testDefaultQuotas) {
+		t.Errorf("ServiceQuotas.ListDefaultServiceQuotas() = %v, want %v", got, testDefaultQuotas)
+	}
+
+	if _, err := s.ListDefaultServiceQuotas(context.TODO(), ""); err == nil {
+		t.Error("expected error for empty service code, got nil")
+	}
+}
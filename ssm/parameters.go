@@ -3,10 +3,12 @@ package ssm
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/YaleSpinup/apierror"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	log "github.com/sirupsen/logrus"
 )
@@ -105,6 +107,36 @@ func (s *SSM) GetParameter(ctx context.Context, prefix, name string) (*ssm.Param
 	return out.Parameter, nil
 }
 
+// parameterLabel matches a valid parameter label, labels can't begin with a number
+var parameterLabel = regexp.MustCompile(`^[a-zA-Z._-][a-zA-Z0-9._-]{0,99}$`)
+
+// GetParameterByLabel gets the details of the version of a parameter with the given label (ie. production).  A label
+// that isn't attached to any version of the parameter is not found.
+func (s *SSM) GetParameterByLabel(ctx context.Context, prefix, name, label string) (*ssm.Parameter, error) {
+	if prefix == "" || name == "" || label == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	if !parameterLabel.MatchString(label) {
+		msg := fmt.Sprintf("invalid parameter label '%s'", label)
+		return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	path := fmt.Sprintf("%s/%s:%s", prefix, name, label)
+
+	log.Infof("getting a ssm parameter store param with path %s", path)
+
+	out, err := s.Service.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(path),
+		WithDecryption: aws.Bool(false),
+	})
+	if err != nil {
+		return nil, ErrCode(fmt.Sprintf("failed to get parameter with label %s", label), err)
+	}
+
+	return out.Parameter, nil
+}
+
 // CreateParameter creates a new parameter
 func (s *SSM) CreateParameter(ctx context.Context, input *ssm.PutParameterInput) error {
 	if input == nil {
@@ -115,6 +147,11 @@ func (s *SSM) CreateParameter(ctx context.Context, input *ssm.PutParameterInput)
 
 	_, err := s.Service.PutParameterWithContext(ctx, input)
 	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ssm.ErrCodeParameterAlreadyExists {
+			msg := fmt.Sprintf("parameter %s already exists, use update to change its value", aws.StringValue(input.Name))
+			return apierror.New(apierror.ErrConflict, msg, aerr)
+		}
+
 		return ErrCode("failed to create parameter", err)
 	}
 
@@ -162,20 +199,54 @@ func (s *SSM) ListParameterTags(ctx context.Context, prefix, name string) ([]*ss
 	return out.TagList, nil
 }
 
-// UpdateParameter creates a new parameter
-func (s *SSM) UpdateParameter(ctx context.Context, input *ssm.PutParameterInput) error {
+// UpdateParameter creates a new parameter and returns the new version of the parameter
+func (s *SSM) UpdateParameter(ctx context.Context, input *ssm.PutParameterInput) (int64, error) {
 	if input == nil {
-		return apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+		return 0, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
 	}
 
 	log.Infof("creating ssm parameter store params with name %s", aws.StringValue(input.Name))
 
-	_, err := s.Service.PutParameterWithContext(ctx, input)
+	out, err := s.Service.PutParameterWithContext(ctx, input)
 	if err != nil {
-		return ErrCode("failed to create parameter", err)
+		return 0, ErrCode("failed to create parameter", err)
 	}
 
-	return nil
+	return aws.Int64Value(out.Version), nil
+}
+
+// MaxParameterVersionLabels is the maximum number of labels that can be attached to a parameter version
+const MaxParameterVersionLabels = 10
+
+// LabelParameterVersion attaches the labels to a version of a parameter, moving them from any other version of the
+// parameter they're attached to.  Labels that don't meet the label requirements aren't attached and are returned.
+func (s *SSM) LabelParameterVersion(ctx context.Context, name string, version int64, labels []string) ([]string, error) {
+	if name == "" || version <= 0 || len(labels) == 0 {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	if len(labels) > MaxParameterVersionLabels {
+		msg := fmt.Sprintf("too many labels, a parameter version can have up to %d labels", MaxParameterVersionLabels)
+		return nil, apierror.New(apierror.ErrBadRequest, msg, nil)
+	}
+
+	log.Infof("labeling ssm parameter %s version %d with %s", name, version, strings.Join(labels, ", "))
+
+	out, err := s.Service.LabelParameterVersionWithContext(ctx, &ssm.LabelParameterVersionInput{
+		Name:             aws.String(name),
+		ParameterVersion: aws.Int64(version),
+		Labels:           aws.StringSlice(labels),
+	})
+	if err != nil {
+		return nil, ErrCode(fmt.Sprintf("failed to label parameter %s version %d", name, version), err)
+	}
+
+	invalid := aws.StringValueSlice(out.InvalidLabels)
+	if len(invalid) > 0 {
+		log.Warnf("invalid labels for ssm parameter %s version %d: %s", name, version, strings.Join(invalid, ", "))
+	}
+
+	return invalid, nil
 }
 
 // UpdateParameterTags updates the tags for the parameter
@@ -197,3 +268,98 @@ func (s *SSM) UpdateParameterTags(ctx context.Context, id string, tags []*ssm.Ta
 
 	return nil
 }
+
+// GetParameters gets the named parameters under a path, optionally with their decrypted values.  Parameters
+// are fetched in batches of 10 (the most allowed by the api) and the results are aggregated.  The names of the
+// returned parameters, and of the invalid (ie. missing) parameters returned separately, are relative to the path.
+func (s *SSM) GetParameters(ctx context.Context, path string, names []string, decrypt bool) ([]*ssm.Parameter, []string, error) {
+	if path == "" {
+		return nil, nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	log.Infof("getting %d ssm parameter store params with path %s", len(names), path)
+
+	params := []*ssm.Parameter{}
+	invalid := []string{}
+	for i := 0; i < len(names); i += 10 {
+		end := i + 10
+		if end > len(names) {
+			end = len(names)
+		}
+
+		batch := make([]*string, 0, end-i)
+		for _, n := range names[i:end] {
+			batch = append(batch, aws.String(fmt.Sprintf("%s/%s", path, n)))
+		}
+
+		out, err := s.Service.GetParametersWithContext(ctx, &ssm.GetParametersInput{
+			Names:          batch,
+			WithDecryption: aws.Bool(decrypt),
+		})
+		if err != nil {
+			return nil, nil, ErrCode("failed to get parameters", err)
+		}
+
+		for _, n := range out.InvalidParameters {
+			invalid = append(invalid, strings.TrimPrefix(aws.StringValue(n), path+"/"))
+		}
+
+		for _, p := range out.Parameters {
+			name := aws.StringValue(p.Name)
+			if !strings.HasPrefix(name, path+"/") {
+				log.Warnf("ignoring parameter %s outside of path %s", name, path)
+				continue
+			}
+
+			p.Name = aws.String(strings.TrimPrefix(name, path+"/"))
+			params = append(params, p)
+		}
+	}
+
+	return params, invalid, nil
+}
+
+// GetParameterHistory gets all of the versions of a parameter, optionally with their decrypted values.  The
+// names of the returned versions are relative to the prefix.
+func (s *SSM) GetParameterHistory(ctx context.Context, prefix, name string, withDecryption bool) ([]*ssm.ParameterHistory, error) {
+	if prefix == "" || name == "" {
+		return nil, apierror.New(apierror.ErrBadRequest, "invalid input", nil)
+	}
+
+	path := fmt.Sprintf("%s/%s", prefix, name)
+
+	log.Infof("getting history for ssm parameter store param with path %s", path)
+
+	history := []*ssm.ParameterHistory{}
+	next := ""
+	for {
+		input := ssm.GetParameterHistoryInput{
+			Name:           aws.String(path),
+			MaxResults:     aws.Int64(50),
+			WithDecryption: aws.Bool(withDecryption),
+		}
+
+		if next != "" {
+			input.NextToken = aws.String(next)
+		}
+
+		out, err := s.Service.GetParameterHistoryWithContext(ctx, &input)
+		if err != nil {
+			return nil, ErrCode("failed to get parameter history", err)
+		}
+
+		for _, p := range out.Parameters {
+			p.Name = aws.String(name)
+			history = append(history, p)
+		}
+
+		next = aws.StringValue(out.NextToken)
+		if next == "" {
+			break
+		}
+	}
+
+	log.Debugf("returning %d versions for %s", len(history), path)
+
+	return history, nil
+}
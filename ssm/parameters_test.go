@@ -3,7 +3,9 @@ package ssm
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -132,8 +134,21 @@ func (m *mockSSMClient) GetParameterWithContext(ctx context.Context, input *ssm.
 		return nil, m.err
 	}
 
+	name := aws.StringValue(input.Name)
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		if label, ok := testParamLabels[name]; ok {
+			return &ssm.GetParameterOutput{
+				Parameter: label,
+			}, nil
+		}
+
+		if strings.HasPrefix(name[:i], org+"/"+prefix) {
+			return &ssm.GetParameterOutput{}, awserr.New(ssm.ErrCodeParameterVersionNotFound, "version not found", nil)
+		}
+	}
+
 	for _, p := range []testParam{testParam1, testParam2, testParam3} {
-		if org+"/"+prefix+"/"+aws.StringValue(p.Param.Name) == aws.StringValue(input.Name) {
+		if org+"/"+prefix+"/"+aws.StringValue(p.Param.Name) == name {
 			return &ssm.GetParameterOutput{
 				Parameter: p.Param,
 			}, nil
@@ -143,12 +158,84 @@ func (m *mockSSMClient) GetParameterWithContext(ctx context.Context, input *ssm.
 	return &ssm.GetParameterOutput{}, awserr.New(ssm.ErrCodeParameterNotFound, "not found", nil)
 }
 
+// testParamLabels are the labeled parameter versions returned by the mock, by name:label
+var testParamLabels = map[string]*ssm.Parameter{
+	org + "/" + prefix + "//newsecret1:production": {
+		ARN:              testParam1.Param.ARN,
+		LastModifiedDate: aws.Time(now.Add(-time.Hour)),
+		Name:             testParam1.Param.Name,
+		Selector:         aws.String(":production"),
+		Type:             aws.String("SecureString"),
+		Value:            aws.String("wwwwwwww"),
+		Version:          aws.Int64(2),
+	},
+}
+
+func (m *mockSSMClient) GetParametersWithContext(ctx context.Context, input *ssm.GetParametersInput, opts ...request.Option) (*ssm.GetParametersOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if len(input.Names) > 10 {
+		return nil, awserr.New("ValidationException", "too many names", nil)
+	}
+
+	value := "value-of-"
+	if !aws.BoolValue(input.WithDecryption) {
+		value = "ciphertext-of-"
+	}
+
+	out := &ssm.GetParametersOutput{}
+	for _, n := range input.Names {
+		name := aws.StringValue(n)
+		switch {
+		case strings.HasSuffix(name, "/missing"), strings.HasSuffix(name, "/invalid"):
+			out.InvalidParameters = append(out.InvalidParameters, n)
+		case strings.HasSuffix(name, "/escaped"):
+			out.Parameters = append(out.Parameters, &ssm.Parameter{Name: aws.String("/other/escaped"), Value: aws.String("nope")})
+		default:
+			out.Parameters = append(out.Parameters, &ssm.Parameter{Name: n, Value: aws.String(value + name)})
+		}
+	}
+
+	return out, nil
+}
+
 func (m *mockSSMClient) PutParameterWithContext(ctx context.Context, input *ssm.PutParameterInput, opts ...request.Option) (*ssm.PutParameterOutput, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 
-	return &ssm.PutParameterOutput{}, nil
+	if !aws.BoolValue(input.Overwrite) {
+		for _, p := range []testParam{testParam1, testParam2, testParam3} {
+			if aws.StringValue(p.Param.Name) == aws.StringValue(input.Name) {
+				return nil, awserr.New(ssm.ErrCodeParameterAlreadyExists, "The parameter already exists.", nil)
+			}
+		}
+	}
+
+	return &ssm.PutParameterOutput{Version: aws.Int64(4)}, nil
+}
+
+func (m *mockSSMClient) LabelParameterVersionWithContext(ctx context.Context, input *ssm.LabelParameterVersionInput, opts ...request.Option) (*ssm.LabelParameterVersionOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if aws.Int64Value(input.ParameterVersion) > 3 {
+		return nil, awserr.New(ssm.ErrCodeParameterVersionNotFound, "version not found", nil)
+	}
+
+	// labels can't begin with a number, aws or ssm
+	out := &ssm.LabelParameterVersionOutput{ParameterVersion: input.ParameterVersion}
+	for _, l := range aws.StringValueSlice(input.Labels) {
+		lower := strings.ToLower(l)
+		if strings.HasPrefix(lower, "aws") || strings.HasPrefix(lower, "ssm") || strings.IndexAny(l[:1], "0123456789") == 0 {
+			out.InvalidLabels = append(out.InvalidLabels, aws.String(l))
+		}
+	}
+
+	return out, nil
 }
 
 func (m *mockSSMClient) DeleteParameterWithContext(ctx context.Context, input *ssm.DeleteParameterInput, opts ...request.Option) (*ssm.DeleteParameterOutput, error) {
@@ -309,6 +396,42 @@ func TestGetParameter(t *testing.T) {
 	}
 }
 
+func TestGetParameterByLabel(t *testing.T) {
+	p := SSM{Service: newmockSSMClient(t, nil)}
+	expected := testParamLabels[org+"/"+prefix+"//newsecret1:production"]
+
+	out, err := p.GetParameterByLabel(context.TODO(), org+"/"+prefix, aws.StringValue(testParam1.Param.Name), "production")
+	if err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	if !reflect.DeepEqual(expected, out) {
+		t.Errorf("expected %+v, got %+v", expected, out)
+	}
+
+	// test label that doesn't exist
+	_, err = p.GetParameterByLabel(context.TODO(), org+"/"+prefix, aws.StringValue(testParam1.Param.Name), "staging")
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrNotFound {
+		t.Errorf("expected not found error for missing label, got %v", err)
+	}
+
+	// test invalid label
+	_, err = p.GetParameterByLabel(context.TODO(), org+"/"+prefix, aws.StringValue(testParam1.Param.Name), "1:production")
+	if aerr, ok := err.(apierror.Error); !ok || aerr.Code != apierror.ErrBadRequest {
+		t.Errorf("expected bad request error for invalid label, got %v", err)
+	}
+
+	// test empty label
+	if _, err = p.GetParameterByLabel(context.TODO(), org+"/"+prefix, "foobar", ""); err == nil {
+		t.Error("expected error for empty label, got nil")
+	}
+
+	p.Service.(*mockSSMClient).err = awserr.New(ssm.ErrCodeInternalServerError, "Internal Error", nil)
+	if _, err = p.GetParameterByLabel(context.TODO(), org+"/"+prefix, "foobar", "production"); err == nil {
+		t.Error("expected error for internal error, got nil")
+	}
+}
+
 func TestCreateParameter(t *testing.T) {
 	p := SSM{Service: newmockSSMClient(t, nil)}
 
@@ -316,13 +439,28 @@ func TestCreateParameter(t *testing.T) {
 		t.Errorf("expected nil error, not %s", err)
 	}
 
+	// test a fresh create
+	if err := p.CreateParameter(context.TODO(), &ssm.PutParameterInput{Name: aws.String("/newsecret4")}); err != nil {
+		t.Errorf("expected nil error, not %s", err)
+	}
+
+	// test create over an existing parameter
+	err := p.CreateParameter(context.TODO(), &ssm.PutParameterInput{Name: testParam1.Param.Name})
+	if aerr, ok := err.(apierror.Error); ok {
+		if aerr.Code != apierror.ErrConflict {
+			t.Errorf("expected error code %s, got: %s", apierror.ErrConflict, aerr.Code)
+		}
+	} else {
+		t.Errorf("expected apierror.Error, got: %s", reflect.TypeOf(err).String())
+	}
+
 	// test nil input
 	if err := p.CreateParameter(context.TODO(), nil); err == nil {
 		t.Error("expected error for nil input, got nil")
 	}
 
 	p.Service.(*mockSSMClient).err = awserr.New(ssm.ErrCodeInternalServerError, "Internal Error", nil)
-	err := p.CreateParameter(context.TODO(), &ssm.PutParameterInput{})
+	err = p.CreateParameter(context.TODO(), &ssm.PutParameterInput{})
 	if aerr, ok := err.(apierror.Error); ok {
 		if aerr.Code != apierror.ErrInternalError {
 			t.Errorf("expected error code %s, got: %s", apierror.ErrInternalError, aerr.Code)
@@ -400,17 +538,22 @@ func TestListParameterTags(t *testing.T) {
 func TestUpdateParameter(t *testing.T) {
 	p := SSM{Service: newmockSSMClient(t, nil)}
 
-	if err := p.UpdateParameter(context.TODO(), &ssm.PutParameterInput{}); err != nil {
+	version, err := p.UpdateParameter(context.TODO(), &ssm.PutParameterInput{})
+	if err != nil {
 		t.Errorf("expected nil error, not %s", err)
 	}
 
+	if version != 4 {
+		t.Errorf("expected version 4, got %d", version)
+	}
+
 	// test nil input
-	if err := p.UpdateParameter(context.TODO(), nil); err == nil {
+	if _, err := p.UpdateParameter(context.TODO(), nil); err == nil {
 		t.Error("expected error for nil input, got nil")
 	}
 
 	p.Service.(*mockSSMClient).err = awserr.New(ssm.ErrCodeInternalServerError, "Internal Error", nil)
-	err := p.UpdateParameter(context.TODO(), &ssm.PutParameterInput{})
+	_, err = p.UpdateParameter(context.TODO(), &ssm.PutParameterInput{})
 	if aerr, ok := err.(apierror.Error); ok {
 		if aerr.Code != apierror.ErrInternalError {
 			t.Errorf("expected error code %s, got: %s", apierror.ErrInternalError, aerr.Code)
@@ -420,6 +563,82 @@ func TestUpdateParameter(t *testing.T) {
 	}
 }
 
+func TestLabelParameterVersion(t *testing.T) {
+	name := org + "/" + prefix + "/newsecret1"
+
+	tests := []struct {
+		name        string
+		version     int64
+		labels      []string
+		err         error
+		wantInvalid []string
+		wantCode    string
+	}{
+		{
+			name:        "label version",
+			version:     3,
+			labels:      []string{"production", "v3"},
+			wantInvalid: []string{},
+		},
+		{
+			name:        "invalid labels",
+			version:     2,
+			labels:      []string{"production", "aws-prod", "1st"},
+			wantInvalid: []string{"aws-prod", "1st"},
+		},
+		{
+			name:     "missing version",
+			version:  5,
+			labels:   []string{"production"},
+			wantCode: apierror.ErrNotFound,
+		},
+		{
+			name:     "no labels",
+			version:  3,
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "no version",
+			labels:   []string{"production"},
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "too many labels",
+			version:  3,
+			labels:   strings.Split("a,b,c,d,e,f,g,h,i,j,k", ","),
+			wantCode: apierror.ErrBadRequest,
+		},
+		{
+			name:     "label limit exceeded",
+			version:  3,
+			labels:   []string{"production"},
+			err:      awserr.New(ssm.ErrCodeParameterVersionLabelLimitExceeded, "too many labels", nil),
+			wantCode: apierror.ErrLimitExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := SSM{Service: newmockSSMClient(t, tt.err)}
+
+			invalid, err := p.LabelParameterVersion(context.TODO(), name, tt.version, tt.labels)
+			if tt.wantCode != "" {
+				if aerr, ok := err.(apierror.Error); !ok || aerr.Code != tt.wantCode {
+					t.Errorf("expected %s error, got %v", tt.wantCode, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+
+			if !reflect.DeepEqual(invalid, tt.wantInvalid) {
+				t.Errorf("expected invalid labels %v, got %v", tt.wantInvalid, invalid)
+			}
+		})
+	}
+}
+
 func TestUpdateParameterTags(t *testing.T) {
 	p := SSM{Service: newmockSSMClient(t, nil)}
 
@@ -449,3 +668,156 @@ func TestUpdateParameterTags(t *testing.T) {
 		t.Errorf("expected apierror.Error, got: %s", reflect.TypeOf(err).String())
 	}
 }
+
+func TestGetParameters(t *testing.T) {
+	p := SSM{Service: newmockSSMClient(t, nil)}
+	path := "/" + org + "/" + prefix
+
+	// 12 names span two batches
+	names := []string{}
+	expected := []*ssm.Parameter{}
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("param%d", i)
+		names = append(names, name)
+		expected = append(expected, &ssm.Parameter{Name: aws.String(name), Value: aws.String("ciphertext-of-" + path + "/" + name)})
+	}
+
+	out, invalid, err := p.GetParameters(context.TODO(), path, names, false)
+	if err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	if !reflect.DeepEqual(expected, out) {
+		t.Errorf("expected %+v, got %+v", expected, out)
+	}
+
+	if len(invalid) != 0 {
+		t.Errorf("expected no invalid parameters, got %+v", invalid)
+	}
+
+	// test a mix of valid and invalid names with decryption
+	out, invalid, err = p.GetParameters(context.TODO(), path, []string{"param0", "missing", "param1", "invalid"}, true)
+	if err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	expected = []*ssm.Parameter{
+		{Name: aws.String("param0"), Value: aws.String("value-of-" + path + "/param0")},
+		{Name: aws.String("param1"), Value: aws.String("value-of-" + path + "/param1")},
+	}
+	if !reflect.DeepEqual(expected, out) {
+		t.Errorf("expected %+v, got %+v", expected, out)
+	}
+
+	if want := []string{"missing", "invalid"}; !reflect.DeepEqual(want, invalid) {
+		t.Errorf("expected invalid parameters %+v, got %+v", want, invalid)
+	}
+
+	// test empty path
+	if _, _, err = p.GetParameters(context.TODO(), "", names, false); err == nil {
+		t.Error("expected error for empty path, got nil")
+	}
+
+	// ssm.ErrCodeInternalServiceError
+	p.Service.(*mockSSMClient).err = awserr.New(ssm.ErrCodeInternalServerError, "Internal Error", nil)
+	_, _, err = p.GetParameters(context.TODO(), path, names, false)
+	if aerr, ok := err.(apierror.Error); ok {
+		if aerr.Code != apierror.ErrInternalError {
+			t.Errorf("expected error code %s, got: %s", apierror.ErrInternalError, aerr.Code)
+		}
+	} else {
+		t.Errorf("expected apierror.Error, got: %s", reflect.TypeOf(err).String())
+	}
+}
+
+func (m *mockSSMClient) GetParameterHistoryWithContext(ctx context.Context, input *ssm.GetParameterHistoryInput, opts ...request.Option) (*ssm.GetParameterHistoryOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if aws.StringValue(input.Name) != org+"/"+prefix+"/"+aws.StringValue(testParam1.Param.Name) {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, "not found", nil)
+	}
+
+	history := []*ssm.ParameterHistory{}
+	for v := int64(1); v <= aws.Int64Value(testParam1.Param.Version); v++ {
+		value := "encrypted"
+		if aws.BoolValue(input.WithDecryption) {
+			value = fmt.Sprintf("value%d", v)
+		}
+
+		history = append(history, &ssm.ParameterHistory{
+			LastModifiedDate: aws.Time(now),
+			Name:             input.Name,
+			Type:             aws.String("SecureString"),
+			Value:            aws.String(value),
+			Version:          aws.Int64(v),
+		})
+	}
+	history[len(history)-1].Labels = aws.StringSlice([]string{"current"})
+
+	// return the first two versions in the first page
+	if aws.StringValue(input.NextToken) == "" {
+		return &ssm.GetParameterHistoryOutput{Parameters: history[:2], NextToken: aws.String("2")}, nil
+	}
+
+	return &ssm.GetParameterHistoryOutput{Parameters: history[2:]}, nil
+}
+
+func TestGetParameterHistory(t *testing.T) {
+	p := SSM{Service: newmockSSMClient(t, nil)}
+
+	for _, withDecryption := range []bool{true, false} {
+		out, err := p.GetParameterHistory(context.TODO(), org+"/"+prefix, aws.StringValue(testParam1.Param.Name), withDecryption)
+		if err != nil {
+			t.Errorf("unexpected error %s", err)
+		}
+
+		if len(out) != 3 {
+			t.Fatalf("expected 3 versions, got %d", len(out))
+		}
+
+		for i, h := range out {
+			if aws.Int64Value(h.Version) != int64(i+1) {
+				t.Errorf("expected version %d, got %d", i+1, aws.Int64Value(h.Version))
+			}
+
+			if aws.StringValue(h.Name) != aws.StringValue(testParam1.Param.Name) {
+				t.Errorf("expected name %s, got %s", aws.StringValue(testParam1.Param.Name), aws.StringValue(h.Name))
+			}
+
+			value := "encrypted"
+			if withDecryption {
+				value = fmt.Sprintf("value%d", i+1)
+			}
+
+			if aws.StringValue(h.Value) != value {
+				t.Errorf("expected value %s, got %s", value, aws.StringValue(h.Value))
+			}
+		}
+
+		if labels := aws.StringValueSlice(out[2].Labels); !reflect.DeepEqual(labels, []string{"current"}) {
+			t.Errorf("expected labels [current] on the latest version, got %v", labels)
+		}
+	}
+
+	// test param that doesn't exist
+	_, err := p.GetParameterHistory(context.TODO(), org+"/"+prefix, "foobar", false)
+	if aerr, ok := err.(apierror.Error); ok {
+		if aerr.Code != apierror.ErrNotFound {
+			t.Errorf("expected error code %s, got: %s", apierror.ErrNotFound, aerr.Code)
+		}
+	} else {
+		t.Errorf("expected apierror.Error, got: %s", reflect.TypeOf(err).String())
+	}
+
+	// test empty prefix
+	if _, err = p.GetParameterHistory(context.TODO(), "", "foobar", false); err == nil {
+		t.Error("expected error for empty prefix, got nil")
+	}
+
+	// test empty name
+	if _, err = p.GetParameterHistory(context.TODO(), org+"/"+prefix, "", false); err == nil {
+		t.Error("expected error for empty name, got nil")
+	}
+}
@@ -2,8 +2,6 @@ package ssm
 
 import (
 	"github.com/YaleSpinup/ecs-api/common"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
@@ -20,10 +18,7 @@ type SSM struct {
 func NewSession(account common.Account) SSM {
 	s := SSM{}
 	log.Infof("creating new aws session for ssm with key id %s in region %s", account.Akid, account.Region)
-	sess := session.Must(session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(account.Akid, account.Secret, ""),
-		Region:      aws.String(account.Region),
-	}))
+	sess := session.Must(session.NewSession(account.AWSConfig()))
 	s.Service = ssm.New(sess)
 	s.DefaultKmsKeyId = account.DefaultKmsKeyId
 	return s